@@ -2,20 +2,73 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
+	"ironarchive/internal/access"
+	"ironarchive/internal/api"
+	"ironarchive/internal/api/handlers"
 	"ironarchive/internal/config"
 	"ironarchive/internal/database"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/extract"
+	"ironarchive/internal/graph"
+	"ironarchive/internal/health"
+	"ironarchive/internal/models"
+	"ironarchive/internal/progress"
+	"ironarchive/internal/queue"
+	"ironarchive/internal/scheduler"
+	"ironarchive/internal/search"
+	"ironarchive/internal/services"
+	"ironarchive/internal/storage"
+	"ironarchive/internal/threads"
 	"ironarchive/internal/utils"
+	"ironarchive/internal/vault"
+	"ironarchive/internal/workers"
 
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
 func main() {
+	// Parse command-line flags
+	migrateOnly := flag.Bool("migrate-only", false, "Apply database migrations and exit")
+	noMigrate := flag.Bool("no-migrate", false, "Skip automatic database migrations on startup")
+	rotateKeys := flag.Bool("rotate-credential-keys", false, "Re-encrypt all tenant credentials under fresh data keys and exit")
+	syncMailbox := flag.String("sync-mailbox", "", "Archive the mailbox with this ID from Microsoft Graph and exit")
+	reindex := flag.String("reindex", "", "Rebuild the search index for \"all\" emails or the tenant with this ID and exit")
+	extractText := flag.Bool(strings.TrimPrefix(extract.ChildFlag, "--"), false, "Extract the text of a document read from stdin (internal: attachment extraction sandbox)")
+	flag.Parse()
+
+	// The extraction sandbox runs this binary with an empty environment, so
+	// it must not load configuration
+	if *extractText {
+		os.Exit(extract.ServeChild(os.Stdin, os.Stdout))
+	}
+
+	if *migrateOnly && *noMigrate {
+		fmt.Fprintln(os.Stderr, "--migrate-only and --no-migrate cannot be used together")
+		os.Exit(2)
+	}
+	if *migrateOnly && *rotateKeys {
+		fmt.Fprintln(os.Stderr, "--migrate-only and --rotate-credential-keys cannot be used together")
+		os.Exit(2)
+	}
+	if *syncMailbox != "" && (*migrateOnly || *rotateKeys) {
+		fmt.Fprintln(os.Stderr, "--sync-mailbox cannot be combined with --migrate-only or --rotate-credential-keys")
+		os.Exit(2)
+	}
+	if *reindex != "" && (*migrateOnly || *rotateKeys || *syncMailbox != "") {
+		fmt.Fprintln(os.Stderr, "--reindex cannot be combined with --migrate-only, --rotate-credential-keys or --sync-mailbox")
+		os.Exit(2)
+	}
+
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -35,6 +88,13 @@ func main() {
 	}
 	defer logger.Sync()
 
+	// Load vault master keys early so a misconfigured key fails before anything starts
+	vaultKeys, err := vault.LoadKeyring(cfg.VaultMasterKeys, cfg.VaultMasterKeysFile)
+	if err != nil {
+		logger.Error("Failed to load vault master keys", zap.Error(err))
+		os.Exit(1)
+	}
+
 	logger.Info("IronArchive server starting...",
 		zap.String("version", "1.0.0"),
 		zap.String("port", cfg.ServerPort),
@@ -47,14 +107,6 @@ func main() {
 		logger.Error("Failed to create PostgreSQL connection", zap.Error(err))
 		os.Exit(1)
 	}
-	defer pgConn.Close()
-
-	// Validate PostgreSQL connection
-	if err := pgConn.Ping(ctx); err != nil {
-		logger.Error("Failed to ping PostgreSQL", zap.Error(err))
-		os.Exit(1)
-	}
-	logger.Info("PostgreSQL connection successful")
 
 	// Initialize Redis connection
 	logger.Info("Connecting to Redis...", zap.String("url", maskConnectionString(cfg.RedisURL)))
@@ -63,58 +115,367 @@ func main() {
 		logger.Error("Failed to create Redis connection", zap.Error(err))
 		os.Exit(1)
 	}
-	defer func() {
+
+	// Initialize Meilisearch connection
+	logger.Info("Connecting to Meilisearch...", zap.String("url", cfg.MeilisearchURL))
+	meiliConn, err := database.NewMeilisearchConnection(cfg.MeilisearchURL, cfg.MeiliMasterKey, logger)
+	if err != nil {
+		logger.Error("Failed to create Meilisearch connection", zap.Error(err))
+		os.Exit(1)
+	}
+
+	// Initialize archive blob storage
+	logger.Info("Opening archive storage...", zap.String("backend", cfg.StorageBackend))
+	archive, err := storage.Open(cfg)
+	if err != nil {
+		logger.Error("Failed to open archive storage", zap.Error(err))
+		os.Exit(1)
+	}
+
+	// Close dependencies in reverse order of initialization so in-flight work can finish
+	closeConnections := func() {
+		meiliConn.Close()
 		if err := redisConn.Close(); err != nil {
 			logger.Warn("Failed to close Redis connection", zap.Error(err))
 		}
-	}()
+		pgConn.Close()
+	}
+
+	// Dependency health checks; only PostgreSQL is required to serve the
+	// archive, and Meilisearch may be down while PostgreSQL answers searches
+	dependencies := []health.Dependency{
+		{Name: "database", Pinger: pgConn, Timeout: cfg.HealthCheckTimeout, Critical: true},
+		{Name: "redis", Pinger: redisConn, Timeout: cfg.HealthCheckTimeout},
+		{Name: "meilisearch", Pinger: meiliConn, Timeout: cfg.MeilisearchTimeout, Optional: cfg.SearchPostgresFallback},
+		{Name: "storage", Pinger: archive, Timeout: cfg.HealthCheckTimeout},
+	}
+
+	// Migration-only and key rotation runs need nothing but the database
+	waitFor := dependencies
+	if *migrateOnly || *rotateKeys {
+		waitFor = []health.Dependency{dependencies[0]}
+	}
+	// A one-off mailbox sync also writes to archive storage
+	if *syncMailbox != "" {
+		waitFor = []health.Dependency{dependencies[0], dependencies[3]}
+	}
+	// A reindex run reads the archive database and writes to Meilisearch,
+	// which it cannot do without
+	if *reindex != "" {
+		meili := dependencies[2]
+		meili.Optional = false
+		waitFor = []health.Dependency{dependencies[0], dependencies[1], meili}
+	}
 
-	// Validate Redis connection
-	if err := redisConn.Ping(ctx); err != nil {
-		logger.Error("Failed to ping Redis", zap.Error(err))
+	// Wait for dependencies with backoff so slow-starting services don't crash-loop the backend
+	startupCtx, stopStartup := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	startup, err := health.WaitForDependencies(startupCtx, waitFor, health.BackoffPolicy{
+		InitialInterval: cfg.StartupRetryInitialInterval,
+		MaxInterval:     cfg.StartupRetryMaxInterval,
+		Multiplier:      cfg.StartupRetryMultiplier,
+		Deadline:        cfg.StartupTimeout,
+	}, cfg.StartupAllowDegraded, logger)
+	if err != nil {
+		stopStartup()
+		logger.Error("Required services unavailable", zap.Error(err))
+		closeConnections()
 		os.Exit(1)
 	}
-	logger.Info("Redis connection successful")
 
-	// Initialize Meilisearch connection
-	logger.Info("Connecting to Meilisearch...", zap.String("url", cfg.MeilisearchURL))
-	meiliConn, err := database.NewMeilisearchConnection(cfg.MeilisearchURL, cfg.MeiliMasterKey, logger)
+	// Apply embedded schema migrations; the advisory lock lets replicas start concurrently
+	if !*noMigrate {
+		if err := pgConn.Migrate(startupCtx); err != nil {
+			stopStartup()
+			logger.Error("Failed to apply database migrations", zap.Error(err))
+			closeConnections()
+			os.Exit(1)
+		}
+	}
+	stopStartup()
+
+	if *migrateOnly {
+		logger.Info("Migrations complete, exiting (--migrate-only)")
+		closeConnections()
+		return
+	}
+
+	store := repositories.NewStore(pgConn.Pool)
+	credentialVault := vault.New(store, vaultKeys, logger)
+
+	if *rotateKeys {
+		logger.Info("Rotating credential encryption keys", zap.String("master_key_id", vaultKeys.ActiveID()))
+		rotateCtx, stopRotate := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		_, err := credentialVault.Rotate(rotateCtx)
+		stopRotate()
+		closeConnections()
+		if err != nil {
+			logger.Error("Credential key rotation failed", zap.Error(err))
+			logger.Sync()
+			os.Exit(1)
+		}
+		return
+	}
+
+	graphClient, err := graph.NewClient(graph.Config{
+		BaseURL:        cfg.GraphBaseURL,
+		LoginURL:       cfg.GraphLoginURL,
+		PageSize:       int(cfg.GraphPageSize),
+		BatchSize:      int(cfg.GraphBatchSize),
+		Timeout:        cfg.GraphTimeout,
+		MaxRetries:     int(cfg.GraphMaxRetries),
+		RetryBaseDelay: cfg.GraphRetryBaseDelay,
+		RetryMaxDelay:  cfg.GraphRetryMaxDelay,
+		Limiter: graph.NewRedisLimiter(redisConn, graph.LimiterConfig{
+			MaxConcurrent: int(cfg.GraphMaxConcurrency),
+			Rate:          int(cfg.GraphRateLimit),
+			Window:        cfg.GraphRateWindow,
+			Lease:         cfg.GraphTimeout + time.Minute,
+		}, logger),
+	})
 	if err != nil {
-		logger.Error("Failed to create Meilisearch connection", zap.Error(err))
+		logger.Error("Failed to create Microsoft Graph client", zap.Error(err))
+		closeConnections()
 		os.Exit(1)
 	}
-	defer meiliConn.Close()
+	syncService := services.NewSyncService(store, credentialVault, graphClient, archive, logger)
+	progressEvents := progress.NewPublisher(redisConn, logger)
+	syncWorker := workers.NewSyncWorker(store, syncService, progressEvents, logger)
+
+	if *syncMailbox != "" {
+		syncCtx, stopSync := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		err := runMailboxSync(syncCtx, store, syncWorker, *syncMailbox)
+		stopSync()
+		closeConnections()
+		if err != nil {
+			logger.Error("Mailbox sync failed", zap.String("mailbox_id", *syncMailbox), zap.Error(err))
+			logger.Sync()
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Search indexing; every replica runs an indexer and one at a time pushes
+	searchIndexer := search.NewIndexer(store.Repositories, search.NewManager(meiliConn, store.Settings, redisConn, logger), search.IndexerConfig{
+		BatchSize:  int(cfg.SearchIndexBatchSize),
+		Interval:   cfg.SearchIndexInterval,
+		MaxRetries: int(cfg.SearchIndexMaxRetries),
+		RetryDelay: cfg.SearchIndexRetryDelay,
+	}, logger)
+
+	if *reindex != "" {
+		reindexCtx, stopReindex := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		err := runReindex(reindexCtx, searchIndexer, *reindex, logger)
+		stopReindex()
+		closeConnections()
+		if err != nil {
+			logger.Error("Search reindex failed", zap.String("target", *reindex), zap.Error(err))
+			logger.Sync()
+			os.Exit(1)
+		}
+		return
+	}
+
+	if startup.Degraded() {
+		logger.Warn("Service connections validated with degraded dependencies", zap.Strings("unavailable", startup.Unavailable))
+	} else {
+		logger.Info("All service connections validated successfully")
+	}
+	checker := health.NewChecker(dependencies...)
 
-	// Validate Meilisearch connection
-	pingCtx, pingCancel := context.WithTimeout(ctx, cfg.MeilisearchTimeout)
-	defer pingCancel()
-	if err := meiliConn.Ping(pingCtx); err != nil {
-		logger.Error("Failed to ping Meilisearch", zap.Error(err))
+	// Start background job workers
+	jobWorkers, err := queue.ParseWorkers(cfg.JobWorkers)
+	if err != nil {
+		logger.Error("Invalid JOB_WORKERS", zap.Error(err))
+		closeConnections()
+		os.Exit(1)
+	}
+	schedulerTimezone, err := time.LoadLocation(cfg.SchedulerTimezone)
+	if err != nil {
+		logger.Error("Invalid SCHEDULER_TIMEZONE", zap.Error(err))
+		closeConnections()
 		os.Exit(1)
 	}
-	logger.Info("Meilisearch connection successful")
+	jobQueue := queue.New(redisConn, store.Jobs, queue.Config{
+		VisibilityTimeout: cfg.JobVisibilityTimeout,
+		MaxDeliveries:     int(cfg.JobMaxDeliveries),
+		MaxAttempts:       int(cfg.JobMaxAttempts),
+		RetryBaseDelay:    cfg.JobRetryBaseDelay,
+		RetryMaxDelay:     cfg.JobRetryMaxDelay,
+		Progress:          progressEvents,
+	}, logger)
+	for _, jobType := range []string{models.JobTypeSyncMailbox, models.JobTypeSyncTenant, models.JobTypeSyncAll} {
+		jobQueue.Register(jobType, jobWorkers[jobType], syncWorker)
+	}
+	queueCtx, stopQueue := context.WithCancel(ctx)
+	queueDone := make(chan struct{})
+	go func() {
+		jobQueue.Run(queueCtx)
+		close(queueDone)
+	}()
+
+	// Start the sync scheduler; every replica runs one and each tick fires once
+	syncScheduler := scheduler.New(store.Repositories, jobQueue, redisConn, scheduler.Config{
+		Timezone:       schedulerTimezone,
+		CatchUp:        cfg.SchedulerCatchUp,
+		CatchUpWindow:  cfg.SchedulerCatchUpWindow,
+		ReloadInterval: cfg.SchedulerReloadInterval,
+		MisfireGrace:   cfg.SchedulerMisfireGrace,
+	}, logger)
+	go syncScheduler.Run(queueCtx)
+
+	// Fan job progress out to event stream clients; stopping it ends open streams
+	progressHub := progress.NewHub(redisConn, logger)
+	go progressHub.Run(queueCtx)
+
+	// Create or migrate the search index, then keep it in step with the
+	// archive; search stays on the previous schema until a migration has
+	// swapped the rebuilt index in
+	go searchIndexer.Run(queueCtx)
+
+	// Thread the emails archived before threading existed; new emails are
+	// threaded as they are archived
+	go threads.NewBackfiller(store, archive, logger).Run(queueCtx)
+
+	// Extract attachment text in sandboxed child processes; recording it
+	// flags the parent emails for the indexer
+	if cfg.ExtractEnabled {
+		sandbox, err := extract.NewSandbox(extract.SandboxConfig{
+			Limits: extract.Limits{
+				MaxTextBytes:     int(cfg.ExtractMaxTextKB) << 10,
+				MaxExpandedBytes: int64(cfg.ExtractMaxExpandedMB) << 20,
+				MaxDepth:         int(cfg.ExtractMaxDepth),
+			},
+			MaxInputBytes: int64(cfg.ExtractMaxInputMB) << 20,
+			Timeout:       cfg.ExtractTimeout,
+			MemoryLimit:   int64(cfg.ExtractMemoryLimitMB) << 20,
+		})
+		if err != nil {
+			logger.Error("Failed to create attachment extraction sandbox", zap.Error(err))
+			closeConnections()
+			os.Exit(1)
+		}
+		extractor := extract.NewExtractor(store.Repositories, archive, sandbox, extract.ExtractorConfig{
+			BatchSize:   int(cfg.ExtractBatchSize),
+			Concurrency: int(cfg.ExtractConcurrency),
+			Interval:    cfg.ExtractInterval,
+		}, logger)
+		go extractor.Run(queueCtx)
+	}
+
+	// Searches are confined to the caller's scope, optionally by Meilisearch
+	// itself, and fall back to PostgreSQL full-text search while it is down
+	searcher := search.NewSearcher(meiliConn, store, search.SearcherConfig{
+		TenantTokens:     cfg.SearchTenantTokens,
+		TokenTTL:         cfg.SearchTenantTokenTTL,
+		Fallback:         cfg.SearchPostgresFallback,
+		FallbackCooldown: cfg.SearchFallbackCooldown,
+	}, logger)
+	scopes := access.NewResolver(store.Repositories)
 
-	logger.Info("All service connections validated successfully")
-	logger.Info(fmt.Sprintf("Server is ready on %s:%s", cfg.ServerHost, cfg.ServerPort))
+	// Start HTTP server
+	server := api.NewServer(cfg, logger, api.Handlers{
+		Health:   handlers.NewHealthHandler(checker, logger),
+		Schedule: handlers.NewScheduleHandler(syncScheduler, store, logger),
+		Jobs:     handlers.NewJobHandler(jobQueue, store, logger),
+		Events:   handlers.NewEventsHandler(progressHub, logger),
+		Search:   handlers.NewSearchHandler(scopes, searcher, logger),
+		Threads:  handlers.NewThreadHandler(scopes, threads.NewReader(store), logger),
+	})
+	serverErr := make(chan error, 1)
+	if err := server.Bind(); err != nil {
+		serverErr <- err
+	} else {
+		logger.Info(fmt.Sprintf("Server is ready on %s", server.Addr()))
+		go func() {
+			serverErr <- server.Serve()
+		}()
+	}
 
-	// Wait for interrupt signal to gracefully shutdown
+	// Wait for interrupt signal or server failure
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
-	<-quit
 
-	logger.Info("Shutting down server...")
+	exitCode := 0
+	select {
+	case sig := <-quit:
+		logger.Info("Shutting down server...", zap.String("signal", sig.String()))
+	case err := <-serverErr:
+		logger.Error("HTTP server failed", zap.Error(err))
+		exitCode = 1
+	}
 
-	// Graceful shutdown
+	// Graceful shutdown: drain HTTP requests and stop job workers, then close dependencies
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer shutdownCancel()
 
-	// Perform cleanup
+	// Running jobs are cancelled and queued again for another instance; open
+	// event streams end so the HTTP server can drain
+	stopQueue()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("HTTP server did not drain before shutdown timeout", zap.Error(err))
+		exitCode = 1
+	}
 	select {
+	case <-queueDone:
 	case <-shutdownCtx.Done():
+		logger.Warn("Job workers did not stop before shutdown timeout")
+		exitCode = 1
+	}
+
+	closeConnections()
+
+	if shutdownCtx.Err() != nil {
 		logger.Warn("Shutdown timeout exceeded")
-	default:
+	} else {
 		logger.Info("Server stopped gracefully")
 	}
+
+	if exitCode != 0 {
+		logger.Sync()
+		os.Exit(exitCode)
+	}
+}
+
+// runMailboxSync records a SYNC_MAILBOX job for the mailbox and processes it
+// in the foreground. Without the queue to settle it, a failure fails the job.
+func runMailboxSync(ctx context.Context, store *repositories.Store, worker *workers.SyncWorker, mailboxID string) error {
+	mailbox, err := store.Mailboxes.GetByID(ctx, mailboxID)
+	if err != nil {
+		return fmt.Errorf("failed to load mailbox: %w", err)
+	}
+	job := &models.Job{
+		Type:      models.JobTypeSyncMailbox,
+		TenantID:  &mailbox.TenantID,
+		MailboxID: &mailbox.ID,
+	}
+	if err := store.Jobs.Create(ctx, job); err != nil {
+		return fmt.Errorf("failed to create sync job: %w", err)
+	}
+	if err := worker.Process(ctx, job); err != nil {
+		if markErr := store.Jobs.MarkFailed(context.WithoutCancel(ctx), job.ID, err.Error()); markErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to mark job failed: %w", markErr))
+		}
+		return err
+	}
+	return nil
+}
+
+// runReindex rebuilds the search index for "all" emails or flags one
+// tenant's emails for the running indexers to re-push
+func runReindex(ctx context.Context, indexer *search.Indexer, target string, logger *zap.Logger) error {
+	var tenantID *string
+	if target != "all" {
+		if _, err := uuid.Parse(target); err != nil {
+			return fmt.Errorf("--reindex takes \"all\" or a tenant ID: %w", err)
+		}
+		tenantID = &target
+	}
+	n, err := indexer.Reindex(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	logger.Info("Search reindex complete", zap.String("target", target), zap.Int64("emails_flagged", n))
+	return nil
 }
 
 // maskConnectionString masks sensitive information in connection strings
@@ -151,4 +512,3 @@ func maskConnectionString(connStr string) string {
 	}
 	return "***"
 }
-
@@ -0,0 +1,87 @@
+// Package access derives the part of the archive a caller may reach from
+// their session.
+package access
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ironarchive/internal/database"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+)
+
+// ErrNoMailbox is returned for users without an archived mailbox
+var ErrNoMailbox = errors.New("user has no archived mailbox")
+
+// Scope is the part of the archive a caller may reach; empty fields do not
+// restrict
+type Scope struct {
+	TenantID  string
+	MailboxID string
+	// Session is the caller the scope belongs to. PostgreSQL queries run as
+	// it, so row-level security backs the scope.
+	Session database.Session
+}
+
+// Includes reports whether a mailbox of a tenant lies within the scope
+func (s Scope) Includes(tenantID, mailboxID string) bool {
+	return (s.TenantID == "" || s.TenantID == tenantID) && (s.MailboxID == "" || s.MailboxID == mailboxID)
+}
+
+// EmailFilter returns the email filter confining listings to the scope
+func (s Scope) EmailFilter() repositories.EmailFilter {
+	var filter repositories.EmailFilter
+	if s.TenantID != "" {
+		filter.TenantID = &s.TenantID
+	}
+	if s.MailboxID != "" {
+		filter.MailboxID = &s.MailboxID
+	}
+	return filter
+}
+
+// SessionRunner runs repository calls in a transaction scoped to a session;
+// *repositories.Store implements it
+type SessionRunner interface {
+	WithSession(ctx context.Context, session database.Session, fn func(repos *repositories.Repositories) error) error
+}
+
+// Resolver derives scopes from users and their mailboxes
+type Resolver struct {
+	users     repositories.UserRepository
+	mailboxes repositories.MailboxRepository
+}
+
+// NewResolver creates a resolver reading users and mailboxes from repos
+func NewResolver(repos *repositories.Repositories) *Resolver {
+	return &Resolver{users: repos.Users, mailboxes: repos.Mailboxes}
+}
+
+// ScopeFor returns what a session may reach: MSP_ADMIN everything,
+// TENANT_ADMIN its tenant and USER the mailbox of its email address
+func (r *Resolver) ScopeFor(ctx context.Context, session database.Session) (Scope, error) {
+	if err := session.Validate(); err != nil {
+		return Scope{}, err
+	}
+	switch session.Role {
+	case models.RoleMSPAdmin:
+		return Scope{Session: session}, nil
+	case models.RoleTenantAdmin:
+		return Scope{TenantID: session.TenantID, Session: session}, nil
+	}
+
+	user, err := r.users.GetByID(ctx, session.UserID)
+	if err != nil {
+		return Scope{}, fmt.Errorf("failed to load user: %w", err)
+	}
+	mailbox, err := r.mailboxes.GetByAddress(ctx, session.TenantID, user.Email)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return Scope{}, ErrNoMailbox
+	}
+	if err != nil {
+		return Scope{}, fmt.Errorf("failed to load mailbox: %w", err)
+	}
+	return Scope{TenantID: session.TenantID, MailboxID: mailbox.ID, Session: session}, nil
+}
@@ -0,0 +1,98 @@
+package access
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ironarchive/internal/database"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+)
+
+const (
+	testTenant   = "11111111-1111-1111-1111-111111111111"
+	testMailbox  = "22222222-2222-2222-2222-222222222222"
+	otherTenant  = "33333333-3333-3333-3333-333333333333"
+	otherMailbox = "44444444-4444-4444-4444-444444444444"
+	userID       = "55555555-5555-5555-5555-555555555555"
+)
+
+type fakeUsers struct {
+	repositories.UserRepository
+	users []models.User
+}
+
+func (f *fakeUsers) GetByID(_ context.Context, id string) (*models.User, error) {
+	for i := range f.users {
+		if f.users[i].ID == id {
+			return &f.users[i], nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+type fakeMailboxes struct {
+	repositories.MailboxRepository
+	mailboxes []models.Mailbox
+}
+
+func (f *fakeMailboxes) GetByAddress(_ context.Context, tenantID, emailAddress string) (*models.Mailbox, error) {
+	for i := range f.mailboxes {
+		if f.mailboxes[i].TenantID == tenantID && strings.EqualFold(f.mailboxes[i].EmailAddress, emailAddress) {
+			return &f.mailboxes[i], nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+// newTestResolver resolves a user whose address has a mailbox in testTenant only
+func newTestResolver() *Resolver {
+	return NewResolver(&repositories.Repositories{
+		Users: &fakeUsers{users: []models.User{{ID: userID, Email: "Alice@acme.test", Role: models.RoleUser}}},
+		Mailboxes: &fakeMailboxes{mailboxes: []models.Mailbox{
+			{ID: testMailbox, TenantID: testTenant, EmailAddress: "alice@acme.test"},
+			{ID: otherMailbox, TenantID: otherTenant, EmailAddress: "eve@other.test"},
+		}},
+	})
+}
+
+// TestScopeFor verifies each role gets its mandatory scope
+func TestScopeFor(t *testing.T) {
+	resolver := newTestResolver()
+	ctx := context.Background()
+
+	msp := database.Session{UserID: userID, Role: models.RoleMSPAdmin}
+	scope, err := resolver.ScopeFor(ctx, msp)
+	require.NoError(t, err)
+	assert.Equal(t, Scope{Session: msp}, scope)
+
+	admin := database.Session{UserID: userID, TenantID: testTenant, Role: models.RoleTenantAdmin}
+	scope, err = resolver.ScopeFor(ctx, admin)
+	require.NoError(t, err)
+	assert.Equal(t, Scope{TenantID: testTenant, Session: admin}, scope)
+
+	user := database.Session{UserID: userID, TenantID: testTenant, Role: models.RoleUser}
+	scope, err = resolver.ScopeFor(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, Scope{TenantID: testTenant, MailboxID: testMailbox, Session: user}, scope)
+
+	// The user's address has no mailbox in another tenant
+	_, err = resolver.ScopeFor(ctx, database.Session{UserID: userID, TenantID: otherTenant, Role: models.RoleUser})
+	assert.ErrorIs(t, err, ErrNoMailbox)
+
+	_, err = resolver.ScopeFor(ctx, database.Session{UserID: userID, Role: models.RoleUser})
+	assert.Error(t, err)
+}
+
+// TestScopeIncludes verifies empty scope fields do not restrict
+func TestScopeIncludes(t *testing.T) {
+	assert.True(t, Scope{}.Includes(otherTenant, otherMailbox))
+	assert.True(t, Scope{TenantID: testTenant}.Includes(testTenant, testMailbox))
+	assert.False(t, Scope{TenantID: testTenant}.Includes(otherTenant, otherMailbox))
+	assert.True(t, Scope{TenantID: testTenant, MailboxID: testMailbox}.Includes(testTenant, testMailbox))
+	assert.False(t, Scope{TenantID: testTenant, MailboxID: testMailbox}.Includes(testTenant, otherMailbox))
+}
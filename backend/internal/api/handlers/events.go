@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/api/middleware"
+	"ironarchive/internal/progress"
+	apperrors "ironarchive/pkg/errors"
+)
+
+// DefaultKeepAlive is how often an idle event stream sends a comment so
+// proxies keep the connection open
+const DefaultKeepAlive = 15 * time.Second
+
+// ProgressSource provides live job progress and its last known state
+type ProgressSource interface {
+	Subscribe(filter progress.Filter) *progress.Subscription
+	Snapshot(ctx context.Context, filter progress.Filter) ([]progress.Event, error)
+}
+
+// EventsHandler streams job progress as Server-Sent Events
+type EventsHandler struct {
+	source    ProgressSource
+	keepAlive time.Duration
+	logger    *zap.Logger
+}
+
+// NewEventsHandler creates an events handler
+func NewEventsHandler(source ProgressSource, logger *zap.Logger) *EventsHandler {
+	return &EventsHandler{source: source, keepAlive: DefaultKeepAlive, logger: logger}
+}
+
+// Stream sends the last known progress of every visible job, then live
+// updates, as "progress" events (?jobId=, ?tenantId= narrow the stream).
+// Callers only see jobs their role allows.
+func (h *EventsHandler) Stream(c fiber.Ctx) error {
+	session, ok := middleware.SessionFrom(c)
+	if !ok {
+		return apperrors.NewUnauthorized("Authentication required")
+	}
+	filter := progress.Filter{Session: session, JobID: c.Query("jobId"), TenantID: c.Query("tenantId")}
+	if filter.JobID != "" && uuid.Validate(filter.JobID) != nil {
+		return apperrors.NewBadRequest("Invalid job ID")
+	}
+	if filter.TenantID != "" && uuid.Validate(filter.TenantID) != nil {
+		return apperrors.NewBadRequest("Invalid tenant ID")
+	}
+
+	// Subscribe before the snapshot so no update falls between them
+	sub := h.source.Subscribe(filter)
+	replay, err := h.source.Snapshot(c.Context(), filter)
+	if err != nil {
+		sub.Close()
+		return err
+	}
+
+	// fasthttp sets the connection's write deadline once per response, from
+	// the server's WriteTimeout. A stream outlives it, so it sends keep-alives
+	// within the timeout and pushes the deadline forward before every flush;
+	// only a client that stops reading still times out.
+	conn := c.RequestCtx().Conn()
+	writeTimeout := c.App().Config().WriteTimeout
+	keepAliveEvery := h.keepAlive
+	if writeTimeout > 0 && keepAliveEvery > writeTimeout/2 {
+		keepAliveEvery = writeTimeout / 2
+	}
+	flush := func(w *bufio.Writer) error {
+		if writeTimeout > 0 {
+			if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+				return err
+			}
+		}
+		return w.Flush()
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+	return c.SendStreamWriter(func(w *bufio.Writer) {
+		defer sub.Close()
+		for _, e := range replay {
+			writeEvent(w, e)
+		}
+		if flush(w) != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(keepAliveEvery)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case e, ok := <-sub.Events():
+				if !ok {
+					// Server shutting down; EventSource reconnects elsewhere
+					return
+				}
+				writeEvent(w, e)
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+			// A failed flush means the client went away
+			if flush(w) != nil {
+				return
+			}
+		}
+	})
+}
+
+// writeEvent writes one progress event in SSE framing
+func writeEvent(w *bufio.Writer, e progress.Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: progress\nid: %s\ndata: %s\n\n", e.JobID, data)
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/api/middleware"
+	"ironarchive/internal/database"
+	"ironarchive/internal/models"
+	"ironarchive/internal/progress"
+)
+
+const (
+	eventsSecret = "events-secret"
+	streamTenant = "7d4c1a52-0b7e-4f0e-9d0c-3b5e2a1f9c01"
+	otherTenant  = "7d4c1a52-0b7e-4f0e-9d0c-3b5e2a1f9c02"
+)
+
+// newEventsServer serves the event stream on a real listener, since streams
+// never complete for app.Test
+func newEventsServer(t *testing.T) (baseURL string, publisher *progress.Publisher) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	conn, err := database.NewRedisConnection("redis://"+mr.Addr(), zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	hub := progress.NewHub(conn, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	go hub.Run(ctx)
+
+	handler := NewEventsHandler(hub, zap.NewNop())
+	handler.keepAlive = 50 * time.Millisecond
+	app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandler(zap.NewNop())})
+	app.Get("/api/v1/jobs/events", middleware.Authenticate(eventsSecret), handler.Stream)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.Listener(ln, fiber.ListenConfig{DisableStartupMessage: true})
+	t.Cleanup(func() {
+		// Stopping the hub ends open streams so the server can shut down
+		cancel()
+		app.ShutdownWithTimeout(5 * time.Second)
+	})
+	return "http://" + ln.Addr().String(), progress.NewPublisher(conn, zap.NewNop())
+}
+
+// tenantAdminToken signs an access token of a tenant admin of streamTenant
+func tenantAdminToken(t *testing.T) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, middleware.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Role:             models.RoleTenantAdmin,
+		TenantID:         streamTenant,
+	}).SignedString([]byte(eventsSecret))
+	require.NoError(t, err)
+	return token
+}
+
+// streamLines reads an SSE response line by line until it ends
+func streamLines(resp *http.Response) <-chan string {
+	lines := make(chan string, 64)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return lines
+}
+
+// readEvents decodes progress events from stream lines until one matches done
+func readEvents(t *testing.T, lines <-chan string, done func(progress.Event) bool) []progress.Event {
+	t.Helper()
+	var events []progress.Event
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			require.True(t, ok, "stream ended early")
+			data, ok := strings.CutPrefix(line, "data: ")
+			if !ok {
+				continue
+			}
+			var e progress.Event
+			require.NoError(t, json.Unmarshal([]byte(data), &e))
+			events = append(events, e)
+			if done(e) {
+				return events
+			}
+		case <-timeout:
+			t.Fatalf("expected event not received, got %d events", len(events))
+		}
+	}
+}
+
+// TestEventsStreamReplaysAndFollows verifies the last state is replayed and
+// live events of other tenants are filtered out
+func TestEventsStreamReplaysAndFollows(t *testing.T) {
+	baseURL, publisher := newEventsServer(t)
+	ctx := context.Background()
+	tenant, other := streamTenant, otherTenant
+	require.NoError(t, publisher.Publish(ctx, progress.Event{JobID: "earlier", TenantID: &tenant, Status: models.JobStatusRunning, Progress: 40}))
+	require.NoError(t, publisher.Publish(ctx, progress.Event{JobID: "foreign", TenantID: &other, Progress: 10}))
+
+	resp, err := http.Get(baseURL + "/api/v1/jobs/events?access_token=" + tenantAdminToken(t))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	lines := streamLines(resp)
+	replay := readEvents(t, lines, func(e progress.Event) bool { return e.JobID == "earlier" })
+	assert.Len(t, replay, 1)
+
+	// Publish until the live subscription picks events up
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			publisher.Publish(ctx, progress.Event{JobID: "foreign", TenantID: &other, Progress: 20})
+			publisher.Publish(ctx, progress.Event{JobID: "earlier", TenantID: &tenant, Status: models.JobStatusCompleted, Progress: 100})
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	live := readEvents(t, lines, func(e progress.Event) bool { return e.Status == models.JobStatusCompleted })
+	for _, e := range live {
+		assert.Equal(t, "earlier", e.JobID)
+	}
+}
+
+// TestEventsStreamRequiresSession verifies anonymous callers and bad filters are rejected
+func TestEventsStreamRequiresSession(t *testing.T) {
+	baseURL, _ := newEventsServer(t)
+
+	for url, want := range map[string]int{
+		baseURL + "/api/v1/jobs/events": 401,
+		baseURL + "/api/v1/jobs/events?jobId=nope&access_token=" + tenantAdminToken(t): 400,
+	} {
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, want, resp.StatusCode)
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/health"
+)
+
+// HealthHandler serves liveness and readiness probes
+type HealthHandler struct {
+	checker   *health.Checker
+	startedAt time.Time
+	logger    *zap.Logger
+
+	mu sync.Mutex
+	// down holds the dependencies the last probe found unreachable
+	down map[string]bool
+}
+
+// NewHealthHandler creates a health handler backed by the given dependency checker
+func NewHealthHandler(checker *health.Checker, logger *zap.Logger) *HealthHandler {
+	return &HealthHandler{
+		checker:   checker,
+		startedAt: time.Now(),
+		logger:    logger,
+		down:      make(map[string]bool),
+	}
+}
+
+// Liveness reports that the process is running and able to serve HTTP.
+// It deliberately does not touch dependencies so a backend outage never
+// causes the orchestrator to restart a healthy process.
+func (h *HealthHandler) Liveness(c fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status":        "alive",
+		"uptimeSeconds": int64(time.Since(h.startedAt).Seconds()),
+	})
+}
+
+// unreachable replaces dependency errors in readiness responses
+const unreachable = "unreachable"
+
+// Readiness checks every dependency and reports per-service status and latency.
+// Returns 200 when ready or degraded and 503 when a critical dependency is down.
+// The endpoint is unauthenticated, so dependency errors are only logged.
+func (h *HealthHandler) Readiness(c fiber.Ctx) error {
+	report := h.checker.Check(c.Context())
+
+	h.logger.Debug("Readiness check completed",
+		zap.String("status", report.Status),
+		zap.Any("services", report.Services),
+	)
+	h.logTransitions(report)
+	for name, service := range report.Services {
+		if service.Error != "" {
+			service.Error = unreachable
+			report.Services[name] = service
+		}
+	}
+
+	status := fiber.StatusOK
+	if !report.Ready() {
+		status = fiber.StatusServiceUnavailable
+	}
+	return c.Status(status).JSON(report)
+}
+
+// logTransitions warns when a dependency becomes unreachable and notes when it
+// is back. Probes run every few seconds, so repeated failures stay at Debug.
+func (h *HealthHandler) logTransitions(report health.Report) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for name, service := range report.Services {
+		switch {
+		case service.Error != "" && !h.down[name]:
+			h.down[name] = true
+			h.logger.Warn("Dependency unreachable",
+				zap.String("service", name),
+				zap.Bool("critical", service.Critical),
+				zap.String("error", service.Error),
+			)
+		case service.Error == "" && h.down[name]:
+			delete(h.down, name)
+			h.logger.Info("Dependency reachable again", zap.String("service", name))
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"ironarchive/internal/health"
+)
+
+// mockPinger returns a fixed error from Ping
+type mockPinger struct {
+	err error
+}
+
+func (m *mockPinger) Ping(ctx context.Context) error {
+	return m.err
+}
+
+// newHealthApp wires a health handler with database, redis and meilisearch mocks
+func newHealthApp(dbErr, redisErr, meiliErr error) *fiber.App {
+	checker := health.NewChecker(
+		health.Dependency{Name: "database", Pinger: &mockPinger{err: dbErr}, Critical: true},
+		health.Dependency{Name: "redis", Pinger: &mockPinger{err: redisErr}},
+		health.Dependency{Name: "meilisearch", Pinger: &mockPinger{err: meiliErr}},
+	)
+	handler := NewHealthHandler(checker, zap.NewNop())
+
+	app := fiber.New()
+	app.Get("/healthz", handler.Liveness)
+	app.Get("/readyz", handler.Readiness)
+	return app
+}
+
+// getReport calls /readyz and decodes the report
+func getReport(t *testing.T, app *fiber.App) (int, health.Report) {
+	t.Helper()
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/readyz", nil))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var report health.Report
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	return resp.StatusCode, report
+}
+
+// TestReadinessAllHealthy verifies 200 with every service up
+func TestReadinessAllHealthy(t *testing.T) {
+	status, report := getReport(t, newHealthApp(nil, nil, nil))
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, health.StatusReady, report.Status)
+	for _, name := range []string{"database", "redis", "meilisearch"} {
+		assert.Equal(t, health.StatusUp, report.Services[name].Status, "%s should be up", name)
+	}
+}
+
+// TestReadinessDatabaseDown verifies 503 when the database is unavailable
+func TestReadinessDatabaseDown(t *testing.T) {
+	status, report := getReport(t, newHealthApp(errors.New("connection refused"), nil, nil))
+
+	assert.Equal(t, 503, status)
+	assert.Equal(t, health.StatusUnavailable, report.Status)
+	assert.Equal(t, health.StatusDown, report.Services["database"].Status)
+	assert.Equal(t, "unreachable", report.Services["database"].Error)
+}
+
+// TestReadinessHidesDependencyErrors verifies driver messages stay out of the response
+func TestReadinessHidesDependencyErrors(t *testing.T) {
+	dbErr := errors.New("failed to connect to `host=db.internal user=ironarchive database=ironarchive`: dial error")
+	app := newHealthApp(dbErr, nil, nil)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/readyz", nil))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, 503, resp.StatusCode)
+	assert.NotContains(t, string(body), "db.internal")
+	assert.Contains(t, string(body), `"error":"unreachable"`)
+}
+
+// TestReadinessRedisDown verifies Redis failures degrade but keep the service ready
+func TestReadinessRedisDown(t *testing.T) {
+	status, report := getReport(t, newHealthApp(nil, errors.New("connection refused"), nil))
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, health.StatusDegraded, report.Status)
+	assert.Equal(t, health.StatusDown, report.Services["redis"].Status)
+}
+
+// TestReadinessMeilisearchDown verifies search outages keep the archive readable
+func TestReadinessMeilisearchDown(t *testing.T) {
+	status, report := getReport(t, newHealthApp(nil, nil, errors.New("Meilisearch is not available")))
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, health.StatusDegraded, report.Status)
+	assert.Equal(t, health.StatusDown, report.Services["meilisearch"].Status)
+}
+
+// TestLivenessIgnoresDependencies verifies liveness stays up even if the database is down
+func TestLivenessIgnoresDependencies(t *testing.T) {
+	app := newHealthApp(errors.New("connection refused"), nil, nil)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/healthz", nil))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "alive", body["status"])
+}
+
+// TestReadinessLogsStateChanges verifies an unreachable dependency is warned
+// about once, not on every probe, and its recovery is noted
+func TestReadinessLogsStateChanges(t *testing.T) {
+	redis := &mockPinger{err: errors.New("dial tcp 10.0.0.5:6379: connection refused")}
+	checker := health.NewChecker(health.Dependency{Name: "redis", Pinger: redis})
+	core, logs := observer.New(zapcore.InfoLevel)
+	app := fiber.New()
+	app.Get("/readyz", NewHealthHandler(checker, zap.New(core)).Readiness)
+
+	for range 3 {
+		getReport(t, app)
+	}
+	warnings := logs.FilterMessage("Dependency unreachable").All()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, zapcore.WarnLevel, warnings[0].Level)
+	assert.Equal(t, "redis", warnings[0].ContextMap()["service"])
+
+	redis.err = nil
+	getReport(t, app)
+	getReport(t, app)
+	assert.Equal(t, 1, logs.FilterMessage("Dependency reachable again").Len())
+
+	redis.err = errors.New("timeout")
+	getReport(t, app)
+	assert.Equal(t, 2, logs.FilterMessage("Dependency unreachable").Len())
+}
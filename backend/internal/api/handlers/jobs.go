@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/access"
+	"ironarchive/internal/api/middleware"
+	"ironarchive/internal/database"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+	"ironarchive/internal/queue"
+	apperrors "ironarchive/pkg/errors"
+)
+
+// JobController cancels and retries queued jobs
+type JobController interface {
+	Cancel(ctx context.Context, id string) (*models.Job, error)
+	Retry(ctx context.Context, id string) (*models.Job, error)
+}
+
+// JobHandler serves job cancellation, retries, attempt history and the
+// dead-letter view. Like job events, MSP_ADMIN reaches all jobs, TENANT_ADMIN
+// the jobs of its tenant and USER the jobs it started. Jobs are read through
+// sessions, so row-level security holds callers to their tenant.
+type JobHandler struct {
+	controller JobController
+	sessions   access.SessionRunner
+	logger     *zap.Logger
+}
+
+// NewJobHandler creates a job handler
+func NewJobHandler(controller JobController, sessions access.SessionRunner, logger *zap.Logger) *JobHandler {
+	return &JobHandler{controller: controller, sessions: sessions, logger: logger}
+}
+
+// DeadLetter lists permanently failed jobs, newest first (?type=, ?tenantId=, ?page=, ?limit=)
+func (h *JobHandler) DeadLetter(c fiber.Ctx) error {
+	session, ok := middleware.SessionFrom(c)
+	if !ok {
+		return apperrors.NewUnauthorized("Authentication required")
+	}
+	filter := repositories.JobFilter{
+		Type:   fiber.Query[string](c, "type"),
+		Status: models.JobStatusFailed,
+	}
+	if tenantID := fiber.Query[string](c, "tenantId"); tenantID != "" {
+		if uuid.Validate(tenantID) != nil {
+			return apperrors.NewBadRequest("Invalid tenant ID")
+		}
+		filter.TenantID = &tenantID
+	}
+	if session.Role == models.RoleUser {
+		filter.UserID = &session.UserID
+	}
+	pagination := repositories.Pagination{
+		Page:  fiber.Query(c, "page", 1),
+		Limit: fiber.Query(c, "limit", 0),
+	}
+	var page repositories.Page[models.Job]
+	err := h.sessions.WithSession(c.Context(), session, func(repos *repositories.Repositories) error {
+		var err error
+		page, err = repos.Jobs.List(c.Context(), filter, pagination)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(page)
+}
+
+// Attempts lists a job's attempts, oldest first
+func (h *JobHandler) Attempts(c fiber.Ctx) error {
+	session, ok := middleware.SessionFrom(c)
+	if !ok {
+		return apperrors.NewUnauthorized("Authentication required")
+	}
+	id, err := jobID(c)
+	if err != nil {
+		return err
+	}
+	var attempts []models.JobAttempt
+	err = h.sessions.WithSession(c.Context(), session, func(repos *repositories.Repositories) error {
+		job, err := visibleJob(c.Context(), repos, session, id)
+		if err != nil {
+			return err
+		}
+		attempts, err = repos.Jobs.ListAttempts(c.Context(), job.ID)
+		return err
+	})
+	if err != nil {
+		return jobError(err)
+	}
+	if attempts == nil {
+		attempts = []models.JobAttempt{}
+	}
+	return c.JSON(attempts)
+}
+
+// Cancel cancels a queued or retrying job, or asks the worker of a running
+// job to stop. It answers 202 with the job as updated.
+func (h *JobHandler) Cancel(c fiber.Ctx) error {
+	target, err := h.job(c)
+	if err != nil {
+		return err
+	}
+	job, err := h.controller.Cancel(c.Context(), target.ID)
+	if err != nil {
+		return jobError(err)
+	}
+	h.logger.Info("Job cancellation requested", zap.String("job_id", target.ID), zap.String("status", job.Status))
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// Retry enqueues a copy of a failed or cancelled job and answers 201 with it
+func (h *JobHandler) Retry(c fiber.Ctx) error {
+	target, err := h.job(c)
+	if err != nil {
+		return err
+	}
+	job, err := h.controller.Retry(c.Context(), target.ID)
+	if err != nil {
+		return jobError(err)
+	}
+	h.logger.Info("Job retried", zap.String("job_id", target.ID), zap.String("retry_id", job.ID))
+	return c.Status(fiber.StatusCreated).JSON(job)
+}
+
+// job loads the job of the :id path parameter. Jobs the caller may not reach
+// are reported as not found, so their IDs are not disclosed.
+func (h *JobHandler) job(c fiber.Ctx) (*models.Job, error) {
+	session, ok := middleware.SessionFrom(c)
+	if !ok {
+		return nil, apperrors.NewUnauthorized("Authentication required")
+	}
+	id, err := jobID(c)
+	if err != nil {
+		return nil, err
+	}
+	var job *models.Job
+	err = h.sessions.WithSession(c.Context(), session, func(repos *repositories.Repositories) error {
+		job, err = visibleJob(c.Context(), repos, session, id)
+		return err
+	})
+	if err != nil {
+		return nil, jobError(err)
+	}
+	return job, nil
+}
+
+// visibleJob loads a job through session-scoped repos. Row-level security
+// hides other tenants; USER callers are further held to the jobs they started.
+func visibleJob(ctx context.Context, repos *repositories.Repositories, session database.Session, id string) (*models.Job, error) {
+	job, err := repos.Jobs.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Role == models.RoleUser && (job.UserID == nil || *job.UserID != session.UserID) {
+		return nil, repositories.ErrNotFound
+	}
+	return job, nil
+}
+
+// jobID returns the validated :id path parameter
+func jobID(c fiber.Ctx) (string, error) {
+	id := c.Params("id")
+	if uuid.Validate(id) != nil {
+		return "", apperrors.NewBadRequest("Invalid job ID")
+	}
+	return id, nil
+}
+
+// jobError maps queue and repository errors to API errors
+func jobError(err error) error {
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		return apperrors.NewNotFound("Job not found")
+	case errors.Is(err, queue.ErrJobFinished):
+		return apperrors.NewConflict("Job already finished")
+	case errors.Is(err, queue.ErrNotRetryable):
+		return apperrors.NewConflict("Only failed or cancelled jobs can be retried")
+	}
+	return err
+}
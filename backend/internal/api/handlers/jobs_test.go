@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/api/middleware"
+	"ironarchive/internal/database"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+	"ironarchive/internal/queue"
+)
+
+const (
+	failedJob   = "2f0c5f44-3d55-4d7e-b0a4-8f0b7d6f1a01"
+	runningJob  = "2f0c5f44-3d55-4d7e-b0a4-8f0b7d6f1a02"
+	finishedJob = "2f0c5f44-3d55-4d7e-b0a4-8f0b7d6f1a03"
+	unknownJob  = "2f0c5f44-3d55-4d7e-b0a4-8f0b7d6f1a04"
+
+	jobsSecret = "jobs-secret"
+	jobTenant  = "2f0c5f44-3d55-4d7e-b0a4-8f0b7d6f1b01"
+	jobOwner   = "2f0c5f44-3d55-4d7e-b0a4-8f0b7d6f1c01"
+)
+
+// stubJobs serves a fixed set of jobs of jobTenant and records the last list
+// filter. Like row-level security, non-MSP sessions of other tenants see none.
+type stubJobs struct {
+	repositories.JobRepository
+	session *database.Session
+	filter  repositories.JobFilter
+}
+
+// hidden reports whether row-level security would hide jobTenant's rows
+func (s *stubJobs) hidden() bool {
+	return s.session != nil && s.session.Role != models.RoleMSPAdmin && s.session.TenantID != jobTenant
+}
+
+func (s *stubJobs) GetByID(_ context.Context, id string) (*models.Job, error) {
+	if s.hidden() {
+		return nil, repositories.ErrNotFound
+	}
+	tenant, owner := jobTenant, jobOwner
+	job := &models.Job{ID: id, TenantID: &tenant, UserID: &owner}
+	switch id {
+	case failedJob:
+		job.Status = models.JobStatusFailed
+	case runningJob:
+		job.Status = models.JobStatusRunning
+	case finishedJob:
+		job.Status = models.JobStatusCompleted
+	default:
+		return nil, repositories.ErrNotFound
+	}
+	return job, nil
+}
+
+func (s *stubJobs) List(_ context.Context, filter repositories.JobFilter, _ repositories.Pagination) (repositories.Page[models.Job], error) {
+	s.filter = filter
+	if s.hidden() {
+		return repositories.Page[models.Job]{Items: []models.Job{}}, nil
+	}
+	return repositories.Page[models.Job]{Items: []models.Job{{ID: failedJob, Status: models.JobStatusFailed}}, Total: 1}, nil
+}
+
+func (s *stubJobs) ListAttempts(_ context.Context, id string) ([]models.JobAttempt, error) {
+	if s.hidden() || id != failedJob {
+		return nil, nil
+	}
+	return []models.JobAttempt{{JobID: id, Attempt: 1, Status: models.JobAttemptFailed}}, nil
+}
+
+// jobSessions runs session-scoped calls on jobs as the session's caller
+type jobSessions struct {
+	jobs *stubJobs
+}
+
+func (s jobSessions) WithSession(_ context.Context, session database.Session, fn func(repos *repositories.Repositories) error) error {
+	s.jobs.session = &session
+	defer func() { s.jobs.session = nil }()
+	return fn(&repositories.Repositories{Jobs: s.jobs})
+}
+
+// stubController cancels and retries the jobs of stubJobs as the table owner
+type stubController struct {
+	jobs *stubJobs
+}
+
+func (s stubController) Cancel(ctx context.Context, id string) (*models.Job, error) {
+	job, err := s.jobs.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Finished() {
+		return job, queue.ErrJobFinished
+	}
+	return job, nil
+}
+
+func (s stubController) Retry(ctx context.Context, id string) (*models.Job, error) {
+	job, err := s.jobs.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.JobStatusFailed {
+		return nil, queue.ErrNotRetryable
+	}
+	return &models.Job{ID: unknownJob, Status: models.JobStatusQueued, RetryOf: &job.ID}, nil
+}
+
+// newJobApp wires a job handler on stub jobs
+func newJobApp() (*fiber.App, *stubJobs) {
+	jobs := &stubJobs{}
+	handler := NewJobHandler(stubController{jobs: jobs}, jobSessions{jobs: jobs}, zap.NewNop())
+
+	app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandler(zap.NewNop())})
+	v1 := app.Group("/api/v1", middleware.Authenticate(jobsSecret))
+	v1.Get("/jobs/dead-letter", handler.DeadLetter)
+	v1.Get("/jobs/:id/attempts", handler.Attempts)
+	v1.Post("/jobs/:id/cancel", handler.Cancel)
+	v1.Post("/jobs/:id/retry", handler.Retry)
+	return app, jobs
+}
+
+// jobToken signs an access token; the tenant is left out for MSP admins
+func jobToken(t *testing.T, role, tenantID, userID string) string {
+	t.Helper()
+	claims := middleware.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: userID, ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Role:             role,
+	}
+	if role != models.RoleMSPAdmin {
+		claims.TenantID = tenantID
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(jobsSecret))
+	require.NoError(t, err)
+	return token
+}
+
+// callJobs calls a job endpoint as the tenant admin of jobTenant and decodes
+// the response body into dest
+func callJobs(t *testing.T, app *fiber.App, method, path string, dest any) int {
+	t.Helper()
+	return callJobsWith(t, app, jobToken(t, models.RoleTenantAdmin, jobTenant, "admin-1"), method, path, dest)
+}
+
+// callJobsWith calls a job endpoint with token, or anonymously when it is empty
+func callJobsWith(t *testing.T, app *fiber.App, token, method, path string, dest any) int {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(dest))
+	return resp.StatusCode
+}
+
+// TestJobDeadLetter verifies the dead-letter view lists failed jobs with the requested filters
+func TestJobDeadLetter(t *testing.T) {
+	app, jobs := newJobApp()
+
+	var page repositories.Page[models.Job]
+	assert.Equal(t, 200, callJobs(t, app, "GET", "/api/v1/jobs/dead-letter?type=SYNC_MAILBOX", &page))
+	assert.Equal(t, 1, page.Total)
+	assert.Equal(t, models.JobStatusFailed, jobs.filter.Status)
+	assert.Equal(t, models.JobTypeSyncMailbox, jobs.filter.Type)
+
+	var body middleware.ErrorResponse
+	assert.Equal(t, 400, callJobs(t, app, "GET", "/api/v1/jobs/dead-letter?tenantId=nope", &body))
+}
+
+// TestJobDeadLetterScopesByRole verifies callers only list failed jobs they may reach
+func TestJobDeadLetterScopesByRole(t *testing.T) {
+	app, jobs := newJobApp()
+	other := "2f0c5f44-3d55-4d7e-b0a4-8f0b7d6f1b02"
+
+	var page repositories.Page[models.Job]
+	otherAdmin := jobToken(t, models.RoleTenantAdmin, other, "admin-2")
+	assert.Equal(t, 200, callJobsWith(t, app, otherAdmin, "GET", "/api/v1/jobs/dead-letter?tenantId="+jobTenant, &page))
+	assert.Zero(t, page.Total, "Tenant admins are held to their own tenant")
+	assert.Nil(t, jobs.filter.UserID)
+
+	user := jobToken(t, models.RoleUser, jobTenant, jobOwner)
+	assert.Equal(t, 200, callJobsWith(t, app, user, "GET", "/api/v1/jobs/dead-letter", &page))
+	require.NotNil(t, jobs.filter.UserID)
+	assert.Equal(t, jobOwner, *jobs.filter.UserID, "Users only list the jobs they started")
+
+	msp := jobToken(t, models.RoleMSPAdmin, "", "msp-1")
+	assert.Equal(t, 200, callJobsWith(t, app, msp, "GET", "/api/v1/jobs/dead-letter?tenantId="+other, &page))
+	require.NotNil(t, jobs.filter.TenantID)
+	assert.Equal(t, other, *jobs.filter.TenantID)
+}
+
+// TestJobAttempts verifies a job's attempt history and unknown jobs
+func TestJobAttempts(t *testing.T) {
+	app, _ := newJobApp()
+
+	var attempts []models.JobAttempt
+	assert.Equal(t, 200, callJobs(t, app, "GET", "/api/v1/jobs/"+failedJob+"/attempts", &attempts))
+	require.Len(t, attempts, 1)
+	assert.Equal(t, models.JobAttemptFailed, attempts[0].Status)
+
+	assert.Equal(t, 200, callJobs(t, app, "GET", "/api/v1/jobs/"+runningJob+"/attempts", &attempts))
+	assert.Empty(t, attempts)
+
+	var body middleware.ErrorResponse
+	assert.Equal(t, 404, callJobs(t, app, "GET", "/api/v1/jobs/"+unknownJob+"/attempts", &body))
+}
+
+// TestJobCancelAndRetry verifies status codes of cancel and retry requests
+func TestJobCancelAndRetry(t *testing.T) {
+	app, _ := newJobApp()
+
+	var job models.Job
+	assert.Equal(t, 202, callJobs(t, app, "POST", "/api/v1/jobs/"+runningJob+"/cancel", &job))
+	assert.Equal(t, models.JobStatusRunning, job.Status)
+	assert.Equal(t, 201, callJobs(t, app, "POST", "/api/v1/jobs/"+failedJob+"/retry", &job))
+	assert.Equal(t, failedJob, *job.RetryOf)
+
+	for path, want := range map[string]int{
+		"/api/v1/jobs/not-a-uuid/cancel":          400,
+		"/api/v1/jobs/" + unknownJob + "/cancel":  404,
+		"/api/v1/jobs/" + finishedJob + "/cancel": 409,
+		"/api/v1/jobs/" + runningJob + "/retry":   409,
+	} {
+		var body middleware.ErrorResponse
+		assert.Equal(t, want, callJobs(t, app, "POST", path, &body), path)
+		assert.NotEmpty(t, body.Error.Code, path)
+	}
+}
+
+// TestJobRoutesRequireToken verifies anonymous callers are rejected
+func TestJobRoutesRequireToken(t *testing.T) {
+	app, _ := newJobApp()
+
+	for path, method := range map[string]string{
+		"/api/v1/jobs/dead-letter":                "GET",
+		"/api/v1/jobs/" + failedJob + "/attempts": "GET",
+		"/api/v1/jobs/" + runningJob + "/cancel":  "POST",
+		"/api/v1/jobs/" + failedJob + "/retry":    "POST",
+	} {
+		var body middleware.ErrorResponse
+		assert.Equal(t, 401, callJobsWith(t, app, "", method, path, &body), path)
+	}
+}
+
+// TestJobsOfOtherTenantsAreHidden verifies jobs outside the caller's reach answer 404
+func TestJobsOfOtherTenantsAreHidden(t *testing.T) {
+	app, _ := newJobApp()
+	otherAdmin := jobToken(t, models.RoleTenantAdmin, "2f0c5f44-3d55-4d7e-b0a4-8f0b7d6f1b02", "admin-2")
+	otherUser := jobToken(t, models.RoleUser, jobTenant, "2f0c5f44-3d55-4d7e-b0a4-8f0b7d6f1c02")
+
+	for path, method := range map[string]string{
+		"/api/v1/jobs/" + failedJob + "/attempts": "GET",
+		"/api/v1/jobs/" + runningJob + "/cancel":  "POST",
+		"/api/v1/jobs/" + failedJob + "/retry":    "POST",
+	} {
+		var body middleware.ErrorResponse
+		assert.Equal(t, 404, callJobsWith(t, app, otherAdmin, method, path, &body), path)
+		assert.Equal(t, 404, callJobsWith(t, app, otherUser, method, path, &body), path)
+	}
+
+	owner := jobToken(t, models.RoleUser, jobTenant, jobOwner)
+	var job models.Job
+	assert.Equal(t, 202, callJobsWith(t, app, owner, "POST", "/api/v1/jobs/"+runningJob+"/cancel", &job))
+}
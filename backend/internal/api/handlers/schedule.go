@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/access"
+	"ironarchive/internal/api/middleware"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/scheduler"
+	apperrors "ironarchive/pkg/errors"
+)
+
+// Bounds of the number of runs a schedule preview returns
+const (
+	DefaultPreviewRuns = 10
+	MaxPreviewRuns     = 100
+)
+
+// MailboxScheduleResolver resolves the sync schedule that applies to a mailbox
+type MailboxScheduleResolver interface {
+	ResolveMailbox(ctx context.Context, mailboxID string) (*scheduler.Resolved, error)
+}
+
+// SchedulePreview is the effective sync schedule of a mailbox and its next runs
+type SchedulePreview struct {
+	MailboxID   string `json:"mailboxId"`
+	Source      string `json:"source"`
+	ScheduleKey string `json:"scheduleKey"`
+	Schedule    string `json:"schedule,omitempty"`
+	Timezone    string `json:"timezone,omitempty"`
+	// Active is false while scheduled sync or the mailbox's sync is disabled
+	Active   bool        `json:"active"`
+	NextRuns []time.Time `json:"nextRuns"`
+}
+
+// ScheduleHandler serves sync schedule previews
+type ScheduleHandler struct {
+	resolver MailboxScheduleResolver
+	sessions access.SessionRunner
+	now      func() time.Time
+	logger   *zap.Logger
+}
+
+// NewScheduleHandler creates a schedule handler; mailboxes are looked up
+// through sessions as the caller
+func NewScheduleHandler(resolver MailboxScheduleResolver, sessions access.SessionRunner, logger *zap.Logger) *ScheduleHandler {
+	return &ScheduleHandler{resolver: resolver, sessions: sessions, now: time.Now, logger: logger}
+}
+
+// Preview resolves a mailbox's schedule Mailbox → Tenant → Global and lists
+// its next runs (?count=, default 10). Inactive schedules list no runs.
+// Tenant admins reach the mailboxes of their tenant, users their own.
+func (h *ScheduleHandler) Preview(c fiber.Ctx) error {
+	session, ok := middleware.SessionFrom(c)
+	if !ok {
+		return apperrors.NewUnauthorized("Authentication required")
+	}
+	mailboxID := c.Params("id")
+	if uuid.Validate(mailboxID) != nil {
+		return apperrors.NewBadRequest("Invalid mailbox ID")
+	}
+	count := fiber.Query(c, "count", DefaultPreviewRuns)
+	if count < 1 || count > MaxPreviewRuns {
+		return apperrors.NewBadRequest("count must be between 1 and 100")
+	}
+
+	// Mailboxes out of reach are reported as not found, before anything
+	// about their schedule is resolved
+	err := h.sessions.WithSession(c.Context(), session, func(repos *repositories.Repositories) error {
+		scope, err := access.NewResolver(repos).ScopeFor(c.Context(), session)
+		if err != nil {
+			return err
+		}
+		mailbox, err := repos.Mailboxes.GetByID(c.Context(), mailboxID)
+		if err != nil {
+			return err
+		}
+		if !scope.Includes(mailbox.TenantID, mailbox.ID) {
+			return repositories.ErrNotFound
+		}
+		return nil
+	})
+	if errors.Is(err, access.ErrNoMailbox) || errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NewNotFound("Mailbox not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	resolved, err := h.resolver.ResolveMailbox(c.Context(), mailboxID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NewNotFound("Mailbox not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	preview := SchedulePreview{
+		MailboxID:   mailboxID,
+		Source:      resolved.Source,
+		ScheduleKey: resolved.ScheduleKey,
+		Active:      resolved.Schedule != nil && resolved.SyncEnabled,
+		NextRuns:    []time.Time{},
+	}
+	if resolved.Schedule != nil {
+		preview.Schedule = resolved.Schedule.Expr
+		preview.Timezone = resolved.Schedule.Location.String()
+	}
+	if preview.Active {
+		preview.NextRuns = resolved.Schedule.Upcoming(h.now(), count)
+	}
+	return c.JSON(preview)
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/api/middleware"
+	"ironarchive/internal/database"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+	"ironarchive/internal/scheduler"
+)
+
+const (
+	previewMailbox = "5b1e6c1e-8d2a-4a47-9a55-1f8c1c0f2a10"
+	otherMailbox   = "5b1e6c1e-8d2a-4a47-9a55-1f8c1c0f2a11"
+	previewTenant  = "5b1e6c1e-8d2a-4a47-9a55-1f8c1c0f2b10"
+	previewOwner   = "5b1e6c1e-8d2a-4a47-9a55-1f8c1c0f2c10"
+	previewSecret  = "preview-secret"
+)
+
+// previewOwnerAddress is the address of previewOwner and previewMailbox
+const previewOwnerAddress = "owner@preview.test"
+
+// stubMailboxes holds previewMailbox and otherMailbox, both of previewTenant
+type stubMailboxes struct {
+	repositories.MailboxRepository
+}
+
+func (stubMailboxes) GetByID(_ context.Context, id string) (*models.Mailbox, error) {
+	if id != previewMailbox && id != otherMailbox {
+		return nil, repositories.ErrNotFound
+	}
+	return &models.Mailbox{ID: id, TenantID: previewTenant}, nil
+}
+
+func (stubMailboxes) GetByAddress(_ context.Context, tenantID, address string) (*models.Mailbox, error) {
+	if tenantID != previewTenant || address != previewOwnerAddress {
+		return nil, repositories.ErrNotFound
+	}
+	return &models.Mailbox{ID: previewMailbox, TenantID: previewTenant, EmailAddress: address}, nil
+}
+
+// stubUsers knows every user; only previewOwner has an archived mailbox
+type stubUsers struct {
+	repositories.UserRepository
+}
+
+func (stubUsers) GetByID(_ context.Context, id string) (*models.User, error) {
+	if id == previewOwner {
+		return &models.User{ID: id, Email: previewOwnerAddress}, nil
+	}
+	return &models.User{ID: id, Email: id + "@preview.test"}, nil
+}
+
+// stubSessions runs session-scoped calls on repos and records their sessions
+type stubSessions struct {
+	repos    *repositories.Repositories
+	sessions []database.Session
+}
+
+func (s *stubSessions) WithSession(_ context.Context, session database.Session, fn func(repos *repositories.Repositories) error) error {
+	s.sessions = append(s.sessions, session)
+	return fn(s.repos)
+}
+
+// staticResolver resolves every known mailbox to the same schedule
+type staticResolver struct {
+	resolved *scheduler.Resolved
+}
+
+func (r staticResolver) ResolveMailbox(_ context.Context, mailboxID string) (*scheduler.Resolved, error) {
+	if mailboxID != previewMailbox {
+		return nil, repositories.ErrNotFound
+	}
+	return r.resolved, nil
+}
+
+// newScheduleApp wires a schedule handler whose clock reads now
+func newScheduleApp(resolved *scheduler.Resolved, now time.Time) *fiber.App {
+	sessions := &stubSessions{repos: &repositories.Repositories{Users: stubUsers{}, Mailboxes: stubMailboxes{}}}
+	handler := NewScheduleHandler(staticResolver{resolved: resolved}, sessions, zap.NewNop())
+	handler.now = func() time.Time { return now }
+
+	app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandler(zap.NewNop())})
+	app.Get("/api/v1/mailboxes/:id/schedule", middleware.Authenticate(previewSecret), handler.Preview)
+	return app
+}
+
+// previewToken signs an access token; the tenant is left out for MSP admins
+func previewToken(t *testing.T, role, tenantID, userID string) string {
+	t.Helper()
+	claims := middleware.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: userID, ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Role:             role,
+	}
+	if role != models.RoleMSPAdmin {
+		claims.TenantID = tenantID
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(previewSecret))
+	require.NoError(t, err)
+	return token
+}
+
+// getPreview calls the preview endpoint as the tenant admin of previewTenant
+// and decodes the response body into dest
+func getPreview(t *testing.T, app *fiber.App, path string, dest any) int {
+	t.Helper()
+	return getPreviewWith(t, app, previewToken(t, models.RoleTenantAdmin, previewTenant, "admin-1"), path, dest)
+}
+
+// getPreviewWith calls the preview endpoint with token, or anonymously when it is empty
+func getPreviewWith(t *testing.T, app *fiber.App, token, path string, dest any) int {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(dest))
+	return resp.StatusCode
+}
+
+// TestSchedulePreviewNextRuns verifies the next runs follow the resolved schedule and zone
+func TestSchedulePreviewNextRuns(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	schedule, err := scheduler.ParseSchedule("0 22 * * *", berlin)
+	require.NoError(t, err)
+	now := time.Date(2025, 3, 28, 12, 0, 0, 0, time.UTC)
+	app := newScheduleApp(&scheduler.Resolved{
+		Source:      scheduler.SourceTenant,
+		ScheduleKey: models.ScheduleKeyTenant("t1"),
+		Schedule:    schedule,
+		SyncEnabled: true,
+	}, now)
+
+	var preview SchedulePreview
+	status := getPreview(t, app, "/api/v1/mailboxes/"+previewMailbox+"/schedule", &preview)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, scheduler.SourceTenant, preview.Source)
+	assert.Equal(t, "Europe/Berlin", preview.Timezone)
+	assert.True(t, preview.Active)
+	require.Len(t, preview.NextRuns, DefaultPreviewRuns)
+	// 22:00 Berlin is 21:00 UTC until the switch to summer time on March 30
+	assert.Equal(t, time.Date(2025, 3, 28, 21, 0, 0, 0, time.UTC), preview.NextRuns[0])
+	assert.Equal(t, time.Date(2025, 3, 30, 20, 0, 0, 0, time.UTC), preview.NextRuns[2])
+
+	status = getPreview(t, app, "/api/v1/mailboxes/"+previewMailbox+"/schedule?count=3", &preview)
+	assert.Equal(t, 200, status)
+	assert.Len(t, preview.NextRuns, 3)
+}
+
+// TestSchedulePreviewInactive verifies disabled sync lists no runs
+func TestSchedulePreviewInactive(t *testing.T) {
+	schedule, err := scheduler.ParseSchedule("0 * * * *", nil)
+	require.NoError(t, err)
+	app := newScheduleApp(&scheduler.Resolved{
+		Source:      scheduler.SourceMailbox,
+		ScheduleKey: models.ScheduleKeyMailbox(previewMailbox),
+		Schedule:    schedule,
+	}, time.Now())
+
+	var preview SchedulePreview
+	assert.Equal(t, 200, getPreview(t, app, "/api/v1/mailboxes/"+previewMailbox+"/schedule", &preview))
+	assert.False(t, preview.Active)
+	assert.Equal(t, "0 * * * *", preview.Schedule)
+	assert.Empty(t, preview.NextRuns)
+}
+
+// TestSchedulePreviewErrors verifies invalid input and unknown mailboxes
+func TestSchedulePreviewErrors(t *testing.T) {
+	app := newScheduleApp(&scheduler.Resolved{Source: scheduler.SourceGlobal}, time.Now())
+
+	for path, want := range map[string]int{
+		"/api/v1/mailboxes/not-a-uuid/schedule":                           400,
+		"/api/v1/mailboxes/" + previewMailbox + "/schedule?count=0":       400,
+		"/api/v1/mailboxes/" + previewMailbox + "/schedule?count=101":     400,
+		"/api/v1/mailboxes/00000000-0000-0000-0000-000000000000/schedule": 404,
+	} {
+		var body middleware.ErrorResponse
+		assert.Equal(t, want, getPreview(t, app, path, &body), path)
+		assert.NotEmpty(t, body.Error.Code, path)
+	}
+}
+
+// TestSchedulePreviewScopesByRole verifies callers only preview mailboxes they may reach
+func TestSchedulePreviewScopesByRole(t *testing.T) {
+	app := newScheduleApp(&scheduler.Resolved{Source: scheduler.SourceGlobal}, time.Now())
+	path := "/api/v1/mailboxes/" + previewMailbox + "/schedule"
+	var body middleware.ErrorResponse
+
+	assert.Equal(t, 401, getPreviewWith(t, app, "", path, &body))
+
+	otherAdmin := previewToken(t, models.RoleTenantAdmin, "5b1e6c1e-8d2a-4a47-9a55-1f8c1c0f2b11", "admin-2")
+	assert.Equal(t, 404, getPreviewWith(t, app, otherAdmin, path, &body), "Mailboxes of other tenants are hidden")
+
+	owner := previewToken(t, models.RoleUser, previewTenant, previewOwner)
+	var preview SchedulePreview
+	assert.Equal(t, 200, getPreviewWith(t, app, owner, path, &preview))
+	assert.Equal(t, 404, getPreviewWith(t, app, owner, "/api/v1/mailboxes/"+otherMailbox+"/schedule", &body), "Users only reach their own mailbox")
+
+	stranger := previewToken(t, models.RoleUser, previewTenant, "5b1e6c1e-8d2a-4a47-9a55-1f8c1c0f2c11")
+	assert.Equal(t, 404, getPreviewWith(t, app, stranger, path, &body))
+
+	msp := previewToken(t, models.RoleMSPAdmin, "", "msp-1")
+	assert.Equal(t, 200, getPreviewWith(t, app, msp, path, &preview))
+}
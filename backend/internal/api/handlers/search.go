@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/access"
+	"ironarchive/internal/api/middleware"
+	"ironarchive/internal/database"
+	"ironarchive/internal/search"
+	"ironarchive/internal/search/query"
+	apperrors "ironarchive/pkg/errors"
+)
+
+// MailboxScoper tells which part of the archive a session may reach
+type MailboxScoper interface {
+	ScopeFor(ctx context.Context, session database.Session) (access.Scope, error)
+}
+
+// EmailSearcher searches the archive within the caller's scope
+type EmailSearcher interface {
+	Search(ctx context.Context, scope access.Scope, req search.Request) (*search.Result, error)
+}
+
+// SearchHandler serves email search
+type SearchHandler struct {
+	scopes   MailboxScoper
+	searcher EmailSearcher
+	logger   *zap.Logger
+}
+
+// NewSearchHandler creates a search handler
+func NewSearchHandler(scopes MailboxScoper, searcher EmailSearcher, logger *zap.Logger) *SearchHandler {
+	return &SearchHandler{scopes: scopes, searcher: searcher, logger: logger}
+}
+
+// Search returns a page of emails matching ?q= with highlights; q accepts
+// operators such as from:, subject:"..." and has:attachment (?mailboxId=,
+// ?sender=, ?hasAttachments=, ?sentAfter=, ?sentBefore= narrow the results;
+// ?sort=relevance|newest|oldest, ?facets=sender,mailbox_id, ?limit=,
+// ?cursor=; ?collapse=thread returns one hit per thread). Callers only find
+// emails their role allows.
+func (h *SearchHandler) Search(c fiber.Ctx) error {
+	session, ok := middleware.SessionFrom(c)
+	if !ok {
+		return apperrors.NewUnauthorized("Authentication required")
+	}
+	req, err := searchRequest(c)
+	if err != nil {
+		return err
+	}
+
+	scope, err := h.scopes.ScopeFor(c.Context(), session)
+	if errors.Is(err, access.ErrNoMailbox) {
+		return apperrors.NewForbidden("No archived mailbox belongs to this user")
+	}
+	if err != nil {
+		return err
+	}
+	result, err := h.searcher.Search(c.Context(), scope, req)
+	if errors.Is(err, search.ErrInvalidRequest) {
+		appErr := apperrors.NewBadRequest(strings.TrimPrefix(err.Error(), search.ErrInvalidRequest.Error()+": "))
+		// Query syntax errors point at the offending character of q
+		var syntaxErr *query.Error
+		if errors.As(err, &syntaxErr) {
+			appErr = appErr.WithDetails(map[string]interface{}{"position": syntaxErr.Pos})
+		}
+		return appErr
+	}
+	if err != nil {
+		h.logger.Error("Search failed", zap.String("user_id", session.UserID), zap.Error(err))
+		return apperrors.NewServiceUnavailable("Search is unavailable")
+	}
+	return c.JSON(result)
+}
+
+// searchRequest reads the search parameters of a request
+func searchRequest(c fiber.Ctx) (search.Request, error) {
+	req := search.Request{
+		Query:    c.Query("q"),
+		Sender:   c.Query("sender"),
+		Sort:     c.Query("sort"),
+		Collapse: c.Query("collapse"),
+		Limit:    fiber.Query(c, "limit", 0),
+		Cursor:   c.Query("cursor"),
+	}
+	if mailboxID := c.Query("mailboxId"); mailboxID != "" {
+		if uuid.Validate(mailboxID) != nil {
+			return req, apperrors.NewBadRequest("Invalid mailbox ID")
+		}
+		req.MailboxID = mailboxID
+	}
+	if raw := c.Query("hasAttachments"); raw != "" {
+		if raw != "true" && raw != "false" {
+			return req, apperrors.NewBadRequest("hasAttachments must be true or false")
+		}
+		hasAttachments := raw == "true"
+		req.HasAttachments = &hasAttachments
+	}
+	for name, dest := range map[string]**time.Time{"sentAfter": &req.SentAfter, "sentBefore": &req.SentBefore} {
+		if raw := c.Query(name); raw != "" {
+			t, err := parseSearchDate(raw)
+			if err != nil {
+				return req, apperrors.NewBadRequest(name + " must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+			}
+			*dest = &t
+		}
+	}
+	if raw := c.Query("facets"); raw != "" {
+		req.Facets = strings.Split(raw, ",")
+	}
+	return req, nil
+}
+
+// parseSearchDate parses an RFC 3339 timestamp or a date at midnight UTC
+func parseSearchDate(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/access"
+	"ironarchive/internal/api/middleware"
+	"ironarchive/internal/database"
+	"ironarchive/internal/models"
+	"ironarchive/internal/search"
+	"ironarchive/internal/search/query"
+)
+
+const (
+	searchSecret  = "search-secret"
+	searchTenant  = "6a1b6a52-0c4b-4c7c-9d3e-3f1f5a2b7c01"
+	searchMailbox = "6a1b6a52-0c4b-4c7c-9d3e-3f1f5a2b7c02"
+)
+
+// stubSearcher scopes sessions like access.Resolver and records the last search
+type stubSearcher struct {
+	scope   access.Scope
+	request search.Request
+	err     error
+}
+
+func (s *stubSearcher) ScopeFor(_ context.Context, session database.Session) (access.Scope, error) {
+	switch session.Role {
+	case models.RoleMSPAdmin:
+		return access.Scope{}, nil
+	case models.RoleTenantAdmin:
+		return access.Scope{TenantID: session.TenantID}, nil
+	}
+	if session.UserID == "no-mailbox" {
+		return access.Scope{}, access.ErrNoMailbox
+	}
+	return access.Scope{TenantID: session.TenantID, MailboxID: searchMailbox}, nil
+}
+
+func (s *stubSearcher) Search(_ context.Context, scope access.Scope, req search.Request) (*search.Result, error) {
+	s.scope, s.request = scope, req
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &search.Result{Hits: []search.Hit{{ID: "e1", Subject: "Invoice"}}, EstimatedTotalHits: 1}, nil
+}
+
+// newSearchApp wires an authenticated search handler on a stub searcher
+func newSearchApp() (*fiber.App, *stubSearcher) {
+	searcher := &stubSearcher{}
+	handler := NewSearchHandler(searcher, searcher, zap.NewNop())
+
+	app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandler(zap.NewNop())})
+	app.Get("/api/v1/search", middleware.Authenticate(searchSecret), handler.Search)
+	return app, searcher
+}
+
+// callSearch searches as the given user and decodes the response body into dest
+func callSearch(t *testing.T, app *fiber.App, userID, role, query string, dest any) int {
+	t.Helper()
+	return callAs(t, app, userID, role, "/api/v1/search"+query, dest)
+}
+
+// callAs requests path as the given user and decodes the response body into dest
+func callAs(t *testing.T, app *fiber.App, userID, role, path string, dest any) int {
+	t.Helper()
+	claims := middleware.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: userID, ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Role:             role,
+	}
+	if role != models.RoleMSPAdmin {
+		claims.TenantID = searchTenant
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(searchSecret))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(dest))
+	return resp.StatusCode
+}
+
+// TestSearchScopesByRole verifies each role searches its own scope with the parsed parameters
+func TestSearchScopesByRole(t *testing.T) {
+	app, searcher := newSearchApp()
+
+	var result search.Result
+	assert.Equal(t, 200, callSearch(t, app, "user-1", models.RoleUser,
+		"?q=invoice&hasAttachments=true&sentAfter=2025-03-01&sort=newest&facets=sender,mailbox_id&limit=10&cursor=abc", &result))
+	require.Len(t, result.Hits, 1)
+	assert.Equal(t, access.Scope{TenantID: searchTenant, MailboxID: searchMailbox}, searcher.scope)
+	assert.Equal(t, "invoice", searcher.request.Query)
+	assert.True(t, *searcher.request.HasAttachments)
+	assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), *searcher.request.SentAfter)
+	assert.Equal(t, []string{"sender", "mailbox_id"}, searcher.request.Facets)
+	assert.Equal(t, 10, searcher.request.Limit)
+	assert.Empty(t, searcher.request.Collapse)
+	assert.Equal(t, "abc", searcher.request.Cursor)
+
+	assert.Equal(t, 200, callSearch(t, app, "user-1", models.RoleTenantAdmin, "?q=x&collapse=thread", &result))
+	assert.Equal(t, access.Scope{TenantID: searchTenant}, searcher.scope)
+	assert.Equal(t, search.CollapseThread, searcher.request.Collapse)
+	assert.Equal(t, 200, callSearch(t, app, "user-1", models.RoleMSPAdmin, "?q=x", &result))
+	assert.Equal(t, access.Scope{}, searcher.scope)
+}
+
+// TestSearchErrors verifies authentication, parameter and search failures map to status codes
+func TestSearchErrors(t *testing.T) {
+	app, searcher := newSearchApp()
+
+	var body middleware.ErrorResponse
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/search?q=x", nil))
+	require.NoError(t, err)
+	assert.Equal(t, 401, resp.StatusCode)
+
+	for query, want := range map[string]int{
+		"?mailboxId=nope":        400,
+		"?hasAttachments=maybe":  400,
+		"?sentBefore=yesterday":  400,
+		"?sentAfter=2025-03-01Z": 400,
+	} {
+		assert.Equal(t, want, callSearch(t, app, "user-1", models.RoleTenantAdmin, query, &body), query)
+	}
+	assert.Equal(t, 403, callSearch(t, app, "no-mailbox", models.RoleUser, "?q=x", &body))
+
+	searcher.err = fmt.Errorf("%w: unknown facet %q", search.ErrInvalidRequest, "body_text")
+	assert.Equal(t, 400, callSearch(t, app, "user-1", models.RoleTenantAdmin, "?facets=body_text", &body))
+	assert.Equal(t, `unknown facet "body_text"`, body.Error.Message)
+	assert.Nil(t, body.Error.Details)
+
+	searcher.err = fmt.Errorf("%w: %w", search.ErrInvalidRequest, &query.Error{Pos: 8, Msg: "unclosed quote"})
+	assert.Equal(t, 400, callSearch(t, app, "user-1", models.RoleTenantAdmin, `?q=subject:"merger`, &body))
+	assert.Equal(t, "unclosed quote at position 8", body.Error.Message)
+	assert.EqualValues(t, 8, body.Error.Details["position"])
+
+	searcher.err = fmt.Errorf("failed to search emails: connection refused")
+	assert.Equal(t, 503, callSearch(t, app, "user-1", models.RoleTenantAdmin, "?q=x", &body))
+}
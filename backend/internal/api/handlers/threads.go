@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/access"
+	"ironarchive/internal/api/middleware"
+	"ironarchive/internal/threads"
+	apperrors "ironarchive/pkg/errors"
+)
+
+// ConversationReader returns email threads within the caller's scope
+type ConversationReader interface {
+	Conversation(ctx context.Context, scope access.Scope, threadID string) (*threads.Conversation, error)
+}
+
+// ThreadHandler serves email conversations
+type ThreadHandler struct {
+	scopes        MailboxScoper
+	conversations ConversationReader
+	logger        *zap.Logger
+}
+
+// NewThreadHandler creates a thread handler
+func NewThreadHandler(scopes MailboxScoper, conversations ConversationReader, logger *zap.Logger) *ThreadHandler {
+	return &ThreadHandler{scopes: scopes, conversations: conversations, logger: logger}
+}
+
+// Get returns the thread :id with its messages, oldest first, across the
+// mailboxes of its tenant. Users only see the messages of their own mailbox.
+func (h *ThreadHandler) Get(c fiber.Ctx) error {
+	session, ok := middleware.SessionFrom(c)
+	if !ok {
+		return apperrors.NewUnauthorized("Authentication required")
+	}
+	id := c.Params("id")
+	if uuid.Validate(id) != nil {
+		return apperrors.NewBadRequest("Invalid thread ID")
+	}
+
+	scope, err := h.scopes.ScopeFor(c.Context(), session)
+	if errors.Is(err, access.ErrNoMailbox) {
+		return apperrors.NewForbidden("No archived mailbox belongs to this user")
+	}
+	if err != nil {
+		return err
+	}
+	conversation, err := h.conversations.Conversation(c.Context(), scope, id)
+	if errors.Is(err, threads.ErrThreadNotFound) {
+		return apperrors.NewNotFound("Thread not found")
+	}
+	if err != nil {
+		h.logger.Error("Failed to load thread", zap.String("thread_id", id), zap.Error(err))
+		return err
+	}
+	return c.JSON(conversation)
+}
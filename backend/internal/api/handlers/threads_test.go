@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/access"
+	"ironarchive/internal/api/middleware"
+	"ironarchive/internal/models"
+	"ironarchive/internal/threads"
+)
+
+const searchThread = "6a1b6a52-0c4b-4c7c-9d3e-3f1f5a2b7c03"
+
+// stubConversations serves searchThread and records the scope it was read in
+type stubConversations struct {
+	stubSearcher
+	err error
+}
+
+func (s *stubConversations) Conversation(_ context.Context, scope access.Scope, threadID string) (*threads.Conversation, error) {
+	s.scope = scope
+	if s.err != nil {
+		return nil, s.err
+	}
+	if threadID != searchThread {
+		return nil, threads.ErrThreadNotFound
+	}
+	return &threads.Conversation{
+		Thread:   models.Thread{ID: threadID, TenantID: searchTenant, MessageCount: 1},
+		Messages: []threads.ConversationMessage{{ID: "e1", Subject: "Invoice", Copies: []threads.Copy{{ID: "e1", MailboxID: searchMailbox}}}},
+	}, nil
+}
+
+// TestGetThread verifies conversations are read in the caller's scope and
+// errors map to status codes
+func TestGetThread(t *testing.T) {
+	conversations := &stubConversations{}
+	app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandler(zap.NewNop())})
+	app.Get("/api/v1/threads/:id", middleware.Authenticate(searchSecret), NewThreadHandler(conversations, conversations, zap.NewNop()).Get)
+
+	var conversation threads.Conversation
+	assert.Equal(t, 200, callAs(t, app, "user-1", models.RoleUser, "/api/v1/threads/"+searchThread, &conversation))
+	assert.Equal(t, access.Scope{TenantID: searchTenant, MailboxID: searchMailbox}, conversations.scope)
+	require.Len(t, conversation.Messages, 1)
+	assert.Equal(t, searchMailbox, conversation.Messages[0].Copies[0].MailboxID)
+
+	assert.Equal(t, 200, callAs(t, app, "user-1", models.RoleTenantAdmin, "/api/v1/threads/"+searchThread, &conversation))
+	assert.Equal(t, access.Scope{TenantID: searchTenant}, conversations.scope)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/threads/"+searchThread, nil))
+	require.NoError(t, err)
+	assert.Equal(t, 401, resp.StatusCode)
+
+	var body middleware.ErrorResponse
+	assert.Equal(t, 400, callAs(t, app, "user-1", models.RoleTenantAdmin, "/api/v1/threads/nope", &body))
+	assert.Equal(t, 403, callAs(t, app, "no-mailbox", models.RoleUser, "/api/v1/threads/"+searchThread, &body))
+	assert.Equal(t, 404, callAs(t, app, "user-1", models.RoleTenantAdmin, "/api/v1/threads/6a1b6a52-0c4b-4c7c-9d3e-3f1f5a2b7c04", &body))
+	assert.Equal(t, "Thread not found", body.Error.Message)
+
+	conversations.err = errors.New("failed to load thread: connection refused")
+	assert.Equal(t, 500, callAs(t, app, "user-1", models.RoleTenantAdmin, "/api/v1/threads/"+searchThread, &body))
+}
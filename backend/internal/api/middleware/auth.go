@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+
+	"ironarchive/internal/database"
+	apperrors "ironarchive/pkg/errors"
+)
+
+// sessionKey stores the caller's database.Session in the request locals
+type sessionKey struct{}
+
+// Claims are the IronArchive claims of an access token; the subject is the user ID
+type Claims struct {
+	jwt.RegisteredClaims
+	Role     string `json:"role"`
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+// Authenticate verifies the HS256 access token signed with secret and stores
+// the caller's session for SessionFrom. The token is read from the
+// Authorization bearer header, or from the access_token query parameter for
+// clients such as EventSource that cannot set headers.
+func Authenticate(secret string) fiber.Handler {
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	key := func(*jwt.Token) (any, error) { return []byte(secret), nil }
+
+	return func(c fiber.Ctx) error {
+		raw, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok {
+			raw = c.Query("access_token")
+		}
+		if raw == "" {
+			return apperrors.NewUnauthorized("Missing access token")
+		}
+
+		var claims Claims
+		if _, err := parser.ParseWithClaims(raw, &claims, key); err != nil {
+			return apperrors.NewUnauthorized("Invalid access token")
+		}
+		session := database.Session{UserID: claims.Subject, TenantID: claims.TenantID, Role: claims.Role}
+		if session.UserID == "" || session.Validate() != nil {
+			return apperrors.NewUnauthorized("Invalid access token")
+		}
+
+		c.Locals(sessionKey{}, session)
+		return c.Next()
+	}
+}
+
+// SessionFrom returns the session Authenticate stored for the request
+func SessionFrom(c fiber.Ctx) (database.Session, bool) {
+	session, ok := c.Locals(sessionKey{}).(database.Session)
+	return session, ok
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database"
+	"ironarchive/internal/models"
+)
+
+const testSecret = "test-secret"
+
+// signToken signs claims with HS256 and the given secret
+func signToken(t *testing.T, secret string, claims Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return token
+}
+
+// validClaims returns unexpired claims of a tenant admin
+func validClaims() Claims {
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Role:             models.RoleTenantAdmin,
+		TenantID:         "tenant-1",
+	}
+}
+
+// newAuthApp serves the caller's session behind Authenticate
+func newAuthApp() *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler(zap.NewNop())})
+	app.Get("/me", Authenticate(testSecret), func(c fiber.Ctx) error {
+		session, ok := SessionFrom(c)
+		if !ok {
+			return fiber.ErrInternalServerError
+		}
+		return c.JSON(session)
+	})
+	return app
+}
+
+// TestAuthenticateStoresSession verifies header and query tokens yield the caller's session
+func TestAuthenticateStoresSession(t *testing.T) {
+	app := newAuthApp()
+	token := signToken(t, testSecret, validClaims())
+
+	header := httptest.NewRequest("GET", "/me", nil)
+	header.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	query := httptest.NewRequest("GET", "/me?access_token="+token, nil)
+
+	for name, req := range map[string]*http.Request{"header": header, "query": query} {
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode, name)
+		var session database.Session
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&session))
+		resp.Body.Close()
+		assert.Equal(t, database.Session{UserID: "user-1", TenantID: "tenant-1", Role: models.RoleTenantAdmin}, session, name)
+	}
+}
+
+// TestAuthenticateRejects verifies missing, forged, expired and incomplete tokens are rejected
+func TestAuthenticateRejects(t *testing.T) {
+	app := newAuthApp()
+
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	noExpiry := validClaims()
+	noExpiry.ExpiresAt = nil
+	noTenant := validClaims()
+	noTenant.TenantID = ""
+	unknownRole := validClaims()
+	unknownRole.Role = "ROOT"
+
+	tokens := map[string]string{
+		"missing":      "",
+		"malformed":    "not-a-jwt",
+		"forged":       signToken(t, "other-secret", validClaims()),
+		"expired":      signToken(t, testSecret, expired),
+		"no expiry":    signToken(t, testSecret, noExpiry),
+		"no tenant":    signToken(t, testSecret, noTenant),
+		"unknown role": signToken(t, testSecret, unknownRole),
+		"unsigned":     unsignedToken(t),
+	}
+	for name, token := range tokens {
+		req := httptest.NewRequest("GET", "/me", nil)
+		if token != "" {
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var body ErrorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		resp.Body.Close()
+		assert.Equal(t, 401, resp.StatusCode, name)
+		assert.Equal(t, "UNAUTHORIZED", body.Error.Code, name)
+	}
+}
+
+// unsignedToken returns a token using the "none" algorithm
+func unsignedToken(t *testing.T) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	return token
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/cors"
+)
+
+// CORS configures cross-origin access for the given allowed origins
+func CORS(allowedOrigins []string) fiber.Handler {
+	return cors.New(cors.Config{
+		AllowOrigins:  allowedOrigins,
+		AllowMethods:  []string{fiber.MethodGet, fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete, fiber.MethodOptions},
+		AllowHeaders:  []string{fiber.HeaderAuthorization, fiber.HeaderContentType, fiber.HeaderXRequestID},
+		ExposeHeaders: []string{fiber.HeaderXRequestID},
+	})
+}
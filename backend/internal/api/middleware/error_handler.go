@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+	"go.uber.org/zap"
+
+	apperrors "ironarchive/pkg/errors"
+)
+
+// ErrorBody is the payload nested under "error" in every error response
+type ErrorBody struct {
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Timestamp string                 `json:"timestamp"`
+	RequestID string                 `json:"requestId"`
+}
+
+// ErrorResponse is the JSON envelope returned for all API errors
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorHandler returns a Fiber error handler that renders errors in the standard JSON envelope
+func ErrorHandler(logger *zap.Logger) fiber.ErrorHandler {
+	return func(c fiber.Ctx, err error) error {
+		appErr := toAppError(err)
+
+		if appErr.StatusCode >= http.StatusInternalServerError {
+			logger.Error("Request failed",
+				zap.String("request_id", requestid.FromContext(c)),
+				zap.String("method", c.Method()),
+				zap.String("path", c.Path()),
+				zap.Int("status", appErr.StatusCode),
+				zap.Error(err),
+			)
+		}
+
+		return c.Status(appErr.StatusCode).JSON(ErrorResponse{
+			Error: ErrorBody{
+				Code:      appErr.Code,
+				Message:   appErr.Message,
+				Details:   appErr.Details,
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+				RequestID: requestid.FromContext(c),
+			},
+		})
+	}
+}
+
+// toAppError converts any error into an AppError, hiding internal details of unknown errors
+func toAppError(err error) *apperrors.AppError {
+	var appErr *apperrors.AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return apperrors.New(fiberErr.Code, statusCode(fiberErr.Code), fiberErr.Message)
+	}
+
+	return apperrors.NewInternal("An unexpected error occurred")
+}
+
+// statusCode derives a machine-readable code from an HTTP status (e.g. 404 -> NOT_FOUND)
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "UNKNOWN_ERROR"
+	}
+	return strings.ToUpper(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	apperrors "ironarchive/pkg/errors"
+)
+
+// newTestApp creates a Fiber app with the error handler and a single failing route
+func newTestApp(handler fiber.Handler) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler(zap.NewNop())})
+	app.Use(requestid.New())
+	app.Get("/test", handler)
+	return app
+}
+
+// decodeError reads the error envelope from a response body
+func decodeError(t *testing.T, app *fiber.App) (int, ErrorResponse, string) {
+	t.Helper()
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/test", nil))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return resp.StatusCode, body, resp.Header.Get(fiber.HeaderXRequestID)
+}
+
+// TestErrorHandlerAppError verifies AppErrors keep their code, status and details
+func TestErrorHandlerAppError(t *testing.T) {
+	app := newTestApp(func(c fiber.Ctx) error {
+		return apperrors.NewBadRequest("Invalid tenant").WithDetails(map[string]interface{}{"field": "name"})
+	})
+
+	status, body, requestID := decodeError(t, app)
+
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "BAD_REQUEST", body.Error.Code)
+	assert.Equal(t, "Invalid tenant", body.Error.Message)
+	assert.Equal(t, "name", body.Error.Details["field"])
+	assert.NotEmpty(t, body.Error.Timestamp)
+	assert.Equal(t, requestID, body.Error.RequestID, "Envelope should carry the request ID header")
+}
+
+// TestErrorHandlerFiberError verifies Fiber errors are mapped to status-derived codes
+func TestErrorHandlerFiberError(t *testing.T) {
+	app := newTestApp(func(c fiber.Ctx) error {
+		return fiber.NewError(fiber.StatusMethodNotAllowed, "Nope")
+	})
+
+	status, body, _ := decodeError(t, app)
+
+	assert.Equal(t, 405, status)
+	assert.Equal(t, "METHOD_NOT_ALLOWED", body.Error.Code)
+	assert.Equal(t, "Nope", body.Error.Message)
+}
+
+// TestErrorHandlerUnknownError verifies internal error messages are not leaked
+func TestErrorHandlerUnknownError(t *testing.T) {
+	app := newTestApp(func(c fiber.Ctx) error {
+		return errors.New("pq: password authentication failed")
+	})
+
+	status, body, _ := decodeError(t, app)
+
+	assert.Equal(t, 500, status)
+	assert.Equal(t, "INTERNAL_ERROR", body.Error.Code)
+	assert.NotContains(t, body.Error.Message, "password")
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+	"go.uber.org/zap"
+)
+
+// RequestLogger logs method, path, status and duration for every request
+func RequestLogger(logger *zap.Logger) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		start := time.Now()
+
+		// Errors are rendered by the app error handler, so run it here to log the final status
+		err := c.Next()
+		if err != nil {
+			if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		logger.Info("HTTP request",
+			zap.String("request_id", requestid.FromContext(c)),
+			zap.String("method", c.Method()),
+			zap.String("path", c.Path()),
+			zap.Int("status", c.Response().StatusCode()),
+			zap.Duration("duration", time.Since(start)),
+			zap.String("ip", c.IP()),
+		)
+
+		return nil
+	}
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v3"
+
+	"ironarchive/internal/api/handlers"
+)
+
+// Handlers groups the route handlers registered by SetupRoutes
+type Handlers struct {
+	Health   *handlers.HealthHandler
+	Schedule *handlers.ScheduleHandler
+	Jobs     *handlers.JobHandler
+	Events   *handlers.EventsHandler
+	Search   *handlers.SearchHandler
+	Threads  *handlers.ThreadHandler
+}
+
+// SetupRoutes registers all HTTP routes on the application; auth guards every
+// route of the versioned API
+func SetupRoutes(app *fiber.App, h Handlers, auth fiber.Handler) {
+	// Operational probes live outside the versioned API
+	app.Get("/healthz", h.Health.Liveness)
+	app.Get("/readyz", h.Health.Readiness)
+
+	v1 := app.Group("/api/v1", auth)
+	v1.Get("/mailboxes/:id/schedule", h.Schedule.Preview)
+	v1.Get("/jobs/events", h.Events.Stream)
+	v1.Get("/jobs/dead-letter", h.Jobs.DeadLetter)
+	v1.Get("/jobs/:id/attempts", h.Jobs.Attempts)
+	v1.Post("/jobs/:id/cancel", h.Jobs.Cancel)
+	v1.Post("/jobs/:id/retry", h.Jobs.Retry)
+	v1.Get("/search", h.Search.Search)
+	v1.Get("/threads/:id", h.Threads.Get)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/recover"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/api/middleware"
+	"ironarchive/internal/config"
+)
+
+// Server wraps the Fiber application and its lifecycle
+type Server struct {
+	App      *fiber.App
+	addr     string
+	listener net.Listener
+	logger   *zap.Logger
+}
+
+// NewServer creates the Fiber application with the standard middleware chain and routes
+func NewServer(cfg *config.Config, logger *zap.Logger, h Handlers) *Server {
+	app := fiber.New(fiber.Config{
+		AppName:      "IronArchive",
+		ReadTimeout:  cfg.ServerReadTimeout,
+		WriteTimeout: cfg.ServerWriteTimeout,
+		IdleTimeout:  cfg.ServerIdleTimeout,
+		ErrorHandler: middleware.ErrorHandler(logger),
+	})
+
+	// Middleware order: RequestID -> Logging -> CORS -> Recover
+	app.Use(requestid.New())
+	app.Use(middleware.RequestLogger(logger))
+	app.Use(middleware.CORS(cfg.CORSOrigins))
+	app.Use(recover.New(recover.Config{EnableStackTrace: cfg.LogLevel == "debug"}))
+
+	SetupRoutes(app, h, middleware.Authenticate(cfg.JWTSecret))
+
+	return &Server{
+		App:    app,
+		addr:   net.JoinHostPort(cfg.ServerHost, cfg.ServerPort),
+		logger: logger,
+	}
+}
+
+// Addr returns the host:port the server is bound to, or binds to until Bind
+// is called
+func (s *Server) Addr() string {
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.addr
+}
+
+// Bind binds the configured address. Connections queue up until Serve is
+// called, so the server is reachable once Bind returns.
+func (s *Server) Bind() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+	s.listener = ln
+	return nil
+}
+
+// Serve serves requests on the bound address until Shutdown is called
+func (s *Server) Serve() error {
+	if s.listener == nil {
+		return fmt.Errorf("failed to serve on %s: not bound", s.addr)
+	}
+	if err := s.App.Listener(s.listener, fiber.ListenConfig{DisableStartupMessage: true}); err != nil {
+		return fmt.Errorf("failed to serve on %s: %w", s.Addr(), err)
+	}
+	return nil
+}
+
+// Shutdown stops accepting new connections and drains in-flight requests until ctx expires
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.App.ShutdownWithContext(ctx); err != nil {
+		return fmt.Errorf("failed to shut down HTTP server: %w", err)
+	}
+	s.logger.Info("HTTP server stopped")
+	return nil
+}
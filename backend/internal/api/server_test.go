@@ -0,0 +1,193 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/api/handlers"
+	"ironarchive/internal/api/middleware"
+	"ironarchive/internal/config"
+	"ironarchive/internal/database"
+	"ironarchive/internal/health"
+	"ironarchive/internal/models"
+	"ironarchive/internal/progress"
+)
+
+// newTestConfig returns the minimal configuration needed to build a server
+func newTestConfig() *config.Config {
+	return &config.Config{
+		ServerHost:  "127.0.0.1",
+		ServerPort:  "0",
+		CORSOrigins: []string{"http://localhost:5173"},
+	}
+}
+
+// newTestServer builds a server with no registered dependencies
+func newTestServer() *Server {
+	logger := zap.NewNop()
+	return NewServer(newTestConfig(), logger, Handlers{
+		Health: handlers.NewHealthHandler(health.NewChecker(), logger),
+	})
+}
+
+// TestServerUnknownRouteReturnsEnvelope verifies unmatched routes use the JSON error format
+func TestServerUnknownRouteReturnsEnvelope(t *testing.T) {
+	server := newTestServer()
+
+	resp, err := server.App.Test(httptest.NewRequest("GET", "/does-not-exist", nil))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, 404, resp.StatusCode)
+	requestID := resp.Header.Get(fiber.HeaderXRequestID)
+	assert.NotEmpty(t, requestID, "Every response should carry a request ID")
+
+	var body middleware.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "NOT_FOUND", body.Error.Code)
+	assert.Equal(t, requestID, body.Error.RequestID)
+}
+
+// TestServerPropagatesRequestID verifies a client-supplied request ID is echoed back
+func TestServerPropagatesRequestID(t *testing.T) {
+	server := newTestServer()
+
+	req := httptest.NewRequest("GET", "/does-not-exist", nil)
+	req.Header.Set(fiber.HeaderXRequestID, "client-request-123")
+
+	resp, err := server.App.Test(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "client-request-123", resp.Header.Get(fiber.HeaderXRequestID))
+}
+
+// TestServerRecoversFromPanic verifies panics become 500 JSON errors instead of crashing
+func TestServerRecoversFromPanic(t *testing.T) {
+	server := newTestServer()
+	server.App.Get("/panic", func(c fiber.Ctx) error {
+		panic("boom")
+	})
+
+	resp, err := server.App.Test(httptest.NewRequest("GET", "/panic", nil))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body middleware.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, 500, resp.StatusCode)
+	assert.Equal(t, "INTERNAL_ERROR", body.Error.Code)
+}
+
+// TestServerCORSPreflight verifies allowed origins receive CORS headers
+func TestServerCORSPreflight(t *testing.T) {
+	server := newTestServer()
+
+	req := httptest.NewRequest("OPTIONS", "/api/v1/tenants", nil)
+	req.Header.Set(fiber.HeaderOrigin, "http://localhost:5173")
+	req.Header.Set(fiber.HeaderAccessControlRequestMethod, "GET")
+
+	resp, err := server.App.Test(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "http://localhost:5173", resp.Header.Get(fiber.HeaderAccessControlAllowOrigin))
+}
+
+// TestServerAPIRequiresToken verifies every versioned API route rejects anonymous callers
+func TestServerAPIRequiresToken(t *testing.T) {
+	server := newTestServer()
+
+	for _, route := range []struct{ method, path string }{
+		{"GET", "/api/v1/mailboxes/6f1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d/schedule"},
+		{"GET", "/api/v1/jobs/dead-letter"},
+		{"GET", "/api/v1/jobs/6f1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d/attempts"},
+		{"POST", "/api/v1/jobs/6f1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d/cancel"},
+		{"POST", "/api/v1/jobs/6f1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d/retry"},
+		{"GET", "/api/v1/jobs/events"},
+		{"GET", "/api/v1/search"},
+	} {
+		resp, err := server.App.Test(httptest.NewRequest(route.method, route.path, nil))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, 401, resp.StatusCode, route.path)
+	}
+}
+
+// TestServerEventStreamOutlivesWriteTimeout verifies the job event stream
+// stays open past the server's write timeout
+func TestServerEventStreamOutlivesWriteTimeout(t *testing.T) {
+	mr := miniredis.RunT(t)
+	conn, err := database.NewRedisConnection("redis://"+mr.Addr(), zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	hub := progress.NewHub(conn, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	go hub.Run(ctx)
+
+	cfg := newTestConfig()
+	cfg.JWTSecret = "server-secret"
+	cfg.ServerWriteTimeout = 200 * time.Millisecond
+	logger := zap.NewNop()
+	server := NewServer(cfg, logger, Handlers{
+		Health: handlers.NewHealthHandler(health.NewChecker(), logger),
+		Events: handlers.NewEventsHandler(hub, logger),
+	})
+	require.NoError(t, server.Bind())
+	go server.Serve()
+	t.Cleanup(func() {
+		cancel()
+		server.App.ShutdownWithTimeout(5 * time.Second)
+	})
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, middleware.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "msp-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Role:             models.RoleMSPAdmin,
+	}).SignedString([]byte(cfg.JWTSecret))
+	require.NoError(t, err)
+	resp, err := http.Get("http://" + server.Addr() + "/api/v1/jobs/events?access_token=" + token)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, 200, resp.StatusCode)
+
+	// Keep-alives must keep arriving well after the first write timeout
+	scanner := bufio.NewScanner(resp.Body)
+	deadline := time.Now().Add(5 * cfg.ServerWriteTimeout)
+	for time.Now().Before(deadline) {
+		require.True(t, scanner.Scan(), "Stream closed before the deadline")
+	}
+}
+
+// TestServerBindBeforeServe verifies the server accepts connections as soon
+// as Bind returns and reports an address already in use
+func TestServerBindBeforeServe(t *testing.T) {
+	server := newTestServer()
+	require.NoError(t, server.Bind())
+	conn, err := net.Dial("tcp", server.Addr())
+	require.NoError(t, err, "Bind alone must make the server reachable")
+	conn.Close()
+
+	go server.Serve()
+	t.Cleanup(func() { server.Shutdown(context.Background()) })
+	resp, err := http.Get("http://" + server.Addr() + "/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 200, resp.StatusCode)
+
+	busy := newTestServer()
+	busy.addr = server.Addr()
+	assert.ErrorContains(t, busy.Bind(), "failed to listen on "+server.Addr())
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -21,6 +22,87 @@ type Config struct {
 	LogLevel         string
 	LogFormat        string
 	EmailStoragePath string
+	CORSOrigins      []string
+
+	// Archive storage backend ("local" uses EmailStoragePath, "s3" the S3 settings)
+	StorageBackend    string
+	S3Endpoint        string
+	S3Region          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3UseSSL          bool
+	S3PathStyle       bool
+	S3Bucket          string
+	S3Prefix          string
+	S3TenantLocations string
+	S3SSE             string
+	S3SSEKMSKeyID     string
+	S3PartSizeMB      int32
+	S3MaxRetries      int32
+
+	// Credential vault master keys ("id:base64" entries, first is active)
+	VaultMasterKeys     string
+	VaultMasterKeysFile string
+
+	// Microsoft Graph endpoints (overridable to point sync at a test server)
+	GraphBaseURL   string
+	GraphLoginURL  string
+	GraphTimeout   time.Duration
+	GraphPageSize  int32
+	GraphBatchSize int32
+
+	// Graph throttling: retries and per-tenant request budget shared through Redis
+	GraphMaxRetries     int32
+	GraphRetryBaseDelay time.Duration
+	GraphRetryMaxDelay  time.Duration
+	GraphMaxConcurrency int32
+	GraphRateLimit      int32
+	GraphRateWindow     time.Duration
+
+	// Background job queue (Redis streams)
+	JobWorkers           string
+	JobVisibilityTimeout time.Duration
+	JobMaxDeliveries     int32
+	JobMaxAttempts       int32
+	JobRetryBaseDelay    time.Duration
+	JobRetryMaxDelay     time.Duration
+
+	// Sync scheduler (schedule itself lives in settings.sync_schedule)
+	SchedulerTimezone       string
+	SchedulerCatchUp        string
+	SchedulerCatchUpWindow  time.Duration
+	SchedulerReloadInterval time.Duration
+	SchedulerMisfireGrace   time.Duration
+
+	// Search indexer
+	SearchIndexBatchSize  int32
+	SearchIndexInterval   time.Duration
+	SearchIndexMaxRetries int32
+	SearchIndexRetryDelay time.Duration
+
+	// Search queries
+	SearchTenantTokens   bool
+	SearchTenantTokenTTL time.Duration
+	// PostgreSQL full-text search while Meilisearch is unavailable
+	SearchPostgresFallback bool
+	SearchFallbackCooldown time.Duration
+
+	// Attachment text extraction
+	ExtractEnabled       bool
+	ExtractBatchSize     int32
+	ExtractConcurrency   int32
+	ExtractInterval      time.Duration
+	ExtractTimeout       time.Duration
+	ExtractMaxInputMB    int32
+	ExtractMaxExpandedMB int32
+	ExtractMaxTextKB     int32
+	ExtractMemoryLimitMB int32
+	ExtractMaxDepth      int32
+
+	// HTTP server configuration
+	ServerReadTimeout  time.Duration
+	ServerWriteTimeout time.Duration
+	ServerIdleTimeout  time.Duration
 
 	// Database connection pool configuration
 	DBMaxConns          int32
@@ -32,6 +114,14 @@ type Config struct {
 	// Timeout configuration
 	MeilisearchTimeout time.Duration
 	ShutdownTimeout    time.Duration
+	HealthCheckTimeout time.Duration
+
+	// Startup dependency wait configuration
+	StartupTimeout              time.Duration
+	StartupRetryInitialInterval time.Duration
+	StartupRetryMaxInterval     time.Duration
+	StartupRetryMultiplier      float64
+	StartupAllowDegraded        bool
 }
 
 // Load reads configuration from environment variables
@@ -50,6 +140,86 @@ func Load() (*Config, error) {
 		LogLevel:         getEnv("LOG_LEVEL", "info"),
 		LogFormat:        getEnv("LOG_FORMAT", "json"),
 		EmailStoragePath: getEnv("EMAIL_STORAGE_PATH", "./data/emails"),
+		CORSOrigins:      getEnvAsSlice("CORS_ORIGINS", []string{"http://localhost:5173"}),
+
+		// Archive storage
+		StorageBackend:    getEnv("STORAGE_BACKEND", "local"),
+		S3Endpoint:        getEnv("S3_ENDPOINT", ""),
+		S3Region:          getEnv("S3_REGION", "us-east-1"),
+		S3AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+		S3UseSSL:          getEnvAsBool("S3_USE_SSL", true),
+		S3PathStyle:       getEnvAsBool("S3_PATH_STYLE", false),
+		S3Bucket:          getEnv("S3_BUCKET", ""),
+		S3Prefix:          getEnv("S3_PREFIX", ""),
+		S3TenantLocations: getEnv("S3_TENANT_LOCATIONS", ""),
+		S3SSE:             getEnv("S3_SSE", ""),
+		S3SSEKMSKeyID:     getEnv("S3_SSE_KMS_KEY_ID", ""),
+		S3PartSizeMB:      getEnvAsInt32("S3_PART_SIZE_MB", 16),
+		S3MaxRetries:      getEnvAsInt32("S3_MAX_RETRIES", 5),
+
+		// Credential vault
+		VaultMasterKeys:     getEnv("VAULT_MASTER_KEYS", ""),
+		VaultMasterKeysFile: getEnv("VAULT_MASTER_KEYS_FILE", ""),
+
+		// Microsoft Graph
+		GraphBaseURL:   getEnv("GRAPH_BASE_URL", "https://graph.microsoft.com/v1.0"),
+		GraphLoginURL:  getEnv("GRAPH_LOGIN_URL", "https://login.microsoftonline.com"),
+		GraphTimeout:   getEnvAsDuration("GRAPH_TIMEOUT", 2*time.Minute),
+		GraphPageSize:  getEnvAsInt32("GRAPH_PAGE_SIZE", 50),
+		GraphBatchSize: getEnvAsInt32("GRAPH_BATCH_SIZE", 20),
+
+		// Graph throttling
+		GraphMaxRetries:     getEnvAsInt32("GRAPH_MAX_RETRIES", 3),
+		GraphRetryBaseDelay: getEnvAsDuration("GRAPH_RETRY_BASE_DELAY", 1*time.Second),
+		GraphRetryMaxDelay:  getEnvAsDuration("GRAPH_RETRY_MAX_DELAY", 1*time.Minute),
+		GraphMaxConcurrency: getEnvAsInt32("GRAPH_MAX_CONCURRENCY", 5),
+		GraphRateLimit:      getEnvAsInt32("GRAPH_RATE_LIMIT", 900),
+		GraphRateWindow:     getEnvAsDuration("GRAPH_RATE_WINDOW", 1*time.Minute),
+
+		// Background job queue
+		JobWorkers:           getEnv("JOB_WORKERS", "SYNC_MAILBOX=4,SYNC_TENANT=2,SYNC_ALL=1"),
+		JobVisibilityTimeout: getEnvAsDuration("JOB_VISIBILITY_TIMEOUT", 5*time.Minute),
+		JobMaxDeliveries:     getEnvAsInt32("JOB_MAX_DELIVERIES", 3),
+		JobMaxAttempts:       getEnvAsInt32("JOB_MAX_ATTEMPTS", 3),
+		JobRetryBaseDelay:    getEnvAsDuration("JOB_RETRY_BASE_DELAY", 30*time.Second),
+		JobRetryMaxDelay:     getEnvAsDuration("JOB_RETRY_MAX_DELAY", 10*time.Minute),
+
+		// Sync scheduler
+		SchedulerTimezone:       getEnv("SCHEDULER_TIMEZONE", "UTC"),
+		SchedulerCatchUp:        getEnv("SCHEDULER_CATCH_UP", "latest"),
+		SchedulerCatchUpWindow:  getEnvAsDuration("SCHEDULER_CATCH_UP_WINDOW", 24*time.Hour),
+		SchedulerReloadInterval: getEnvAsDuration("SCHEDULER_RELOAD_INTERVAL", 30*time.Second),
+		SchedulerMisfireGrace:   getEnvAsDuration("SCHEDULER_MISFIRE_GRACE", 1*time.Minute),
+
+		// Search indexer
+		SearchIndexBatchSize:  getEnvAsInt32("SEARCH_INDEX_BATCH_SIZE", 500),
+		SearchIndexInterval:   getEnvAsDuration("SEARCH_INDEX_INTERVAL", 10*time.Second),
+		SearchIndexMaxRetries: getEnvAsInt32("SEARCH_INDEX_MAX_RETRIES", 3),
+		SearchIndexRetryDelay: getEnvAsDuration("SEARCH_INDEX_RETRY_DELAY", 1*time.Second),
+
+		// Search queries
+		SearchTenantTokens:     getEnvAsBool("SEARCH_TENANT_TOKENS", false),
+		SearchTenantTokenTTL:   getEnvAsDuration("SEARCH_TENANT_TOKEN_TTL", 10*time.Minute),
+		SearchPostgresFallback: getEnvAsBool("SEARCH_POSTGRES_FALLBACK", true),
+		SearchFallbackCooldown: getEnvAsDuration("SEARCH_FALLBACK_COOLDOWN", 30*time.Second),
+
+		// Attachment text extraction
+		ExtractEnabled:       getEnvAsBool("EXTRACT_ENABLED", true),
+		ExtractBatchSize:     getEnvAsInt32("EXTRACT_BATCH_SIZE", 20),
+		ExtractConcurrency:   getEnvAsInt32("EXTRACT_CONCURRENCY", 2),
+		ExtractInterval:      getEnvAsDuration("EXTRACT_INTERVAL", 10*time.Second),
+		ExtractTimeout:       getEnvAsDuration("EXTRACT_TIMEOUT", 30*time.Second),
+		ExtractMaxInputMB:    getEnvAsInt32("EXTRACT_MAX_INPUT_MB", 50),
+		ExtractMaxExpandedMB: getEnvAsInt32("EXTRACT_MAX_EXPANDED_MB", 256),
+		ExtractMaxTextKB:     getEnvAsInt32("EXTRACT_MAX_TEXT_KB", 1024),
+		ExtractMemoryLimitMB: getEnvAsInt32("EXTRACT_MEMORY_LIMIT_MB", 512),
+		ExtractMaxDepth:      getEnvAsInt32("EXTRACT_MAX_DEPTH", 3),
+
+		// HTTP server timeouts
+		ServerReadTimeout:  getEnvAsDuration("SERVER_READ_TIMEOUT", 30*time.Second),
+		ServerWriteTimeout: getEnvAsDuration("SERVER_WRITE_TIMEOUT", 30*time.Second),
+		ServerIdleTimeout:  getEnvAsDuration("SERVER_IDLE_TIMEOUT", 2*time.Minute),
 
 		// Database pool configuration with sensible defaults
 		DBMaxConns:          getEnvAsInt32("DB_MAX_CONNS", 25),
@@ -61,6 +231,14 @@ func Load() (*Config, error) {
 		// Timeout configuration
 		MeilisearchTimeout: getEnvAsDuration("MEILISEARCH_TIMEOUT", 5*time.Second),
 		ShutdownTimeout:    getEnvAsDuration("SHUTDOWN_TIMEOUT", 10*time.Second),
+		HealthCheckTimeout: getEnvAsDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+
+		// Startup dependency wait configuration
+		StartupTimeout:              getEnvAsDuration("STARTUP_TIMEOUT", 60*time.Second),
+		StartupRetryInitialInterval: getEnvAsDuration("STARTUP_RETRY_INITIAL_INTERVAL", 500*time.Millisecond),
+		StartupRetryMaxInterval:     getEnvAsDuration("STARTUP_RETRY_MAX_INTERVAL", 10*time.Second),
+		StartupRetryMultiplier:      getEnvAsFloat64("STARTUP_RETRY_MULTIPLIER", 2.0),
+		StartupAllowDegraded:        getEnvAsBool("STARTUP_ALLOW_DEGRADED", false),
 	}
 
 	// Validate required configuration
@@ -79,6 +257,78 @@ func Load() (*Config, error) {
 	if cfg.JWTSecret == "" {
 		return nil, fmt.Errorf("JWT_SECRET is required")
 	}
+	if cfg.StorageBackend != "local" && cfg.StorageBackend != "s3" {
+		return nil, fmt.Errorf("STORAGE_BACKEND must be local or s3")
+	}
+	if cfg.VaultMasterKeys == "" && cfg.VaultMasterKeysFile == "" {
+		return nil, fmt.Errorf("VAULT_MASTER_KEYS or VAULT_MASTER_KEYS_FILE is required")
+	}
+	if cfg.StartupRetryInitialInterval <= 0 {
+		return nil, fmt.Errorf("STARTUP_RETRY_INITIAL_INTERVAL must be positive")
+	}
+	if cfg.StartupRetryMaxInterval < cfg.StartupRetryInitialInterval {
+		return nil, fmt.Errorf("STARTUP_RETRY_MAX_INTERVAL must not be less than STARTUP_RETRY_INITIAL_INTERVAL")
+	}
+	if cfg.GraphPageSize < 1 || cfg.GraphPageSize > 1000 {
+		return nil, fmt.Errorf("GRAPH_PAGE_SIZE must be between 1 and 1000")
+	}
+	if cfg.GraphBatchSize < 1 || cfg.GraphBatchSize > 20 {
+		return nil, fmt.Errorf("GRAPH_BATCH_SIZE must be between 1 and 20")
+	}
+	if cfg.JobVisibilityTimeout < 3*time.Second {
+		return nil, fmt.Errorf("JOB_VISIBILITY_TIMEOUT must be at least 3s")
+	}
+	if cfg.JobMaxDeliveries < 1 {
+		return nil, fmt.Errorf("JOB_MAX_DELIVERIES must be at least 1")
+	}
+	if cfg.JobMaxAttempts < 1 {
+		return nil, fmt.Errorf("JOB_MAX_ATTEMPTS must be at least 1")
+	}
+	if cfg.JobRetryBaseDelay < time.Second {
+		return nil, fmt.Errorf("JOB_RETRY_BASE_DELAY must be at least 1s")
+	}
+	if cfg.JobRetryMaxDelay < cfg.JobRetryBaseDelay {
+		return nil, fmt.Errorf("JOB_RETRY_MAX_DELAY must not be less than JOB_RETRY_BASE_DELAY")
+	}
+	if cfg.SchedulerCatchUp != "skip" && cfg.SchedulerCatchUp != "latest" {
+		return nil, fmt.Errorf("SCHEDULER_CATCH_UP must be skip or latest")
+	}
+	if cfg.SearchIndexBatchSize < 1 || cfg.SearchIndexBatchSize > 10000 {
+		return nil, fmt.Errorf("SEARCH_INDEX_BATCH_SIZE must be between 1 and 10000")
+	}
+	if cfg.SearchIndexInterval < time.Second {
+		return nil, fmt.Errorf("SEARCH_INDEX_INTERVAL must be at least 1s")
+	}
+	if cfg.SearchIndexMaxRetries < 0 {
+		return nil, fmt.Errorf("SEARCH_INDEX_MAX_RETRIES must not be negative")
+	}
+	if cfg.SearchTenantTokenTTL < time.Minute {
+		return nil, fmt.Errorf("SEARCH_TENANT_TOKEN_TTL must be at least 1m")
+	}
+	if cfg.SearchFallbackCooldown < time.Second {
+		return nil, fmt.Errorf("SEARCH_FALLBACK_COOLDOWN must be at least 1s")
+	}
+	if cfg.ExtractBatchSize < 1 || cfg.ExtractBatchSize > 1000 {
+		return nil, fmt.Errorf("EXTRACT_BATCH_SIZE must be between 1 and 1000")
+	}
+	if cfg.ExtractConcurrency < 1 || cfg.ExtractConcurrency > 64 {
+		return nil, fmt.Errorf("EXTRACT_CONCURRENCY must be between 1 and 64")
+	}
+	if cfg.ExtractInterval < time.Second {
+		return nil, fmt.Errorf("EXTRACT_INTERVAL must be at least 1s")
+	}
+	if cfg.ExtractTimeout < time.Second {
+		return nil, fmt.Errorf("EXTRACT_TIMEOUT must be at least 1s")
+	}
+	if cfg.ExtractMaxInputMB < 1 || cfg.ExtractMaxExpandedMB < 1 || cfg.ExtractMaxTextKB < 1 {
+		return nil, fmt.Errorf("EXTRACT_MAX_INPUT_MB, EXTRACT_MAX_EXPANDED_MB and EXTRACT_MAX_TEXT_KB must be at least 1")
+	}
+	if cfg.ExtractMemoryLimitMB < 64 {
+		return nil, fmt.Errorf("EXTRACT_MEMORY_LIMIT_MB must be at least 64")
+	}
+	if cfg.ExtractMaxDepth < 0 || cfg.ExtractMaxDepth > 10 {
+		return nil, fmt.Errorf("EXTRACT_MAX_DEPTH must be between 0 and 10")
+	}
 
 	return cfg, nil
 }
@@ -116,3 +366,47 @@ func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	}
 	return value
 }
+
+// getEnvAsFloat64 retrieves an environment variable as float64 or returns a default value
+func getEnvAsFloat64(key string, defaultValue float64) float64 {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsBool retrieves an environment variable as bool or returns a default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsSlice retrieves a comma-separated environment variable as a string slice or returns a default value
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	var values []string
+	for _, part := range strings.Split(valueStr, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	if len(values) == 0 {
+		return defaultValue
+	}
+	return values
+}
@@ -11,6 +11,8 @@ import (
 // MeilisearchConnection manages Meilisearch client connection
 type MeilisearchConnection struct {
 	Client meilisearch.ServiceManager
+	// URL is the server address, for clients with other API keys
+	URL    string
 	logger *zap.Logger
 }
 
@@ -21,6 +23,7 @@ func NewMeilisearchConnection(meilisearchURL, masterKey string, logger *zap.Logg
 
 	return &MeilisearchConnection{
 		Client: client,
+		URL:    meilisearchURL,
 		logger: logger,
 	}, nil
 }
@@ -28,7 +31,7 @@ func NewMeilisearchConnection(meilisearchURL, masterKey string, logger *zap.Logg
 // Ping validates the Meilisearch connection
 func (m *MeilisearchConnection) Ping(ctx context.Context) error {
 	// Check health endpoint
-	health, err := m.Client.Health()
+	health, err := m.Client.HealthWithContext(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to check Meilisearch health: %w", err)
 	}
@@ -0,0 +1,322 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// migrationFiles holds the SQL migrations compiled into the binary
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the PostgreSQL advisory lock key held while migrating,
+// so replicas starting at the same time apply migrations one at a time
+const migrationLockID int64 = 0x1a0c_a7c4_1e00_0001
+
+// migrationFilePattern matches golang-migrate style file names (000001_name.up.sql)
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// ErrDirtyDatabase is returned when a previous migration did not complete cleanly
+var ErrDirtyDatabase = errors.New("database is in a dirty migration state")
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes the currently applied schema version
+type MigrationStatus struct {
+	Version uint
+	Dirty   bool
+	Latest  uint
+}
+
+// Pending reports whether embedded migrations newer than the database exist
+func (s MigrationStatus) Pending() bool {
+	return s.Version < s.Latest
+}
+
+// Migrator applies embedded migrations and tracks state in the schema_migrations
+// table, using the same layout as the golang-migrate CLI so existing databases
+// migrated with `make migrate-up` are picked up seamlessly
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+	logger     *zap.Logger
+}
+
+// NewMigrator creates a migrator for the embedded migrations
+func NewMigrator(pool *pgxpool.Pool, logger *zap.Logger) (*Migrator, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded migrations: %w", err)
+	}
+
+	migrations, err := loadMigrations(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		pool:       pool,
+		migrations: migrations,
+		logger:     logger,
+	}, nil
+}
+
+// loadMigrations reads and pairs up/down files, sorted by version
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, entry := range entries {
+		matches := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		contents, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[uint(version)]
+		if !ok {
+			m = &Migration{Version: uint(version), Name: matches[2]}
+			byVersion[uint(version)] = m
+		} else if m.Name != matches[2] {
+			return nil, fmt.Errorf("migration version %d has conflicting names %q and %q", version, m.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			m.Up = string(contents)
+		} else {
+			m.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s is missing its up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Latest returns the highest embedded migration version
+func (m *Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status returns the applied version and whether it is dirty
+func (m *Migrator) Status(ctx context.Context) (MigrationStatus, error) {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return MigrationStatus{}, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if err := ensureMigrationsTable(ctx, conn.Conn()); err != nil {
+		return MigrationStatus{}, err
+	}
+
+	status, err := readMigrationStatus(ctx, conn.Conn())
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	status.Latest = m.Latest()
+	return status, nil
+}
+
+// Up applies all pending migrations. Each migration runs in its own
+// transaction together with the version bump, so a failure leaves the
+// schema at the previous version rather than half-applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *pgx.Conn) error {
+		status, err := readMigrationStatus(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if status.Dirty {
+			return fmt.Errorf("%w at version %d: fix the schema manually and reset the dirty flag in schema_migrations", ErrDirtyDatabase, status.Version)
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version <= status.Version {
+				continue
+			}
+
+			m.logger.Info("Applying migration",
+				zap.Uint("version", migration.Version),
+				zap.String("name", migration.Name),
+			)
+			if err := applyMigration(ctx, conn, migration.Up, migration.Version, true); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back every applied migration in reverse order
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *pgx.Conn) error {
+		status, err := readMigrationStatus(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if status.Dirty {
+			return fmt.Errorf("%w at version %d", ErrDirtyDatabase, status.Version)
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if migration.Version > status.Version {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+
+			previous := uint(0)
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+
+			m.logger.Info("Rolling back migration",
+				zap.Uint("version", migration.Version),
+				zap.String("name", migration.Name),
+			)
+			if err := applyMigration(ctx, conn, migration.Down, previous, previous > 0); err != nil {
+				return fmt.Errorf("rollback of %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// withLock runs fn on a dedicated connection holding the migration advisory lock
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgx.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	m.logger.Debug("Waiting for migration lock")
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		// Use a fresh context so the lock is released even if ctx was cancelled
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID); err != nil {
+			m.logger.Warn("Failed to release migration lock", zap.Error(err))
+		}
+	}()
+
+	if err := ensureMigrationsTable(ctx, conn.Conn()); err != nil {
+		return err
+	}
+	return fn(conn.Conn())
+}
+
+// ensureMigrationsTable creates the version table if it does not exist
+func ensureMigrationsTable(ctx context.Context, conn *pgx.Conn) error {
+	_, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// readMigrationStatus returns the recorded version, or zero if none is applied
+func readMigrationStatus(ctx context.Context, conn *pgx.Conn) (MigrationStatus, error) {
+	var version int64
+	var dirty bool
+	err := conn.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return MigrationStatus{}, nil
+	}
+	if err != nil {
+		return MigrationStatus{}, fmt.Errorf("failed to read migration version: %w", err)
+	}
+	return MigrationStatus{Version: uint(version), Dirty: dirty}, nil
+}
+
+// applyMigration executes a migration script and records the resulting version atomically
+func applyMigration(ctx context.Context, conn *pgx.Conn, script string, version uint, recordVersion bool) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// No arguments means pgx uses the simple protocol, which allows multi-statement scripts
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, "TRUNCATE schema_migrations"); err != nil {
+		return fmt.Errorf("failed to reset migration version: %w", err)
+	}
+	if recordVersion {
+		if _, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, FALSE)", int64(version)); err != nil {
+			return fmt.Errorf("failed to record migration version: %w", err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Migrate applies all pending embedded migrations to the connected database
+func (p *PostgresConnection) Migrate(ctx context.Context) error {
+	migrator, err := NewMigrator(p.Pool, p.logger)
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+
+	p.logger.Info("Database migrations up to date",
+		zap.Uint("version", migrator.Latest()),
+		zap.Int("applied", applied),
+	)
+	return nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// TestEmbeddedMigrationsLoad verifies the migrations compiled into the binary are well-formed
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	migrator, err := NewMigrator(nil, zap.NewNop())
+	require.NoError(t, err, "Embedded migrations should load")
+
+	require.NotEmpty(t, migrator.migrations)
+	assert.Equal(t, uint(1), migrator.migrations[0].Version)
+	assert.Equal(t, "initial_schema", migrator.migrations[0].Name)
+	assert.Contains(t, migrator.migrations[0].Up, "CREATE TABLE tenants")
+	assert.Contains(t, migrator.migrations[0].Down, "DROP TABLE IF EXISTS tenants")
+
+	for _, m := range migrator.migrations {
+		assert.NotEmpty(t, m.Down, "Migration %d_%s should have a down file", m.Version, m.Name)
+	}
+}
+
+// TestLoadMigrationsOrdering verifies migrations are sorted numerically and paired
+func TestLoadMigrationsOrdering(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000010_ten.up.sql":   {Data: []byte("SELECT 10")},
+		"000010_ten.down.sql": {Data: []byte("SELECT -10")},
+		"000002_two.up.sql":   {Data: []byte("SELECT 2")},
+		"000002_two.down.sql": {Data: []byte("SELECT -2")},
+		"README.md":           {Data: []byte("ignored")},
+	}
+
+	migrations, err := loadMigrations(fsys)
+	require.NoError(t, err)
+
+	require.Len(t, migrations, 2)
+	assert.Equal(t, uint(2), migrations[0].Version)
+	assert.Equal(t, "SELECT -2", migrations[0].Down)
+	assert.Equal(t, uint(10), migrations[1].Version)
+	assert.Equal(t, "SELECT 10", migrations[1].Up)
+}
+
+// TestLoadMigrationsMissingUp verifies a down-only migration is rejected
+func TestLoadMigrationsMissingUp(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000001_orphan.down.sql": {Data: []byte("SELECT 1")},
+	}
+
+	_, err := loadMigrations(fsys)
+	assert.ErrorContains(t, err, "missing its up file")
+}
+
+// TestLoadMigrationsConflictingNames verifies two names for one version are rejected
+func TestLoadMigrationsConflictingNames(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000001_first.up.sql":    {Data: []byte("SELECT 1")},
+		"000001_second.up.sql":   {Data: []byte("SELECT 1")},
+		"000001_second.down.sql": {Data: []byte("SELECT 1")},
+	}
+
+	_, err := loadMigrations(fsys)
+	assert.ErrorContains(t, err, "conflicting names")
+}
@@ -0,0 +1,59 @@
+-- ============================================================================
+-- Migration Rollback: 000002_row_level_security
+-- Description: Drop RLS policies, helper functions and the scoped role
+-- Created: 2025-10-18
+-- ============================================================================
+
+-- ============================================================================
+-- SECTION 1: Drop Policies
+-- ============================================================================
+
+DROP POLICY IF EXISTS settings_write ON settings;
+DROP POLICY IF EXISTS settings_read ON settings;
+DROP POLICY IF EXISTS tenant_isolation ON audit_logs;
+DROP POLICY IF EXISTS tenant_isolation ON jobs;
+DROP POLICY IF EXISTS tenant_isolation ON attachments;
+DROP POLICY IF EXISTS tenant_isolation ON emails;
+DROP POLICY IF EXISTS tenant_isolation ON mailboxes;
+DROP POLICY IF EXISTS tenant_isolation ON users;
+DROP POLICY IF EXISTS tenant_isolation ON tenants;
+
+-- ============================================================================
+-- SECTION 2: Disable Row-Level Security
+-- ============================================================================
+
+ALTER TABLE settings DISABLE ROW LEVEL SECURITY;
+ALTER TABLE audit_logs DISABLE ROW LEVEL SECURITY;
+ALTER TABLE jobs DISABLE ROW LEVEL SECURITY;
+ALTER TABLE attachments DISABLE ROW LEVEL SECURITY;
+ALTER TABLE emails DISABLE ROW LEVEL SECURITY;
+ALTER TABLE mailboxes DISABLE ROW LEVEL SECURITY;
+ALTER TABLE users DISABLE ROW LEVEL SECURITY;
+ALTER TABLE tenants DISABLE ROW LEVEL SECURITY;
+
+-- ============================================================================
+-- SECTION 3: Drop Functions
+-- ============================================================================
+
+DROP FUNCTION IF EXISTS app_is_msp_admin();
+DROP FUNCTION IF EXISTS app_current_tenant_id();
+
+-- ============================================================================
+-- SECTION 4: Drop Scoped Role
+-- ============================================================================
+
+REVOKE ALL ON tenants, users, mailboxes, emails, attachments, jobs, audit_logs, settings FROM ironarchive_tenant_scope;
+REVOKE USAGE ON SCHEMA public FROM ironarchive_tenant_scope;
+
+-- The role is cluster-wide; keep it if another database still references it
+DO $$
+BEGIN
+    DROP ROLE IF EXISTS ironarchive_tenant_scope;
+EXCEPTION WHEN dependent_objects_still_exist THEN
+    RAISE NOTICE 'ironarchive_tenant_scope still in use elsewhere, not dropped';
+END
+$$;
+
+-- ============================================================================
+-- Rollback Complete
+-- ============================================================================
@@ -0,0 +1,125 @@
+-- ============================================================================
+-- Migration: 000002_row_level_security
+-- Description: Enforce tenant isolation with PostgreSQL Row-Level Security
+-- Created: 2025-10-18
+-- ============================================================================
+--
+-- Request-scoped connections switch to the ironarchive_tenant_scope role and
+-- set app.tenant_id / app.role (see repositories.Store.WithSession). Policies
+-- below restrict that role to rows of its own tenant unless app.role is
+-- MSP_ADMIN. The table owner (migrations, background workers) is not subject
+-- to RLS. Sessions without app.role set see nothing.
+
+-- ============================================================================
+-- SECTION 1: Create Scoped Role
+-- ============================================================================
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'ironarchive_tenant_scope') THEN
+        CREATE ROLE ironarchive_tenant_scope NOLOGIN;
+    END IF;
+END
+$$;
+
+-- Allow the application user to SET ROLE to the scoped role
+GRANT ironarchive_tenant_scope TO CURRENT_USER;
+
+GRANT USAGE ON SCHEMA public TO ironarchive_tenant_scope;
+GRANT SELECT, INSERT, UPDATE, DELETE ON tenants, users, mailboxes, emails, attachments, jobs, settings TO ironarchive_tenant_scope;
+GRANT SELECT, INSERT ON audit_logs TO ironarchive_tenant_scope;
+
+-- ============================================================================
+-- SECTION 2: Session Helper Functions
+-- ============================================================================
+
+-- ----------------------------------------------------------------------------
+-- Function: app_current_tenant_id
+-- Description: Tenant of the current request, or NULL when unset
+-- ----------------------------------------------------------------------------
+CREATE OR REPLACE FUNCTION app_current_tenant_id()
+RETURNS UUID AS $$
+    SELECT NULLIF(current_setting('app.tenant_id', true), '')::uuid;
+$$ LANGUAGE sql STABLE;
+
+-- ----------------------------------------------------------------------------
+-- Function: app_is_msp_admin
+-- Description: True when the current request is made by an MSP admin
+-- ----------------------------------------------------------------------------
+CREATE OR REPLACE FUNCTION app_is_msp_admin()
+RETURNS BOOLEAN AS $$
+    SELECT COALESCE(current_setting('app.role', true), '') = 'MSP_ADMIN';
+$$ LANGUAGE sql STABLE;
+
+-- ============================================================================
+-- SECTION 3: Enable Row-Level Security
+-- ============================================================================
+
+ALTER TABLE tenants ENABLE ROW LEVEL SECURITY;
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+ALTER TABLE mailboxes ENABLE ROW LEVEL SECURITY;
+ALTER TABLE emails ENABLE ROW LEVEL SECURITY;
+ALTER TABLE attachments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE jobs ENABLE ROW LEVEL SECURITY;
+ALTER TABLE audit_logs ENABLE ROW LEVEL SECURITY;
+ALTER TABLE settings ENABLE ROW LEVEL SECURITY;
+
+-- ============================================================================
+-- SECTION 4: Create Policies
+-- ============================================================================
+
+CREATE POLICY tenant_isolation ON tenants
+    USING (app_is_msp_admin() OR id = app_current_tenant_id());
+
+-- MSP admins (tenant_id NULL) are only visible to other MSP admins
+CREATE POLICY tenant_isolation ON users
+    USING (app_is_msp_admin() OR tenant_id = app_current_tenant_id());
+
+CREATE POLICY tenant_isolation ON mailboxes
+    USING (app_is_msp_admin() OR tenant_id = app_current_tenant_id());
+
+CREATE POLICY tenant_isolation ON emails
+    USING (
+        app_is_msp_admin() OR mailbox_id IN (
+            SELECT id FROM mailboxes WHERE tenant_id = app_current_tenant_id()
+        )
+    );
+
+CREATE POLICY tenant_isolation ON attachments
+    USING (
+        app_is_msp_admin() OR email_id IN (
+            SELECT e.id FROM emails e
+            JOIN mailboxes m ON m.id = e.mailbox_id
+            WHERE m.tenant_id = app_current_tenant_id()
+        )
+    );
+
+-- Global jobs (tenant_id NULL, e.g. SYNC_ALL) are only visible to MSP admins
+CREATE POLICY tenant_isolation ON jobs
+    USING (app_is_msp_admin() OR tenant_id = app_current_tenant_id());
+
+CREATE POLICY tenant_isolation ON audit_logs
+    USING (
+        app_is_msp_admin() OR user_id IN (
+            SELECT id FROM users WHERE tenant_id = app_current_tenant_id()
+        )
+    )
+    WITH CHECK (
+        app_is_msp_admin() OR user_id IS NULL OR user_id IN (
+            SELECT id FROM users WHERE tenant_id = app_current_tenant_id()
+        )
+    );
+
+-- Settings are global: readable by every scoped session, writable by MSP admins only
+CREATE POLICY settings_read ON settings
+    FOR SELECT
+    USING (app_current_tenant_id() IS NOT NULL OR app_is_msp_admin());
+
+CREATE POLICY settings_write ON settings
+    FOR ALL
+    USING (app_is_msp_admin())
+    WITH CHECK (app_is_msp_admin());
+
+-- ============================================================================
+-- Migration Complete
+-- ============================================================================
@@ -0,0 +1,11 @@
+-- ============================================================================
+-- Migration Rollback: 000003_tenant_data_keys
+-- Description: Drop per-tenant data keys
+-- Created: 2025-10-19
+-- ============================================================================
+
+DROP TABLE IF EXISTS tenant_data_keys;
+
+-- ============================================================================
+-- Rollback Complete
+-- ============================================================================
@@ -0,0 +1,47 @@
+-- ============================================================================
+-- Migration: 000003_tenant_data_keys
+-- Description: Per-tenant data encryption keys for the credential vault
+-- Created: 2025-10-19
+-- ============================================================================
+--
+-- tenants.azure_app_credentials holds ciphertext sealed with a per-tenant data
+-- key. Data keys are stored here wrapped (encrypted) by an application master
+-- key that never touches the database. Credential ciphertexts record the data
+-- key version they were sealed with, so keys can be rotated online.
+
+-- ============================================================================
+-- SECTION 1: Create Tables
+-- ============================================================================
+
+-- ----------------------------------------------------------------------------
+-- Table: tenant_data_keys
+-- Description: Wrapped per-tenant data keys, one row per key version
+-- Dependencies: tenants
+-- ----------------------------------------------------------------------------
+CREATE TABLE tenant_data_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL CHECK (version > 0),
+    master_key_id VARCHAR(64) NOT NULL, -- Identifier of the master key that wrapped this key
+    wrapped_key BYTEA NOT NULL, -- AES-256-GCM nonce || ciphertext of the data key
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(tenant_id, version)
+);
+
+-- ============================================================================
+-- SECTION 2: Create Indexes
+-- ============================================================================
+
+CREATE INDEX idx_tenant_data_keys_master_key_id ON tenant_data_keys(master_key_id);
+
+-- ============================================================================
+-- SECTION 3: Row-Level Security
+-- ============================================================================
+
+-- Only the owner (vault, rotation) may read key material; the request-scoped
+-- role gets no grants and RLS denies everything as a second line of defence
+ALTER TABLE tenant_data_keys ENABLE ROW LEVEL SECURITY;
+
+-- ============================================================================
+-- Migration Complete
+-- ============================================================================
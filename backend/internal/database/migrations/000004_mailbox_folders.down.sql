@@ -0,0 +1,11 @@
+-- ============================================================================
+-- Migration Rollback: 000004_mailbox_folders
+-- Description: Drop per-folder delta sync state
+-- Created: 2025-10-21
+-- ============================================================================
+
+DROP TABLE IF EXISTS mailbox_folders;
+
+-- ============================================================================
+-- Rollback Complete
+-- ============================================================================
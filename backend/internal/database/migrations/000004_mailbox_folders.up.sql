@@ -0,0 +1,49 @@
+-- ============================================================================
+-- Migration: 000004_mailbox_folders
+-- Description: Per-folder Graph delta sync state
+-- Created: 2025-10-21
+-- ============================================================================
+--
+-- Microsoft Graph message delta queries are scoped to a single mail folder, so
+-- a mailbox sync holds one delta link per folder. mailboxes.last_sync_at still
+-- records the last complete sync of the whole mailbox.
+
+-- ============================================================================
+-- SECTION 1: Create Tables
+-- ============================================================================
+
+-- ----------------------------------------------------------------------------
+-- Table: mailbox_folders
+-- Description: Mail folders discovered in a mailbox and their delta links
+-- Dependencies: mailboxes
+-- ----------------------------------------------------------------------------
+CREATE TABLE mailbox_folders (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    mailbox_id UUID NOT NULL REFERENCES mailboxes(id) ON DELETE CASCADE,
+    folder_id TEXT NOT NULL, -- Microsoft Graph mail folder ID
+    display_name VARCHAR(255),
+    delta_link TEXT, -- @odata.deltaLink of the last committed delta round, NULL before the first
+    email_count INTEGER DEFAULT 0,
+    last_sync_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(mailbox_id, folder_id)
+);
+
+-- ============================================================================
+-- SECTION 2: Row-Level Security
+-- ============================================================================
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON mailbox_folders TO ironarchive_tenant_scope;
+
+ALTER TABLE mailbox_folders ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation ON mailbox_folders
+    USING (
+        app_is_msp_admin() OR mailbox_id IN (
+            SELECT id FROM mailboxes WHERE tenant_id = app_current_tenant_id()
+        )
+    );
+
+-- ============================================================================
+-- Migration Complete
+-- ============================================================================
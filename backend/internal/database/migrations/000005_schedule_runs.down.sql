@@ -0,0 +1,11 @@
+-- ============================================================================
+-- Migration Rollback: 000005_schedule_runs
+-- Description: Drop scheduler tick history
+-- Created: 2025-10-24
+-- ============================================================================
+
+DROP TABLE IF EXISTS schedule_runs;
+
+-- ============================================================================
+-- Rollback Complete
+-- ============================================================================
@@ -0,0 +1,47 @@
+-- ============================================================================
+-- Migration: 000005_schedule_runs
+-- Description: Ticks of the sync scheduler, including runs missed during downtime
+-- Created: 2025-10-24
+-- ============================================================================
+--
+-- The scheduler records every tick of settings.sync_schedule it handles. The
+-- unique key makes firing idempotent across replicas, and the latest recorded
+-- tick tells a restarted scheduler which runs it missed.
+
+-- ============================================================================
+-- SECTION 1: Create Tables
+-- ============================================================================
+
+-- ----------------------------------------------------------------------------
+-- Table: schedule_runs
+-- Description: One row per schedule tick and how it was handled
+-- Dependencies: jobs
+-- ----------------------------------------------------------------------------
+CREATE TABLE schedule_runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    schedule_key VARCHAR(100) NOT NULL, -- 'global' for settings.sync_schedule
+    scheduled_at TIMESTAMP NOT NULL, -- tick time in UTC
+    status VARCHAR(20) NOT NULL CHECK (status IN ('FIRED', 'MISSED', 'CAUGHT_UP')),
+    job_id UUID REFERENCES jobs(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(schedule_key, scheduled_at)
+);
+
+CREATE INDEX idx_schedule_runs_key_scheduled ON schedule_runs(schedule_key, scheduled_at DESC);
+
+-- ============================================================================
+-- SECTION 2: Row-Level Security
+-- ============================================================================
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON schedule_runs TO ironarchive_tenant_scope;
+
+ALTER TABLE schedule_runs ENABLE ROW LEVEL SECURITY;
+
+-- The global schedule is managed by MSP admins only
+CREATE POLICY msp_only ON schedule_runs
+    USING (app_is_msp_admin())
+    WITH CHECK (app_is_msp_admin());
+
+-- ============================================================================
+-- Migration Complete
+-- ============================================================================
@@ -0,0 +1,20 @@
+-- ============================================================================
+-- Migration Rollback: 000006_sync_schedule_overrides
+-- Description: Drop tenant and mailbox sync schedule overrides
+-- Created: 2025-10-26
+-- ============================================================================
+
+DROP INDEX IF EXISTS idx_mailboxes_sync_schedule;
+DROP INDEX IF EXISTS idx_tenants_sync_schedule;
+
+ALTER TABLE mailboxes
+    DROP COLUMN IF EXISTS sync_timezone,
+    DROP COLUMN IF EXISTS sync_schedule;
+
+ALTER TABLE tenants
+    DROP COLUMN IF EXISTS sync_timezone,
+    DROP COLUMN IF EXISTS sync_schedule;
+
+-- ============================================================================
+-- Rollback Complete
+-- ============================================================================
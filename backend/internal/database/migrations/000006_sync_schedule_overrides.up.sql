@@ -0,0 +1,38 @@
+-- ============================================================================
+-- Migration: 000006_sync_schedule_overrides
+-- Description: Tenant and mailbox overrides of the global sync schedule
+-- Created: 2025-10-26
+-- ============================================================================
+--
+-- A mailbox syncs on its own schedule if it has one, otherwise on its tenant's,
+-- otherwise on settings.sync_schedule. NULL inherits. An override's time zone
+-- falls back to the next level's zone, then to settings.sync_timezone.
+--
+-- schedule_runs.schedule_key is 'tenant:<id>' or 'mailbox:<id>' for ticks of
+-- an override.
+
+-- ============================================================================
+-- SECTION 1: Alter Tables
+-- ============================================================================
+
+ALTER TABLE tenants
+    ADD COLUMN sync_schedule VARCHAR(100), -- cron expression, NULL inherits the global schedule
+    ADD COLUMN sync_timezone VARCHAR(64), -- IANA zone of sync_schedule
+    ADD CONSTRAINT tenants_sync_timezone_needs_schedule CHECK (sync_timezone IS NULL OR sync_schedule IS NOT NULL);
+
+ALTER TABLE mailboxes
+    ADD COLUMN sync_schedule VARCHAR(100), -- cron expression, NULL inherits the tenant or global schedule
+    ADD COLUMN sync_timezone VARCHAR(64), -- IANA zone of sync_schedule
+    ADD CONSTRAINT mailboxes_sync_timezone_needs_schedule CHECK (sync_timezone IS NULL OR sync_schedule IS NOT NULL);
+
+-- ============================================================================
+-- SECTION 2: Create Indexes
+-- ============================================================================
+
+-- The scheduler lists overrides on every reload
+CREATE INDEX idx_tenants_sync_schedule ON tenants(id) WHERE sync_schedule IS NOT NULL;
+CREATE INDEX idx_mailboxes_sync_schedule ON mailboxes(tenant_id) WHERE sync_schedule IS NOT NULL;
+
+-- ============================================================================
+-- Migration Complete
+-- ============================================================================
@@ -0,0 +1,29 @@
+-- ============================================================================
+-- Migration Rollback: 000007_job_attempts
+-- Description: Drop job retries, cancellation and attempt history
+-- Created: 2025-10-28
+-- ============================================================================
+
+DROP TABLE IF EXISTS job_attempts;
+
+DROP INDEX IF EXISTS idx_jobs_failed;
+DROP INDEX IF EXISTS idx_jobs_retry_of;
+
+-- Jobs in the new states end as they would have before
+UPDATE jobs SET status = 'FAILED' WHERE status IN ('RETRYING', 'CANCELLED');
+
+ALTER TABLE jobs
+    DROP COLUMN IF EXISTS result,
+    DROP COLUMN IF EXISTS retry_of,
+    DROP COLUMN IF EXISTS cancel_requested_at,
+    DROP COLUMN IF EXISTS next_attempt_at,
+    DROP COLUMN IF EXISTS max_attempts,
+    DROP COLUMN IF EXISTS attempts;
+
+ALTER TABLE jobs DROP CONSTRAINT jobs_status_check;
+ALTER TABLE jobs ADD CONSTRAINT jobs_status_check
+    CHECK (status IN ('QUEUED', 'RUNNING', 'COMPLETED', 'FAILED'));
+
+-- ============================================================================
+-- Rollback Complete
+-- ============================================================================
@@ -0,0 +1,77 @@
+-- ============================================================================
+-- Migration: 000007_job_attempts
+-- Description: Job retries, cancellation and per-attempt history
+-- Created: 2025-10-28
+-- ============================================================================
+--
+-- A failed job is RETRYING until next_attempt_at while it has failed fewer
+-- than max_attempts times; after that it stays FAILED, which is the
+-- dead-letter state. Cancelling a running job sets cancel_requested_at and the
+-- worker stops cooperatively; queued and retrying jobs are CANCELLED at once.
+-- Jobs created by the retry API reference the job they clone via retry_of.
+-- metadata holds a job's input and result its outcome, so a clone reruns the
+-- original request.
+
+-- ============================================================================
+-- SECTION 1: Alter Tables
+-- ============================================================================
+
+ALTER TABLE jobs DROP CONSTRAINT jobs_status_check;
+ALTER TABLE jobs ADD CONSTRAINT jobs_status_check
+    CHECK (status IN ('QUEUED', 'RUNNING', 'RETRYING', 'COMPLETED', 'FAILED', 'CANCELLED'));
+
+ALTER TABLE jobs
+    ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0, -- attempts started, including interrupted ones
+    ADD COLUMN max_attempts INTEGER NOT NULL DEFAULT 1 CHECK (max_attempts > 0), -- failed attempts before the job is dead-lettered
+    ADD COLUMN next_attempt_at TIMESTAMP, -- when a RETRYING job is queued again
+    ADD COLUMN cancel_requested_at TIMESTAMP,
+    ADD COLUMN retry_of UUID REFERENCES jobs(id) ON DELETE SET NULL,
+    ADD COLUMN result JSONB;
+
+-- ============================================================================
+-- SECTION 2: Create Tables
+-- ============================================================================
+
+-- ----------------------------------------------------------------------------
+-- Table: job_attempts
+-- Description: One row per run of a job by a worker
+-- Dependencies: jobs
+-- ----------------------------------------------------------------------------
+CREATE TABLE job_attempts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    job_id UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL CHECK (attempt > 0),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('RUNNING', 'COMPLETED', 'FAILED', 'CANCELLED', 'INTERRUPTED')),
+    worker VARCHAR(255), -- queue consumer that ran the attempt
+    error_message TEXT,
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP,
+    UNIQUE(job_id, attempt)
+);
+
+-- ============================================================================
+-- SECTION 3: Create Indexes
+-- ============================================================================
+
+CREATE INDEX idx_jobs_retry_of ON jobs(retry_of);
+-- Dead-letter listing
+CREATE INDEX idx_jobs_failed ON jobs(created_at DESC) WHERE status = 'FAILED';
+
+-- ============================================================================
+-- SECTION 4: Row-Level Security
+-- ============================================================================
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON job_attempts TO ironarchive_tenant_scope;
+
+ALTER TABLE job_attempts ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation ON job_attempts
+    USING (
+        app_is_msp_admin() OR job_id IN (
+            SELECT id FROM jobs WHERE tenant_id = app_current_tenant_id()
+        )
+    );
+
+-- ============================================================================
+-- Migration Complete
+-- ============================================================================
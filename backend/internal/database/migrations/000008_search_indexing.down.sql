@@ -0,0 +1,21 @@
+-- ============================================================================
+-- Migration Rollback: 000008_search_indexing
+-- Description: Drop search index bookkeeping for changed and deleted emails
+-- Created: 2025-11-04
+-- ============================================================================
+
+DROP TRIGGER IF EXISTS queue_emails_search_deletion ON emails;
+DROP FUNCTION IF EXISTS queue_search_deletion();
+
+DROP TRIGGER IF EXISTS mark_emails_changed ON emails;
+DROP FUNCTION IF EXISTS mark_email_changed();
+
+DROP INDEX IF EXISTS idx_emails_unindexed;
+
+DROP TABLE IF EXISTS search_deletions;
+
+ALTER TABLE emails DROP COLUMN IF EXISTS updated_at;
+
+-- ============================================================================
+-- Rollback Complete
+-- ============================================================================
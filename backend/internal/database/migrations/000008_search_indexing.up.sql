@@ -0,0 +1,95 @@
+-- ============================================================================
+-- Migration: 000008_search_indexing
+-- Description: Search index bookkeeping for changed and deleted emails
+-- Created: 2025-11-04
+-- ============================================================================
+--
+-- indexed_at is NULL while the search document of an email is missing or
+-- stale. Changing any indexed column (including soft deletion) clears it and
+-- bumps updated_at, so the indexer picks the email up again; the indexer only
+-- marks an email indexed if updated_at still matches what it pushed. Hard
+-- deleted emails are queued in search_deletions until their documents are
+-- removed.
+
+-- ============================================================================
+-- SECTION 1: Alter Tables
+-- ============================================================================
+
+ALTER TABLE emails ADD COLUMN updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+-- ============================================================================
+-- SECTION 2: Create Tables
+-- ============================================================================
+
+-- ----------------------------------------------------------------------------
+-- Table: search_deletions
+-- Description: Emails deleted from PostgreSQL whose search documents still
+--              need to be removed
+-- Dependencies: none (emails are gone by the time rows are written)
+-- ----------------------------------------------------------------------------
+CREATE TABLE search_deletions (
+    email_id UUID PRIMARY KEY,
+    deleted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- ============================================================================
+-- SECTION 3: Create Indexes
+-- ============================================================================
+
+-- Pending work of the indexer, oldest change first
+CREATE INDEX idx_emails_unindexed ON emails(updated_at, id) WHERE indexed_at IS NULL;
+
+-- ============================================================================
+-- SECTION 4: Functions and Triggers
+-- ============================================================================
+
+-- ----------------------------------------------------------------------------
+-- Function: mark_email_changed
+-- Description: Flags an email for re-indexing when an indexed column changes
+-- ----------------------------------------------------------------------------
+CREATE OR REPLACE FUNCTION mark_email_changed()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF (NEW.mailbox_id, NEW.message_id, NEW.subject, NEW.sender, NEW.recipients, NEW.sent_at,
+        NEW.body_text, NEW.has_attachments, NEW.size_bytes, NEW.deleted_at)
+       IS DISTINCT FROM
+       (OLD.mailbox_id, OLD.message_id, OLD.subject, OLD.sender, OLD.recipients, OLD.sent_at,
+        OLD.body_text, OLD.has_attachments, OLD.size_bytes, OLD.deleted_at) THEN
+        NEW.updated_at = CURRENT_TIMESTAMP;
+        NEW.indexed_at = NULL;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER mark_emails_changed
+BEFORE UPDATE ON emails
+FOR EACH ROW EXECUTE FUNCTION mark_email_changed();
+
+-- ----------------------------------------------------------------------------
+-- Function: queue_search_deletion
+-- Description: Queues the search document of a deleted email for removal
+-- ----------------------------------------------------------------------------
+CREATE OR REPLACE FUNCTION queue_search_deletion()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO search_deletions (email_id) VALUES (OLD.id) ON CONFLICT DO NOTHING;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER queue_emails_search_deletion
+AFTER DELETE ON emails
+FOR EACH ROW EXECUTE FUNCTION queue_search_deletion();
+
+-- ============================================================================
+-- SECTION 5: Row-Level Security
+-- ============================================================================
+
+-- Tenant sessions may delete emails, which fires the trigger; only the
+-- indexer reads the queue
+GRANT INSERT ON search_deletions TO ironarchive_tenant_scope;
+
+-- ============================================================================
+-- Migration Complete
+-- ============================================================================
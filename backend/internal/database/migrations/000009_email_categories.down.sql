@@ -0,0 +1,26 @@
+-- ============================================================================
+-- Migration Rollback: 000009_email_categories
+-- Description: Drop the Outlook categories of archived emails
+-- Created: 2025-11-18
+-- ============================================================================
+
+CREATE OR REPLACE FUNCTION mark_email_changed()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF (NEW.mailbox_id, NEW.message_id, NEW.subject, NEW.sender, NEW.recipients, NEW.sent_at,
+        NEW.body_text, NEW.has_attachments, NEW.size_bytes, NEW.deleted_at)
+       IS DISTINCT FROM
+       (OLD.mailbox_id, OLD.message_id, OLD.subject, OLD.sender, OLD.recipients, OLD.sent_at,
+        OLD.body_text, OLD.has_attachments, OLD.size_bytes, OLD.deleted_at) THEN
+        NEW.updated_at = CURRENT_TIMESTAMP;
+        NEW.indexed_at = NULL;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE emails DROP COLUMN IF EXISTS categories;
+
+-- ============================================================================
+-- Rollback Complete
+-- ============================================================================
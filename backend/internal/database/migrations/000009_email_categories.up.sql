@@ -0,0 +1,41 @@
+-- ============================================================================
+-- Migration: 000009_email_categories
+-- Description: Outlook categories of archived emails, searched as labels
+-- Created: 2025-11-18
+-- ============================================================================
+--
+-- Categories are copied from Microsoft Graph when an email is archived. They
+-- are indexed, so mark_email_changed now flags emails whose categories change.
+
+-- ============================================================================
+-- SECTION 1: Alter Tables
+-- ============================================================================
+
+ALTER TABLE emails ADD COLUMN categories TEXT[] NOT NULL DEFAULT '{}';
+
+-- ============================================================================
+-- SECTION 2: Functions and Triggers
+-- ============================================================================
+
+-- ----------------------------------------------------------------------------
+-- Function: mark_email_changed
+-- Description: Flags an email for re-indexing when an indexed column changes
+-- ----------------------------------------------------------------------------
+CREATE OR REPLACE FUNCTION mark_email_changed()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF (NEW.mailbox_id, NEW.message_id, NEW.subject, NEW.sender, NEW.recipients, NEW.sent_at,
+        NEW.body_text, NEW.has_attachments, NEW.size_bytes, NEW.categories, NEW.deleted_at)
+       IS DISTINCT FROM
+       (OLD.mailbox_id, OLD.message_id, OLD.subject, OLD.sender, OLD.recipients, OLD.sent_at,
+        OLD.body_text, OLD.has_attachments, OLD.size_bytes, OLD.categories, OLD.deleted_at) THEN
+        NEW.updated_at = CURRENT_TIMESTAMP;
+        NEW.indexed_at = NULL;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- ============================================================================
+-- Migration Complete
+-- ============================================================================
@@ -0,0 +1,13 @@
+-- ============================================================================
+-- Migration Rollback: 000010_email_full_text
+-- Description: Drop PostgreSQL full-text search over emails
+-- Created: 2025-11-21
+-- ============================================================================
+
+DROP INDEX IF EXISTS idx_emails_search_vector;
+
+ALTER TABLE emails DROP COLUMN IF EXISTS search_vector;
+
+-- ============================================================================
+-- Rollback Complete
+-- ============================================================================
@@ -0,0 +1,31 @@
+-- ============================================================================
+-- Migration: 000010_email_full_text
+-- Description: PostgreSQL full-text search over emails, the fallback while
+--              Meilisearch is unavailable
+-- Created: 2025-11-21
+-- ============================================================================
+--
+-- search_vector is generated from subject (weight A), sender (B) and the
+-- first 256 KiB of body_text (C) with the 'simple' configuration, which
+-- lower-cases without language-specific stemming since the archive holds
+-- mail in many languages. Adding the stored column rewrites the table.
+
+-- ============================================================================
+-- SECTION 1: Alter Tables
+-- ============================================================================
+
+ALTER TABLE emails ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', COALESCE(subject, '')), 'A') ||
+    setweight(to_tsvector('simple', COALESCE(sender, '')), 'B') ||
+    setweight(to_tsvector('simple', LEFT(COALESCE(body_text, ''), 262144)), 'C')
+) STORED;
+
+-- ============================================================================
+-- SECTION 2: Create Indexes
+-- ============================================================================
+
+CREATE INDEX idx_emails_search_vector ON emails USING GIN (search_vector);
+
+-- ============================================================================
+-- Migration Complete
+-- ============================================================================
@@ -0,0 +1,23 @@
+-- ============================================================================
+-- Migration Rollback: 000011_attachment_text
+-- Description: Drop text extracted from attachments
+-- Created: 2025-11-25
+-- ============================================================================
+
+DROP TRIGGER IF EXISTS mark_attachments_text_changed ON attachments;
+DROP FUNCTION IF EXISTS mark_attachment_text_changed();
+
+DROP INDEX IF EXISTS idx_attachments_search_vector;
+DROP INDEX IF EXISTS idx_attachments_text_pending;
+
+ALTER TABLE attachments
+    DROP COLUMN IF EXISTS search_vector,
+    DROP COLUMN IF EXISTS text_claimed_until,
+    DROP COLUMN IF EXISTS text_extracted_at,
+    DROP COLUMN IF EXISTS text_error,
+    DROP COLUMN IF EXISTS extracted_text,
+    DROP COLUMN IF EXISTS text_status;
+
+-- ============================================================================
+-- Rollback Complete
+-- ============================================================================
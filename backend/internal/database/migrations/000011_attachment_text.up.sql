@@ -0,0 +1,67 @@
+-- ============================================================================
+-- Migration: 000011_attachment_text
+-- Description: Text extracted from attachments for search and discovery
+-- Created: 2025-11-25
+-- ============================================================================
+--
+-- Attachments start PENDING and the extraction worker records the outcome:
+-- EXTRACTED with the text, UNSUPPORTED for formats without a parser,
+-- TOO_LARGE above the size limits, or FAILED with the error (corrupt or
+-- encrypted documents, timeouts). Workers claim pending rows by setting
+-- text_claimed_until; a claim that lapses is picked up again. Recording text
+-- flags the parent email for re-indexing, so its search document gains the
+-- attachment text. Existing attachments become PENDING and are backfilled.
+--
+-- search_vector covers the first 256 KiB of the text, like emails.
+
+-- ============================================================================
+-- SECTION 1: Alter Tables
+-- ============================================================================
+
+ALTER TABLE attachments
+    ADD COLUMN text_status VARCHAR(20) NOT NULL DEFAULT 'PENDING'
+        CHECK (text_status IN ('PENDING', 'EXTRACTED', 'UNSUPPORTED', 'TOO_LARGE', 'FAILED')),
+    ADD COLUMN extracted_text TEXT,
+    ADD COLUMN text_error TEXT,
+    ADD COLUMN text_extracted_at TIMESTAMP,
+    ADD COLUMN text_claimed_until TIMESTAMP;
+
+ALTER TABLE attachments ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    to_tsvector('simple', LEFT(COALESCE(extracted_text, ''), 262144))
+) STORED;
+
+-- ============================================================================
+-- SECTION 2: Create Indexes
+-- ============================================================================
+
+-- Pending work of the extraction worker, oldest first
+CREATE INDEX idx_attachments_text_pending ON attachments(created_at, id) WHERE text_status = 'PENDING';
+
+CREATE INDEX idx_attachments_search_vector ON attachments USING GIN (search_vector);
+
+-- ============================================================================
+-- SECTION 3: Functions and Triggers
+-- ============================================================================
+
+-- ----------------------------------------------------------------------------
+-- Function: mark_attachment_text_changed
+-- Description: Flags the parent email for re-indexing when the text of an
+--              attachment changes
+-- ----------------------------------------------------------------------------
+CREATE OR REPLACE FUNCTION mark_attachment_text_changed()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE emails SET updated_at = CURRENT_TIMESTAMP, indexed_at = NULL WHERE id = NEW.email_id;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER mark_attachments_text_changed
+AFTER UPDATE OF extracted_text ON attachments
+FOR EACH ROW
+WHEN (OLD.extracted_text IS DISTINCT FROM NEW.extracted_text)
+EXECUTE FUNCTION mark_attachment_text_changed();
+
+-- ============================================================================
+-- Migration Complete
+-- ============================================================================
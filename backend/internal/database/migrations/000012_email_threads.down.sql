@@ -0,0 +1,38 @@
+-- ============================================================================
+-- Migration Rollback: 000012_email_threads
+-- Description: Drop conversation threads of archived emails
+-- Created: 2025-11-27
+-- ============================================================================
+
+CREATE OR REPLACE FUNCTION mark_email_changed()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF (NEW.mailbox_id, NEW.message_id, NEW.subject, NEW.sender, NEW.recipients, NEW.sent_at,
+        NEW.body_text, NEW.has_attachments, NEW.size_bytes, NEW.categories, NEW.deleted_at)
+       IS DISTINCT FROM
+       (OLD.mailbox_id, OLD.message_id, OLD.subject, OLD.sender, OLD.recipients, OLD.sent_at,
+        OLD.body_text, OLD.has_attachments, OLD.size_bytes, OLD.categories, OLD.deleted_at) THEN
+        NEW.updated_at = CURRENT_TIMESTAMP;
+        NEW.indexed_at = NULL;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_emails_unthreaded;
+DROP INDEX IF EXISTS idx_emails_internet_message_id;
+DROP INDEX IF EXISTS idx_emails_thread_id;
+
+ALTER TABLE emails
+    DROP COLUMN IF EXISTS thread_id,
+    DROP COLUMN IF EXISTS conversation_id,
+    DROP COLUMN IF EXISTS message_references,
+    DROP COLUMN IF EXISTS in_reply_to,
+    DROP COLUMN IF EXISTS internet_message_id;
+
+DROP TABLE IF EXISTS thread_keys;
+DROP TABLE IF EXISTS threads;
+
+-- ============================================================================
+-- Rollback Complete
+-- ============================================================================
@@ -0,0 +1,114 @@
+-- ============================================================================
+-- Migration: 000012_email_threads
+-- Description: Conversation threads of archived emails
+-- Created: 2025-11-27
+-- ============================================================================
+--
+-- Emails are threaded per tenant from their Message-ID, In-Reply-To and
+-- References headers and the Microsoft Graph conversationId. Every value is a
+-- key in thread_keys; emails sharing a key belong to the same thread, so two
+-- threads merge when an email links them. The copies of a message archived
+-- in several mailboxes share its Message-ID and therefore its thread.
+--
+-- Threads record the subject of their earliest email, the number of distinct
+-- messages and the time span; they are refreshed whenever an email joins.
+-- thread_id is indexed, so mark_email_changed now flags emails whose thread
+-- changes. Existing emails have no thread until the backfill reaches them.
+
+-- ============================================================================
+-- SECTION 1: Create Tables
+-- ============================================================================
+
+-- ----------------------------------------------------------------------------
+-- Table: threads
+-- Description: Conversations of a tenant, across its mailboxes
+-- Dependencies: tenants
+-- ----------------------------------------------------------------------------
+CREATE TABLE threads (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    subject TEXT, -- Subject of the earliest email
+    message_count INTEGER NOT NULL DEFAULT 0, -- Distinct messages; copies in several mailboxes count once
+    first_sent_at TIMESTAMP,
+    last_sent_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- ----------------------------------------------------------------------------
+-- Table: thread_keys
+-- Description: Message-IDs ('mid:<id>') and Graph conversation IDs
+--              ('conv:<id>') seen in a thread
+-- Dependencies: tenants, threads
+-- ----------------------------------------------------------------------------
+CREATE TABLE thread_keys (
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    thread_id UUID NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+    PRIMARY KEY (tenant_id, key)
+);
+
+-- ============================================================================
+-- SECTION 2: Alter Tables
+-- ============================================================================
+
+ALTER TABLE emails
+    ADD COLUMN internet_message_id TEXT, -- Message-ID without angle brackets
+    ADD COLUMN in_reply_to TEXT,
+    ADD COLUMN message_references TEXT[] NOT NULL DEFAULT '{}', -- References, oldest first
+    ADD COLUMN conversation_id TEXT, -- Microsoft Graph conversationId
+    ADD COLUMN thread_id UUID REFERENCES threads(id) ON DELETE SET NULL;
+
+-- ============================================================================
+-- SECTION 3: Create Indexes
+-- ============================================================================
+
+CREATE INDEX idx_threads_tenant_id ON threads(tenant_id, last_sent_at DESC);
+CREATE INDEX idx_thread_keys_thread_id ON thread_keys(thread_id);
+CREATE INDEX idx_emails_thread_id ON emails(thread_id, sent_at);
+CREATE INDEX idx_emails_internet_message_id ON emails(internet_message_id);
+
+-- Pending work of the thread backfill
+CREATE INDEX idx_emails_unthreaded ON emails(created_at, id) WHERE thread_id IS NULL;
+
+-- ============================================================================
+-- SECTION 4: Functions and Triggers
+-- ============================================================================
+
+-- ----------------------------------------------------------------------------
+-- Function: mark_email_changed
+-- Description: Flags an email for re-indexing when an indexed column changes
+-- ----------------------------------------------------------------------------
+CREATE OR REPLACE FUNCTION mark_email_changed()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF (NEW.mailbox_id, NEW.message_id, NEW.subject, NEW.sender, NEW.recipients, NEW.sent_at,
+        NEW.body_text, NEW.has_attachments, NEW.size_bytes, NEW.categories, NEW.thread_id, NEW.deleted_at)
+       IS DISTINCT FROM
+       (OLD.mailbox_id, OLD.message_id, OLD.subject, OLD.sender, OLD.recipients, OLD.sent_at,
+        OLD.body_text, OLD.has_attachments, OLD.size_bytes, OLD.categories, OLD.thread_id, OLD.deleted_at) THEN
+        NEW.updated_at = CURRENT_TIMESTAMP;
+        NEW.indexed_at = NULL;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- ============================================================================
+-- SECTION 5: Row-Level Security
+-- ============================================================================
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON threads, thread_keys TO ironarchive_tenant_scope;
+
+ALTER TABLE threads ENABLE ROW LEVEL SECURITY;
+ALTER TABLE thread_keys ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation ON threads
+    USING (app_is_msp_admin() OR tenant_id = app_current_tenant_id());
+
+CREATE POLICY tenant_isolation ON thread_keys
+    USING (app_is_msp_admin() OR tenant_id = app_current_tenant_id());
+
+-- ============================================================================
+-- Migration Complete
+-- ============================================================================
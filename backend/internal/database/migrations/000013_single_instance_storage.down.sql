@@ -0,0 +1,29 @@
+-- ============================================================================
+-- Migration Rollback: 000013_single_instance_storage
+-- Description: Drop single-instance storage of archived messages
+-- Created: 2025-11-29
+-- ============================================================================
+--
+-- Restoring the global message_id constraint fails while two mailboxes hold
+-- the same Graph message ID.
+
+ALTER TABLE tenants DROP COLUMN IF EXISTS logical_storage_bytes;
+ALTER TABLE mailboxes DROP COLUMN IF EXISTS logical_storage_bytes;
+
+DROP INDEX IF EXISTS idx_emails_folder_id;
+DROP INDEX IF EXISTS idx_emails_blob_id;
+
+ALTER TABLE emails
+    DROP COLUMN IF EXISTS flag_status,
+    DROP COLUMN IF EXISTS is_read,
+    DROP COLUMN IF EXISTS folder_id,
+    DROP COLUMN IF EXISTS blob_id;
+
+ALTER TABLE emails DROP CONSTRAINT IF EXISTS emails_mailbox_message_id_key;
+ALTER TABLE emails ADD CONSTRAINT emails_message_id_key UNIQUE (message_id);
+
+DROP TABLE IF EXISTS message_blobs;
+
+-- ============================================================================
+-- Rollback Complete
+-- ============================================================================
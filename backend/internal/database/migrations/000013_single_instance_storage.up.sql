@@ -0,0 +1,92 @@
+-- ============================================================================
+-- Migration: 000013_single_instance_storage
+-- Description: Single-instance storage of archived messages
+-- Created: 2025-11-29
+-- ============================================================================
+--
+-- A message delivered to several mailboxes of a tenant is stored once. Its
+-- MIME blob is content-addressed by SHA-256 and recorded in message_blobs;
+-- each mailbox keeps its own emails row referencing the blob and carrying the
+-- folder, read state and flag of its copy. Graph message IDs are unique per
+-- mailbox only, so emails.message_id is no longer globally unique.
+--
+-- storage_bytes of tenants and mailboxes remains the physical usage: a blob
+-- counts once, towards the mailbox that stored it first. logical_storage_bytes
+-- counts every copy, as if nothing were deduplicated. Emails archived before
+-- this migration keep their per-mailbox MIME and have no blob.
+
+-- ============================================================================
+-- SECTION 1: Create Tables
+-- ============================================================================
+
+-- ----------------------------------------------------------------------------
+-- Table: message_blobs
+-- Description: Stored MIME of archived messages, one per tenant and content hash
+-- Dependencies: tenants
+-- ----------------------------------------------------------------------------
+CREATE TABLE message_blobs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    sha256_hash VARCHAR(64) NOT NULL,
+    file_path TEXT NOT NULL, -- Storage URI of the first copy; later copies reuse it
+    size_bytes BIGINT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(tenant_id, sha256_hash)
+);
+
+-- ============================================================================
+-- SECTION 2: Alter Tables
+-- ============================================================================
+
+ALTER TABLE emails DROP CONSTRAINT emails_message_id_key;
+ALTER TABLE emails ADD CONSTRAINT emails_mailbox_message_id_key UNIQUE (mailbox_id, message_id);
+
+-- A blob cannot be deleted while an email references it (NO ACTION, so
+-- deleting a tenant still cascades to both)
+ALTER TABLE emails
+    ADD COLUMN blob_id UUID REFERENCES message_blobs(id),
+    ADD COLUMN folder_id UUID REFERENCES mailbox_folders(id) ON DELETE SET NULL, -- Folder the copy was last seen in
+    ADD COLUMN is_read BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN flag_status VARCHAR(20) NOT NULL DEFAULT 'notFlagged'
+        CHECK (flag_status IN ('notFlagged', 'flagged', 'complete'));
+
+ALTER TABLE mailboxes ADD COLUMN logical_storage_bytes BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE tenants ADD COLUMN logical_storage_bytes BIGINT NOT NULL DEFAULT 0;
+
+-- ============================================================================
+-- SECTION 3: Create Indexes
+-- ============================================================================
+
+CREATE INDEX idx_emails_blob_id ON emails(blob_id);
+CREATE INDEX idx_emails_folder_id ON emails(folder_id);
+
+-- ============================================================================
+-- SECTION 4: Backfill
+-- ============================================================================
+
+-- Nothing was deduplicated across mailboxes yet, so the logical usage is the
+-- size of every archived message and attachment
+UPDATE mailboxes SET logical_storage_bytes = COALESCE((
+    SELECT SUM(e.size_bytes) FROM emails e WHERE e.mailbox_id = mailboxes.id
+), 0) + COALESCE((
+    SELECT SUM(a.size_bytes) FROM attachments a JOIN emails e ON e.id = a.email_id WHERE e.mailbox_id = mailboxes.id
+), 0);
+
+UPDATE tenants SET logical_storage_bytes = COALESCE((
+    SELECT SUM(m.logical_storage_bytes) FROM mailboxes m WHERE m.tenant_id = tenants.id
+), 0);
+
+-- ============================================================================
+-- SECTION 5: Row-Level Security
+-- ============================================================================
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON message_blobs TO ironarchive_tenant_scope;
+
+ALTER TABLE message_blobs ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation ON message_blobs
+    USING (app_is_msp_admin() OR tenant_id = app_current_tenant_id());
+
+-- ============================================================================
+-- Migration Complete
+-- ============================================================================
@@ -0,0 +1,214 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ironarchive/internal/models"
+)
+
+// AttachmentRepository provides access to the attachments table
+type AttachmentRepository interface {
+	Create(ctx context.Context, attachment *models.Attachment) error
+	GetByID(ctx context.Context, id string) (*models.Attachment, error)
+	ListByEmail(ctx context.Context, emailID string) ([]models.Attachment, error)
+	ListByEmails(ctx context.Context, emailIDs []string) ([]models.Attachment, error)
+	FindByHash(ctx context.Context, sha256Hash string) (*models.Attachment, error)
+	ReserveHash(ctx context.Context, tenantID, sha256Hash string) (bool, error)
+	ClaimPendingText(ctx context.Context, limit int, now, until time.Time) ([]models.Attachment, error)
+	FindExtractedByHash(ctx context.Context, sha256Hash string) (*models.Attachment, error)
+	SaveText(ctx context.Context, sha256Hash string, text models.AttachmentText, extractedAt time.Time) (int64, error)
+	Delete(ctx context.Context, id string) error
+}
+
+const attachmentColumns = `id, email_id, filename, content_type, size_bytes, sha256_hash, file_path,
+	text_status, extracted_text, text_error, text_extracted_at, COALESCE(created_at, CURRENT_TIMESTAMP)`
+
+type attachmentRepository struct {
+	db DBTX
+}
+
+// NewAttachmentRepository creates an attachment repository
+func NewAttachmentRepository(db DBTX) AttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+func scanAttachment(row rowScanner) (models.Attachment, error) {
+	var a models.Attachment
+	err := row.Scan(
+		&a.ID,
+		&a.EmailID,
+		&a.Filename,
+		&a.ContentType,
+		&a.SizeBytes,
+		&a.SHA256Hash,
+		&a.FilePath,
+		&a.TextStatus,
+		&a.ExtractedText,
+		&a.TextError,
+		&a.TextExtractedAt,
+		&a.CreatedAt,
+	)
+	return a, mapError(err)
+}
+
+// Create inserts an attachment and populates its generated fields
+func (r *attachmentRepository) Create(ctx context.Context, attachment *models.Attachment) error {
+	query := `
+		INSERT INTO attachments (email_id, filename, content_type, size_bytes, sha256_hash, file_path)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, text_status, created_at
+	`
+	err := r.db.QueryRow(ctx, query,
+		attachment.EmailID,
+		attachment.Filename,
+		attachment.ContentType,
+		attachment.SizeBytes,
+		attachment.SHA256Hash,
+		attachment.FilePath,
+	).Scan(&attachment.ID, &attachment.TextStatus, &attachment.CreatedAt)
+	return mapError(err)
+}
+
+// GetByID returns an attachment by primary key
+func (r *attachmentRepository) GetByID(ctx context.Context, id string) (*models.Attachment, error) {
+	a, err := scanAttachment(r.db.QueryRow(ctx, "SELECT "+attachmentColumns+" FROM attachments WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ListByEmail returns all attachments of an email in insertion order
+func (r *attachmentRepository) ListByEmail(ctx context.Context, emailID string) ([]models.Attachment, error) {
+	rows, err := r.db.Query(ctx, "SELECT "+attachmentColumns+" FROM attachments WHERE email_id = $1 ORDER BY created_at, id", emailID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, mapError(rows.Err())
+}
+
+// ListByEmails returns the attachments of several emails, grouped by email
+func (r *attachmentRepository) ListByEmails(ctx context.Context, emailIDs []string) ([]models.Attachment, error) {
+	if len(emailIDs) == 0 {
+		return []models.Attachment{}, nil
+	}
+	rows, err := r.db.Query(ctx, "SELECT "+attachmentColumns+" FROM attachments WHERE email_id = ANY($1::uuid[]) ORDER BY email_id, created_at, id", emailIDs)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, mapError(rows.Err())
+}
+
+// FindByHash returns any attachment with the given content hash, used to reuse stored blobs
+func (r *attachmentRepository) FindByHash(ctx context.Context, sha256Hash string) (*models.Attachment, error) {
+	a, err := scanAttachment(r.db.QueryRow(ctx, "SELECT "+attachmentColumns+" FROM attachments WHERE sha256_hash = $1 ORDER BY created_at LIMIT 1", sha256Hash))
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ReserveHash reports whether a tenant already has an attachment with the
+// content hash, and holds the hash until the transaction ends. ReserveHash
+// must run in a transaction: others reserving the same hash wait for it, so
+// exactly one of them sees the content as new.
+func (r *attachmentRepository) ReserveHash(ctx context.Context, tenantID, sha256Hash string) (bool, error) {
+	if _, err := r.db.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('attachments:' || $1 || ':' || $2))", tenantID, sha256Hash); err != nil {
+		return false, fmt.Errorf("failed to lock attachment hash: %w", mapError(err))
+	}
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM attachments a
+			JOIN emails e ON e.id = a.email_id
+			JOIN mailboxes m ON m.id = e.mailbox_id
+			WHERE m.tenant_id = $1 AND a.sha256_hash = $2
+		)
+	`
+	var stored bool
+	err := r.db.QueryRow(ctx, query, tenantID, sha256Hash).Scan(&stored)
+	return stored, mapError(err)
+}
+
+// ClaimPendingText claims up to limit attachments awaiting text extraction
+// until the given time, oldest first. Claims of other workers are skipped
+// until they lapse.
+func (r *attachmentRepository) ClaimPendingText(ctx context.Context, limit int, now, until time.Time) ([]models.Attachment, error) {
+	query := `
+		UPDATE attachments SET text_claimed_until = $3
+		WHERE id IN (
+			SELECT id FROM attachments
+			WHERE text_status = 'PENDING' AND (text_claimed_until IS NULL OR text_claimed_until < $2)
+			ORDER BY created_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + attachmentColumns
+	rows, err := r.db.Query(ctx, query, limit, now, until)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, mapError(rows.Err())
+}
+
+// FindExtractedByHash returns an attachment with the given content hash
+// whose extraction has finished, so identical content is extracted once
+func (r *attachmentRepository) FindExtractedByHash(ctx context.Context, sha256Hash string) (*models.Attachment, error) {
+	query := "SELECT " + attachmentColumns + " FROM attachments WHERE sha256_hash = $1 AND text_status <> 'PENDING' ORDER BY text_extracted_at LIMIT 1"
+	a, err := scanAttachment(r.db.QueryRow(ctx, query, sha256Hash))
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// SaveText records the extraction outcome for every pending attachment with
+// the given content hash and returns how many were updated
+func (r *attachmentRepository) SaveText(ctx context.Context, sha256Hash string, text models.AttachmentText, extractedAt time.Time) (int64, error) {
+	query := `
+		UPDATE attachments
+		SET text_status = $2, extracted_text = $3, text_error = $4, text_extracted_at = $5, text_claimed_until = NULL
+		WHERE sha256_hash = $1 AND text_status = 'PENDING'
+	`
+	tag, err := r.db.Exec(ctx, query, sha256Hash, text.Status, text.Text, text.Error, extractedAt)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Delete removes an attachment row
+func (r *attachmentRepository) Delete(ctx context.Context, id string) error {
+	return affectOne(r.db.Exec(ctx, "DELETE FROM attachments WHERE id = $1", id))
+}
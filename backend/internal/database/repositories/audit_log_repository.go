@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"ironarchive/internal/models"
+)
+
+// AuditLogFilter narrows audit log listings
+type AuditLogFilter struct {
+	UserID *string
+	Action string
+	From   *time.Time
+	To     *time.Time
+}
+
+// AuditLogRepository provides append-only access to the audit_logs table.
+// There is deliberately no Update or Delete: the table is immutable and a
+// trigger rejects modifications.
+type AuditLogRepository interface {
+	Create(ctx context.Context, entry *models.AuditLog) error
+	List(ctx context.Context, filter AuditLogFilter, page Pagination) (Page[models.AuditLog], error)
+}
+
+const auditLogColumns = `id, user_id, action, host(ip_address), details, timestamp`
+
+type auditLogRepository struct {
+	db DBTX
+}
+
+// NewAuditLogRepository creates an audit log repository
+func NewAuditLogRepository(db DBTX) AuditLogRepository {
+	return &auditLogRepository{db: db}
+}
+
+func scanAuditLog(row rowScanner) (models.AuditLog, error) {
+	var a models.AuditLog
+	var details []byte
+	err := row.Scan(
+		&a.ID,
+		&a.UserID,
+		&a.Action,
+		&a.IPAddress,
+		&details,
+		&a.Timestamp,
+	)
+	if details != nil {
+		a.Details = json.RawMessage(details)
+	}
+	return a, mapError(err)
+}
+
+// Create appends an audit log entry
+func (r *auditLogRepository) Create(ctx context.Context, entry *models.AuditLog) error {
+	query := `
+		INSERT INTO audit_logs (user_id, action, ip_address, details)
+		VALUES ($1, $2, $3::inet, $4)
+		RETURNING id, timestamp
+	`
+	err := r.db.QueryRow(ctx, query,
+		entry.UserID,
+		entry.Action,
+		entry.IPAddress,
+		entry.Details,
+	).Scan(&entry.ID, &entry.Timestamp)
+	return mapError(err)
+}
+
+// List returns a page of audit log entries, newest first
+func (r *auditLogRepository) List(ctx context.Context, filter AuditLogFilter, page Pagination) (Page[models.AuditLog], error) {
+	w := &whereBuilder{}
+	if filter.UserID != nil {
+		w.add("user_id = ?", *filter.UserID)
+	}
+	if filter.Action != "" {
+		w.add("action = ?", filter.Action)
+	}
+	if filter.From != nil {
+		w.add("timestamp >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		w.add("timestamp < ?", *filter.To)
+	}
+	return listPage(ctx, r.db, "audit_logs", auditLogColumns, "timestamp DESC, id", w, page, scanAuditLog)
+}
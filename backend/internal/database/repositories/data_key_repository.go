@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"context"
+
+	"ironarchive/internal/models"
+)
+
+// DataKeyRepository provides access to the tenant_data_keys table. Key
+// material is only ever stored wrapped; unwrapping happens in the vault.
+type DataKeyRepository interface {
+	Create(ctx context.Context, key *models.TenantDataKey) error
+	GetLatest(ctx context.Context, tenantID string) (*models.TenantDataKey, error)
+	ListByTenant(ctx context.Context, tenantID string) ([]models.TenantDataKey, error)
+	DeleteOlderThan(ctx context.Context, tenantID string, version int) (int64, error)
+}
+
+const dataKeyColumns = `id, tenant_id, version, master_key_id, wrapped_key, COALESCE(created_at, CURRENT_TIMESTAMP)`
+
+type dataKeyRepository struct {
+	db DBTX
+}
+
+// NewDataKeyRepository creates a data key repository
+func NewDataKeyRepository(db DBTX) DataKeyRepository {
+	return &dataKeyRepository{db: db}
+}
+
+func scanDataKey(row rowScanner) (models.TenantDataKey, error) {
+	var k models.TenantDataKey
+	err := row.Scan(
+		&k.ID,
+		&k.TenantID,
+		&k.Version,
+		&k.MasterKeyID,
+		&k.WrappedKey,
+		&k.CreatedAt,
+	)
+	return k, mapError(err)
+}
+
+// Create inserts a wrapped data key version
+func (r *dataKeyRepository) Create(ctx context.Context, key *models.TenantDataKey) error {
+	query := `
+		INSERT INTO tenant_data_keys (tenant_id, version, master_key_id, wrapped_key)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRow(ctx, query,
+		key.TenantID,
+		key.Version,
+		key.MasterKeyID,
+		key.WrappedKey,
+	).Scan(&key.ID, &key.CreatedAt)
+	return mapError(err)
+}
+
+// GetLatest returns the highest key version of a tenant
+func (r *dataKeyRepository) GetLatest(ctx context.Context, tenantID string) (*models.TenantDataKey, error) {
+	query := "SELECT " + dataKeyColumns + " FROM tenant_data_keys WHERE tenant_id = $1 ORDER BY version DESC LIMIT 1"
+	k, err := scanDataKey(r.db.QueryRow(ctx, query, tenantID))
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// ListByTenant returns all key versions of a tenant, newest first
+func (r *dataKeyRepository) ListByTenant(ctx context.Context, tenantID string) ([]models.TenantDataKey, error) {
+	rows, err := r.db.Query(ctx, "SELECT "+dataKeyColumns+" FROM tenant_data_keys WHERE tenant_id = $1 ORDER BY version DESC", tenantID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	keys := []models.TenantDataKey{}
+	for rows.Next() {
+		k, err := scanDataKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, mapError(rows.Err())
+}
+
+// DeleteOlderThan removes superseded key versions and returns how many were deleted
+func (r *dataKeyRepository) DeleteOlderThan(ctx context.Context, tenantID string, version int) (int64, error) {
+	tag, err := r.db.Exec(ctx, "DELETE FROM tenant_data_keys WHERE tenant_id = $1 AND version < $2", tenantID, version)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return tag.RowsAffected(), nil
+}
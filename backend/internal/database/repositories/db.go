@@ -0,0 +1,177 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ironarchive/internal/database"
+)
+
+// PostgreSQL error codes mapped to repository errors
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+)
+
+var (
+	// ErrNotFound is returned when a row does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict is returned when a unique constraint is violated
+	ErrConflict = errors.New("record already exists")
+	// ErrInvalidReference is returned when a foreign key target does not exist
+	ErrInvalidReference = errors.New("referenced record does not exist")
+	// ErrConstraintViolation is returned when a CHECK constraint rejects a value
+	ErrConstraintViolation = errors.New("value violates a constraint")
+)
+
+// DBTX is the subset of pgx implemented by both *pgxpool.Pool and pgx.Tx,
+// so every repository works inside or outside a transaction
+type DBTX interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Repositories bundles every table repository bound to the same DBTX
+type Repositories struct {
+	Tenants         TenantRepository
+	Users           UserRepository
+	Mailboxes       MailboxRepository
+	Folders         FolderRepository
+	Emails          EmailRepository
+	Attachments     AttachmentRepository
+	MessageBlobs    MessageBlobRepository
+	Threads         ThreadRepository
+	Jobs            JobRepository
+	ScheduleRuns    ScheduleRunRepository
+	AuditLogs       AuditLogRepository
+	Settings        SettingRepository
+	DataKeys        DataKeyRepository
+	SearchDeletions SearchDeletionRepository
+}
+
+// New creates all repositories on top of the given pool or transaction
+func New(db DBTX) *Repositories {
+	return &Repositories{
+		Tenants:         NewTenantRepository(db),
+		Users:           NewUserRepository(db),
+		Mailboxes:       NewMailboxRepository(db),
+		Folders:         NewFolderRepository(db),
+		Emails:          NewEmailRepository(db),
+		Attachments:     NewAttachmentRepository(db),
+		MessageBlobs:    NewMessageBlobRepository(db),
+		Threads:         NewThreadRepository(db),
+		Jobs:            NewJobRepository(db),
+		ScheduleRuns:    NewScheduleRunRepository(db),
+		AuditLogs:       NewAuditLogRepository(db),
+		Settings:        NewSettingRepository(db),
+		DataKeys:        NewDataKeyRepository(db),
+		SearchDeletions: NewSearchDeletionRepository(db),
+	}
+}
+
+// Store provides repositories on the connection pool and runs transactions
+type Store struct {
+	*Repositories
+	pool *pgxpool.Pool
+}
+
+// NewStore creates a store backed by the given pool
+func NewStore(pool *pgxpool.Pool) *Store {
+	return &Store{
+		Repositories: New(pool),
+		pool:         pool,
+	}
+}
+
+// WithTx runs fn with repositories bound to a single transaction. The
+// transaction commits if fn returns nil and rolls back otherwise, so
+// multi-table writes (e.g. an email and its attachments) are atomic.
+func (s *Store) WithTx(ctx context.Context, fn func(repos *Repositories) error) error {
+	return WithTx(ctx, s.pool, func(tx pgx.Tx) error {
+		return fn(New(tx))
+	})
+}
+
+// TxBeginner is implemented by *pgxpool.Pool, *pgx.Conn and pgx.Tx (nested savepoints)
+type TxBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// WithTx runs fn inside a transaction, committing on success and rolling back on error or panic
+func WithTx(ctx context.Context, db TxBeginner, fn func(tx pgx.Tx) error) (err error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(ctx)
+			panic(p)
+		}
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// mapError translates pgx errors into repository errors while keeping the original wrapped
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			return fmt.Errorf("%w: %s", ErrConflict, pgErr.ConstraintName)
+		case pgForeignKeyViolation:
+			return fmt.Errorf("%w: %s", ErrInvalidReference, pgErr.ConstraintName)
+		case pgCheckViolation:
+			return fmt.Errorf("%w: %s", ErrConstraintViolation, pgErr.ConstraintName)
+		}
+	}
+	return err
+}
+
+// affectOne returns ErrNotFound when a write touched no rows
+func affectOne(tag pgconn.CommandTag, err error) error {
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// WithSession runs fn with repositories bound to a transaction scoped to the
+// session's tenant and role, so row-level security filters every query
+func (s *Store) WithSession(ctx context.Context, session database.Session, fn func(repos *Repositories) error) error {
+	return WithTx(ctx, s.pool, func(tx pgx.Tx) error {
+		if err := database.ApplySession(ctx, tx, session); err != nil {
+			return err
+		}
+		return fn(New(tx))
+	})
+}
@@ -0,0 +1,286 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"ironarchive/internal/models"
+)
+
+// EmailFilter narrows email listings
+type EmailFilter struct {
+	// TenantID restricts results to mailboxes of one tenant
+	TenantID       *string
+	MailboxID      *string
+	Sender         string
+	SentAfter      *time.Time
+	SentBefore     *time.Time
+	HasAttachments *bool
+	// IncludeDeleted also returns soft-deleted emails
+	IncludeDeleted bool
+}
+
+// ReindexFilter selects emails whose search documents are rebuilt
+type ReindexFilter struct {
+	// TenantID restricts the reindex to mailboxes of one tenant
+	TenantID *string
+	// ChangedSince restricts the reindex to emails changed at or after it
+	ChangedSince *time.Time
+}
+
+// EmailRepository provides access to the emails table
+type EmailRepository interface {
+	Create(ctx context.Context, email *models.Email) error
+	GetByID(ctx context.Context, id string) (*models.Email, error)
+	GetByMessageID(ctx context.Context, mailboxID, messageID string) (*models.Email, error)
+	List(ctx context.Context, filter EmailFilter, page Pagination) (Page[models.Email], error)
+	ListAfter(ctx context.Context, filter EmailFilter, afterID string, limit int) ([]models.Email, error)
+	ListUnindexed(ctx context.Context, limit int) ([]models.Email, error)
+	ListUnthreaded(ctx context.Context, limit int) ([]models.Email, error)
+	SetThreadHeaders(ctx context.Context, email *models.Email) error
+	SetFolderState(ctx context.Context, email *models.Email) error
+	MarkIndexed(ctx context.Context, emails []models.Email, indexedAt time.Time) (int64, error)
+	ResetIndexed(ctx context.Context, filter ReindexFilter) (int64, error)
+	Search(ctx context.Context, search EmailSearch) (*EmailSearchResult, error)
+	SoftDelete(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string) error
+}
+
+// emailSummaryColumns omits the (potentially large) bodies for listings
+const emailSummaryColumns = `id, mailbox_id, message_id, subject, sender, COALESCE(recipients, '{}'), sent_at,
+	NULL::text, NULL::text, COALESCE(has_attachments, FALSE), size_bytes, file_path, categories,
+	internet_message_id, in_reply_to, message_references, conversation_id, thread_id,
+	blob_id, folder_id, is_read, flag_status, indexed_at, deleted_at,
+	COALESCE(created_at, CURRENT_TIMESTAMP), COALESCE(updated_at, created_at, CURRENT_TIMESTAMP)`
+
+const emailColumns = `id, mailbox_id, message_id, subject, sender, COALESCE(recipients, '{}'), sent_at,
+	body_text, body_html, COALESCE(has_attachments, FALSE), size_bytes, file_path, categories,
+	internet_message_id, in_reply_to, message_references, conversation_id, thread_id,
+	blob_id, folder_id, is_read, flag_status, indexed_at, deleted_at,
+	COALESCE(created_at, CURRENT_TIMESTAMP), COALESCE(updated_at, created_at, CURRENT_TIMESTAMP)`
+
+type emailRepository struct {
+	db DBTX
+}
+
+// NewEmailRepository creates an email repository
+func NewEmailRepository(db DBTX) EmailRepository {
+	return &emailRepository{db: db}
+}
+
+func scanEmail(row rowScanner) (models.Email, error) {
+	var e models.Email
+	err := row.Scan(emailFields(&e)...)
+	return e, mapError(err)
+}
+
+// emailFields returns the scan destinations of emailColumns
+func emailFields(e *models.Email) []any {
+	return []any{
+		&e.ID,
+		&e.MailboxID,
+		&e.MessageID,
+		&e.Subject,
+		&e.Sender,
+		&e.Recipients,
+		&e.SentAt,
+		&e.BodyText,
+		&e.BodyHTML,
+		&e.HasAttachments,
+		&e.SizeBytes,
+		&e.FilePath,
+		&e.Categories,
+		&e.InternetMessageID,
+		&e.InReplyTo,
+		&e.References,
+		&e.ConversationID,
+		&e.ThreadID,
+		&e.BlobID,
+		&e.FolderID,
+		&e.IsRead,
+		&e.FlagStatus,
+		&e.IndexedAt,
+		&e.DeletedAt,
+		&e.CreatedAt,
+		&e.UpdatedAt,
+	}
+}
+
+// Create inserts an email and populates its generated fields
+func (r *emailRepository) Create(ctx context.Context, email *models.Email) error {
+	query := `
+		INSERT INTO emails (mailbox_id, message_id, subject, sender, recipients, sent_at, body_text, body_html, has_attachments, size_bytes, file_path, categories,
+			internet_message_id, in_reply_to, message_references, conversation_id, thread_id, blob_id, folder_id, is_read, flag_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, COALESCE($12, '{}'::text[]), $13, $14, COALESCE($15, '{}'::text[]), $16, $17,
+			$18, $19, $20, COALESCE(NULLIF($21, ''), 'notFlagged'))
+		RETURNING id, flag_status, created_at
+	`
+	err := r.db.QueryRow(ctx, query,
+		email.MailboxID,
+		email.MessageID,
+		email.Subject,
+		email.Sender,
+		email.Recipients,
+		email.SentAt,
+		email.BodyText,
+		email.BodyHTML,
+		email.HasAttachments,
+		email.SizeBytes,
+		email.FilePath,
+		email.Categories,
+		email.InternetMessageID,
+		email.InReplyTo,
+		email.References,
+		email.ConversationID,
+		email.ThreadID,
+		email.BlobID,
+		email.FolderID,
+		email.IsRead,
+		email.FlagStatus,
+	).Scan(&email.ID, &email.FlagStatus, &email.CreatedAt)
+	return mapError(err)
+}
+
+// GetByID returns an email including its bodies
+func (r *emailRepository) GetByID(ctx context.Context, id string) (*models.Email, error) {
+	e, err := scanEmail(r.db.QueryRow(ctx, "SELECT "+emailColumns+" FROM emails WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// GetByMessageID returns an email by its Graph message ID
+func (r *emailRepository) GetByMessageID(ctx context.Context, mailboxID, messageID string) (*models.Email, error) {
+	e, err := scanEmail(r.db.QueryRow(ctx, "SELECT "+emailColumns+" FROM emails WHERE message_id = $1", messageID))
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// List returns a page of email summaries (without bodies), newest first
+func (r *emailRepository) List(ctx context.Context, filter EmailFilter, page Pagination) (Page[models.Email], error) {
+	return listPage(ctx, r.db, "emails", emailSummaryColumns, "sent_at DESC, id", emailWhere(filter), page, scanEmail)
+}
+
+// ListAfter returns up to limit emails with bodies whose ID sorts after
+// afterID, in ID order, for walking the whole archive in batches
+func (r *emailRepository) ListAfter(ctx context.Context, filter EmailFilter, afterID string, limit int) ([]models.Email, error) {
+	w := emailWhere(filter)
+	if afterID != "" {
+		w.add("id > ?", afterID)
+	}
+	return listAll(ctx, r.db, "emails", emailColumns, "id LIMIT "+w.arg(limit), w, scanEmail)
+}
+
+// emailWhere builds the conditions of an email filter
+func emailWhere(filter EmailFilter) *whereBuilder {
+	w := &whereBuilder{}
+	if filter.TenantID != nil {
+		w.add("mailbox_id IN (SELECT id FROM mailboxes WHERE tenant_id = ?)", *filter.TenantID)
+	}
+	if filter.MailboxID != nil {
+		w.add("mailbox_id = ?", *filter.MailboxID)
+	}
+	if filter.Sender != "" {
+		w.add("sender = ?", filter.Sender)
+	}
+	if filter.SentAfter != nil {
+		w.add("sent_at >= ?", *filter.SentAfter)
+	}
+	if filter.SentBefore != nil {
+		w.add("sent_at < ?", *filter.SentBefore)
+	}
+	if filter.HasAttachments != nil {
+		w.add("COALESCE(has_attachments, FALSE) = ?", *filter.HasAttachments)
+	}
+	if !filter.IncludeDeleted {
+		w.add("deleted_at IS NULL")
+	}
+	return w
+}
+
+// ListUnindexed returns up to limit emails whose search document is missing
+// or stale, least recently changed first. Soft-deleted emails are included
+// so their documents can be removed.
+func (r *emailRepository) ListUnindexed(ctx context.Context, limit int) ([]models.Email, error) {
+	w := &whereBuilder{}
+	w.add("indexed_at IS NULL")
+	return listAll(ctx, r.db, "emails", emailColumns, "updated_at, id LIMIT "+w.arg(limit), w, scanEmail)
+}
+
+// ListUnthreaded returns up to limit emails without a thread, oldest first.
+// Bodies are not loaded.
+func (r *emailRepository) ListUnthreaded(ctx context.Context, limit int) ([]models.Email, error) {
+	w := &whereBuilder{}
+	w.add("thread_id IS NULL")
+	return listAll(ctx, r.db, "emails", emailSummaryColumns, "created_at, id LIMIT "+w.arg(limit), w, scanEmail)
+}
+
+// SetThreadHeaders stores the threading headers of an email
+func (r *emailRepository) SetThreadHeaders(ctx context.Context, email *models.Email) error {
+	query := `
+		UPDATE emails SET internet_message_id = $2, in_reply_to = $3, message_references = COALESCE($4, '{}'::text[])
+		WHERE id = $1
+	`
+	return affectOne(r.db.Exec(ctx, query, email.ID, email.InternetMessageID, email.InReplyTo, email.References))
+}
+
+// SetFolderState stores the folder, read state and flag of an email's copy
+func (r *emailRepository) SetFolderState(ctx context.Context, email *models.Email) error {
+	query := `
+		UPDATE emails SET folder_id = $2, is_read = $3, flag_status = COALESCE(NULLIF($4, ''), 'notFlagged')
+		WHERE id = $1
+	`
+	return affectOne(r.db.Exec(ctx, query, email.ID, email.FolderID, email.IsRead, email.FlagStatus))
+}
+
+// MarkIndexed records the search indexing time of emails as they were read.
+// Emails changed since are skipped so they are indexed again; the number of
+// marked emails is returned.
+func (r *emailRepository) MarkIndexed(ctx context.Context, emails []models.Email, indexedAt time.Time) (int64, error) {
+	ids := make([]string, len(emails))
+	versions := make([]time.Time, len(emails))
+	for i, e := range emails {
+		ids[i], versions[i] = e.ID, e.UpdatedAt
+	}
+	query := `
+		UPDATE emails SET indexed_at = $3
+		FROM unnest($1::uuid[], $2::timestamp[]) AS indexed(id, updated_at)
+		WHERE emails.id = indexed.id AND COALESCE(emails.updated_at, emails.created_at) = indexed.updated_at
+	`
+	tag, err := r.db.Exec(ctx, query, ids, versions, indexedAt)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ResetIndexed flags the selected indexed emails for indexing again and
+// returns how many were flagged
+func (r *emailRepository) ResetIndexed(ctx context.Context, filter ReindexFilter) (int64, error) {
+	w := &whereBuilder{}
+	w.add("indexed_at IS NOT NULL")
+	if filter.TenantID != nil {
+		w.add("mailbox_id IN (SELECT id FROM mailboxes WHERE tenant_id = ?)", *filter.TenantID)
+	}
+	if filter.ChangedSince != nil {
+		w.add("COALESCE(updated_at, created_at) >= ?", *filter.ChangedSince)
+	}
+	tag, err := r.db.Exec(ctx, "UPDATE emails SET indexed_at = NULL"+w.sql(), w.args...)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// SoftDelete marks an email as deleted without removing it
+func (r *emailRepository) SoftDelete(ctx context.Context, id string) error {
+	return affectOne(r.db.Exec(ctx, "UPDATE emails SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL", id))
+}
+
+// Delete permanently removes an email and its attachments
+func (r *emailRepository) Delete(ctx context.Context, id string) error {
+	return affectOne(r.db.Exec(ctx, "DELETE FROM emails WHERE id = $1", id))
+}
@@ -0,0 +1,387 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"ironarchive/internal/models"
+	"ironarchive/internal/search/query"
+)
+
+// EmailSearch is a full-text search over the emails table, the fallback
+// while the search index is unavailable
+type EmailSearch struct {
+	Filter EmailFilter
+	// Query is the parsed search query; nil matches every email
+	Query query.Node
+	// Sort is "relevance" (default), "newest" or "oldest"
+	Sort string
+	// Collapse returns one hit per thread, the best-ranked email; Total
+	// counts threads, while facets keep counting emails
+	Collapse bool
+	// Facets are the attributes whose values are counted: tenant_id,
+	// mailbox_id, sender, recipients or has_attachments
+	Facets []string
+	Offset int64
+	Limit  int
+	// MaxTotal caps the number of matches counted
+	MaxTotal int
+	// HighlightPreTag and HighlightPostTag wrap matched words in highlights
+	HighlightPreTag  string
+	HighlightPostTag string
+	// SnippetWords is the length of the body snippet
+	SnippetWords int
+}
+
+// EmailSearchHit is an email matching a search; bodies are not loaded
+type EmailSearchHit struct {
+	Email    models.Email
+	TenantID string
+	// Subject and Sender are highlighted; Snippet is the body text around
+	// the best match, or its beginning
+	Subject string
+	Sender  string
+	Snippet string
+	// Attachments are the attachments whose text matched the free text
+	Attachments []EmailSearchAttachment
+}
+
+// EmailSearchAttachment is an attachment of a hit with a highlighted
+// snippet of its extracted text
+type EmailSearchAttachment struct {
+	ID       string
+	Filename string
+	Snippet  string
+}
+
+// EmailSearchResult is one page of an email search
+type EmailSearchResult struct {
+	Hits []EmailSearchHit
+	// Total is the number of matches, up to EmailSearch.MaxTotal
+	Total  int64
+	Facets map[string]map[string]int64
+}
+
+// emailFacets maps facet names to the expressions whose values are counted
+var emailFacets = map[string]string{
+	"tenant_id":       "(SELECT tenant_id::text FROM mailboxes WHERE mailboxes.id = emails.mailbox_id)",
+	"mailbox_id":      "mailbox_id::text",
+	"sender":          "sender",
+	"recipients":      "unnest(recipients)",
+	"has_attachments": "COALESCE(has_attachments, FALSE)::text",
+}
+
+// emailThreadKey groups the emails of a thread when collapsing; emails not
+// threaded yet stand alone
+const emailThreadKey = "COALESCE(thread_id, id)"
+
+// maxFacetValues caps the values returned per facet
+const maxFacetValues = 100
+
+// headlineBodyChars matches the text prefix of the search_vector columns of
+// emails and attachments (migrations 000010 and 000011)
+const headlineBodyChars = 262144
+
+// Search returns a page of the emails matching a search, ranked by how well
+// their subject, sender and body match the query's free text. Free text also
+// matches the extracted text of attachments; hits list the attachments that
+// matched.
+func (r *emailRepository) Search(ctx context.Context, search EmailSearch) (*EmailSearchResult, error) {
+	w := emailWhere(search.Filter)
+	if search.Query != nil {
+		condition, err := queryCondition(w, search.Query)
+		if err != nil {
+			return nil, err
+		}
+		w.add(condition)
+	}
+	where := w.sql()
+	result := &EmailSearchResult{Hits: []EmailSearchHit{}}
+
+	// The matches are counted up to MaxTotal so broad searches stay cheap
+	countQuery := "SELECT COUNT(*) FROM (SELECT 1 FROM emails" + where + " LIMIT " + strconv.Itoa(search.MaxTotal) + ") matches"
+	if search.Collapse {
+		countQuery = "SELECT COUNT(*) FROM (SELECT DISTINCT " + emailThreadKey + " FROM emails" + where + " LIMIT " + strconv.Itoa(search.MaxTotal) + ") matches"
+	}
+	if err := r.db.QueryRow(ctx, countQuery, w.args...).Scan(&result.Total); err != nil {
+		return nil, mapError(err)
+	}
+	if len(search.Facets) > 0 {
+		result.Facets = make(map[string]map[string]int64, len(search.Facets))
+	}
+	for _, facet := range search.Facets {
+		counts, err := r.countFacet(ctx, facet, where, w.args)
+		if err != nil {
+			return nil, err
+		}
+		result.Facets[facet] = counts
+	}
+	if result.Total == 0 || search.Limit <= 0 {
+		return result, nil
+	}
+
+	var subject, sender, snippet, orderBy string
+	if tsquery := rankQuery(w, search.Query); tsquery != "" {
+		tags := fmt.Sprintf(`StartSel="%s", StopSel="%s"`, search.HighlightPreTag, search.HighlightPostTag)
+		fieldOptions := w.arg(tags + ", HighlightAll=true")
+		bodyOptions := w.arg(fmt.Sprintf("%s, MaxWords=%d, MinWords=%d", tags, search.SnippetWords, max(search.SnippetWords/2, 1)))
+		subject = "ts_headline('simple', COALESCE(subject, ''), " + tsquery + ", " + fieldOptions + ")"
+		sender = "ts_headline('simple', COALESCE(sender, ''), " + tsquery + ", " + fieldOptions + ")"
+		snippet = fmt.Sprintf("ts_headline('simple', LEFT(COALESCE(body_text, ''), %d), %s, %s)", headlineBodyChars, tsquery, bodyOptions)
+		orderBy = "ts_rank_cd(search_vector, " + tsquery + ") DESC, sent_at DESC, id"
+	} else {
+		subject, sender = "COALESCE(subject, '')", "COALESCE(sender, '')"
+		snippet = fmt.Sprintf(`array_to_string((regexp_split_to_array(btrim(LEFT(COALESCE(body_text, ''), 4096)), '\s+'))[1:%d], ' ')`, search.SnippetWords)
+		orderBy = "sent_at DESC, id"
+	}
+	switch search.Sort {
+	case "newest":
+		orderBy = "sent_at DESC, id"
+	case "oldest":
+		orderBy = "sent_at, id"
+	}
+
+	if search.Collapse {
+		// The first email of each thread in result order stands for it
+		where = " WHERE id IN (SELECT id FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY " + emailThreadKey +
+			" ORDER BY " + orderBy + ") AS thread_rank FROM emails" + where + ") ranked WHERE thread_rank = 1)"
+	}
+	selectQuery := "SELECT " + emailSummaryColumns + ", (SELECT tenant_id FROM mailboxes WHERE mailboxes.id = emails.mailbox_id), " +
+		subject + ", " + sender + ", " + snippet + " FROM emails" + where +
+		" ORDER BY " + orderBy + " LIMIT " + w.arg(search.Limit) + " OFFSET " + w.arg(search.Offset)
+	rows, err := r.db.Query(ctx, selectQuery, w.args...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hit EmailSearchHit
+		if err := rows.Scan(append(emailFields(&hit.Email), &hit.TenantID, &hit.Subject, &hit.Sender, &hit.Snippet)...); err != nil {
+			return nil, mapError(err)
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+	return result, r.matchAttachments(ctx, search, result.Hits)
+}
+
+// matchAttachments sets the attachments of hits whose text matches the free
+// text of the search, with a highlighted snippet
+func (r *emailRepository) matchAttachments(ctx context.Context, search EmailSearch, hits []EmailSearchHit) error {
+	w := &whereBuilder{}
+	tsquery := rankQuery(w, search.Query)
+	if tsquery == "" || len(hits) == 0 {
+		return nil
+	}
+	ids := make([]string, len(hits))
+	byEmail := make(map[string]*EmailSearchHit, len(hits))
+	for i := range hits {
+		ids[i] = hits[i].Email.ID
+		byEmail[hits[i].Email.ID] = &hits[i]
+	}
+	options := w.arg(fmt.Sprintf(`StartSel="%s", StopSel="%s", MaxWords=%d, MinWords=%d`,
+		search.HighlightPreTag, search.HighlightPostTag, search.SnippetWords, max(search.SnippetWords/2, 1)))
+	matchQuery := fmt.Sprintf(`
+		SELECT email_id, id, filename, ts_headline('simple', LEFT(COALESCE(extracted_text, ''), %d), %s, %s)
+		FROM attachments
+		WHERE email_id = ANY(%s::uuid[]) AND search_vector @@ %s
+		ORDER BY email_id, created_at, id
+	`, headlineBodyChars, tsquery, options, w.arg(ids), tsquery)
+	rows, err := r.db.Query(ctx, matchQuery, w.args...)
+	if err != nil {
+		return mapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var emailID string
+		var a EmailSearchAttachment
+		if err := rows.Scan(&emailID, &a.ID, &a.Filename, &a.Snippet); err != nil {
+			return mapError(err)
+		}
+		if hit := byEmail[emailID]; hit != nil {
+			hit.Attachments = append(hit.Attachments, a)
+		}
+	}
+	return mapError(rows.Err())
+}
+
+// countFacet counts the most frequent values of a facet among the matches
+func (r *emailRepository) countFacet(ctx context.Context, facet, where string, args []any) (map[string]int64, error) {
+	expr, ok := emailFacets[facet]
+	if !ok {
+		return nil, fmt.Errorf("unknown facet %q", facet)
+	}
+	rows, err := r.db.Query(ctx, "SELECT value, COUNT(*) FROM (SELECT "+expr+" AS value FROM emails"+where+") facet"+
+		" WHERE value IS NOT NULL GROUP BY value ORDER BY COUNT(*) DESC, value LIMIT "+strconv.Itoa(maxFacetValues), args...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int64{}
+	for rows.Next() {
+		var value string
+		var count int64
+		if err := rows.Scan(&value, &count); err != nil {
+			return nil, mapError(err)
+		}
+		counts[value] = count
+	}
+	return counts, mapError(rows.Err())
+}
+
+// queryCondition renders a parsed query as a condition over the emails
+// table with the semantics of the search index: free text matches
+// search_vector of the email or of one of its attachments, operators match
+// like the derived terms of search documents
+func queryCondition(w *whereBuilder, node query.Node) (string, error) {
+	switch n := node.(type) {
+	case *query.And:
+		return joinConditions(w, n.Nodes, " AND ")
+	case *query.Or:
+		return joinConditions(w, n.Nodes, " OR ")
+	case *query.Not:
+		condition, err := queryCondition(w, n.Node)
+		if err != nil {
+			return "", err
+		}
+		// Conditions on NULL columns are NULL, which NOT would keep excluding
+		return "NOT COALESCE(" + condition + ", FALSE)", nil
+	case *query.Text:
+		if !strings.ContainsFunc(n.Value, isWordRune) {
+			// Punctuation has no lexemes; the search index ignores it too
+			return "TRUE", nil
+		}
+		tsquery := textQuery(w, n)
+		return "(search_vector @@ " + tsquery + " OR EXISTS (SELECT 1 FROM attachments a WHERE a.email_id = emails.id AND a.search_vector @@ " + tsquery + "))", nil
+	case *query.Field:
+		return fieldCondition(w, n), nil
+	case *query.HasAttachment:
+		return "COALESCE(has_attachments, FALSE)", nil
+	case *query.Size:
+		if n.Larger {
+			return "size_bytes > " + w.arg(n.Bytes) + "::bigint", nil
+		}
+		return "size_bytes < " + w.arg(n.Bytes) + "::bigint", nil
+	case *query.Date:
+		if n.Before {
+			return "sent_at < " + w.arg(n.Time), nil
+		}
+		return "sent_at >= " + w.arg(n.Time), nil
+	}
+	return "", fmt.Errorf("unsupported search term %s", node)
+}
+
+func joinConditions(w *whereBuilder, nodes []query.Node, sep string) (string, error) {
+	parts := make([]string, len(nodes))
+	for i, node := range nodes {
+		part, err := queryCondition(w, node)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = part
+	}
+	return "(" + strings.Join(parts, sep) + ")", nil
+}
+
+// fieldCondition matches an operator such as from: or filename:
+func fieldCondition(w *whereBuilder, f *query.Field) string {
+	value := strings.ToLower(strings.TrimSpace(f.Value))
+	switch f.Name {
+	case query.FieldFrom:
+		return addressCondition(w, "lower(sender)", value)
+	case query.FieldTo, query.FieldCc, query.FieldBcc:
+		return "EXISTS (SELECT 1 FROM unnest(recipients) AS r(address) WHERE " + addressCondition(w, "lower(r.address)", value) + ")"
+	case query.FieldLabel:
+		return "EXISTS (SELECT 1 FROM unnest(categories) AS c(label) WHERE lower(c.label) = " + w.arg(value) + ")"
+	case query.FieldFilename:
+		// The whole name, the name without extension, or any word of it
+		// (which includes the extension)
+		name := "lower(a.filename)"
+		v := w.arg(value)
+		return "EXISTS (SELECT 1 FROM attachments a WHERE a.email_id = emails.id AND (" +
+			name + " = " + v + " OR regexp_replace(" + name + `, '\.[^.]*$', '') = ` + v +
+			" OR " + v + " = ANY(regexp_split_to_array(" + name + ", '[^[:alnum:]]+'))))"
+	case query.FieldSubject:
+		words := query.Words(value)
+		if len(words) == 0 {
+			return "FALSE"
+		}
+		return "regexp_split_to_array(lower(COALESCE(subject, '')), '[^[:alnum:]]+') @> " + w.arg(words) + "::text[]"
+	}
+	return "FALSE"
+}
+
+// addressCondition matches an address expression against a from: or to:
+// value: "local@", "@domain", a full address, or a bare local part or
+// domain, where domains also match their subdomains
+func addressCondition(w *whereBuilder, expr, value string) string {
+	local := "split_part(" + expr + ", '@', 1)"
+	domain := "split_part(" + expr + ", '@', 2)"
+	at := strings.LastIndexByte(value, '@')
+	switch {
+	case at < 0:
+		v := w.arg(value)
+		condition := local + " = " + v + " OR " + domain + " = " + v
+		if strings.Contains(value, ".") {
+			condition += " OR " + domain + " LIKE " + w.arg("%."+escapeLike(value))
+		}
+		return "(" + condition + ")"
+	case at == 0:
+		value = value[1:]
+		condition := domain + " = " + w.arg(value)
+		if strings.Contains(value, ".") {
+			condition += " OR " + domain + " LIKE " + w.arg("%."+escapeLike(value))
+		}
+		return "(" + condition + ")"
+	case at == len(value)-1:
+		return local + " = " + w.arg(value[:at])
+	default:
+		return expr + " = " + w.arg(value)
+	}
+}
+
+// rankQuery returns the tsquery of the free text that must or may match,
+// used to rank and highlight results; empty without free text
+func rankQuery(w *whereBuilder, node query.Node) string {
+	var texts []string
+	var walk func(query.Node)
+	walk = func(node query.Node) {
+		switch n := node.(type) {
+		case *query.And:
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *query.Or:
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *query.Text:
+			if strings.ContainsFunc(n.Value, isWordRune) {
+				texts = append(texts, textQuery(w, n))
+			}
+		}
+	}
+	walk(node)
+	if len(texts) == 0 {
+		return ""
+	}
+	return "(" + strings.Join(texts, " || ") + ")"
+}
+
+// textQuery returns the tsquery of a word or phrase
+func textQuery(w *whereBuilder, t *query.Text) string {
+	if t.Phrase {
+		return "phraseto_tsquery('simple', " + w.arg(t.Value) + ")"
+	}
+	return "plainto_tsquery('simple', " + w.arg(t.Value) + ")"
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"ironarchive/internal/models"
+)
+
+// FolderRepository provides access to the mailbox_folders table
+type FolderRepository interface {
+	Upsert(ctx context.Context, folder *models.MailboxFolder) error
+	ListByMailbox(ctx context.Context, mailboxID string) ([]models.MailboxFolder, error)
+	CommitDelta(ctx context.Context, id string, deltaLink string, emailDelta int, syncedAt time.Time) error
+	ResetDelta(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string) error
+}
+
+const folderColumns = `id, mailbox_id, folder_id, display_name, delta_link,
+	COALESCE(email_count, 0), last_sync_at, COALESCE(created_at, CURRENT_TIMESTAMP)`
+
+type folderRepository struct {
+	db DBTX
+}
+
+// NewFolderRepository creates a mailbox folder repository
+func NewFolderRepository(db DBTX) FolderRepository {
+	return &folderRepository{db: db}
+}
+
+func scanFolder(row rowScanner) (models.MailboxFolder, error) {
+	var f models.MailboxFolder
+	err := row.Scan(
+		&f.ID,
+		&f.MailboxID,
+		&f.FolderID,
+		&f.DisplayName,
+		&f.DeltaLink,
+		&f.EmailCount,
+		&f.LastSyncAt,
+		&f.CreatedAt,
+	)
+	return f, mapError(err)
+}
+
+// Upsert inserts a folder or refreshes its display name, and populates the
+// stored sync state
+func (r *folderRepository) Upsert(ctx context.Context, folder *models.MailboxFolder) error {
+	query := `
+		INSERT INTO mailbox_folders (mailbox_id, folder_id, display_name)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (mailbox_id, folder_id) DO UPDATE SET display_name = EXCLUDED.display_name
+		RETURNING ` + folderColumns
+	f, err := scanFolder(r.db.QueryRow(ctx, query, folder.MailboxID, folder.FolderID, folder.DisplayName))
+	if err != nil {
+		return err
+	}
+	*folder = f
+	return nil
+}
+
+// ListByMailbox returns all known folders of a mailbox
+func (r *folderRepository) ListByMailbox(ctx context.Context, mailboxID string) ([]models.MailboxFolder, error) {
+	rows, err := r.db.Query(ctx, "SELECT "+folderColumns+" FROM mailbox_folders WHERE mailbox_id = $1 ORDER BY created_at, id", mailboxID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	folders := []models.MailboxFolder{}
+	for rows.Next() {
+		f, err := scanFolder(rows)
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, f)
+	}
+	return folders, mapError(rows.Err())
+}
+
+// CommitDelta records the delta link of a completed delta round. Call it only
+// after the emails of that round are committed, so a crash replays the round.
+func (r *folderRepository) CommitDelta(ctx context.Context, id string, deltaLink string, emailDelta int, syncedAt time.Time) error {
+	query := `
+		UPDATE mailbox_folders
+		SET delta_link = $2, email_count = COALESCE(email_count, 0) + $3, last_sync_at = $4
+		WHERE id = $1
+	`
+	return affectOne(r.db.Exec(ctx, query, id, deltaLink, emailDelta, syncedAt))
+}
+
+// ResetDelta clears the delta link so the next sync walks the folder in full
+func (r *folderRepository) ResetDelta(ctx context.Context, id string) error {
+	return affectOne(r.db.Exec(ctx, "UPDATE mailbox_folders SET delta_link = NULL WHERE id = $1", id))
+}
+
+// Delete removes a folder's sync state
+func (r *folderRepository) Delete(ctx context.Context, id string) error {
+	return affectOne(r.db.Exec(ctx, "DELETE FROM mailbox_folders WHERE id = $1", id))
+}
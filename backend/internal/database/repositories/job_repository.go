@@ -0,0 +1,270 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"ironarchive/internal/models"
+)
+
+// JobFilter narrows job listings
+type JobFilter struct {
+	TenantID  *string
+	MailboxID *string
+	UserID    *string
+	Type      string
+	Status    string
+}
+
+// JobRepository provides access to the jobs table
+type JobRepository interface {
+	Create(ctx context.Context, job *models.Job) error
+	GetByID(ctx context.Context, id string) (*models.Job, error)
+	List(ctx context.Context, filter JobFilter, page Pagination) (Page[models.Job], error)
+	MarkRunning(ctx context.Context, id string) error
+	UpdateProgress(ctx context.Context, id string, progress int) error
+	MarkCompleted(ctx context.Context, id string) error
+	MarkFailed(ctx context.Context, id, errorMessage string) error
+	Requeue(ctx context.Context, id string) error
+	MarkRetrying(ctx context.Context, id, errorMessage string, nextAttemptAt time.Time) error
+	Resume(ctx context.Context, id string) error
+	RequestCancel(ctx context.Context, id string) (string, error)
+	MarkCancelled(ctx context.Context, id string) error
+	UpdateResult(ctx context.Context, id string, result json.RawMessage) error
+	StartAttempt(ctx context.Context, id, worker string) (int, error)
+	FinishAttempt(ctx context.Context, id string, attempt int, status string, errorMessage *string) error
+	ListAttempts(ctx context.Context, id string) ([]models.JobAttempt, error)
+}
+
+const jobColumns = `id, type, status, tenant_id, mailbox_id, user_id, COALESCE(progress, 0), error_message,
+	metadata, result, attempts, max_attempts, next_attempt_at, cancel_requested_at, retry_of,
+	started_at, completed_at, COALESCE(created_at, CURRENT_TIMESTAMP)`
+
+const jobAttemptColumns = `id, job_id, attempt, status, worker, error_message,
+	COALESCE(started_at, CURRENT_TIMESTAMP), finished_at`
+
+type jobRepository struct {
+	db DBTX
+}
+
+// NewJobRepository creates a job repository
+func NewJobRepository(db DBTX) JobRepository {
+	return &jobRepository{db: db}
+}
+
+func scanJob(row rowScanner) (models.Job, error) {
+	var j models.Job
+	var metadata, result []byte
+	err := row.Scan(
+		&j.ID,
+		&j.Type,
+		&j.Status,
+		&j.TenantID,
+		&j.MailboxID,
+		&j.UserID,
+		&j.Progress,
+		&j.ErrorMessage,
+		&metadata,
+		&result,
+		&j.Attempts,
+		&j.MaxAttempts,
+		&j.NextAttemptAt,
+		&j.CancelRequestedAt,
+		&j.RetryOf,
+		&j.StartedAt,
+		&j.CompletedAt,
+		&j.CreatedAt,
+	)
+	if metadata != nil {
+		j.Metadata = json.RawMessage(metadata)
+	}
+	if result != nil {
+		j.Result = json.RawMessage(result)
+	}
+	return j, mapError(err)
+}
+
+func scanJobAttempt(row rowScanner) (models.JobAttempt, error) {
+	var a models.JobAttempt
+	err := row.Scan(&a.ID, &a.JobID, &a.Attempt, &a.Status, &a.Worker, &a.ErrorMessage, &a.StartedAt, &a.FinishedAt)
+	return a, mapError(err)
+}
+
+// Create inserts a job (defaulting to QUEUED and a single attempt) and
+// populates its generated fields
+func (r *jobRepository) Create(ctx context.Context, job *models.Job) error {
+	if job.Status == "" {
+		job.Status = models.JobStatusQueued
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = 1
+	}
+	query := `
+		INSERT INTO jobs (type, status, tenant_id, mailbox_id, user_id, metadata, max_attempts, retry_of)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, progress, created_at
+	`
+	err := r.db.QueryRow(ctx, query,
+		job.Type,
+		job.Status,
+		job.TenantID,
+		job.MailboxID,
+		job.UserID,
+		job.Metadata,
+		job.MaxAttempts,
+		job.RetryOf,
+	).Scan(&job.ID, &job.Progress, &job.CreatedAt)
+	return mapError(err)
+}
+
+// GetByID returns a job by primary key
+func (r *jobRepository) GetByID(ctx context.Context, id string) (*models.Job, error) {
+	j, err := scanJob(r.db.QueryRow(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// List returns a page of jobs, newest first
+func (r *jobRepository) List(ctx context.Context, filter JobFilter, page Pagination) (Page[models.Job], error) {
+	w := &whereBuilder{}
+	if filter.TenantID != nil {
+		w.add("tenant_id = ?", *filter.TenantID)
+	}
+	if filter.MailboxID != nil {
+		w.add("mailbox_id = ?", *filter.MailboxID)
+	}
+	if filter.UserID != nil {
+		w.add("user_id = ?", *filter.UserID)
+	}
+	if filter.Type != "" {
+		w.add("type = ?", filter.Type)
+	}
+	if filter.Status != "" {
+		w.add("status = ?", filter.Status)
+	}
+	return listPage(ctx, r.db, "jobs", jobColumns, "created_at DESC, id", w, page, scanJob)
+}
+
+// MarkRunning moves a queued job to RUNNING and records its start time
+func (r *jobRepository) MarkRunning(ctx context.Context, id string) error {
+	query := "UPDATE jobs SET status = $2, started_at = CURRENT_TIMESTAMP WHERE id = $1 AND status IN ($2, $3)"
+	return affectOne(r.db.Exec(ctx, query, id, models.JobStatusRunning, models.JobStatusQueued))
+}
+
+// UpdateProgress sets the completion percentage (0-100)
+func (r *jobRepository) UpdateProgress(ctx context.Context, id string, progress int) error {
+	return affectOne(r.db.Exec(ctx, "UPDATE jobs SET progress = $2 WHERE id = $1", id, progress))
+}
+
+// MarkCompleted finishes a job successfully
+func (r *jobRepository) MarkCompleted(ctx context.Context, id string) error {
+	query := "UPDATE jobs SET status = $2, progress = 100, completed_at = CURRENT_TIMESTAMP WHERE id = $1"
+	return affectOne(r.db.Exec(ctx, query, id, models.JobStatusCompleted))
+}
+
+// MarkFailed finishes a job with an error message
+func (r *jobRepository) MarkFailed(ctx context.Context, id, errorMessage string) error {
+	query := "UPDATE jobs SET status = $2, error_message = $3, completed_at = CURRENT_TIMESTAMP WHERE id = $1"
+	return affectOne(r.db.Exec(ctx, query, id, models.JobStatusFailed, errorMessage))
+}
+
+// Requeue resets an interrupted job to QUEUED so it can run again
+func (r *jobRepository) Requeue(ctx context.Context, id string) error {
+	query := `
+		UPDATE jobs SET status = $2, progress = 0, error_message = NULL, started_at = NULL, completed_at = NULL
+		WHERE id = $1
+	`
+	return affectOne(r.db.Exec(ctx, query, id, models.JobStatusQueued))
+}
+
+// MarkRetrying records a failed attempt of a job that runs again at nextAttemptAt
+func (r *jobRepository) MarkRetrying(ctx context.Context, id, errorMessage string, nextAttemptAt time.Time) error {
+	query := `
+		UPDATE jobs SET status = $2, error_message = $3, next_attempt_at = $4, completed_at = NULL
+		WHERE id = $1 AND status NOT IN ($5, $6)
+	`
+	return affectOne(r.db.Exec(ctx, query, id, models.JobStatusRetrying, errorMessage, nextAttemptAt,
+		models.JobStatusCompleted, models.JobStatusCancelled))
+}
+
+// Resume queues a RETRYING job for its next attempt; ErrNotFound means it is
+// no longer waiting, e.g. because it was cancelled
+func (r *jobRepository) Resume(ctx context.Context, id string) error {
+	query := `
+		UPDATE jobs SET status = $2, progress = 0, started_at = NULL, next_attempt_at = NULL
+		WHERE id = $1 AND status = $3
+	`
+	return affectOne(r.db.Exec(ctx, query, id, models.JobStatusQueued, models.JobStatusRetrying))
+}
+
+// RequestCancel cancels a queued or retrying job outright and flags a running
+// one for its worker to stop. It returns the resulting status; ErrNotFound
+// means the job does not exist or already finished.
+func (r *jobRepository) RequestCancel(ctx context.Context, id string) (string, error) {
+	query := `
+		UPDATE jobs SET
+			status = CASE WHEN status = $2 THEN status ELSE $3 END,
+			completed_at = CASE WHEN status = $2 THEN completed_at ELSE CURRENT_TIMESTAMP END,
+			next_attempt_at = NULL,
+			cancel_requested_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status IN ($4, $5, $2)
+		RETURNING status
+	`
+	var status string
+	err := r.db.QueryRow(ctx, query, id, models.JobStatusRunning, models.JobStatusCancelled,
+		models.JobStatusQueued, models.JobStatusRetrying).Scan(&status)
+	return status, mapError(err)
+}
+
+// MarkCancelled finishes a job whose worker stopped on a cancel request,
+// unless it completed first
+func (r *jobRepository) MarkCancelled(ctx context.Context, id string) error {
+	query := `
+		UPDATE jobs SET status = $2, completed_at = CURRENT_TIMESTAMP, next_attempt_at = NULL
+		WHERE id = $1 AND status <> $3
+	`
+	return affectOne(r.db.Exec(ctx, query, id, models.JobStatusCancelled, models.JobStatusCompleted))
+}
+
+// UpdateResult stores the outcome a handler reports for a job
+func (r *jobRepository) UpdateResult(ctx context.Context, id string, result json.RawMessage) error {
+	return affectOne(r.db.Exec(ctx, "UPDATE jobs SET result = $2 WHERE id = $1", id, result))
+}
+
+// StartAttempt counts a new attempt of a job and records it as running. Earlier
+// attempts still running were interrupted by a crashed worker.
+func (r *jobRepository) StartAttempt(ctx context.Context, id, worker string) (int, error) {
+	query := `
+		WITH interrupted AS (
+			UPDATE job_attempts SET status = $3, finished_at = CURRENT_TIMESTAMP
+			WHERE job_id = $1 AND status = $4
+		), counted AS (
+			UPDATE jobs SET attempts = attempts + 1 WHERE id = $1 RETURNING id, attempts
+		)
+		INSERT INTO job_attempts (job_id, attempt, status, worker)
+		SELECT id, attempts, $4, $2 FROM counted
+		RETURNING attempt
+	`
+	var attempt int
+	err := r.db.QueryRow(ctx, query, id, worker, models.JobAttemptInterrupted, models.JobAttemptRunning).Scan(&attempt)
+	return attempt, mapError(err)
+}
+
+// FinishAttempt records the outcome of an attempt
+func (r *jobRepository) FinishAttempt(ctx context.Context, id string, attempt int, status string, errorMessage *string) error {
+	query := `
+		UPDATE job_attempts SET status = $3, error_message = $4, finished_at = CURRENT_TIMESTAMP
+		WHERE job_id = $1 AND attempt = $2
+	`
+	return affectOne(r.db.Exec(ctx, query, id, attempt, status, errorMessage))
+}
+
+// ListAttempts returns the attempts of a job, oldest first
+func (r *jobRepository) ListAttempts(ctx context.Context, id string) ([]models.JobAttempt, error) {
+	w := &whereBuilder{}
+	w.add("job_id = ?", id)
+	return listAll(ctx, r.db, "job_attempts", jobAttemptColumns, "attempt", w, scanJobAttempt)
+}
@@ -0,0 +1,206 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"ironarchive/internal/models"
+)
+
+// MailboxFilter narrows mailbox listings
+type MailboxFilter struct {
+	TenantID    *string
+	MailboxType string
+	SyncEnabled *bool
+	// Search matches email address or display name case-insensitively
+	Search string
+}
+
+// MailboxRepository provides access to the mailboxes table
+type MailboxRepository interface {
+	Create(ctx context.Context, mailbox *models.Mailbox) error
+	GetByID(ctx context.Context, id string) (*models.Mailbox, error)
+	GetByAddress(ctx context.Context, tenantID, emailAddress string) (*models.Mailbox, error)
+	List(ctx context.Context, filter MailboxFilter, page Pagination) (Page[models.Mailbox], error)
+	Update(ctx context.Context, mailbox *models.Mailbox) error
+	SetSyncEnabled(ctx context.Context, id string, enabled bool) error
+	ListSyncEnabled(ctx context.Context, tenantID *string) ([]models.Mailbox, error)
+	ListScheduled(ctx context.Context) ([]models.Mailbox, error)
+	ListInheritingSchedule(ctx context.Context, tenantID *string) ([]models.Mailbox, error)
+	UpdateLastSync(ctx context.Context, id string, syncedAt time.Time) error
+	AddUsage(ctx context.Context, id string, emailDelta int, logicalDelta, physicalDelta int64) error
+	Delete(ctx context.Context, id string) error
+}
+
+const mailboxColumns = `id, tenant_id, email_address, display_name, mailbox_type,
+	COALESCE(sync_enabled, FALSE), last_sync_at, last_delta_token,
+	COALESCE(email_count, 0), COALESCE(storage_bytes, 0), logical_storage_bytes, sync_schedule, sync_timezone,
+	COALESCE(created_at, CURRENT_TIMESTAMP)`
+
+type mailboxRepository struct {
+	db DBTX
+}
+
+// NewMailboxRepository creates a mailbox repository
+func NewMailboxRepository(db DBTX) MailboxRepository {
+	return &mailboxRepository{db: db}
+}
+
+func scanMailbox(row rowScanner) (models.Mailbox, error) {
+	var m models.Mailbox
+	err := row.Scan(
+		&m.ID,
+		&m.TenantID,
+		&m.EmailAddress,
+		&m.DisplayName,
+		&m.MailboxType,
+		&m.SyncEnabled,
+		&m.LastSyncAt,
+		&m.LastDeltaToken,
+		&m.EmailCount,
+		&m.StorageBytes,
+		&m.LogicalStorageBytes,
+		&m.SyncSchedule,
+		&m.SyncTimezone,
+		&m.CreatedAt,
+	)
+	return m, mapError(err)
+}
+
+// Create inserts a mailbox and populates its generated fields
+func (r *mailboxRepository) Create(ctx context.Context, mailbox *models.Mailbox) error {
+	query := `
+		INSERT INTO mailboxes (tenant_id, email_address, display_name, mailbox_type, sync_enabled, sync_schedule, sync_timezone)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRow(ctx, query,
+		mailbox.TenantID,
+		mailbox.EmailAddress,
+		mailbox.DisplayName,
+		mailbox.MailboxType,
+		mailbox.SyncEnabled,
+		mailbox.SyncSchedule,
+		mailbox.SyncTimezone,
+	).Scan(&mailbox.ID, &mailbox.CreatedAt)
+	return mapError(err)
+}
+
+// GetByID returns a mailbox by primary key
+func (r *mailboxRepository) GetByID(ctx context.Context, id string) (*models.Mailbox, error) {
+	m, err := scanMailbox(r.db.QueryRow(ctx, "SELECT "+mailboxColumns+" FROM mailboxes WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// GetByAddress returns a tenant's mailbox by email address (case-insensitive)
+func (r *mailboxRepository) GetByAddress(ctx context.Context, tenantID, emailAddress string) (*models.Mailbox, error) {
+	query := "SELECT " + mailboxColumns + " FROM mailboxes WHERE tenant_id = $1 AND LOWER(email_address) = LOWER($2)"
+	m, err := scanMailbox(r.db.QueryRow(ctx, query, tenantID, emailAddress))
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// List returns a page of mailboxes ordered by email address
+func (r *mailboxRepository) List(ctx context.Context, filter MailboxFilter, page Pagination) (Page[models.Mailbox], error) {
+	w := &whereBuilder{}
+	if filter.TenantID != nil {
+		w.add("tenant_id = ?", *filter.TenantID)
+	}
+	if filter.MailboxType != "" {
+		w.add("mailbox_type = ?", filter.MailboxType)
+	}
+	if filter.SyncEnabled != nil {
+		w.add("COALESCE(sync_enabled, FALSE) = ?", *filter.SyncEnabled)
+	}
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		w.add("(email_address ILIKE ? OR display_name ILIKE ?)", pattern, pattern)
+	}
+	return listPage(ctx, r.db, "mailboxes", mailboxColumns, "email_address, id", w, page, scanMailbox)
+}
+
+// Update saves the descriptive mailbox fields and sync settings
+func (r *mailboxRepository) Update(ctx context.Context, mailbox *models.Mailbox) error {
+	query := `
+		UPDATE mailboxes
+		SET email_address = $2, display_name = $3, mailbox_type = $4, sync_enabled = $5,
+			sync_schedule = $6, sync_timezone = $7
+		WHERE id = $1
+	`
+	return affectOne(r.db.Exec(ctx, query,
+		mailbox.ID,
+		mailbox.EmailAddress,
+		mailbox.DisplayName,
+		mailbox.MailboxType,
+		mailbox.SyncEnabled,
+		mailbox.SyncSchedule,
+		mailbox.SyncTimezone,
+	))
+}
+
+// SetSyncEnabled toggles whether a mailbox is included in sync runs
+func (r *mailboxRepository) SetSyncEnabled(ctx context.Context, id string, enabled bool) error {
+	return affectOne(r.db.Exec(ctx, "UPDATE mailboxes SET sync_enabled = $2 WHERE id = $1", id, enabled))
+}
+
+// ListSyncEnabled returns all mailboxes included in sync runs, optionally of one tenant
+func (r *mailboxRepository) ListSyncEnabled(ctx context.Context, tenantID *string) ([]models.Mailbox, error) {
+	w := &whereBuilder{}
+	w.add("sync_enabled = TRUE")
+	if tenantID != nil {
+		w.add("tenant_id = ?", *tenantID)
+	}
+	return listAll(ctx, r.db, "mailboxes", mailboxColumns, "tenant_id, email_address", w, scanMailbox)
+}
+
+// ListScheduled returns the mailboxes included in sync runs that have their own sync schedule
+func (r *mailboxRepository) ListScheduled(ctx context.Context) ([]models.Mailbox, error) {
+	w := &whereBuilder{}
+	w.add("sync_enabled = TRUE")
+	w.add("sync_schedule IS NOT NULL")
+	return listAll(ctx, r.db, "mailboxes", mailboxColumns, "tenant_id, email_address", w, scanMailbox)
+}
+
+// ListInheritingSchedule returns the mailboxes a scheduled sync of a tenant
+// covers, or of the global schedule when tenantID is nil: mailboxes included
+// in sync runs without a schedule of their own and, for the global schedule,
+// whose tenant has none either
+func (r *mailboxRepository) ListInheritingSchedule(ctx context.Context, tenantID *string) ([]models.Mailbox, error) {
+	w := &whereBuilder{}
+	w.add("sync_enabled = TRUE")
+	w.add("sync_schedule IS NULL")
+	if tenantID != nil {
+		w.add("tenant_id = ?", *tenantID)
+	} else {
+		w.add("tenant_id IN (SELECT id FROM tenants WHERE sync_schedule IS NULL)")
+	}
+	return listAll(ctx, r.db, "mailboxes", mailboxColumns, "tenant_id, email_address", w, scanMailbox)
+}
+
+// UpdateLastSync records the time of the last successful sync. Delta state is
+// kept per folder by the folder repository.
+func (r *mailboxRepository) UpdateLastSync(ctx context.Context, id string, syncedAt time.Time) error {
+	return affectOne(r.db.Exec(ctx, "UPDATE mailboxes SET last_sync_at = $2 WHERE id = $1", id, syncedAt))
+}
+
+// AddUsage adjusts the archived email count and the logical and physical
+// storage counters by the given deltas
+func (r *mailboxRepository) AddUsage(ctx context.Context, id string, emailDelta int, logicalDelta, physicalDelta int64) error {
+	query := `
+		UPDATE mailboxes
+		SET email_count = COALESCE(email_count, 0) + $2, logical_storage_bytes = logical_storage_bytes + $3,
+			storage_bytes = COALESCE(storage_bytes, 0) + $4
+		WHERE id = $1
+	`
+	return affectOne(r.db.Exec(ctx, query, id, emailDelta, logicalDelta, physicalDelta))
+}
+
+// Delete removes a mailbox and cascades to its emails
+func (r *mailboxRepository) Delete(ctx context.Context, id string) error {
+	return affectOne(r.db.Exec(ctx, "DELETE FROM mailboxes WHERE id = $1", id))
+}
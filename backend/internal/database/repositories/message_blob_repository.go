@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+
+	"ironarchive/internal/models"
+)
+
+// MessageBlobRepository provides access to the message_blobs table
+type MessageBlobRepository interface {
+	Upsert(ctx context.Context, blob *models.MessageBlob) (bool, error)
+	GetByID(ctx context.Context, id string) (*models.MessageBlob, error)
+	CountReferences(ctx context.Context, id string) (int, error)
+}
+
+const messageBlobColumns = `id, tenant_id, sha256_hash, file_path, size_bytes, COALESCE(created_at, CURRENT_TIMESTAMP)`
+
+type messageBlobRepository struct {
+	db DBTX
+}
+
+// NewMessageBlobRepository creates a message blob repository
+func NewMessageBlobRepository(db DBTX) MessageBlobRepository {
+	return &messageBlobRepository{db: db}
+}
+
+func scanMessageBlob(row rowScanner) (models.MessageBlob, error) {
+	var b models.MessageBlob
+	err := row.Scan(
+		&b.ID,
+		&b.TenantID,
+		&b.SHA256Hash,
+		&b.FilePath,
+		&b.SizeBytes,
+		&b.CreatedAt,
+	)
+	return b, mapError(err)
+}
+
+// Upsert records a blob of a tenant, or populates blob from the row already
+// stored for its hash, and reports whether the row was created. The stored
+// row keeps its file path, so copies share the blob written first. Until the
+// transaction ends, concurrent upserts of the same hash wait for it.
+func (r *messageBlobRepository) Upsert(ctx context.Context, blob *models.MessageBlob) (bool, error) {
+	query := `
+		INSERT INTO message_blobs (tenant_id, sha256_hash, file_path, size_bytes)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tenant_id, sha256_hash) DO UPDATE SET sha256_hash = EXCLUDED.sha256_hash
+		RETURNING ` + messageBlobColumns + `, xmax = 0`
+	var created bool
+	row := r.db.QueryRow(ctx, query, blob.TenantID, blob.SHA256Hash, blob.FilePath, blob.SizeBytes)
+	err := row.Scan(&blob.ID, &blob.TenantID, &blob.SHA256Hash, &blob.FilePath, &blob.SizeBytes, &blob.CreatedAt, &created)
+	if err != nil {
+		return false, mapError(err)
+	}
+	return created, nil
+}
+
+// GetByID returns a message blob by primary key
+func (r *messageBlobRepository) GetByID(ctx context.Context, id string) (*models.MessageBlob, error) {
+	b, err := scanMessageBlob(r.db.QueryRow(ctx, "SELECT "+messageBlobColumns+" FROM message_blobs WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// CountReferences returns how many emails, soft-deleted ones included,
+// reference a blob. A blob without references may be removed from storage.
+func (r *messageBlobRepository) CountReferences(ctx context.Context, id string) (int, error) {
+	var n int
+	err := r.db.QueryRow(ctx, "SELECT COUNT(*) FROM emails WHERE blob_id = $1", id).Scan(&n)
+	return n, mapError(err)
+}
@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Pagination limits
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 500
+)
+
+// Pagination selects a page of results (1-based page numbers, as in the REST API)
+type Pagination struct {
+	Page  int
+	Limit int
+}
+
+// normalize applies defaults and bounds
+func (p Pagination) normalize() Pagination {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.Limit < 1 {
+		p.Limit = DefaultPageLimit
+	}
+	if p.Limit > MaxPageLimit {
+		p.Limit = MaxPageLimit
+	}
+	return p
+}
+
+// Offset returns the row offset for the page
+func (p Pagination) Offset() int {
+	p = p.normalize()
+	return (p.Page - 1) * p.Limit
+}
+
+// Page is a page of results with the total number of matching rows
+type Page[T any] struct {
+	Items []T `json:"items"`
+	Total int `json:"total"`
+	Page  int `json:"page"`
+	Limit int `json:"limit"`
+}
+
+// whereBuilder accumulates SQL conditions and positional arguments
+type whereBuilder struct {
+	conditions []string
+	args       []any
+}
+
+// add appends a condition; each "?" placeholder is replaced by the next $n argument
+func (w *whereBuilder) add(condition string, args ...any) {
+	for _, arg := range args {
+		w.args = append(w.args, arg)
+		condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(w.args)), 1)
+	}
+	w.conditions = append(w.conditions, condition)
+}
+
+// arg registers an argument without a condition and returns its placeholder
+func (w *whereBuilder) arg(value any) string {
+	w.args = append(w.args, value)
+	return fmt.Sprintf("$%d", len(w.args))
+}
+
+// sql renders the WHERE clause, or an empty string when there are no conditions
+func (w *whereBuilder) sql() string {
+	if len(w.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conditions, " AND ")
+}
+
+// limitOffset renders a LIMIT/OFFSET clause using positional arguments
+func (w *whereBuilder) limitOffset(p Pagination) string {
+	p = p.normalize()
+	return fmt.Sprintf(" LIMIT %s OFFSET %s", w.arg(p.Limit), w.arg(p.Offset()))
+}
+
+// escapeLike escapes LIKE wildcards in user-supplied search terms
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// rowScanner is implemented by pgx.Row and pgx.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// listPage runs a count query and a paginated select sharing the same WHERE clause
+func listPage[T any](ctx context.Context, db DBTX, fromSQL, columns, orderBy string, w *whereBuilder, p Pagination, scan func(rowScanner) (T, error)) (Page[T], error) {
+	p = p.normalize()
+	result := Page[T]{Items: []T{}, Page: p.Page, Limit: p.Limit}
+
+	where := w.sql()
+	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM "+fromSQL+where, w.args...).Scan(&result.Total); err != nil {
+		return result, mapError(err)
+	}
+	if result.Total == 0 {
+		return result, nil
+	}
+
+	query := "SELECT " + columns + " FROM " + fromSQL + where + " ORDER BY " + orderBy + w.limitOffset(p)
+	rows, err := db.Query(ctx, query, w.args...)
+	if err != nil {
+		return result, mapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return result, err
+		}
+		result.Items = append(result.Items, item)
+	}
+	return result, mapError(rows.Err())
+}
+
+// listAll runs an unpaginated select and scans every row
+func listAll[T any](ctx context.Context, db DBTX, fromSQL, columns, orderBy string, w *whereBuilder, scan func(rowScanner) (T, error)) ([]T, error) {
+	rows, err := db.Query(ctx, "SELECT "+columns+" FROM "+fromSQL+w.sql()+" ORDER BY "+orderBy, w.args...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	items := []T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, mapError(rows.Err())
+}
@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPaginationNormalize verifies defaults and bounds applied to page requests
+func TestPaginationNormalize(t *testing.T) {
+	assert.Equal(t, Pagination{Page: 1, Limit: DefaultPageLimit}, Pagination{}.normalize())
+	assert.Equal(t, Pagination{Page: 3, Limit: MaxPageLimit}, Pagination{Page: 3, Limit: 10000}.normalize())
+	assert.Equal(t, 40, Pagination{Page: 3, Limit: 20}.Offset())
+	assert.Equal(t, 0, Pagination{Page: -1, Limit: 20}.Offset())
+}
+
+// TestWhereBuilder verifies placeholders are numbered across conditions and pagination
+func TestWhereBuilder(t *testing.T) {
+	w := &whereBuilder{}
+	assert.Empty(t, w.sql())
+
+	w.add("tenant_id = ?", "t1")
+	w.add("sent_at >= ? AND sent_at < ?", 1, 2)
+	w.add("deleted_at IS NULL")
+
+	assert.Equal(t, " WHERE tenant_id = $1 AND sent_at >= $2 AND sent_at < $3 AND deleted_at IS NULL", w.sql())
+	assert.Equal(t, " LIMIT $4 OFFSET $5", w.limitOffset(Pagination{Page: 2, Limit: 10}))
+	assert.Equal(t, []any{"t1", 1, 2, 10, 10}, w.args)
+}
+
+// TestEscapeLike verifies LIKE wildcards in user input are matched literally
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, `100\%\_done\\`, escapeLike(`100%_done\`))
+	assert.Equal(t, "plain", escapeLike("plain"))
+}
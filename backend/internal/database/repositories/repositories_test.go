@@ -0,0 +1,614 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ironarchive/internal/database"
+	"ironarchive/internal/database/dbtest"
+	"ironarchive/internal/models"
+	"ironarchive/internal/search/query"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestStore connects to the test database, applies migrations and returns a store
+func setupTestStore(t *testing.T) (*Store, *pgxpool.Pool) {
+	t.Helper()
+
+	pool := dbtest.Connect(t)
+	return NewStore(pool), pool
+}
+
+// createTestMailbox inserts a tenant and a mailbox for email tests
+func createTestMailbox(t *testing.T, store *Store) (*models.Tenant, *models.Mailbox) {
+	t.Helper()
+	ctx := context.Background()
+
+	tenant := &models.Tenant{Name: "Acme", AzureTenantID: "11111111-1111-1111-1111-111111111111"}
+	require.NoError(t, store.Tenants.Create(ctx, tenant))
+
+	mailbox := &models.Mailbox{TenantID: tenant.ID, EmailAddress: "user@acme.test", MailboxType: models.MailboxTypeUser, SyncEnabled: true}
+	require.NoError(t, store.Mailboxes.Create(ctx, mailbox))
+
+	return tenant, mailbox
+}
+
+// TestTenantRepositoryCRUD verifies create, read, update, list and delete for tenants
+func TestTenantRepositoryCRUD(t *testing.T) {
+	store, _ := setupTestStore(t)
+	ctx := context.Background()
+
+	tenant := &models.Tenant{Name: "Acme", AzureTenantID: "11111111-1111-1111-1111-111111111111"}
+	require.NoError(t, store.Tenants.Create(ctx, tenant))
+	assert.NotEmpty(t, tenant.ID)
+	assert.Equal(t, 2555, tenant.RetentionPolicyDays, "retention should default from the schema")
+
+	found, err := store.Tenants.GetByID(ctx, tenant.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Acme", found.Name)
+
+	found.Name = "Acme Corp"
+	found.LegalHold = true
+	require.NoError(t, store.Tenants.Update(ctx, found))
+
+	page, err := store.Tenants.List(ctx, TenantFilter{Search: "corp"}, Pagination{})
+	require.NoError(t, err)
+	require.Equal(t, 1, page.Total)
+	assert.True(t, page.Items[0].LegalHold)
+
+	duplicate := &models.Tenant{Name: "Other", AzureTenantID: tenant.AzureTenantID}
+	assert.ErrorIs(t, store.Tenants.Create(ctx, duplicate), ErrConflict)
+
+	require.NoError(t, store.Tenants.Delete(ctx, tenant.ID))
+	_, err = store.Tenants.GetByID(ctx, tenant.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// TestStoreWithTxRollsBackEmailAndAttachments verifies multi-table writes are atomic
+func TestStoreWithTxRollsBackEmailAndAttachments(t *testing.T) {
+	store, _ := setupTestStore(t)
+	ctx := context.Background()
+	_, mailbox := createTestMailbox(t, store)
+
+	errAbort := errors.New("abort")
+	err := store.WithTx(ctx, func(repos *Repositories) error {
+		email := &models.Email{MailboxID: mailbox.ID, MessageID: "msg-1", SentAt: time.Now(), HasAttachments: true, FilePath: "/archive/msg-1.json"}
+		if err := repos.Emails.Create(ctx, email); err != nil {
+			return err
+		}
+		attachment := &models.Attachment{EmailID: email.ID, Filename: "a.pdf", SizeBytes: 10, SHA256Hash: "abc", FilePath: "/archive/abc"}
+		if err := repos.Attachments.Create(ctx, attachment); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	require.ErrorIs(t, err, errAbort)
+
+	_, err = store.Emails.GetByMessageID(ctx, mailbox.ID, "msg-1")
+	assert.ErrorIs(t, err, ErrNotFound, "email insert should be rolled back")
+	_, err = store.Attachments.FindByHash(ctx, "abc")
+	assert.ErrorIs(t, err, ErrNotFound, "attachment insert should be rolled back")
+
+	err = store.WithTx(ctx, func(repos *Repositories) error {
+		email := &models.Email{MailboxID: mailbox.ID, MessageID: "msg-2", SentAt: time.Now(), HasAttachments: true, FilePath: "/archive/msg-2.json"}
+		if err := repos.Emails.Create(ctx, email); err != nil {
+			return err
+		}
+		return repos.Attachments.Create(ctx, &models.Attachment{EmailID: email.ID, Filename: "b.pdf", SizeBytes: 10, SHA256Hash: "def", FilePath: "/archive/def"})
+	})
+	require.NoError(t, err)
+
+	email, err := store.Emails.GetByMessageID(ctx, mailbox.ID, "msg-2")
+	require.NoError(t, err)
+	attachments, err := store.Attachments.ListByEmail(ctx, email.ID)
+	require.NoError(t, err)
+	assert.Len(t, attachments, 1)
+	attachments, err = store.Attachments.ListByEmails(ctx, []string{email.ID, "00000000-0000-0000-0000-000000000000"})
+	require.NoError(t, err)
+	require.Len(t, attachments, 1)
+	assert.Equal(t, "b.pdf", attachments[0].Filename)
+}
+
+// TestAttachmentRepositoryText verifies extraction claims and that outcomes
+// are saved for all attachments with the same content
+func TestAttachmentRepositoryText(t *testing.T) {
+	store, _ := setupTestStore(t)
+	ctx := context.Background()
+	_, mailbox := createTestMailbox(t, store)
+
+	email := &models.Email{MailboxID: mailbox.ID, MessageID: "msg-1", SentAt: time.Now(), HasAttachments: true, FilePath: "/archive/msg-1.json"}
+	require.NoError(t, store.Emails.Create(ctx, email))
+	for _, name := range []string{"a.pdf", "copy-of-a.pdf"} {
+		attachment := &models.Attachment{EmailID: email.ID, Filename: name, SizeBytes: 10, SHA256Hash: "abc", FilePath: "/archive/abc"}
+		require.NoError(t, store.Attachments.Create(ctx, attachment))
+		assert.Equal(t, models.AttachmentTextPending, attachment.TextStatus)
+	}
+	_, err := store.Emails.MarkIndexed(ctx, []models.Email{*email}, time.Now())
+	require.NoError(t, err)
+
+	now := time.Now()
+	claimed, err := store.Attachments.ClaimPendingText(ctx, 1, now, now.Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	claimed, err = store.Attachments.ClaimPendingText(ctx, 10, now, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Len(t, claimed, 1, "claimed attachments are skipped")
+	claimed, err = store.Attachments.ClaimPendingText(ctx, 10, now.Add(2*time.Minute), now.Add(3*time.Minute))
+	require.NoError(t, err)
+	assert.Len(t, claimed, 2, "lapsed claims are taken over")
+
+	_, err = store.Attachments.FindExtractedByHash(ctx, "abc")
+	assert.ErrorIs(t, err, ErrNotFound)
+	text := "Signed copy"
+	saved, err := store.Attachments.SaveText(ctx, "abc", models.AttachmentText{Status: models.AttachmentTextExtracted, Text: &text}, now)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, saved)
+
+	done, err := store.Attachments.FindExtractedByHash(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, models.AttachmentTextExtracted, done.TextStatus)
+	assert.Equal(t, "Signed copy", *done.ExtractedText)
+	claimed, err = store.Attachments.ClaimPendingText(ctx, 10, now.Add(time.Hour), now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	// New text flags the email for indexing
+	pending, err := store.Emails.ListUnindexed(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, email.ID, pending[0].ID)
+}
+
+// TestAttachmentRepositoryReserveHash verifies stored content is recognized
+// per tenant once its first row commits
+func TestAttachmentRepositoryReserveHash(t *testing.T) {
+	store, _ := setupTestStore(t)
+	ctx := context.Background()
+	tenant, mailbox := createTestMailbox(t, store)
+	other := &models.Tenant{Name: "Globex", AzureTenantID: "22222222-2222-2222-2222-222222222222"}
+	require.NoError(t, store.Tenants.Create(ctx, other))
+
+	reserve := func(tenantID string) bool {
+		var stored bool
+		require.NoError(t, store.WithTx(ctx, func(repos *Repositories) error {
+			var err error
+			stored, err = repos.Attachments.ReserveHash(ctx, tenantID, "abc")
+			return err
+		}))
+		return stored
+	}
+	assert.False(t, reserve(tenant.ID))
+
+	email := &models.Email{MailboxID: mailbox.ID, MessageID: "msg-1", SentAt: time.Now(), HasAttachments: true, FilePath: "/archive/msg-1.json"}
+	require.NoError(t, store.Emails.Create(ctx, email))
+	require.NoError(t, store.Attachments.Create(ctx, &models.Attachment{EmailID: email.ID, Filename: "a.pdf", SizeBytes: 10, SHA256Hash: "abc", FilePath: "/archive/abc"}))
+	assert.True(t, reserve(tenant.ID))
+	assert.False(t, reserve(other.ID), "other tenants store their own copy")
+}
+
+// TestEmailRepositoryListFilters verifies tenant scoping, pagination and soft delete filtering
+func TestEmailRepositoryListFilters(t *testing.T) {
+	store, _ := setupTestStore(t)
+	ctx := context.Background()
+	tenant, mailbox := createTestMailbox(t, store)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		email := &models.Email{MailboxID: mailbox.ID, MessageID: "msg-" + string(rune('a'+i)), SentAt: base.AddDate(0, 0, i), FilePath: "/archive/x"}
+		require.NoError(t, store.Emails.Create(ctx, email))
+		ids = append(ids, email.ID)
+	}
+	require.NoError(t, store.Emails.SoftDelete(ctx, ids[0]))
+
+	page, err := store.Emails.List(ctx, EmailFilter{TenantID: &tenant.ID}, Pagination{Page: 1, Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, page.Total)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, ids[2], page.Items[0].ID, "newest email first")
+
+	after := base.AddDate(0, 0, 1)
+	page, err = store.Emails.List(ctx, EmailFilter{MailboxID: &mailbox.ID, SentAfter: &after, IncludeDeleted: true}, Pagination{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, page.Total)
+}
+
+// TestEmailRepositoryIndexing verifies indexed_at bookkeeping and the search deletion queue
+func TestEmailRepositoryIndexing(t *testing.T) {
+	store, _ := setupTestStore(t)
+	ctx := context.Background()
+	tenant, mailbox := createTestMailbox(t, store)
+
+	var ids []string
+	for _, messageID := range []string{"msg-a", "msg-b"} {
+		email := &models.Email{MailboxID: mailbox.ID, MessageID: messageID, SentAt: time.Now(), FilePath: "/archive/x",
+			Categories: []string{"Legal"}}
+		require.NoError(t, store.Emails.Create(ctx, email))
+		ids = append(ids, email.ID)
+	}
+
+	pending, err := store.Emails.ListUnindexed(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, []string{"Legal"}, pending[0].Categories)
+
+	// An email changed after it was read for indexing stays pending
+	require.NoError(t, store.Emails.SoftDelete(ctx, ids[1]))
+	marked, err := store.Emails.MarkIndexed(ctx, pending, time.Now())
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, marked)
+	pending, err = store.Emails.ListUnindexed(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, ids[1], pending[0].ID)
+	assert.NotNil(t, pending[0].DeletedAt)
+
+	reset, err := store.Emails.ResetIndexed(ctx, ReindexFilter{TenantID: &tenant.ID})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, reset)
+
+	require.NoError(t, store.Emails.Delete(ctx, ids[0]))
+	deleted, err := store.SearchDeletions.List(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{ids[0]}, deleted)
+	require.NoError(t, store.SearchDeletions.Delete(ctx, deleted))
+	deleted, err = store.SearchDeletions.List(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, deleted)
+}
+
+// TestStoreWithSessionScopesSearch verifies row-level security confines
+// searches to the session's tenant even without a tenant filter
+func TestStoreWithSessionScopesSearch(t *testing.T) {
+	store, _ := setupTestStore(t)
+	ctx := context.Background()
+	tenant, mailbox := createTestMailbox(t, store)
+	other := &models.Tenant{Name: "Globex", AzureTenantID: "22222222-2222-2222-2222-222222222222"}
+	require.NoError(t, store.Tenants.Create(ctx, other))
+	otherMailbox := &models.Mailbox{TenantID: other.ID, EmailAddress: "user@globex.test", MailboxType: models.MailboxTypeUser, SyncEnabled: true}
+	require.NoError(t, store.Mailboxes.Create(ctx, otherMailbox))
+	for _, mailboxID := range []string{mailbox.ID, otherMailbox.ID} {
+		subject := "Merger agreement"
+		require.NoError(t, store.Emails.Create(ctx, &models.Email{MailboxID: mailboxID, MessageID: "msg-" + mailboxID, Subject: &subject,
+			SentAt: time.Now(), FilePath: "/archive/x"}))
+	}
+	node, err := query.Parse("merger")
+	require.NoError(t, err)
+
+	var result *EmailSearchResult
+	session := database.Session{UserID: "00000000-0000-0000-0000-000000000001", TenantID: tenant.ID, Role: models.RoleTenantAdmin}
+	require.NoError(t, store.WithSession(ctx, session, func(repos *Repositories) error {
+		result, err = repos.Emails.Search(ctx, EmailSearch{Query: node, Limit: 10, MaxTotal: 1000})
+		return err
+	}))
+	require.Len(t, result.Hits, 1)
+	assert.Equal(t, tenant.ID, result.Hits[0].TenantID)
+}
+
+// TestEmailRepositorySearch verifies full-text matching, operators, ranking and highlights
+func TestEmailRepositorySearch(t *testing.T) {
+	store, _ := setupTestStore(t)
+	ctx := context.Background()
+	tenant, mailbox := createTestMailbox(t, store)
+
+	base := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	ids := map[string]string{}
+	add := func(messageID, subject, sender, body string, day int, labels []string) {
+		email := &models.Email{MailboxID: mailbox.ID, MessageID: messageID, Subject: &subject, Sender: &sender, BodyText: &body,
+			Recipients: []string{"legal@acme.test"}, SentAt: base.AddDate(0, 0, day), SizeBytes: 1000 * (day + 1), FilePath: "/archive/x",
+			Categories: labels}
+		require.NoError(t, store.Emails.Create(ctx, email))
+		ids[messageID] = email.ID
+	}
+	add("msg-a", "Merger agreement", "ceo@acme.test", "Please review the merger draft before Friday.", 0, []string{"Legal"})
+	add("msg-b", "Lunch", "bob@mail.vendor.test", "The merger is not on the menu.", 1, nil)
+	add("msg-c", "Invoice March", "billing@vendor.test", "Quarterly numbers attached.", 2, []string{"Finance"})
+	require.NoError(t, store.Attachments.Create(ctx, &models.Attachment{EmailID: ids["msg-c"], Filename: "invoice-march.pdf",
+		SizeBytes: 10, SHA256Hash: "abc", FilePath: "/archive/abc"}))
+	text := "Bank reconciliation for the vendor account."
+	_, err := store.Attachments.SaveText(ctx, "abc", models.AttachmentText{Status: models.AttachmentTextExtracted, Text: &text}, time.Now())
+	require.NoError(t, err)
+
+	search := func(q string) *EmailSearchResult {
+		t.Helper()
+		node, err := query.Parse(q)
+		require.NoError(t, err, q)
+		result, err := store.Emails.Search(ctx, EmailSearch{Filter: EmailFilter{TenantID: &tenant.ID}, Query: node, Facets: []string{"sender"},
+			Limit: 10, MaxTotal: 1000, HighlightPreTag: "<mark>", HighlightPostTag: "</mark>", SnippetWords: 30})
+		require.NoError(t, err, q)
+		return result
+	}
+	hitIDs := func(result *EmailSearchResult) []string {
+		var found []string
+		for _, hit := range result.Hits {
+			found = append(found, hit.Email.ID)
+		}
+		return found
+	}
+
+	result := search("merger")
+	assert.EqualValues(t, 2, result.Total)
+	assert.Equal(t, []string{ids["msg-a"], ids["msg-b"]}, hitIDs(result), "subject matches rank first")
+	assert.Equal(t, "<mark>Merger</mark> agreement", result.Hits[0].Subject)
+	assert.Contains(t, result.Hits[0].Snippet, "<mark>merger</mark>")
+	assert.Equal(t, tenant.ID, result.Hits[0].TenantID)
+	assert.Equal(t, map[string]int64{"ceo@acme.test": 1, "bob@mail.vendor.test": 1}, result.Facets["sender"])
+
+	for q, want := range map[string][]string{
+		`"merger draft"`:                 {"msg-a"},
+		`merger -label:legal`:            {"msg-b"},
+		`from:vendor.test`:               {"msg-c", "msg-b"},
+		`from:ceo@ OR to:@acme.test`:     {"msg-c", "msg-b", "msg-a"},
+		`subject:"march invoice"`:        {"msg-c"},
+		`filename:invoice filename:pdf`:  {"msg-c"},
+		`larger:1500 before:2025-03-03`:  {"msg-b"},
+		`label:finance smaller:5000`:     {"msg-c"},
+		`quarterly (label:a OR -from:x)`: {"msg-c"},
+	} {
+		var wantIDs []string
+		for _, messageID := range want {
+			wantIDs = append(wantIDs, ids[messageID])
+		}
+		assert.Equal(t, wantIDs, hitIDs(search(q)), q)
+	}
+
+	// Attachment text matches its email and names the attachment
+	result = search("reconciliation")
+	assert.Equal(t, []string{ids["msg-c"]}, hitIDs(result))
+	require.Len(t, result.Hits[0].Attachments, 1)
+	assert.Equal(t, "invoice-march.pdf", result.Hits[0].Attachments[0].Filename)
+	assert.Contains(t, result.Hits[0].Attachments[0].Snippet, "<mark>reconciliation</mark>")
+	assert.Empty(t, search("merger").Hits[0].Attachments)
+
+	// Without free text results are newest first with the body's beginning as snippet
+	result = search("")
+	assert.EqualValues(t, 3, result.Total)
+	assert.Equal(t, ids["msg-c"], result.Hits[0].Email.ID)
+	assert.Equal(t, "Quarterly numbers attached.", result.Hits[0].Snippet)
+}
+
+// TestThreadRepositoryAssign verifies emails are threaded by shared keys,
+// threads merge when an email links them and collapsed searches return one
+// hit per thread
+func TestThreadRepositoryAssign(t *testing.T) {
+	store, _ := setupTestStore(t)
+	ctx := context.Background()
+	tenant, mailbox := createTestMailbox(t, store)
+	shared := &models.Mailbox{TenantID: tenant.ID, EmailAddress: "shared@acme.test", MailboxType: models.MailboxTypeShared, SyncEnabled: true}
+	require.NoError(t, store.Mailboxes.Create(ctx, shared))
+
+	base := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	assign := func(mailboxID, graphID, messageID, subject string, day int, keys ...string) *models.Email {
+		t.Helper()
+		email := &models.Email{MailboxID: mailboxID, MessageID: graphID, InternetMessageID: &messageID, Subject: &subject,
+			SentAt: base.AddDate(0, 0, day), FilePath: "/archive/x"}
+		require.NoError(t, store.WithTx(ctx, func(repos *Repositories) error {
+			if err := repos.Emails.Create(ctx, email); err != nil {
+				return err
+			}
+			threadID, err := repos.Threads.Assign(ctx, tenant.ID, email.ID, append([]string{"mid:" + messageID}, keys...))
+			email.ThreadID = &threadID
+			return err
+		}))
+		return email
+	}
+	root := assign(mailbox.ID, "g1", "root@x", "Merger", 0)
+	copied := assign(shared.ID, "g2", "root@x", "Merger", 0)
+	other := assign(mailbox.ID, "g3", "other@x", "RE: Merger", 2, "mid:lost@x")
+	assert.Equal(t, *root.ThreadID, *copied.ThreadID, "copies share the thread")
+	assert.NotEqual(t, *root.ThreadID, *other.ThreadID)
+
+	// A reply referencing both merges the threads into the older one
+	reply := assign(shared.ID, "g4", "reply@x", "RE: Merger", 3, "mid:root@x", "mid:lost@x")
+	assert.Equal(t, *root.ThreadID, *reply.ThreadID)
+	_, err := store.Threads.GetByID(ctx, *other.ThreadID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	thread, err := store.Threads.GetByID(ctx, *root.ThreadID)
+	require.NoError(t, err)
+	assert.Equal(t, "Merger", *thread.Subject)
+	assert.Equal(t, 3, thread.MessageCount, "copies count once")
+	assert.Equal(t, base.AddDate(0, 0, 3), thread.LastSentAt.UTC())
+
+	emails, err := store.Threads.ListEmails(ctx, thread.ID, EmailFilter{MailboxID: &mailbox.ID}, 10)
+	require.NoError(t, err)
+	require.Len(t, emails, 2)
+	assert.Equal(t, root.ID, emails[0].ID)
+	counts, err := store.Threads.CountMessages(ctx, []string{thread.ID}, EmailFilter{MailboxID: &shared.ID})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{thread.ID: 2}, counts)
+
+	result, err := store.Emails.Search(ctx, EmailSearch{Filter: EmailFilter{TenantID: &tenant.ID}, Collapse: true, Sort: "newest", Limit: 10, MaxTotal: 1000})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, result.Total)
+	require.Len(t, result.Hits, 1)
+	assert.Equal(t, reply.ID, result.Hits[0].Email.ID)
+	assert.Equal(t, thread.ID, *result.Hits[0].Email.ThreadID)
+}
+
+// TestMessageBlobRepositoryUpsert verifies copies of a message in several
+// mailboxes share one blob, while a mailbox holds a Graph message ID once
+func TestMessageBlobRepositoryUpsert(t *testing.T) {
+	store, _ := setupTestStore(t)
+	ctx := context.Background()
+	tenant, mailbox := createTestMailbox(t, store)
+	shared := &models.Mailbox{TenantID: tenant.ID, EmailAddress: "shared@acme.test", MailboxType: models.MailboxTypeShared, SyncEnabled: true}
+	require.NoError(t, store.Mailboxes.Create(ctx, shared))
+
+	blob := &models.MessageBlob{TenantID: tenant.ID, SHA256Hash: "abc123", FilePath: "file:///archive/first.eml", SizeBytes: 42}
+	created, err := store.MessageBlobs.Upsert(ctx, blob)
+	require.NoError(t, err)
+	assert.True(t, created)
+	again := &models.MessageBlob{TenantID: tenant.ID, SHA256Hash: "abc123", FilePath: "s3://archive/second.eml", SizeBytes: 42}
+	created, err = store.MessageBlobs.Upsert(ctx, again)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, blob.ID, again.ID)
+	assert.Equal(t, "file:///archive/first.eml", again.FilePath, "the first copy's file is kept")
+
+	for _, mailboxID := range []string{mailbox.ID, shared.ID} {
+		email := &models.Email{MailboxID: mailboxID, MessageID: "AAMk1", SentAt: time.Now(), FilePath: blob.FilePath, BlobID: &blob.ID}
+		require.NoError(t, store.Emails.Create(ctx, email))
+		assert.Equal(t, models.FlagStatusNotFlagged, email.FlagStatus)
+	}
+	duplicate := &models.Email{MailboxID: mailbox.ID, MessageID: "AAMk1", SentAt: time.Now(), FilePath: blob.FilePath}
+	assert.ErrorIs(t, store.Emails.Create(ctx, duplicate), ErrConflict)
+
+	refs, err := store.MessageBlobs.CountReferences(ctx, blob.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, refs)
+	found, err := store.MessageBlobs.GetByID(ctx, blob.ID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 42, found.SizeBytes)
+
+	require.NoError(t, store.Mailboxes.AddUsage(ctx, shared.ID, 1, 42, 0))
+	require.NoError(t, store.Tenants.AddStorageBytes(ctx, tenant.ID, 42, 0))
+	usage, err := store.Mailboxes.GetByID(ctx, shared.ID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 42, usage.LogicalStorageBytes)
+	assert.Zero(t, usage.StorageBytes)
+}
+
+// TestFolderRepositoryDeltaState verifies upserts keep sync state and delta links can be reset
+func TestFolderRepositoryDeltaState(t *testing.T) {
+	store, _ := setupTestStore(t)
+	ctx := context.Background()
+	_, mailbox := createTestMailbox(t, store)
+
+	name := "Inbox"
+	folder := &models.MailboxFolder{MailboxID: mailbox.ID, FolderID: "AAMkInbox", DisplayName: &name}
+	require.NoError(t, store.Folders.Upsert(ctx, folder))
+	assert.Nil(t, folder.DeltaLink)
+
+	syncedAt := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, store.Folders.CommitDelta(ctx, folder.ID, "https://graph/delta?$deltatoken=1", 3, syncedAt))
+
+	renamed := "Posteingang"
+	again := &models.MailboxFolder{MailboxID: mailbox.ID, FolderID: "AAMkInbox", DisplayName: &renamed}
+	require.NoError(t, store.Folders.Upsert(ctx, again))
+	assert.Equal(t, folder.ID, again.ID)
+	require.NotNil(t, again.DeltaLink)
+	assert.Equal(t, 3, again.EmailCount)
+	assert.Equal(t, "Posteingang", *again.DisplayName)
+
+	require.NoError(t, store.Folders.ResetDelta(ctx, folder.ID))
+	folders, err := store.Folders.ListByMailbox(ctx, mailbox.ID)
+	require.NoError(t, err)
+	require.Len(t, folders, 1)
+	assert.Nil(t, folders[0].DeltaLink)
+	assert.ErrorIs(t, store.Folders.ResetDelta(ctx, "00000000-0000-0000-0000-000000000000"), ErrNotFound)
+}
+
+// TestMailboxRepositoryScheduleOverrides verifies overrides split mailboxes between schedules
+func TestMailboxRepositoryScheduleOverrides(t *testing.T) {
+	store, _ := setupTestStore(t)
+	ctx := context.Background()
+	tenant, inheriting := createTestMailbox(t, store)
+
+	hourly := "0 * * * *"
+	own := &models.Mailbox{TenantID: tenant.ID, EmailAddress: "ceo@acme.test", MailboxType: models.MailboxTypeUser, SyncEnabled: true, SyncSchedule: &hourly}
+	require.NoError(t, store.Mailboxes.Create(ctx, own))
+
+	scheduled, err := store.Mailboxes.ListScheduled(ctx)
+	require.NoError(t, err)
+	require.Len(t, scheduled, 1)
+	assert.Equal(t, own.ID, scheduled[0].ID)
+
+	global, err := store.Mailboxes.ListInheritingSchedule(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, global, 1)
+	assert.Equal(t, inheriting.ID, global[0].ID)
+
+	// A tenant override takes its mailboxes off the global schedule
+	nightly, zone := "0 2 * * *", "Europe/Berlin"
+	tenant.SyncSchedule, tenant.SyncTimezone = &nightly, &zone
+	require.NoError(t, store.Tenants.Update(ctx, tenant))
+	tenants, err := store.Tenants.ListScheduled(ctx)
+	require.NoError(t, err)
+	require.Len(t, tenants, 1)
+	assert.Equal(t, zone, *tenants[0].SyncTimezone)
+
+	global, err = store.Mailboxes.ListInheritingSchedule(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, global)
+	ofTenant, err := store.Mailboxes.ListInheritingSchedule(ctx, &tenant.ID)
+	require.NoError(t, err)
+	require.Len(t, ofTenant, 1)
+	assert.Equal(t, inheriting.ID, ofTenant[0].ID)
+
+	// A zone without a schedule is rejected
+	inheriting.SyncTimezone = &zone
+	assert.ErrorIs(t, store.Mailboxes.Update(ctx, inheriting), ErrConstraintViolation)
+}
+
+// TestMailboxRepositoryUpdateLastSync verifies a sync only records its time
+func TestMailboxRepositoryUpdateLastSync(t *testing.T) {
+	store, _ := setupTestStore(t)
+	ctx := context.Background()
+	_, mailbox := createTestMailbox(t, store)
+
+	syncedAt := time.Date(2025, 11, 30, 8, 0, 0, 0, time.UTC)
+	require.NoError(t, store.Mailboxes.UpdateLastSync(ctx, mailbox.ID, syncedAt))
+
+	got, err := store.Mailboxes.GetByID(ctx, mailbox.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.LastSyncAt)
+	assert.True(t, syncedAt.Equal(*got.LastSyncAt))
+	assert.Nil(t, got.LastDeltaToken)
+
+	assert.ErrorIs(t, store.Mailboxes.UpdateLastSync(ctx, "00000000-0000-0000-0000-000000000000", syncedAt), ErrNotFound)
+}
+
+// TestJobRepositoryAttempts verifies attempt history, retry transitions and cancellation
+func TestJobRepositoryAttempts(t *testing.T) {
+	store, _ := setupTestStore(t)
+	ctx := context.Background()
+	_, mailbox := createTestMailbox(t, store)
+
+	job := &models.Job{Type: models.JobTypeSyncMailbox, Status: models.JobStatusQueued, MailboxID: &mailbox.ID, MaxAttempts: 3}
+	require.NoError(t, store.Jobs.Create(ctx, job))
+	require.NoError(t, store.Jobs.MarkRunning(ctx, job.ID))
+
+	first, err := store.Jobs.StartAttempt(ctx, job.ID, "worker-a")
+	require.NoError(t, err)
+	// worker-a crashed; its attempt is interrupted by the next one
+	second, err := store.Jobs.StartAttempt(ctx, job.ID, "worker-b")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, []int{first, second})
+	message := "graph unavailable"
+	require.NoError(t, store.Jobs.FinishAttempt(ctx, job.ID, second, models.JobAttemptFailed, &message))
+
+	attempts, err := store.Jobs.ListAttempts(ctx, job.ID)
+	require.NoError(t, err)
+	require.Len(t, attempts, 2)
+	assert.Equal(t, models.JobAttemptInterrupted, attempts[0].Status)
+	assert.Equal(t, models.JobAttemptFailed, attempts[1].Status)
+
+	require.NoError(t, store.Jobs.MarkRetrying(ctx, job.ID, message, time.Now().Add(time.Minute)))
+	require.NoError(t, store.Jobs.Resume(ctx, job.ID))
+	assert.ErrorIs(t, store.Jobs.Resume(ctx, job.ID), ErrNotFound)
+
+	status, err := store.Jobs.RequestCancel(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusCancelled, status)
+	_, err = store.Jobs.RequestCancel(ctx, job.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.Jobs.MarkRunning(ctx, job.ID), ErrNotFound)
+
+	cancelled, err := store.Jobs.GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cancelled.Attempts)
+	assert.NotNil(t, cancelled.CancelRequestedAt)
+
+	retry := &models.Job{Type: job.Type, Status: models.JobStatusQueued, MailboxID: &mailbox.ID, RetryOf: &job.ID}
+	require.NoError(t, store.Jobs.Create(ctx, retry))
+	stored, err := store.Jobs.GetByID(ctx, retry.ID)
+	require.NoError(t, err)
+	assert.Equal(t, job.ID, *stored.RetryOf)
+	assert.Equal(t, 1, stored.MaxAttempts)
+}
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"ironarchive/internal/models"
+)
+
+// ScheduleRunRepository provides access to the schedule_runs table
+type ScheduleRunRepository interface {
+	Record(ctx context.Context, run *models.ScheduleRun) (bool, error)
+	SetJob(ctx context.Context, id, jobID string) error
+	Delete(ctx context.Context, id string) error
+	Latest(ctx context.Context, scheduleKey string) (*models.ScheduleRun, error)
+	List(ctx context.Context, scheduleKey, status string, page Pagination) (Page[models.ScheduleRun], error)
+}
+
+const scheduleRunColumns = `id, schedule_key, scheduled_at, status, job_id, COALESCE(created_at, CURRENT_TIMESTAMP)`
+
+type scheduleRunRepository struct {
+	db DBTX
+}
+
+// NewScheduleRunRepository creates a schedule run repository
+func NewScheduleRunRepository(db DBTX) ScheduleRunRepository {
+	return &scheduleRunRepository{db: db}
+}
+
+func scanScheduleRun(row rowScanner) (models.ScheduleRun, error) {
+	var r models.ScheduleRun
+	err := row.Scan(&r.ID, &r.ScheduleKey, &r.ScheduledAt, &r.Status, &r.JobID, &r.CreatedAt)
+	r.ScheduledAt = r.ScheduledAt.UTC()
+	return r, mapError(err)
+}
+
+// Record inserts a tick unless it was already recorded, e.g. by another
+// replica, and reports whether this call created it
+func (r *scheduleRunRepository) Record(ctx context.Context, run *models.ScheduleRun) (bool, error) {
+	query := `
+		INSERT INTO schedule_runs (schedule_key, scheduled_at, status, job_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (schedule_key, scheduled_at) DO NOTHING
+		RETURNING ` + scheduleRunColumns
+	recorded, err := scanScheduleRun(r.db.QueryRow(ctx, query, run.ScheduleKey, run.ScheduledAt.UTC(), run.Status, run.JobID))
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	*run = recorded
+	return true, nil
+}
+
+// SetJob links a tick to the job it enqueued
+func (r *scheduleRunRepository) SetJob(ctx context.Context, id, jobID string) error {
+	return affectOne(r.db.Exec(ctx, "UPDATE schedule_runs SET job_id = $2 WHERE id = $1", id, jobID))
+}
+
+// Delete removes a tick, e.g. one whose job could not be enqueued, so that
+// it is fired again
+func (r *scheduleRunRepository) Delete(ctx context.Context, id string) error {
+	return affectOne(r.db.Exec(ctx, "DELETE FROM schedule_runs WHERE id = $1", id))
+}
+
+// Latest returns the most recent recorded tick of a schedule
+func (r *scheduleRunRepository) Latest(ctx context.Context, scheduleKey string) (*models.ScheduleRun, error) {
+	query := "SELECT " + scheduleRunColumns + " FROM schedule_runs WHERE schedule_key = $1 ORDER BY scheduled_at DESC LIMIT 1"
+	run, err := scanScheduleRun(r.db.QueryRow(ctx, query, scheduleKey))
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// List returns a page of a schedule's ticks, newest first, optionally by status
+func (r *scheduleRunRepository) List(ctx context.Context, scheduleKey, status string, page Pagination) (Page[models.ScheduleRun], error) {
+	w := &whereBuilder{}
+	w.add("schedule_key = ?", scheduleKey)
+	if status != "" {
+		w.add("status = ?", status)
+	}
+	return listPage(ctx, r.db, "schedule_runs", scheduleRunColumns, "scheduled_at DESC", w, page, scanScheduleRun)
+}
@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"context"
+)
+
+// SearchDeletionRepository provides access to the search_deletions queue of
+// deleted emails whose search documents still need to be removed
+type SearchDeletionRepository interface {
+	List(ctx context.Context, limit int) ([]string, error)
+	Delete(ctx context.Context, emailIDs []string) error
+}
+
+type searchDeletionRepository struct {
+	db DBTX
+}
+
+// NewSearchDeletionRepository creates a search deletion repository
+func NewSearchDeletionRepository(db DBTX) SearchDeletionRepository {
+	return &searchDeletionRepository{db: db}
+}
+
+// List returns up to limit queued email IDs, oldest deletion first
+func (r *searchDeletionRepository) List(ctx context.Context, limit int) ([]string, error) {
+	w := &whereBuilder{}
+	return listAll(ctx, r.db, "search_deletions", "email_id", "deleted_at, email_id LIMIT "+w.arg(limit), w, func(row rowScanner) (string, error) {
+		var id string
+		return id, mapError(row.Scan(&id))
+	})
+}
+
+// Delete removes email IDs whose documents were removed from the index
+func (r *searchDeletionRepository) Delete(ctx context.Context, emailIDs []string) error {
+	_, err := r.db.Exec(ctx, "DELETE FROM search_deletions WHERE email_id = ANY($1::uuid[])", emailIDs)
+	return mapError(err)
+}
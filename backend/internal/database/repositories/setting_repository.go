@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"ironarchive/internal/models"
+)
+
+// SettingRepository provides access to the global settings key-value table
+type SettingRepository interface {
+	Get(ctx context.Context, key string) (*models.Setting, error)
+	GetValue(ctx context.Context, key string, dest any) error
+	List(ctx context.Context) ([]models.Setting, error)
+	Set(ctx context.Context, key string, value any) error
+	Delete(ctx context.Context, key string) error
+}
+
+type settingRepository struct {
+	db DBTX
+}
+
+// NewSettingRepository creates a setting repository
+func NewSettingRepository(db DBTX) SettingRepository {
+	return &settingRepository{db: db}
+}
+
+func scanSetting(row rowScanner) (models.Setting, error) {
+	var s models.Setting
+	var value []byte
+	err := row.Scan(&s.Key, &value, &s.UpdatedAt)
+	s.Value = json.RawMessage(value)
+	return s, mapError(err)
+}
+
+// Get returns a setting by key
+func (r *settingRepository) Get(ctx context.Context, key string) (*models.Setting, error) {
+	s, err := scanSetting(r.db.QueryRow(ctx, "SELECT key, value, COALESCE(updated_at, CURRENT_TIMESTAMP) FROM settings WHERE key = $1", key))
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetValue decodes a setting's JSON value into dest
+func (r *settingRepository) GetValue(ctx context.Context, key string, dest any) error {
+	s, err := r.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(s.Value, dest); err != nil {
+		return fmt.Errorf("failed to decode setting %s: %w", key, err)
+	}
+	return nil
+}
+
+// List returns all settings ordered by key
+func (r *settingRepository) List(ctx context.Context) ([]models.Setting, error) {
+	rows, err := r.db.Query(ctx, "SELECT key, value, COALESCE(updated_at, CURRENT_TIMESTAMP) FROM settings ORDER BY key")
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	settings := []models.Setting{}
+	for rows.Next() {
+		s, err := scanSetting(rows)
+		if err != nil {
+			return nil, err
+		}
+		settings = append(settings, s)
+	}
+	return settings, mapError(rows.Err())
+}
+
+// Set creates or replaces a setting; value is encoded as JSON
+func (r *settingRepository) Set(ctx context.Context, key string, value any) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode setting %s: %w", key, err)
+	}
+	query := `
+		INSERT INTO settings (key, value, updated_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at
+	`
+	_, err = r.db.Exec(ctx, query, key, json.RawMessage(encoded))
+	return mapError(err)
+}
+
+// Delete removes a setting
+func (r *settingRepository) Delete(ctx context.Context, key string) error {
+	return affectOne(r.db.Exec(ctx, "DELETE FROM settings WHERE key = $1", key))
+}
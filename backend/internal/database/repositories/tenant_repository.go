@@ -0,0 +1,199 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+
+	"ironarchive/internal/models"
+)
+
+// TenantFilter narrows tenant listings
+type TenantFilter struct {
+	// TenantID restricts results to a single tenant (TENANT_ADMIN scoping)
+	TenantID *string
+	// Search matches the tenant name case-insensitively
+	Search string
+	// LegalHold filters by legal hold state when set
+	LegalHold *bool
+}
+
+// TenantRepository provides access to the tenants table
+type TenantRepository interface {
+	Create(ctx context.Context, tenant *models.Tenant) error
+	GetByID(ctx context.Context, id string) (*models.Tenant, error)
+	GetByAzureTenantID(ctx context.Context, azureTenantID string) (*models.Tenant, error)
+	List(ctx context.Context, filter TenantFilter, page Pagination) (Page[models.Tenant], error)
+	Update(ctx context.Context, tenant *models.Tenant) error
+	UpdateCredentials(ctx context.Context, id, credentials string) error
+	LockCredentials(ctx context.Context, id string) (string, error)
+	ListIDs(ctx context.Context) ([]string, error)
+	ListScheduled(ctx context.Context) ([]models.Tenant, error)
+	AddStorageBytes(ctx context.Context, id string, logicalDelta, physicalDelta int64) error
+	Delete(ctx context.Context, id string) error
+}
+
+const tenantColumns = `id, name, azure_tenant_id, azure_app_credentials,
+	COALESCE(retention_policy_days, 2555), COALESCE(legal_hold, FALSE), whitelabel_config,
+	COALESCE(storage_bytes, 0), logical_storage_bytes, sync_schedule, sync_timezone, COALESCE(created_at, CURRENT_TIMESTAMP)`
+
+type tenantRepository struct {
+	db DBTX
+}
+
+// NewTenantRepository creates a tenant repository
+func NewTenantRepository(db DBTX) TenantRepository {
+	return &tenantRepository{db: db}
+}
+
+func scanTenant(row rowScanner) (models.Tenant, error) {
+	var t models.Tenant
+	var whitelabel []byte
+	err := row.Scan(
+		&t.ID,
+		&t.Name,
+		&t.AzureTenantID,
+		&t.AzureAppCredentials,
+		&t.RetentionPolicyDays,
+		&t.LegalHold,
+		&whitelabel,
+		&t.StorageBytes,
+		&t.LogicalStorageBytes,
+		&t.SyncSchedule,
+		&t.SyncTimezone,
+		&t.CreatedAt,
+	)
+	if whitelabel != nil {
+		t.WhitelabelConfig = json.RawMessage(whitelabel)
+	}
+	return t, mapError(err)
+}
+
+// Create inserts a tenant and populates its generated fields
+func (r *tenantRepository) Create(ctx context.Context, tenant *models.Tenant) error {
+	query := `
+		INSERT INTO tenants (name, azure_tenant_id, azure_app_credentials, retention_policy_days, legal_hold, whitelabel_config, sync_schedule, sync_timezone)
+		VALUES ($1, $2, $3, COALESCE($4, 2555), $5, $6, $7, $8)
+		RETURNING id, retention_policy_days, storage_bytes, logical_storage_bytes, created_at
+	`
+	var retention *int
+	if tenant.RetentionPolicyDays > 0 {
+		retention = &tenant.RetentionPolicyDays
+	}
+	err := r.db.QueryRow(ctx, query,
+		tenant.Name,
+		tenant.AzureTenantID,
+		tenant.AzureAppCredentials,
+		retention,
+		tenant.LegalHold,
+		tenant.WhitelabelConfig,
+		tenant.SyncSchedule,
+		tenant.SyncTimezone,
+	).Scan(&tenant.ID, &tenant.RetentionPolicyDays, &tenant.StorageBytes, &tenant.LogicalStorageBytes, &tenant.CreatedAt)
+	return mapError(err)
+}
+
+// GetByID returns a tenant by primary key
+func (r *tenantRepository) GetByID(ctx context.Context, id string) (*models.Tenant, error) {
+	t, err := scanTenant(r.db.QueryRow(ctx, "SELECT "+tenantColumns+" FROM tenants WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetByAzureTenantID returns a tenant by its Microsoft 365 tenant ID
+func (r *tenantRepository) GetByAzureTenantID(ctx context.Context, azureTenantID string) (*models.Tenant, error) {
+	t, err := scanTenant(r.db.QueryRow(ctx, "SELECT "+tenantColumns+" FROM tenants WHERE azure_tenant_id = $1", azureTenantID))
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// List returns a page of tenants ordered by name
+func (r *tenantRepository) List(ctx context.Context, filter TenantFilter, page Pagination) (Page[models.Tenant], error) {
+	w := &whereBuilder{}
+	if filter.TenantID != nil {
+		w.add("id = ?", *filter.TenantID)
+	}
+	if filter.Search != "" {
+		w.add("name ILIKE ?", "%"+escapeLike(filter.Search)+"%")
+	}
+	if filter.LegalHold != nil {
+		w.add("COALESCE(legal_hold, FALSE) = ?", *filter.LegalHold)
+	}
+	return listPage(ctx, r.db, "tenants", tenantColumns, "name, id", w, page, scanTenant)
+}
+
+// Update saves the mutable tenant fields (credentials are updated separately)
+func (r *tenantRepository) Update(ctx context.Context, tenant *models.Tenant) error {
+	query := `
+		UPDATE tenants
+		SET name = $2, retention_policy_days = $3, legal_hold = $4, whitelabel_config = $5,
+			sync_schedule = $6, sync_timezone = $7
+		WHERE id = $1
+	`
+	return affectOne(r.db.Exec(ctx, query,
+		tenant.ID,
+		tenant.Name,
+		tenant.RetentionPolicyDays,
+		tenant.LegalHold,
+		tenant.WhitelabelConfig,
+		tenant.SyncSchedule,
+		tenant.SyncTimezone,
+	))
+}
+
+// UpdateCredentials replaces the stored (already encrypted) app credentials
+func (r *tenantRepository) UpdateCredentials(ctx context.Context, id, credentials string) error {
+	return affectOne(r.db.Exec(ctx, "UPDATE tenants SET azure_app_credentials = $2 WHERE id = $1", id, credentials))
+}
+
+// LockCredentials returns the stored credentials and locks the tenant row
+// until the surrounding transaction ends
+func (r *tenantRepository) LockCredentials(ctx context.Context, id string) (string, error) {
+	var credentials string
+	err := r.db.QueryRow(ctx, "SELECT azure_app_credentials FROM tenants WHERE id = $1 FOR UPDATE", id).Scan(&credentials)
+	return credentials, mapError(err)
+}
+
+// ListIDs returns the IDs of all tenants in creation order
+func (r *tenantRepository) ListIDs(ctx context.Context) ([]string, error) {
+	rows, err := r.db.Query(ctx, "SELECT id FROM tenants ORDER BY created_at, id")
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, mapError(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, mapError(rows.Err())
+}
+
+// ListScheduled returns the tenants with their own sync schedule
+func (r *tenantRepository) ListScheduled(ctx context.Context) ([]models.Tenant, error) {
+	w := &whereBuilder{}
+	w.add("sync_schedule IS NOT NULL")
+	return listAll(ctx, r.db, "tenants", tenantColumns, "id", w, scanTenant)
+}
+
+// AddStorageBytes adjusts the tenant's logical and physical storage counters
+func (r *tenantRepository) AddStorageBytes(ctx context.Context, id string, logicalDelta, physicalDelta int64) error {
+	query := `
+		UPDATE tenants
+		SET logical_storage_bytes = logical_storage_bytes + $2, storage_bytes = COALESCE(storage_bytes, 0) + $3
+		WHERE id = $1
+	`
+	return affectOne(r.db.Exec(ctx, query, id, logicalDelta, physicalDelta))
+}
+
+// Delete removes a tenant and cascades to its users, mailboxes and jobs
+func (r *tenantRepository) Delete(ctx context.Context, id string) error {
+	return affectOne(r.db.Exec(ctx, "DELETE FROM tenants WHERE id = $1", id))
+}
@@ -0,0 +1,184 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"ironarchive/internal/models"
+)
+
+// ThreadRepository provides access to the threads and thread_keys tables
+type ThreadRepository interface {
+	Assign(ctx context.Context, tenantID, emailID string, keys []string) (string, error)
+	GetByID(ctx context.Context, id string) (*models.Thread, error)
+	ListEmails(ctx context.Context, threadID string, filter EmailFilter, limit int) ([]models.Email, error)
+	CountMessages(ctx context.Context, threadIDs []string, filter EmailFilter) (map[string]int, error)
+}
+
+const threadColumns = `id, tenant_id, subject, message_count, first_sent_at, last_sent_at,
+	COALESCE(created_at, CURRENT_TIMESTAMP), COALESCE(updated_at, created_at, CURRENT_TIMESTAMP)`
+
+// threadMessageKey identifies a message across the mailboxes it was archived
+// in; emails without a Message-ID count on their own
+const threadMessageKey = "COALESCE(internet_message_id, id::text)"
+
+type threadRepository struct {
+	db DBTX
+}
+
+// NewThreadRepository creates a thread repository
+func NewThreadRepository(db DBTX) ThreadRepository {
+	return &threadRepository{db: db}
+}
+
+func scanThread(row rowScanner) (models.Thread, error) {
+	var t models.Thread
+	err := row.Scan(
+		&t.ID,
+		&t.TenantID,
+		&t.Subject,
+		&t.MessageCount,
+		&t.FirstSentAt,
+		&t.LastSentAt,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+	)
+	return t, mapError(err)
+}
+
+// Assign puts an email into the thread of a tenant holding any of its keys
+// and returns the thread ID. A new thread is created when no key is known;
+// when the keys span several threads they are merged into the oldest. Assign
+// must run in a transaction: it serializes with other assignments of the
+// tenant until the transaction ends.
+func (r *threadRepository) Assign(ctx context.Context, tenantID, emailID string, keys []string) (string, error) {
+	if _, err := r.db.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('threads:' || $1))", tenantID); err != nil {
+		return "", fmt.Errorf("failed to lock threads: %w", mapError(err))
+	}
+
+	// Threads holding a key, and the current thread of the email, oldest first
+	query := `
+		SELECT id FROM threads
+		WHERE tenant_id = $1 AND (
+			id IN (SELECT thread_id FROM thread_keys WHERE tenant_id = $1 AND key = ANY($2))
+			OR id = (SELECT thread_id FROM emails WHERE id = $3)
+		)
+		ORDER BY created_at, id
+	`
+	rows, err := r.db.Query(ctx, query, tenantID, keys, emailID)
+	if err != nil {
+		return "", mapError(err)
+	}
+	var threadIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return "", mapError(err)
+		}
+		threadIDs = append(threadIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", mapError(err)
+	}
+
+	var threadID string
+	if len(threadIDs) == 0 {
+		if err := r.db.QueryRow(ctx, "INSERT INTO threads (tenant_id) VALUES ($1) RETURNING id", tenantID).Scan(&threadID); err != nil {
+			return "", mapError(err)
+		}
+	} else {
+		threadID = threadIDs[0]
+		if merged := threadIDs[1:]; len(merged) > 0 {
+			if err := r.merge(ctx, threadID, merged); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if _, err := r.db.Exec(ctx, `
+		INSERT INTO thread_keys (tenant_id, key, thread_id)
+		SELECT $1, key, $3 FROM unnest($2::text[]) AS key
+		ON CONFLICT DO NOTHING
+	`, tenantID, keys, threadID); err != nil {
+		return "", mapError(err)
+	}
+	if err := affectOne(r.db.Exec(ctx, "UPDATE emails SET thread_id = $2 WHERE id = $1", emailID, threadID)); err != nil {
+		return "", err
+	}
+	return threadID, r.refresh(ctx, threadID)
+}
+
+// merge moves the emails and keys of threads into threadID and deletes them
+func (r *threadRepository) merge(ctx context.Context, threadID string, merged []string) error {
+	for _, query := range []string{
+		"UPDATE emails SET thread_id = $1 WHERE thread_id = ANY($2)",
+		"UPDATE thread_keys SET thread_id = $1 WHERE thread_id = ANY($2)",
+		"DELETE FROM threads WHERE id = ANY($2) AND id <> $1",
+	} {
+		if _, err := r.db.Exec(ctx, query, threadID, merged); err != nil {
+			return fmt.Errorf("failed to merge threads: %w", mapError(err))
+		}
+	}
+	return nil
+}
+
+// refresh recomputes the subject, message count and time span of a thread
+func (r *threadRepository) refresh(ctx context.Context, threadID string) error {
+	query := `
+		UPDATE threads SET
+			subject = (SELECT subject FROM emails WHERE thread_id = $1 ORDER BY sent_at, id LIMIT 1),
+			message_count = stats.message_count,
+			first_sent_at = stats.first_sent_at,
+			last_sent_at = stats.last_sent_at,
+			updated_at = CURRENT_TIMESTAMP
+		FROM (
+			SELECT COUNT(DISTINCT ` + threadMessageKey + `) AS message_count, MIN(sent_at) AS first_sent_at, MAX(sent_at) AS last_sent_at
+			FROM emails WHERE thread_id = $1
+		) stats
+		WHERE id = $1
+	`
+	return affectOne(r.db.Exec(ctx, query, threadID))
+}
+
+// GetByID returns a thread by primary key
+func (r *threadRepository) GetByID(ctx context.Context, id string) (*models.Thread, error) {
+	t, err := scanThread(r.db.QueryRow(ctx, "SELECT "+threadColumns+" FROM threads WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListEmails returns up to limit emails of a thread with bodies, oldest first
+func (r *threadRepository) ListEmails(ctx context.Context, threadID string, filter EmailFilter, limit int) ([]models.Email, error) {
+	w := emailWhere(filter)
+	w.add("thread_id = ?", threadID)
+	return listAll(ctx, r.db, "emails", emailColumns, "sent_at, id LIMIT "+w.arg(limit), w, scanEmail)
+}
+
+// CountMessages returns the number of distinct messages per thread among the
+// emails matching filter. Threads without such emails are omitted.
+func (r *threadRepository) CountMessages(ctx context.Context, threadIDs []string, filter EmailFilter) (map[string]int, error) {
+	counts := make(map[string]int, len(threadIDs))
+	if len(threadIDs) == 0 {
+		return counts, nil
+	}
+	w := emailWhere(filter)
+	w.add("thread_id = ANY(?::uuid[])", threadIDs)
+	rows, err := r.db.Query(ctx, "SELECT thread_id, COUNT(DISTINCT "+threadMessageKey+") FROM emails"+w.sql()+" GROUP BY thread_id", w.args...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, mapError(err)
+		}
+		counts[id] = count
+	}
+	return counts, mapError(rows.Err())
+}
@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"context"
+
+	"ironarchive/internal/models"
+)
+
+// UserFilter narrows user listings
+type UserFilter struct {
+	TenantID *string
+	Role     string
+	// Search matches email or display name case-insensitively
+	Search string
+}
+
+// UserRepository provides access to the users table
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id string) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	List(ctx context.Context, filter UserFilter, page Pagination) (Page[models.User], error)
+	Update(ctx context.Context, user *models.User) error
+	UpdatePassword(ctx context.Context, id, passwordHash string) error
+	UpdateMFA(ctx context.Context, id string, secret *string, enabled bool) error
+	Delete(ctx context.Context, id string) error
+	Count(ctx context.Context) (int, error)
+}
+
+const userColumns = `id, email, password_hash, display_name, role, tenant_id, mfa_secret,
+	COALESCE(mfa_enabled, FALSE), COALESCE(created_at, CURRENT_TIMESTAMP), COALESCE(updated_at, CURRENT_TIMESTAMP)`
+
+type userRepository struct {
+	db DBTX
+}
+
+// NewUserRepository creates a user repository
+func NewUserRepository(db DBTX) UserRepository {
+	return &userRepository{db: db}
+}
+
+func scanUser(row rowScanner) (models.User, error) {
+	var u models.User
+	err := row.Scan(
+		&u.ID,
+		&u.Email,
+		&u.PasswordHash,
+		&u.DisplayName,
+		&u.Role,
+		&u.TenantID,
+		&u.MFASecret,
+		&u.MFAEnabled,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+	)
+	return u, mapError(err)
+}
+
+// Create inserts a user and populates its generated fields
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	query := `
+		INSERT INTO users (email, password_hash, display_name, role, tenant_id, mfa_secret, mfa_enabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at
+	`
+	err := r.db.QueryRow(ctx, query,
+		user.Email,
+		user.PasswordHash,
+		user.DisplayName,
+		user.Role,
+		user.TenantID,
+		user.MFASecret,
+		user.MFAEnabled,
+	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	return mapError(err)
+}
+
+// GetByID returns a user by primary key
+func (r *userRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
+	u, err := scanUser(r.db.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetByEmail returns a user by login email (case-insensitive)
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	u, err := scanUser(r.db.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE LOWER(email) = LOWER($1)", email))
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// List returns a page of users ordered by email
+func (r *userRepository) List(ctx context.Context, filter UserFilter, page Pagination) (Page[models.User], error) {
+	w := &whereBuilder{}
+	if filter.TenantID != nil {
+		w.add("tenant_id = ?", *filter.TenantID)
+	}
+	if filter.Role != "" {
+		w.add("role = ?", filter.Role)
+	}
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		w.add("(email ILIKE ? OR display_name ILIKE ?)", pattern, pattern)
+	}
+	return listPage(ctx, r.db, "users", userColumns, "email, id", w, page, scanUser)
+}
+
+// Update saves profile, role and tenant assignment
+func (r *userRepository) Update(ctx context.Context, user *models.User) error {
+	query := `
+		UPDATE users
+		SET email = $2, display_name = $3, role = $4, tenant_id = $5
+		WHERE id = $1
+		RETURNING updated_at
+	`
+	err := r.db.QueryRow(ctx, query,
+		user.ID,
+		user.Email,
+		user.DisplayName,
+		user.Role,
+		user.TenantID,
+	).Scan(&user.UpdatedAt)
+	return mapError(err)
+}
+
+// UpdatePassword replaces the stored bcrypt hash
+func (r *userRepository) UpdatePassword(ctx context.Context, id, passwordHash string) error {
+	return affectOne(r.db.Exec(ctx, "UPDATE users SET password_hash = $2 WHERE id = $1", id, passwordHash))
+}
+
+// UpdateMFA sets the TOTP secret and enrollment flag
+func (r *userRepository) UpdateMFA(ctx context.Context, id string, secret *string, enabled bool) error {
+	return affectOne(r.db.Exec(ctx, "UPDATE users SET mfa_secret = $2, mfa_enabled = $3 WHERE id = $1", id, secret, enabled))
+}
+
+// Delete removes a user
+func (r *userRepository) Delete(ctx context.Context, id string) error {
+	return affectOne(r.db.Exec(ctx, "DELETE FROM users WHERE id = $1", id))
+}
+
+// Count returns the total number of users (used to detect first-run setup)
+func (r *userRepository) Count(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, "SELECT COUNT(*) FROM users").Scan(&count)
+	return count, mapError(err)
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ironarchive/internal/models"
+)
+
+// seedTenantWithEmail inserts a tenant, mailbox and email as the table owner and returns their IDs
+func seedTenantWithEmail(t *testing.T, db *pgxpool.Pool, name, azureID, address, messageID string) (tenantID, emailID string) {
+	t.Helper()
+	ctx := context.Background()
+
+	err := db.QueryRow(ctx,
+		"INSERT INTO tenants (name, azure_tenant_id, azure_app_credentials) VALUES ($1, $2, 'x') RETURNING id",
+		name, azureID,
+	).Scan(&tenantID)
+	require.NoError(t, err)
+
+	var mailboxID string
+	err = db.QueryRow(ctx,
+		"INSERT INTO mailboxes (tenant_id, email_address, mailbox_type) VALUES ($1, $2, 'USER') RETURNING id",
+		tenantID, address,
+	).Scan(&mailboxID)
+	require.NoError(t, err)
+
+	err = db.QueryRow(ctx,
+		"INSERT INTO emails (mailbox_id, message_id, sent_at, size_bytes, file_path) VALUES ($1, $2, $3, 100, '/archive/x') RETURNING id",
+		mailboxID, messageID, time.Now(),
+	).Scan(&emailID)
+	require.NoError(t, err)
+
+	return tenantID, emailID
+}
+
+// withSession runs fn in a transaction scoped to session, committing if it returns nil
+func withSession(ctx context.Context, db *pgxpool.Pool, session Session, fn func(tx pgx.Tx) error) error {
+	return pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		if err := ApplySession(ctx, tx, session); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// TestSessionValidate verifies role and tenant requirements for scoped sessions
+func TestSessionValidate(t *testing.T) {
+	assert.NoError(t, Session{Role: models.RoleMSPAdmin}.Validate())
+	assert.NoError(t, Session{Role: models.RoleTenantAdmin, TenantID: "t1"}.Validate())
+	assert.ErrorIs(t, Session{Role: models.RoleUser}.Validate(), ErrMissingSessionTenant)
+	assert.ErrorIs(t, Session{Role: "ROOT", TenantID: "t1"}.Validate(), ErrInvalidSessionRole)
+}
+
+// TestRLSTenantAdminCannotReadOtherTenantEmails verifies RLS hides foreign rows even for hand-written queries
+func TestRLSTenantAdminCannotReadOtherTenantEmails(t *testing.T) {
+	db := setupTestDatabase(t)
+	defer teardownTestDatabase(t, db)
+
+	ctx := context.Background()
+
+	tenantA, emailA := seedTenantWithEmail(t, db, "Tenant A", "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "a@a.test", "msg-a")
+	_, emailB := seedTenantWithEmail(t, db, "Tenant B", "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", "b@b.test", "msg-b")
+
+	session := Session{TenantID: tenantA, Role: models.RoleTenantAdmin}
+	err := withSession(ctx, db, session, func(tx pgx.Tx) error {
+		var count int
+		require.NoError(t, tx.QueryRow(ctx, "SELECT COUNT(*) FROM emails").Scan(&count))
+		assert.Equal(t, 1, count, "only the own tenant's email should be visible")
+
+		var id string
+		require.NoError(t, tx.QueryRow(ctx, "SELECT id FROM emails").Scan(&id))
+		assert.Equal(t, emailA, id)
+
+		// Direct lookup by primary key of another tenant's email
+		err := tx.QueryRow(ctx, "SELECT id FROM emails WHERE id = $1", emailB).Scan(&id)
+		assert.True(t, errors.Is(err, pgx.ErrNoRows), "foreign email must not be readable, got %v", err)
+
+		// Join that bypasses the tenant filter in application code
+		require.NoError(t, tx.QueryRow(ctx,
+			"SELECT COUNT(*) FROM emails e JOIN mailboxes m ON m.id = e.mailbox_id JOIN tenants t ON t.id = m.tenant_id",
+		).Scan(&count))
+		assert.Equal(t, 1, count)
+
+		// Updates to foreign rows silently affect nothing
+		tag, err := tx.Exec(ctx, "UPDATE emails SET subject = 'pwned' WHERE id = $1", emailB)
+		require.NoError(t, err)
+		assert.Zero(t, tag.RowsAffected())
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// TestRLSTenantAdminCannotInsertIntoOtherTenant verifies WITH CHECK rejects writes into foreign tenants
+func TestRLSTenantAdminCannotInsertIntoOtherTenant(t *testing.T) {
+	db := setupTestDatabase(t)
+	defer teardownTestDatabase(t, db)
+
+	ctx := context.Background()
+
+	tenantA, _ := seedTenantWithEmail(t, db, "Tenant A", "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "a@a.test", "msg-a")
+	tenantB, _ := seedTenantWithEmail(t, db, "Tenant B", "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", "b@b.test", "msg-b")
+
+	err := withSession(ctx, db, Session{TenantID: tenantA, Role: models.RoleTenantAdmin}, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "INSERT INTO mailboxes (tenant_id, email_address, mailbox_type) VALUES ($1, 'x@b.test', 'USER')", tenantB)
+		return err
+	})
+	assert.Error(t, err, "inserting a mailbox for another tenant should violate the RLS policy")
+}
+
+// TestRLSMSPAdminBypass verifies MSP admins see every tenant's emails
+func TestRLSMSPAdminBypass(t *testing.T) {
+	db := setupTestDatabase(t)
+	defer teardownTestDatabase(t, db)
+
+	ctx := context.Background()
+
+	seedTenantWithEmail(t, db, "Tenant A", "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "a@a.test", "msg-a")
+	seedTenantWithEmail(t, db, "Tenant B", "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", "b@b.test", "msg-b")
+
+	err := withSession(ctx, db, Session{Role: models.RoleMSPAdmin}, func(tx pgx.Tx) error {
+		var count int
+		require.NoError(t, tx.QueryRow(ctx, "SELECT COUNT(*) FROM emails").Scan(&count))
+		assert.Equal(t, 2, count)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// TestRLSSessionDoesNotLeakToPool verifies session settings are reset when the transaction ends
+func TestRLSSessionDoesNotLeakToPool(t *testing.T) {
+	db := setupTestDatabase(t)
+	defer teardownTestDatabase(t, db)
+
+	ctx := context.Background()
+
+	tenantA, _ := seedTenantWithEmail(t, db, "Tenant A", "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "a@a.test", "msg-a")
+	seedTenantWithEmail(t, db, "Tenant B", "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", "b@b.test", "msg-b")
+
+	require.NoError(t, withSession(ctx, db, Session{TenantID: tenantA, Role: models.RoleUser}, func(tx pgx.Tx) error {
+		return nil
+	}))
+
+	var role string
+	var count int
+	require.NoError(t, db.QueryRow(ctx, "SELECT current_user, (SELECT COUNT(*) FROM emails)").Scan(&role, &count))
+	assert.NotEqual(t, TenantScopeRole, role)
+	assert.Equal(t, 2, count, "owner connections are not subject to RLS")
+}
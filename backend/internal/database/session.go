@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"ironarchive/internal/models"
+)
+
+// TenantScopeRole is the non-owner role request-scoped transactions switch to,
+// so the row-level security policies from migration 000002 apply
+const TenantScopeRole = "ironarchive_tenant_scope"
+
+var (
+	// ErrInvalidSessionRole is returned for roles other than MSP_ADMIN, TENANT_ADMIN and USER
+	ErrInvalidSessionRole = errors.New("invalid session role")
+	// ErrMissingSessionTenant is returned when a tenant-bound role has no tenant
+	ErrMissingSessionTenant = errors.New("session tenant is required for this role")
+)
+
+// Session identifies who a request runs as. Its values are exposed to the
+// RLS policies as the app.tenant_id, app.role and app.user_id settings.
+type Session struct {
+	UserID   string
+	TenantID string
+	Role     string
+}
+
+// Validate checks the role and that non-MSP roles are bound to a tenant
+func (s Session) Validate() error {
+	switch s.Role {
+	case models.RoleMSPAdmin:
+		return nil
+	case models.RoleTenantAdmin, models.RoleUser:
+		if s.TenantID == "" {
+			return ErrMissingSessionTenant
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidSessionRole, s.Role)
+	}
+}
+
+// ApplySession switches the transaction to the tenant-scoped role and sets the
+// session variables read by the RLS policies. Everything is transaction-local,
+// so nothing leaks to the next user of the pooled connection. Request-scoped
+// code runs through repositories.Store.WithSession, which applies it.
+func ApplySession(ctx context.Context, tx pgx.Tx, session Session) error {
+	if err := session.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, "SET LOCAL ROLE "+TenantScopeRole); err != nil {
+		return fmt.Errorf("failed to switch to tenant scope role: %w", err)
+	}
+
+	_, err := tx.Exec(ctx,
+		"SELECT set_config('app.tenant_id', $1, true), set_config('app.role', $2, true), set_config('app.user_id', $3, true)",
+		session.TenantID, session.Role, session.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set session variables: %w", err)
+	}
+	return nil
+}
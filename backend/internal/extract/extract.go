@@ -0,0 +1,272 @@
+// Package extract pulls the searchable text out of archived attachments:
+// PDF, Office Open XML (DOCX, XLSX, PPTX), OpenDocument text, RTF, plain text
+// and attached emails (.eml, .msg) including their own attachments. Parsing
+// untrusted documents runs in a child process, see Sandbox.
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Format is a document format text can be extracted from
+type Format string
+
+// Supported formats
+const (
+	FormatPDF  Format = "pdf"
+	FormatDOCX Format = "docx"
+	FormatXLSX Format = "xlsx"
+	FormatPPTX Format = "pptx"
+	FormatODT  Format = "odt"
+	FormatRTF  Format = "rtf"
+	FormatText Format = "text"
+	FormatEML  Format = "eml"
+	FormatMSG  Format = "msg"
+)
+
+var (
+	// ErrUnsupported is returned for documents of other formats
+	ErrUnsupported = errors.New("unsupported document format")
+	// ErrEncrypted is returned for password-protected documents
+	ErrEncrypted = errors.New("document is encrypted")
+	// ErrTooLarge is returned when a document expands beyond
+	// Limits.MaxExpandedBytes, e.g. a zip bomb
+	ErrTooLarge = errors.New("document expands beyond the size limit")
+)
+
+// Limits bound the work of one extraction
+type Limits struct {
+	// MaxTextBytes caps the extracted text; the rest is dropped
+	MaxTextBytes int
+	// MaxExpandedBytes caps the bytes decompressed from archive entries and
+	// streams, including those of nested documents
+	MaxExpandedBytes int64
+	// MaxDepth bounds the nesting of attached emails
+	MaxDepth int
+}
+
+// DefaultLimits are the limits of a Sandbox without configured ones
+var DefaultLimits = Limits{MaxTextBytes: 1 << 20, MaxExpandedBytes: 256 << 20, MaxDepth: 3}
+
+// Result is the text of a document
+type Result struct {
+	Text   string `json:"text"`
+	Format Format `json:"format"`
+	// Truncated reports that the text was cut at Limits.MaxTextBytes
+	Truncated bool `json:"truncated"`
+}
+
+// Extract returns the text of a document. It parses untrusted input in the
+// calling process; use a Sandbox to isolate it.
+func Extract(data []byte, filename string, limits Limits) (*Result, error) {
+	e := &extraction{limits: limits, out: &output{max: limits.MaxTextBytes}}
+	format, err := e.document(data, filename, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Text: normalize(e.out.String()), Format: format, Truncated: e.out.truncated}, nil
+}
+
+// extraction is the state of one Extract call
+type extraction struct {
+	limits   Limits
+	out      *output
+	expanded int64
+}
+
+// document writes the text of a document at nesting depth to the output
+func (e *extraction) document(data []byte, filename string, depth int) (Format, error) {
+	format, err := Detect(data, filename)
+	if err != nil {
+		return "", err
+	}
+	switch format {
+	case FormatPDF:
+		err = e.pdf(data)
+	case FormatDOCX, FormatXLSX, FormatPPTX, FormatODT:
+		err = e.office(data, format)
+	case FormatRTF:
+		err = e.rtf(data)
+	case FormatText:
+		e.out.WriteString(decodeText(data))
+	case FormatEML:
+		err = e.mail(data, depth)
+	case FormatMSG:
+		err = e.msg(data, depth)
+	}
+	return format, err
+}
+
+// attachment writes the text of a document attached to an email at depth,
+// headed by its name. Attachments that cannot be read are skipped; only
+// exhausted limits fail the enclosing document.
+func (e *extraction) attachment(data []byte, filename string, depth int) error {
+	if depth >= e.limits.MaxDepth || e.out.truncated {
+		return nil
+	}
+	mark := e.out.Len()
+	e.out.WriteString("\n\n" + filename + "\n")
+	if _, err := e.document(data, filename, depth+1); err != nil {
+		if errors.Is(err, ErrTooLarge) {
+			return err
+		}
+		e.out.Reset(mark)
+	}
+	return nil
+}
+
+// expand reads a decompressing reader within the expansion budget
+func (e *extraction) expand(r io.Reader) ([]byte, error) {
+	remaining := e.limits.MaxExpandedBytes - e.expanded
+	data, err := io.ReadAll(io.LimitReader(r, remaining+1))
+	e.expanded += int64(len(data))
+	if int64(len(data)) > remaining {
+		return nil, ErrTooLarge
+	}
+	return data, err
+}
+
+// zipEntry returns the content of a zip entry, nil if it is missing
+func (e *extraction) zipEntry(zr *zip.Reader, name string) ([]byte, error) {
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, nil
+	}
+	defer f.Close()
+	return e.expand(f)
+}
+
+// officeTypes maps the OPC main part or OpenDocument mimetype to a format
+var officeTypes = map[string]Format{
+	"word/document.xml":                       FormatDOCX,
+	"xl/workbook.xml":                         FormatXLSX,
+	"ppt/presentation.xml":                    FormatPPTX,
+	"application/vnd.oasis.opendocument.text": FormatODT,
+}
+
+// textExtensions are file extensions of plain text formats
+var textExtensions = map[string]bool{".txt": true, ".text": true, ".csv": true, ".tsv": true, ".log": true, ".md": true}
+
+// mailHeader matches the first line of an RFC 822 message
+var mailHeader = regexp.MustCompile(`^(?i)(received|return-path|from|date|message-id|mime-version|subject|to|delivered-to|x-[a-z0-9-]+):`)
+
+// oleMagic starts OLE compound files such as .msg
+var oleMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// Detect returns the format of a document from its content, using the file
+// name to tell apart formats with the same container
+func Detect(data []byte, filename string) (Format, error) {
+	ext := strings.ToLower(path.Ext(filename))
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		return FormatPDF, nil
+	case bytes.HasPrefix(data, []byte(`{\rtf`)):
+		return FormatRTF, nil
+	case bytes.HasPrefix(data, oleMagic):
+		// Legacy Office documents are compound files too
+		if ext == ".msg" {
+			return FormatMSG, nil
+		}
+		return "", ErrUnsupported
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrUnsupported, err)
+		}
+		for _, f := range zr.File {
+			if format, ok := officeTypes[f.Name]; ok {
+				return format, nil
+			}
+			if f.Name == "mimetype" && f.Method == zip.Store && f.UncompressedSize64 < 100 {
+				r, err := f.Open()
+				if err != nil {
+					break
+				}
+				mimetype, _ := io.ReadAll(r)
+				r.Close()
+				if format, ok := officeTypes[string(mimetype)]; ok {
+					return format, nil
+				}
+			}
+		}
+		return "", ErrUnsupported
+	case ext == ".eml" || mailHeader.Match(data):
+		return FormatEML, nil
+	case textExtensions[ext]:
+		return FormatText, nil
+	}
+	sniffed := http.DetectContentType(data)
+	if strings.HasPrefix(sniffed, "text/plain") && (utf8.Valid(data) || bytes.IndexByte(data, 0) < 0) {
+		return FormatText, nil
+	}
+	return "", ErrUnsupported
+}
+
+// output collects extracted text up to a byte limit
+type output struct {
+	b         strings.Builder
+	max       int
+	truncated bool
+}
+
+// WriteString appends s, truncating it at the limit
+func (o *output) WriteString(s string) {
+	if o.truncated {
+		return
+	}
+	if room := o.max - o.b.Len(); len(s) > room {
+		s = truncate(s, room)
+		o.truncated = true
+	}
+	o.b.WriteString(s)
+}
+
+func (o *output) Len() int {
+	return o.b.Len()
+}
+
+// Reset drops the text written after the first n bytes
+func (o *output) Reset(n int) {
+	s := o.b.String()[:n]
+	o.b.Reset()
+	o.b.WriteString(s)
+	o.truncated = false
+}
+
+func (o *output) String() string {
+	return o.b.String()
+}
+
+// blankLines collapses runs of empty lines
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// normalize collapses the whitespace of each line and runs of blank lines
+func normalize(s string) string {
+	s = strings.ToValidUTF8(strings.ReplaceAll(s, "\r\n", "\n"), "")
+	lines := strings.Split(strings.ReplaceAll(s, "\r", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(strings.ReplaceAll(line, "\x00", "")), " ")
+	}
+	s = strings.Join(lines, "\n")
+	return strings.TrimSpace(blankLines.ReplaceAllString(s, "\n\n"))
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,186 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// zipOf builds a zip archive of name/content pairs in order
+func zipOf(t *testing.T, files ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i := 0; i < len(files); i += 2 {
+		method := zip.Deflate
+		if files[i] == "mimetype" {
+			method = zip.Store
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: files[i], Method: method})
+		require.NoError(t, err)
+		_, err = w.Write([]byte(files[i+1]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func extractText(t *testing.T, data []byte, filename string) *Result {
+	t.Helper()
+	result, err := Extract(data, filename, DefaultLimits)
+	require.NoError(t, err)
+	return result
+}
+
+// TestDetect verifies formats are told by content, with names breaking ties
+func TestDetect(t *testing.T) {
+	docx := zipOf(t, "[Content_Types].xml", "<Types/>", "word/document.xml", "<document/>")
+	odt := zipOf(t, "mimetype", "application/vnd.oasis.opendocument.text", "content.xml", "<office/>")
+	for _, tc := range []struct {
+		data     []byte
+		filename string
+		want     Format
+	}{
+		{[]byte("%PDF-1.7\n"), "scan.bin", FormatPDF},
+		{[]byte(`{\rtf1\ansi hi}`), "letter.doc", FormatRTF},
+		{docx, "report.zip", FormatDOCX},
+		{zipOf(t, "xl/workbook.xml", "<workbook/>"), "", FormatXLSX},
+		{zipOf(t, "ppt/presentation.xml", "<presentation/>"), "", FormatPPTX},
+		{odt, "", FormatODT},
+		{[]byte("Received: from mx\r\nSubject: hi\r\n\r\nbody"), "noname", FormatEML},
+		{[]byte("a,b\n1,2\n"), "data.csv", FormatText},
+		{[]byte("plain words"), "", FormatText},
+		{append(append([]byte{}, oleMagic...), make([]byte, 600)...), "mail.msg", FormatMSG},
+	} {
+		format, err := Detect(tc.data, tc.filename)
+		require.NoError(t, err, tc.want)
+		assert.Equal(t, tc.want, format)
+	}
+
+	for _, data := range [][]byte{
+		{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n', 0, 0, 0, 0},
+		append(append([]byte{}, oleMagic...), make([]byte, 600)...), // legacy .doc
+		zipOf(t, "photo.jpg", "\xff\xd8\xff"),
+	} {
+		_, err := Detect(data, "file.doc")
+		assert.ErrorIs(t, err, ErrUnsupported)
+	}
+}
+
+// TestExtractOffice verifies the text of Office Open XML and OpenDocument files
+func TestExtractOffice(t *testing.T) {
+	docx := zipOf(t,
+		"word/document.xml", `<w:document xmlns:w="w"><w:body><w:p><w:r><w:t>Merger</w:t></w:r><w:r><w:tab/><w:t xml:space="preserve"> agreement</w:t></w:r></w:p>`+
+			`<w:p><w:r><w:t>Closing date</w:t></w:r></w:p></w:body></w:document>`,
+		"word/header1.xml", `<w:hdr xmlns:w="w"><w:p><w:r><w:t>Confidential</w:t></w:r></w:p></w:hdr>`,
+		"word/comments.xml", `<w:comments xmlns:w="w"><w:comment><w:p><w:r><w:t>Check with legal</w:t></w:r></w:p></w:comment></w:comments>`,
+	)
+	result := extractText(t, docx, "deal.docx")
+	assert.Equal(t, FormatDOCX, result.Format)
+	assert.Equal(t, "Merger agreement\nClosing date\n\nConfidential\n\nCheck with legal", result.Text)
+
+	xlsx := zipOf(t,
+		"xl/workbook.xml", `<workbook/>`,
+		"xl/sharedStrings.xml", `<sst><si><t>Vendor</t></si><si><r><t>Acme </t></r><r><t>Corp</t></r></si></sst>`,
+		"xl/worksheets/sheet2.xml", `<worksheet><sheetData><row><c t="inlineStr"><is><t>Second</t></is></c></row></sheetData></worksheet>`,
+		"xl/worksheets/sheet1.xml", `<worksheet><sheetData><row><c t="s"><v>0</v></c><c><v>1200.5</v></c></row>`+
+			`<row><c t="s"><v>1</v></c><c t="s"><v>7</v></c></row></sheetData></worksheet>`,
+	)
+	result = extractText(t, xlsx, "ledger.xlsx")
+	assert.Equal(t, "Vendor 1200.5\nAcme Corp\n\nSecond", result.Text)
+
+	pptx := zipOf(t,
+		"ppt/presentation.xml", `<presentation/>`,
+		"ppt/slides/slide10.xml", `<p:sld xmlns:a="a" xmlns:p="p"><a:p><a:r><a:t>Last slide</a:t></a:r></a:p></p:sld>`,
+		"ppt/slides/slide2.xml", `<p:sld xmlns:a="a" xmlns:p="p"><a:p><a:r><a:t>Roadmap</a:t></a:r></a:p></p:sld>`,
+		"ppt/notesSlides/notesSlide2.xml", `<p:notes xmlns:a="a" xmlns:p="p"><a:p><a:r><a:t>Speaker note</a:t></a:r></a:p></p:notes>`,
+	)
+	result = extractText(t, pptx, "deck.pptx")
+	assert.Equal(t, "Roadmap\n\nLast slide\n\nSpeaker note", result.Text)
+
+	odt := zipOf(t,
+		"mimetype", "application/vnd.oasis.opendocument.text",
+		"content.xml", `<office:document-content xmlns:office="o" xmlns:text="t"><office:body><office:text>`+
+			`<text:h>Minutes</text:h><text:p>Budget<text:s text:c="3"/>approved<text:line-break/>unanimously</text:p>`+
+			`</office:text></office:body></office:document-content>`,
+	)
+	result = extractText(t, odt, "minutes.odt")
+	assert.Equal(t, "Minutes\nBudget approved\nunanimously", result.Text)
+}
+
+// TestExtractRTF verifies control words, escapes, Unicode and skipped destinations
+func TestExtractRTF(t *testing.T) {
+	rtf := `{\rtf1\ansi\ansicpg1252{\fonttbl{\f0 Arial;}}{\*\generator Writer;}` +
+		`{\info{\title Hidden}}\f0 Gr\'fc\'dfe aus M\u252?nchen\par ` +
+		`Price\tab 5\'80\par {\pict 0123abcd}Emoji \u-10179?\u-8704?\line done\~now}`
+	result := extractText(t, []byte(rtf), "letter.rtf")
+	assert.Equal(t, FormatRTF, result.Format)
+	assert.Equal(t, "Grüße aus München\nPrice 5€\nEmoji 😀\ndone now", result.Text)
+}
+
+// TestExtractText verifies plain text encodings are decoded
+func TestExtractText(t *testing.T) {
+	utf16 := []byte{0xFF, 0xFE, 'H', 0, 'i', 0, ' ', 0, 0xE9, 0}
+	assert.Equal(t, "Hi é", extractText(t, utf16, "a.txt").Text)
+	assert.Equal(t, "Café", extractText(t, []byte("Caf\xe9"), "b.txt").Text, "invalid UTF-8 is read as Windows-1252")
+	assert.Equal(t, "a b\n\nc", extractText(t, []byte("\xEF\xBB\xBFa   b\r\n\r\n\r\n\r\nc"), "c.txt").Text, "whitespace is collapsed")
+}
+
+// TestExtractMail verifies emails are extracted with their attachments,
+// including attached emails and their own attachments
+func TestExtractMail(t *testing.T) {
+	inner := "From: cfo@acme.test\r\nSubject: Forecast\r\nContent-Type: multipart/mixed; boundary=in\r\n\r\n" +
+		"--in\r\nContent-Type: text/plain\r\n\r\nSee the numbers.\r\n" +
+		"--in\r\nContent-Type: text/csv; name=q3.csv\r\nContent-Transfer-Encoding: base64\r\n\r\n" +
+		base64.StdEncoding.EncodeToString([]byte("quarter,revenue\nQ3,1200\n")) + "\r\n--in--\r\n"
+	outer := "From: =?UTF-8?Q?J=C3=BCrgen?= <j@acme.test>\r\nTo: legal@acme.test\r\nSubject: Fwd: Forecast\r\n" +
+		"MIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=out\r\n\r\n" +
+		"--out\r\nContent-Type: multipart/alternative; boundary=alt\r\n\r\n" +
+		"--alt\r\nContent-Type: text/html\r\n\r\n<p>HTML version</p>\r\n" +
+		"--alt\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nPlease review =C3=BCber.\r\n" +
+		"--alt--\r\n" +
+		"--out\r\nContent-Type: message/rfc822\r\nContent-Disposition: attachment; filename=forecast.eml\r\n\r\n" + inner + "\r\n" +
+		"--out--\r\n"
+
+	result := extractText(t, []byte(outer), "fwd.eml")
+	assert.Equal(t, FormatEML, result.Format)
+	assert.Equal(t, "From: Jürgen <j@acme.test>\nTo: legal@acme.test\nSubject: Fwd: Forecast\n\nPlease review über.\n\n"+
+		"forecast.eml\nFrom: cfo@acme.test\nSubject: Forecast\n\nSee the numbers.\n\nq3.csv\nquarter,revenue\nQ3,1200", result.Text)
+
+	// Nesting beyond MaxDepth drops the innermost attachments
+	limits := DefaultLimits
+	limits.MaxDepth = 1
+	shallow, err := Extract([]byte(outer), "fwd.eml", limits)
+	require.NoError(t, err)
+	assert.Contains(t, shallow.Text, "See the numbers.")
+	assert.NotContains(t, shallow.Text, "Q3,1200")
+}
+
+// TestExtractLimits verifies text truncation, expansion limits and errors
+func TestExtractLimits(t *testing.T) {
+	result, err := Extract([]byte(strings.Repeat("é", 10)), "a.txt", Limits{MaxTextBytes: 5, MaxExpandedBytes: 1 << 20})
+	require.NoError(t, err)
+	assert.True(t, result.Truncated)
+	assert.Equal(t, "éé", result.Text, "runes are not split")
+
+	bomb := zipOf(t, "word/document.xml", "<w:t>"+strings.Repeat("a", 1<<20)+"</w:t>")
+	_, err = Extract(bomb, "bomb.docx", Limits{MaxTextBytes: 1 << 20, MaxExpandedBytes: 64 << 10, MaxDepth: 1})
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "word/document.xml", Flags: 0x1})
+	require.NoError(t, err)
+	w.Write([]byte("<w:t>secret</w:t>"))
+	require.NoError(t, zw.Close())
+	_, err = Extract(buf.Bytes(), "locked.docx", DefaultLimits)
+	assert.ErrorIs(t, err, ErrEncrypted)
+
+	_, err = Extract([]byte{0x89, 'P', 'N', 'G', 0, 0, 0, 0}, "photo.png", DefaultLimits)
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
@@ -0,0 +1,193 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+	"ironarchive/internal/storage"
+)
+
+// ExtractorConfig tunes the extraction worker
+type ExtractorConfig struct {
+	// BatchSize is the number of attachments claimed per pass (default 20)
+	BatchSize int
+	// Concurrency is the number of documents extracted at once (default 2)
+	Concurrency int
+	// Interval is the pause between passes once nothing is pending (default 10s)
+	Interval time.Duration
+	// Lease is how long claimed attachments are reserved before another
+	// worker may retry them (default: twice the time the batch may take)
+	Lease time.Duration
+}
+
+// Extractor extracts the text of archived attachments in a Sandbox and
+// records it on the attachment rows, which flags the parent emails for
+// search indexing. Identical content is extracted once.
+type Extractor struct {
+	attachments repositories.AttachmentRepository
+	blobs       blobOpener
+	extract     func(ctx context.Context, data []byte, filename string) (*Result, error)
+	maxInput    int64
+	cfg         ExtractorConfig
+	logger      *zap.Logger
+	now         func() time.Time
+}
+
+// blobOpener reads archived attachments
+type blobOpener interface {
+	Open(ctx context.Context, uri string) (io.ReadCloser, error)
+}
+
+// NewExtractor creates an extraction worker reading attachments from archive
+func NewExtractor(repos *repositories.Repositories, archive *storage.Archive, sandbox *Sandbox, cfg ExtractorConfig, logger *zap.Logger) *Extractor {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 20
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 2
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.Lease <= 0 {
+		rounds := (cfg.BatchSize + cfg.Concurrency - 1) / cfg.Concurrency
+		cfg.Lease = 2*time.Duration(rounds)*sandbox.cfg.Timeout + time.Minute
+	}
+	return &Extractor{
+		attachments: repos.Attachments,
+		blobs:       archive,
+		extract:     sandbox.Extract,
+		maxInput:    sandbox.MaxInputBytes(),
+		cfg:         cfg,
+		logger:      logger,
+		now:         time.Now,
+	}
+}
+
+// Run extracts pending attachments until ctx is cancelled. Replicas share
+// the work through claims.
+func (x *Extractor) Run(ctx context.Context) {
+	x.logger.Info("Attachment text extractor started", zap.Int("batch_size", x.cfg.BatchSize), zap.Int("concurrency", x.cfg.Concurrency))
+	for {
+		n, err := x.ExtractPending(ctx)
+		if err != nil && ctx.Err() == nil {
+			x.logger.Error("Attachment text extraction failed", zap.Error(err))
+		}
+		// A full batch means more is pending
+		if err == nil && n >= x.cfg.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			x.logger.Info("Attachment text extractor stopped")
+			return
+		case <-time.After(x.cfg.Interval):
+		}
+	}
+}
+
+// ExtractPending claims one batch of pending attachments, extracts their
+// text and returns how many were claimed. Attachments whose extraction could
+// not be run stay pending and are retried once their claim lapses.
+func (x *Extractor) ExtractPending(ctx context.Context) (int, error) {
+	now := x.now().UTC()
+	claimed, err := x.attachments.ClaimPendingText(ctx, x.cfg.BatchSize, now, now.Add(x.cfg.Lease))
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim attachments: %w", err)
+	}
+
+	// One attachment per content hash; saving its text covers the others
+	var unique []models.Attachment
+	seen := make(map[string]bool)
+	for _, a := range claimed {
+		if !seen[a.SHA256Hash] {
+			seen[a.SHA256Hash] = true
+			unique = append(unique, a)
+		}
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, x.cfg.Concurrency)
+	for _, a := range unique {
+		slots <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-slots }()
+			if err := x.process(ctx, a); err != nil && ctx.Err() == nil {
+				x.logger.Warn("Failed to extract attachment text, will retry",
+					zap.String("attachment_id", a.ID), zap.Error(err))
+			}
+		})
+	}
+	wg.Wait()
+	return len(claimed), ctx.Err()
+}
+
+// process extracts and records the text of one attachment
+func (x *Extractor) process(ctx context.Context, a models.Attachment) error {
+	text, err := x.textOf(ctx, a)
+	if err != nil {
+		return err
+	}
+	n, err := x.attachments.SaveText(ctx, a.SHA256Hash, text, x.now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save text: %w", err)
+	}
+	x.logger.Debug("Extracted attachment text", zap.String("attachment_id", a.ID), zap.String("status", text.Status),
+		zap.Int64("attachments", n))
+	return nil
+}
+
+// textOf returns the extraction outcome for an attachment, reusing that of
+// identical content. Errors mean the extraction could not be run.
+func (x *Extractor) textOf(ctx context.Context, a models.Attachment) (models.AttachmentText, error) {
+	done, err := x.attachments.FindExtractedByHash(ctx, a.SHA256Hash)
+	if err == nil {
+		return models.AttachmentText{Status: done.TextStatus, Text: done.ExtractedText, Error: done.TextError}, nil
+	}
+	if !errors.Is(err, repositories.ErrNotFound) {
+		return models.AttachmentText{}, fmt.Errorf("failed to look up extracted text: %w", err)
+	}
+
+	if int64(a.SizeBytes) > x.maxInput {
+		return models.AttachmentText{Status: models.AttachmentTextTooLarge}, nil
+	}
+	blob, err := x.blobs.Open(ctx, a.FilePath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return failed(err), nil
+	}
+	if err != nil {
+		return models.AttachmentText{}, fmt.Errorf("failed to open attachment: %w", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(blob, x.maxInput+1))
+	blob.Close()
+	if err != nil {
+		return models.AttachmentText{}, fmt.Errorf("failed to read attachment: %w", err)
+	}
+
+	result, err := x.extract(ctx, data, a.Filename)
+	switch {
+	case err == nil:
+		return models.AttachmentText{Status: models.AttachmentTextExtracted, Text: &result.Text}, nil
+	case errors.Is(err, ErrUnsupported):
+		return models.AttachmentText{Status: models.AttachmentTextUnsupported}, nil
+	case errors.Is(err, ErrTooLarge):
+		return models.AttachmentText{Status: models.AttachmentTextTooLarge}, nil
+	case errors.Is(err, ErrEncrypted), errors.Is(err, ErrTimeout), errors.Is(err, ErrFailed):
+		return failed(err), nil
+	}
+	return models.AttachmentText{}, err
+}
+
+// failed is the outcome of a document that cannot be read
+func failed(err error) models.AttachmentText {
+	msg := truncate(err.Error(), 1000)
+	return models.AttachmentText{Status: models.AttachmentTextFailed, Error: &msg}
+}
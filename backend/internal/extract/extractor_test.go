@@ -0,0 +1,194 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+	"ironarchive/internal/storage"
+)
+
+// fakeAttachments keeps attachments in memory with the claim and save
+// semantics of the attachment repository
+type fakeAttachments struct {
+	repositories.AttachmentRepository
+	mu          sync.Mutex
+	attachments []*models.Attachment
+	claims      map[string]time.Time
+}
+
+func (f *fakeAttachments) ClaimPendingText(_ context.Context, limit int, now, until time.Time) ([]models.Attachment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []models.Attachment
+	for _, a := range f.attachments {
+		if a.TextStatus == models.AttachmentTextPending && !f.claims[a.ID].After(now) && len(out) < limit {
+			f.claims[a.ID] = until
+			out = append(out, *a)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeAttachments) FindExtractedByHash(_ context.Context, hash string) (*models.Attachment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, a := range f.attachments {
+		if a.SHA256Hash == hash && a.TextStatus != models.AttachmentTextPending {
+			found := *a
+			return &found, nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+func (f *fakeAttachments) SaveText(_ context.Context, hash string, text models.AttachmentText, extractedAt time.Time) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var n int64
+	for _, a := range f.attachments {
+		if a.SHA256Hash == hash && a.TextStatus == models.AttachmentTextPending {
+			a.TextStatus, a.ExtractedText, a.TextError, a.TextExtractedAt = text.Status, text.Text, text.Error, &extractedAt
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeAttachments) get(id string) *models.Attachment {
+	for _, a := range f.attachments {
+		if a.ID == id {
+			return a
+		}
+	}
+	return nil
+}
+
+// fakeBlobs serves attachment content by URI
+type fakeBlobs map[string]string
+
+func (f fakeBlobs) Open(_ context.Context, uri string) (io.ReadCloser, error) {
+	data, ok := f[uri]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader([]byte(data))), nil
+}
+
+type extractorFixture struct {
+	attachments *fakeAttachments
+	extractor   *Extractor
+	mu          sync.Mutex
+	calls       []string
+}
+
+// newExtractorFixture returns an extractor that runs extract in process,
+// except for documents named after the sandbox errors
+func newExtractorFixture(attachments ...*models.Attachment) *extractorFixture {
+	f := &extractorFixture{attachments: &fakeAttachments{attachments: attachments, claims: map[string]time.Time{}}}
+	blobs := fakeBlobs{}
+	for _, a := range attachments {
+		if a.TextStatus == "" {
+			a.TextStatus = models.AttachmentTextPending
+		}
+		if _, ok := blobs[a.FilePath]; !ok && a.FilePath != "missing" {
+			blobs[a.FilePath] = "content of " + a.Filename
+		}
+	}
+	f.extractor = &Extractor{
+		attachments: f.attachments,
+		blobs:       blobs,
+		extract: func(_ context.Context, data []byte, filename string) (*Result, error) {
+			f.mu.Lock()
+			f.calls = append(f.calls, filename)
+			f.mu.Unlock()
+			switch filename {
+			case "hang.pdf":
+				return nil, ErrTimeout
+			case "down.pdf":
+				return nil, errors.New("failed to run extraction process: fork: resource temporarily unavailable")
+			case "photo.png":
+				return nil, ErrUnsupported
+			}
+			return Extract(data, filename, DefaultLimits)
+		},
+		maxInput: 1 << 10,
+		cfg:      ExtractorConfig{BatchSize: 10, Concurrency: 2, Interval: time.Millisecond, Lease: time.Minute},
+		logger:   zap.NewNop(),
+		now:      time.Now,
+	}
+	return f
+}
+
+// TestExtractPending verifies outcomes are recorded per attachment and
+// identical content is extracted once
+func TestExtractPending(t *testing.T) {
+	text := "Done before"
+	f := newExtractorFixture(
+		&models.Attachment{ID: "a1", Filename: "terms.txt", SHA256Hash: "h1", FilePath: "blob/h1"},
+		&models.Attachment{ID: "a2", Filename: "terms-copy.txt", SHA256Hash: "h1", FilePath: "blob/h1"},
+		&models.Attachment{ID: "a3", Filename: "photo.png", SHA256Hash: "h2", FilePath: "blob/h2"},
+		&models.Attachment{ID: "a4", Filename: "hang.pdf", SHA256Hash: "h3", FilePath: "blob/h3"},
+		&models.Attachment{ID: "a5", Filename: "huge.txt", SHA256Hash: "h4", FilePath: "blob/h4", SizeBytes: 2 << 10},
+		&models.Attachment{ID: "a6", Filename: "gone.txt", SHA256Hash: "h5", FilePath: "missing"},
+		&models.Attachment{ID: "a7", Filename: "old.txt", SHA256Hash: "h6", FilePath: "blob/h6", TextStatus: models.AttachmentTextExtracted, ExtractedText: &text},
+		&models.Attachment{ID: "a8", Filename: "new.txt", SHA256Hash: "h6", FilePath: "blob/h6"},
+	)
+
+	n, err := f.extractor.ExtractPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 7, n)
+	assert.ElementsMatch(t, []string{"terms.txt", "photo.png", "hang.pdf"}, f.calls, "duplicates, oversized, missing and known content are not extracted")
+
+	for id, want := range map[string]string{
+		"a1": models.AttachmentTextExtracted,
+		"a2": models.AttachmentTextExtracted,
+		"a3": models.AttachmentTextUnsupported,
+		"a4": models.AttachmentTextFailed,
+		"a5": models.AttachmentTextTooLarge,
+		"a6": models.AttachmentTextFailed,
+		"a8": models.AttachmentTextExtracted,
+	} {
+		a := f.attachments.get(id)
+		assert.Equal(t, want, a.TextStatus, id)
+		assert.NotNil(t, a.TextExtractedAt, id)
+	}
+	assert.Equal(t, "content of terms.txt", *f.attachments.get("a2").ExtractedText)
+	assert.Equal(t, "Done before", *f.attachments.get("a8").ExtractedText)
+	assert.Contains(t, *f.attachments.get("a4").TextError, "timed out")
+
+	n, err = f.extractor.ExtractPending(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+// TestExtractPendingRetries verifies attachments whose extraction could not
+// be run stay pending until their claim lapses
+func TestExtractPendingRetries(t *testing.T) {
+	f := newExtractorFixture(&models.Attachment{ID: "a1", Filename: "down.pdf", SHA256Hash: "h1", FilePath: "blob/h1"})
+
+	n, err := f.extractor.ExtractPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, models.AttachmentTextPending, f.attachments.get("a1").TextStatus)
+
+	n, err = f.extractor.ExtractPending(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n, "the claim holds")
+
+	f.extractor.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	n, err = f.extractor.ExtractPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Len(t, f.calls, 2)
+}
@@ -0,0 +1,23 @@
+package extract
+
+import (
+	"html"
+	"regexp"
+)
+
+var (
+	// htmlInvisible matches elements whose content is never displayed
+	htmlInvisible = regexp.MustCompile(`(?is)<(script|style|head)\b.*?</(script|style|head)\s*>`)
+	// htmlBreak matches tags that end a line of text
+	htmlBreak = regexp.MustCompile(`(?i)<(br|/p|/div|/li|/tr|/h[1-6])\b[^>]*>`)
+	// htmlTag matches any remaining tag or comment
+	htmlTag = regexp.MustCompile(`(?s)<!--.*?-->|<[^>]*>`)
+)
+
+// HTMLToText reduces an HTML body to plain text for indexing and previews
+func HTMLToText(s string) string {
+	s = htmlInvisible.ReplaceAllString(s, "")
+	s = htmlBreak.ReplaceAllString(s, "\n")
+	s = htmlTag.ReplaceAllString(s, "")
+	return normalize(html.UnescapeString(s))
+}
@@ -0,0 +1,17 @@
+package extract
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHTMLToText verifies markup, scripts and entities are reduced to readable text
+func TestHTMLToText(t *testing.T) {
+	input := `<html><head><style>p{color:red}</style></head><body>
+		<p>Hello&nbsp;<b>Bob</b>,</p><p>see   the &lt;report&gt;</p><script>alert(1)</script>
+		<!-- tracking --><div>Thanks<br>Alice</div></body></html>`
+
+	assert.Equal(t, "Hello Bob,\nsee the <report>\n\nThanks\nAlice", HTMLToText(input))
+	assert.Equal(t, "", HTMLToText(""))
+}
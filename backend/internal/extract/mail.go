@@ -0,0 +1,172 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// maxMailParts bounds the MIME parts read from one email
+const maxMailParts = 1000
+
+// mailHeaders are the headers written before the body of an email
+var mailHeaders = []string{"From", "To", "Cc", "Date", "Subject"}
+
+// headerDecoder decodes RFC 2047 encoded words in any known charset
+var headerDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// mail writes the headers, body and attachments of an RFC 822 email
+func (e *extraction) mail(data []byte, depth int) error {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for _, name := range mailHeaders {
+		if value := msg.Header.Get(name); value != "" {
+			e.out.WriteString(name + ": " + decodeHeader(value) + "\n")
+		}
+	}
+	e.out.WriteString("\n")
+	parts := 0
+	return e.mimePart(textproto.MIMEHeader(msg.Header), msg.Body, depth, &parts)
+}
+
+// mimePart writes the text of a MIME part: text bodies directly, files and
+// attached emails as attachments, and the parts of multiparts in turn
+func (e *extraction) mimePart(header textproto.MIMEHeader, body io.Reader, depth int, parts *int) error {
+	if *parts++; *parts > maxMailParts || e.out.truncated {
+		return nil
+	}
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dispositionParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	filename = decodeHeader(filename)
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		return e.multipart(body, params["boundary"], mediaType == "multipart/alternative", depth, parts)
+	}
+	content, err := e.expand(transferDecoder(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return err
+	}
+	switch {
+	case mediaType == "message/rfc822":
+		if filename == "" {
+			filename = "message.eml"
+		}
+		return e.attachment(content, filename, depth)
+	case filename != "" || disposition == "attachment":
+		return e.attachment(content, filename, depth)
+	case mediaType == "text/plain":
+		e.out.WriteString(decodeCharset(content, params["charset"]) + "\n")
+	case mediaType == "text/html":
+		e.out.WriteString(HTMLToText(decodeCharset(content, params["charset"])) + "\n")
+	}
+	return nil
+}
+
+// multipart writes the parts of a multipart body. Of alternatives only the
+// plain text one is written, or else the first.
+func (e *extraction) multipart(body io.Reader, boundary string, alternative bool, depth int, parts *int) error {
+	type part struct {
+		header textproto.MIMEHeader
+		body   []byte
+	}
+	var alternatives []part
+	mr := multipart.NewReader(body, boundary)
+	for {
+		p, err := mr.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// Keep the text of the parts before a malformed one
+			break
+		}
+		if !alternative {
+			if err := e.mimePart(p.Header, p, depth, parts); err != nil {
+				return err
+			}
+			continue
+		}
+		content, err := e.expand(p)
+		if err != nil {
+			return err
+		}
+		alternatives = append(alternatives, part{header: p.Header, body: content})
+	}
+	if len(alternatives) == 0 {
+		return nil
+	}
+	chosen := alternatives[0]
+	for _, p := range alternatives {
+		if mediaType, _, _ := mime.ParseMediaType(p.header.Get("Content-Type")); mediaType == "text/plain" {
+			chosen = p
+			break
+		}
+	}
+	return e.mimePart(chosen.header, bytes.NewReader(chosen.body), depth, parts)
+}
+
+// transferDecoder decodes a Content-Transfer-Encoding
+func transferDecoder(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// base64Cleaner drops the whitespace and padding noise mailers put in base64
+// bodies, which base64.NewDecoder only ignores for line breaks
+type base64Cleaner struct {
+	r io.Reader
+}
+
+func (c *base64Cleaner) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	kept := 0
+	for _, b := range p[:n] {
+		if b != ' ' && b != '\t' {
+			p[kept] = b
+			kept++
+		}
+	}
+	if kept == 0 && n > 0 && err == nil {
+		return c.Read(p)
+	}
+	return kept, err
+}
+
+// decodeHeader decodes the encoded words of a header value
+func decodeHeader(value string) string {
+	decoded, err := headerDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// charsetReader decodes text in the named charset for mime.WordDecoder
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	return strings.NewReader(decodeCharset(data, charset)), nil
+}
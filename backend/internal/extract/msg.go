@@ -0,0 +1,330 @@
+package extract
+
+import (
+	"encoding/binary"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Compound file layout; sector numbers above cfbMaxSector mark free
+// sectors and chain ends
+const (
+	cfbMaxSector   = 0xFFFFFFFA
+	cfbNoStream    = 0xFFFFFFFF
+	cfbHeaderSize  = 512
+	cfbEntrySize   = 128
+	cfbHeaderDIFAT = 109
+)
+
+// Compound file directory entry types
+const (
+	cfbStorage = 1
+	cfbStream  = 2
+	cfbRoot    = 5
+)
+
+// Outlook property IDs, the first half of __substg1.0_<ID><type> names
+const (
+	propSubject     = "0037"
+	propSenderName  = "0C1A"
+	propSenderEmail = "0C1F"
+	propDisplayTo   = "0E04"
+	propDisplayCc   = "0E03"
+	propBody        = "1000"
+	propBodyHTML    = "1013"
+	propAttachData  = "3701"
+	propAttachLong  = "3707"
+	propAttachShort = "3704"
+	propDisplayName = "3001"
+)
+
+var errCorruptCFB = errors.New("msg: corrupt compound file")
+
+// cfb is an OLE compound file, the container of Outlook .msg files
+type cfb struct {
+	data       []byte
+	sectorSize int
+	fat        []uint32
+	miniFAT    []uint32
+	miniStream []byte
+	entries    []cfbEntry
+	// read counts stream bytes against the expansion limit
+	read func(n int) error
+}
+
+// cfbEntry is a storage or stream of a compound file
+type cfbEntry struct {
+	name               string
+	kind               byte
+	left, right, child uint32
+	start              uint32
+	size               uint64
+}
+
+// parseCFB reads the allocation tables and directory of a compound file
+func parseCFB(data []byte, read func(n int) error) (*cfb, error) {
+	if len(data) < cfbHeaderSize {
+		return nil, errCorruptCFB
+	}
+	shift := binary.LittleEndian.Uint16(data[0x1E:])
+	if shift != 9 && shift != 12 {
+		return nil, errCorruptCFB
+	}
+	c := &cfb{data: data, sectorSize: 1 << shift, read: read}
+	perSector := c.sectorSize / 4
+
+	// The FAT sectors are listed in the header and a chain of DIFAT sectors
+	var fatSectors []uint32
+	for i := 0; i < cfbHeaderDIFAT; i++ {
+		fatSectors = append(fatSectors, binary.LittleEndian.Uint32(data[0x4C+4*i:]))
+	}
+	difat := binary.LittleEndian.Uint32(data[0x44:])
+	for steps := 0; difat <= cfbMaxSector && steps < len(data)/c.sectorSize; steps++ {
+		sector, ok := c.sector(difat)
+		if !ok {
+			return nil, errCorruptCFB
+		}
+		for i := 0; i < perSector-1; i++ {
+			fatSectors = append(fatSectors, binary.LittleEndian.Uint32(sector[4*i:]))
+		}
+		difat = binary.LittleEndian.Uint32(sector[4*(perSector-1):])
+	}
+	for _, s := range fatSectors {
+		if s > cfbMaxSector {
+			continue
+		}
+		sector, ok := c.sector(s)
+		if !ok {
+			return nil, errCorruptCFB
+		}
+		for i := 0; i < perSector; i++ {
+			c.fat = append(c.fat, binary.LittleEndian.Uint32(sector[4*i:]))
+		}
+	}
+
+	dir, err := c.chain(binary.LittleEndian.Uint32(data[0x30:]), -1)
+	if err != nil {
+		return nil, err
+	}
+	for off := 0; off+cfbEntrySize <= len(dir); off += cfbEntrySize {
+		raw := dir[off : off+cfbEntrySize]
+		nameLen := min(int(binary.LittleEndian.Uint16(raw[64:])), 64)
+		c.entries = append(c.entries, cfbEntry{
+			name:  utf16String(raw[:max(nameLen-2, 0)]),
+			kind:  raw[66],
+			left:  binary.LittleEndian.Uint32(raw[68:]),
+			right: binary.LittleEndian.Uint32(raw[72:]),
+			child: binary.LittleEndian.Uint32(raw[76:]),
+			start: binary.LittleEndian.Uint32(raw[116:]),
+			size:  binary.LittleEndian.Uint64(raw[120:]) & 0xFFFFFFFF,
+		})
+	}
+	if len(c.entries) == 0 || c.entries[0].kind != cfbRoot {
+		return nil, errCorruptCFB
+	}
+
+	miniFAT, err := c.chain(binary.LittleEndian.Uint32(data[0x3C:]), -1)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i+4 <= len(miniFAT); i += 4 {
+		c.miniFAT = append(c.miniFAT, binary.LittleEndian.Uint32(miniFAT[i:]))
+	}
+	root := c.entries[0]
+	if c.miniStream, err = c.chain(root.start, int64(root.size)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// sector returns sector n
+func (c *cfb) sector(n uint32) ([]byte, bool) {
+	off := (int64(n) + 1) * int64(c.sectorSize)
+	if off+int64(c.sectorSize) > int64(len(c.data)) {
+		return nil, false
+	}
+	return c.data[off : off+int64(c.sectorSize)], true
+}
+
+// chain reads the sectors chained from start, up to size bytes (all with -1)
+func (c *cfb) chain(start uint32, size int64) ([]byte, error) {
+	var out []byte
+	for s, steps := start, 0; s <= cfbMaxSector; s, steps = c.fat[s], steps+1 {
+		if steps > len(c.fat) || int(s) >= len(c.fat) {
+			return nil, errCorruptCFB
+		}
+		sector, ok := c.sector(s)
+		if !ok {
+			return nil, errCorruptCFB
+		}
+		if err := c.read(len(sector)); err != nil {
+			return nil, err
+		}
+		out = append(out, sector...)
+		if size >= 0 && int64(len(out)) >= size {
+			return out[:size], nil
+		}
+	}
+	if size >= 0 && int64(len(out)) < size {
+		return nil, errCorruptCFB
+	}
+	return out, nil
+}
+
+// stream returns the content of stream entry i
+func (c *cfb) stream(i uint32) ([]byte, error) {
+	e := c.entries[i]
+	if e.size >= 4096 {
+		return c.chain(e.start, int64(e.size))
+	}
+	// Small streams live in 64-byte sectors of the mini stream
+	var out []byte
+	for s, steps := e.start, 0; s <= cfbMaxSector && uint64(len(out)) < e.size; s, steps = c.miniFAT[s], steps+1 {
+		off := int(s) * 64
+		if steps > len(c.miniFAT) || int(s) >= len(c.miniFAT) || off+64 > len(c.miniStream) {
+			return nil, errCorruptCFB
+		}
+		if err := c.read(64); err != nil {
+			return nil, err
+		}
+		out = append(out, c.miniStream[off:off+64]...)
+	}
+	if uint64(len(out)) < e.size {
+		return nil, errCorruptCFB
+	}
+	return out[:e.size], nil
+}
+
+// children returns the entries of storage i by name
+func (c *cfb) children(i uint32) map[string]uint32 {
+	children := map[string]uint32{}
+	var walk func(n uint32)
+	walk = func(n uint32) {
+		if n == cfbNoStream || int(n) >= len(c.entries) || len(children) > len(c.entries) {
+			return
+		}
+		if _, seen := children[c.entries[n].name]; seen {
+			return
+		}
+		children[c.entries[n].name] = n
+		walk(c.entries[n].left)
+		walk(c.entries[n].right)
+	}
+	walk(c.entries[i].child)
+	return children
+}
+
+// msg writes the headers, body and attachments of an Outlook .msg file
+func (e *extraction) msg(data []byte, depth int) error {
+	c, err := parseCFB(data, func(n int) error {
+		if e.expanded += int64(n); e.expanded > e.limits.MaxExpandedBytes {
+			return ErrTooLarge
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return e.message(c, 0, depth)
+}
+
+// message writes the message stored in storage i of a .msg file
+func (e *extraction) message(c *cfb, i uint32, depth int) error {
+	children := c.children(i)
+	prop := func(id string) string {
+		for _, kind := range []string{"001F", "001E"} {
+			n, ok := children["__substg1.0_"+id+kind]
+			if !ok || c.entries[n].kind != cfbStream {
+				continue
+			}
+			data, err := c.stream(n)
+			if err != nil {
+				return ""
+			}
+			if kind == "001F" {
+				return utf16String(data)
+			}
+			return strings.TrimRight(decodeText(data), "\x00")
+		}
+		return ""
+	}
+
+	from := prop(propSenderName)
+	if email := prop(propSenderEmail); email != "" && email != from {
+		from = strings.TrimSpace(from + " <" + email + ">")
+	}
+	for _, header := range [][2]string{{"From", from}, {"To", prop(propDisplayTo)}, {"Cc", prop(propDisplayCc)}, {"Subject", prop(propSubject)}} {
+		if header[1] != "" {
+			e.out.WriteString(header[0] + ": " + header[1] + "\n")
+		}
+	}
+	e.out.WriteString("\n")
+	if body := prop(propBody); body != "" {
+		e.out.WriteString(body + "\n")
+	} else if n, ok := children["__substg1.0_"+propBodyHTML+"0102"]; ok {
+		if html, err := c.stream(n); err == nil {
+			e.out.WriteString(HTMLToText(decodeText(html)) + "\n")
+		}
+	}
+
+	// Attachment storages are numbered in order, e.g. __attach_version1.0_#00000000
+	for _, name := range slices.Sorted(maps.Keys(children)) {
+		n := children[name]
+		if !strings.HasPrefix(name, "__attach_version1.0_") || c.entries[n].kind != cfbStorage {
+			continue
+		}
+		if err := e.msgAttachment(c, n, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// msgAttachment writes an attachment of a .msg file: a file, or an
+// embedded message stored as a sub-storage
+func (e *extraction) msgAttachment(c *cfb, i uint32, depth int) error {
+	children := c.children(i)
+	name := ""
+	for _, id := range []string{propAttachLong, propAttachShort, propDisplayName} {
+		if n, ok := children["__substg1.0_"+id+"001F"]; ok {
+			if data, err := c.stream(n); err == nil {
+				if name = utf16String(data); name != "" {
+					break
+				}
+			}
+		}
+	}
+
+	if n, ok := children["__substg1.0_"+propAttachData+"000D"]; ok && c.entries[n].kind == cfbStorage {
+		if depth >= e.limits.MaxDepth || e.out.truncated {
+			return nil
+		}
+		if name == "" {
+			name = "message.msg"
+		}
+		mark := e.out.Len()
+		e.out.WriteString("\n\n" + name + "\n")
+		if err := e.message(c, n, depth+1); err != nil {
+			if errors.Is(err, ErrTooLarge) {
+				return err
+			}
+			e.out.Reset(mark)
+		}
+		return nil
+	}
+	n, ok := children["__substg1.0_"+propAttachData+"0102"]
+	if !ok {
+		return nil
+	}
+	data, err := c.stream(n)
+	if errors.Is(err, ErrTooLarge) {
+		return err
+	}
+	if err != nil {
+		// Skip a corrupt attachment like an unreadable one
+		return nil
+	}
+	return e.attachment(data, name, depth)
+}
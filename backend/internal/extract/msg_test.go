@@ -0,0 +1,186 @@
+package extract
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cfbNode is a storage (with children) or stream of a test compound file
+type cfbNode struct {
+	name     string
+	data     []byte
+	children []*cfbNode
+}
+
+func utf16LE(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+	return b
+}
+
+// msgProps returns the string property streams of a message or attachment
+func msgProps(props map[string]string) []*cfbNode {
+	var nodes []*cfbNode
+	for id, value := range props {
+		nodes = append(nodes, &cfbNode{name: "__substg1.0_" + id + "001F", data: utf16LE(value)})
+	}
+	return nodes
+}
+
+// buildCFB writes a compound file with 512-byte sectors. Every stream is
+// below the mini stream cutoff, and siblings are chained through their
+// right pointers.
+func buildCFB(children []*cfbNode) []byte {
+	const endOfChain, free, fatSect, noStream = 0xFFFFFFFE, 0xFFFFFFFF, 0xFFFFFFFD, 0xFFFFFFFF
+	type entry struct {
+		node               *cfbNode
+		kind               byte
+		left, right, child uint32
+		start              uint32
+		size               uint64
+	}
+	entries := []entry{{node: &cfbNode{name: "Root Entry"}, kind: cfbRoot, left: noStream, right: noStream, child: noStream}}
+	var miniStream, miniFAT []uint32
+	var miniData []byte
+	var add func(nodes []*cfbNode) uint32
+	add = func(nodes []*cfbNode) uint32 {
+		first, prev := uint32(noStream), -1
+		for _, n := range nodes {
+			i := len(entries)
+			e := entry{node: n, kind: cfbStream, left: noStream, right: noStream, child: noStream, start: endOfChain}
+			if n.children != nil {
+				e.kind = cfbStorage
+			} else if len(n.data) > 0 {
+				e.start, e.size = uint32(len(miniStream)), uint64(len(n.data))
+				for off := 0; off < len(n.data); off += 64 {
+					miniStream = append(miniStream, 0)
+					miniFAT = append(miniFAT, uint32(len(miniFAT)+1))
+					chunk := make([]byte, 64)
+					copy(chunk, n.data[off:])
+					miniData = append(miniData, chunk...)
+				}
+				miniFAT[len(miniFAT)-1] = endOfChain
+			}
+			entries = append(entries, e)
+			if n.children != nil {
+				entries[i].child = add(n.children)
+			}
+			if prev < 0 {
+				first = uint32(i)
+			} else {
+				entries[prev].right = uint32(i)
+			}
+			prev = i
+		}
+		return first
+	}
+	entries[0].child = add(children)
+
+	sectors := func(n int) int { return (n + 511) / 512 }
+	dirSectors := sectors(len(entries) * cfbEntrySize)
+	miniFATSectors := sectors(4 * len(miniFAT))
+	miniSectors := sectors(len(miniData))
+	dirStart := 1
+	miniFATStart := dirStart + dirSectors
+	miniStart := miniFATStart + miniFATSectors
+	total := miniStart + miniSectors
+	entries[0].start, entries[0].size = uint32(miniStart), uint64(len(miniData))
+
+	fat := make([]uint32, 128)
+	for i := range fat {
+		fat[i] = free
+	}
+	fat[0] = fatSect
+	chain := func(start, n int) {
+		for i := 0; i < n; i++ {
+			fat[start+i] = uint32(start + i + 1)
+		}
+		if n > 0 {
+			fat[start+n-1] = endOfChain
+		}
+	}
+	chain(dirStart, dirSectors)
+	chain(miniFATStart, miniFATSectors)
+	chain(miniStart, miniSectors)
+
+	out := make([]byte, cfbHeaderSize+512*total)
+	copy(out, oleMagic)
+	binary.LittleEndian.PutUint16(out[0x18:], 0x3E)
+	binary.LittleEndian.PutUint16(out[0x1A:], 3)
+	binary.LittleEndian.PutUint16(out[0x1C:], 0xFFFE)
+	binary.LittleEndian.PutUint16(out[0x1E:], 9)
+	binary.LittleEndian.PutUint16(out[0x20:], 6)
+	binary.LittleEndian.PutUint32(out[0x2C:], 1)
+	binary.LittleEndian.PutUint32(out[0x30:], uint32(dirStart))
+	binary.LittleEndian.PutUint32(out[0x38:], 4096)
+	miniFATHead := uint32(endOfChain)
+	if miniFATSectors > 0 {
+		miniFATHead = uint32(miniFATStart)
+	}
+	binary.LittleEndian.PutUint32(out[0x3C:], miniFATHead)
+	binary.LittleEndian.PutUint32(out[0x40:], uint32(miniFATSectors))
+	binary.LittleEndian.PutUint32(out[0x44:], endOfChain)
+	for i := 0; i < cfbHeaderDIFAT; i++ {
+		binary.LittleEndian.PutUint32(out[0x4C+4*i:], free)
+	}
+	binary.LittleEndian.PutUint32(out[0x4C:], 0)
+
+	sector := func(n int) []byte { return out[cfbHeaderSize+512*n : cfbHeaderSize+512*(n+1)] }
+	for i, v := range fat {
+		binary.LittleEndian.PutUint32(sector(0)[4*i:], v)
+	}
+	dir := out[cfbHeaderSize+512*dirStart:]
+	for i, e := range entries {
+		raw := dir[i*cfbEntrySize:]
+		name := utf16LE(e.node.name)
+		copy(raw, name)
+		binary.LittleEndian.PutUint16(raw[64:], uint16(len(name)+2))
+		raw[66] = e.kind
+		binary.LittleEndian.PutUint32(raw[68:], e.left)
+		binary.LittleEndian.PutUint32(raw[72:], e.right)
+		binary.LittleEndian.PutUint32(raw[76:], e.child)
+		binary.LittleEndian.PutUint32(raw[116:], e.start)
+		binary.LittleEndian.PutUint64(raw[120:], e.size)
+	}
+	for i, v := range miniFAT {
+		binary.LittleEndian.PutUint32(out[cfbHeaderSize+512*miniFATStart+4*i:], v)
+	}
+	copy(out[cfbHeaderSize+512*miniStart:], miniData)
+	return out
+}
+
+// TestExtractMSG verifies the headers, body and attachments of Outlook
+// messages, including embedded messages
+func TestExtractMSG(t *testing.T) {
+	embedded := append(msgProps(map[string]string{"0037": "Draft terms", propBody: "Exclusivity for 12 months."}),
+		&cfbNode{name: "__properties_version1.0", data: make([]byte, 32)})
+	msg := buildCFB(append(msgProps(map[string]string{
+		propSubject:     "Term sheet",
+		propSenderName:  "Dana Diaz",
+		propSenderEmail: "dana@acme.test",
+		propDisplayTo:   "Legal Team",
+		propBody:        "Attached are the files.",
+	}),
+		&cfbNode{name: "__attach_version1.0_#00000001", children: append(msgProps(map[string]string{propAttachLong: "Draft.msg"}),
+			&cfbNode{name: "__substg1.0_3701000D", children: embedded})},
+		&cfbNode{name: "__attach_version1.0_#00000000", children: append(msgProps(map[string]string{propAttachLong: "notes.txt"}),
+			&cfbNode{name: "__substg1.0_37010102", data: []byte("Price: 4.2M")})},
+		&cfbNode{name: "__attach_version1.0_#00000002", children: append(msgProps(map[string]string{propAttachLong: "logo.png"}),
+			&cfbNode{name: "__substg1.0_37010102", data: []byte{0x89, 'P', 'N', 'G', 0, 0}})},
+	))
+
+	result := extractText(t, msg, "term-sheet.msg")
+	assert.Equal(t, FormatMSG, result.Format)
+	assert.Equal(t, "From: Dana Diaz <dana@acme.test>\nTo: Legal Team\nSubject: Term sheet\n\nAttached are the files.\n\n"+
+		"notes.txt\nPrice: 4.2M\n\nDraft.msg\nSubject: Draft terms\n\nExclusivity for 12 months.", result.Text)
+
+	_, err := Extract(msg[:600], "cut.msg", DefaultLimits)
+	require.Error(t, err)
+}
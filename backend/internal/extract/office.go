@@ -0,0 +1,267 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// xmlRules say which elements of an XML part hold text
+type xmlRules struct {
+	// text are the elements whose character data is text; nil takes the
+	// character data of every element
+	text map[string]bool
+	// breaks are the elements that end a line, tabs those that are a tab
+	breaks map[string]bool
+	tabs   map[string]bool
+}
+
+var (
+	wordRules = xmlRules{
+		text:   map[string]bool{"t": true, "delText": true},
+		breaks: map[string]bool{"p": true, "br": true, "cr": true},
+		tabs:   map[string]bool{"tab": true},
+	}
+	slideRules = xmlRules{
+		text:   map[string]bool{"t": true},
+		breaks: map[string]bool{"p": true, "br": true},
+	}
+	odfRules = xmlRules{
+		breaks: map[string]bool{"p": true, "h": true, "line-break": true, "table-row": true},
+		tabs:   map[string]bool{"tab": true, "table-cell": true},
+	}
+)
+
+// office writes the text of a zipped Office Open XML or OpenDocument file
+func (e *extraction) office(data []byte, format Format) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		// Encrypted OOXML is an OLE file, but zip entries may be encrypted too
+		if f.Flags&0x1 != 0 {
+			return ErrEncrypted
+		}
+	}
+
+	var parts []string
+	rules := wordRules
+	switch format {
+	case FormatDOCX:
+		parts = append(parts, "word/document.xml")
+		parts = append(parts, numberedParts(zr, "word/header", ".xml")...)
+		parts = append(parts, numberedParts(zr, "word/footer", ".xml")...)
+		parts = append(parts, "word/footnotes.xml", "word/endnotes.xml", "word/comments.xml")
+	case FormatPPTX:
+		rules = slideRules
+		parts = append(numberedParts(zr, "ppt/slides/slide", ".xml"), numberedParts(zr, "ppt/notesSlides/notesSlide", ".xml")...)
+	case FormatXLSX:
+		return e.workbook(zr)
+	case FormatODT:
+		rules = odfRules
+		parts = []string{"content.xml"}
+	}
+
+	for _, name := range parts {
+		part, err := e.zipEntry(zr, name)
+		if err != nil {
+			return err
+		}
+		if part == nil {
+			continue
+		}
+		if err := e.xmlText(part, rules); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		e.out.WriteString("\n")
+	}
+	return nil
+}
+
+// xmlText writes the text of an XML part
+func (e *extraction) xmlText(data []byte, rules xmlRules) error {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	inText := 0
+	for !e.out.truncated {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case rules.text[t.Name.Local]:
+				inText++
+			case rules.tabs[t.Name.Local]:
+				e.out.WriteString("\t")
+			case rules.text == nil && t.Name.Local == "s":
+				// OpenDocument collapses runs of spaces into <text:s text:c="n"/>
+				e.out.WriteString(" ")
+			}
+		case xml.EndElement:
+			if rules.text[t.Name.Local] {
+				inText--
+			}
+			if rules.breaks[t.Name.Local] {
+				e.out.WriteString("\n")
+			}
+		case xml.CharData:
+			if rules.text == nil || inText > 0 {
+				e.out.WriteString(string(t))
+			}
+		}
+	}
+	return nil
+}
+
+// workbook writes the cells of every worksheet, one row per line
+func (e *extraction) workbook(zr *zip.Reader) error {
+	data, err := e.zipEntry(zr, "xl/sharedStrings.xml")
+	if err != nil {
+		return err
+	}
+	shared, err := sharedStrings(data)
+	if err != nil {
+		return fmt.Errorf("xl/sharedStrings.xml: %w", err)
+	}
+	for _, name := range numberedParts(zr, "xl/worksheets/sheet", ".xml") {
+		sheet, err := e.zipEntry(zr, name)
+		if err != nil {
+			return err
+		}
+		if err := e.worksheet(sheet, shared); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		e.out.WriteString("\n")
+	}
+	return nil
+}
+
+// sharedStrings parses the shared string table of a workbook
+func sharedStrings(data []byte) ([]string, error) {
+	var shared []string
+	if data == nil {
+		return shared, nil
+	}
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var current strings.Builder
+	inText := false
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return shared, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "si" {
+				current.Reset()
+			}
+			inText = t.Name.Local == "t"
+		case xml.EndElement:
+			inText = false
+			if t.Name.Local == "si" {
+				shared = append(shared, current.String())
+			}
+		case xml.CharData:
+			if inText {
+				current.Write(t)
+			}
+		}
+	}
+}
+
+// worksheet writes the values of a worksheet's cells
+func (e *extraction) worksheet(data []byte, shared []string) error {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var cellType string
+	var value strings.Builder
+	inValue := false
+	for !e.out.truncated {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "c":
+				cellType = ""
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "t" {
+						cellType = attr.Value
+					}
+				}
+				value.Reset()
+			case "v", "t":
+				inValue = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "v", "t":
+				inValue = false
+			case "c":
+				text := value.String()
+				if cellType == "s" {
+					i, err := strconv.Atoi(strings.TrimSpace(text))
+					if err != nil || i < 0 || i >= len(shared) {
+						continue
+					}
+					text = shared[i]
+				}
+				if text != "" {
+					e.out.WriteString(text + "\t")
+				}
+			case "row":
+				e.out.WriteString("\n")
+			}
+		case xml.CharData:
+			if inValue {
+				value.Write(t)
+			}
+		}
+	}
+	return nil
+}
+
+// numberedParts returns the names of zip entries prefix<N>suffix ordered by N
+func numberedParts(zr *zip.Reader, prefix, suffix string) []string {
+	type part struct {
+		name string
+		n    int
+	}
+	var parts []part
+	for _, f := range zr.File {
+		number, ok := strings.CutPrefix(f.Name, prefix)
+		if !ok {
+			continue
+		}
+		number, ok = strings.CutSuffix(number, suffix)
+		n, err := strconv.Atoi(number)
+		if !ok || err != nil {
+			continue
+		}
+		parts = append(parts, part{name: f.Name, n: n})
+	}
+	slices.SortFunc(parts, func(a, b part) int { return a.n - b.n })
+	names := make([]string, len(parts))
+	for i, p := range parts {
+		names[i] = p.name
+	}
+	return names
+}
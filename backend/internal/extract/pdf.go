@@ -0,0 +1,928 @@
+package extract
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+)
+
+// PDF object types; numbers are float64 and strings []byte
+type (
+	pdfName    string
+	pdfKeyword string
+	pdfDict    map[pdfName]any
+	pdfArray   []any
+	pdfRef     struct{ num, gen int }
+	pdfStream  struct {
+		dict pdfDict
+		raw  []byte
+	}
+)
+
+const (
+	// maxPDFNesting bounds nested arrays and dictionaries
+	maxPDFNesting = 64
+	// maxPDFFormDepth bounds form XObjects drawn inside each other
+	maxPDFFormDepth = 8
+	// maxPDFPageTree bounds the depth of the page tree
+	maxPDFPageTree = 64
+)
+
+var (
+	errPDFSyntax = errors.New("pdf: syntax error")
+	// pdfObjectStart matches "12 0 obj"
+	pdfObjectStart = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	// pdfTrailer matches the trailer dictionaries of classic cross-reference tables
+	pdfTrailer = regexp.MustCompile(`trailer\s*<<`)
+)
+
+// pdfLexer tokenizes PDF objects and content streams
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// skipSpace skips whitespace and comments
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// token returns the next token: a number, name, string, keyword or one of
+// the delimiters "<<", ">>", "[", "]", "{", "}"; nil at the end
+func (l *pdfLexer) token() (any, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, nil
+	}
+	c := l.data[l.pos]
+	switch {
+	case c == '(':
+		return l.literalString()
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		return pdfKeyword("<<"), nil
+	case c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+		l.pos += 2
+		return pdfKeyword(">>"), nil
+	case c == '<':
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			return nil, errPDFSyntax
+		}
+		digits := bytes.Map(func(r rune) rune {
+			if isPDFSpace(byte(r)) {
+				return -1
+			}
+			return r
+		}, l.data[l.pos+1:l.pos+end])
+		l.pos += end + 1
+		if len(digits)%2 == 1 {
+			digits = append(digits, '0')
+		}
+		s := make([]byte, hex.DecodedLen(len(digits)))
+		n, _ := hex.Decode(s, digits)
+		return s[:n], nil
+	case c == '[' || c == ']' || c == '{' || c == '}':
+		l.pos++
+		return pdfKeyword(c), nil
+	case c == '/':
+		l.pos++
+		start := l.pos
+		for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		return pdfName(decodeName(l.data[start:l.pos])), nil
+	case c == ')' || c == '>':
+		l.pos++
+		return nil, errPDFSyntax
+	}
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+	if n, err := strconv.ParseFloat(word, 64); err == nil && (word[0] == '-' || word[0] == '+' || word[0] == '.' || (word[0] >= '0' && word[0] <= '9')) {
+		return n, nil
+	}
+	return pdfKeyword(word), nil
+}
+
+// literalString reads a (string) with its escapes and balanced parentheses
+func (l *pdfLexer) literalString() ([]byte, error) {
+	var s []byte
+	depth := 0
+	for l.pos++; l.pos < len(l.data); l.pos++ {
+		c := l.data[l.pos]
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				l.pos++
+				return s, nil
+			}
+			depth--
+		case '\\':
+			l.pos++
+			if l.pos >= len(l.data) {
+				return s, nil
+			}
+			c = l.data[l.pos]
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos+1 < len(l.data) && l.data[l.pos+1] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					n := 0
+					for i := 0; i < 3 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					l.pos--
+					c = byte(n)
+				}
+			}
+		}
+		s = append(s, c)
+	}
+	return s, nil
+}
+
+// decodeName resolves the #xx escapes of a name
+func decodeName(b []byte) string {
+	if bytes.IndexByte(b, '#') < 0 {
+		return string(b)
+	}
+	var s []byte
+	for i := 0; i < len(b); i++ {
+		if b[i] == '#' && i+2 < len(b) {
+			if v, err := strconv.ParseUint(string(b[i+1:i+3]), 16, 8); err == nil {
+				s = append(s, byte(v))
+				i += 2
+				continue
+			}
+		}
+		s = append(s, b[i])
+	}
+	return string(s)
+}
+
+// object parses the object starting with token tok
+func (l *pdfLexer) object(tok any, depth int) (any, error) {
+	if depth > maxPDFNesting {
+		return nil, errPDFSyntax
+	}
+	switch t := tok.(type) {
+	case pdfKeyword:
+		switch t {
+		case "<<":
+			dict := pdfDict{}
+			for {
+				key, err := l.token()
+				if err != nil {
+					return nil, err
+				}
+				if key == pdfKeyword(">>") {
+					return dict, nil
+				}
+				name, ok := key.(pdfName)
+				if !ok {
+					return nil, errPDFSyntax
+				}
+				value, err := l.next(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				dict[name] = value
+			}
+		case "[":
+			var array pdfArray
+			for {
+				tok, err := l.token()
+				if err != nil {
+					return nil, err
+				}
+				if tok == nil {
+					return nil, errPDFSyntax
+				}
+				if tok == pdfKeyword("]") {
+					return array, nil
+				}
+				value, err := l.object(tok, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				array = append(array, value)
+			}
+		}
+	case float64:
+		// "12 0 R" is a reference
+		save := l.pos
+		gen, err := l.token()
+		if g, ok := gen.(float64); ok && err == nil {
+			if r, err := l.token(); err == nil && r == pdfKeyword("R") {
+				return pdfRef{num: int(t), gen: int(g)}, nil
+			}
+		}
+		l.pos = save
+	}
+	return tok, nil
+}
+
+// next parses the next object
+func (l *pdfLexer) next(depth int) (any, error) {
+	tok, err := l.token()
+	if err != nil {
+		return nil, err
+	}
+	if tok == nil {
+		return nil, errPDFSyntax
+	}
+	return l.object(tok, depth)
+}
+
+// pdfDoc holds the objects of a PDF file
+type pdfDoc struct {
+	e       *extraction
+	objects map[int]any
+	fonts   map[pdfRef]*pdfFont
+}
+
+// pdf writes the text of the pages of a PDF file
+func (e *extraction) pdf(data []byte) error {
+	doc := &pdfDoc{e: e, objects: map[int]any{}, fonts: map[pdfRef]*pdfFont{}}
+	for _, loc := range pdfTrailer.FindAllIndex(data, -1) {
+		l := &pdfLexer{data: data, pos: loc[1] - 2}
+		if trailer, err := l.next(0); err == nil {
+			if dict, ok := trailer.(pdfDict); ok && dict["Encrypt"] != nil {
+				return ErrEncrypted
+			}
+		}
+	}
+
+	// Objects are read in file order so incremental updates replace older
+	// versions, rather than through the cross-reference table
+	var objectStreams []pdfStream
+	for _, m := range pdfObjectStart.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		l := &pdfLexer{data: data, pos: m[1]}
+		value, err := l.next(0)
+		if err != nil {
+			continue
+		}
+		if dict, ok := value.(pdfDict); ok {
+			if stream, ok := streamAfter(data, l, dict); ok {
+				switch dict["Type"] {
+				case pdfName("XRef"):
+					if dict["Encrypt"] != nil {
+						return ErrEncrypted
+					}
+				case pdfName("ObjStm"):
+					objectStreams = append(objectStreams, stream)
+				}
+				value = stream
+			}
+		}
+		doc.objects[num] = value
+	}
+	for _, stream := range objectStreams {
+		if err := doc.objectStream(stream); err != nil {
+			return err
+		}
+	}
+
+	pages, err := doc.pages()
+	if err != nil {
+		return err
+	}
+	for _, page := range pages {
+		if e.out.truncated {
+			break
+		}
+		content, err := doc.contents(page.dict["Contents"])
+		if err != nil {
+			return err
+		}
+		if err := doc.run(content, page.resources, 0); err != nil {
+			return err
+		}
+		e.out.WriteString("\n\n")
+	}
+	return nil
+}
+
+// streamAfter reads the stream following dict, if any
+func streamAfter(data []byte, l *pdfLexer, dict pdfDict) (pdfStream, bool) {
+	l.skipSpace()
+	if !bytes.HasPrefix(data[l.pos:], []byte("stream")) {
+		return pdfStream{}, false
+	}
+	start := l.pos + len("stream")
+	if start < len(data) && data[start] == '\r' {
+		start++
+	}
+	if start < len(data) && data[start] == '\n' {
+		start++
+	}
+	// Trust /Length only when a direct number ending at endstream
+	if length, ok := dict["Length"].(float64); ok && length >= 0 && start+int(length) <= len(data) {
+		end := start + int(length)
+		rest := bytes.TrimLeft(data[end:min(end+16, len(data))], "\r\n \t")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			return pdfStream{dict: dict, raw: data[start:end]}, true
+		}
+	}
+	end := bytes.Index(data[start:], []byte("endstream"))
+	if end < 0 {
+		return pdfStream{}, false
+	}
+	return pdfStream{dict: dict, raw: bytes.TrimRight(data[start:start+end], "\r\n")}, true
+}
+
+// objectStream adds the objects compressed into an object stream, unless
+// defined directly
+func (d *pdfDoc) objectStream(stream pdfStream) error {
+	data, err := d.decode(stream)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return nil
+	}
+	n, _ := stream.dict["N"].(float64)
+	first, _ := stream.dict["First"].(float64)
+	l := &pdfLexer{data: data}
+	for i := 0; i < int(n); i++ {
+		num, err1 := l.token()
+		offset, err2 := l.token()
+		numValue, ok1 := num.(float64)
+		offsetValue, ok2 := offset.(float64)
+		if err1 != nil || err2 != nil || !ok1 || !ok2 {
+			break
+		}
+		pos := int(first) + int(offsetValue)
+		if pos < 0 || pos >= len(data) {
+			continue
+		}
+		if _, exists := d.objects[int(numValue)]; exists {
+			continue
+		}
+		ol := &pdfLexer{data: data, pos: pos}
+		if value, err := ol.next(0); err == nil {
+			d.objects[int(numValue)] = value
+		}
+	}
+	return nil
+}
+
+// resolve follows references
+func (d *pdfDoc) resolve(v any) any {
+	for i := 0; i < 16; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = d.objects[ref.num]
+	}
+	return nil
+}
+
+func (d *pdfDoc) dict(v any) pdfDict {
+	switch v := d.resolve(v).(type) {
+	case pdfDict:
+		return v
+	case pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+// decode returns the decoded data of a stream, nil for unsupported filters
+func (d *pdfDoc) decode(stream pdfStream) ([]byte, error) {
+	var filters []any
+	switch f := d.resolve(stream.dict["Filter"]).(type) {
+	case pdfName:
+		filters = []any{f}
+	case pdfArray:
+		filters = f
+	}
+	data := stream.raw
+	for _, f := range filters {
+		var err error
+		switch d.resolve(f) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			data, err = d.inflate(data)
+		case pdfName("ASCIIHexDecode"), pdfName("AHx"):
+			l := &pdfLexer{data: append(append([]byte{'<'}, bytes.TrimSuffix(bytes.TrimSpace(data), []byte(">"))...), '>')}
+			var tok any
+			tok, err = l.token()
+			data, _ = tok.([]byte)
+		case pdfName("ASCII85Decode"), pdfName("A85"):
+			data = bytes.TrimSuffix(bytes.TrimSpace(data), []byte("~>"))
+			data, err = d.e.expand(ascii85.NewDecoder(bytes.NewReader(data)))
+		default:
+			// Image and LZW data has no text we read
+			return nil, nil
+		}
+		if errors.Is(err, ErrTooLarge) {
+			return nil, err
+		}
+		if err != nil && len(data) == 0 {
+			return nil, nil
+		}
+	}
+	return data, nil
+}
+
+// inflate decompresses zlib data, keeping what precedes a corrupt tail
+func (d *pdfDoc) inflate(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		// Some writers omit the zlib header
+		return d.e.expand(flate.NewReader(bytes.NewReader(data)))
+	}
+	defer zr.Close()
+	return d.e.expand(zr)
+}
+
+// pdfPage is a page with its inherited resources
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages returns the pages in order from the page tree, or every page object
+// when the tree is broken
+func (d *pdfDoc) pages() ([]pdfPage, error) {
+	var pages []pdfPage
+	visited := map[int]bool{}
+	var walk func(node any, resources pdfDict, depth int)
+	walk = func(node any, resources pdfDict, depth int) {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref.num] {
+				return
+			}
+			visited[ref.num] = true
+		}
+		dict := d.dict(node)
+		if dict == nil || depth > maxPDFPageTree {
+			return
+		}
+		if r := d.dict(dict["Resources"]); r != nil {
+			resources = r
+		}
+		kids, isNode := d.resolve(dict["Kids"]).(pdfArray)
+		if !isNode {
+			pages = append(pages, pdfPage{dict: dict, resources: resources})
+			return
+		}
+		for _, kid := range kids {
+			walk(kid, resources, depth+1)
+		}
+	}
+
+	var nums []int
+	for num := range d.objects {
+		nums = append(nums, num)
+	}
+	slices.Sort(nums)
+	for _, num := range slices.Backward(nums) {
+		if catalog := d.dict(d.objects[num]); catalog["Type"] == pdfName("Catalog") {
+			walk(catalog["Pages"], nil, 0)
+			break
+		}
+	}
+	if len(pages) > 0 {
+		return pages, nil
+	}
+	for _, num := range nums {
+		if dict := d.dict(d.objects[num]); dict["Type"] == pdfName("Page") {
+			pages = append(pages, pdfPage{dict: dict, resources: d.dict(dict["Resources"])})
+		}
+	}
+	return pages, nil
+}
+
+// contents returns the concatenated content streams of a page
+func (d *pdfDoc) contents(v any) ([]byte, error) {
+	var streams []any
+	switch c := d.resolve(v).(type) {
+	case pdfStream:
+		streams = []any{c}
+	case pdfArray:
+		streams = c
+	}
+	var out []byte
+	for _, s := range streams {
+		stream, ok := d.resolve(s).(pdfStream)
+		if !ok {
+			continue
+		}
+		data, err := d.decode(stream)
+		if err != nil {
+			return nil, err
+		}
+		out = append(append(out, data...), '\n')
+	}
+	return out, nil
+}
+
+// run interprets a content stream, writing the text it shows
+func (d *pdfDoc) run(content []byte, resources pdfDict, depth int) error {
+	l := &pdfLexer{data: content}
+	var operands []any
+	var font *pdfFont
+	lastY := math.NaN()
+	for !d.e.out.truncated {
+		tok, err := l.token()
+		if err != nil {
+			// Skip a stray delimiter and go on
+			operands = operands[:0]
+			continue
+		}
+		if tok == nil {
+			return nil
+		}
+		op, isOp := tok.(pdfKeyword)
+		if !isOp || op == "<<" || op == "[" {
+			value, err := l.object(tok, 0)
+			if err != nil {
+				operands = operands[:0]
+				continue
+			}
+			if len(operands) < 64 {
+				operands = append(operands, value)
+			}
+			continue
+		}
+
+		switch op {
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[0].(pdfName); ok {
+					font = d.font(d.dict(resources["Font"])[name])
+				}
+			}
+		case "Tj":
+			if len(operands) >= 1 {
+				d.show(font, operands[0])
+			}
+		case "'", "\"":
+			d.e.out.WriteString("\n")
+			if len(operands) >= 1 {
+				d.show(font, operands[len(operands)-1])
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				array, _ := operands[0].(pdfArray)
+				for _, item := range array {
+					// A large negative adjustment separates words
+					if n, ok := item.(float64); ok && n < -200 {
+						d.e.out.WriteString(" ")
+						continue
+					}
+					d.show(font, item)
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, _ := operands[1].(float64); ty != 0 {
+					d.e.out.WriteString("\n")
+				} else {
+					d.e.out.WriteString(" ")
+				}
+			}
+		case "T*":
+			d.e.out.WriteString("\n")
+		case "Tm":
+			if len(operands) >= 6 {
+				y, _ := operands[5].(float64)
+				if y != lastY {
+					d.e.out.WriteString("\n")
+				} else {
+					d.e.out.WriteString(" ")
+				}
+				lastY = y
+			}
+		case "ET":
+			d.e.out.WriteString(" ")
+		case "Do":
+			if len(operands) >= 1 && depth < maxPDFFormDepth {
+				name, _ := operands[0].(pdfName)
+				form, ok := d.resolve(d.dict(resources["XObject"])[name]).(pdfStream)
+				if ok && form.dict["Subtype"] == pdfName("Form") {
+					data, err := d.decode(form)
+					if err != nil {
+						return err
+					}
+					formResources := d.dict(form.dict["Resources"])
+					if formResources == nil {
+						formResources = resources
+					}
+					if err := d.run(data, formResources, depth+1); err != nil {
+						return err
+					}
+				}
+			}
+		case "ID":
+			// Inline image data runs to EI
+			end := bytes.Index(l.data[l.pos:], []byte("EI"))
+			for end >= 0 {
+				after := l.pos + end + 2
+				if after >= len(l.data) || isPDFSpace(l.data[after]) {
+					break
+				}
+				next := bytes.Index(l.data[after:], []byte("EI"))
+				if next < 0 {
+					end = -1
+					break
+				}
+				end = after - l.pos + next
+			}
+			if end < 0 {
+				return nil
+			}
+			l.pos += end + 2
+		}
+		operands = operands[:0]
+	}
+	return nil
+}
+
+// show writes a string shown with font
+func (d *pdfDoc) show(font *pdfFont, v any) {
+	s, ok := v.([]byte)
+	if !ok {
+		return
+	}
+	if font == nil {
+		font = &pdfFont{codeLen: 1}
+	}
+	d.e.out.WriteString(font.decode(s))
+}
+
+// pdfFont maps the character codes of a font to text
+type pdfFont struct {
+	// toUnicode is the font's ToUnicode CMap by code
+	toUnicode map[string]string
+	// codeLen is the code length in bytes: 2 for composite fonts
+	codeLen int
+	// encoding maps the codes of a simple font; nil is Windows-1252
+	encoding map[byte]string
+}
+
+// font loads the font at v
+func (d *pdfDoc) font(v any) *pdfFont {
+	ref, isRef := v.(pdfRef)
+	if isRef {
+		if f, ok := d.fonts[ref]; ok {
+			return f
+		}
+	}
+	dict := d.dict(v)
+	f := &pdfFont{codeLen: 1}
+	if dict["Subtype"] == pdfName("Type0") {
+		f.codeLen = 2
+	}
+	if cmap, ok := d.resolve(dict["ToUnicode"]).(pdfStream); ok {
+		if data, err := d.decode(cmap); err == nil && data != nil {
+			f.toUnicode, f.codeLen = parseToUnicode(data, f.codeLen)
+		}
+	}
+	if enc := d.dict(dict["Encoding"]); enc != nil {
+		if differences, ok := d.resolve(enc["Differences"]).(pdfArray); ok {
+			f.encoding = map[byte]string{}
+			code := 0
+			for _, item := range differences {
+				switch item := d.resolve(item).(type) {
+				case float64:
+					code = int(item)
+				case pdfName:
+					if code >= 0 && code < 256 {
+						f.encoding[byte(code)] = glyphText(string(item))
+					}
+					code++
+				}
+			}
+		}
+	}
+	if isRef {
+		d.fonts[ref] = f
+	}
+	return f
+}
+
+// decode maps the codes of a shown string to text
+func (f *pdfFont) decode(s []byte) string {
+	var out strings.Builder
+	for i := 0; i+f.codeLen <= len(s); i += f.codeLen {
+		code := s[i : i+f.codeLen]
+		if text, ok := f.toUnicode[string(code)]; ok {
+			out.WriteString(text)
+			continue
+		}
+		if f.codeLen != 1 {
+			// Glyph IDs of composite fonts mean nothing without a CMap
+			continue
+		}
+		if text, ok := f.encoding[code[0]]; ok {
+			out.WriteString(text)
+			continue
+		}
+		out.WriteString(decodeWith(charmap.Windows1252, code))
+	}
+	return out.String()
+}
+
+// parseToUnicode reads the bfchar and bfrange mappings of a ToUnicode CMap
+// and the code length of its codespace
+func parseToUnicode(data []byte, codeLen int) (map[string]string, int) {
+	cmap := map[string]string{}
+	l := &pdfLexer{data: data}
+	var operands []any
+	mode := ""
+	for len(cmap) < 1<<16 {
+		tok, err := l.token()
+		if err != nil {
+			continue
+		}
+		if tok == nil {
+			break
+		}
+		if kw, ok := tok.(pdfKeyword); ok && kw != "[" {
+			switch kw {
+			case "begincodespacerange", "beginbfchar", "beginbfrange":
+				mode = string(kw)
+			case "endcodespacerange":
+				if len(operands) >= 1 {
+					if lo, ok := operands[0].([]byte); ok && (len(lo) == 1 || len(lo) == 2) {
+						codeLen = len(lo)
+					}
+				}
+				mode = ""
+			case "endbfchar":
+				for i := 0; i+1 < len(operands); i += 2 {
+					src, ok1 := operands[i].([]byte)
+					dst, ok2 := operands[i+1].([]byte)
+					if ok1 && ok2 {
+						cmap[string(src)] = utf16BE(dst)
+					}
+				}
+				mode = ""
+			case "endbfrange":
+				for i := 0; i+2 < len(operands); i += 3 {
+					lo, ok1 := operands[i].([]byte)
+					hi, ok2 := operands[i+1].([]byte)
+					if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) == 0 || len(lo) > 2 {
+						continue
+					}
+					from, to := codeValue(lo), codeValue(hi)
+					for code := from; code <= to && code-from < 1<<16; code++ {
+						key := codeBytes(code, len(lo))
+						switch dst := operands[i+2].(type) {
+						case []byte:
+							// Ranges increment the last character of the destination
+							if len(dst) >= 2 {
+								next := slices.Clone(dst)
+								v := int(next[len(next)-2])<<8 | int(next[len(next)-1]) + code - from
+								next[len(next)-2], next[len(next)-1] = byte(v>>8), byte(v)
+								cmap[key] = utf16BE(next)
+							}
+						case pdfArray:
+							if code-from < len(dst) {
+								if b, ok := dst[code-from].([]byte); ok {
+									cmap[key] = utf16BE(b)
+								}
+							}
+						}
+					}
+				}
+				mode = ""
+			}
+			operands = operands[:0]
+			continue
+		}
+		if mode == "" {
+			continue
+		}
+		value, err := l.object(tok, 0)
+		if err == nil {
+			operands = append(operands, value)
+		}
+	}
+	return cmap, codeLen
+}
+
+func codeValue(b []byte) int {
+	v := 0
+	for _, c := range b {
+		v = v<<8 | int(c)
+	}
+	return v
+}
+
+func codeBytes(v, n int) string {
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return string(b)
+}
+
+// utf16BE decodes big-endian UTF-16 as used by CMaps
+func utf16BE(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// glyphNames maps common glyph names of /Differences to text
+var glyphNames = map[string]string{
+	"space": " ", "exclam": "!", "quotedbl": "\"", "numbersign": "#", "dollar": "$", "percent": "%",
+	"ampersand": "&", "quotesingle": "'", "quoteright": "’", "quoteleft": "‘", "parenleft": "(",
+	"parenright": ")", "asterisk": "*", "plus": "+", "comma": ",", "hyphen": "-", "minus": "-",
+	"period": ".", "slash": "/", "colon": ":", "semicolon": ";", "less": "<", "equal": "=", "greater": ">",
+	"question": "?", "at": "@", "bracketleft": "[", "backslash": "\\", "bracketright": "]",
+	"underscore": "_", "braceleft": "{", "bar": "|", "braceright": "}", "quotedblleft": "“",
+	"quotedblright": "”", "endash": "–", "emdash": "—", "bullet": "•", "ellipsis": "…", "fi": "fi",
+	"fl": "fl", "ff": "ff", "ffi": "ffi", "ffl": "ffl", "germandbls": "ß", "euro": "€", "Euro": "€",
+	"zero": "0", "one": "1", "two": "2", "three": "3", "four": "4", "five": "5", "six": "6",
+	"seven": "7", "eight": "8", "nine": "9",
+}
+
+// glyphAccents maps the suffixes of accented glyph names to combining marks
+var glyphAccents = map[string]string{
+	"acute": "́", "grave": "̀", "circumflex": "̂", "dieresis": "̈",
+	"tilde": "̃", "ring": "̊", "cedilla": "̧", "caron": "̌",
+}
+
+// glyphText returns the text of a glyph name such as "a", "eacute" or "uni00E9"
+func glyphText(name string) string {
+	if text, ok := glyphNames[name]; ok {
+		return text
+	}
+	if len(name) == 1 {
+		return name
+	}
+	if hexValue, ok := strings.CutPrefix(name, "uni"); ok && len(hexValue) == 4 {
+		if v, err := strconv.ParseUint(hexValue, 16, 16); err == nil {
+			return string(rune(v))
+		}
+	}
+	for suffix, mark := range glyphAccents {
+		if base, ok := strings.CutSuffix(name, suffix); ok && len(base) == 1 {
+			return norm.NFC.String(base + mark)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pdfOf assembles a PDF of objects numbered from 1 with a trailer; empty
+// objects are left out, e.g. for those in object streams
+func pdfOf(trailer string, objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	for i, obj := range objects {
+		if obj != "" {
+			fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+		}
+	}
+	fmt.Fprintf(&buf, "trailer\n%s\n%%%%EOF\n", trailer)
+	return buf.Bytes()
+}
+
+// pdfStreamOf returns a stream object; dict holds extra entries
+func pdfStreamOf(dict string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func deflate(data string) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write([]byte(data))
+	zw.Close()
+	return buf.Bytes()
+}
+
+// TestExtractPDF verifies text of simple fonts in page order, with spacing
+// from text positioning and glyph names of font encodings
+func TestExtractPDF(t *testing.T) {
+	content := `BT /F1 12 Tf 72 720 Td (Quarterly) Tj ( report) Tj 0 -14 Td [(Tot) 20 (al) -300 (\(net\)) ] TJ T* (Caf\351 ) Tj <41> Tj ET`
+	pdf := pdfOf("<< /Root 1 0 R /Size 6 >>",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /Resources << /Font << /F1 5 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		pdfStreamOf("", []byte(content)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding << /Differences [65 /eacute] >> >>",
+	)
+	result := extractText(t, pdf, "report.pdf")
+	assert.Equal(t, FormatPDF, result.Format)
+	assert.Equal(t, "Quarterly report\nTotal (net)\nCafé é", result.Text)
+}
+
+// TestExtractPDFCompressed verifies compressed content, composite fonts
+// with ToUnicode CMaps, object streams, form XObjects and page order
+func TestExtractPDFCompressed(t *testing.T) {
+	cmap := `/CIDInit /ProcSet findresource begin 12 dict begin begincmap
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+2 beginbfchar <0001> <0048> <0002> <0069> endbfchar
+1 beginbfrange <0010> <0012> <0061> endbfrange
+1 beginbfrange <0020> <0021> [<00DC> <D83DDE00>] endbfrange
+endcmap end end`
+	// Objects 5 and 6 live in an object stream
+	font := "<< /Type /Font /Subtype /Type0 /BaseFont /ABC /Encoding /Identity-H /ToUnicode 7 0 R >>"
+	page := "<< /Type /Page /Parent 2 0 R /Contents [9 0 R 10 0 R] /Resources << /Font << /F1 5 0 R >> /XObject << /X1 11 0 R >> >> >>"
+	offsets := fmt.Sprintf("5 0 6 %d ", len(font)+1)
+	objstm := offsets + font + " " + page
+	pdf := pdfOf("<< /Root 1 0 R /Size 12 >>",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [6 0 R 3 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		pdfStreamOf("/Filter /FlateDecode", deflate("BT /F1 10 Tf <00100011001200200021> Tj ET")),
+		"",
+		"",
+		pdfStreamOf("/Filter /FlateDecode", deflate(cmap)),
+		pdfStreamOf(fmt.Sprintf("/Type /ObjStm /N 2 /First %d /Filter /FlateDecode", len(offsets)), deflate(objstm)),
+		pdfStreamOf("/Filter [/FlateDecode]", deflate("BT /F1 10 Tf <00010002> Tj ET")),
+		pdfStreamOf("", []byte("q /X1 Do Q")),
+		pdfStreamOf("/Type /XObject /Subtype /Form", []byte("BT /F1 10 Tf 1 0 0 1 0 500 Tm <0002> Tj ET")),
+	)
+	result := extractText(t, pdf, "scan.pdf")
+	assert.Equal(t, "Hi\ni\n\nabcÜ😀", result.Text)
+}
+
+// TestExtractPDFEncrypted verifies encrypted PDFs are reported as such
+func TestExtractPDFEncrypted(t *testing.T) {
+	pdf := pdfOf("<< /Root 1 0 R /Encrypt 3 0 R >>",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [] /Count 0 >>",
+		"<< /Filter /Standard /V 2 >>",
+	)
+	_, err := Extract(pdf, "locked.pdf", DefaultLimits)
+	require.ErrorIs(t, err, ErrEncrypted)
+}
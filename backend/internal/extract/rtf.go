@@ -0,0 +1,183 @@
+package extract
+
+import (
+	"errors"
+	"strconv"
+	"unicode/utf16"
+)
+
+// maxRTFDepth bounds the nesting of RTF groups
+const maxRTFDepth = 256
+
+// rtfSkipped are destinations that hold no document text
+var rtfSkipped = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true, "pict": true, "objdata": true,
+	"themedata": true, "colorschememapping": true, "datastore": true, "latentstyles": true, "listtable": true,
+	"listoverridetable": true, "rsidtbl": true, "generator": true, "xmlnstbl": true, "fldinst": true,
+	"datafield": true, "filetbl": true, "revtbl": true, "userprops": true, "mmathPr": true,
+}
+
+// rtfSymbols are control words that stand for text
+var rtfSymbols = map[string]string{
+	"par": "\n", "line": "\n", "sect": "\n", "page": "\n", "row": "\n",
+	"tab": "\t", "cell": "\t",
+	"emdash": "—", "endash": "–", "bullet": "•",
+	"lquote": "‘", "rquote": "’", "ldblquote": "“", "rdblquote": "”",
+}
+
+// rtfCodepages names the charsets of \ansicpg values other than windows-<n>
+var rtfCodepages = map[int]string{932: "shift_jis", 936: "gbk", 949: "euc-kr", 950: "big5", 65001: "utf-8"}
+
+// rtfGroup is the state saved by {
+type rtfGroup struct {
+	// skip drops the text of destinations without document text
+	skip bool
+	// uc is the number of fallback characters after \u
+	uc int
+}
+
+// rtf writes the text of an RTF document
+func (e *extraction) rtf(data []byte) error {
+	var (
+		stack     []rtfGroup
+		group     = rtfGroup{uc: 1}
+		pending   []byte // 8-bit text not yet decoded
+		codepage  = "windows-1252"
+		skipChars int  // fallback characters still to drop after \u
+		high      rune // high surrogate of a pair written as two \u
+	)
+	flush := func() {
+		if len(pending) > 0 {
+			e.out.WriteString(decodeCharset(pending, codepage))
+			pending = pending[:0]
+		}
+	}
+	text := func(s string) {
+		if !group.skip {
+			flush()
+			e.out.WriteString(s)
+		}
+	}
+	char := func(b byte) {
+		if skipChars > 0 {
+			skipChars--
+			return
+		}
+		if !group.skip {
+			pending = append(pending, b)
+		}
+	}
+
+	for i := 0; i < len(data) && !e.out.truncated; {
+		c := data[i]
+		i++
+		switch c {
+		case '{':
+			flush()
+			if len(stack) >= maxRTFDepth {
+				return errors.New("rtf: groups nested too deeply")
+			}
+			stack = append(stack, group)
+		case '}':
+			flush()
+			if len(stack) > 0 {
+				group = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case '\r', '\n':
+		case '\\':
+			if i >= len(data) {
+				break
+			}
+			c = data[i]
+			switch {
+			case c == '\'':
+				if i+3 > len(data) {
+					i = len(data)
+					break
+				}
+				b, err := strconv.ParseUint(string(data[i+1:i+3]), 16, 8)
+				i += 3
+				if err == nil {
+					char(byte(b))
+				}
+			case isASCIILetter(c):
+				start := i
+				for i < len(data) && isASCIILetter(data[i]) {
+					i++
+				}
+				word := string(data[start:i])
+				paramStart := i
+				if i < len(data) && data[i] == '-' {
+					i++
+				}
+				for i < len(data) && data[i] >= '0' && data[i] <= '9' {
+					i++
+				}
+				param, hasParam := 0, i > paramStart
+				if hasParam {
+					param, _ = strconv.Atoi(string(data[paramStart:i]))
+				}
+				if i < len(data) && data[i] == ' ' {
+					i++
+				}
+
+				switch {
+				case rtfSkipped[word]:
+					group.skip = true
+				case word == "ansicpg":
+					codepage = "windows-" + strconv.Itoa(param)
+					if name, ok := rtfCodepages[param]; ok {
+						codepage = name
+					}
+				case word == "uc":
+					group.uc = param
+				case word == "u":
+					r := rune(param)
+					if r < 0 {
+						r += 0x10000
+					}
+					switch {
+					case utf16.IsSurrogate(r) && r < 0xDC00:
+						high = r
+					case utf16.IsSurrogate(r):
+						text(string(utf16.DecodeRune(high, r)))
+						high = 0
+					default:
+						text(string(r))
+					}
+					skipChars = group.uc
+				case word == "bin":
+					// Binary data of pictures and objects
+					i += max(param, 0)
+				default:
+					if s, ok := rtfSymbols[word]; ok {
+						text(s)
+					}
+				}
+			default:
+				i++
+				switch c {
+				case '\\', '{', '}':
+					char(c)
+				case '~':
+					text(" ")
+				case '_':
+					text("-")
+				case '*':
+					group.skip = true
+				case '\r', '\n':
+					text("\n")
+				}
+			}
+		default:
+			char(c)
+		}
+	}
+	flush()
+	return nil
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
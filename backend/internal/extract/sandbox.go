@@ -0,0 +1,255 @@
+package extract
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// ChildFlag starts the server binary as a sandboxed extraction process; see
+// ServeChild
+const ChildFlag = "--extract-text"
+
+var (
+	// ErrTimeout is returned when a document takes longer than
+	// SandboxConfig.Timeout
+	ErrTimeout = errors.New("extraction timed out")
+	// ErrFailed is returned when a document cannot be parsed or the
+	// extraction process dies on it, e.g. by exceeding its memory limit
+	ErrFailed = errors.New("extraction failed")
+)
+
+// Error kinds of a child response, mapped back to the errors above
+const (
+	kindUnsupported = "unsupported"
+	kindEncrypted   = "encrypted"
+	kindTooLarge    = "too_large"
+	kindFailed      = "failed"
+)
+
+// maxStderrBytes bounds the diagnostics kept from a failed child
+const maxStderrBytes = 4096
+
+// SandboxConfig bounds the child processes of a Sandbox
+type SandboxConfig struct {
+	// Limits bound the extraction itself (default DefaultLimits)
+	Limits Limits
+	// MaxInputBytes rejects larger documents unread (default 50 MB)
+	MaxInputBytes int64
+	// Timeout is the wall-clock limit per document (default 30s)
+	Timeout time.Duration
+	// MemoryLimit caps the memory of the child process (default 512 MB)
+	MemoryLimit int64
+}
+
+// Sandbox extracts text in a child process, so a hostile or broken document
+// can at worst kill that process. The child gets an empty environment and
+// no files, a CPU, memory and file size limit where the platform has them,
+// and is killed with its process group at the timeout.
+type Sandbox struct {
+	cfg SandboxConfig
+	// command builds the child process; tests run their own binary
+	command func(ctx context.Context) *exec.Cmd
+}
+
+// childRequest heads the document on the child's stdin
+type childRequest struct {
+	Filename    string        `json:"filename"`
+	Limits      Limits        `json:"limits"`
+	MemoryLimit int64         `json:"memory_limit"`
+	CPUTime     time.Duration `json:"cpu_time"`
+}
+
+// childResponse is written by the child to stdout
+type childResponse struct {
+	Result *Result `json:"result,omitempty"`
+	Kind   string  `json:"kind,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// NewSandbox creates a sandbox running the current executable with ChildFlag
+func NewSandbox(cfg SandboxConfig) (*Sandbox, error) {
+	if cfg.Limits == (Limits{}) {
+		cfg.Limits = DefaultLimits
+	}
+	if cfg.MaxInputBytes <= 0 {
+		cfg.MaxInputBytes = 50 << 20
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.MemoryLimit <= 0 {
+		cfg.MemoryLimit = 512 << 20
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate executable: %w", err)
+	}
+	return &Sandbox{
+		cfg: cfg,
+		command: func(ctx context.Context) *exec.Cmd {
+			cmd := exec.CommandContext(ctx, exe, ChildFlag)
+			cmd.Env = []string{}
+			return cmd
+		},
+	}, nil
+}
+
+// MaxInputBytes is the size of the largest document the sandbox reads
+func (s *Sandbox) MaxInputBytes() int64 {
+	return s.cfg.MaxInputBytes
+}
+
+// Extract returns the text of a document. Errors for the document itself
+// wrap ErrUnsupported, ErrEncrypted, ErrTooLarge, ErrTimeout or ErrFailed;
+// others mean the extraction could not be run and may be retried.
+func (s *Sandbox) Extract(ctx context.Context, data []byte, filename string) (*Result, error) {
+	if int64(len(data)) > s.cfg.MaxInputBytes {
+		return nil, ErrTooLarge
+	}
+	header, err := json.Marshal(childRequest{
+		Filename:    filename,
+		Limits:      s.cfg.Limits,
+		MemoryLimit: s.cfg.MemoryLimit,
+		CPUTime:     s.cfg.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	cmd := s.command(runCtx)
+	cmd.Dir = os.TempDir()
+	cmd.Stdin = io.MultiReader(bytes.NewReader(header), strings.NewReader("\n"), bytes.NewReader(data))
+	// JSON escaping at most sextuples the text
+	stdout := &limitedBuffer{max: 6*s.cfg.Limits.MaxTextBytes + 4096}
+	stderr := &limitedBuffer{max: maxStderrBytes}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	isolate(cmd)
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return nil, ErrTimeout
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("%w: %v: %s", ErrFailed, exitErr, strings.TrimSpace(firstLine(stderr.String())))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to run extraction process: %w", err)
+	}
+	if stdout.overflow {
+		return nil, fmt.Errorf("%w: output exceeds limit", ErrFailed)
+	}
+
+	var resp childResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("%w: invalid response: %v", ErrFailed, err)
+	}
+	switch resp.Kind {
+	case "":
+		if resp.Result == nil {
+			return nil, fmt.Errorf("%w: empty response", ErrFailed)
+		}
+		return resp.Result, nil
+	case kindUnsupported:
+		return nil, ErrUnsupported
+	case kindEncrypted:
+		return nil, ErrEncrypted
+	case kindTooLarge:
+		return nil, ErrTooLarge
+	}
+	return nil, fmt.Errorf("%w: %s", ErrFailed, resp.Error)
+}
+
+// ServeChild is the main function of the extraction process: it reads a
+// request and document from stdin, writes the response to stdout and
+// returns the exit code
+func ServeChild(stdin io.Reader, stdout io.Writer) int {
+	r := bufio.NewReader(stdin)
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read request: %v\n", err)
+		return 2
+	}
+	var req childRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		fmt.Fprintf(os.Stderr, "decode request: %v\n", err)
+		return 2
+	}
+	if req.MemoryLimit > 0 {
+		// The soft limit makes the GC work hard before the hard limit kills
+		debug.SetMemoryLimit(req.MemoryLimit * 3 / 4)
+	}
+	if err := restrict(req.MemoryLimit, req.CPUTime); err != nil {
+		fmt.Fprintf(os.Stderr, "restrict process: %v\n", err)
+		return 2
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read document: %v\n", err)
+		return 2
+	}
+
+	if err := json.NewEncoder(stdout).Encode(serve(data, req)); err != nil {
+		fmt.Fprintf(os.Stderr, "write response: %v\n", err)
+		return 2
+	}
+	return 0
+}
+
+// serve extracts the document, turning parser panics into failures
+func serve(data []byte, req childRequest) (resp childResponse) {
+	defer func() {
+		if r := recover(); r != nil {
+			resp = childResponse{Kind: kindFailed, Error: fmt.Sprintf("panic: %v", r)}
+		}
+	}()
+	result, err := Extract(data, req.Filename, req.Limits)
+	switch {
+	case err == nil:
+		return childResponse{Result: result}
+	case errors.Is(err, ErrUnsupported):
+		return childResponse{Kind: kindUnsupported, Error: err.Error()}
+	case errors.Is(err, ErrEncrypted):
+		return childResponse{Kind: kindEncrypted, Error: err.Error()}
+	case errors.Is(err, ErrTooLarge):
+		return childResponse{Kind: kindTooLarge, Error: err.Error()}
+	}
+	return childResponse{Kind: kindFailed, Error: err.Error()}
+}
+
+// limitedBuffer keeps the first max bytes written to it
+type limitedBuffer struct {
+	bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); len(p) > room {
+		b.overflow = true
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
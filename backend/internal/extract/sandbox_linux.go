@@ -0,0 +1,37 @@
+package extract
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// isolate puts the child in its own process group, killed as a whole at the
+// timeout, and kills it should the parent die
+func isolate(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pdeathsig: syscall.SIGKILL}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// restrict sets the resource limits of the extraction process: its heap,
+// CPU time, no files written and no core dumps
+func restrict(memoryLimit int64, cpuTime time.Duration) error {
+	limits := map[int]uint64{
+		syscall.RLIMIT_FSIZE: 0,
+		syscall.RLIMIT_CORE:  0,
+	}
+	if memoryLimit > 0 {
+		limits[syscall.RLIMIT_DATA] = uint64(memoryLimit)
+	}
+	if cpuTime > 0 {
+		limits[syscall.RLIMIT_CPU] = uint64(cpuTime/time.Second) + 1
+	}
+	for resource, limit := range limits {
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: limit, Max: limit}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
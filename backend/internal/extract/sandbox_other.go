@@ -0,0 +1,16 @@
+//go:build !linux
+
+package extract
+
+import (
+	"os/exec"
+	"time"
+)
+
+// isolate relies on the timeout alone outside Linux
+func isolate(cmd *exec.Cmd) {}
+
+// restrict leaves the extraction process unrestricted outside Linux
+func restrict(memoryLimit int64, cpuTime time.Duration) error {
+	return nil
+}
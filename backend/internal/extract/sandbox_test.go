@@ -0,0 +1,84 @@
+package extract
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// childEnv makes the test binary act as an extraction process
+const childEnv = "EXTRACT_TEST_CHILD"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(childEnv) {
+	case "":
+		os.Exit(m.Run())
+	case "serve":
+		os.Exit(ServeChild(os.Stdin, os.Stdout))
+	case "hang":
+		time.Sleep(time.Minute)
+	case "crash":
+		fmt.Fprintln(os.Stderr, "fatal error: out of memory")
+		os.Exit(2)
+	}
+}
+
+// testSandbox returns a sandbox whose child runs the test binary in mode
+func testSandbox(t *testing.T, mode string, cfg SandboxConfig) *Sandbox {
+	t.Helper()
+	s, err := NewSandbox(cfg)
+	require.NoError(t, err)
+	s.command = func(ctx context.Context) *exec.Cmd {
+		cmd := exec.CommandContext(ctx, os.Args[0])
+		cmd.Env = []string{childEnv + "=" + mode}
+		return cmd
+	}
+	return s
+}
+
+// TestSandboxExtract verifies documents are extracted in a child process
+// and its errors are mapped back
+func TestSandboxExtract(t *testing.T) {
+	ctx := context.Background()
+	s := testSandbox(t, "serve", SandboxConfig{})
+
+	result, err := s.Extract(ctx, []byte("Board minutes"), "minutes.txt")
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Format: FormatText, Text: "Board minutes"}, result)
+
+	_, err = s.Extract(ctx, []byte{0x89, 'P', 'N', 'G', 0, 0, 0, 0}, "photo.png")
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	_, err = s.Extract(ctx, []byte("%PDF-1.7\n1 0 obj\n<< /Filter /Standard >>\nendobj\ntrailer\n<< /Encrypt 1 0 R >>\n"), "locked.pdf")
+	assert.ErrorIs(t, err, ErrEncrypted)
+
+	small := testSandbox(t, "serve", SandboxConfig{MaxInputBytes: 4})
+	_, err = small.Extract(ctx, []byte("too long"), "a.txt")
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
+
+// TestSandboxFailures verifies hung and crashed children are reported as
+// document failures, and cancellation as such
+func TestSandboxFailures(t *testing.T) {
+	ctx := context.Background()
+
+	start := time.Now()
+	_, err := testSandbox(t, "hang", SandboxConfig{Timeout: 200 * time.Millisecond}).Extract(ctx, []byte("x"), "a.txt")
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	_, err = testSandbox(t, "crash", SandboxConfig{}).Extract(ctx, []byte("x"), "a.txt")
+	assert.ErrorIs(t, err, ErrFailed)
+	assert.ErrorContains(t, err, "out of memory")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = testSandbox(t, "hang", SandboxConfig{}).Extract(cancelled, []byte("x"), "a.txt")
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,59 @@
+package extract
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// decodeText decodes plain text: UTF-16 and UTF-8 with a byte order mark,
+// valid UTF-8, and otherwise Windows-1252, the usual legacy encoding of mail
+func decodeText(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeWith(unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), data)
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeWith(unicode.UTF16(unicode.BigEndian, unicode.UseBOM), data)
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:])
+	case utf8.Valid(data):
+		return string(data)
+	}
+	return decodeWith(charmap.Windows1252, data)
+}
+
+// decodeCharset decodes text in a named charset such as iso-8859-1 or
+// gb2312; unknown charsets are decoded like plain text
+func decodeCharset(data []byte, charset string) string {
+	charset = strings.ToLower(strings.Trim(strings.TrimSpace(charset), `"`))
+	switch charset {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return decodeText(data)
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return decodeText(data)
+	}
+	return decodeWith(enc, data)
+}
+
+// decodeWith decodes data, replacing invalid sequences
+func decodeWith(enc encoding.Encoding, data []byte) string {
+	out, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return strings.ToValidUTF8(string(data), "")
+	}
+	return string(out)
+}
+
+// utf16String decodes little-endian UTF-16 without a byte order mark, as
+// used by compound files and Outlook properties
+func utf16String(data []byte) string {
+	s := decodeWith(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), data)
+	return strings.TrimRight(s, "\x00")
+}
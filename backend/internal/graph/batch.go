@@ -0,0 +1,200 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxBatchSize is the number of sub-requests Graph accepts in one $batch call
+const MaxBatchSize = 20
+
+// BatchRequest is one sub-request of a JSON batch
+type BatchRequest struct {
+	Method string            `json:"method"`
+	URL    string            `json:"url"` // relative to the API root, e.g. /users/{id}/messages/{id}/$value
+	Header map[string]string `json:"headers,omitempty"`
+}
+
+// BatchResponse is the response of one sub-request
+type BatchResponse struct {
+	Status int               `json:"status"`
+	Header map[string]string `json:"headers"`
+	Body   json.RawMessage   `json:"body"`
+}
+
+// BatchResult pairs a sub-request with its response or failure
+type BatchResult struct {
+	Response *BatchResponse
+	Err      error
+}
+
+// Decode unmarshals a JSON sub-response body
+func (r *BatchResponse) Decode(dest any) error {
+	if err := json.Unmarshal(r.Body, dest); err != nil {
+		return fmt.Errorf("failed to decode batch response: %w", err)
+	}
+	return nil
+}
+
+// Bytes returns the raw content of a binary sub-response. Graph encodes
+// non-JSON bodies, such as $value downloads, as base64 strings.
+func (r *BatchResponse) Bytes() ([]byte, error) {
+	var encoded string
+	if err := json.Unmarshal(r.Body, &encoded); err != nil {
+		return nil, fmt.Errorf("batch response body is not binary content: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode batch response content: %w", err)
+	}
+	return data, nil
+}
+
+// header returns a sub-response header ignoring case
+func (r *BatchResponse) header(name string) string {
+	for k, v := range r.Header {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// asError converts a failed sub-response into an Error
+func (r *BatchResponse) asError() *Error {
+	gerr := &Error{StatusCode: r.Status, RetryAfter: parseRetryAfter(r.header("Retry-After"))}
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(r.Body, &body) == nil {
+		gerr.Code = body.Error.Code
+		gerr.Message = body.Error.Message
+	}
+	return gerr
+}
+
+// relative builds a batch sub-request URL from an API path and query
+func relative(path string, query url.Values) string {
+	u := escapePath(path)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// Batch sends requests in $batch calls of up to the configured batch size
+// and returns one result per request, in order. Sub-requests that are
+// throttled or fail transiently are retried in a later batch, after the
+// longest Retry-After among them or a backoff delay, at most MaxRetries
+// times. Permanent sub-request failures are reported in their result without
+// affecting the others. The error is set only if a whole batch call failed.
+func (t *TenantClient) Batch(ctx context.Context, reqs []BatchRequest) ([]BatchResult, error) {
+	results := make([]BatchResult, len(reqs))
+	pending := make([]int, len(reqs))
+	for i := range reqs {
+		pending[i] = i
+	}
+
+	for attempt := 0; len(pending) > 0; attempt++ {
+		var retry []int
+		var wait time.Duration
+		throttled := false
+
+		for start := 0; start < len(pending); start += t.client.batchSize {
+			chunk := pending[start:min(start+t.client.batchSize, len(pending))]
+			responses, err := t.postBatch(ctx, reqs, chunk)
+			if err != nil {
+				return nil, err
+			}
+			for _, i := range chunk {
+				resp, ok := responses[strconv.Itoa(i)]
+				if !ok {
+					results[i].Err = fmt.Errorf("batch response is missing sub-request %d", i)
+					continue
+				}
+				if resp.Status >= 200 && resp.Status < 300 {
+					results[i] = BatchResult{Response: resp}
+					continue
+				}
+				gerr := resp.asError()
+				results[i] = BatchResult{Response: resp, Err: gerr}
+				if gerr.Temporary() && attempt < t.client.maxRetries {
+					retry = append(retry, i)
+					wait = max(wait, gerr.RetryAfter)
+					throttled = throttled || gerr.StatusCode == http.StatusTooManyRequests || gerr.StatusCode == http.StatusServiceUnavailable
+				}
+			}
+		}
+
+		if len(retry) == 0 {
+			break
+		}
+		if wait == 0 {
+			wait = t.client.backoff(attempt)
+		}
+		if throttled && t.client.limiter != nil {
+			t.client.limiter.Pause(ctx, t.azureTenantID, wait)
+		}
+		if err := t.client.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+		pending = retry
+	}
+	return results, nil
+}
+
+// postBatch sends the selected requests as one $batch call, using their
+// index as sub-request ID, and returns the responses by ID
+func (t *TenantClient) postBatch(ctx context.Context, reqs []BatchRequest, indexes []int) (map[string]*BatchResponse, error) {
+	type subRequest struct {
+		ID string `json:"id"`
+		BatchRequest
+	}
+	payload := struct {
+		Requests []subRequest `json:"requests"`
+	}{Requests: make([]subRequest, len(indexes))}
+	for n, i := range indexes {
+		payload.Requests[n] = subRequest{ID: strconv.Itoa(i), BatchRequest: reqs[i]}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode batch request: %w", err)
+	}
+
+	resp, err := t.do(ctx, request{
+		method: http.MethodPost,
+		url:    t.client.resolve("/$batch", nil),
+		header: http.Header{"Content-Type": {"application/json"}},
+		body:   body,
+		weight: len(indexes),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("graph batch request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var decoded struct {
+		Responses []struct {
+			ID string `json:"id"`
+			BatchResponse
+		} `json:"responses"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode batch response: %w", err)
+	}
+	responses := make(map[string]*BatchResponse, len(decoded.Responses))
+	for i := range decoded.Responses {
+		responses[decoded.Responses[i].ID] = &decoded.Responses[i].BatchResponse
+	}
+	return responses, nil
+}
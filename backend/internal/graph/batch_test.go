@@ -0,0 +1,260 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ironarchive/internal/graph/graphtest"
+)
+
+// newBatchClient starts a fake Graph server and a client with the given batch size
+func newBatchClient(tb testing.TB, batchSize int) (*graphtest.Server, *TenantClient) {
+	tb.Helper()
+	server := graphtest.NewServer(testTenant, "app-id", "app-secret")
+	tb.Cleanup(server.Close)
+	server.AddFolder(testUser, graphtest.Folder{ID: "inbox", DisplayName: "Inbox"})
+
+	client, err := NewClient(Config{BaseURL: server.BaseURL(), LoginURL: server.LoginURL(), BatchSize: batchSize})
+	require.NoError(tb, err)
+	return server, client.Tenant(testTenant, testCredentials("app-secret"))
+}
+
+// mimeRequests builds $value sub-requests for message IDs
+func mimeRequests(ids ...string) []BatchRequest {
+	reqs := make([]BatchRequest, len(ids))
+	for i, id := range ids {
+		reqs[i] = BatchRequest{Method: http.MethodGet, URL: relative("/users/"+testUser+"/messages/"+id+"/$value", nil)}
+	}
+	return reqs
+}
+
+// readContent opens, reads and closes fetched content
+func readContent(t *testing.T, open func(context.Context) (io.ReadCloser, error)) []byte {
+	t.Helper()
+	r, err := open(context.Background())
+	require.NoError(t, err)
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return data
+}
+
+// TestBatchPartialFailure verifies a failing sub-request does not affect the others
+func TestBatchPartialFailure(t *testing.T) {
+	server, client := newBatchClient(t, 20)
+	addMessages(server, "inbox", "m1", "m2")
+
+	results, err := client.Batch(context.Background(), mimeRequests("m1", "missing", "m2"))
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, 1, server.Batches())
+
+	for _, i := range []int{0, 2} {
+		require.NoError(t, results[i].Err)
+		data, err := results[i].Response.Bytes()
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "Subject: Subject m")
+	}
+	require.Error(t, results[1].Err)
+	assert.True(t, IsNotFound(results[1].Err))
+	assert.True(t, IsPermanent(results[1].Err))
+}
+
+// TestBatchRetriesThrottledSubRequests verifies only throttled sub-requests are resent, after their Retry-After
+func TestBatchRetriesThrottledSubRequests(t *testing.T) {
+	server, client := newBatchClient(t, 20)
+	delays := recordSleeps(client)
+	addMessages(server, "inbox", "m1", "m2", "m3")
+	server.FailNext(1, graphtest.Failure{Path: "/messages/m2/", Status: http.StatusTooManyRequests, Code: "TooManyRequests", Header: http.Header{"Retry-After": {"4"}}})
+
+	before := server.Requests()
+	results, err := client.Batch(context.Background(), mimeRequests("m1", "m2", "m3"))
+	require.NoError(t, err)
+	for _, r := range results {
+		assert.NoError(t, r.Err)
+	}
+	assert.Equal(t, 2, server.Batches())
+	// Two envelopes, three sub-requests, one resent sub-request
+	assert.Equal(t, 6, server.Requests()-before)
+	assert.Equal(t, []time.Duration{4 * time.Second}, *delays)
+}
+
+// TestBatchGivesUpOnPersistentFailures verifies sub-request retries are bounded
+func TestBatchGivesUpOnPersistentFailures(t *testing.T) {
+	server, client := newBatchClient(t, 20)
+	delays := recordSleeps(client)
+	addMessages(server, "inbox", "m1", "m2")
+	server.FailNext(DefaultMaxRetries+1, graphtest.Failure{Path: "/messages/m1/", Status: http.StatusServiceUnavailable, Code: "ServiceUnavailable"})
+
+	results, err := client.Batch(context.Background(), mimeRequests("m1", "m2"))
+	require.NoError(t, err)
+	require.Error(t, results[0].Err)
+	assert.False(t, IsPermanent(results[0].Err))
+	assert.NoError(t, results[1].Err)
+	assert.Len(t, *delays, DefaultMaxRetries)
+}
+
+// TestBatchEnvelopeFailure verifies a failed $batch call is retried as a whole
+func TestBatchEnvelopeFailure(t *testing.T) {
+	server, client := newBatchClient(t, 20)
+	recordSleeps(client)
+	addMessages(server, "inbox", "m1")
+	server.FailNext(1, graphtest.Failure{Path: "$batch", Status: http.StatusBadGateway, Code: "BadGateway"})
+
+	results, err := client.Batch(context.Background(), mimeRequests("m1"))
+	require.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, 2, server.Batches())
+}
+
+// TestBatchChunksBySize verifies requests are split into calls of the configured size
+func TestBatchChunksBySize(t *testing.T) {
+	server, client := newBatchClient(t, 4)
+	ids := make([]string, 10)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("m%d", i)
+	}
+	addMessages(server, "inbox", ids...)
+
+	results, err := client.Batch(context.Background(), mimeRequests(ids...))
+	require.NoError(t, err)
+	require.Len(t, results, 10)
+	for _, r := range results {
+		assert.NoError(t, r.Err)
+	}
+	assert.Equal(t, 3, server.Batches())
+}
+
+// TestFetchMessages verifies MIME and attachments are batched and large content is streamed
+func TestFetchMessages(t *testing.T) {
+	server, client := newBatchClient(t, 20)
+	large := bytes.Repeat([]byte("x"), MaxBatchedContentSize+1)
+	server.AddMessage(testUser, graphtest.Message{ID: "plain", FolderID: "inbox", Subject: "Plain", SentAt: time.Now()})
+	server.AddMessage(testUser, graphtest.Message{
+		ID:       "small",
+		FolderID: "inbox",
+		Subject:  "Small",
+		SentAt:   time.Now(),
+		Attachments: []graphtest.Attachment{
+			{ID: "a1", Name: "report.pdf", ContentType: "application/pdf", Content: []byte("%PDF-1.7")},
+			{ID: "a2", Name: "notes.txt", ContentType: "text/plain", Content: []byte("notes")},
+		},
+	})
+	server.AddMessage(testUser, graphtest.Message{
+		ID:          "large",
+		FolderID:    "inbox",
+		Subject:     "Large",
+		SentAt:      time.Now(),
+		Attachments: []graphtest.Attachment{{ID: "a3", Name: "backup.zip", ContentType: "application/zip", Content: large}},
+	})
+	messages := collectMessages(t, client, "inbox")
+	require.Len(t, messages, 3)
+
+	ctx := context.Background()
+	contents, err := client.FetchMessages(ctx, testUser, messages)
+	require.NoError(t, err)
+	require.Len(t, contents, 3)
+	// Attachment lists, then small MIME, then small attachment content
+	assert.Equal(t, 3, server.Batches())
+
+	byID := map[string]*MessageContent{}
+	for i := range contents {
+		require.NoError(t, contents[i].Err)
+		byID[contents[i].MessageID] = &contents[i]
+	}
+	assert.Empty(t, byID["plain"].Attachments)
+	assert.Contains(t, string(readContent(t, byID["plain"].OpenMIME)), "Subject: Plain")
+
+	small := byID["small"]
+	require.Len(t, small.Attachments, 2)
+	assert.Equal(t, "%PDF-1.7", string(readContent(t, small.Attachments[0].Open)))
+	assert.Equal(t, "notes", string(readContent(t, small.Attachments[1].Open)))
+
+	// Content above the batch limit is downloaded directly on Open
+	before := server.Requests()
+	largeContent := byID["large"]
+	require.Len(t, largeContent.Attachments, 1)
+	assert.Contains(t, string(readContent(t, largeContent.OpenMIME)), "Subject: Large")
+	assert.Equal(t, large, readContent(t, largeContent.Attachments[0].Open))
+	assert.Equal(t, 2, server.Requests()-before)
+}
+
+// TestFetchMessagesReportsPerMessageErrors verifies a missing message fails only its own content
+func TestFetchMessagesReportsPerMessageErrors(t *testing.T) {
+	server, client := newBatchClient(t, 20)
+	addMessages(server, "inbox", "m1", "m2")
+	messages := collectMessages(t, client, "inbox")
+	require.Len(t, messages, 2)
+	server.RemoveMessage(testUser, "m1")
+
+	contents, err := client.FetchMessages(context.Background(), testUser, messages)
+	require.NoError(t, err)
+	for _, c := range contents {
+		if c.MessageID == "m1" {
+			assert.True(t, IsNotFound(c.Err))
+		} else {
+			assert.NoError(t, c.Err)
+		}
+	}
+}
+
+// collectMessages runs an initial delta round and returns the messages
+func collectMessages(t *testing.T, client *TenantClient, folderID string) []Message {
+	t.Helper()
+	var all []Message
+	_, err := client.MessageDelta(context.Background(), testUser, folderID, "", func(messages []Message) error {
+		all = append(all, messages...)
+		return nil
+	})
+	require.NoError(t, err)
+	return all
+}
+
+// BenchmarkFetchMessages measures download throughput of messages with one
+// attachment against a fake server with 2ms round trips, by batch size
+func BenchmarkFetchMessages(b *testing.B) {
+	const messageCount = 100
+	for _, size := range []int{1, 5, 10, 20} {
+		b.Run(fmt.Sprintf("batch=%d", size), func(b *testing.B) {
+			server, client := newBatchClient(b, size)
+			var messages []Message
+			for i := range messageCount {
+				id := fmt.Sprintf("m%d", i)
+				server.AddMessage(testUser, graphtest.Message{
+					ID:          id,
+					FolderID:    "inbox",
+					Subject:     "Subject " + id,
+					SentAt:      time.Now(),
+					Body:        "<p>Hello</p>",
+					Attachments: []graphtest.Attachment{{ID: "a1", Name: "file.txt", ContentType: "text/plain", Content: bytes.Repeat([]byte("a"), 4096)}},
+				})
+				messages = append(messages, Message{ID: id, HasAttachments: true})
+			}
+			server.SetLatency(2 * time.Millisecond)
+			ctx := context.Background()
+
+			rounds := 0
+			for b.Loop() {
+				contents, err := client.FetchMessages(ctx, testUser, messages)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for _, c := range contents {
+					if c.Err != nil {
+						b.Fatal(c.Err)
+					}
+				}
+				rounds++
+			}
+			b.ReportMetric(float64(messageCount*rounds)/b.Elapsed().Seconds(), "msgs/s")
+		})
+	}
+}
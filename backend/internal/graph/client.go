@@ -0,0 +1,302 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"ironarchive/internal/models"
+)
+
+// Default endpoints of the Microsoft commercial cloud
+const (
+	DefaultBaseURL  = "https://graph.microsoft.com/v1.0"
+	DefaultLoginURL = "https://login.microsoftonline.com"
+	DefaultScope    = "https://graph.microsoft.com/.default"
+)
+
+// Retry defaults
+const (
+	DefaultMaxRetries     = 3
+	DefaultRetryBaseDelay = time.Second
+	DefaultRetryMaxDelay  = time.Minute
+)
+
+// Config configures the Graph client
+type Config struct {
+	// BaseURL is the Graph API root, e.g. https://graph.microsoft.com/v1.0
+	BaseURL string
+	// LoginURL is the OAuth authority; tokens come from {LoginURL}/{tenant}/oauth2/v2.0/token
+	LoginURL string
+	// Scope requested with the client credentials grant
+	Scope string
+	// PageSize is the odata.maxpagesize preference for delta queries
+	PageSize int
+	// BatchSize is the number of sub-requests per $batch call, at most MaxBatchSize
+	BatchSize int
+	// Timeout bounds each request including reading its body
+	Timeout time.Duration
+	// MaxRetries bounds retries of throttled and transient failures
+	MaxRetries int
+	// RetryBaseDelay is the first backoff delay when no Retry-After is given
+	RetryBaseDelay time.Duration
+	// RetryMaxDelay caps exponential backoff delays
+	RetryMaxDelay time.Duration
+	// Limiter enforces per-tenant request budgets; nil sends requests unrestricted
+	Limiter Limiter
+	// Transport overrides the HTTP transport (tests)
+	Transport http.RoundTripper
+}
+
+// Client talks to Microsoft Graph on behalf of many tenants. It is safe for
+// concurrent use and caches app-only tokens per tenant.
+type Client struct {
+	baseURL        *url.URL
+	loginURL       string
+	scope          string
+	pageSize       int
+	batchSize      int
+	maxRetries     int
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+	limiter        Limiter
+	http           *http.Client
+	tokens         *tokenCache
+	sleep          func(ctx context.Context, d time.Duration) error
+}
+
+// NewClient creates a Graph client
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	if cfg.LoginURL == "" {
+		cfg.LoginURL = DefaultLoginURL
+	}
+	if cfg.Scope == "" {
+		cfg.Scope = DefaultScope
+	}
+	if cfg.PageSize <= 0 {
+		cfg.PageSize = 50
+	}
+	if cfg.BatchSize <= 0 || cfg.BatchSize > MaxBatchSize {
+		cfg.BatchSize = MaxBatchSize
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = DefaultMaxRetries
+	}
+	if cfg.RetryBaseDelay <= 0 {
+		cfg.RetryBaseDelay = DefaultRetryBaseDelay
+	}
+	if cfg.RetryMaxDelay <= 0 {
+		cfg.RetryMaxDelay = DefaultRetryMaxDelay
+	}
+	base, err := url.Parse(strings.TrimRight(cfg.BaseURL, "/"))
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid Graph base URL %q", cfg.BaseURL)
+	}
+	httpClient := &http.Client{Timeout: cfg.Timeout}
+	if cfg.Transport != nil {
+		httpClient.Transport = cfg.Transport
+	}
+	return &Client{
+		baseURL:        base,
+		loginURL:       strings.TrimRight(cfg.LoginURL, "/"),
+		scope:          cfg.Scope,
+		pageSize:       cfg.PageSize,
+		batchSize:      cfg.BatchSize,
+		maxRetries:     cfg.MaxRetries,
+		retryBaseDelay: cfg.RetryBaseDelay,
+		retryMaxDelay:  cfg.RetryMaxDelay,
+		limiter:        cfg.Limiter,
+		http:           httpClient,
+		tokens:         newTokenCache(),
+		sleep:          sleep,
+	}, nil
+}
+
+// Tenant returns a client authenticating as the tenant's app registration
+func (c *Client) Tenant(azureTenantID string, creds models.AzureCredentials) *TenantClient {
+	return &TenantClient{client: c, azureTenantID: azureTenantID, creds: creds}
+}
+
+// TenantClient issues Graph requests with one tenant's app credentials
+type TenantClient struct {
+	client        *Client
+	azureTenantID string
+	creds         models.AzureCredentials
+}
+
+// resolve turns a path relative to the base URL into an absolute URL
+func (c *Client) resolve(path string, query url.Values) string {
+	u := c.baseURL.String() + escapePath(path)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// checkLink rejects server-provided links that would send the bearer token
+// to a host other than the configured Graph endpoint
+func (c *Client) checkLink(link string) error {
+	u, err := url.Parse(link)
+	if err != nil {
+		return fmt.Errorf("invalid Graph link: %w", err)
+	}
+	if u.Scheme != c.baseURL.Scheme || u.Host != c.baseURL.Host {
+		return fmt.Errorf("graph link %q points outside %s", u.Redacted(), c.baseURL.Host)
+	}
+	return nil
+}
+
+// escapePath escapes each segment of a path built from Graph IDs
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// do sends an authenticated request and returns the successful response.
+// Throttled (429/503) and transient failures are retried up to MaxRetries
+// times, waiting for Retry-After when the service sends one and for an
+// exponential backoff with jitter otherwise. A 401 drops the cached token and
+// retries once with a fresh one. Permanent errors are returned immediately.
+func (t *TenantClient) do(ctx context.Context, r request) (*http.Response, error) {
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		resp, err := t.send(ctx, r)
+		if err == nil {
+			return resp, nil
+		}
+
+		var gerr *Error
+		isGraphErr := errors.As(err, &gerr)
+		if isGraphErr && gerr.StatusCode == http.StatusUnauthorized && !gerr.token && !refreshed {
+			refreshed = true
+			t.client.tokens.invalidate(t.tokenKey())
+			attempt--
+			continue
+		}
+		if ctx.Err() != nil || attempt >= t.client.maxRetries || !isRetryable(err) {
+			return nil, err
+		}
+
+		delay := t.client.backoff(attempt)
+		if isGraphErr && gerr.RetryAfter > 0 {
+			delay = gerr.RetryAfter
+		}
+		if isGraphErr && (gerr.StatusCode == http.StatusTooManyRequests || gerr.StatusCode == http.StatusServiceUnavailable) && t.client.limiter != nil {
+			// Hold back every worker of this tenant, not only this request
+			t.client.limiter.Pause(ctx, t.azureTenantID, delay)
+		}
+		if err := t.client.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// request describes a Graph API call; the body is kept as bytes so retries can resend it
+type request struct {
+	method string
+	url    string
+	header http.Header
+	body   []byte
+	// weight is the number of Graph requests this call counts as (sub-requests of a batch)
+	weight int
+}
+
+// send performs a single attempt within the tenant's request budget
+func (t *TenantClient) send(ctx context.Context, r request) (*http.Response, error) {
+	token, err := t.client.tokens.get(ctx, t.tokenKey(), t.fetchToken)
+	if err != nil {
+		return nil, err
+	}
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, r.url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Graph request: %w", err)
+	}
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	release := func() {}
+	if t.client.limiter != nil {
+		if release, err = t.client.limiter.Acquire(ctx, t.azureTenantID, max(r.weight, 1)); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := t.client.http.Do(req)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("graph request failed: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		gerr := parseError(resp)
+		resp.Body.Close()
+		release()
+		return nil, gerr
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// backoff returns the jittered exponential delay before retry attempt+1
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.retryBaseDelay << attempt
+	if d <= 0 || d > c.retryMaxDelay {
+		d = c.retryMaxDelay
+	}
+	// Equal jitter: half fixed, half random, so retries of many workers spread out
+	return d/2 + time.Duration(mathrand.Int64N(int64(d/2)+1))
+}
+
+// releasingBody frees the request's budget slot when the body is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// getJSON fetches rawURL and decodes the JSON response into dest
+func (t *TenantClient) getJSON(ctx context.Context, rawURL string, header http.Header, dest any) error {
+	resp, err := t.do(ctx, request{method: http.MethodGet, url: rawURL, header: header})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(dest); err != nil {
+		return fmt.Errorf("failed to decode Graph response: %w", err)
+	}
+	return nil
+}
+
+// stream fetches rawURL and returns the response body
+func (t *TenantClient) stream(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	resp, err := t.do(ctx, request{method: http.MethodGet, url: rawURL})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,210 @@
+package graph
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ironarchive/internal/graph/graphtest"
+	"ironarchive/internal/models"
+)
+
+const (
+	testTenant = "11111111-1111-1111-1111-111111111111"
+	testUser   = "alice@contoso.com"
+)
+
+// newTestClient starts a fake Graph server with an Inbox and a nested folder
+func newTestClient(t *testing.T, pageSize int) (*graphtest.Server, *TenantClient) {
+	t.Helper()
+	server := graphtest.NewServer(testTenant, "app-id", "app-secret")
+	t.Cleanup(server.Close)
+	server.AddFolder(testUser, graphtest.Folder{ID: "inbox", DisplayName: "Inbox"})
+	server.AddFolder(testUser, graphtest.Folder{ID: "projects", DisplayName: "Projects", ParentID: "inbox"})
+
+	client, err := NewClient(Config{BaseURL: server.BaseURL(), LoginURL: server.LoginURL(), PageSize: pageSize})
+	require.NoError(t, err)
+	return server, client.Tenant(testTenant, testCredentials("app-secret"))
+}
+
+// testCredentials returns the fake app registration with the given secret
+func testCredentials(secret string) models.AzureCredentials {
+	return models.AzureCredentials{ClientID: "app-id", ClientSecret: models.Secret(secret)}
+}
+
+// addMessages adds n messages to a folder of the test mailbox
+func addMessages(server *graphtest.Server, folderID string, ids ...string) {
+	for _, id := range ids {
+		server.AddMessage(testUser, graphtest.Message{
+			ID:       id,
+			FolderID: folderID,
+			Subject:  "Subject " + id,
+			From:     "bob@contoso.com",
+			To:       []string{testUser},
+			SentAt:   time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC),
+			Body:     "<p>Hello</p>",
+		})
+	}
+}
+
+// collectDelta runs a delta round and returns the IDs of added and removed messages
+func collectDelta(t *testing.T, client *TenantClient, folderID, link string) (added, removed []string, next string) {
+	t.Helper()
+	next, err := client.MessageDelta(context.Background(), testUser, folderID, link, func(messages []Message) error {
+		for _, m := range messages {
+			if m.Removed != nil {
+				removed = append(removed, m.ID)
+			} else {
+				added = append(added, m.ID)
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return added, removed, next
+}
+
+// TestListMailFoldersIncludesNested verifies child folders are discovered and the token is cached
+func TestListMailFoldersIncludesNested(t *testing.T) {
+	server, client := newTestClient(t, 10)
+
+	folders, err := client.ListMailFolders(context.Background(), testUser)
+	require.NoError(t, err)
+	require.Len(t, folders, 2)
+	assert.Equal(t, "Inbox", folders[0].DisplayName)
+	assert.Equal(t, "Projects", folders[1].DisplayName)
+
+	_, err = client.ListMailFolders(context.Background(), testUser)
+	require.NoError(t, err)
+	assert.Equal(t, 1, server.TokenRequests())
+}
+
+// TestMessageDeltaPagesAndResumes verifies paging, incremental rounds and removals
+func TestMessageDeltaPagesAndResumes(t *testing.T) {
+	server, client := newTestClient(t, 2)
+	addMessages(server, "inbox", "m1", "m2", "m3")
+	addMessages(server, "projects", "p1")
+
+	added, removed, link := collectDelta(t, client, "inbox", "")
+	assert.Equal(t, []string{"m1", "m2", "m3"}, added)
+	assert.Empty(t, removed)
+	require.NotEmpty(t, link)
+
+	added, _, link = collectDelta(t, client, "inbox", link)
+	assert.Empty(t, added)
+
+	addMessages(server, "inbox", "m4")
+	server.RemoveMessage(testUser, "m1")
+	added, removed, _ = collectDelta(t, client, "inbox", link)
+	assert.Equal(t, []string{"m4"}, added)
+	assert.Equal(t, []string{"m1"}, removed)
+}
+
+// TestMessageDeltaCallbackErrorStopsRound verifies a failing page returns no delta link
+func TestMessageDeltaCallbackErrorStopsRound(t *testing.T) {
+	server, client := newTestClient(t, 1)
+	addMessages(server, "inbox", "m1", "m2")
+
+	pages := 0
+	next, err := client.MessageDelta(context.Background(), testUser, "inbox", "", func([]Message) error {
+		pages++
+		return io.ErrUnexpectedEOF
+	})
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Empty(t, next)
+	assert.Equal(t, 1, pages)
+}
+
+// TestIsDeltaExpired verifies expired delta links are classified for a full resync
+func TestIsDeltaExpired(t *testing.T) {
+	server, client := newTestClient(t, 10)
+	addMessages(server, "inbox", "m1")
+	_, _, link := collectDelta(t, client, "inbox", "")
+
+	server.ExpireDeltaLinks()
+	_, err := client.MessageDelta(context.Background(), testUser, "inbox", link, func([]Message) error { return nil })
+	require.Error(t, err)
+	assert.True(t, IsDeltaExpired(err))
+	assert.False(t, IsNotFound(err))
+
+	assert.True(t, IsDeltaExpired(&Error{StatusCode: http.StatusBadRequest, Code: "resyncRequired"}))
+	assert.False(t, IsDeltaExpired(io.EOF))
+}
+
+// TestMessageMIMEAndAttachments verifies raw content downloads
+func TestMessageMIMEAndAttachments(t *testing.T) {
+	server, client := newTestClient(t, 10)
+	server.AddMessage(testUser, graphtest.Message{
+		ID:       "m1",
+		FolderID: "inbox",
+		Subject:  "Report",
+		SentAt:   time.Now(),
+		Attachments: []graphtest.Attachment{
+			{ID: "a1", Name: "report.pdf", ContentType: "application/pdf", Content: []byte("%PDF-1.7")},
+		},
+	})
+	ctx := context.Background()
+
+	mime, err := client.MessageMIME(ctx, testUser, "m1")
+	require.NoError(t, err)
+	raw, err := io.ReadAll(mime)
+	mime.Close()
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "Subject: Report")
+
+	attachments, err := client.ListAttachments(ctx, testUser, "m1")
+	require.NoError(t, err)
+	require.Len(t, attachments, 1)
+	assert.Equal(t, AttachmentTypeFile, attachments[0].ODataType)
+	assert.Equal(t, int64(8), attachments[0].Size)
+
+	content, err := client.AttachmentContent(ctx, testUser, "m1", "a1")
+	require.NoError(t, err)
+	data, err := io.ReadAll(content)
+	content.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "%PDF-1.7", string(data))
+
+	_, err = client.MessageMIME(ctx, testUser, "missing")
+	assert.True(t, IsNotFound(err))
+}
+
+// TestTokenRefreshOnUnauthorized verifies a revoked token is replaced once
+func TestTokenRefreshOnUnauthorized(t *testing.T) {
+	server, client := newTestClient(t, 10)
+
+	_, err := client.ListMailFolders(context.Background(), testUser)
+	require.NoError(t, err)
+	server.RevokeTokens()
+
+	_, err = client.ListMailFolders(context.Background(), testUser)
+	require.NoError(t, err)
+	assert.Equal(t, 2, server.TokenRequests())
+}
+
+// TestInvalidCredentials verifies token endpoint errors surface with their OAuth code
+func TestInvalidCredentials(t *testing.T) {
+	server, _ := newTestClient(t, 10)
+	client, err := NewClient(Config{BaseURL: server.BaseURL(), LoginURL: server.LoginURL()})
+	require.NoError(t, err)
+
+	_, err = client.Tenant(testTenant, testCredentials("wrong")).ListMailFolders(context.Background(), testUser)
+	var gerr *Error
+	require.ErrorAs(t, err, &gerr)
+	assert.Equal(t, "invalid_client", gerr.Code)
+	assert.NotContains(t, err.Error(), "wrong")
+}
+
+// TestForeignLinksRejected verifies the bearer token is never sent to another host
+func TestForeignLinksRejected(t *testing.T) {
+	_, client := newTestClient(t, 10)
+
+	_, err := client.MessageDelta(context.Background(), testUser, "inbox", "https://attacker.example/v1.0/delta", func([]Message) error { return nil })
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "outside")
+}
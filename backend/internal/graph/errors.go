@@ -0,0 +1,163 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxErrorBody bounds how much of an error response is read
+const maxErrorBody = 64 << 10
+
+// Error is a non-success Graph or token endpoint response
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	// RetryAfter is the wait requested by a throttling response, if any
+	RetryAfter time.Duration
+
+	// token marks errors of the token endpoint rather than the Graph API
+	token bool
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("graph: HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("graph: HTTP %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Temporary reports whether the request may succeed when retried later
+func (e *Error) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isRetryable reports whether a failed attempt should be retried. Transport
+// errors (resets, timeouts) are retried; cancellation is not.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var gerr *Error
+	if errors.As(err, &gerr) {
+		return gerr.Temporary()
+	}
+	return true
+}
+
+// IsPermanent reports whether err is a Graph response that retrying cannot
+// fix, such as denied access, a deleted mailbox or invalid app credentials.
+// Jobs should fail immediately on these errors.
+func IsPermanent(err error) bool {
+	var gerr *Error
+	return errors.As(err, &gerr) && !gerr.Temporary()
+}
+
+// IsNotFound reports whether err is a Graph 404
+func IsNotFound(err error) bool {
+	var gerr *Error
+	return errors.As(err, &gerr) && gerr.StatusCode == http.StatusNotFound
+}
+
+// IsMailboxUnavailable reports whether the mailbox itself is gone or cannot
+// be accessed through the API (deleted user, unlicensed or on-premises mailbox)
+func IsMailboxUnavailable(err error) bool {
+	var gerr *Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+	switch gerr.Code {
+	case "ErrorInvalidUser", "MailboxNotEnabledForRESTAPI", "MailboxNotFound", "ErrorMailboxNotFound":
+		return true
+	}
+	return false
+}
+
+// IsAccessDenied reports whether the tenant's app registration cannot
+// authenticate or lacks permissions; this affects every mailbox of the tenant
+func IsAccessDenied(err error) bool {
+	var gerr *Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+	if gerr.token {
+		return !gerr.Temporary()
+	}
+	return gerr.StatusCode == http.StatusUnauthorized || gerr.StatusCode == http.StatusForbidden
+}
+
+// IsDeltaExpired reports whether a delta link can no longer be used and the
+// folder must be walked again from scratch
+func IsDeltaExpired(err error) bool {
+	var gerr *Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+	if gerr.StatusCode == http.StatusGone {
+		return true
+	}
+	switch gerr.Code {
+	case "SyncStateNotFound", "SyncStateInvalid", "resyncRequired":
+		return true
+	}
+	return false
+}
+
+// parseError builds an Error from a failed response, accepting both the
+// Graph ({"error":{"code","message"}}) and OAuth ({"error","error_description"}) shapes
+func parseError(resp *http.Response) *Error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	gerr := &Error{StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+
+	var graphBody struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &graphBody) == nil && graphBody.Error.Code != "" {
+		gerr.Code = graphBody.Error.Code
+		gerr.Message = graphBody.Error.Message
+		return gerr
+	}
+
+	var oauthBody struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	if json.Unmarshal(body, &oauthBody) == nil && oauthBody.Error != "" {
+		gerr.Code = oauthBody.Error
+		gerr.Message = oauthBody.Description
+	}
+	return gerr
+}
+
+// parseRetryAfter reads a Retry-After value in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,240 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+const (
+	// MaxBatchedContentSize is the largest attachment, or message with
+	// attachments, downloaded inside a batch; larger content is streamed
+	MaxBatchedContentSize = 3 << 20
+	// maxBatchBytes bounds the estimated content size of one batch response
+	maxBatchBytes = 16 << 20
+	// mimeOverhead estimates a message's MIME size beyond its attachments
+	mimeOverhead = 64 << 10
+)
+
+// attachmentSelect lists the attachment metadata properties fetched
+const attachmentSelect = "id,name,contentType,size,isInline"
+
+// MessageContent is the MIME and attachments of a message fetched by FetchMessages
+type MessageContent struct {
+	MessageID   string
+	Attachments []AttachmentContent
+	// Err is set when the message could not be fetched; other messages are unaffected
+	Err error
+
+	mime   []byte
+	client *TenantClient
+	userID string
+}
+
+// OpenMIME returns the message's RFC 822 content, streaming it if it was too
+// large to batch. The caller closes it.
+func (m *MessageContent) OpenMIME(ctx context.Context) (io.ReadCloser, error) {
+	if m.mime != nil {
+		return io.NopCloser(bytes.NewReader(m.mime)), nil
+	}
+	return m.client.MessageMIME(ctx, m.userID, m.MessageID)
+}
+
+// AttachmentContent is attachment metadata with its content, if batched
+type AttachmentContent struct {
+	Attachment
+
+	content   []byte
+	client    *TenantClient
+	userID    string
+	messageID string
+}
+
+// Open returns the attachment content, streaming it if it was too large to
+// batch. Reference attachments have no content. The caller closes it.
+func (a *AttachmentContent) Open(ctx context.Context) (io.ReadCloser, error) {
+	if a.content != nil {
+		return io.NopCloser(bytes.NewReader(a.content)), nil
+	}
+	return a.client.AttachmentContent(ctx, a.userID, a.messageID, a.ID)
+}
+
+// FetchMessages downloads the attachment metadata, MIME and attachment
+// content of messages using $batch calls. Content above
+// MaxBatchedContentSize is left to be streamed on Open. Failures of single
+// messages are reported in MessageContent.Err; the error is set only when a
+// batch call as a whole failed.
+func (t *TenantClient) FetchMessages(ctx context.Context, userID string, messages []Message) ([]MessageContent, error) {
+	contents := make([]MessageContent, len(messages))
+	for i, msg := range messages {
+		contents[i] = MessageContent{MessageID: msg.ID, client: t, userID: userID}
+	}
+
+	if err := t.fetchAttachmentLists(ctx, userID, messages, contents); err != nil {
+		return nil, err
+	}
+	if err := t.fetchMIME(ctx, userID, contents); err != nil {
+		return nil, err
+	}
+	if err := t.fetchAttachmentContent(ctx, userID, contents); err != nil {
+		return nil, err
+	}
+	return contents, nil
+}
+
+// fetchAttachmentLists loads attachment metadata of messages that have attachments
+func (t *TenantClient) fetchAttachmentLists(ctx context.Context, userID string, messages []Message, contents []MessageContent) error {
+	var reqs []BatchRequest
+	var owners []int
+	query := url.Values{"$select": {attachmentSelect}}
+	for i, msg := range messages {
+		if msg.HasAttachments {
+			reqs = append(reqs, BatchRequest{Method: http.MethodGet, URL: relative("/users/"+userID+"/messages/"+msg.ID+"/attachments", query)})
+			owners = append(owners, i)
+		}
+	}
+	if len(reqs) == 0 {
+		return nil
+	}
+
+	results, err := t.Batch(ctx, reqs)
+	if err != nil {
+		return err
+	}
+	for n, result := range results {
+		c := &contents[owners[n]]
+		if result.Err != nil {
+			c.Err = fmt.Errorf("failed to list attachments: %w", result.Err)
+			continue
+		}
+		var page collection[Attachment]
+		if err := result.Response.Decode(&page); err != nil {
+			c.Err = err
+			continue
+		}
+		list := page.Value
+		if page.NextLink != "" {
+			// Rare: more attachments than one page; fetch the full list directly
+			if list, err = t.ListAttachments(ctx, userID, c.MessageID); err != nil {
+				c.Err = err
+				continue
+			}
+		}
+		for _, a := range list {
+			c.Attachments = append(c.Attachments, AttachmentContent{Attachment: a, client: t, userID: userID, messageID: c.MessageID})
+		}
+	}
+	return nil
+}
+
+// fetchMIME batches the MIME download of messages whose attachments are small
+func (t *TenantClient) fetchMIME(ctx context.Context, userID string, contents []MessageContent) error {
+	var items []int
+	var sizes []int64
+	for i := range contents {
+		if contents[i].Err != nil {
+			continue
+		}
+		var size int64
+		for _, a := range contents[i].Attachments {
+			size += a.Size
+		}
+		if size <= MaxBatchedContentSize {
+			items = append(items, i)
+			sizes = append(sizes, size+mimeOverhead)
+		}
+	}
+
+	for _, group := range t.client.groupBySize(sizes) {
+		reqs := make([]BatchRequest, len(group))
+		for n, k := range group {
+			reqs[n] = BatchRequest{Method: http.MethodGet, URL: relative("/users/"+userID+"/messages/"+contents[items[k]].MessageID+"/$value", nil)}
+		}
+		results, err := t.Batch(ctx, reqs)
+		if err != nil {
+			return err
+		}
+		for n, result := range results {
+			c := &contents[items[group[n]]]
+			if result.Err != nil {
+				c.Err = fmt.Errorf("failed to download message MIME: %w", result.Err)
+				continue
+			}
+			if c.mime, err = result.Response.Bytes(); err != nil {
+				c.Err = err
+			}
+		}
+	}
+	return nil
+}
+
+// fetchAttachmentContent batches the download of small file and item attachments
+func (t *TenantClient) fetchAttachmentContent(ctx context.Context, userID string, contents []MessageContent) error {
+	type ref struct{ message, attachment int }
+	var refs []ref
+	var sizes []int64
+	for i := range contents {
+		if contents[i].Err != nil {
+			continue
+		}
+		for j, a := range contents[i].Attachments {
+			if a.ODataType != AttachmentTypeReference && a.Size <= MaxBatchedContentSize {
+				refs = append(refs, ref{i, j})
+				sizes = append(sizes, a.Size)
+			}
+		}
+	}
+
+	for _, group := range t.client.groupBySize(sizes) {
+		reqs := make([]BatchRequest, len(group))
+		for n, k := range group {
+			c := &contents[refs[k].message]
+			a := c.Attachments[refs[k].attachment]
+			reqs[n] = BatchRequest{Method: http.MethodGet, URL: relative("/users/"+userID+"/messages/"+c.MessageID+"/attachments/"+a.ID+"/$value", nil)}
+		}
+		results, err := t.Batch(ctx, reqs)
+		if err != nil {
+			return err
+		}
+		for n, result := range results {
+			r := refs[group[n]]
+			c := &contents[r.message]
+			if result.Err != nil {
+				if c.Err == nil {
+					c.Err = fmt.Errorf("failed to download attachment: %w", result.Err)
+				}
+				continue
+			}
+			data, err := result.Response.Bytes()
+			if err != nil {
+				c.Err = err
+				continue
+			}
+			c.Attachments[r.attachment].content = data
+		}
+	}
+	return nil
+}
+
+// groupBySize splits item indexes into batches limited by count and by
+// estimated response size
+func (c *Client) groupBySize(sizes []int64) [][]int {
+	var groups [][]int
+	var current []int
+	var total int64
+	for i, size := range sizes {
+		if len(current) > 0 && (len(current) == c.batchSize || total+size > maxBatchBytes) {
+			groups = append(groups, current)
+			current, total = nil, 0
+		}
+		current = append(current, i)
+		total += size
+	}
+	if len(current) > 0 {
+		groups = append(groups, current)
+	}
+	return groups
+}
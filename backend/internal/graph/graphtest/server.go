@@ -0,0 +1,601 @@
+// Package graphtest provides an in-memory fake of the Microsoft Graph mail
+// API and the Azure AD token endpoint for tests and benchmarks.
+package graphtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultPageSize is used when the client sends no odata.maxpagesize preference
+const defaultPageSize = 10
+
+// Folder is a mail folder of a fake mailbox
+type Folder struct {
+	ID          string
+	DisplayName string
+	ParentID    string
+}
+
+// Attachment is an attachment of a fake message. Item attachments are
+// attached messages whose $value is MIME.
+type Attachment struct {
+	ID          string
+	Name        string
+	ContentType string
+	Content     []byte
+	Item        bool
+	Inline      bool
+}
+
+// Message is a message in a fake mailbox
+type Message struct {
+	ID          string
+	FolderID    string
+	Subject     string
+	From        string
+	To          []string
+	SentAt      time.Time
+	Body        string
+	MIME        []byte
+	Attachments []Attachment
+	Read        bool
+	FlagStatus  string // Graph followupFlagStatus, "notFlagged" if empty
+
+	seq int
+}
+
+// change records a message leaving a folder
+type change struct {
+	messageID string
+	folderID  string
+	seq       int
+}
+
+type mailbox struct {
+	folders  []Folder
+	messages map[string]*Message
+	removed  []change
+}
+
+// Failure is an injected error response
+type Failure struct {
+	// Path restricts the failure to requests whose path contains it. Empty
+	// matches any request except $batch calls themselves, whose sub-requests
+	// are matched individually; use "$batch" to fail a whole batch call.
+	Path   string
+	Status int
+	Code   string
+	// Header is added to the response, e.g. Retry-After
+	Header http.Header
+}
+
+// Server is a fake Graph endpoint backed by an httptest.Server
+type Server struct {
+	*httptest.Server
+
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+
+	mux *http.ServeMux
+
+	mu            sync.Mutex
+	latency       time.Duration
+	batches       int
+	seq           int
+	mailboxes     map[string]*mailbox
+	tokens        map[string]bool
+	tokenRequests int
+	requests      int
+	expiredBefore int
+	failures      []Failure
+}
+
+// NewServer starts a fake accepting the given app registration
+func NewServer(tenantID, clientID, clientSecret string) *Server {
+	s := &Server{
+		TenantID:     tenantID,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		mailboxes:    make(map[string]*mailbox),
+		tokens:       make(map[string]bool),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login/{tenant}/oauth2/v2.0/token", s.handleToken)
+	mux.HandleFunc("GET /v1.0/users/{user}/mailFolders", s.graph(s.handleFolders))
+	mux.HandleFunc("GET /v1.0/users/{user}/mailFolders/{folder}/childFolders", s.graph(s.handleFolders))
+	mux.HandleFunc("GET /v1.0/users/{user}/mailFolders/{folder}/messages/delta", s.graph(s.handleDelta))
+	mux.HandleFunc("GET /v1.0/users/{user}/messages/{message}/$value", s.graph(s.handleMIME))
+	mux.HandleFunc("GET /v1.0/users/{user}/messages/{message}/attachments", s.graph(s.handleAttachments))
+	mux.HandleFunc("GET /v1.0/users/{user}/messages/{message}/attachments/{attachment}/$value", s.graph(s.handleAttachmentValue))
+	mux.HandleFunc("POST /v1.0/$batch", s.handleBatch)
+	s.mux = mux
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// serve applies the simulated network latency to every HTTP round trip
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	latency := s.latency
+	s.mu.Unlock()
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// SetLatency delays every HTTP round trip, e.g. to measure the effect of batching
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Batches returns how many $batch calls were received
+func (s *Server) Batches() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.batches
+}
+
+// BaseURL is the Graph API root to configure the client with
+func (s *Server) BaseURL() string {
+	return s.URL + "/v1.0"
+}
+
+// LoginURL is the OAuth authority to configure the client with
+func (s *Server) LoginURL() string {
+	return s.URL + "/login"
+}
+
+// AddFolder creates a folder in a mailbox
+func (s *Server) AddFolder(user string, folder Folder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mb := s.mailbox(user)
+	mb.folders = append(mb.folders, folder)
+}
+
+// AddMessage stores a message; it shows up in the next delta round of its folder
+func (s *Server) AddMessage(user string, msg Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	m := msg
+	m.seq = s.seq
+	if m.MIME == nil {
+		m.MIME = buildMIME(&m)
+	}
+	s.mailbox(user).messages[m.ID] = &m
+}
+
+// RemoveMessage deletes a message; delta rounds report it as removed
+func (s *Server) RemoveMessage(user, messageID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mb := s.mailbox(user)
+	m, ok := mb.messages[messageID]
+	if !ok {
+		return
+	}
+	s.seq++
+	mb.removed = append(mb.removed, change{messageID: messageID, folderID: m.FolderID, seq: s.seq})
+	delete(mb.messages, messageID)
+}
+
+// ExpireDeltaLinks makes every delta link issued so far fail with 410 Gone
+func (s *Server) ExpireDeltaLinks() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expiredBefore = s.seq + 1
+}
+
+// FailNext makes the next n matching Graph API requests fail
+func (s *Server) FailNext(n int, f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for range n {
+		s.failures = append(s.failures, f)
+	}
+}
+
+// TokenRequests returns how many tokens were issued
+func (s *Server) TokenRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokenRequests
+}
+
+// Requests returns how many Graph API requests were received, counting each
+// batch sub-request and the batch call itself
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// RevokeTokens invalidates all issued tokens, as after a secret reset
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = make(map[string]bool)
+}
+
+func (s *Server) mailbox(user string) *mailbox {
+	key := strings.ToLower(user)
+	mb, ok := s.mailboxes[key]
+	if !ok {
+		mb = &mailbox{messages: make(map[string]*Message)}
+		s.mailboxes[key] = mb
+	}
+	return mb
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	if r.PathValue("tenant") != s.TenantID {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_tenant")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "client_credentials" ||
+		r.PostForm.Get("client_id") != s.ClientID ||
+		r.PostForm.Get("client_secret") != s.ClientSecret {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	s.mu.Lock()
+	s.tokenRequests++
+	token := fmt.Sprintf("token-%d", s.tokenRequests)
+	s.tokens[token] = true
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"token_type":   "Bearer",
+		"expires_in":   3599,
+		"access_token": token,
+	})
+}
+
+// graph wraps a Graph API handler with authentication and failure injection
+func (s *Server) graph(h func(http.ResponseWriter, *http.Request, *mailbox)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests++
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !s.tokens[token] {
+			writeGraphError(w, http.StatusUnauthorized, "InvalidAuthenticationToken", nil)
+			return
+		}
+		for i, f := range s.failures {
+			if strings.Contains(r.URL.Path, f.Path) {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+				writeGraphError(w, f.Status, f.Code, f.Header)
+				return
+			}
+		}
+		mb, ok := s.mailboxes[strings.ToLower(r.PathValue("user"))]
+		if !ok {
+			writeGraphError(w, http.StatusNotFound, "ErrorInvalidUser", nil)
+			return
+		}
+		h(w, r, mb)
+	}
+}
+
+func (s *Server) handleFolders(w http.ResponseWriter, r *http.Request, mb *mailbox) {
+	parent := r.PathValue("folder")
+	value := []map[string]any{}
+	for _, f := range mb.folders {
+		if f.ParentID != parent {
+			continue
+		}
+		children, items := 0, 0
+		for _, c := range mb.folders {
+			if c.ParentID == f.ID {
+				children++
+			}
+		}
+		for _, m := range mb.messages {
+			if m.FolderID == f.ID {
+				items++
+			}
+		}
+		value = append(value, map[string]any{
+			"id":               f.ID,
+			"displayName":      f.DisplayName,
+			"parentFolderId":   f.ParentID,
+			"childFolderCount": children,
+			"totalItemCount":   items,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"value": value})
+}
+
+// handleDelta pages through a folder. Links encode the round's starting
+// sequence ("since") and the position within the round's snapshot.
+func (s *Server) handleDelta(w http.ResponseWriter, r *http.Request, mb *mailbox) {
+	folderID := r.PathValue("folder")
+	query := r.URL.Query()
+	since, upTo, offset := 0, s.seq, 0
+
+	if dt := query.Get("$deltatoken"); dt != "" {
+		n, err := strconv.Atoi(dt)
+		if err != nil || n < s.expiredBefore {
+			writeGraphError(w, http.StatusGone, "SyncStateNotFound", nil)
+			return
+		}
+		since = n
+	}
+	if st := query.Get("$skiptoken"); st != "" {
+		parts := strings.Split(st, ".")
+		if len(parts) != 3 {
+			writeGraphError(w, http.StatusBadRequest, "BadRequest", nil)
+			return
+		}
+		since, _ = strconv.Atoi(parts[0])
+		upTo, _ = strconv.Atoi(parts[1])
+		offset, _ = strconv.Atoi(parts[2])
+		if since > 0 && since < s.expiredBefore {
+			writeGraphError(w, http.StatusGone, "SyncStateNotFound", nil)
+			return
+		}
+	}
+
+	entries := deltaEntries(mb, folderID, since, upTo)
+	pageSize := maxPageSize(r.Header.Values("Prefer"))
+	end := min(offset+pageSize, len(entries))
+	page := []map[string]any{}
+	if offset < len(entries) {
+		page = entries[offset:end]
+	}
+
+	link := s.URL + r.URL.EscapedPath()
+	resp := map[string]any{"value": page}
+	if end < len(entries) {
+		resp["@odata.nextLink"] = link + "?$skiptoken=" + fmt.Sprintf("%d.%d.%d", since, upTo, end)
+	} else {
+		resp["@odata.deltaLink"] = link + "?$deltatoken=" + strconv.Itoa(upTo)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// deltaEntries lists changes of a folder with since < seq <= upTo in order
+func deltaEntries(mb *mailbox, folderID string, since, upTo int) []map[string]any {
+	type entry struct {
+		seq  int
+		body map[string]any
+	}
+	var entries []entry
+	for _, m := range mb.messages {
+		if m.FolderID == folderID && m.seq > since && m.seq <= upTo {
+			entries = append(entries, entry{m.seq, messageJSON(m)})
+		}
+	}
+	if since > 0 {
+		for _, c := range mb.removed {
+			if c.folderID == folderID && c.seq > since && c.seq <= upTo {
+				entries = append(entries, entry{c.seq, map[string]any{
+					"id":       c.messageID,
+					"@removed": map[string]string{"reason": "deleted"},
+				}})
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+	out := make([]map[string]any, len(entries))
+	for i, e := range entries {
+		out[i] = e.body
+	}
+	return out
+}
+
+func messageJSON(m *Message) map[string]any {
+	to := make([]map[string]any, len(m.To))
+	for i, addr := range m.To {
+		to[i] = map[string]any{"emailAddress": map[string]string{"address": addr}}
+	}
+	flagStatus := m.FlagStatus
+	if flagStatus == "" {
+		flagStatus = "notFlagged"
+	}
+	return map[string]any{
+		"id":                m.ID,
+		"subject":           m.Subject,
+		"from":              map[string]any{"emailAddress": map[string]string{"address": m.From}},
+		"toRecipients":      to,
+		"sentDateTime":      m.SentAt.UTC().Format(time.RFC3339),
+		"receivedDateTime":  m.SentAt.UTC().Format(time.RFC3339),
+		"hasAttachments":    len(m.Attachments) > 0,
+		"internetMessageId": "<" + m.ID + "@graphtest>",
+		"isRead":            m.Read,
+		"flag":              map[string]string{"flagStatus": flagStatus},
+		"body":              map[string]string{"contentType": "html", "content": m.Body},
+	}
+}
+
+func (s *Server) handleMIME(w http.ResponseWriter, r *http.Request, mb *mailbox) {
+	m, ok := mb.messages[r.PathValue("message")]
+	if !ok {
+		writeGraphError(w, http.StatusNotFound, "ErrorItemNotFound", nil)
+		return
+	}
+	w.Header().Set("Content-Type", "message/rfc822")
+	_, _ = w.Write(m.MIME)
+}
+
+func (s *Server) handleAttachments(w http.ResponseWriter, r *http.Request, mb *mailbox) {
+	m, ok := mb.messages[r.PathValue("message")]
+	if !ok {
+		writeGraphError(w, http.StatusNotFound, "ErrorItemNotFound", nil)
+		return
+	}
+	value := []map[string]any{}
+	for _, a := range m.Attachments {
+		odataType := "#microsoft.graph.fileAttachment"
+		if a.Item {
+			odataType = "#microsoft.graph.itemAttachment"
+		}
+		value = append(value, map[string]any{
+			"@odata.type": odataType,
+			"id":          a.ID,
+			"name":        a.Name,
+			"contentType": a.ContentType,
+			"size":        len(a.Content),
+			"isInline":    a.Inline,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"value": value})
+}
+
+func (s *Server) handleAttachmentValue(w http.ResponseWriter, r *http.Request, mb *mailbox) {
+	m, ok := mb.messages[r.PathValue("message")]
+	if !ok {
+		writeGraphError(w, http.StatusNotFound, "ErrorItemNotFound", nil)
+		return
+	}
+	for _, a := range m.Attachments {
+		if a.ID == r.PathValue("attachment") {
+			w.Header().Set("Content-Type", a.ContentType)
+			_, _ = w.Write(a.Content)
+			return
+		}
+	}
+	writeGraphError(w, http.StatusNotFound, "ErrorItemNotFound", nil)
+}
+
+// maxBatchSize is the sub-request limit of a Graph JSON batch
+const maxBatchSize = 20
+
+// handleBatch runs each sub-request through the regular handlers and wraps
+// the results the way Graph does, with binary bodies base64-encoded
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	s.batches++
+	authorized := s.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	var injected *Failure
+	for i, f := range s.failures {
+		if f.Path != "" && strings.Contains(r.URL.Path, f.Path) {
+			injected = &f
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if !authorized {
+		writeGraphError(w, http.StatusUnauthorized, "InvalidAuthenticationToken", nil)
+		return
+	}
+	if injected != nil {
+		writeGraphError(w, injected.Status, injected.Code, injected.Header)
+		return
+	}
+
+	var batch struct {
+		Requests []struct {
+			ID      string            `json:"id"`
+			Method  string            `json:"method"`
+			URL     string            `json:"url"`
+			Headers map[string]string `json:"headers"`
+		} `json:"requests"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil || len(batch.Requests) == 0 {
+		writeGraphError(w, http.StatusBadRequest, "BadRequest", nil)
+		return
+	}
+	if len(batch.Requests) > maxBatchSize {
+		writeGraphError(w, http.StatusBadRequest, "TooManyRequestsInBatch", nil)
+		return
+	}
+
+	responses := make([]map[string]any, 0, len(batch.Requests))
+	for _, sub := range batch.Requests {
+		req := httptest.NewRequest(sub.Method, "/v1.0"+sub.URL, nil)
+		req.Header.Set("Authorization", r.Header.Get("Authorization"))
+		for k, v := range sub.Headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, req)
+
+		headers := map[string]string{}
+		for k := range rec.Header() {
+			headers[k] = rec.Header().Get(k)
+		}
+		var body any = base64.StdEncoding.EncodeToString(rec.Body.Bytes())
+		if strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+			body = json.RawMessage(rec.Body.Bytes())
+		}
+		responses = append(responses, map[string]any{
+			"id":      sub.ID,
+			"status":  rec.Code,
+			"headers": headers,
+			"body":    body,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"responses": responses})
+}
+
+// maxPageSize reads odata.maxpagesize from Prefer headers
+func maxPageSize(prefer []string) int {
+	for _, header := range prefer {
+		for _, p := range strings.Split(header, ",") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "odata.maxpagesize="); ok {
+				if n, err := strconv.Atoi(v); err == nil && n > 0 {
+					return n
+				}
+			}
+		}
+	}
+	return defaultPageSize
+}
+
+// buildMIME renders a minimal RFC 822 message
+func buildMIME(m *Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Message-ID: <%s@graphtest>\r\n", m.ID)
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", m.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", m.SentAt.UTC().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/html; charset=utf-8\r\n\r\n")
+	b.WriteString(m.Body)
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeGraphError(w http.ResponseWriter, status int, code string, header http.Header) {
+	for k, v := range header {
+		w.Header()[k] = v
+	}
+	writeJSON(w, status, map[string]any{
+		"error": map[string]string{"code": code, "message": http.StatusText(status)},
+	})
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{"error": code, "error_description": code})
+}
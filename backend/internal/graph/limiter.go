@@ -0,0 +1,204 @@
+package graph
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mathrand "math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database"
+)
+
+// Limiter enforces a request budget per tenant across all workers
+type Limiter interface {
+	// Acquire blocks until the tenant may send a request counting as weight
+	// Graph requests. The returned release function must be called once the
+	// response body is consumed.
+	Acquire(ctx context.Context, tenant string, weight int) (release func(), err error)
+	// Pause holds back all requests of the tenant, e.g. for a Retry-After period
+	Pause(ctx context.Context, tenant string, d time.Duration)
+}
+
+// LimiterConfig configures the per-tenant request budget
+type LimiterConfig struct {
+	// MaxConcurrent is the number of in-flight requests allowed per tenant
+	MaxConcurrent int
+	// Rate is the number of requests allowed per tenant within Window
+	Rate   int
+	Window time.Duration
+	// Lease bounds how long a crashed worker can hold a concurrency slot
+	Lease time.Duration
+}
+
+const (
+	// maxLimiterPoll caps how long Acquire sleeps before asking Redis again
+	maxLimiterPoll = time.Second
+	// limiterWarnInterval rate-limits warnings while Redis is unreachable
+	limiterWarnInterval = time.Minute
+)
+
+// acquireScript atomically checks the tenant's pause flag, concurrency slots
+// and sliding request window. It returns 0 when a slot was taken, otherwise
+// the number of milliseconds to wait before trying again.
+//
+// KEYS: pause, inflight, requests
+// ARGV: member, max concurrent, rate, window ms, lease ms, weight
+var acquireScript = redis.NewScript(`
+local paused = redis.call('PTTL', KEYS[1])
+if paused > 0 then
+	return paused
+end
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local window = tonumber(ARGV[4])
+local lease = tonumber(ARGV[5])
+local weight = tonumber(ARGV[6])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', now)
+if redis.call('ZCARD', KEYS[2]) >= tonumber(ARGV[2]) then
+	return 50
+end
+
+redis.call('ZREMRANGEBYSCORE', KEYS[3], '-inf', now - window)
+local used = redis.call('ZCARD', KEYS[3])
+if used > 0 and used + weight > tonumber(ARGV[3]) then
+	local oldest = redis.call('ZRANGE', KEYS[3], 0, 0, 'WITHSCORES')
+	return math.max(tonumber(oldest[2]) + window - now, 1)
+end
+
+redis.call('ZADD', KEYS[2], now + lease, ARGV[1])
+redis.call('PEXPIRE', KEYS[2], lease)
+for i = 1, weight do
+	redis.call('ZADD', KEYS[3], now, ARGV[1] .. ':' .. i)
+end
+redis.call('PEXPIRE', KEYS[3], window)
+return 0
+`)
+
+// RedisLimiter shares per-tenant budgets between processes through Redis.
+// If Redis is unreachable it fails open so syncs degrade to unthrottled
+// rather than stopping.
+type RedisLimiter struct {
+	client *redis.Client
+	cfg    LimiterConfig
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	lastWarn time.Time
+}
+
+// NewRedisLimiter creates a limiter on the shared Redis connection
+func NewRedisLimiter(conn *database.RedisConnection, cfg LimiterConfig, logger *zap.Logger) *RedisLimiter {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = 5
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	if cfg.Rate <= 0 {
+		cfg.Rate = 900
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 5 * time.Minute
+	}
+	return &RedisLimiter{client: conn.Client, cfg: cfg, logger: logger}
+}
+
+func limiterKey(tenant, name string) string {
+	return "ironarchive:graph:" + tenant + ":" + name
+}
+
+// Acquire waits for a concurrency slot and weight requests within the rate
+// window. A weight above the rate only passes once the window is empty.
+func (l *RedisLimiter) Acquire(ctx context.Context, tenant string, weight int) (func(), error) {
+	member, err := randomMember()
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{limiterKey(tenant, "pause"), limiterKey(tenant, "inflight"), limiterKey(tenant, "requests")}
+	args := []any{member, l.cfg.MaxConcurrent, l.cfg.Rate, l.cfg.Window.Milliseconds(), l.cfg.Lease.Milliseconds(), max(weight, 1)}
+
+	for {
+		wait, err := acquireScript.Run(ctx, l.client, keys, args...).Int64()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			l.warnUnavailable(tenant, err)
+			return func() {}, nil
+		}
+		if wait == 0 {
+			return func() { l.release(tenant, member) }, nil
+		}
+		// Sleep in short steps with jitter so waiting workers don't retry in lockstep
+		d := min(time.Duration(wait)*time.Millisecond, maxLimiterPoll)
+		d += time.Duration(mathrand.Int64N(int64(d)/4 + 1))
+		if err := sleep(ctx, d); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// warnUnavailable logs Redis failures at most once per limiterWarnInterval
+func (l *RedisLimiter) warnUnavailable(tenant string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Since(l.lastWarn) < limiterWarnInterval {
+		return
+	}
+	l.lastWarn = time.Now()
+	l.logger.Warn("Graph request budget unavailable, continuing without it", zap.String("tenant", tenant), zap.Error(err))
+}
+
+// release frees a concurrency slot; the rate window entry stays until it expires
+func (l *RedisLimiter) release(tenant, member string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := l.client.ZRem(ctx, limiterKey(tenant, "inflight"), member).Err(); err != nil {
+		l.logger.Warn("Failed to release Graph request slot", zap.String("tenant", tenant), zap.Error(err))
+	}
+}
+
+// Pause blocks all workers from sending requests for the tenant for d
+func (l *RedisLimiter) Pause(ctx context.Context, tenant string, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	key := limiterKey(tenant, "pause")
+	// Extend an existing pause but never shorten it
+	if ttl, err := l.client.PTTL(ctx, key).Result(); err == nil && ttl >= d {
+		return
+	}
+	if err := l.client.Set(ctx, key, "1", d).Err(); err != nil && !errors.Is(err, context.Canceled) {
+		l.logger.Warn("Failed to pause Graph requests", zap.String("tenant", tenant), zap.Error(err))
+	}
+}
+
+// randomMember returns a unique ID for a request slot
+func randomMember() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate request ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
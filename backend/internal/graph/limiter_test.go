@@ -0,0 +1,124 @@
+package graph
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database"
+	"ironarchive/internal/graph/graphtest"
+)
+
+// newTestLimiter returns a limiter backed by an in-memory Redis
+func newTestLimiter(t *testing.T, cfg LimiterConfig) (*RedisLimiter, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	conn := &database.RedisConnection{Client: redis.NewClient(&redis.Options{Addr: mr.Addr()})}
+	t.Cleanup(func() { conn.Client.Close() })
+	return NewRedisLimiter(conn, cfg, zap.NewNop()), mr
+}
+
+// TestLimiterCapsConcurrency verifies a tenant never exceeds its in-flight budget
+func TestLimiterCapsConcurrency(t *testing.T) {
+	limiter, _ := newTestLimiter(t, LimiterConfig{MaxConcurrent: 2, Rate: 100, Window: time.Minute})
+	ctx := context.Background()
+
+	release1, err := limiter.Acquire(ctx, "t1", 1)
+	require.NoError(t, err)
+	_, err = limiter.Acquire(ctx, "t1", 1)
+	require.NoError(t, err)
+
+	blocked, cancel := context.WithTimeout(ctx, 150*time.Millisecond)
+	defer cancel()
+	_, err = limiter.Acquire(blocked, "t1", 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Other tenants have their own budget
+	_, err = limiter.Acquire(ctx, "t2", 1)
+	require.NoError(t, err)
+
+	release1()
+	release1()
+	_, err = limiter.Acquire(ctx, "t1", 1)
+	assert.NoError(t, err)
+}
+
+// TestLimiterEnforcesRate verifies the sliding request window
+func TestLimiterEnforcesRate(t *testing.T) {
+	limiter, _ := newTestLimiter(t, LimiterConfig{MaxConcurrent: 10, Rate: 2, Window: 300 * time.Millisecond})
+	ctx := context.Background()
+
+	for range 2 {
+		release, err := limiter.Acquire(ctx, "t1", 1)
+		require.NoError(t, err)
+		release()
+	}
+
+	start := time.Now()
+	release, err := limiter.Acquire(ctx, "t1", 1)
+	require.NoError(t, err)
+	release()
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
+
+// TestLimiterPause verifies a throttled tenant is held back for every worker
+func TestLimiterPause(t *testing.T) {
+	limiter, mr := newTestLimiter(t, LimiterConfig{})
+	ctx := context.Background()
+
+	limiter.Pause(ctx, "t1", time.Minute)
+	limiter.Pause(ctx, "t1", time.Second)
+	assert.Greater(t, mr.TTL(limiterKey("t1", "pause")), 30*time.Second, "a shorter pause does not cut a longer one")
+
+	blocked, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err := limiter.Acquire(blocked, "t1", 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// TestLimiterFailsOpen verifies requests continue when Redis is down
+func TestLimiterFailsOpen(t *testing.T) {
+	limiter, mr := newTestLimiter(t, LimiterConfig{})
+	mr.Close()
+
+	release, err := limiter.Acquire(context.Background(), "t1", 1)
+	require.NoError(t, err)
+	release()
+}
+
+// TestThrottlingPausesSharedBudget verifies a 429 pauses the tenant in Redis for Retry-After
+func TestThrottlingPausesSharedBudget(t *testing.T) {
+	server := graphtest.NewServer(testTenant, "app-id", "app-secret")
+	t.Cleanup(server.Close)
+	server.AddFolder(testUser, graphtest.Folder{ID: "inbox", DisplayName: "Inbox"})
+	limiter, mr := newTestLimiter(t, LimiterConfig{MaxConcurrent: 1})
+
+	client, err := NewClient(Config{BaseURL: server.BaseURL(), LoginURL: server.LoginURL(), Limiter: limiter})
+	require.NoError(t, err)
+	tenant := client.Tenant(testTenant, testCredentials("app-secret"))
+	server.FailNext(1, graphtest.Failure{Status: http.StatusTooManyRequests, Code: "TooManyRequests", Header: http.Header{"Retry-After": {"30"}}})
+
+	// The pause would block the retry, so lift it once it has been recorded
+	var paused time.Duration
+	client.sleep = func(context.Context, time.Duration) error {
+		paused = mr.TTL(limiterKey(testTenant, "pause"))
+		mr.Del(limiterKey(testTenant, "pause"))
+		return nil
+	}
+
+	_, err = tenant.ListMailFolders(context.Background(), testUser)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, paused)
+
+	// All slots were released after the bodies were read
+	count, err := limiter.client.ZCard(context.Background(), limiterKey(testTenant, "inflight")).Result()
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,218 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Attachment OData types
+const (
+	AttachmentTypeFile      = "#microsoft.graph.fileAttachment"
+	AttachmentTypeItem      = "#microsoft.graph.itemAttachment"
+	AttachmentTypeReference = "#microsoft.graph.referenceAttachment"
+)
+
+// messageSelect lists the message properties archived from delta queries
+const messageSelect = "subject,from,toRecipients,ccRecipients,bccRecipients,sentDateTime," +
+	"receivedDateTime,hasAttachments,internetMessageId,conversationId,categories,isRead,flag,body"
+
+// MailFolder is a folder in a user's mailbox
+type MailFolder struct {
+	ID               string `json:"id"`
+	DisplayName      string `json:"displayName"`
+	ParentFolderID   string `json:"parentFolderId"`
+	ChildFolderCount int    `json:"childFolderCount"`
+	TotalItemCount   int    `json:"totalItemCount"`
+}
+
+// EmailAddress is a named address
+type EmailAddress struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// Recipient wraps an address as Graph returns it
+type Recipient struct {
+	EmailAddress EmailAddress `json:"emailAddress"`
+}
+
+// ItemBody is a message body
+type ItemBody struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+// Removed marks a delta entry for a message that left the folder
+type Removed struct {
+	Reason string `json:"reason"`
+}
+
+// FollowupFlag is the follow-up flag of a message
+type FollowupFlag struct {
+	FlagStatus string `json:"flagStatus"`
+}
+
+// Message is the archived subset of a Graph message
+type Message struct {
+	ID                string        `json:"id"`
+	Subject           string        `json:"subject"`
+	From              *Recipient    `json:"from"`
+	ToRecipients      []Recipient   `json:"toRecipients"`
+	CcRecipients      []Recipient   `json:"ccRecipients"`
+	BccRecipients     []Recipient   `json:"bccRecipients"`
+	SentDateTime      *time.Time    `json:"sentDateTime"`
+	ReceivedDateTime  *time.Time    `json:"receivedDateTime"`
+	HasAttachments    bool          `json:"hasAttachments"`
+	InternetMessageID string        `json:"internetMessageId"`
+	ConversationID    string        `json:"conversationId"`
+	Categories        []string      `json:"categories"`
+	IsRead            bool          `json:"isRead"`
+	Flag              *FollowupFlag `json:"flag"`
+	Body              *ItemBody     `json:"body"`
+	Removed           *Removed      `json:"@removed,omitempty"`
+}
+
+// Attachment is attachment metadata without content
+type Attachment struct {
+	ODataType   string `json:"@odata.type"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	IsInline    bool   `json:"isInline"`
+}
+
+// collection is a page of a Graph collection response
+type collection[T any] struct {
+	Value     []T    `json:"value"`
+	NextLink  string `json:"@odata.nextLink"`
+	DeltaLink string `json:"@odata.deltaLink"`
+}
+
+// messagesHeader asks for immutable IDs, so a message keeps its ID when moved
+// between folders, and for the configured delta page size
+func (c *Client) messagesHeader() http.Header {
+	h := http.Header{}
+	h.Add("Prefer", `IdType="ImmutableId"`)
+	h.Add("Prefer", "odata.maxpagesize="+strconv.Itoa(c.pageSize))
+	return h
+}
+
+// ListMailFolders returns every folder of a mailbox, including hidden and nested ones
+func (t *TenantClient) ListMailFolders(ctx context.Context, userID string) ([]MailFolder, error) {
+	query := url.Values{"includeHiddenFolders": {"true"}, "$top": {"100"}}
+	folders, err := t.listFolders(ctx, t.client.resolve("/users/"+userID+"/mailFolders", query))
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(folders); i++ {
+		if folders[i].ChildFolderCount == 0 {
+			continue
+		}
+		children, err := t.listFolders(ctx, t.client.resolve("/users/"+userID+"/mailFolders/"+folders[i].ID+"/childFolders", query))
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, children...)
+	}
+	return folders, nil
+}
+
+// listFolders reads every page of a folder collection
+func (t *TenantClient) listFolders(ctx context.Context, link string) ([]MailFolder, error) {
+	var folders []MailFolder
+	for link != "" {
+		var page collection[MailFolder]
+		if err := t.getJSON(ctx, link, nil, &page); err != nil {
+			return nil, fmt.Errorf("failed to list mail folders: %w", err)
+		}
+		folders = append(folders, page.Value...)
+		if page.NextLink != "" {
+			if err := t.client.checkLink(page.NextLink); err != nil {
+				return nil, err
+			}
+		}
+		link = page.NextLink
+	}
+	return folders, nil
+}
+
+// MessageDelta runs one delta round over a folder, calling fn for every page.
+// An empty deltaLink starts a full initial sync. It returns the new delta link
+// once the last page was handled; if fn fails the round stops and the
+// previous link stays valid.
+func (t *TenantClient) MessageDelta(ctx context.Context, userID, folderID, deltaLink string, fn func([]Message) error) (string, error) {
+	link := deltaLink
+	if link == "" {
+		link = t.client.resolve("/users/"+userID+"/mailFolders/"+folderID+"/messages/delta", url.Values{"$select": {messageSelect}})
+	} else if err := t.client.checkLink(link); err != nil {
+		return "", err
+	}
+
+	header := t.client.messagesHeader()
+	for {
+		var page collection[Message]
+		if err := t.getJSON(ctx, link, header, &page); err != nil {
+			return "", fmt.Errorf("failed to query message delta: %w", err)
+		}
+		if err := fn(page.Value); err != nil {
+			return "", err
+		}
+		switch {
+		case page.DeltaLink != "":
+			return page.DeltaLink, nil
+		case page.NextLink != "":
+			if err := t.client.checkLink(page.NextLink); err != nil {
+				return "", err
+			}
+			link = page.NextLink
+		default:
+			return "", fmt.Errorf("delta page has neither next nor delta link")
+		}
+	}
+}
+
+// MessageMIME streams the RFC 822 content of a message. The caller closes it.
+func (t *TenantClient) MessageMIME(ctx context.Context, userID, messageID string) (io.ReadCloser, error) {
+	body, err := t.stream(ctx, t.client.resolve("/users/"+userID+"/messages/"+messageID+"/$value", nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download message MIME: %w", err)
+	}
+	return body, nil
+}
+
+// ListAttachments returns attachment metadata of a message
+func (t *TenantClient) ListAttachments(ctx context.Context, userID, messageID string) ([]Attachment, error) {
+	query := url.Values{"$select": {"id,name,contentType,size,isInline"}}
+	link := t.client.resolve("/users/"+userID+"/messages/"+messageID+"/attachments", query)
+	var attachments []Attachment
+	for link != "" {
+		var page collection[Attachment]
+		if err := t.getJSON(ctx, link, nil, &page); err != nil {
+			return nil, fmt.Errorf("failed to list attachments: %w", err)
+		}
+		attachments = append(attachments, page.Value...)
+		if page.NextLink != "" {
+			if err := t.client.checkLink(page.NextLink); err != nil {
+				return nil, err
+			}
+		}
+		link = page.NextLink
+	}
+	return attachments, nil
+}
+
+// AttachmentContent streams the raw content of a file attachment, or the MIME
+// of an attached item. The caller closes it.
+func (t *TenantClient) AttachmentContent(ctx context.Context, userID, messageID, attachmentID string) (io.ReadCloser, error) {
+	body, err := t.stream(ctx, t.client.resolve("/users/"+userID+"/messages/"+messageID+"/attachments/"+attachmentID+"/$value", nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download attachment: %w", err)
+	}
+	return body, nil
+}
@@ -0,0 +1,111 @@
+package graph
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ironarchive/internal/graph/graphtest"
+)
+
+// recordSleeps replaces the client's sleep with one that records delays
+func recordSleeps(client *TenantClient) *[]time.Duration {
+	var delays []time.Duration
+	client.client.sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	return &delays
+}
+
+// TestRetryHonoursRetryAfter verifies throttled requests wait as instructed and then succeed
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	server, client := newTestClient(t, 10)
+	delays := recordSleeps(client)
+	server.FailNext(1, graphtest.Failure{Status: http.StatusTooManyRequests, Code: "TooManyRequests", Header: http.Header{"Retry-After": {"7"}}})
+	server.FailNext(1, graphtest.Failure{Status: http.StatusServiceUnavailable, Code: "ServiceUnavailable", Header: http.Header{"Retry-After": {"3"}}})
+
+	folders, err := client.ListMailFolders(context.Background(), testUser)
+	require.NoError(t, err)
+	assert.Len(t, folders, 2)
+	assert.Equal(t, []time.Duration{7 * time.Second, 3 * time.Second}, *delays)
+}
+
+// TestRetryBacksOffAndGivesUp verifies transient errors use bounded backoff and stop after the retry limit
+func TestRetryBacksOffAndGivesUp(t *testing.T) {
+	server, client := newTestClient(t, 10)
+	delays := recordSleeps(client)
+	server.FailNext(DefaultMaxRetries+1, graphtest.Failure{Status: http.StatusBadGateway, Code: "BadGateway"})
+
+	before := server.Requests()
+	_, err := client.ListMailFolders(context.Background(), testUser)
+	require.Error(t, err)
+	assert.False(t, IsPermanent(err))
+	assert.Equal(t, DefaultMaxRetries+1, server.Requests()-before)
+	require.Len(t, *delays, DefaultMaxRetries)
+	for i, d := range *delays {
+		full := DefaultRetryBaseDelay << i
+		assert.GreaterOrEqual(t, d, full/2, "attempt %d", i)
+		assert.LessOrEqual(t, d, full, "attempt %d", i)
+	}
+}
+
+// TestPermanentErrorsFailFast verifies forbidden and deleted mailboxes are not retried
+func TestPermanentErrorsFailFast(t *testing.T) {
+	server, client := newTestClient(t, 10)
+	delays := recordSleeps(client)
+	server.FailNext(1, graphtest.Failure{Status: http.StatusForbidden, Code: "ErrorAccessDenied"})
+
+	_, err := client.ListMailFolders(context.Background(), testUser)
+	require.Error(t, err)
+	assert.True(t, IsPermanent(err))
+	assert.True(t, IsAccessDenied(err))
+	assert.False(t, IsMailboxUnavailable(err))
+
+	_, err = client.ListMailFolders(context.Background(), "deleted@contoso.com")
+	require.Error(t, err)
+	assert.True(t, IsPermanent(err))
+	assert.True(t, IsMailboxUnavailable(err))
+	assert.False(t, IsAccessDenied(err))
+	assert.Empty(t, *delays)
+}
+
+// TestInvalidCredentialsAreAccessDenied verifies token failures are tenant-wide permanent errors
+func TestInvalidCredentialsAreAccessDenied(t *testing.T) {
+	server, _ := newTestClient(t, 10)
+	client, err := NewClient(Config{BaseURL: server.BaseURL(), LoginURL: server.LoginURL()})
+	require.NoError(t, err)
+	tenant := client.Tenant(testTenant, testCredentials("wrong"))
+	delays := recordSleeps(tenant)
+
+	_, err = tenant.ListMailFolders(context.Background(), testUser)
+	assert.True(t, IsAccessDenied(err))
+	assert.True(t, IsPermanent(err))
+	assert.Empty(t, *delays)
+}
+
+// TestParseRetryAfter verifies both Retry-After formats
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(""))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-5"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+
+	d := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.Greater(t, d, 55*time.Second)
+	assert.LessOrEqual(t, d, time.Minute)
+}
+
+// TestBackoffIsCapped verifies delays never exceed the configured maximum
+func TestBackoffIsCapped(t *testing.T) {
+	client, err := NewClient(Config{RetryBaseDelay: time.Second, RetryMaxDelay: 5 * time.Second})
+	require.NoError(t, err)
+	for attempt := range 70 {
+		assert.LessOrEqual(t, client.backoff(attempt), 5*time.Second)
+		assert.Greater(t, client.backoff(attempt), time.Duration(0))
+	}
+}
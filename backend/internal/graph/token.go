@@ -0,0 +1,120 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpirySkew renews tokens this long before Azure AD expires them
+const tokenExpirySkew = 5 * time.Minute
+
+// token is a cached app-only access token
+type token struct {
+	value     string
+	expiresAt time.Time
+}
+
+// tokenEntry serializes fetches for one tenant without blocking others
+type tokenEntry struct {
+	mu    sync.Mutex
+	token token
+}
+
+// tokenCache holds access tokens keyed by Azure tenant and client ID
+type tokenCache struct {
+	mu      sync.Mutex
+	entries map[string]*tokenEntry
+	now     func() time.Time
+}
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{entries: make(map[string]*tokenEntry), now: time.Now}
+}
+
+func (c *tokenCache) entry(key string) *tokenEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		e = &tokenEntry{}
+		c.entries[key] = e
+	}
+	return e
+}
+
+// get returns a valid cached token or obtains a new one with fetch
+func (c *tokenCache) get(ctx context.Context, key string, fetch func(context.Context) (token, error)) (string, error) {
+	e := c.entry(key)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.token.value != "" && c.now().Before(e.token.expiresAt) {
+		return e.token.value, nil
+	}
+	t, err := fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	e.token = t
+	return t.value, nil
+}
+
+// invalidate drops a cached token, e.g. after the API rejected it
+func (c *tokenCache) invalidate(key string) {
+	e := c.entry(key)
+	e.mu.Lock()
+	e.token = token{}
+	e.mu.Unlock()
+}
+
+func (t *TenantClient) tokenKey() string {
+	return t.azureTenantID + "|" + t.creds.ClientID
+}
+
+// fetchToken runs the OAuth 2.0 client credentials grant against the tenant
+func (t *TenantClient) fetchToken(ctx context.Context) (token, error) {
+	form := url.Values{
+		"client_id":     {t.creds.ClientID},
+		"client_secret": {t.creds.ClientSecret.Reveal()},
+		"scope":         {t.client.scope},
+		"grant_type":    {"client_credentials"},
+	}
+	endpoint := t.client.loginURL + "/" + url.PathEscape(t.azureTenantID) + "/oauth2/v2.0/token"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return token{}, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.client.http.Do(req)
+	if err != nil {
+		return token{}, fmt.Errorf("failed to request Graph token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		gerr := parseError(resp)
+		gerr.token = true
+		return token{}, fmt.Errorf("failed to request Graph token: %w", gerr)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return token{}, fmt.Errorf("failed to decode Graph token: %w", err)
+	}
+	if body.AccessToken == "" {
+		return token{}, fmt.Errorf("token endpoint returned no access token")
+	}
+	lifetime := time.Duration(body.ExpiresIn)*time.Second - tokenExpirySkew
+	if lifetime < 0 {
+		lifetime = 0
+	}
+	return token{value: body.AccessToken, expiresAt: t.client.tokens.now().Add(lifetime)}, nil
+}
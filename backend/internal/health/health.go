@@ -0,0 +1,132 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Status values reported for individual dependencies and the overall service
+const (
+	StatusUp          = "up"
+	StatusDown        = "down"
+	StatusReady       = "ready"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+)
+
+// Pinger is implemented by every backend connection that can report its health
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Dependency describes a backend service checked by the Checker
+type Dependency struct {
+	// Name identifies the dependency in reports (e.g. "database")
+	Name string
+	// Pinger performs the actual connectivity check
+	Pinger Pinger
+	// Timeout bounds a single check so a hung backend cannot stall the report
+	Timeout time.Duration
+	// Critical dependencies make the service unavailable when down;
+	// non-critical ones only degrade it
+	Critical bool
+	// Optional non-critical dependencies may be down at startup even when a
+	// degraded start is not allowed, e.g. because a fallback covers them
+	Optional bool
+}
+
+// DependencyStatus is the result of checking a single dependency
+type DependencyStatus struct {
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report aggregates dependency results into an overall status
+type Report struct {
+	Status    string                      `json:"status"`
+	Timestamp time.Time                   `json:"timestamp"`
+	Services  map[string]DependencyStatus `json:"services"`
+}
+
+// Ready reports whether the service can accept traffic (fully or degraded)
+func (r Report) Ready() bool {
+	return r.Status != StatusUnavailable
+}
+
+// Checker runs connectivity checks against registered dependencies
+type Checker struct {
+	dependencies []Dependency
+}
+
+// NewChecker creates a checker for the given dependencies
+func NewChecker(dependencies ...Dependency) *Checker {
+	return &Checker{dependencies: dependencies}
+}
+
+// Check pings every dependency concurrently, each bounded by its own timeout
+func (c *Checker) Check(ctx context.Context) Report {
+	results := make(map[string]DependencyStatus, len(c.dependencies))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, dep := range c.dependencies {
+		wg.Add(1)
+		go func(dep Dependency) {
+			defer wg.Done()
+			result := checkDependency(ctx, dep)
+
+			mu.Lock()
+			results[dep.Name] = result
+			mu.Unlock()
+		}(dep)
+	}
+	wg.Wait()
+
+	return Report{
+		Status:    overallStatus(c.dependencies, results),
+		Timestamp: time.Now().UTC(),
+		Services:  results,
+	}
+}
+
+// checkDependency pings a single dependency and measures latency
+func checkDependency(ctx context.Context, dep Dependency) DependencyStatus {
+	if dep.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dep.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := dep.Pinger.Ping(ctx)
+	latency := time.Since(start)
+
+	result := DependencyStatus{
+		Status:    StatusUp,
+		Critical:  dep.Critical,
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// overallStatus derives the service status from individual dependency results
+func overallStatus(dependencies []Dependency, results map[string]DependencyStatus) string {
+	status := StatusReady
+	for _, dep := range dependencies {
+		if results[dep.Name].Status == StatusUp {
+			continue
+		}
+		if dep.Critical {
+			return StatusUnavailable
+		}
+		status = StatusDegraded
+	}
+	return status
+}
@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePinger returns a fixed error, optionally blocking until the context is done
+type fakePinger struct {
+	err   error
+	block bool
+}
+
+func (f *fakePinger) Ping(ctx context.Context) error {
+	if f.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return f.err
+}
+
+// TestCheckAllUp verifies the service is ready when every dependency responds
+func TestCheckAllUp(t *testing.T) {
+	checker := NewChecker(
+		Dependency{Name: "database", Pinger: &fakePinger{}, Critical: true},
+		Dependency{Name: "redis", Pinger: &fakePinger{}},
+	)
+
+	report := checker.Check(context.Background())
+
+	assert.Equal(t, StatusReady, report.Status)
+	assert.True(t, report.Ready())
+	assert.Equal(t, StatusUp, report.Services["database"].Status)
+	assert.Equal(t, StatusUp, report.Services["redis"].Status)
+}
+
+// TestCheckNonCriticalDown verifies a non-critical failure only degrades the service
+func TestCheckNonCriticalDown(t *testing.T) {
+	checker := NewChecker(
+		Dependency{Name: "database", Pinger: &fakePinger{}, Critical: true},
+		Dependency{Name: "meilisearch", Pinger: &fakePinger{err: errors.New("connection refused")}},
+	)
+
+	report := checker.Check(context.Background())
+
+	assert.Equal(t, StatusDegraded, report.Status)
+	assert.True(t, report.Ready(), "Degraded service should still accept traffic")
+	assert.Equal(t, StatusDown, report.Services["meilisearch"].Status)
+	assert.Equal(t, "connection refused", report.Services["meilisearch"].Error)
+}
+
+// TestCheckCriticalDown verifies a critical failure makes the service unavailable
+func TestCheckCriticalDown(t *testing.T) {
+	checker := NewChecker(
+		Dependency{Name: "database", Pinger: &fakePinger{err: errors.New("connection refused")}, Critical: true},
+		Dependency{Name: "redis", Pinger: &fakePinger{}},
+	)
+
+	report := checker.Check(context.Background())
+
+	assert.Equal(t, StatusUnavailable, report.Status)
+	assert.False(t, report.Ready())
+}
+
+// TestCheckTimeout verifies a hung dependency is cut off by its own timeout
+func TestCheckTimeout(t *testing.T) {
+	checker := NewChecker(
+		Dependency{Name: "redis", Pinger: &fakePinger{block: true}, Timeout: 20 * time.Millisecond},
+	)
+
+	start := time.Now()
+	report := checker.Check(context.Background())
+
+	assert.Less(t, time.Since(start), time.Second, "Check should not wait beyond the dependency timeout")
+	assert.Equal(t, StatusDown, report.Services["redis"].Status)
+	assert.Contains(t, report.Services["redis"].Error, "deadline exceeded")
+}
@@ -0,0 +1,138 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// BackoffPolicy controls how startup connectivity checks are retried
+type BackoffPolicy struct {
+	// InitialInterval is the base delay before the second attempt
+	InitialInterval time.Duration
+	// MaxInterval caps the delay between attempts
+	MaxInterval time.Duration
+	// Multiplier grows the delay after each failed attempt
+	Multiplier float64
+	// Deadline bounds the total time spent waiting for all dependencies
+	Deadline time.Duration
+}
+
+// delay returns the jittered wait before the given retry attempt (1-based).
+// Half the interval is fixed and half is random ("equal jitter") so replicas
+// restarting together do not hammer a recovering backend in lockstep.
+// MaxInterval caps the first delay too.
+func (p BackoffPolicy) delay(attempt int) time.Duration {
+	interval := float64(min(p.InitialInterval, p.MaxInterval))
+	for i := 1; i < attempt && p.Multiplier > 1; i++ {
+		interval *= p.Multiplier
+		if interval >= float64(p.MaxInterval) {
+			interval = float64(p.MaxInterval)
+			break
+		}
+	}
+
+	half := time.Duration(interval / 2)
+	if half <= 0 {
+		return time.Duration(interval)
+	}
+	return half + rand.N(half)
+}
+
+// StartupResult reports which dependencies were still down when startup proceeded
+type StartupResult struct {
+	Unavailable []string
+}
+
+// Degraded reports whether the service started without some non-critical dependencies
+func (r StartupResult) Degraded() bool {
+	return len(r.Unavailable) > 0
+}
+
+// WaitForDependencies pings every dependency with exponential backoff until it
+// responds or the policy deadline expires. Critical dependencies must come up;
+// non-critical ones may stay down only when allowDegraded is set or they are
+// optional.
+func WaitForDependencies(ctx context.Context, dependencies []Dependency, policy BackoffPolicy, allowDegraded bool, logger *zap.Logger) (StartupResult, error) {
+	if policy.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Deadline)
+		defer cancel()
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	failed := make(map[string]error)
+
+	for _, dep := range dependencies {
+		wg.Add(1)
+		go func(dep Dependency) {
+			defer wg.Done()
+			if err := waitForDependency(ctx, dep, policy, logger); err != nil {
+				mu.Lock()
+				failed[dep.Name] = err
+				mu.Unlock()
+			}
+		}(dep)
+	}
+	wg.Wait()
+
+	var result StartupResult
+	var fatal []string
+	for _, dep := range dependencies {
+		err, down := failed[dep.Name]
+		if !down {
+			continue
+		}
+		if dep.Critical || (!allowDegraded && !dep.Optional) {
+			fatal = append(fatal, fmt.Sprintf("%s: %v", dep.Name, err))
+			continue
+		}
+		result.Unavailable = append(result.Unavailable, dep.Name)
+	}
+
+	if len(fatal) > 0 {
+		sort.Strings(fatal)
+		return result, fmt.Errorf("dependencies unavailable after startup wait: %s", strings.Join(fatal, "; "))
+	}
+
+	return result, nil
+}
+
+// waitForDependency retries a single dependency until it responds or ctx is done
+func waitForDependency(ctx context.Context, dep Dependency, policy BackoffPolicy, logger *zap.Logger) error {
+	for attempt := 1; ; attempt++ {
+		status := checkDependency(ctx, dep)
+		if status.Status == StatusUp {
+			logger.Info("Dependency connection successful",
+				zap.String("dependency", dep.Name),
+				zap.Int("attempt", attempt),
+				zap.Float64("latency_ms", status.LatencyMs),
+			)
+			return nil
+		}
+
+		delay := policy.delay(attempt)
+		logger.Warn("Dependency not available, retrying",
+			zap.String("dependency", dep.Name),
+			zap.Bool("critical", dep.Critical),
+			zap.Int("attempt", attempt),
+			zap.Duration("retry_in", delay),
+			zap.String("error", status.Error),
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("gave up after %d attempts: %s", attempt, status.Error)
+		case <-timer.C:
+		}
+	}
+}
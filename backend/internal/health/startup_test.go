@@ -0,0 +1,134 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// flakyPinger fails until it has been called failures+1 times
+type flakyPinger struct {
+	failures int32
+	calls    atomic.Int32
+}
+
+func (f *flakyPinger) Ping(ctx context.Context) error {
+	if f.calls.Add(1) <= f.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+// testPolicy returns a fast backoff policy suitable for tests
+func testPolicy(deadline time.Duration) BackoffPolicy {
+	return BackoffPolicy{
+		InitialInterval: time.Millisecond,
+		MaxInterval:     5 * time.Millisecond,
+		Multiplier:      2,
+		Deadline:        deadline,
+	}
+}
+
+// TestWaitForDependenciesRetriesUntilUp verifies slow dependencies are retried
+func TestWaitForDependenciesRetriesUntilUp(t *testing.T) {
+	meili := &flakyPinger{failures: 3}
+	deps := []Dependency{
+		{Name: "database", Pinger: &flakyPinger{}, Critical: true},
+		{Name: "meilisearch", Pinger: meili},
+	}
+
+	result, err := WaitForDependencies(context.Background(), deps, testPolicy(time.Second), false, zap.NewNop())
+
+	require.NoError(t, err)
+	assert.False(t, result.Degraded())
+	assert.Equal(t, int32(4), meili.calls.Load(), "Meilisearch should be pinged until it responds")
+}
+
+// TestWaitForDependenciesCriticalFailure verifies a critical dependency blocks startup
+func TestWaitForDependenciesCriticalFailure(t *testing.T) {
+	deps := []Dependency{
+		{Name: "database", Pinger: &flakyPinger{failures: 1 << 30}, Critical: true},
+		{Name: "redis", Pinger: &flakyPinger{}},
+	}
+
+	_, err := WaitForDependencies(context.Background(), deps, testPolicy(30*time.Millisecond), true, zap.NewNop())
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "database")
+}
+
+// TestWaitForDependenciesDegraded verifies non-critical outages are tolerated when allowed
+func TestWaitForDependenciesDegraded(t *testing.T) {
+	deps := []Dependency{
+		{Name: "database", Pinger: &flakyPinger{}, Critical: true},
+		{Name: "meilisearch", Pinger: &flakyPinger{failures: 1 << 30}},
+	}
+
+	result, err := WaitForDependencies(context.Background(), deps, testPolicy(30*time.Millisecond), true, zap.NewNop())
+
+	require.NoError(t, err)
+	assert.True(t, result.Degraded())
+	assert.Equal(t, []string{"meilisearch"}, result.Unavailable)
+}
+
+// TestWaitForDependenciesDegradedDisallowed verifies non-critical outages fail startup by default
+func TestWaitForDependenciesDegradedDisallowed(t *testing.T) {
+	deps := []Dependency{
+		{Name: "database", Pinger: &flakyPinger{}, Critical: true},
+		{Name: "meilisearch", Pinger: &flakyPinger{failures: 1 << 30}},
+	}
+
+	_, err := WaitForDependencies(context.Background(), deps, testPolicy(30*time.Millisecond), false, zap.NewNop())
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "meilisearch")
+}
+
+// TestWaitForDependenciesOptional verifies optional dependencies may be down
+// even when degraded startup is not allowed
+func TestWaitForDependenciesOptional(t *testing.T) {
+	deps := []Dependency{
+		{Name: "database", Pinger: &flakyPinger{}, Critical: true},
+		{Name: "meilisearch", Pinger: &flakyPinger{failures: 1 << 30}, Optional: true},
+	}
+
+	result, err := WaitForDependencies(context.Background(), deps, testPolicy(30*time.Millisecond), false, zap.NewNop())
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"meilisearch"}, result.Unavailable)
+}
+
+// TestBackoffPolicyDelay verifies delays grow exponentially, are capped and jittered
+func TestBackoffPolicyDelay(t *testing.T) {
+	policy := BackoffPolicy{
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     time.Second,
+		Multiplier:      2,
+	}
+
+	for i := 0; i < 20; i++ {
+		first := policy.delay(1)
+		assert.GreaterOrEqual(t, first, 50*time.Millisecond)
+		assert.Less(t, first, 100*time.Millisecond)
+
+		third := policy.delay(3)
+		assert.GreaterOrEqual(t, third, 200*time.Millisecond)
+		assert.Less(t, third, 400*time.Millisecond)
+
+		capped := policy.delay(10)
+		assert.GreaterOrEqual(t, capped, 500*time.Millisecond)
+		assert.Less(t, capped, time.Second)
+	}
+
+	// A maximum below the initial interval caps the first delay too
+	policy.MaxInterval = 40 * time.Millisecond
+	first := policy.delay(1)
+	assert.GreaterOrEqual(t, first, 20*time.Millisecond)
+	assert.Less(t, first, 40*time.Millisecond)
+}
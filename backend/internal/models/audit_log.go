@@ -0,0 +1,16 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditLog is an immutable audit trail entry for compliance
+type AuditLog struct {
+	ID        string          `json:"id"`
+	UserID    *string         `json:"userId,omitempty"`
+	Action    string          `json:"action"`
+	IPAddress *string         `json:"ipAddress,omitempty"`
+	Details   json.RawMessage `json:"details,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// redacted replaces secret values wherever they would be printed or serialized
+const redacted = "[REDACTED]"
+
+// Secret is a sensitive string that never prints or serializes in plaintext.
+// It can still be decoded from JSON request bodies; use Reveal to read it.
+type Secret string
+
+// String implements fmt.Stringer without exposing the value
+func (s Secret) String() string {
+	return redacted
+}
+
+// GoString implements fmt.GoStringer without exposing the value
+func (s Secret) GoString() string {
+	return redacted
+}
+
+// MarshalJSON always encodes the redaction marker
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(redacted)
+}
+
+// Reveal returns the plaintext value
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// AzureCredentials are the M365 app registration credentials of a tenant
+type AzureCredentials struct {
+	ClientID     string `json:"clientId"`
+	ClientSecret Secret `json:"clientSecret"`
+}
+
+// AzureCredentialsInfo is the API-safe view of stored credentials
+type AzureCredentialsInfo struct {
+	Configured bool   `json:"configured"`
+	ClientID   string `json:"clientId,omitempty"`
+	KeyVersion int    `json:"keyVersion,omitempty"`
+}
+
+// TenantDataKey is a per-tenant data encryption key, wrapped by a master key
+type TenantDataKey struct {
+	ID          string    `json:"id"`
+	TenantID    string    `json:"tenantId"`
+	Version     int       `json:"version"`
+	MasterKeyID string    `json:"masterKeyId"`
+	WrappedKey  []byte    `json:"-"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSecretNeverSerialized verifies secrets are redacted in JSON and formatted output
+func TestSecretNeverSerialized(t *testing.T) {
+	creds := AzureCredentials{ClientID: "app-id", ClientSecret: "super-secret"}
+
+	encoded, err := json.Marshal(creds)
+	require.NoError(t, err)
+	assert.NotContains(t, string(encoded), "super-secret")
+	assert.Contains(t, string(encoded), "app-id")
+
+	assert.NotContains(t, fmt.Sprintf("%v %+v %#v %s", creds, creds, creds, creds.ClientSecret), "super-secret")
+	assert.Equal(t, "super-secret", creds.ClientSecret.Reveal())
+}
+
+// TestSecretDecodesFromJSON verifies secrets can still be submitted in request bodies
+func TestSecretDecodesFromJSON(t *testing.T) {
+	var creds AzureCredentials
+	require.NoError(t, json.Unmarshal([]byte(`{"clientId":"a","clientSecret":"s3cret"}`), &creds))
+	assert.Equal(t, "s3cret", creds.ClientSecret.Reveal())
+}
@@ -0,0 +1,102 @@
+package models
+
+import "time"
+
+// Email represents an archived email message with metadata
+type Email struct {
+	ID             string    `json:"id"`
+	MailboxID      string    `json:"mailboxId"`
+	MessageID      string    `json:"messageId"`
+	Subject        *string   `json:"subject,omitempty"`
+	Sender         *string   `json:"sender,omitempty"`
+	Recipients     []string  `json:"recipients"`
+	SentAt         time.Time `json:"sentAt"`
+	BodyText       *string   `json:"bodyText,omitempty"`
+	BodyHTML       *string   `json:"bodyHtml,omitempty"`
+	HasAttachments bool      `json:"hasAttachments"`
+	SizeBytes      int       `json:"sizeBytes"`
+	FilePath       string    `json:"filePath"`
+	Categories     []string  `json:"categories"` // Outlook categories, searched as labels
+	// InternetMessageID, InReplyTo and References are the threading headers,
+	// without angle brackets; ConversationID is the Graph conversationId
+	InternetMessageID *string  `json:"internetMessageId,omitempty"`
+	InReplyTo         *string  `json:"inReplyTo,omitempty"`
+	References        []string `json:"references"`
+	ConversationID    *string  `json:"conversationId,omitempty"`
+	ThreadID          *string  `json:"threadId,omitempty"` // Unset until the email is threaded
+	// BlobID is the stored MIME, shared with copies of the message in other
+	// mailboxes; unset for emails archived before single-instance storage
+	BlobID     *string    `json:"blobId,omitempty"`
+	FolderID   *string    `json:"folderId,omitempty"` // mailbox_folders row the copy was last seen in
+	IsRead     bool       `json:"isRead"`
+	FlagStatus string     `json:"flagStatus"`
+	IndexedAt  *time.Time `json:"indexedAt,omitempty"`
+	DeletedAt  *time.Time `json:"deletedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"` // Last change of an indexed column
+}
+
+// Follow-up flag statuses of an email, as reported by Graph
+const (
+	FlagStatusNotFlagged = "notFlagged"
+	FlagStatusFlagged    = "flagged"
+	FlagStatusComplete   = "complete"
+)
+
+// MessageBlob is the stored MIME of a message, kept once per tenant and
+// content hash however many mailboxes hold the message
+type MessageBlob struct {
+	ID         string    `json:"id"`
+	TenantID   string    `json:"tenantId"`
+	SHA256Hash string    `json:"sha256Hash"`
+	FilePath   string    `json:"filePath"`
+	SizeBytes  int64     `json:"sizeBytes"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Thread is a conversation of a tenant, built from the threading headers
+// and Graph conversation IDs of its emails across mailboxes
+type Thread struct {
+	ID       string  `json:"id"`
+	TenantID string  `json:"tenantId"`
+	Subject  *string `json:"subject,omitempty"` // Subject of the earliest email
+	// MessageCount counts distinct messages; copies archived in several
+	// mailboxes count once
+	MessageCount int        `json:"messageCount"`
+	FirstSentAt  *time.Time `json:"firstSentAt,omitempty"`
+	LastSentAt   *time.Time `json:"lastSentAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+// Attachment text extraction statuses
+const (
+	AttachmentTextPending     = "PENDING"
+	AttachmentTextExtracted   = "EXTRACTED"
+	AttachmentTextUnsupported = "UNSUPPORTED" // no parser for the format
+	AttachmentTextTooLarge    = "TOO_LARGE"
+	AttachmentTextFailed      = "FAILED" // corrupt, encrypted or timed out; see TextError
+)
+
+// Attachment represents an email attachment, deduplicated by content hash
+type Attachment struct {
+	ID              string     `json:"id"`
+	EmailID         string     `json:"emailId"`
+	Filename        string     `json:"filename"`
+	ContentType     *string    `json:"contentType,omitempty"`
+	SizeBytes       int        `json:"sizeBytes"`
+	SHA256Hash      string     `json:"sha256Hash"`
+	FilePath        string     `json:"filePath"`
+	TextStatus      string     `json:"textStatus"`
+	ExtractedText   *string    `json:"extractedText,omitempty"`
+	TextError       *string    `json:"textError,omitempty"`
+	TextExtractedAt *time.Time `json:"textExtractedAt,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+}
+
+// AttachmentText is the outcome of extracting an attachment's text
+type AttachmentText struct {
+	Status string
+	Text   *string
+	Error  *string
+}
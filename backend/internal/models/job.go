@@ -0,0 +1,74 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Job types
+const (
+	JobTypeSyncMailbox      = "SYNC_MAILBOX"
+	JobTypeSyncTenant       = "SYNC_TENANT"
+	JobTypeSyncAll          = "SYNC_ALL"
+	JobTypeExport           = "EXPORT"
+	JobTypeRetentionCleanup = "RETENTION_CLEANUP"
+)
+
+// Job statuses
+const (
+	JobStatusQueued    = "QUEUED"
+	JobStatusRunning   = "RUNNING"
+	JobStatusRetrying  = "RETRYING" // failed, waiting for its next attempt
+	JobStatusCompleted = "COMPLETED"
+	JobStatusFailed    = "FAILED" // failed permanently (dead letter)
+	JobStatusCancelled = "CANCELLED"
+)
+
+// Job attempt statuses
+const (
+	JobAttemptRunning     = "RUNNING"
+	JobAttemptCompleted   = "COMPLETED"
+	JobAttemptFailed      = "FAILED"
+	JobAttemptCancelled   = "CANCELLED"
+	JobAttemptInterrupted = "INTERRUPTED" // worker shut down or crashed
+)
+
+// Job tracks background job execution (sync, export, retention cleanup)
+type Job struct {
+	ID           string  `json:"id"`
+	Type         string  `json:"type"`
+	Status       string  `json:"status"`
+	TenantID     *string `json:"tenantId,omitempty"`
+	MailboxID    *string `json:"mailboxId,omitempty"`
+	UserID       *string `json:"userId,omitempty"`
+	Progress     int     `json:"progress"`
+	ErrorMessage *string `json:"errorMessage,omitempty"`
+	// Metadata is the job's input; Result is written by its handler
+	Metadata          json.RawMessage `json:"metadata,omitempty"`
+	Result            json.RawMessage `json:"result,omitempty"`
+	Attempts          int             `json:"attempts"`
+	MaxAttempts       int             `json:"maxAttempts"`
+	NextAttemptAt     *time.Time      `json:"nextAttemptAt,omitempty"`
+	CancelRequestedAt *time.Time      `json:"cancelRequestedAt,omitempty"`
+	RetryOf           *string         `json:"retryOf,omitempty"` // job this one was cloned from
+	StartedAt         *time.Time      `json:"startedAt,omitempty"`
+	CompletedAt       *time.Time      `json:"completedAt,omitempty"`
+	CreatedAt         time.Time       `json:"createdAt"`
+}
+
+// Finished reports whether the job reached a terminal status
+func (j *Job) Finished() bool {
+	return j.Status == JobStatusCompleted || j.Status == JobStatusFailed || j.Status == JobStatusCancelled
+}
+
+// JobAttempt records one run of a job by a worker
+type JobAttempt struct {
+	ID           string     `json:"id"`
+	JobID        string     `json:"jobId"`
+	Attempt      int        `json:"attempt"`
+	Status       string     `json:"status"`
+	Worker       *string    `json:"worker,omitempty"`
+	ErrorMessage *string    `json:"errorMessage,omitempty"`
+	StartedAt    time.Time  `json:"startedAt"`
+	FinishedAt   *time.Time `json:"finishedAt,omitempty"`
+}
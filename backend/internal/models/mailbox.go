@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Mailbox types
+const (
+	MailboxTypeUser      = "USER"
+	MailboxTypeShared    = "SHARED"
+	MailboxTypeRoom      = "ROOM"
+	MailboxTypeEquipment = "EQUIPMENT"
+)
+
+// Mailbox represents an M365 mailbox configured for backup
+type Mailbox struct {
+	ID             string     `json:"id"`
+	TenantID       string     `json:"tenantId"`
+	EmailAddress   string     `json:"emailAddress"`
+	DisplayName    *string    `json:"displayName,omitempty"`
+	MailboxType    string     `json:"mailboxType"`
+	SyncEnabled    bool       `json:"syncEnabled"`
+	LastSyncAt     *time.Time `json:"lastSyncAt,omitempty"`
+	LastDeltaToken *string    `json:"-"` // Superseded by mailbox_folders.delta_link; not written
+	EmailCount     int        `json:"emailCount"`
+	// StorageBytes counts physical bytes: shared content counts towards the
+	// mailbox that stored it first. LogicalStorageBytes counts every copy.
+	StorageBytes        int64     `json:"storageBytes"`
+	LogicalStorageBytes int64     `json:"logicalStorageBytes"`
+	SyncSchedule        *string   `json:"syncSchedule,omitempty"` // Cron override of the tenant or global sync schedule
+	SyncTimezone        *string   `json:"syncTimezone,omitempty"`
+	CreatedAt           time.Time `json:"createdAt"`
+}
+
+// MailboxFolder tracks the Graph delta sync state of one mail folder
+type MailboxFolder struct {
+	ID          string     `json:"id"`
+	MailboxID   string     `json:"mailboxId"`
+	FolderID    string     `json:"folderId"`
+	DisplayName *string    `json:"displayName,omitempty"`
+	DeltaLink   *string    `json:"-"`
+	EmailCount  int        `json:"emailCount"`
+	LastSyncAt  *time.Time `json:"lastSyncAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// ScheduleKeyGlobal identifies the global sync schedule (settings.sync_schedule)
+const ScheduleKeyGlobal = "global"
+
+// ScheduleKeyTenant identifies a tenant's sync schedule override
+func ScheduleKeyTenant(tenantID string) string {
+	return "tenant:" + tenantID
+}
+
+// ScheduleKeyMailbox identifies a mailbox's sync schedule override
+func ScheduleKeyMailbox(mailboxID string) string {
+	return "mailbox:" + mailboxID
+}
+
+// Schedule run statuses
+const (
+	ScheduleRunFired    = "FIRED"     // job enqueued on time
+	ScheduleRunMissed   = "MISSED"    // tick passed while no scheduler was running
+	ScheduleRunCaughtUp = "CAUGHT_UP" // missed tick whose job was enqueued late
+)
+
+// ScheduleRun records how the scheduler handled one tick of a schedule
+type ScheduleRun struct {
+	ID          string    `json:"id"`
+	ScheduleKey string    `json:"scheduleKey"`
+	ScheduledAt time.Time `json:"scheduledAt"`
+	Status      string    `json:"status"`
+	JobID       *string   `json:"jobId,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Well-known global setting keys seeded by the initial migration
+const (
+	SettingGlobalRetentionPolicyDays = "global_retention_policy_days"
+	SettingSMTPConfig                = "smtp_config"
+	SettingNotificationChannels      = "notification_channels"
+	SettingSchedulerEnabled          = "scheduler_enabled"
+	SettingSyncSchedule              = "sync_schedule"
+	SettingSyncTimezone              = "sync_timezone"
+)
+
+// SettingSearchSchemaVersion records the schema version of the live search
+// index; it is written by the search index manager rather than seeded
+const SettingSearchSchemaVersion = "search_schema_version"
+
+// Setting is a global configuration key-value entry
+type Setting struct {
+	Key       string          `json:"key"`
+	Value     json.RawMessage `json:"value"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
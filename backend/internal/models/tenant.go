@@ -0,0 +1,22 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Tenant represents an MSP customer with M365 tenant configuration
+type Tenant struct {
+	ID                  string          `json:"id"`
+	Name                string          `json:"name"`
+	AzureTenantID       string          `json:"azureTenantId"`
+	AzureAppCredentials string          `json:"-"` // Encrypted JSON blob, never serialized
+	RetentionPolicyDays int             `json:"retentionPolicyDays"`
+	LegalHold           bool            `json:"legalHold"`
+	WhitelabelConfig    json.RawMessage `json:"whitelabelConfig,omitempty"`
+	StorageBytes        int64           `json:"storageBytes"`           // Physical bytes after deduplication
+	LogicalStorageBytes int64           `json:"logicalStorageBytes"`    // Bytes of every archived copy
+	SyncSchedule        *string         `json:"syncSchedule,omitempty"` // Cron override of the global sync schedule
+	SyncTimezone        *string         `json:"syncTimezone,omitempty"`
+	CreatedAt           time.Time       `json:"createdAt"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// User roles
+const (
+	RoleMSPAdmin    = "MSP_ADMIN"
+	RoleTenantAdmin = "TENANT_ADMIN"
+	RoleUser        = "USER"
+)
+
+// User represents a system user with role-based access
+type User struct {
+	ID           string    `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"-"`
+	DisplayName  string    `json:"displayName"`
+	Role         string    `json:"role"`
+	TenantID     *string   `json:"tenantId,omitempty"` // Null for MSP Admins
+	MFASecret    *string   `json:"-"`
+	MFAEnabled   bool      `json:"mfaEnabled"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
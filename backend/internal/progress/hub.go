@@ -0,0 +1,210 @@
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database"
+	"ironarchive/internal/models"
+)
+
+// subscriptionBuffer is how many events a slow subscriber may lag behind
+// before further events are dropped for it
+const subscriptionBuffer = 64
+
+// Filter selects the events a caller may see and asked for
+type Filter struct {
+	// Session limits events by role: MSP_ADMIN sees all jobs, TENANT_ADMIN
+	// the jobs of its tenant and USER the jobs it started
+	Session database.Session
+	// JobID and TenantID optionally narrow the events further
+	JobID    string
+	TenantID string
+}
+
+// Match reports whether an event passes the filter
+func (f Filter) Match(e Event) bool {
+	if f.JobID != "" && e.JobID != f.JobID {
+		return false
+	}
+	if f.TenantID != "" && (e.TenantID == nil || *e.TenantID != f.TenantID) {
+		return false
+	}
+	switch f.Session.Role {
+	case models.RoleMSPAdmin:
+		return true
+	case models.RoleTenantAdmin:
+		return e.TenantID != nil && *e.TenantID == f.Session.TenantID
+	case models.RoleUser:
+		return e.UserID != nil && *e.UserID == f.Session.UserID &&
+			e.TenantID != nil && *e.TenantID == f.Session.TenantID
+	default:
+		return false
+	}
+}
+
+// Subscription receives the live events matching its filter
+type Subscription struct {
+	hub    *Hub
+	filter Filter
+	events chan Event
+	once   sync.Once
+}
+
+// Events returns the channel of matching events; it is closed when the
+// subscription is closed or the hub stops
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+}
+
+// Hub fans the progress channel out to the subscribers of this process
+type Hub struct {
+	redis  *redis.Client
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	subs    map[*Subscription]struct{}
+	stopped bool
+}
+
+// NewHub creates a hub; Run delivers events to its subscribers
+func NewHub(conn *database.RedisConnection, logger *zap.Logger) *Hub {
+	return &Hub{redis: conn.Client, logger: logger, subs: make(map[*Subscription]struct{})}
+}
+
+// Run delivers published events to subscribers until ctx is done, then
+// closes every subscription
+func (h *Hub) Run(ctx context.Context) {
+	defer h.stop()
+
+	sub := h.redis.Subscribe(ctx, channel)
+	defer sub.Close()
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+				h.logger.Warn("Dropping malformed progress event", zap.Error(err))
+				continue
+			}
+			h.deliver(e)
+		}
+	}
+}
+
+// Subscribe registers a subscriber for live events. Subscribing after the
+// hub stopped returns a closed subscription.
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	s := &Subscription{hub: h, filter: filter, events: make(chan Event, subscriptionBuffer)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopped {
+		close(s.events)
+		s.once.Do(func() {})
+		return s
+	}
+	h.subs[s] = struct{}{}
+	return s
+}
+
+// Snapshot returns the last event of each recently active job matching the
+// filter, oldest first
+func (h *Hub) Snapshot(ctx context.Context, filter Filter) ([]Event, error) {
+	var ids []string
+	if filter.JobID != "" {
+		ids = []string{filter.JobID}
+	} else {
+		var err error
+		since := strconv.FormatInt(time.Now().Add(-Retention).UnixMilli(), 10)
+		ids, err = h.redis.ZRangeByScore(ctx, indexKey, &redis.ZRangeBy{Min: since, Max: "+inf"}).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list job progress: %w", err)
+		}
+	}
+	if len(ids) == 0 {
+		return []Event{}, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = stateKey(id)
+	}
+	values, err := h.redis.MGet(ctx, keys...).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to load job progress: %w", err)
+	}
+	events := []Event{}
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			// Expired since it was indexed
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			continue
+		}
+		if filter.Match(e) {
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].UpdatedAt.Before(events[j].UpdatedAt) })
+	return events, nil
+}
+
+// deliver hands an event to every matching subscriber without blocking
+func (h *Hub) deliver(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			// The client reads slower than jobs progress; a later event supersedes this one
+		}
+	}
+}
+
+// remove closes a subscription once
+func (h *Hub) remove(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s.once.Do(func() {
+		delete(h.subs, s)
+		close(s.events)
+	})
+}
+
+// stop closes all subscriptions and rejects new ones
+func (h *Hub) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stopped = true
+	for s := range h.subs {
+		s.once.Do(func() { close(s.events) })
+		delete(h.subs, s)
+	}
+}
@@ -0,0 +1,212 @@
+// Package progress publishes live job progress over Redis pub/sub and keeps
+// the last known state of each job for clients that connect later.
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database"
+	"ironarchive/internal/models"
+)
+
+const (
+	keyPrefix = "ironarchive:progress:"
+	// channel carries every progress event as JSON
+	channel = keyPrefix + "events"
+	// indexKey is a sorted set of job IDs scored by the Unix milliseconds of
+	// their last event; it bounds the replay to recently active jobs
+	indexKey = keyPrefix + "jobs"
+)
+
+const (
+	// Retention is how long the last event of a job is replayed
+	Retention = time.Hour
+	// Interval is the least time between two published updates of a job
+	Interval = time.Second
+)
+
+// Event is the progress of one job at a point in time
+type Event struct {
+	JobID     string  `json:"jobId"`
+	Type      string  `json:"type"`
+	Status    string  `json:"status"`
+	TenantID  *string `json:"tenantId,omitempty"`
+	MailboxID *string `json:"mailboxId,omitempty"`
+	UserID    *string `json:"userId,omitempty"`
+	// Progress is the completion percentage (0-100)
+	Progress int `json:"progress"`
+	// Done and Total count the job's units of work, e.g. mailboxes
+	Done  int `json:"done"`
+	Total int `json:"total"`
+	// Items and Bytes count what the job processed so far, e.g. emails
+	Items int   `json:"items"`
+	Bytes int64 `json:"bytes"`
+	// ETA extrapolates the time taken so far; nil until progress is made
+	ETA       *time.Time `json:"eta,omitempty"`
+	Message   *string    `json:"message,omitempty"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// FromJob returns an event with a job's identity, status and progress
+func FromJob(job *models.Job) Event {
+	return Event{
+		JobID:     job.ID,
+		Type:      job.Type,
+		Status:    job.Status,
+		TenantID:  job.TenantID,
+		MailboxID: job.MailboxID,
+		UserID:    job.UserID,
+		Progress:  job.Progress,
+		Message:   job.ErrorMessage,
+	}
+}
+
+// stateKey holds the last event of a job
+func stateKey(jobID string) string {
+	return keyPrefix + "job:" + jobID
+}
+
+// Publisher stores and broadcasts progress events. A nil Publisher discards them.
+type Publisher struct {
+	redis  *redis.Client
+	logger *zap.Logger
+}
+
+// NewPublisher creates a publisher
+func NewPublisher(conn *database.RedisConnection, logger *zap.Logger) *Publisher {
+	return &Publisher{redis: conn.Client, logger: logger}
+}
+
+// Publish records an event as its job's last state and broadcasts it
+func (p *Publisher) Publish(ctx context.Context, e Event) error {
+	if p == nil {
+		return nil
+	}
+	if e.UpdatedAt.IsZero() {
+		e.UpdatedAt = time.Now().UTC()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode progress event: %w", err)
+	}
+
+	now := time.Now()
+	_, err = p.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, stateKey(e.JobID), data, Retention)
+		pipe.ZAdd(ctx, indexKey, redis.Z{Score: float64(now.UnixMilli()), Member: e.JobID})
+		pipe.ZRemRangeByScore(ctx, indexKey, "-inf", strconv.FormatInt(now.Add(-Retention).UnixMilli(), 10))
+		pipe.Publish(ctx, channel, data)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish progress event: %w", err)
+	}
+	return nil
+}
+
+// PublishStatus broadcasts a job's new status, keeping the counts of its
+// last event
+func (p *Publisher) PublishStatus(ctx context.Context, job *models.Job) error {
+	if p == nil {
+		return nil
+	}
+	e := FromJob(job)
+	last, err := p.last(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+	if last != nil {
+		e.Done, e.Total, e.Items, e.Bytes = last.Done, last.Total, last.Items, last.Bytes
+		if job.Status == models.JobStatusRunning {
+			e.ETA = last.ETA
+		}
+	}
+	return p.Publish(ctx, e)
+}
+
+// last returns the stored last event of a job, or nil
+func (p *Publisher) last(ctx context.Context, jobID string) (*Event, error) {
+	data, err := p.redis.Get(ctx, stateKey(jobID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load job progress: %w", err)
+	}
+	var e Event
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("failed to decode job progress: %w", err)
+	}
+	return &e, nil
+}
+
+// Tracker publishes the progress of one running job, at most once per interval
+type Tracker struct {
+	publisher *Publisher
+	interval  time.Duration
+	started   time.Time
+
+	mu    sync.Mutex
+	event Event
+	sent  time.Time
+}
+
+// Track starts tracking a running job. Updates within Interval of the last
+// published one are only published by the next update or Flush.
+func (p *Publisher) Track(job *models.Job) *Tracker {
+	event := FromJob(job)
+	event.Status = models.JobStatusRunning
+	return &Tracker{publisher: p, interval: Interval, started: time.Now(), event: event}
+}
+
+// Update changes the tracked event and publishes it unless the last publish
+// is too recent. The ETA is derived from the progress percentage.
+func (t *Tracker) Update(ctx context.Context, update func(e *Event)) {
+	t.mu.Lock()
+	update(&t.event)
+	now := time.Now()
+	t.event.ETA = nil
+	if t.event.Progress > 0 && t.event.Progress < 100 {
+		elapsed := now.Sub(t.started)
+		eta := now.Add(elapsed*100/time.Duration(t.event.Progress) - elapsed).UTC()
+		t.event.ETA = &eta
+	}
+	if now.Sub(t.sent) < t.interval {
+		t.mu.Unlock()
+		return
+	}
+	t.sent = now
+	event := t.event
+	t.mu.Unlock()
+	t.publish(ctx, event)
+}
+
+// Flush publishes the tracked event regardless of the interval
+func (t *Tracker) Flush(ctx context.Context) {
+	t.mu.Lock()
+	t.sent = time.Now()
+	event := t.event
+	t.mu.Unlock()
+	t.publish(ctx, event)
+}
+
+// publish sends an event; failures are only logged
+func (t *Tracker) publish(ctx context.Context, e Event) {
+	if t.publisher == nil {
+		return
+	}
+	e.UpdatedAt = time.Now().UTC()
+	// Progress is best effort and must not fail the job
+	if err := t.publisher.Publish(context.WithoutCancel(ctx), e); err != nil {
+		t.publisher.logger.Warn("Failed to publish job progress", zap.String("job_id", e.JobID), zap.Error(err))
+	}
+}
@@ -0,0 +1,180 @@
+package progress
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database"
+	"ironarchive/internal/models"
+)
+
+const (
+	tenantA = "tenant-a"
+	tenantB = "tenant-b"
+	userA   = "user-a"
+)
+
+// newTestHub creates a publisher and a running hub on miniredis
+func newTestHub(t *testing.T) (*Publisher, *Hub) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	conn, err := database.NewRedisConnection("redis://"+mr.Addr(), zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	hub := NewHub(conn, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return NewPublisher(conn, zap.NewNop()), hub
+}
+
+// job returns a running job of a tenant, started by a user if given
+func job(id, tenantID string, userID *string) *models.Job {
+	return &models.Job{ID: id, Type: models.JobTypeSyncTenant, Status: models.JobStatusRunning, TenantID: &tenantID, UserID: userID}
+}
+
+// receive waits for the next event of a subscription
+func receive(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	select {
+	case e := <-sub.Events():
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no progress event received")
+		return Event{}
+	}
+}
+
+// TestFilterMatch verifies role-based visibility of job events
+func TestFilterMatch(t *testing.T) {
+	user := userA
+	own := FromJob(job("j1", tenantA, &user))
+	other := FromJob(job("j2", tenantA, nil))
+	foreign := FromJob(job("j3", tenantB, nil))
+	global := Event{JobID: "j4", Type: models.JobTypeSyncAll}
+
+	cases := []struct {
+		name    string
+		filter  Filter
+		visible []Event
+	}{
+		{"msp admin", Filter{Session: database.Session{Role: models.RoleMSPAdmin}}, []Event{own, other, foreign, global}},
+		{"msp admin narrowed to tenant", Filter{Session: database.Session{Role: models.RoleMSPAdmin}, TenantID: tenantB}, []Event{foreign}},
+		{"tenant admin", Filter{Session: database.Session{Role: models.RoleTenantAdmin, TenantID: tenantA}}, []Event{own, other}},
+		{"user", Filter{Session: database.Session{Role: models.RoleUser, TenantID: tenantA, UserID: userA}}, []Event{own}},
+		{"single job", Filter{Session: database.Session{Role: models.RoleTenantAdmin, TenantID: tenantA}, JobID: "j2"}, []Event{other}},
+		{"no role", Filter{}, nil},
+	}
+	for _, tc := range cases {
+		var visible []Event
+		for _, e := range []Event{own, other, foreign, global} {
+			if tc.filter.Match(e) {
+				visible = append(visible, e)
+			}
+		}
+		assert.Equal(t, tc.visible, visible, tc.name)
+	}
+}
+
+// TestHubDeliversAndReplays verifies live delivery by tenant and replay of the last state
+func TestHubDeliversAndReplays(t *testing.T) {
+	publisher, hub := newTestHub(t)
+	ctx := context.Background()
+	admin := Filter{Session: database.Session{Role: models.RoleTenantAdmin, TenantID: tenantA}}
+
+	sub := hub.Subscribe(admin)
+	defer sub.Close()
+	// The subscription may attach after the first publishes; wait until events flow
+	require.Eventually(t, func() bool {
+		require.NoError(t, publisher.Publish(ctx, Event{JobID: "ping", TenantID: ptr(tenantA)}))
+		select {
+		case <-sub.Events():
+			return true
+		case <-time.After(20 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, time.Millisecond)
+	for len(sub.Events()) > 0 {
+		<-sub.Events()
+	}
+
+	require.NoError(t, publisher.Publish(ctx, Event{JobID: "b1", TenantID: ptr(tenantB), Progress: 10}))
+	require.NoError(t, publisher.Publish(ctx, Event{JobID: "a1", TenantID: ptr(tenantA), Progress: 20, Items: 7}))
+	e := receive(t, sub)
+	assert.Equal(t, "a1", e.JobID)
+	assert.Equal(t, 7, e.Items)
+
+	// Status changes keep the counts of the last event
+	finished := job("a1", tenantA, nil)
+	finished.Status, finished.Progress = models.JobStatusCompleted, 100
+	require.NoError(t, publisher.PublishStatus(ctx, finished))
+	e = receive(t, sub)
+	assert.Equal(t, models.JobStatusCompleted, e.Status)
+	assert.Equal(t, 7, e.Items)
+
+	replay, err := hub.Snapshot(ctx, admin)
+	require.NoError(t, err)
+	ids := make([]string, len(replay))
+	for i, e := range replay {
+		ids[i] = e.JobID
+	}
+	assert.ElementsMatch(t, []string{"ping", "a1"}, ids)
+	one, err := hub.Snapshot(ctx, Filter{Session: admin.Session, JobID: "a1"})
+	require.NoError(t, err)
+	require.Len(t, one, 1)
+	assert.Equal(t, models.JobStatusCompleted, one[0].Status)
+}
+
+// TestTrackerThrottles verifies a tracker publishes at most once per interval until flushed
+func TestTrackerThrottles(t *testing.T) {
+	publisher, hub := newTestHub(t)
+	ctx := context.Background()
+
+	tracker := publisher.Track(job("j1", tenantA, nil))
+	tracker.interval = time.Hour
+	tracker.Update(ctx, func(e *Event) { e.Total = 4 })
+	tracker.Update(ctx, func(e *Event) { e.Progress, e.Done = 50, 2 })
+
+	replay, err := hub.Snapshot(ctx, Filter{Session: database.Session{Role: models.RoleMSPAdmin}})
+	require.NoError(t, err)
+	require.Len(t, replay, 1)
+	assert.Equal(t, models.JobStatusRunning, replay[0].Status)
+	assert.Equal(t, 4, replay[0].Total)
+	assert.Zero(t, replay[0].Done)
+
+	tracker.Flush(ctx)
+	replay, err = hub.Snapshot(ctx, Filter{Session: database.Session{Role: models.RoleMSPAdmin}})
+	require.NoError(t, err)
+	require.Len(t, replay, 1)
+	assert.Equal(t, 2, replay[0].Done)
+	require.NotNil(t, replay[0].ETA)
+	assert.True(t, replay[0].ETA.After(replay[0].UpdatedAt.Add(-time.Second)))
+}
+
+// TestNilPublisherDiscards verifies jobs run without a publisher
+func TestNilPublisherDiscards(t *testing.T) {
+	var publisher *Publisher
+	assert.NoError(t, publisher.Publish(context.Background(), Event{JobID: "j1"}))
+	assert.NoError(t, publisher.PublishStatus(context.Background(), job("j1", tenantA, nil)))
+	tracker := publisher.Track(job("j1", tenantA, nil))
+	tracker.Update(context.Background(), func(e *Event) { e.Progress = 10 })
+	tracker.Flush(context.Background())
+}
+
+func ptr(s string) *string {
+	return &s
+}
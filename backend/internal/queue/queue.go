@@ -0,0 +1,842 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+	"ironarchive/internal/progress"
+)
+
+// Handler runs a job. Handlers record status and progress through the job
+// repository; the queue completes or fails jobs a handler left unfinished.
+// A cancelled job's context is cancelled with ErrCancelled as its cause.
+type Handler interface {
+	Process(ctx context.Context, job *models.Job) error
+}
+
+// Retrier is implemented by handlers whose errors are not all worth another
+// attempt; errors of other handlers are always retried
+type Retrier interface {
+	Retryable(err error) bool
+}
+
+var (
+	// ErrCancelled is the context cause of a job cancelled while running
+	ErrCancelled = errors.New("job cancelled")
+	// ErrJobFinished is returned when cancelling a job that already finished
+	ErrJobFinished = errors.New("job already finished")
+	// ErrNotRetryable is returned when retrying a job that did not fail or was not cancelled
+	ErrNotRetryable = errors.New("only failed or cancelled jobs can be retried")
+)
+
+// HandlerFunc adapts a function to Handler
+type HandlerFunc func(ctx context.Context, job *models.Job) error
+
+// Process calls f
+func (f HandlerFunc) Process(ctx context.Context, job *models.Job) error {
+	return f(ctx, job)
+}
+
+// Config tunes delivery of queued jobs
+type Config struct {
+	// VisibilityTimeout is how long a job may go without a heartbeat from its
+	// worker before another worker reclaims it
+	VisibilityTimeout time.Duration
+	// MaxDeliveries bounds how often a job is handed out after worker crashes
+	MaxDeliveries int
+	// MaxAttempts is how often an enqueued job may fail before it is
+	// dead-lettered, unless the job sets its own
+	MaxAttempts int
+	// RetryBaseDelay is the wait before the second attempt; it doubles per
+	// failed attempt up to RetryMaxDelay
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// Progress, if set, receives every status change of a job
+	Progress *progress.Publisher
+	// Block is how long a worker waits on Redis for new jobs per poll
+	Block time.Duration
+	// Consumer identifies this process in the consumer groups
+	Consumer string
+}
+
+// Defaults for zero Config fields
+const (
+	DefaultVisibilityTimeout = 5 * time.Minute
+	DefaultMaxDeliveries     = 3
+	DefaultMaxAttempts       = 3
+	DefaultRetryBaseDelay    = 30 * time.Second
+	DefaultRetryMaxDelay     = 10 * time.Minute
+	DefaultBlock             = 5 * time.Second
+)
+
+const (
+	keyPrefix = "ironarchive:jobs:"
+	group     = "workers"
+	// delayedKey is a sorted set of RETRYING job IDs scored by the Unix
+	// milliseconds of their next attempt
+	delayedKey = keyPrefix + "delayed"
+	// cancelChannel broadcasts the IDs of running jobs to cancel
+	cancelChannel = keyPrefix + "cancel"
+	// promoteInterval is how often due retries are queued
+	promoteInterval = time.Second
+	// promoteBatch bounds the retries queued per promotion
+	promoteBatch = 100
+	// errorDelay is the pause after a failed Redis call before polling again
+	errorDelay = time.Second
+	// recoverAge skips jobs that may still be between insert and XADD
+	recoverAge = time.Minute
+)
+
+type registration struct {
+	handler Handler
+	workers int
+}
+
+// Queue delivers jobs from the jobs table to worker pools through one Redis
+// stream per job type. Each job is read by one consumer of the stream's
+// group; a running job's entry stays pending until the job finishes, and
+// entries idle for longer than the visibility timeout are reclaimed.
+//
+// Failed jobs wait as RETRYING in a sorted set until their next attempt.
+// Cancellation is broadcast over Redis pub/sub to the worker running the job.
+type Queue struct {
+	redis    *redis.Client
+	jobs     repositories.JobRepository
+	cfg      Config
+	logger   *zap.Logger
+	handlers map[string]registration
+
+	// promoteEvery is how often due retries are queued
+	promoteEvery time.Duration
+
+	mu sync.Mutex
+	// running cancels the jobs this process is running, by job ID
+	running map[string]context.CancelCauseFunc
+}
+
+// New creates a queue. Register handlers before calling Run.
+func New(conn *database.RedisConnection, jobs repositories.JobRepository, cfg Config, logger *zap.Logger) *Queue {
+	if cfg.VisibilityTimeout <= 0 {
+		cfg.VisibilityTimeout = DefaultVisibilityTimeout
+	}
+	if cfg.MaxDeliveries <= 0 {
+		cfg.MaxDeliveries = DefaultMaxDeliveries
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.RetryBaseDelay <= 0 {
+		cfg.RetryBaseDelay = DefaultRetryBaseDelay
+	}
+	if cfg.RetryMaxDelay < cfg.RetryBaseDelay {
+		cfg.RetryMaxDelay = max(DefaultRetryMaxDelay, cfg.RetryBaseDelay)
+	}
+	if cfg.Block <= 0 {
+		cfg.Block = DefaultBlock
+	}
+	if cfg.Consumer == "" {
+		host, _ := os.Hostname()
+		cfg.Consumer = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	return &Queue{
+		redis:    conn.Client,
+		jobs:     jobs,
+		cfg:      cfg,
+		logger:   logger,
+		handlers: make(map[string]registration),
+		running:  make(map[string]context.CancelCauseFunc),
+
+		promoteEvery: promoteInterval,
+	}
+}
+
+// Register runs jobs of a type on a pool of workers in this process.
+// A type without workers is only enqueued here and run by other replicas.
+func (q *Queue) Register(jobType string, workers int, handler Handler) {
+	if workers > 0 {
+		q.handlers[jobType] = registration{handler: handler, workers: workers}
+	}
+}
+
+// streamKey returns the Redis stream of a job type
+func streamKey(jobType string) string {
+	return keyPrefix + jobType
+}
+
+// Enqueue records a QUEUED job and hands it to the workers of its type. If
+// Redis rejects the entry the job is marked failed.
+func (q *Queue) Enqueue(ctx context.Context, job *models.Job) error {
+	job.Status = models.JobStatusQueued
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = q.cfg.MaxAttempts
+	}
+	if err := q.jobs.Create(ctx, job); err != nil {
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+	if err := q.push(ctx, job); err != nil {
+		err = fmt.Errorf("failed to queue job: %w", err)
+		if markErr := q.jobs.MarkFailed(context.WithoutCancel(ctx), job.ID, err.Error()); markErr != nil {
+			q.logger.Error("Failed to mark job failed", zap.String("job_id", job.ID), zap.Error(markErr))
+		}
+		return err
+	}
+	q.publish(ctx, job.ID)
+	return nil
+}
+
+// push adds a stream entry for a job
+func (q *Queue) push(ctx context.Context, job *models.Job) error {
+	return q.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamKey(job.Type),
+		Values: map[string]any{"job_id": job.ID},
+	}).Err()
+}
+
+// ensureGroup creates the consumer group of a stream; entries added before
+// the group existed are delivered too
+func (q *Queue) ensureGroup(ctx context.Context, stream string) error {
+	err := q.redis.XGroupCreateMkStream(ctx, stream, group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+	return nil
+}
+
+// Run starts the worker pools and blocks until ctx is cancelled and every
+// running job has returned. Jobs interrupted by the cancellation are queued
+// again.
+func (q *Queue) Run(ctx context.Context) {
+	if err := q.recover(ctx); err != nil {
+		q.logger.Warn("Failed to recover queued jobs", zap.Error(err))
+	}
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		q.promoteLoop(ctx)
+	})
+	wg.Go(func() {
+		q.listenCancels(ctx)
+	})
+	for jobType, reg := range q.handlers {
+		q.logger.Info("Starting job workers", zap.String("type", jobType), zap.Int("workers", reg.workers))
+		for i := range reg.workers {
+			consumer := fmt.Sprintf("%s-%d", q.cfg.Consumer, i)
+			wg.Go(func() {
+				q.work(ctx, jobType, reg.handler, consumer)
+			})
+		}
+	}
+	wg.Wait()
+}
+
+// work is the loop of one worker
+func (q *Queue) work(ctx context.Context, jobType string, handler Handler, consumer string) {
+	stream := streamKey(jobType)
+	for ctx.Err() == nil {
+		msg, reclaimed, err := q.next(ctx, stream, consumer)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			q.logger.Warn("Failed to read job queue", zap.String("type", jobType), zap.Error(err))
+			sleep(ctx, errorDelay)
+			continue
+		}
+		if msg != nil {
+			q.handle(ctx, stream, consumer, handler, *msg, reclaimed)
+		}
+	}
+}
+
+// next returns an entry abandoned by a crashed worker or, failing that, waits
+// for a new one. A nil message means the poll timed out.
+func (q *Queue) next(ctx context.Context, stream, consumer string) (*redis.XMessage, bool, error) {
+	claimed, _, err := q.redis.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  q.cfg.VisibilityTimeout,
+		Start:    "0-0",
+		Count:    1,
+	}).Result()
+	if isNoGroup(err) {
+		return nil, false, q.ensureGroup(ctx, stream)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(claimed) > 0 {
+		return &claimed[0], true, nil
+	}
+
+	streams, err := q.redis.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, ">"},
+		Count:    1,
+		Block:    q.cfg.Block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if isNoGroup(err) {
+		return nil, false, q.ensureGroup(ctx, stream)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return nil, false, nil
+	}
+	return &streams[0].Messages[0], false, nil
+}
+
+// isNoGroup reports whether the stream or its group does not exist yet
+func isNoGroup(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOGROUP")
+}
+
+// handle runs the job of a stream entry and acknowledges the entry once the
+// job's outcome is recorded
+func (q *Queue) handle(ctx context.Context, stream, consumer string, handler Handler, msg redis.XMessage, reclaimed bool) {
+	jobID, _ := msg.Values["job_id"].(string)
+	logger := q.logger.With(zap.String("job_id", jobID), zap.String("stream", stream))
+	// Outcomes are recorded even when shutdown cancelled ctx
+	record := context.WithoutCancel(ctx)
+
+	if reclaimed {
+		deliveries, err := q.deliveries(ctx, stream, msg.ID)
+		if err != nil {
+			logger.Warn("Failed to read job delivery count", zap.Error(err))
+		}
+		if deliveries > int64(q.cfg.MaxDeliveries) {
+			logger.Error("Abandoning job after repeated worker crashes", zap.Int64("deliveries", deliveries))
+			message := fmt.Sprintf("abandoned after %d deliveries without finishing", deliveries-1)
+			if err := q.jobs.MarkFailed(record, jobID, message); err != nil && !errors.Is(err, repositories.ErrNotFound) {
+				logger.Error("Failed to mark job failed", zap.Error(err))
+				return
+			}
+			if job, err := q.jobs.GetByID(record, jobID); err == nil && job.Attempts > 0 {
+				q.finishAttempt(record, jobID, job.Attempts, models.JobAttemptInterrupted, nil)
+			}
+			q.publish(record, jobID)
+			q.ack(record, stream, msg.ID)
+			return
+		}
+		logger.Warn("Reclaimed job from an unresponsive worker", zap.Int64("deliveries", deliveries))
+	}
+
+	job, err := q.jobs.GetByID(ctx, jobID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		logger.Warn("Dropping queue entry of unknown job")
+		q.ack(record, stream, msg.ID)
+		return
+	}
+	if err != nil {
+		// Left pending; reclaimed after the visibility timeout
+		logger.Error("Failed to load job", zap.Error(err))
+		return
+	}
+	if job.Finished() || job.Status == models.JobStatusRetrying {
+		// The worker recorded the outcome but crashed before acknowledging,
+		// or the job was cancelled while queued
+		q.ack(record, stream, msg.ID)
+		return
+	}
+	if job.CancelRequestedAt != nil {
+		// Cancelled while its worker crashed
+		if err := q.jobs.MarkCancelled(record, job.ID); err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			logger.Error("Failed to mark job cancelled", zap.Error(err))
+			return
+		}
+		q.publish(record, job.ID)
+		q.ack(record, stream, msg.ID)
+		return
+	}
+
+	attempt, err := q.jobs.StartAttempt(ctx, job.ID, consumer)
+	if err != nil {
+		logger.Warn("Failed to record job attempt", zap.Error(err))
+	}
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	q.track(job.ID, cancel)
+	var heartbeat sync.WaitGroup
+	heartbeat.Go(func() {
+		q.heartbeat(jobCtx, cancel, stream, consumer, msg.ID, job.ID)
+	})
+	err = q.process(jobCtx, handler, job)
+	cancelled := errors.Is(context.Cause(jobCtx), ErrCancelled)
+	q.untrack(job.ID)
+	cancel(nil)
+	heartbeat.Wait()
+
+	if err != nil && ctx.Err() != nil && !cancelled {
+		logger.Info("Job interrupted by shutdown, queuing it again")
+		q.finishAttempt(record, job.ID, attempt, models.JobAttemptInterrupted, nil)
+		q.requeue(record, stream, job, msg.ID)
+		return
+	}
+	if err := q.settle(record, job, handler, attempt, err); err != nil {
+		logger.Error("Failed to record job outcome", zap.Error(err))
+		return
+	}
+	q.publish(record, job.ID)
+	q.ack(record, stream, msg.ID)
+}
+
+// process runs a handler, turning a panic into an error
+func (q *Queue) process(ctx context.Context, handler Handler, job *models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			q.logger.Error("Job handler panicked", zap.String("job_id", job.ID), zap.Any("panic", r), zap.Stack("stack"))
+			err = fmt.Errorf("job handler panicked: %v", r)
+		}
+	}()
+	return handler.Process(ctx, job)
+}
+
+// settle records how an attempt ended: completed, cancelled on request,
+// waiting for a retry or failed for good
+func (q *Queue) settle(ctx context.Context, job *models.Job, handler Handler, attempt int, cause error) error {
+	current, err := q.jobs.GetByID(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case cause == nil:
+		q.finishAttempt(ctx, job.ID, attempt, models.JobAttemptCompleted, nil)
+		if current.Finished() {
+			return nil
+		}
+		return q.jobs.MarkCompleted(ctx, job.ID)
+	case current.CancelRequestedAt != nil:
+		q.finishAttempt(ctx, job.ID, attempt, models.JobAttemptCancelled, nil)
+		q.logger.Info("Job cancelled", zap.String("job_id", job.ID))
+		err := q.jobs.MarkCancelled(ctx, job.ID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			// Completed before the worker noticed the cancellation
+			return nil
+		}
+		return err
+	}
+
+	message := cause.Error()
+	q.finishAttempt(ctx, job.ID, attempt, models.JobAttemptFailed, &message)
+	failures, err := q.failures(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+	if retrier, ok := handler.(Retrier); (!ok || retrier.Retryable(cause)) && failures < current.MaxAttempts {
+		next := time.Now().Add(q.backoff(failures))
+		if err := q.jobs.MarkRetrying(ctx, job.ID, message, next); err != nil {
+			return err
+		}
+		if err := q.redis.ZAdd(ctx, delayedKey, redis.Z{Score: float64(next.UnixMilli()), Member: job.ID}).Err(); err != nil {
+			return fmt.Errorf("failed to schedule job retry: %w", err)
+		}
+		q.logger.Warn("Job failed, retrying",
+			zap.String("job_id", job.ID),
+			zap.Int("failures", failures),
+			zap.Time("next_attempt_at", next),
+			zap.Error(cause),
+		)
+		return nil
+	}
+	if current.Status == models.JobStatusFailed {
+		return nil
+	}
+	return q.jobs.MarkFailed(ctx, job.ID, message)
+}
+
+// failures counts the failed attempts of a job
+func (q *Queue) failures(ctx context.Context, jobID string) (int, error) {
+	attempts, err := q.jobs.ListAttempts(ctx, jobID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load job attempts: %w", err)
+	}
+	failures := 0
+	for _, a := range attempts {
+		if a.Status == models.JobAttemptFailed {
+			failures++
+		}
+	}
+	// The attempt history is best effort; count at least this failure
+	return max(failures, 1), nil
+}
+
+// backoff returns the wait before the next attempt after the given number of failures
+func (q *Queue) backoff(failures int) time.Duration {
+	delay := q.cfg.RetryBaseDelay
+	for i := 1; i < failures && delay < q.cfg.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, q.cfg.RetryMaxDelay)
+}
+
+// finishAttempt records an attempt's outcome; attempt 0 was never recorded
+func (q *Queue) finishAttempt(ctx context.Context, jobID string, attempt int, status string, message *string) {
+	if attempt == 0 {
+		return
+	}
+	if err := q.jobs.FinishAttempt(ctx, jobID, attempt, status, message); err != nil {
+		q.logger.Warn("Failed to record job attempt", zap.String("job_id", jobID), zap.Int("attempt", attempt), zap.Error(err))
+	}
+}
+
+// requeue resets an interrupted job and replaces its stream entry, so the
+// interruption does not count as a crashed delivery
+func (q *Queue) requeue(ctx context.Context, stream string, job *models.Job, entryID string) {
+	if err := q.jobs.Requeue(ctx, job.ID); err != nil {
+		q.logger.Error("Failed to requeue job", zap.String("job_id", job.ID), zap.Error(err))
+		return
+	}
+	if err := q.push(ctx, job); err != nil {
+		// The pending entry is reclaimed after the visibility timeout instead
+		q.logger.Error("Failed to requeue job", zap.String("job_id", job.ID), zap.Error(err))
+		return
+	}
+	q.ack(ctx, stream, entryID)
+}
+
+// heartbeat keeps a running job's entry from being reclaimed by resetting
+// its idle time until ctx is done. It also cancels the job if a cancel
+// request was recorded but its broadcast missed this process.
+func (q *Queue) heartbeat(ctx context.Context, cancel context.CancelCauseFunc, stream, consumer, entryID, jobID string) {
+	ticker := time.NewTicker(q.cfg.VisibilityTimeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := q.redis.XClaimJustID(ctx, &redis.XClaimArgs{
+				Stream:   stream,
+				Group:    group,
+				Consumer: consumer,
+				Messages: []string{entryID},
+			}).Err()
+			if err != nil && ctx.Err() == nil {
+				q.logger.Warn("Failed to extend job visibility", zap.String("entry_id", entryID), zap.Error(err))
+			}
+			if job, err := q.jobs.GetByID(ctx, jobID); err == nil && job.CancelRequestedAt != nil {
+				cancel(ErrCancelled)
+			}
+		}
+	}
+}
+
+// track registers the cancel function of a job running in this process
+func (q *Queue) track(jobID string, cancel context.CancelCauseFunc) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.running[jobID] = cancel
+}
+
+// untrack forgets a job that stopped running
+func (q *Queue) untrack(jobID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.running, jobID)
+}
+
+// cancelLocal cancels a job if this process runs it
+func (q *Queue) cancelLocal(jobID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if cancel, ok := q.running[jobID]; ok {
+		cancel(ErrCancelled)
+	}
+}
+
+// listenCancels cancels running jobs named on the cancel channel until ctx is done
+func (q *Queue) listenCancels(ctx context.Context) {
+	sub := q.redis.Subscribe(ctx, cancelChannel)
+	defer sub.Close()
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			q.cancelLocal(msg.Payload)
+		}
+	}
+}
+
+// Cancel stops a job: queued and retrying jobs are cancelled at once, a
+// running job's worker is told to stop and records the cancellation when it
+// does. It returns the job as updated, or ErrJobFinished.
+func (q *Queue) Cancel(ctx context.Context, id string) (*models.Job, error) {
+	status, err := q.jobs.RequestCancel(ctx, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		job, err := q.jobs.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return job, ErrJobFinished
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel job: %w", err)
+	}
+
+	if status == models.JobStatusRunning {
+		q.cancelLocal(id)
+		if err := q.redis.Publish(ctx, cancelChannel, id).Err(); err != nil {
+			// The worker's heartbeat still sees the request
+			q.logger.Warn("Failed to broadcast job cancellation", zap.String("job_id", id), zap.Error(err))
+		}
+	} else if err := q.redis.ZRem(ctx, delayedKey, id).Err(); err != nil {
+		// A promoted retry finds the job cancelled and is dropped
+		q.logger.Warn("Failed to drop cancelled job retry", zap.String("job_id", id), zap.Error(err))
+	}
+	job, err := q.jobs.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.cfg.Progress.PublishStatus(ctx, job); err != nil {
+		q.logger.Warn("Failed to publish job status", zap.String("job_id", id), zap.Error(err))
+	}
+	return job, nil
+}
+
+// Retry enqueues a copy of a failed or cancelled job with the same target and
+// metadata; the copy references the original through RetryOf
+func (q *Queue) Retry(ctx context.Context, id string) (*models.Job, error) {
+	original, err := q.jobs.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if original.Status != models.JobStatusFailed && original.Status != models.JobStatusCancelled {
+		return nil, ErrNotRetryable
+	}
+	clone := &models.Job{
+		Type:        original.Type,
+		TenantID:    original.TenantID,
+		MailboxID:   original.MailboxID,
+		UserID:      original.UserID,
+		Metadata:    original.Metadata,
+		MaxAttempts: original.MaxAttempts,
+		RetryOf:     &original.ID,
+	}
+	if err := q.Enqueue(ctx, clone); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// promoteLoop queues due retries until ctx is done
+func (q *Queue) promoteLoop(ctx context.Context) {
+	ticker := time.NewTicker(q.promoteEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := q.promote(ctx); err != nil && ctx.Err() == nil {
+				q.logger.Warn("Failed to queue job retries", zap.Error(err))
+			}
+		}
+	}
+}
+
+// promote moves RETRYING jobs whose next attempt is due back to their stream.
+// Removing the set member first makes one replica handle each retry.
+func (q *Queue) promote(ctx context.Context) error {
+	due, err := q.redis.ZRangeByScore(ctx, delayedKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Count: promoteBatch,
+	}).Result()
+	if err != nil {
+		return err
+	}
+	for _, id := range due {
+		job, err := q.jobs.GetByID(ctx, id)
+		if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			return err
+		}
+		removed, err := q.redis.ZRem(ctx, delayedKey, id).Result()
+		if err != nil {
+			return err
+		}
+		if removed == 0 || job == nil {
+			continue
+		}
+		if err := q.jobs.Resume(ctx, id); err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				// Cancelled while waiting
+				continue
+			}
+			return err
+		}
+		if err := q.push(ctx, job); err != nil {
+			// recover queues it again on the next start
+			return fmt.Errorf("failed to queue job retry: %w", err)
+		}
+		q.publish(ctx, id)
+	}
+	return nil
+}
+
+// publish broadcasts a job's current status; failures are only logged
+func (q *Queue) publish(ctx context.Context, jobID string) {
+	if q.cfg.Progress == nil {
+		return
+	}
+	job, err := q.jobs.GetByID(ctx, jobID)
+	if err == nil {
+		err = q.cfg.Progress.PublishStatus(ctx, job)
+	}
+	if err != nil {
+		q.logger.Warn("Failed to publish job status", zap.String("job_id", jobID), zap.Error(err))
+	}
+}
+
+// deliveries returns how often an entry has been handed to a consumer
+func (q *Queue) deliveries(ctx context.Context, stream, entryID string) (int64, error) {
+	pending, err := q.redis.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  group,
+		Start:  entryID,
+		End:    entryID,
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+	return pending[0].RetryCount, nil
+}
+
+// ack removes a handled entry from the stream
+func (q *Queue) ack(ctx context.Context, stream, entryID string) {
+	pipe := q.redis.TxPipeline()
+	pipe.XAck(ctx, stream, group, entryID)
+	pipe.XDel(ctx, stream, entryID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		q.logger.Warn("Failed to acknowledge job", zap.String("entry_id", entryID), zap.Error(err))
+	}
+}
+
+// recover re-adds unfinished jobs that have no stream entry and retrying jobs
+// missing from the retry set, e.g. after Redis lost its data. Only one replica
+// recovers at a time.
+func (q *Queue) recover(ctx context.Context) error {
+	lock := keyPrefix + "recover"
+	ok, err := q.redis.SetNX(ctx, lock, q.cfg.Consumer, q.cfg.VisibilityTimeout).Result()
+	if err != nil || !ok {
+		return err
+	}
+	defer q.redis.Del(context.WithoutCancel(ctx), lock)
+
+	cutoff := time.Now().Add(-recoverAge)
+	for jobType := range q.handlers {
+		stream := streamKey(jobType)
+		if err := q.ensureGroup(ctx, stream); err != nil {
+			return err
+		}
+		entries, err := q.redis.XRange(ctx, stream, "-", "+").Result()
+		if err != nil {
+			return fmt.Errorf("failed to read job stream: %w", err)
+		}
+		queued := make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			if id, ok := entry.Values["job_id"].(string); ok {
+				queued[id] = true
+			}
+		}
+
+		retrying, err := q.unfinished(ctx, jobType, models.JobStatusRetrying)
+		if err != nil {
+			return err
+		}
+		for _, job := range retrying {
+			next := time.Now()
+			if job.NextAttemptAt != nil {
+				next = *job.NextAttemptAt
+			}
+			err := q.redis.ZAddNX(ctx, delayedKey, redis.Z{Score: float64(next.UnixMilli()), Member: job.ID}).Err()
+			if err != nil {
+				return fmt.Errorf("failed to schedule job retry: %w", err)
+			}
+		}
+
+		for _, status := range []string{models.JobStatusQueued, models.JobStatusRunning} {
+			jobs, err := q.unfinished(ctx, jobType, status)
+			if err != nil {
+				return err
+			}
+			for i := range jobs {
+				job := &jobs[i]
+				if queued[job.ID] || job.CreatedAt.After(cutoff) {
+					continue
+				}
+				if err := q.push(ctx, job); err != nil {
+					return fmt.Errorf("failed to requeue job: %w", err)
+				}
+				q.logger.Warn("Recovered job missing from the queue", zap.String("job_id", job.ID), zap.String("type", jobType))
+			}
+		}
+	}
+	return nil
+}
+
+// unfinished lists all jobs of a type in a status
+func (q *Queue) unfinished(ctx context.Context, jobType, status string) ([]models.Job, error) {
+	var jobs []models.Job
+	filter := repositories.JobFilter{Type: jobType, Status: status}
+	for page := 1; ; page++ {
+		result, err := q.jobs.List(ctx, filter, repositories.Pagination{Page: page, Limit: repositories.MaxPageLimit})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s jobs: %w", strings.ToLower(status), err)
+		}
+		jobs = append(jobs, result.Items...)
+		if len(jobs) >= result.Total || len(result.Items) == 0 {
+			return jobs, nil
+		}
+	}
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+// ParseWorkers parses "JOB_TYPE=workers" entries separated by commas
+func ParseWorkers(spec string) (map[string]int, error) {
+	workers := make(map[string]int)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		jobType, count, ok := strings.Cut(entry, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if !ok || strings.TrimSpace(jobType) == "" || err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid job worker count %q, expected JOB_TYPE=workers", entry)
+		}
+		workers[strings.ToUpper(strings.TrimSpace(jobType))] = n
+	}
+	return workers, nil
+}
@@ -0,0 +1,578 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+)
+
+// memoryJobs is an in-memory jobs table covering the methods the queue uses
+type memoryJobs struct {
+	repositories.JobRepository
+
+	mu       sync.Mutex
+	seq      int
+	jobs     map[string]*models.Job
+	attempts map[string][]models.JobAttempt
+}
+
+func newMemoryJobs() *memoryJobs {
+	return &memoryJobs{jobs: make(map[string]*models.Job), attempts: make(map[string][]models.JobAttempt)}
+}
+
+func (m *memoryJobs) Create(_ context.Context, job *models.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	job.ID = fmt.Sprintf("job-%d", m.seq)
+	if job.Status == "" {
+		job.Status = models.JobStatusQueued
+	}
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
+	stored := *job
+	m.jobs[job.ID] = &stored
+	return nil
+}
+
+func (m *memoryJobs) GetByID(_ context.Context, id string) (*models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, repositories.ErrNotFound
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func (m *memoryJobs) List(_ context.Context, filter repositories.JobFilter, _ repositories.Pagination) (repositories.Page[models.Job], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var page repositories.Page[models.Job]
+	for _, job := range m.jobs {
+		if job.Type == filter.Type && job.Status == filter.Status {
+			page.Items = append(page.Items, *job)
+		}
+	}
+	page.Total = len(page.Items)
+	return page, nil
+}
+
+func (m *memoryJobs) setStatus(id, status string, message *string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return repositories.ErrNotFound
+	}
+	job.Status = status
+	job.ErrorMessage = message
+	return nil
+}
+
+func (m *memoryJobs) MarkRunning(_ context.Context, id string) error {
+	return m.setStatus(id, models.JobStatusRunning, nil)
+}
+
+func (m *memoryJobs) MarkCompleted(_ context.Context, id string) error {
+	return m.setStatus(id, models.JobStatusCompleted, nil)
+}
+
+func (m *memoryJobs) MarkFailed(_ context.Context, id, errorMessage string) error {
+	return m.setStatus(id, models.JobStatusFailed, &errorMessage)
+}
+
+func (m *memoryJobs) Requeue(_ context.Context, id string) error {
+	return m.setStatus(id, models.JobStatusQueued, nil)
+}
+
+func (m *memoryJobs) MarkRetrying(_ context.Context, id, errorMessage string, nextAttemptAt time.Time) error {
+	if err := m.setStatus(id, models.JobStatusRetrying, &errorMessage); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[id].NextAttemptAt = &nextAttemptAt
+	return nil
+}
+
+func (m *memoryJobs) Resume(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok || job.Status != models.JobStatusRetrying {
+		return repositories.ErrNotFound
+	}
+	job.Status = models.JobStatusQueued
+	job.NextAttemptAt = nil
+	return nil
+}
+
+func (m *memoryJobs) RequestCancel(_ context.Context, id string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok || job.Finished() {
+		return "", repositories.ErrNotFound
+	}
+	now := time.Now()
+	job.CancelRequestedAt = &now
+	if job.Status != models.JobStatusRunning {
+		job.Status = models.JobStatusCancelled
+	}
+	return job.Status, nil
+}
+
+func (m *memoryJobs) MarkCancelled(_ context.Context, id string) error {
+	return m.setStatus(id, models.JobStatusCancelled, nil)
+}
+
+func (m *memoryJobs) StartAttempt(_ context.Context, id, worker string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return 0, repositories.ErrNotFound
+	}
+	job.Attempts++
+	m.attempts[id] = append(m.attempts[id], models.JobAttempt{
+		JobID:     id,
+		Attempt:   job.Attempts,
+		Status:    models.JobAttemptRunning,
+		Worker:    &worker,
+		StartedAt: time.Now(),
+	})
+	return job.Attempts, nil
+}
+
+func (m *memoryJobs) FinishAttempt(_ context.Context, id string, attempt int, status string, errorMessage *string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.attempts[id] {
+		if a := &m.attempts[id][i]; a.Attempt == attempt {
+			now := time.Now()
+			a.Status, a.ErrorMessage, a.FinishedAt = status, errorMessage, &now
+			return nil
+		}
+	}
+	return repositories.ErrNotFound
+}
+
+func (m *memoryJobs) ListAttempts(_ context.Context, id string) ([]models.JobAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.JobAttempt(nil), m.attempts[id]...), nil
+}
+
+// attemptStatuses returns the statuses of a job's attempts, oldest first
+func (m *memoryJobs) attemptStatuses(id string) []string {
+	attempts, _ := m.ListAttempts(context.Background(), id)
+	var statuses []string
+	for _, a := range attempts {
+		statuses = append(statuses, a.Status)
+	}
+	return statuses
+}
+
+// status returns a job's current status
+func (m *memoryJobs) status(id string) string {
+	job, err := m.GetByID(context.Background(), id)
+	if err != nil {
+		return ""
+	}
+	return job.Status
+}
+
+// newTestQueue creates a queue on miniredis and an in-memory jobs table
+func newTestQueue(t *testing.T, visibility time.Duration) (*Queue, *memoryJobs, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	conn, err := database.NewRedisConnection("redis://"+mr.Addr(), zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	jobs := newMemoryJobs()
+	q := New(conn, jobs, Config{
+		VisibilityTimeout: visibility,
+		MaxDeliveries:     2,
+		MaxAttempts:       1,
+		RetryBaseDelay:    20 * time.Millisecond,
+		Block:             50 * time.Millisecond,
+		Consumer:          "test",
+	}, zap.NewNop())
+	q.promoteEvery = 20 * time.Millisecond
+	return q, jobs, conn.Client
+}
+
+// runQueue runs the queue until the test ends or the returned stop is called
+func runQueue(t *testing.T, q *Queue) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+	stop = func() {
+		cancel()
+		<-done
+	}
+	t.Cleanup(stop)
+	return sync.OnceFunc(stop)
+}
+
+// waitForStatus waits until a job reaches a status
+func waitForStatus(t *testing.T, jobs *memoryJobs, id, status string) {
+	t.Helper()
+	require.Eventually(t, func() bool { return jobs.status(id) == status }, 5*time.Second, 10*time.Millisecond,
+		"job %s did not reach %s", id, status)
+}
+
+// TestEnqueueRunsJob verifies queued jobs run and a handler's result is recorded
+func TestEnqueueRunsJob(t *testing.T) {
+	q, jobs, rdb := newTestQueue(t, time.Minute)
+	q.Register(models.JobTypeSyncMailbox, 1, HandlerFunc(func(ctx context.Context, job *models.Job) error {
+		if job.MailboxID == nil {
+			return errors.New("no mailbox")
+		}
+		return nil
+	}))
+	runQueue(t, q)
+
+	ctx := context.Background()
+	mailboxID := "mailbox-1"
+	ok := &models.Job{Type: models.JobTypeSyncMailbox, MailboxID: &mailboxID}
+	bad := &models.Job{Type: models.JobTypeSyncMailbox}
+	require.NoError(t, q.Enqueue(ctx, ok))
+	require.NoError(t, q.Enqueue(ctx, bad))
+
+	waitForStatus(t, jobs, ok.ID, models.JobStatusCompleted)
+	waitForStatus(t, jobs, bad.ID, models.JobStatusFailed)
+	failed, err := jobs.GetByID(ctx, bad.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "no mailbox", *failed.ErrorMessage)
+
+	// Handled entries are acknowledged and removed
+	require.Eventually(t, func() bool {
+		return rdb.XLen(ctx, streamKey(models.JobTypeSyncMailbox)).Val() == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+// TestPanickingHandlerFailsJob verifies a panic fails the job without stopping the worker
+func TestPanickingHandlerFailsJob(t *testing.T) {
+	q, jobs, _ := newTestQueue(t, time.Minute)
+	var calls atomic.Int32
+	q.Register(models.JobTypeExport, 1, HandlerFunc(func(ctx context.Context, job *models.Job) error {
+		if calls.Add(1) == 1 {
+			panic("boom")
+		}
+		return nil
+	}))
+	runQueue(t, q)
+
+	ctx := context.Background()
+	first := &models.Job{Type: models.JobTypeExport}
+	second := &models.Job{Type: models.JobTypeExport}
+	require.NoError(t, q.Enqueue(ctx, first))
+	require.NoError(t, q.Enqueue(ctx, second))
+
+	waitForStatus(t, jobs, first.ID, models.JobStatusFailed)
+	waitForStatus(t, jobs, second.ID, models.JobStatusCompleted)
+}
+
+// TestWorkerPoolSize verifies a job type runs at most its configured number of jobs at once
+func TestWorkerPoolSize(t *testing.T) {
+	q, jobs, _ := newTestQueue(t, time.Minute)
+	var running, peak atomic.Int32
+	q.Register(models.JobTypeSyncTenant, 2, HandlerFunc(func(ctx context.Context, job *models.Job) error {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		running.Add(-1)
+		return nil
+	}))
+	runQueue(t, q)
+
+	var ids []string
+	for range 6 {
+		job := &models.Job{Type: models.JobTypeSyncTenant}
+		require.NoError(t, q.Enqueue(context.Background(), job))
+		ids = append(ids, job.ID)
+	}
+	for _, id := range ids {
+		waitForStatus(t, jobs, id, models.JobStatusCompleted)
+	}
+	assert.Equal(t, int32(2), peak.Load())
+}
+
+// TestReclaimsJobOfCrashedWorker verifies a job read but never acknowledged is run by another worker
+func TestReclaimsJobOfCrashedWorker(t *testing.T) {
+	q, jobs, rdb := newTestQueue(t, 200*time.Millisecond)
+	ctx := context.Background()
+	stream := streamKey(models.JobTypeSyncAll)
+
+	// A worker of another process took the job and died while running it
+	job := &models.Job{Type: models.JobTypeSyncAll}
+	require.NoError(t, jobs.Create(ctx, job))
+	require.NoError(t, jobs.MarkRunning(ctx, job.ID))
+	require.NoError(t, q.ensureGroup(ctx, stream))
+	require.NoError(t, q.push(ctx, job))
+	require.NoError(t, rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: group, Consumer: "crashed", Streams: []string{stream, ">"}, Count: 1}).Err())
+
+	var runs atomic.Int32
+	q.Register(models.JobTypeSyncAll, 1, HandlerFunc(func(ctx context.Context, job *models.Job) error {
+		runs.Add(1)
+		return nil
+	}))
+	runQueue(t, q)
+
+	waitForStatus(t, jobs, job.ID, models.JobStatusCompleted)
+	assert.Equal(t, int32(1), runs.Load())
+}
+
+// TestHeartbeatPreventsReclaim verifies a long job is not handed to a second worker
+func TestHeartbeatPreventsReclaim(t *testing.T) {
+	q, jobs, _ := newTestQueue(t, 150*time.Millisecond)
+	var runs atomic.Int32
+	q.Register(models.JobTypeSyncMailbox, 2, HandlerFunc(func(ctx context.Context, job *models.Job) error {
+		runs.Add(1)
+		time.Sleep(600 * time.Millisecond)
+		return nil
+	}))
+	runQueue(t, q)
+
+	job := &models.Job{Type: models.JobTypeSyncMailbox}
+	require.NoError(t, q.Enqueue(context.Background(), job))
+	waitForStatus(t, jobs, job.ID, models.JobStatusCompleted)
+	assert.Equal(t, int32(1), runs.Load())
+}
+
+// TestAbandonsJobAfterMaxDeliveries verifies a job that keeps crashing workers is failed
+func TestAbandonsJobAfterMaxDeliveries(t *testing.T) {
+	q, jobs, rdb := newTestQueue(t, 100*time.Millisecond)
+	ctx := context.Background()
+	stream := streamKey(models.JobTypeSyncMailbox)
+
+	job := &models.Job{Type: models.JobTypeSyncMailbox}
+	require.NoError(t, jobs.Create(ctx, job))
+	require.NoError(t, q.ensureGroup(ctx, stream))
+	require.NoError(t, q.push(ctx, job))
+	require.NoError(t, rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: group, Consumer: "crashed", Streams: []string{stream, ">"}, Count: 1}).Err())
+	// Reclaimed by another worker that crashed as well
+	require.NoError(t, rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{Stream: stream, Group: group, Consumer: "crashed-again", Start: "0-0", Count: 1}).Err())
+
+	q.Register(models.JobTypeSyncMailbox, 1, HandlerFunc(func(ctx context.Context, job *models.Job) error {
+		t.Error("abandoned job must not run")
+		return nil
+	}))
+	runQueue(t, q)
+
+	waitForStatus(t, jobs, job.ID, models.JobStatusFailed)
+	failed, err := jobs.GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Contains(t, *failed.ErrorMessage, "abandoned after 2 deliveries")
+}
+
+// TestShutdownRequeuesRunningJob verifies a job cancelled by shutdown is queued again
+func TestShutdownRequeuesRunningJob(t *testing.T) {
+	q, jobs, rdb := newTestQueue(t, time.Minute)
+	started := make(chan struct{})
+	q.Register(models.JobTypeSyncTenant, 1, HandlerFunc(func(ctx context.Context, job *models.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	stop := runQueue(t, q)
+
+	ctx := context.Background()
+	job := &models.Job{Type: models.JobTypeSyncTenant}
+	require.NoError(t, q.Enqueue(ctx, job))
+	<-started
+	stop()
+
+	assert.Equal(t, models.JobStatusQueued, jobs.status(job.ID))
+	stream := streamKey(models.JobTypeSyncTenant)
+	assert.Equal(t, int64(1), rdb.XLen(ctx, stream).Val())
+	pending, err := rdb.XPending(ctx, stream, group).Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count)
+}
+
+// TestRecoverQueuesMissingJobs verifies unfinished jobs without a stream entry are queued again
+func TestRecoverQueuesMissingJobs(t *testing.T) {
+	q, jobs, _ := newTestQueue(t, time.Minute)
+	ctx := context.Background()
+
+	lost := &models.Job{Type: models.JobTypeSyncAll, CreatedAt: time.Now().Add(-time.Hour)}
+	require.NoError(t, jobs.Create(ctx, lost))
+	// Possibly still being enqueued by another process
+	recent := &models.Job{Type: models.JobTypeSyncAll}
+	require.NoError(t, jobs.Create(ctx, recent))
+
+	q.Register(models.JobTypeSyncAll, 1, HandlerFunc(func(ctx context.Context, job *models.Job) error {
+		return nil
+	}))
+	runQueue(t, q)
+
+	waitForStatus(t, jobs, lost.ID, models.JobStatusCompleted)
+	assert.Equal(t, models.JobStatusQueued, jobs.status(recent.ID))
+}
+
+// permanent marks a handler's errors as not worth retrying
+type permanent struct{ HandlerFunc }
+
+func (permanent) Retryable(error) bool { return false }
+
+// TestFailedJobRetried verifies failed attempts are retried after a backoff until one succeeds
+func TestFailedJobRetried(t *testing.T) {
+	q, jobs, rdb := newTestQueue(t, time.Minute)
+	var calls atomic.Int32
+	q.Register(models.JobTypeSyncMailbox, 1, HandlerFunc(func(ctx context.Context, job *models.Job) error {
+		if calls.Add(1) < 3 {
+			return errors.New("graph unavailable")
+		}
+		return nil
+	}))
+	runQueue(t, q)
+
+	ctx := context.Background()
+	job := &models.Job{Type: models.JobTypeSyncMailbox, MaxAttempts: 3}
+	require.NoError(t, q.Enqueue(ctx, job))
+
+	waitForStatus(t, jobs, job.ID, models.JobStatusCompleted)
+	assert.Equal(t, []string{models.JobAttemptFailed, models.JobAttemptFailed, models.JobAttemptCompleted}, jobs.attemptStatuses(job.ID))
+	assert.Zero(t, rdb.ZCard(ctx, delayedKey).Val())
+}
+
+// TestExhaustedJobDeadLettered verifies a job fails for good after its last attempt or a permanent error
+func TestExhaustedJobDeadLettered(t *testing.T) {
+	q, jobs, _ := newTestQueue(t, time.Minute)
+	q.Register(models.JobTypeSyncMailbox, 1, HandlerFunc(func(ctx context.Context, job *models.Job) error {
+		return errors.New("graph unavailable")
+	}))
+	q.Register(models.JobTypeExport, 1, permanent{HandlerFunc(func(ctx context.Context, job *models.Job) error {
+		return errors.New("mailbox deleted")
+	})})
+	runQueue(t, q)
+
+	ctx := context.Background()
+	exhausted := &models.Job{Type: models.JobTypeSyncMailbox, MaxAttempts: 2}
+	rejected := &models.Job{Type: models.JobTypeExport, MaxAttempts: 3}
+	require.NoError(t, q.Enqueue(ctx, exhausted))
+	require.NoError(t, q.Enqueue(ctx, rejected))
+
+	waitForStatus(t, jobs, exhausted.ID, models.JobStatusFailed)
+	waitForStatus(t, jobs, rejected.ID, models.JobStatusFailed)
+	assert.Equal(t, []string{models.JobAttemptFailed, models.JobAttemptFailed}, jobs.attemptStatuses(exhausted.ID))
+	assert.Equal(t, []string{models.JobAttemptFailed}, jobs.attemptStatuses(rejected.ID))
+}
+
+// TestCancelRunningJob verifies a broadcast cancellation stops the job's handler
+func TestCancelRunningJob(t *testing.T) {
+	q, jobs, rdb := newTestQueue(t, time.Minute)
+	started := make(chan struct{})
+	q.Register(models.JobTypeSyncTenant, 1, HandlerFunc(func(ctx context.Context, job *models.Job) error {
+		if err := jobs.MarkRunning(ctx, job.ID); err != nil {
+			return err
+		}
+		close(started)
+		<-ctx.Done()
+		return context.Cause(ctx)
+	}))
+	runQueue(t, q)
+
+	ctx := context.Background()
+	job := &models.Job{Type: models.JobTypeSyncTenant, MaxAttempts: 3}
+	require.NoError(t, q.Enqueue(ctx, job))
+	<-started
+
+	// Requested through another replica, which only reaches this worker over pub/sub
+	status, err := jobs.RequestCancel(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusRunning, status)
+	require.Eventually(t, func() bool {
+		require.NoError(t, rdb.Publish(ctx, cancelChannel, job.ID).Err())
+		return jobs.status(job.ID) == models.JobStatusCancelled
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, []string{models.JobAttemptCancelled}, jobs.attemptStatuses(job.ID))
+
+	_, err = q.Cancel(ctx, job.ID)
+	assert.ErrorIs(t, err, ErrJobFinished)
+}
+
+// TestCancelQueuedJob verifies a job cancelled before a worker picks it up never runs
+func TestCancelQueuedJob(t *testing.T) {
+	q, jobs, rdb := newTestQueue(t, time.Minute)
+	ctx := context.Background()
+	job := &models.Job{Type: models.JobTypeExport}
+	require.NoError(t, q.Enqueue(ctx, job))
+
+	cancelled, err := q.Cancel(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusCancelled, cancelled.Status)
+
+	q.Register(models.JobTypeExport, 1, HandlerFunc(func(ctx context.Context, job *models.Job) error {
+		t.Error("cancelled job must not run")
+		return nil
+	}))
+	runQueue(t, q)
+	require.Eventually(t, func() bool {
+		return rdb.XLen(ctx, streamKey(models.JobTypeExport)).Val() == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, models.JobStatusCancelled, jobs.status(job.ID))
+	assert.Empty(t, jobs.attemptStatuses(job.ID))
+}
+
+// TestRetryClonesJob verifies a retry copies a failed job's target and metadata
+func TestRetryClonesJob(t *testing.T) {
+	q, jobs, _ := newTestQueue(t, time.Minute)
+	ctx := context.Background()
+	tenantID := "tenant-1"
+	original := &models.Job{Type: models.JobTypeSyncTenant, TenantID: &tenantID, Metadata: []byte(`{"full":true}`)}
+	require.NoError(t, q.Enqueue(ctx, original))
+
+	_, err := q.Retry(ctx, original.ID)
+	assert.ErrorIs(t, err, ErrNotRetryable)
+
+	require.NoError(t, jobs.MarkFailed(ctx, original.ID, "graph unavailable"))
+	clone, err := q.Retry(ctx, original.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, original.ID, clone.ID)
+	assert.Equal(t, models.JobStatusQueued, jobs.status(clone.ID))
+	assert.Equal(t, &original.ID, clone.RetryOf)
+	assert.Equal(t, &tenantID, clone.TenantID)
+	assert.JSONEq(t, `{"full":true}`, string(clone.Metadata))
+}
+
+// TestParseWorkers verifies per-type pool sizes and rejection of malformed entries
+func TestParseWorkers(t *testing.T) {
+	workers, err := ParseWorkers("SYNC_MAILBOX=4, sync_tenant=2,SYNC_ALL=0,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"SYNC_MAILBOX": 4, "SYNC_TENANT": 2, "SYNC_ALL": 0}, workers)
+
+	for _, spec := range []string{"SYNC_MAILBOX", "=3", "SYNC_ALL=-1", "SYNC_ALL=many"} {
+		_, err := ParseWorkers(spec)
+		assert.Error(t, err, spec)
+	}
+}
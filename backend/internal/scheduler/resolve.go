@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+)
+
+// Levels a mailbox's sync schedule can come from, most specific first
+const (
+	SourceMailbox = "mailbox"
+	SourceTenant  = "tenant"
+	SourceGlobal  = "global"
+)
+
+// Resolved is the sync schedule that applies to a mailbox
+type Resolved struct {
+	// Source is SourceMailbox, SourceTenant or SourceGlobal
+	Source string
+	// ScheduleKey identifies the schedule in schedule_runs
+	ScheduleKey string
+	// Schedule is nil when scheduled sync is disabled
+	Schedule *Schedule
+	// SyncEnabled reports whether the mailbox is included in sync runs at all
+	SyncEnabled bool
+}
+
+// ResolveMailbox resolves a mailbox's sync schedule Mailbox → Tenant → Global
+// from the current settings
+func (s *Scheduler) ResolveMailbox(ctx context.Context, mailboxID string) (*Resolved, error) {
+	mailbox, err := s.mailboxes.GetByID(ctx, mailboxID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mailbox: %w", err)
+	}
+	tenant, err := s.tenants.GetByID(ctx, mailbox.TenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tenant: %w", err)
+	}
+	enabled, err := s.enabled(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zone, err := s.zone(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mailbox overrides without a zone use their tenant's
+	var tenantSchedule *Schedule
+	var tenantErr error
+	tenantZone := zone
+	if tenant.SyncSchedule != nil {
+		if tenantSchedule, tenantErr = parseOverride(tenant.SyncSchedule, tenant.SyncTimezone, zone); tenantErr == nil {
+			tenantZone = tenantSchedule.Location
+		}
+	}
+
+	var resolved *Resolved
+	switch {
+	case mailbox.SyncSchedule != nil:
+		schedule, err := parseOverride(mailbox.SyncSchedule, mailbox.SyncTimezone, tenantZone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sync schedule of mailbox %s: %w", mailbox.ID, err)
+		}
+		resolved = &Resolved{Source: SourceMailbox, ScheduleKey: models.ScheduleKeyMailbox(mailbox.ID), Schedule: schedule}
+	case tenant.SyncSchedule != nil:
+		if tenantErr != nil {
+			return nil, fmt.Errorf("invalid sync schedule of tenant %s: %w", tenant.ID, tenantErr)
+		}
+		resolved = &Resolved{Source: SourceTenant, ScheduleKey: models.ScheduleKeyTenant(tenant.ID), Schedule: tenantSchedule}
+	default:
+		schedule, err := s.global(ctx, zone)
+		if err != nil {
+			return nil, err
+		}
+		resolved = &Resolved{Source: SourceGlobal, ScheduleKey: models.ScheduleKeyGlobal, Schedule: schedule}
+	}
+	if !enabled {
+		resolved.Schedule = nil
+	}
+	resolved.SyncEnabled = mailbox.SyncEnabled
+	return resolved, nil
+}
+
+// enabled reads the scheduler_enabled setting, which defaults to on
+func (s *Scheduler) enabled(ctx context.Context) (bool, error) {
+	enabled := true
+	if err := s.settings.GetValue(ctx, models.SettingSchedulerEnabled, &enabled); err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		return false, err
+	}
+	return enabled, nil
+}
+
+// zone returns the sync_timezone setting, or the configured zone without one
+func (s *Scheduler) zone(ctx context.Context) (*time.Location, error) {
+	var zone string
+	err := s.settings.GetValue(ctx, models.SettingSyncTimezone, &zone)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		return nil, err
+	}
+	if zone == "" {
+		return s.cfg.Timezone, nil
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s setting: %w", models.SettingSyncTimezone, err)
+	}
+	return loc, nil
+}
+
+// global parses the sync_schedule setting; nil means it is not set
+func (s *Scheduler) global(ctx context.Context, zone *time.Location) (*Schedule, error) {
+	var expr string
+	if err := s.settings.GetValue(ctx, models.SettingSyncSchedule, &expr); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return ParseSchedule(expr, zone)
+}
+
+// parseOverride parses a tenant or mailbox schedule, in its own zone if it
+// has one and in fallback otherwise
+func parseOverride(expr, zone *string, fallback *time.Location) (*Schedule, error) {
+	loc := fallback
+	if zone != nil {
+		var err error
+		if loc, err = time.LoadLocation(*zone); err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %w", *zone, err)
+		}
+	}
+	return ParseSchedule(*expr, loc)
+}
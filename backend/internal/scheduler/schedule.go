@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	// Embedded zone database, so CRON_TZ and sync_timezone work in minimal containers
+	_ "time/tzdata"
+
+	"github.com/robfig/cron/v3"
+)
+
+// cronParser accepts standard five-field expressions, descriptors such as
+// @daily, and a leading CRON_TZ=<zone>. ParseSchedule rejects @every.
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Schedule is a parsed cron expression bound to a time zone
+type Schedule struct {
+	Expr     string
+	Location *time.Location
+	spec     cron.Schedule
+}
+
+// ParseSchedule parses a cron expression evaluated in loc, unless the
+// expression names its own zone with CRON_TZ=
+func ParseSchedule(expr string, loc *time.Location) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("cron expression is empty")
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	full := expr
+	if !strings.HasPrefix(expr, "CRON_TZ=") && !strings.HasPrefix(expr, "TZ=") {
+		full = "CRON_TZ=" + loc.String() + " " + expr
+	}
+	spec, err := cronParser.Parse(full)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	// @every ticks relative to whenever a replica starts counting, so
+	// replicas would not agree on the ticks that dedupe their runs
+	anchored, ok := spec.(*cron.SpecSchedule)
+	if !ok {
+		return nil, fmt.Errorf("invalid cron expression %q: @every is not supported, use a cron expression", expr)
+	}
+	return &Schedule{Expr: expr, Location: anchored.Location, spec: spec}, nil
+}
+
+// Next returns the first tick after t, in UTC
+func (s *Schedule) Next(t time.Time) time.Time {
+	return s.spec.Next(t).UTC()
+}
+
+// Between returns the ticks in (after, until], oldest first, at most limit
+func (s *Schedule) Between(after, until time.Time, limit int) []time.Time {
+	var ticks []time.Time
+	for t := s.Next(after); !t.IsZero() && !t.After(until) && len(ticks) < limit; t = s.Next(t) {
+		ticks = append(ticks, t)
+	}
+	return ticks
+}
+
+// Upcoming returns the next n ticks after t, oldest first
+func (s *Schedule) Upcoming(t time.Time, n int) []time.Time {
+	ticks := []time.Time{}
+	for next := s.Next(t); !next.IsZero() && len(ticks) < n; next = s.Next(next) {
+		ticks = append(ticks, next)
+	}
+	return ticks
+}
+
+// equal reports whether two schedules produce the same ticks
+func (s *Schedule) equal(other *Schedule) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	return s.Expr == other.Expr && s.Location.String() == other.Location.String()
+}
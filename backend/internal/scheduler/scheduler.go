@@ -0,0 +1,408 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+)
+
+// Catch-up policies for ticks missed while no scheduler was running
+const (
+	// CatchUpSkip records missed ticks without running them
+	CatchUpSkip = "skip"
+	// CatchUpLatest runs the most recent missed tick once and records the rest
+	CatchUpLatest = "latest"
+)
+
+// Defaults for zero Config fields
+const (
+	DefaultCatchUpWindow  = 24 * time.Hour
+	DefaultReloadInterval = 30 * time.Second
+	DefaultMisfireGrace   = time.Minute
+)
+
+const (
+	lockPrefix = "ironarchive:scheduler:"
+	// lockTTL keeps other replicas from firing a tick they see late
+	lockTTL = 10 * time.Minute
+	// maxMissed is the number of missed ticks recorded per batch
+	maxMissed = 1000
+)
+
+// Enqueuer queues jobs; implemented by queue.Queue
+type Enqueuer interface {
+	Enqueue(ctx context.Context, job *models.Job) error
+}
+
+// Config tunes the scheduler
+type Config struct {
+	// Timezone applies to schedules without CRON_TZ= when the sync_timezone
+	// setting is absent
+	Timezone *time.Location
+	// CatchUp is CatchUpSkip or CatchUpLatest
+	CatchUp string
+	// CatchUpWindow limits how far back missed ticks are considered
+	CatchUpWindow time.Duration
+	// ReloadInterval is how often settings are checked for changes
+	ReloadInterval time.Duration
+	// MisfireGrace is how late a tick may be noticed and still count as on time
+	MisfireGrace time.Duration
+}
+
+// TriggerSchedule marks jobs enqueued by the scheduler
+const TriggerSchedule = "schedule"
+
+// TickMetadata is stored as the metadata of scheduled jobs
+type TickMetadata struct {
+	Trigger     string    `json:"trigger"`
+	ScheduleKey string    `json:"scheduleKey"`
+	ScheduledAt time.Time `json:"scheduledAt"`
+	CatchUp     bool      `json:"catchUp,omitempty"`
+}
+
+// entry is one schedule the scheduler fires: the global one or an override
+type entry struct {
+	key      string
+	schedule *Schedule
+	// job is the template of the jobs enqueued on each tick
+	job models.Job
+	// last is the latest tick handled; zero until loaded from schedule_runs
+	last time.Time
+}
+
+// Scheduler enqueues sync jobs on the ticks of settings.sync_schedule
+// (SYNC_ALL) and of tenant (SYNC_TENANT) and mailbox (SYNC_MAILBOX) schedule
+// overrides. Every replica runs one; a Redis lock and the schedule_runs
+// record make each tick fire once.
+type Scheduler struct {
+	settings  repositories.SettingRepository
+	runs      repositories.ScheduleRunRepository
+	tenants   repositories.TenantRepository
+	mailboxes repositories.MailboxRepository
+	queue     Enqueuer
+	redis     *redis.Client
+	cfg       Config
+	logger    *zap.Logger
+	now       func() time.Time
+	reload    chan struct{}
+
+	// entries are the loaded schedules by key, empty while disabled
+	entries map[string]*entry
+	// loaded is set once the settings were read
+	loaded bool
+}
+
+// New creates a scheduler
+func New(repos *repositories.Repositories, queue Enqueuer, conn *database.RedisConnection, cfg Config, logger *zap.Logger) *Scheduler {
+	if cfg.Timezone == nil {
+		cfg.Timezone = time.UTC
+	}
+	if cfg.CatchUp == "" {
+		cfg.CatchUp = CatchUpLatest
+	}
+	if cfg.CatchUpWindow <= 0 {
+		cfg.CatchUpWindow = DefaultCatchUpWindow
+	}
+	if cfg.ReloadInterval <= 0 {
+		cfg.ReloadInterval = DefaultReloadInterval
+	}
+	if cfg.MisfireGrace <= 0 {
+		cfg.MisfireGrace = DefaultMisfireGrace
+	}
+	return &Scheduler{
+		settings:  repos.Settings,
+		runs:      repos.ScheduleRuns,
+		tenants:   repos.Tenants,
+		mailboxes: repos.Mailboxes,
+		queue:     queue,
+		redis:     conn.Client,
+		cfg:       cfg,
+		logger:    logger,
+		now:       time.Now,
+		reload:    make(chan struct{}, 1),
+		entries:   map[string]*entry{},
+	}
+}
+
+// Reload makes a running scheduler re-read its settings now instead of at
+// the next reload interval, e.g. after the schedule was changed via the API
+func (s *Scheduler) Reload() {
+	select {
+	case s.reload <- struct{}{}:
+	default:
+	}
+}
+
+// Run fires scheduled jobs until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		s.refresh(ctx)
+
+		wait := s.cfg.ReloadInterval
+		if len(s.entries) > 0 {
+			now := s.now()
+			if err := s.fireDue(ctx, now); err != nil && ctx.Err() == nil {
+				s.logger.Error("Failed to fire scheduled sync", zap.Error(err))
+			}
+			for _, e := range s.entries {
+				wait = min(wait, max(e.schedule.Next(now).Sub(s.now()), 0))
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.reload:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// refresh loads the schedules and switches to changed ones
+func (s *Scheduler) refresh(ctx context.Context) {
+	entries, err := s.load(ctx)
+	if err != nil {
+		// Keep the previous schedules while settings are unreadable or invalid
+		s.logger.Error("Failed to load sync schedule", zap.Error(err))
+		return
+	}
+
+	for key, e := range entries {
+		if old, ok := s.entries[key]; ok && e.schedule.equal(old.schedule) {
+			e.last = old.last
+			continue
+		}
+		s.logger.Info("Scheduled sync loaded",
+			zap.String("schedule_key", key),
+			zap.String("schedule", e.schedule.Expr),
+			zap.String("timezone", e.schedule.Location.String()),
+			zap.Time("next_run", e.schedule.Next(s.now())),
+		)
+		// Ticks before a runtime change or re-enable were not missed; after a
+		// restart the last recorded tick decides
+		if s.loaded {
+			e.last = s.now()
+		}
+	}
+	for key := range s.entries {
+		if _, ok := entries[key]; !ok {
+			s.logger.Info("Scheduled sync disabled", zap.String("schedule_key", key))
+		}
+	}
+	if !s.loaded && len(entries) == 0 {
+		s.logger.Info("Scheduled sync disabled")
+	}
+	s.entries = entries
+	s.loaded = true
+}
+
+// load reads the global schedule and the tenant and mailbox overrides; no
+// entries means scheduled sync is disabled
+func (s *Scheduler) load(ctx context.Context) (map[string]*entry, error) {
+	entries := map[string]*entry{}
+	enabled, err := s.enabled(ctx)
+	if err != nil || !enabled {
+		return entries, err
+	}
+	zone, err := s.zone(ctx)
+	if err != nil {
+		return nil, err
+	}
+	global, err := s.global(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	if global != nil {
+		entries[models.ScheduleKeyGlobal] = &entry{
+			key:      models.ScheduleKeyGlobal,
+			schedule: global,
+			job:      models.Job{Type: models.JobTypeSyncAll},
+		}
+	}
+
+	tenants, err := s.tenants.ListScheduled(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tenant sync schedules: %w", err)
+	}
+	// Mailbox overrides without a zone use their tenant's
+	zones := make(map[string]*time.Location, len(tenants))
+	for _, tenant := range tenants {
+		key := models.ScheduleKeyTenant(tenant.ID)
+		schedule, err := parseOverride(tenant.SyncSchedule, tenant.SyncTimezone, zone)
+		if err != nil {
+			s.keepInvalid(entries, key, err)
+			continue
+		}
+		zones[tenant.ID] = schedule.Location
+		entries[key] = &entry{
+			key:      key,
+			schedule: schedule,
+			job:      models.Job{Type: models.JobTypeSyncTenant, TenantID: &tenant.ID},
+		}
+	}
+
+	mailboxes, err := s.mailboxes.ListScheduled(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mailbox sync schedules: %w", err)
+	}
+	for _, mailbox := range mailboxes {
+		key := models.ScheduleKeyMailbox(mailbox.ID)
+		fallback, ok := zones[mailbox.TenantID]
+		if !ok {
+			fallback = zone
+		}
+		schedule, err := parseOverride(mailbox.SyncSchedule, mailbox.SyncTimezone, fallback)
+		if err != nil {
+			s.keepInvalid(entries, key, err)
+			continue
+		}
+		entries[key] = &entry{
+			key:      key,
+			schedule: schedule,
+			job:      models.Job{Type: models.JobTypeSyncMailbox, TenantID: &mailbox.TenantID, MailboxID: &mailbox.ID},
+		}
+	}
+	return entries, nil
+}
+
+// keepInvalid keeps the previous schedule of an override that no longer parses
+func (s *Scheduler) keepInvalid(entries map[string]*entry, key string, err error) {
+	s.logger.Error("Invalid sync schedule override", zap.String("schedule_key", key), zap.Error(err))
+	if old, ok := s.entries[key]; ok {
+		entries[key] = &entry{key: key, schedule: old.schedule, job: old.job}
+	}
+}
+
+// fireDue handles the due ticks of every schedule; one failing schedule does
+// not hold up the others
+func (s *Scheduler) fireDue(ctx context.Context, now time.Time) error {
+	var errs []error
+	for _, e := range s.entries {
+		if err := s.fireEntry(ctx, e, now); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.key, err))
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fireEntry handles every tick of a schedule since the last one handled: a
+// tick within the misfire grace fires normally, older ones were missed and
+// are recorded according to the catch-up policy
+func (s *Scheduler) fireEntry(ctx context.Context, e *entry, now time.Time) error {
+	if e.last.IsZero() {
+		latest, err := s.runs.Latest(ctx, e.key)
+		switch {
+		case errors.Is(err, repositories.ErrNotFound):
+			// First start: nothing was missed
+			e.last = now
+			return nil
+		case err != nil:
+			return fmt.Errorf("failed to load last scheduled run: %w", err)
+		}
+		e.last = latest.ScheduledAt
+	}
+
+	after := e.last
+	if floor := now.Add(-s.cfg.CatchUpWindow); after.Before(floor) {
+		after = floor
+	}
+	for {
+		ticks := e.schedule.Between(after, now, maxMissed)
+		if len(ticks) == 0 {
+			return nil
+		}
+		// Long downtime of a frequent schedule: record in chunks, all missed but the final tick
+		final := len(ticks) < maxMissed || e.schedule.Next(ticks[len(ticks)-1]).After(now)
+		onTime := now.Sub(ticks[len(ticks)-1]) <= s.cfg.MisfireGrace
+		for i, tick := range ticks {
+			status := models.ScheduleRunMissed
+			switch {
+			case i < len(ticks)-1 || !final:
+			case onTime:
+				status = models.ScheduleRunFired
+			case s.cfg.CatchUp == CatchUpLatest:
+				status = models.ScheduleRunCaughtUp
+			}
+			if err := s.fire(ctx, e, tick, status); err != nil {
+				return err
+			}
+			e.last = tick
+		}
+		if final {
+			return nil
+		}
+		after = e.last
+	}
+}
+
+// fire records a tick and, unless it was missed, enqueues its job. Replicas
+// that lose the tick's lock or find it recorded leave it alone. A tick whose
+// job cannot be enqueued is forgotten again so the next pass retries it.
+func (s *Scheduler) fire(ctx context.Context, e *entry, tick time.Time, status string) error {
+	logger := s.logger.With(zap.String("schedule_key", e.key), zap.Time("scheduled_at", tick), zap.String("status", status))
+	lock := fmt.Sprintf("%s%s:%d", lockPrefix, e.key, tick.Unix())
+	if status != models.ScheduleRunMissed {
+		acquired, err := s.redis.SetNX(ctx, lock, 1, lockTTL).Result()
+		if err != nil {
+			// The unique schedule_runs record still prevents a second run
+			logger.Warn("Scheduler lock unavailable", zap.Error(err))
+		} else if !acquired {
+			return nil
+		}
+	}
+
+	run := &models.ScheduleRun{ScheduleKey: e.key, ScheduledAt: tick, Status: status}
+	created, err := s.runs.Record(ctx, run)
+	if err != nil {
+		return fmt.Errorf("failed to record scheduled run: %w", err)
+	}
+	if !created {
+		return nil
+	}
+	if status == models.ScheduleRunMissed {
+		logger.Warn("Scheduled sync missed")
+		return nil
+	}
+
+	metadata, err := json.Marshal(TickMetadata{
+		Trigger:     TriggerSchedule,
+		ScheduleKey: e.key,
+		ScheduledAt: tick,
+		CatchUp:     status == models.ScheduleRunCaughtUp,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode job metadata: %w", err)
+	}
+	job := e.job
+	job.Metadata = metadata
+	if err := s.queue.Enqueue(ctx, &job); err != nil {
+		err = fmt.Errorf("failed to enqueue scheduled sync: %w", err)
+		if delErr := s.runs.Delete(ctx, run.ID); delErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to forget scheduled run: %w", delErr))
+		}
+		if delErr := s.redis.Del(ctx, lock).Err(); delErr != nil {
+			logger.Warn("Failed to release scheduler lock", zap.Error(delErr))
+		}
+		return err
+	}
+	if err := s.runs.SetJob(ctx, run.ID, job.ID); err != nil {
+		logger.Warn("Failed to link scheduled run to its job", zap.String("job_id", job.ID), zap.Error(err))
+	}
+	logger.Info("Scheduled sync enqueued", zap.String("job_id", job.ID))
+	return nil
+}
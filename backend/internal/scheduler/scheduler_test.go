@@ -0,0 +1,597 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+)
+
+// memorySettings is an in-memory settings table
+type memorySettings struct {
+	repositories.SettingRepository
+
+	mu     sync.Mutex
+	values map[string]json.RawMessage
+}
+
+func (m *memorySettings) GetValue(_ context.Context, key string, dest any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.values[key]
+	if !ok {
+		return repositories.ErrNotFound
+	}
+	return json.Unmarshal(value, dest)
+}
+
+func (m *memorySettings) Set(_ context.Context, key string, value any) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key] = encoded
+	return nil
+}
+
+// memoryTenants serves tenants from a slice
+type memoryTenants struct {
+	repositories.TenantRepository
+
+	mu      sync.Mutex
+	tenants []models.Tenant
+}
+
+func (m *memoryTenants) GetByID(_ context.Context, id string) (*models.Tenant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tenant := range m.tenants {
+		if tenant.ID == id {
+			return &tenant, nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+func (m *memoryTenants) ListScheduled(_ context.Context) ([]models.Tenant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var scheduled []models.Tenant
+	for _, tenant := range m.tenants {
+		if tenant.SyncSchedule != nil {
+			scheduled = append(scheduled, tenant)
+		}
+	}
+	return scheduled, nil
+}
+
+// memoryMailboxes serves mailboxes from a slice
+type memoryMailboxes struct {
+	repositories.MailboxRepository
+
+	mu        sync.Mutex
+	mailboxes []models.Mailbox
+}
+
+func (m *memoryMailboxes) GetByID(_ context.Context, id string) (*models.Mailbox, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, mailbox := range m.mailboxes {
+		if mailbox.ID == id {
+			return &mailbox, nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+func (m *memoryMailboxes) ListScheduled(_ context.Context) ([]models.Mailbox, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var scheduled []models.Mailbox
+	for _, mailbox := range m.mailboxes {
+		if mailbox.SyncEnabled && mailbox.SyncSchedule != nil {
+			scheduled = append(scheduled, mailbox)
+		}
+	}
+	return scheduled, nil
+}
+
+// runKey identifies a tick of a schedule
+type runKey struct {
+	key string
+	at  time.Time
+}
+
+// memoryRuns is an in-memory schedule_runs table
+type memoryRuns struct {
+	repositories.ScheduleRunRepository
+
+	mu   sync.Mutex
+	runs map[runKey]*models.ScheduleRun
+}
+
+func (m *memoryRuns) Record(_ context.Context, run *models.ScheduleRun) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if run.ScheduleKey == "" {
+		run.ScheduleKey = models.ScheduleKeyGlobal
+	}
+	k := runKey{run.ScheduleKey, run.ScheduledAt.UTC()}
+	if _, ok := m.runs[k]; ok {
+		return false, nil
+	}
+	run.ID = fmt.Sprintf("run-%d", len(m.runs)+1)
+	stored := *run
+	m.runs[k] = &stored
+	return true, nil
+}
+
+func (m *memoryRuns) SetJob(_ context.Context, id, jobID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, run := range m.runs {
+		if run.ID == id {
+			run.JobID = &jobID
+			return nil
+		}
+	}
+	return repositories.ErrNotFound
+}
+
+func (m *memoryRuns) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, run := range m.runs {
+		if run.ID == id {
+			delete(m.runs, k)
+			return nil
+		}
+	}
+	return repositories.ErrNotFound
+}
+
+func (m *memoryRuns) Latest(_ context.Context, key string) (*models.ScheduleRun, error) {
+	var latest *models.ScheduleRun
+	for _, run := range m.list() {
+		if run.ScheduleKey == key {
+			latest = &run
+		}
+	}
+	if latest == nil {
+		return nil, repositories.ErrNotFound
+	}
+	return latest, nil
+}
+
+// list returns the recorded runs, oldest first
+func (m *memoryRuns) list() []models.ScheduleRun {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []models.ScheduleRun
+	for _, run := range m.runs {
+		list = append(list, *run)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].ScheduledAt.Equal(list[j].ScheduledAt) {
+			return list[i].ScheduledAt.Before(list[j].ScheduledAt)
+		}
+		return list[i].ScheduleKey < list[j].ScheduleKey
+	})
+	return list
+}
+
+// statuses returns the status of each recorded run, oldest first
+func (m *memoryRuns) statuses() []string {
+	var statuses []string
+	for _, run := range m.list() {
+		statuses = append(statuses, run.Status)
+	}
+	return statuses
+}
+
+// recordingQueue collects enqueued jobs, failing the first failures calls
+type recordingQueue struct {
+	mu       sync.Mutex
+	jobs     []models.Job
+	failures int
+}
+
+func (q *recordingQueue) Enqueue(_ context.Context, job *models.Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.failures > 0 {
+		q.failures--
+		return errors.New("queue unavailable")
+	}
+	job.ID = fmt.Sprintf("job-%d", len(q.jobs)+1)
+	q.jobs = append(q.jobs, *job)
+	return nil
+}
+
+func (q *recordingQueue) count() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.jobs)
+}
+
+type schedulerFixture struct {
+	settings  *memorySettings
+	tenants   *memoryTenants
+	mailboxes *memoryMailboxes
+	runs      *memoryRuns
+	queue     *recordingQueue
+	conn      *database.RedisConnection
+}
+
+// newFixture creates shared settings, run history, queue and Redis
+func newFixture(t *testing.T, schedule string) *schedulerFixture {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	conn, err := database.NewRedisConnection("redis://"+mr.Addr(), zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	f := &schedulerFixture{
+		settings:  &memorySettings{values: map[string]json.RawMessage{}},
+		tenants:   &memoryTenants{},
+		mailboxes: &memoryMailboxes{},
+		runs:      &memoryRuns{runs: map[runKey]*models.ScheduleRun{}},
+		queue:     &recordingQueue{},
+		conn:      conn,
+	}
+	require.NoError(t, f.settings.Set(context.Background(), models.SettingSchedulerEnabled, true))
+	require.NoError(t, f.settings.Set(context.Background(), models.SettingSyncSchedule, schedule))
+	return f
+}
+
+// repositories returns the fakes as a repository bundle
+func (f *schedulerFixture) repositories() *repositories.Repositories {
+	return &repositories.Repositories{Settings: f.settings, Tenants: f.tenants, Mailboxes: f.mailboxes, ScheduleRuns: f.runs}
+}
+
+// scheduler creates a replica whose clock reads now
+func (f *schedulerFixture) scheduler(t *testing.T, cfg Config, now time.Time) *Scheduler {
+	t.Helper()
+	s := New(f.repositories(), f.queue, f.conn, cfg, zap.NewNop())
+	s.now = func() time.Time { return now }
+	s.refresh(context.Background())
+	require.NotEmpty(t, s.entries)
+	return s
+}
+
+// TestParseScheduleTimezone verifies ticks follow the schedule's zone, including DST
+func TestParseScheduleTimezone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	s, err := ParseSchedule("0 6 * * *", berlin)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 15, 5, 0, 0, 0, time.UTC), s.Next(time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2025, 7, 15, 4, 0, 0, 0, time.UTC), s.Next(time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC)))
+
+	// CRON_TZ in the expression wins over the default zone
+	s, err = ParseSchedule("CRON_TZ=America/New_York 0 6 * * *", berlin)
+	require.NoError(t, err)
+	assert.Equal(t, "America/New_York", s.Location.String())
+	assert.Equal(t, time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC), s.Next(time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)))
+
+	s, err = ParseSchedule("0 6,12,18,0 * * *", nil)
+	require.NoError(t, err)
+	ticks := s.Between(time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC), 10)
+	assert.Len(t, ticks, 4)
+
+	s, err = ParseSchedule("@daily", berlin)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 15, 23, 0, 0, 0, time.UTC), s.Next(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)))
+
+	for _, expr := range []string{"", "61 * * * *", "0 6 * *", "CRON_TZ=Mars/Olympus 0 6 * * *", "@every 6h", "CRON_TZ=UTC @every 1h"} {
+		_, err := ParseSchedule(expr, nil)
+		assert.Error(t, err, expr)
+	}
+}
+
+// TestFireDueOnTime verifies a tick fires a SYNC_ALL job and is recorded
+func TestFireDueOnTime(t *testing.T) {
+	f := newFixture(t, "0 6,12,18,0 * * *")
+	tick := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	f.runs.Record(context.Background(), &models.ScheduleRun{ScheduledAt: tick.Add(-6 * time.Hour), Status: models.ScheduleRunFired})
+
+	s := f.scheduler(t, Config{}, tick.Add(5*time.Second))
+	require.NoError(t, s.fireDue(context.Background(), tick.Add(5*time.Second)))
+
+	assert.Equal(t, []string{models.ScheduleRunFired, models.ScheduleRunFired}, f.runs.statuses())
+	require.Equal(t, 1, f.queue.count())
+	job := f.queue.jobs[0]
+	assert.Equal(t, models.JobTypeSyncAll, job.Type)
+	var metadata TickMetadata
+	require.NoError(t, json.Unmarshal(job.Metadata, &metadata))
+	assert.Equal(t, tick, metadata.ScheduledAt)
+	assert.False(t, metadata.CatchUp)
+	assert.Equal(t, job.ID, *f.runs.list()[1].JobID)
+
+	// Nothing is due until the next tick
+	require.NoError(t, s.fireDue(context.Background(), tick.Add(time.Hour)))
+	assert.Equal(t, 1, f.queue.count())
+}
+
+// TestFireDueRetriesFailedEnqueue verifies a tick whose job could not be
+// enqueued is not recorded and fires on the next pass
+func TestFireDueRetriesFailedEnqueue(t *testing.T) {
+	f := newFixture(t, "0 6,12,18,0 * * *")
+	tick := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	f.runs.Record(context.Background(), &models.ScheduleRun{ScheduledAt: tick.Add(-6 * time.Hour), Status: models.ScheduleRunFired})
+	f.queue.failures = 1
+
+	s := f.scheduler(t, Config{}, tick.Add(5*time.Second))
+	assert.Error(t, s.fireDue(context.Background(), tick.Add(5*time.Second)))
+	assert.Equal(t, []string{models.ScheduleRunFired}, f.runs.statuses())
+	assert.Equal(t, 0, f.queue.count())
+
+	require.NoError(t, s.fireDue(context.Background(), tick.Add(10*time.Second)))
+	assert.Equal(t, []string{models.ScheduleRunFired, models.ScheduleRunFired}, f.runs.statuses())
+	require.Equal(t, 1, f.queue.count())
+	assert.Equal(t, f.queue.jobs[0].ID, *f.runs.list()[1].JobID)
+}
+
+// TestFireDueFirstStart verifies a fresh install does not treat earlier ticks as missed
+func TestFireDueFirstStart(t *testing.T) {
+	f := newFixture(t, "0 6,12,18,0 * * *")
+	now := time.Date(2025, 3, 10, 13, 0, 0, 0, time.UTC)
+	s := f.scheduler(t, Config{}, now)
+
+	require.NoError(t, s.fireDue(context.Background(), now))
+	assert.Empty(t, f.runs.list())
+	assert.Zero(t, f.queue.count())
+}
+
+// TestFireDueCatchUp verifies missed ticks after downtime are recorded per the catch-up policy
+func TestFireDueCatchUp(t *testing.T) {
+	last := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	// Down from just after midnight until 13:30: 06:00 and 12:00 were missed
+	now := time.Date(2025, 3, 10, 13, 30, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		policy   string
+		statuses []string
+		jobs     int
+	}{
+		{CatchUpLatest, []string{models.ScheduleRunFired, models.ScheduleRunMissed, models.ScheduleRunCaughtUp}, 1},
+		{CatchUpSkip, []string{models.ScheduleRunFired, models.ScheduleRunMissed, models.ScheduleRunMissed}, 0},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			f := newFixture(t, "0 6,12,18,0 * * *")
+			f.runs.Record(context.Background(), &models.ScheduleRun{ScheduledAt: last, Status: models.ScheduleRunFired})
+
+			s := f.scheduler(t, Config{CatchUp: tc.policy}, now)
+			require.NoError(t, s.fireDue(context.Background(), now))
+			assert.Equal(t, tc.statuses, f.runs.statuses())
+			require.Equal(t, tc.jobs, f.queue.count())
+			if tc.jobs > 0 {
+				var metadata TickMetadata
+				require.NoError(t, json.Unmarshal(f.queue.jobs[0].Metadata, &metadata))
+				assert.True(t, metadata.CatchUp)
+				assert.Equal(t, time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC), metadata.ScheduledAt)
+			}
+		})
+	}
+}
+
+// TestFireDueCatchUpWindow verifies ticks older than the window are ignored
+func TestFireDueCatchUpWindow(t *testing.T) {
+	f := newFixture(t, "0 * * * *")
+	last := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	f.runs.Record(context.Background(), &models.ScheduleRun{ScheduledAt: last, Status: models.ScheduleRunFired})
+	now := time.Date(2025, 3, 10, 12, 30, 0, 0, time.UTC)
+
+	s := f.scheduler(t, Config{CatchUpWindow: 3 * time.Hour}, now)
+	require.NoError(t, s.fireDue(context.Background(), now))
+	// 10:00, 11:00 and 12:00 besides the earlier run
+	assert.Len(t, f.runs.list(), 4)
+	assert.Equal(t, 1, f.queue.count())
+}
+
+// TestTickFiresOncePerReplicaSet verifies replicas seeing the same tick enqueue one job
+func TestTickFiresOncePerReplicaSet(t *testing.T) {
+	f := newFixture(t, "0 6,12,18,0 * * *")
+	tick := time.Date(2025, 3, 10, 18, 0, 0, 0, time.UTC)
+	f.runs.Record(context.Background(), &models.ScheduleRun{ScheduledAt: tick.Add(-6 * time.Hour), Status: models.ScheduleRunFired})
+
+	var wg sync.WaitGroup
+	for i := range 3 {
+		s := f.scheduler(t, Config{}, tick.Add(time.Duration(i)*time.Second))
+		wg.Go(func() {
+			assert.NoError(t, s.fireDue(context.Background(), s.now()))
+		})
+	}
+	wg.Wait()
+	assert.Equal(t, 1, f.queue.count())
+	assert.Len(t, f.runs.list(), 2)
+}
+
+// TestRunHotReload verifies schedule changes apply without a restart
+func TestRunHotReload(t *testing.T) {
+	f := newFixture(t, "0 6,12,18,0 * * *")
+	require.NoError(t, f.settings.Set(context.Background(), models.SettingSchedulerEnabled, false))
+	s := New(f.repositories(), f.queue, f.conn, Config{ReloadInterval: time.Hour}, zap.NewNop())
+	var mu sync.Mutex
+	now := time.Date(2025, 3, 10, 11, 59, 30, 0, time.UTC)
+	s.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	// advance moves the clock and wakes the scheduler
+	advance := func(d time.Duration) {
+		mu.Lock()
+		now = now.Add(d)
+		mu.Unlock()
+		s.Reload()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Zero(t, f.queue.count())
+
+	require.NoError(t, f.settings.Set(context.Background(), models.SettingSyncSchedule, "* * * * *"))
+	require.NoError(t, f.settings.Set(context.Background(), models.SettingSchedulerEnabled, true))
+	s.Reload()
+	time.Sleep(50 * time.Millisecond)
+	advance(time.Minute)
+	require.Eventually(t, func() bool { return f.queue.count() == 1 }, 5*time.Second, 20*time.Millisecond)
+	advance(time.Minute)
+	require.Eventually(t, func() bool { return f.queue.count() == 2 }, 5*time.Second, 20*time.Millisecond)
+
+	require.NoError(t, f.settings.Set(context.Background(), models.SettingSchedulerEnabled, false))
+	s.Reload()
+	time.Sleep(50 * time.Millisecond)
+	advance(time.Minute)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 2, f.queue.count())
+	for _, status := range f.runs.statuses() {
+		assert.Equal(t, models.ScheduleRunFired, status)
+	}
+}
+
+// strptr returns a pointer to s
+func strptr(s string) *string {
+	return &s
+}
+
+// TestFireDueOverrides verifies tenant and mailbox overrides fire their own jobs
+func TestFireDueOverrides(t *testing.T) {
+	f := newFixture(t, "0 6,12,18,0 * * *")
+	f.tenants.tenants = []models.Tenant{{ID: "t1", SyncSchedule: strptr("0 * * * *")}}
+	f.mailboxes.mailboxes = []models.Mailbox{
+		{ID: "m1", TenantID: "t1", SyncEnabled: true, SyncSchedule: strptr("15 * * * *")},
+		// Excluded from sync runs, so its override never fires
+		{ID: "m2", TenantID: "t1", SyncSchedule: strptr("* * * * *")},
+	}
+	start := time.Date(2025, 3, 10, 11, 59, 0, 0, time.UTC)
+	s := f.scheduler(t, Config{}, start)
+	require.Len(t, s.entries, 3)
+
+	// First start records nothing, then 12:00 is due for the global and tenant schedules
+	require.NoError(t, s.fireDue(context.Background(), start))
+	require.NoError(t, s.fireDue(context.Background(), start.Add(65*time.Second)))
+	require.Equal(t, 2, f.queue.count())
+	jobs := map[string]models.Job{}
+	for _, job := range f.queue.jobs {
+		jobs[job.Type] = job
+	}
+	assert.Contains(t, jobs, models.JobTypeSyncAll)
+	require.Contains(t, jobs, models.JobTypeSyncTenant)
+	assert.Equal(t, "t1", *jobs[models.JobTypeSyncTenant].TenantID)
+	var metadata TickMetadata
+	require.NoError(t, json.Unmarshal(jobs[models.JobTypeSyncTenant].Metadata, &metadata))
+	assert.Equal(t, models.ScheduleKeyTenant("t1"), metadata.ScheduleKey)
+
+	require.NoError(t, s.fireDue(context.Background(), start.Add(16*time.Minute+5*time.Second)))
+	require.Equal(t, 3, f.queue.count())
+	job := f.queue.jobs[2]
+	assert.Equal(t, models.JobTypeSyncMailbox, job.Type)
+	assert.Equal(t, "m1", *job.MailboxID)
+	assert.Equal(t, "t1", *job.TenantID)
+
+	keys := map[string]int{}
+	for _, run := range f.runs.list() {
+		keys[run.ScheduleKey]++
+	}
+	assert.Equal(t, map[string]int{models.ScheduleKeyGlobal: 1, "tenant:t1": 1, "mailbox:m1": 1}, keys)
+}
+
+// TestRefreshOverrides verifies overrides added at runtime start fresh and
+// invalid ones keep their previous schedule
+func TestRefreshOverrides(t *testing.T) {
+	f := newFixture(t, "0 6,12,18,0 * * *")
+	now := time.Date(2025, 3, 10, 13, 0, 0, 0, time.UTC)
+	s := f.scheduler(t, Config{}, now)
+
+	f.tenants.tenants = []models.Tenant{{ID: "t1", SyncSchedule: strptr("0 * * * *")}}
+	s.refresh(context.Background())
+	require.Contains(t, s.entries, "tenant:t1")
+	assert.Equal(t, now, s.entries["tenant:t1"].last)
+
+	f.tenants.tenants[0].SyncSchedule = strptr("not a schedule")
+	s.refresh(context.Background())
+	require.Contains(t, s.entries, "tenant:t1")
+	assert.Equal(t, "0 * * * *", s.entries["tenant:t1"].schedule.Expr)
+
+	f.tenants.tenants = nil
+	s.refresh(context.Background())
+	assert.NotContains(t, s.entries, "tenant:t1")
+	assert.Contains(t, s.entries, models.ScheduleKeyGlobal)
+}
+
+// TestResolveMailbox verifies schedules resolve Mailbox → Tenant → Global
+func TestResolveMailbox(t *testing.T) {
+	f := newFixture(t, "0 6 * * *")
+	ctx := context.Background()
+	require.NoError(t, f.settings.Set(ctx, models.SettingSyncTimezone, "America/New_York"))
+	f.tenants.tenants = []models.Tenant{
+		{ID: "t1", SyncSchedule: strptr("0 22 * * *"), SyncTimezone: strptr("Europe/Berlin")},
+		{ID: "t2"},
+	}
+	f.mailboxes.mailboxes = []models.Mailbox{
+		{ID: "exec", TenantID: "t1", SyncSchedule: strptr("0 8 * * *")},
+		{ID: "shared", TenantID: "t1"},
+		{ID: "plain", TenantID: "t2"},
+		{ID: "tokyo", TenantID: "t2", SyncSchedule: strptr("0 8 * * *"), SyncTimezone: strptr("Asia/Tokyo")},
+	}
+	s := New(f.repositories(), f.queue, f.conn, Config{}, zap.NewNop())
+
+	for _, tc := range []struct {
+		mailbox, source, key, zone string
+	}{
+		// Inherits the tenant's zone
+		{"exec", SourceMailbox, "mailbox:exec", "Europe/Berlin"},
+		{"shared", SourceTenant, "tenant:t1", "Europe/Berlin"},
+		{"plain", SourceGlobal, models.ScheduleKeyGlobal, "America/New_York"},
+		{"tokyo", SourceMailbox, "mailbox:tokyo", "Asia/Tokyo"},
+	} {
+		resolved, err := s.ResolveMailbox(ctx, tc.mailbox)
+		require.NoError(t, err, tc.mailbox)
+		assert.Equal(t, tc.source, resolved.Source, tc.mailbox)
+		assert.Equal(t, tc.key, resolved.ScheduleKey, tc.mailbox)
+		require.NotNil(t, resolved.Schedule, tc.mailbox)
+		assert.Equal(t, tc.zone, resolved.Schedule.Location.String(), tc.mailbox)
+	}
+
+	resolved, err := s.ResolveMailbox(ctx, "exec")
+	require.NoError(t, err)
+	from := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 1, 15, 7, 0, 0, 0, time.UTC), resolved.Schedule.Next(from))
+
+	_, err = s.ResolveMailbox(ctx, "missing")
+	assert.ErrorIs(t, err, repositories.ErrNotFound)
+
+	require.NoError(t, f.settings.Set(ctx, models.SettingSchedulerEnabled, false))
+	resolved, err = s.ResolveMailbox(ctx, "exec")
+	require.NoError(t, err)
+	assert.Equal(t, SourceMailbox, resolved.Source)
+	assert.Nil(t, resolved.Schedule)
+}
@@ -0,0 +1,145 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+
+	"ironarchive/internal/search/query"
+)
+
+// compileQuery turns a parsed query into the Meilisearch search text and a
+// filter over the derived attributes of EmailDocument. Free text that must
+// match becomes search text, so it ranks; inside OR or negation it becomes a
+// filter on its words instead.
+func compileQuery(node query.Node) (text, filter string, err error) {
+	var texts, clauses []string
+	for _, term := range conjuncts(node) {
+		if t, ok := term.(*query.Text); ok {
+			texts = append(texts, t.String())
+			continue
+		}
+		clause, err := compileFilter(term)
+		if err != nil {
+			return "", "", err
+		}
+		clauses = append(clauses, clause)
+	}
+	return strings.Join(texts, " "), strings.Join(clauses, " AND "), nil
+}
+
+// conjuncts returns the terms that must all match, flattening nested groups
+func conjuncts(node query.Node) []query.Node {
+	switch n := node.(type) {
+	case nil:
+		return nil
+	case *query.And:
+		var terms []query.Node
+		for _, child := range n.Nodes {
+			terms = append(terms, conjuncts(child)...)
+		}
+		return terms
+	default:
+		return []query.Node{node}
+	}
+}
+
+// compileFilter renders a query term as a Meilisearch filter expression
+func compileFilter(node query.Node) (string, error) {
+	switch n := node.(type) {
+	case *query.And:
+		return compileGroup(conjuncts(n), " AND ")
+	case *query.Or:
+		return compileGroup(n.Nodes, " OR ")
+	case *query.Not:
+		part, err := compileFilter(n.Node)
+		if err != nil {
+			return "", err
+		}
+		return "NOT " + part, nil
+	case *query.Text:
+		return compileText(n)
+	case *query.Field:
+		return compileField(n)
+	case *query.HasAttachment:
+		return "has_attachments = true", nil
+	case *query.Size:
+		if n.Larger {
+			return "size_bytes > " + strconv.FormatInt(n.Bytes, 10), nil
+		}
+		return "size_bytes < " + strconv.FormatInt(n.Bytes, 10), nil
+	case *query.Date:
+		if n.Before {
+			return "sent_at < " + strconv.FormatInt(n.Time.Unix(), 10), nil
+		}
+		return "sent_at >= " + strconv.FormatInt(n.Time.Unix(), 10), nil
+	}
+	return "", &query.Error{Pos: node.Pos(), Msg: "unsupported search term"}
+}
+
+// textAttributes are the derived attributes free text is filtered on, one
+// per searchable attribute
+var textAttributes = []string{"subject_words", "sender_terms", "recipient_terms", "attachment_terms", "text_words"}
+
+// compileText requires every word of free text in one of textAttributes.
+// Words of a phrase are matched anywhere, not only next to each other.
+func compileText(t *query.Text) (string, error) {
+	words := query.Words(t.Value)
+	if len(words) == 0 {
+		return "", &query.Error{Pos: t.At, Msg: "free text needs a word to match"}
+	}
+	clauses := make([]string, len(words))
+	for i, word := range words {
+		matches := make([]string, len(textAttributes))
+		for j, attribute := range textAttributes {
+			matches[j] = attribute + " = " + quote(word)
+		}
+		clauses[i] = "(" + strings.Join(matches, " OR ") + ")"
+	}
+	if len(clauses) == 1 {
+		return clauses[0], nil
+	}
+	return "(" + strings.Join(clauses, " AND ") + ")", nil
+}
+
+// compileGroup joins the filters of nodes with sep in parentheses
+func compileGroup(nodes []query.Node, sep string) (string, error) {
+	parts := make([]string, len(nodes))
+	for i, node := range nodes {
+		part, err := compileFilter(node)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = part
+	}
+	return "(" + strings.Join(parts, sep) + ")", nil
+}
+
+// compileField matches a field against the derived terms of EmailDocument
+func compileField(f *query.Field) (string, error) {
+	value := strings.ToLower(strings.TrimSpace(f.Value))
+	switch f.Name {
+	case query.FieldFrom:
+		return "sender_terms = " + quote(value), nil
+	case query.FieldTo, query.FieldCc, query.FieldBcc:
+		// Recipients are archived as one list
+		return "recipient_terms = " + quote(value), nil
+	case query.FieldFilename:
+		return "attachment_terms = " + quote(value), nil
+	case query.FieldLabel:
+		return "labels = " + quote(value), nil
+	case query.FieldSubject:
+		subjectWords := query.Words(value)
+		if len(subjectWords) == 0 {
+			return "", &query.Error{Pos: f.At, Msg: "subject: needs a word to match"}
+		}
+		clauses := make([]string, len(subjectWords))
+		for i, word := range subjectWords {
+			clauses[i] = "subject_words = " + quote(word)
+		}
+		if len(clauses) == 1 {
+			return clauses[0], nil
+		}
+		return "(" + strings.Join(clauses, " AND ") + ")", nil
+	}
+	return "", &query.Error{Pos: f.At, Msg: "unsupported operator " + f.Name + ":"}
+}
@@ -0,0 +1,48 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ironarchive/internal/search/query"
+)
+
+// TestCompileQuery verifies queries split into search text and a filter
+func TestCompileQuery(t *testing.T) {
+	for q, want := range map[string][2]string{
+		``: {"", ""},
+		`from:CEO@ to:legal subject:"merger" has:attachment larger:5MB before:2024-01-01 filename:pdf -label:spam`: {"",
+			`sender_terms = "ceo@" AND recipient_terms = "legal" AND subject_words = "merger" AND has_attachments = true AND ` +
+				`size_bytes > 5242880 AND sent_at < 1704067200 AND attachment_terms = "pdf" AND NOT labels = "spam"`},
+		`quarterly "board meeting" (cc:bob after:2024-01-01)`: {`quarterly "board meeting"`,
+			`recipient_terms = "bob" AND sent_at >= 1704067200`},
+		`smaller:1K (from:alice OR -subject:"re: budget")`: {"",
+			`size_bytes < 1024 AND (sender_terms = "alice" OR NOT (subject_words = "re" AND subject_words = "budget"))`},
+		`-(label:a (label:b OR label:"To Do"))`: {"", `NOT (labels = "a" AND (labels = "b" OR labels = "to do"))`},
+		`invoice OR receipt`: {"", `((subject_words = "invoice" OR sender_terms = "invoice" OR recipient_terms = "invoice" OR ` +
+			`attachment_terms = "invoice" OR text_words = "invoice") OR (subject_words = "receipt" OR sender_terms = "receipt" OR ` +
+			`recipient_terms = "receipt" OR attachment_terms = "receipt" OR text_words = "receipt"))`},
+		`report -"do not"`: {"report", `NOT ((subject_words = "do" OR sender_terms = "do" OR recipient_terms = "do" OR ` +
+			`attachment_terms = "do" OR text_words = "do") AND (subject_words = "not" OR sender_terms = "not" OR ` +
+			`recipient_terms = "not" OR attachment_terms = "not" OR text_words = "not"))`},
+	} {
+		node, err := query.Parse(q)
+		require.NoError(t, err, q)
+		text, filter, err := compileQuery(node)
+		require.NoError(t, err, q)
+		assert.Equal(t, want[0], text, q)
+		assert.Equal(t, want[1], filter, q)
+	}
+
+	for q, want := range map[string]string{
+		`subject:"?!"`: "subject: needs a word to match at position 0",
+		`a OR -"?!"`:   "free text needs a word to match at position 6",
+	} {
+		node, err := query.Parse(q)
+		require.NoError(t, err, q)
+		_, _, err = compileQuery(node)
+		assert.EqualError(t, err, want, q)
+	}
+}
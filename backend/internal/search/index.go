@@ -0,0 +1,246 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/meilisearch/meilisearch-go"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+)
+
+const (
+	// migrateLock serializes index creation and migrations across replicas
+	migrateLock = "ironarchive:search:migrate"
+	// copyBatch is the page size when copying documents between indexes
+	copyBatch = 1000
+)
+
+// VersionStore keeps the schema version of the live index; the settings
+// repository implements it
+type VersionStore interface {
+	GetValue(ctx context.Context, key string, dest any) error
+	Set(ctx context.Context, key string, value any) error
+}
+
+// Filler fills a freshly built index with documents
+type Filler func(ctx context.Context, uid string) error
+
+// Manager creates the emails index and migrates it when SchemaVersion changes
+type Manager struct {
+	client   meilisearch.ServiceManager
+	versions VersionStore
+	redis    *redis.Client
+	logger   *zap.Logger
+
+	// index is the UID of the live index
+	index string
+	// taskInterval is how often task status is polled
+	taskInterval time.Duration
+	// lockRetry is how often a replica retries a held migration lock
+	lockRetry time.Duration
+}
+
+// NewManager creates an index manager
+func NewManager(meili *database.MeilisearchConnection, versions VersionStore, conn *database.RedisConnection, logger *zap.Logger) *Manager {
+	return &Manager{
+		client:       meili.Client,
+		versions:     versions,
+		redis:        conn.Client,
+		logger:       logger,
+		index:        EmailsIndex,
+		taskInterval: 100 * time.Millisecond,
+		lockRetry:    time.Second,
+	}
+}
+
+// Index returns the UID of the live emails index
+func (m *Manager) Index() string {
+	return m.index
+}
+
+// Ensure creates the live index with the current settings, or migrates it
+// when its recorded schema version is older: a new index is built with the
+// new settings, filled by fill (nil copies the live documents), and swapped
+// with the live one in a single Meilisearch task, so searches never see a
+// partial index. A newer recorded version, written by a newer release during
+// a rolling deploy, is left alone. Replicas wait for each other.
+func (m *Manager) Ensure(ctx context.Context, fill Filler) error {
+	return m.ensure(ctx, fill, false)
+}
+
+// Rebuild builds a fresh index with fill and swaps it in for the live one
+// like a migration, dropping documents that went stale
+func (m *Manager) Rebuild(ctx context.Context, fill Filler) error {
+	return m.ensure(ctx, fill, true)
+}
+
+func (m *Manager) ensure(ctx context.Context, fill Filler, rebuild bool) error {
+	unlock, err := waitLock(ctx, m.redis, migrateLock, m.lockRetry, m.logger)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var version int
+	if err := m.versions.GetValue(ctx, models.SettingSearchSchemaVersion, &version); err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		return fmt.Errorf("failed to read search schema version: %w", err)
+	}
+	exists, err := m.exists(ctx, m.index)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !exists:
+		m.logger.Info("Creating search index", zap.String("index", m.index), zap.Int("schema_version", SchemaVersion))
+		if err := m.create(ctx, m.index); err != nil {
+			return err
+		}
+		// A lost index is refilled in place; there is nothing to serve meanwhile
+		if fill != nil {
+			if err := fill(ctx, m.index); err != nil {
+				return fmt.Errorf("failed to fill search index %s: %w", m.index, err)
+			}
+		}
+	case version > SchemaVersion:
+		if rebuild {
+			return fmt.Errorf("search index has schema version %d, newer than supported version %d", version, SchemaVersion)
+		}
+		m.logger.Warn("Search index has a newer schema, leaving it unchanged",
+			zap.String("index", m.index), zap.Int("schema_version", version), zap.Int("supported_version", SchemaVersion))
+		return nil
+	case version == SchemaVersion && !rebuild:
+		return nil
+	default:
+		if err := m.migrate(ctx, version, fill); err != nil {
+			return err
+		}
+	}
+
+	if err := m.versions.Set(ctx, models.SettingSearchSchemaVersion, SchemaVersion); err != nil {
+		return fmt.Errorf("failed to record search schema version: %w", err)
+	}
+	return nil
+}
+
+// migrate builds an index of SchemaVersion and swaps it in for the live one
+func (m *Manager) migrate(ctx context.Context, from int, fill Filler) error {
+	next := fmt.Sprintf("%s_v%d", m.index, SchemaVersion)
+	logger := m.logger.With(zap.String("index", m.index), zap.String("build_index", next),
+		zap.Int("from_version", from), zap.Int("to_version", SchemaVersion))
+	logger.Info("Building search index")
+
+	// A build interrupted by a crash is started over
+	if err := m.drop(ctx, next); err != nil {
+		return err
+	}
+	if err := m.create(ctx, next); err != nil {
+		return err
+	}
+	if fill == nil {
+		fill = func(ctx context.Context, uid string) error { return m.copyDocuments(ctx, m.index, uid) }
+	}
+	if err := fill(ctx, next); err != nil {
+		return fmt.Errorf("failed to fill search index %s: %w", next, err)
+	}
+
+	task, err := m.client.SwapIndexesWithContext(ctx, []*meilisearch.SwapIndexesParams{{Indexes: []string{m.index, next}}})
+	if err := m.wait(ctx, task, err); err != nil {
+		return fmt.Errorf("failed to swap search index %s: %w", next, err)
+	}
+	// next now holds the previous schema; the migration succeeded either way
+	if err := m.drop(ctx, next); err != nil {
+		logger.Warn("Failed to delete previous search index", zap.Error(err))
+	}
+	logger.Info("Search index swapped in")
+	return nil
+}
+
+// create creates an index and applies the current settings
+func (m *Manager) create(ctx context.Context, uid string) error {
+	task, err := m.client.CreateIndexWithContext(ctx, &meilisearch.IndexConfig{Uid: uid, PrimaryKey: PrimaryKey})
+	if err := m.wait(ctx, task, err); err != nil {
+		return fmt.Errorf("failed to create search index %s: %w", uid, err)
+	}
+	task, err = m.client.Index(uid).UpdateSettingsWithContext(ctx, EmailSettings())
+	if err := m.wait(ctx, task, err); err != nil {
+		return fmt.Errorf("failed to configure search index %s: %w", uid, err)
+	}
+	return nil
+}
+
+// drop deletes an index if it exists
+func (m *Manager) drop(ctx context.Context, uid string) error {
+	exists, err := m.exists(ctx, uid)
+	if err != nil || !exists {
+		return err
+	}
+	task, err := m.client.DeleteIndexWithContext(ctx, uid)
+	if err := m.wait(ctx, task, err); err != nil {
+		return fmt.Errorf("failed to delete search index %s: %w", uid, err)
+	}
+	return nil
+}
+
+// exists reports whether an index exists
+func (m *Manager) exists(ctx context.Context, uid string) (bool, error) {
+	_, err := m.client.GetIndexWithContext(ctx, uid)
+	var apiErr *meilisearch.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get search index %s: %w", uid, err)
+	}
+	return true, nil
+}
+
+// copyDocuments copies every document of one index into another
+func (m *Manager) copyDocuments(ctx context.Context, from, to string) error {
+	primaryKey := PrimaryKey
+	for offset := int64(0); ; offset += copyBatch {
+		var page meilisearch.DocumentsResult
+		if err := m.client.Index(from).GetDocumentsWithContext(ctx, &meilisearch.DocumentsQuery{Offset: offset, Limit: copyBatch}, &page); err != nil {
+			return fmt.Errorf("failed to read documents of %s: %w", from, err)
+		}
+		if len(page.Results) == 0 {
+			return nil
+		}
+		task, err := m.client.Index(to).AddDocumentsWithContext(ctx, page.Results, &primaryKey)
+		if err := m.wait(ctx, task, err); err != nil {
+			return fmt.Errorf("failed to add documents to %s: %w", to, err)
+		}
+		if int64(len(page.Results)) < copyBatch {
+			return nil
+		}
+	}
+}
+
+// wait waits until an enqueued task has finished and returns its failure
+func (m *Manager) wait(ctx context.Context, info *meilisearch.TaskInfo, err error) error {
+	return waitTask(ctx, m.client, m.taskInterval, info, err)
+}
+
+// waitTask waits for the task of a Meilisearch call that returned info and
+// err, and returns the error of a failed or cancelled task
+func waitTask(ctx context.Context, client meilisearch.ServiceManager, interval time.Duration, info *meilisearch.TaskInfo, err error) error {
+	if err != nil {
+		return err
+	}
+	task, err := client.WaitForTaskWithContext(ctx, info.TaskUID, interval)
+	if err != nil {
+		return fmt.Errorf("failed to wait for task %d: %w", info.TaskUID, err)
+	}
+	if task.Status != meilisearch.TaskStatusSucceeded {
+		return fmt.Errorf("task %d (%s) %s: %s", task.UID, task.Type, task.Status, task.Error.Message)
+	}
+	return nil
+}
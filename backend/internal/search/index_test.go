@@ -0,0 +1,209 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/meilisearch/meilisearch-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+	"ironarchive/internal/search/searchtest"
+)
+
+// memoryVersions is an in-memory VersionStore
+type memoryVersions struct {
+	mu     sync.Mutex
+	values map[string]json.RawMessage
+}
+
+func (v *memoryVersions) GetValue(_ context.Context, key string, dest any) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	value, ok := v.values[key]
+	if !ok {
+		return repositories.ErrNotFound
+	}
+	return json.Unmarshal(value, dest)
+}
+
+func (v *memoryVersions) Set(_ context.Context, key string, value any) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	v.values[key] = encoded
+	return nil
+}
+
+func (v *memoryVersions) version(t *testing.T) int {
+	t.Helper()
+	var version int
+	require.NoError(t, v.GetValue(context.Background(), models.SettingSearchSchemaVersion, &version))
+	return version
+}
+
+// newTestManager creates a manager against a fake Meilisearch server and miniredis
+func newTestManager(t *testing.T) (*Manager, *searchtest.Server, *memoryVersions) {
+	t.Helper()
+	server := searchtest.NewServer()
+	t.Cleanup(server.Close)
+	mr := miniredis.RunT(t)
+	conn, err := database.NewRedisConnection("redis://"+mr.Addr(), zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	meili, err := database.NewMeilisearchConnection(server.URL, "", zap.NewNop())
+	require.NoError(t, err)
+
+	versions := &memoryVersions{values: make(map[string]json.RawMessage)}
+	manager := NewManager(meili, versions, conn, zap.NewNop())
+	manager.taskInterval = time.Millisecond
+	manager.lockRetry = 5 * time.Millisecond
+	return manager, server, versions
+}
+
+// TestEnsureCreatesIndex verifies a missing index is created with the current settings
+func TestEnsureCreatesIndex(t *testing.T) {
+	manager, server, versions := newTestManager(t)
+
+	require.NoError(t, manager.Ensure(context.Background(), nil))
+	assert.Equal(t, []string{EmailsIndex}, server.Indexes())
+	settings, _ := server.Settings(EmailsIndex)
+	assert.Equal(t, EmailSettings().FilterableAttributes, settings.FilterableAttributes)
+	assert.Equal(t, EmailSettings().SortableAttributes, settings.SortableAttributes)
+	assert.Equal(t, SchemaVersion, versions.version(t))
+
+	// Once current, nothing is changed
+	tasks := len(server.Tasks())
+	require.NoError(t, manager.Ensure(context.Background(), nil))
+	assert.Len(t, server.Tasks(), tasks)
+}
+
+// TestEnsureMigratesOutdatedIndex verifies an older schema is rebuilt and swapped in with its documents
+func TestEnsureMigratesOutdatedIndex(t *testing.T) {
+	manager, server, versions := newTestManager(t)
+	old := meilisearch.Settings{SearchableAttributes: []string{"subject"}}
+	server.AddIndex(EmailsIndex, PrimaryKey, old,
+		map[string]any{"id": "e1", "subject": "Invoice"},
+		map[string]any{"id": "e2", "subject": "Contract"},
+	)
+	require.NoError(t, versions.Set(context.Background(), models.SettingSearchSchemaVersion, SchemaVersion-1))
+
+	require.NoError(t, manager.Ensure(context.Background(), nil))
+	assert.Equal(t, 1, server.Swaps())
+	assert.Equal(t, []string{EmailsIndex}, server.Indexes(), "the previous index is deleted")
+	settings, _ := server.Settings(EmailsIndex)
+	assert.Equal(t, EmailSettings().SearchableAttributes, settings.SearchableAttributes)
+	assert.Len(t, server.Documents(EmailsIndex), 2)
+	assert.Equal(t, SchemaVersion, versions.version(t))
+}
+
+// TestEnsureUsesFiller verifies a filler replaces the document copy and its failure keeps the live index
+func TestEnsureUsesFiller(t *testing.T) {
+	manager, server, _ := newTestManager(t)
+	server.AddIndex(EmailsIndex, PrimaryKey, meilisearch.Settings{}, map[string]any{"id": "stale"})
+
+	failing := func(context.Context, string) error { return assert.AnError }
+	require.ErrorIs(t, manager.Ensure(context.Background(), failing), assert.AnError)
+	assert.Zero(t, server.Swaps())
+	assert.Contains(t, server.Documents(EmailsIndex), "stale")
+
+	var filled string
+	fill := func(ctx context.Context, uid string) error {
+		filled = uid
+		primaryKey := PrimaryKey
+		task, err := manager.client.Index(uid).AddDocumentsWithContext(ctx, []EmailDocument{{ID: "fresh"}}, &primaryKey)
+		return manager.wait(ctx, task, err)
+	}
+	require.NoError(t, manager.Ensure(context.Background(), fill))
+	assert.Equal(t, fmt.Sprintf("%s_v%d", EmailsIndex, SchemaVersion), filled)
+	docs := server.Documents(EmailsIndex)
+	assert.Contains(t, docs, "fresh")
+	assert.NotContains(t, docs, "stale")
+	assert.Equal(t, []string{EmailsIndex}, server.Indexes())
+}
+
+// TestEnsureKeepsNewerSchema verifies an index migrated by a newer release is not downgraded
+func TestEnsureKeepsNewerSchema(t *testing.T) {
+	manager, server, versions := newTestManager(t)
+	server.AddIndex(EmailsIndex, PrimaryKey, meilisearch.Settings{})
+	require.NoError(t, versions.Set(context.Background(), models.SettingSearchSchemaVersion, SchemaVersion+1))
+
+	require.NoError(t, manager.Ensure(context.Background(), nil))
+	assert.Empty(t, server.Tasks())
+	assert.Equal(t, SchemaVersion+1, versions.version(t))
+}
+
+// TestEnsureConcurrentReplicas verifies replicas starting together create the index once
+func TestEnsureConcurrentReplicas(t *testing.T) {
+	manager, server, _ := newTestManager(t)
+	replica := *manager
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, m := range []*Manager{manager, &replica} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = m.Ensure(context.Background(), nil)
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, errs[0])
+	require.NoError(t, errs[1])
+	for _, task := range server.Tasks() {
+		assert.Equal(t, "succeeded", task.Status, task.Type)
+	}
+}
+
+// TestNewEmailDocument verifies the mapping of an email to its document
+func TestNewEmailDocument(t *testing.T) {
+	subject, sender := "Quarterly report: Q3/Q4", "Alice@Mail.Example.com"
+	body := "Zahlen für Q3 " + string(make([]byte, MaxBodyBytes))
+	sentAt := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	report := "Revenue grew " + strings.Repeat("x", MaxAttachmentTextBytes)
+	doc := NewEmailDocument(&models.Email{
+		ID: "e1", MailboxID: "m1", MessageID: "<m1@example.com>", Subject: &subject, Sender: &sender,
+		Recipients: []string{"bob@example.com", "Bob@example.com"}, SentAt: sentAt, BodyText: &body, HasAttachments: true,
+		SizeBytes: 2048, Categories: []string{"Legal"},
+	}, "t1", []models.Attachment{{ID: "a1", Filename: "Q3-Report.pdf", ExtractedText: &report}, {ID: "a2", Filename: "notes"}})
+
+	assert.Equal(t, "t1", doc.TenantID)
+	assert.Equal(t, "m1", doc.MailboxID)
+	assert.Equal(t, subject, doc.Subject)
+	assert.Equal(t, sender, doc.Sender)
+	assert.Equal(t, []string{"bob@example.com", "Bob@example.com"}, doc.Recipients)
+	assert.Equal(t, sentAt.Unix(), doc.SentAt)
+	assert.True(t, doc.HasAttachments)
+	assert.Len(t, doc.BodyText, MaxBodyBytes)
+
+	// Derived terms back the query operators
+	assert.Equal(t, []string{"alice@mail.example.com", "alice@", "alice", "@mail.example.com", "mail.example.com",
+		"@example.com", "example.com"}, doc.SenderTerms)
+	assert.Equal(t, []string{"bob@example.com", "bob@", "bob", "@example.com", "example.com"}, doc.RecipientTerms)
+	assert.Equal(t, []string{"quarterly", "report", "q3", "q4"}, doc.SubjectWords)
+	assert.Equal(t, []string{"Q3-Report.pdf", "notes"}, doc.AttachmentNames)
+	assert.Equal(t, []string{"q3-report.pdf", "q3-report", "pdf", "q3", "report", "notes"}, doc.AttachmentTerms)
+	assert.Equal(t, []string{"legal"}, doc.Labels)
+	assert.Equal(t, []string{"zahlen", "für", "q3", "revenue", "grew"}, doc.TextWords, "Overlong words are left out")
+
+	// Attachment text shares one budget in attachment order
+	require.Len(t, doc.Attachments, 2)
+	assert.Equal(t, "a1", doc.Attachments[0].ID)
+	assert.Len(t, doc.Attachments[0].Text, MaxAttachmentTextBytes)
+	assert.Equal(t, AttachmentDocument{ID: "a2", Filename: "notes"}, doc.Attachments[1])
+
+	assert.Equal(t, "Zahlen f", truncate("Zahlen für", 9), "multi-byte runes are not split")
+}
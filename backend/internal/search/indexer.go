@@ -0,0 +1,357 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/meilisearch/meilisearch-go"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+)
+
+// indexLock lets one replica at a time push pending emails
+const indexLock = "ironarchive:search:index"
+
+// catchUpMargin widens the window of emails re-indexed after a rebuild to
+// cover clock skew between the backend and PostgreSQL
+const catchUpMargin = time.Minute
+
+// IndexerConfig tunes the indexer
+type IndexerConfig struct {
+	// BatchSize is the number of emails pushed per Meilisearch task (default 500)
+	BatchSize int
+	// Interval is the pause between passes once nothing is pending (default 10s)
+	Interval time.Duration
+	// MaxRetries is how often a failed Meilisearch task is retried (default 3)
+	MaxRetries int
+	// RetryDelay is the wait before the first retry, doubling per retry (default 1s)
+	RetryDelay time.Duration
+}
+
+// Indexer pushes new, changed and deleted emails to the live index and
+// records emails.indexed_at once Meilisearch has processed them
+type Indexer struct {
+	emails      repositories.EmailRepository
+	attachments repositories.AttachmentRepository
+	mailboxes   repositories.MailboxRepository
+	deletions   repositories.SearchDeletionRepository
+	manager     *Manager
+	cfg         IndexerConfig
+	logger      *zap.Logger
+	now         func() time.Time
+
+	mu sync.Mutex
+	// tenants caches the tenant ID of each mailbox; mailboxes never move
+	tenants map[string]string
+}
+
+// NewIndexer creates an indexer for the index of manager
+func NewIndexer(repos *repositories.Repositories, manager *Manager, cfg IndexerConfig, logger *zap.Logger) *Indexer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = time.Second
+	}
+	return &Indexer{
+		emails:      repos.Emails,
+		attachments: repos.Attachments,
+		mailboxes:   repos.Mailboxes,
+		deletions:   repos.SearchDeletions,
+		manager:     manager,
+		cfg:         cfg,
+		logger:      logger,
+		now:         time.Now,
+		tenants:     make(map[string]string),
+	}
+}
+
+// Run prepares the live index, then indexes pending emails until ctx is
+// cancelled. Every replica runs one; a Redis lock lets one push at a time.
+func (i *Indexer) Run(ctx context.Context) {
+	// Documents written to a missing index would create it without settings
+	for {
+		err := i.ensure(ctx)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		i.logger.Error("Failed to prepare search index", zap.Error(err))
+		if !sleep(ctx, i.cfg.Interval) {
+			return
+		}
+	}
+
+	i.logger.Info("Search indexer started", zap.String("index", i.manager.Index()), zap.Int("batch_size", i.cfg.BatchSize))
+	for {
+		n, err := i.IndexPending(ctx)
+		if err != nil && ctx.Err() == nil {
+			i.logger.Error("Search indexing failed", zap.Error(err))
+		}
+		// A full batch means more is pending
+		if err == nil && n >= i.cfg.BatchSize {
+			continue
+		}
+		if !sleep(ctx, i.cfg.Interval) {
+			i.logger.Info("Search indexer stopped")
+			return
+		}
+	}
+}
+
+// ensure creates or migrates the index, refilling a rebuilt one from the
+// archive and catching up with emails changed while it was built
+func (i *Indexer) ensure(ctx context.Context) error {
+	_, err := i.catchUp(ctx, func() error { return i.manager.Ensure(ctx, i.Fill) })
+	return err
+}
+
+// IndexPending pushes one batch of queued deletions and one batch of
+// unindexed emails and returns how many emails were handled. It does nothing
+// while another replica is indexing.
+func (i *Indexer) IndexPending(ctx context.Context) (int, error) {
+	release, acquired, err := tryLock(ctx, i.manager.redis, indexLock, i.logger)
+	if err != nil || !acquired {
+		return 0, err
+	}
+	defer release()
+
+	if err := i.removeDeleted(ctx); err != nil {
+		return 0, err
+	}
+
+	emails, err := i.emails.ListUnindexed(ctx, i.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list unindexed emails: %w", err)
+	}
+	if len(emails) == 0 {
+		return 0, nil
+	}
+
+	live := make([]models.Email, 0, len(emails))
+	var removed []string
+	for _, email := range emails {
+		if email.DeletedAt != nil {
+			removed = append(removed, email.ID)
+			continue
+		}
+		live = append(live, email)
+	}
+	docs, err := i.documents(ctx, live)
+	if err != nil {
+		return 0, err
+	}
+	uid := i.manager.Index()
+	if err := i.addDocuments(ctx, uid, docs); err != nil {
+		return 0, err
+	}
+	if err := i.deleteDocuments(ctx, uid, removed); err != nil {
+		return 0, err
+	}
+
+	marked, err := i.emails.MarkIndexed(ctx, emails, i.now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark emails indexed: %w", err)
+	}
+	i.logger.Debug("Indexed emails", zap.Int("documents", len(docs)), zap.Int("removed", len(removed)),
+		zap.Int64("marked", marked))
+	return len(emails), nil
+}
+
+// removeDeleted removes the documents of hard-deleted emails
+func (i *Indexer) removeDeleted(ctx context.Context) error {
+	ids, err := i.deletions.List(ctx, i.cfg.BatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to list search deletions: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := i.deleteDocuments(ctx, i.manager.Index(), ids); err != nil {
+		return err
+	}
+	if err := i.deletions.Delete(ctx, ids); err != nil {
+		return fmt.Errorf("failed to clear search deletions: %w", err)
+	}
+	return nil
+}
+
+// Fill adds the documents of all live emails to the index uid; it is the
+// Filler of index builds
+func (i *Indexer) Fill(ctx context.Context, uid string) error {
+	after := ""
+	total := 0
+	for {
+		emails, err := i.emails.ListAfter(ctx, repositories.EmailFilter{}, after, i.cfg.BatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to list emails: %w", err)
+		}
+		if len(emails) == 0 {
+			break
+		}
+		docs, err := i.documents(ctx, emails)
+		if err != nil {
+			return err
+		}
+		if err := i.addDocuments(ctx, uid, docs); err != nil {
+			return err
+		}
+		after = emails[len(emails)-1].ID
+		total += len(emails)
+	}
+	i.logger.Info("Search index filled", zap.String("index", uid), zap.Int("documents", total))
+	return nil
+}
+
+// Reindex rebuilds the search documents of one tenant, or of the whole
+// archive when tenantID is nil, while search stays available. A tenant's
+// emails are flagged and re-pushed by the running indexers; the whole
+// archive is rebuilt into a new index that is swapped in, which also drops
+// documents without an email. It returns the number of flagged emails.
+func (i *Indexer) Reindex(ctx context.Context, tenantID *string) (int64, error) {
+	if tenantID != nil {
+		n, err := i.emails.ResetIndexed(ctx, repositories.ReindexFilter{TenantID: tenantID})
+		if err != nil {
+			return 0, fmt.Errorf("failed to flag emails for reindexing: %w", err)
+		}
+		i.logger.Info("Tenant emails flagged for reindexing", zap.String("tenant_id", *tenantID), zap.Int64("emails", n))
+		return n, nil
+	}
+
+	return i.catchUp(ctx, func() error { return i.manager.Rebuild(ctx, i.Fill) })
+}
+
+// catchUp runs build and then flags emails changed since it started, whose
+// updates may have gone to the previous index; it returns how many it flagged
+func (i *Indexer) catchUp(ctx context.Context, build func() error) (int64, error) {
+	since := i.now().UTC().Add(-catchUpMargin)
+	if err := build(); err != nil {
+		return 0, err
+	}
+	n, err := i.emails.ResetIndexed(ctx, repositories.ReindexFilter{ChangedSince: &since})
+	if err != nil {
+		return 0, fmt.Errorf("failed to flag recently changed emails: %w", err)
+	}
+	return n, nil
+}
+
+// tenantOf returns the tenant ID of a mailbox
+func (i *Indexer) tenantOf(ctx context.Context, mailboxID string) (string, error) {
+	i.mu.Lock()
+	tenantID, ok := i.tenants[mailboxID]
+	i.mu.Unlock()
+	if ok {
+		return tenantID, nil
+	}
+	mailbox, err := i.mailboxes.GetByID(ctx, mailboxID)
+	if err != nil {
+		return "", fmt.Errorf("failed to load mailbox %s: %w", mailboxID, err)
+	}
+	i.mu.Lock()
+	i.tenants[mailboxID] = mailbox.TenantID
+	i.mu.Unlock()
+	return mailbox.TenantID, nil
+}
+
+// documents builds the search documents of emails
+func (i *Indexer) documents(ctx context.Context, emails []models.Email) ([]EmailDocument, error) {
+	ids := make([]string, len(emails))
+	for idx := range emails {
+		ids[idx] = emails[idx].ID
+	}
+	attachments, err := i.attachments.ListByEmails(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+	byEmail := make(map[string][]models.Attachment)
+	for _, a := range attachments {
+		byEmail[a.EmailID] = append(byEmail[a.EmailID], a)
+	}
+
+	docs := make([]EmailDocument, len(emails))
+	for idx := range emails {
+		tenantID, err := i.tenantOf(ctx, emails[idx].MailboxID)
+		if err != nil {
+			return nil, err
+		}
+		docs[idx] = NewEmailDocument(&emails[idx], tenantID, byEmail[emails[idx].ID])
+	}
+	return docs, nil
+}
+
+// addDocuments adds or replaces documents in an index
+func (i *Indexer) addDocuments(ctx context.Context, uid string, docs []EmailDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	primaryKey := PrimaryKey
+	return i.retry(ctx, "add documents", func() error {
+		task, err := i.manager.client.Index(uid).AddDocumentsWithContext(ctx, docs, &primaryKey)
+		return i.manager.wait(ctx, task, err)
+	})
+}
+
+// deleteDocuments removes documents from an index; unknown IDs are ignored
+func (i *Indexer) deleteDocuments(ctx context.Context, uid string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return i.retry(ctx, "delete documents", func() error {
+		task, err := i.manager.client.Index(uid).DeleteDocumentsWithContext(ctx, ids)
+		return i.manager.wait(ctx, task, err)
+	})
+}
+
+// retry runs a Meilisearch call until it succeeds or MaxRetries retries
+// failed, backing off exponentially
+func (i *Indexer) retry(ctx context.Context, op string, call func() error) error {
+	delay := i.cfg.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err := call()
+		if err == nil {
+			return nil
+		}
+		if attempt >= i.cfg.MaxRetries || ctx.Err() != nil || !retryable(err) {
+			return fmt.Errorf("failed to %s after %d attempts: %w", op, attempt+1, err)
+		}
+		i.logger.Warn("Meilisearch task failed, retrying", zap.String("operation", op), zap.Int("attempt", attempt+1),
+			zap.Duration("delay", delay), zap.Error(err))
+		if !sleep(ctx, delay) {
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+// retryable reports whether a Meilisearch failure may succeed when repeated;
+// rejected requests fail the same way again
+func retryable(err error) bool {
+	var apiErr *meilisearch.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 && apiErr.StatusCode != 429 {
+		return false
+	}
+	return true
+}
+
+// sleep waits for d and reports false if ctx ended first
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
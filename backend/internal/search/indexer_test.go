@@ -0,0 +1,320 @@
+package search
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+	"ironarchive/internal/search/searchtest"
+)
+
+// fakeEmails keeps emails in memory with the indexed_at semantics of the
+// email repository
+type fakeEmails struct {
+	repositories.EmailRepository
+	mu     sync.Mutex
+	emails map[string]*models.Email
+	resets []repositories.ReindexFilter
+}
+
+func (f *fakeEmails) add(email models.Email) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.emails[email.ID] = &email
+}
+
+func (f *fakeEmails) sorted() []*models.Email {
+	emails := make([]*models.Email, 0, len(f.emails))
+	for _, e := range f.emails {
+		emails = append(emails, e)
+	}
+	sort.Slice(emails, func(a, b int) bool { return emails[a].ID < emails[b].ID })
+	return emails
+}
+
+func (f *fakeEmails) ListAfter(_ context.Context, _ repositories.EmailFilter, afterID string, limit int) ([]models.Email, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []models.Email
+	for _, e := range f.sorted() {
+		if e.ID > afterID && e.DeletedAt == nil && len(out) < limit {
+			out = append(out, *e)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeEmails) ListUnindexed(_ context.Context, limit int) ([]models.Email, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []models.Email
+	for _, e := range f.sorted() {
+		if e.IndexedAt == nil && len(out) < limit {
+			out = append(out, *e)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeEmails) MarkIndexed(_ context.Context, emails []models.Email, indexedAt time.Time) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var n int64
+	for _, e := range emails {
+		if stored, ok := f.emails[e.ID]; ok && stored.UpdatedAt.Equal(e.UpdatedAt) {
+			stored.IndexedAt = &indexedAt
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeEmails) ResetIndexed(_ context.Context, filter repositories.ReindexFilter) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resets = append(f.resets, filter)
+	var n int64
+	for _, e := range f.emails {
+		if e.IndexedAt != nil && (filter.ChangedSince == nil || !e.UpdatedAt.Before(*filter.ChangedSince)) {
+			e.IndexedAt = nil
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeEmails) indexed(id string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.emails[id].IndexedAt != nil
+}
+
+type fakeMailboxes struct {
+	repositories.MailboxRepository
+	mailboxes []models.Mailbox
+}
+
+func (f *fakeMailboxes) GetByID(_ context.Context, id string) (*models.Mailbox, error) {
+	for i := range f.mailboxes {
+		if f.mailboxes[i].ID == id {
+			return &f.mailboxes[i], nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+type fakeAttachments struct {
+	repositories.AttachmentRepository
+	attachments []models.Attachment
+}
+
+func (f *fakeAttachments) ListByEmails(_ context.Context, emailIDs []string) ([]models.Attachment, error) {
+	var out []models.Attachment
+	for _, a := range f.attachments {
+		if slices.Contains(emailIDs, a.EmailID) {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+type fakeDeletions struct {
+	repositories.SearchDeletionRepository
+	mu  sync.Mutex
+	ids []string
+}
+
+func (f *fakeDeletions) List(_ context.Context, limit int) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.ids[:min(limit, len(f.ids))]...), nil
+}
+
+func (f *fakeDeletions) Delete(_ context.Context, ids []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ids = f.ids[len(ids):]
+	return nil
+}
+
+const (
+	testTenant  = "11111111-1111-1111-1111-111111111111"
+	testMailbox = "22222222-2222-2222-2222-222222222222"
+)
+
+// newTestIndexer creates an indexer with in-memory repositories and an
+// existing live index
+func newTestIndexer(t *testing.T) (*Indexer, *fakeEmails, *fakeDeletions, *Manager, *searchtest.Server) {
+	t.Helper()
+	manager, server, _ := newTestManager(t)
+	require.NoError(t, manager.Ensure(context.Background(), nil))
+
+	emails := &fakeEmails{emails: make(map[string]*models.Email)}
+	deletions := &fakeDeletions{}
+	repos := &repositories.Repositories{
+		Emails:          emails,
+		Attachments:     &fakeAttachments{},
+		Mailboxes:       &fakeMailboxes{mailboxes: []models.Mailbox{{ID: testMailbox, TenantID: testTenant}}},
+		SearchDeletions: deletions,
+	}
+	indexer := NewIndexer(repos, manager, IndexerConfig{BatchSize: 2, Interval: 10 * time.Millisecond, MaxRetries: 2, RetryDelay: time.Millisecond}, zap.NewNop())
+	return indexer, emails, deletions, manager, server
+}
+
+func testEmail(id string) models.Email {
+	subject := "Subject " + id
+	return models.Email{ID: id, MailboxID: testMailbox, Subject: &subject, UpdatedAt: time.Now().UTC()}
+}
+
+// TestIndexPending verifies unindexed emails are pushed in batches and marked indexed
+func TestIndexPending(t *testing.T) {
+	indexer, emails, _, manager, server := newTestIndexer(t)
+	for _, id := range []string{"a", "b", "c"} {
+		emails.add(testEmail(id))
+	}
+	indexer.attachments = &fakeAttachments{attachments: []models.Attachment{{EmailID: "a", Filename: "Contract.PDF"}}}
+
+	n, err := indexer.IndexPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	n, err = indexer.IndexPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = indexer.IndexPending(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	docs := server.Documents(manager.Index())
+	require.Len(t, docs, 3)
+	assert.Equal(t, testTenant, docs["a"]["tenant_id"])
+	assert.Equal(t, "Subject c", docs["c"]["subject"])
+	assert.Equal(t, []any{"Contract.PDF"}, docs["a"]["attachment_names"])
+	assert.Equal(t, []any{}, docs["b"]["attachment_names"])
+	for _, id := range []string{"a", "b", "c"} {
+		assert.True(t, emails.indexed(id), id)
+	}
+}
+
+// TestIndexPendingRemovesDeleted verifies soft-deleted and hard-deleted emails lose their documents
+func TestIndexPendingRemovesDeleted(t *testing.T) {
+	indexer, emails, deletions, manager, server := newTestIndexer(t)
+	emails.add(testEmail("a"))
+	emails.add(testEmail("b"))
+	_, err := indexer.IndexPending(context.Background())
+	require.NoError(t, err)
+	require.Len(t, server.Documents(manager.Index()), 2)
+
+	// Soft delete a, hard delete b
+	deletedAt := time.Now().UTC()
+	softDeleted := testEmail("a")
+	softDeleted.DeletedAt = &deletedAt
+	emails.add(softDeleted)
+	emails.mu.Lock()
+	delete(emails.emails, "b")
+	emails.mu.Unlock()
+	deletions.ids = []string{"b"}
+
+	_, err = indexer.IndexPending(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, server.Documents(manager.Index()))
+	assert.Empty(t, deletions.ids)
+	assert.True(t, emails.indexed("a"))
+}
+
+// TestIndexPendingRetries verifies failed tasks are retried and exhausted retries leave emails pending
+func TestIndexPendingRetries(t *testing.T) {
+	indexer, emails, _, manager, server := newTestIndexer(t)
+	emails.add(testEmail("a"))
+
+	server.FailTasks(2)
+	_, err := indexer.IndexPending(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, server.Documents(manager.Index()), 1)
+	assert.True(t, emails.indexed("a"))
+
+	emails.add(testEmail("b"))
+	server.FailTasks(3)
+	_, err = indexer.IndexPending(context.Background())
+	require.Error(t, err)
+	assert.False(t, emails.indexed("b"))
+}
+
+// TestReindexTenant verifies a tenant reindex flags the tenant's emails without rebuilding the index
+func TestReindexTenant(t *testing.T) {
+	indexer, emails, _, _, _ := newTestIndexer(t)
+	emails.add(testEmail("a"))
+	_, err := indexer.IndexPending(context.Background())
+	require.NoError(t, err)
+
+	tenantID := testTenant
+	n, err := indexer.Reindex(context.Background(), &tenantID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	require.Len(t, emails.resets, 1)
+	assert.Equal(t, &tenantID, emails.resets[0].TenantID)
+	assert.False(t, emails.indexed("a"))
+}
+
+// TestReindexAll verifies a full reindex rebuilds the index from the archive and drops stale documents
+func TestReindexAll(t *testing.T) {
+	indexer, emails, _, manager, server := newTestIndexer(t)
+	emails.add(testEmail("a"))
+	emails.add(testEmail("b"))
+	emails.add(testEmail("c"))
+	for {
+		n, err := indexer.IndexPending(context.Background())
+		require.NoError(t, err)
+		if n == 0 {
+			break
+		}
+	}
+	// A document whose email vanished without a queued deletion
+	emails.mu.Lock()
+	delete(emails.emails, "c")
+	emails.mu.Unlock()
+
+	_, err := indexer.Reindex(context.Background(), nil)
+	require.NoError(t, err)
+
+	docs := server.Documents(manager.Index())
+	assert.Len(t, docs, 2)
+	assert.NotContains(t, docs, "c")
+	assert.Equal(t, 1, server.Swaps())
+	require.Len(t, emails.resets, 1)
+	assert.NotNil(t, emails.resets[0].ChangedSince)
+}
+
+// TestRunCreatesAndFillsIndex verifies Run creates a missing index and indexes pending emails
+func TestRunCreatesAndFillsIndex(t *testing.T) {
+	manager, server, _ := newTestManager(t)
+	emails := &fakeEmails{emails: make(map[string]*models.Email)}
+	emails.add(testEmail("a"))
+	repos := &repositories.Repositories{
+		Emails:          emails,
+		Attachments:     &fakeAttachments{},
+		Mailboxes:       &fakeMailboxes{mailboxes: []models.Mailbox{{ID: testMailbox, TenantID: testTenant}}},
+		SearchDeletions: &fakeDeletions{},
+	}
+	indexer := NewIndexer(repos, manager, IndexerConfig{Interval: 5 * time.Millisecond}, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		indexer.Run(ctx)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return emails.indexed("a") }, 5*time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Len(t, server.Documents(EmailsIndex), 1)
+}
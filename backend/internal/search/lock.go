@@ -0,0 +1,71 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// lockTTL expires the lock of a crashed replica; holders refresh it
+const lockTTL = time.Minute
+
+// releaseScript deletes a lock only if this replica still holds it
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// waitLock takes a Redis lock, polling every retry while another replica
+// holds it. The lock is kept alive until the returned release is called.
+func waitLock(ctx context.Context, rdb *redis.Client, key string, retry time.Duration, logger *zap.Logger) (func(), error) {
+	for {
+		release, acquired, err := tryLock(ctx, rdb, key, logger)
+		if err != nil || acquired {
+			return release, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retry):
+		}
+	}
+}
+
+// tryLock takes a Redis lock if it is free and keeps it alive until the
+// returned release is called
+func tryLock(ctx context.Context, rdb *redis.Client, key string, logger *zap.Logger) (func(), bool, error) {
+	token := uuid.NewString()
+	acquired, err := rdb.SetNX(ctx, key, token, lockTTL).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to take lock %s: %w", key, err)
+	}
+	if !acquired {
+		return nil, false, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(lockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				rdb.Expire(context.WithoutCancel(ctx), key, lockTTL)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		if err := releaseScript.Run(context.WithoutCancel(ctx), rdb, []string{key}, token).Err(); err != nil {
+			logger.Warn("Failed to release lock", zap.String("key", key), zap.Error(err))
+		}
+	}, true, nil
+}
@@ -0,0 +1,253 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meilisearch/meilisearch-go"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/access"
+)
+
+// meiliBackend searches the Meilisearch emails index
+type meiliBackend struct {
+	client       meilisearch.ServiceManager
+	url          string
+	tenantTokens bool
+	tokenTTL     time.Duration
+	logger       *zap.Logger
+	now          func() time.Time
+
+	mu sync.Mutex
+	// key is the API key tenant tokens are derived from
+	key    *meilisearch.Key
+	tokens map[access.Scope]tenantToken
+}
+
+// tenantToken is a client authenticated with a tenant token of one scope
+type tenantToken struct {
+	client    meilisearch.ServiceManager
+	expiresAt time.Time
+}
+
+// Search runs req against the emails index
+func (b *meiliBackend) Search(ctx context.Context, scope access.Scope, req *ParsedRequest) (*Result, error) {
+	result := &Result{Hits: []Hit{}, Backend: BackendMeilisearch}
+	if req.Offset >= MaxTotalHits {
+		return result, nil
+	}
+	client, err := b.clientFor(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	search := meiliRequest(scope, req)
+	resp, err := client.Index(EmailsIndex).SearchWithContext(ctx, search.Query, search)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search emails: %w", err)
+	}
+
+	for _, raw := range resp.Hits {
+		hit, err := decodeHit(raw)
+		if err != nil {
+			return nil, err
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+	if len(resp.FacetDistribution) > 0 {
+		if err := json.Unmarshal(resp.FacetDistribution, &result.Facets); err != nil {
+			return nil, fmt.Errorf("failed to decode facets: %w", err)
+		}
+	}
+	result.EstimatedTotalHits = resp.EstimatedTotalHits
+	result.ProcessingTimeMs = resp.ProcessingTimeMs
+	result.NextCursor = nextCursor(req, len(result.Hits), resp.EstimatedTotalHits)
+	return result, nil
+}
+
+// meiliRequest returns the Meilisearch request of req within scope
+func meiliRequest(scope access.Scope, req *ParsedRequest) *meilisearch.SearchRequest {
+	clauses := []string{}
+	if f := scopeFilter(scope); f != "" {
+		clauses = append(clauses, f)
+	}
+	if req.filter != "" {
+		clauses = append(clauses, req.filter)
+	}
+	if req.MailboxID != "" {
+		clauses = append(clauses, "mailbox_id = "+quote(req.MailboxID))
+	}
+	if req.Sender != "" {
+		clauses = append(clauses, "sender = "+quote(req.Sender))
+	}
+	if req.HasAttachments != nil {
+		clauses = append(clauses, "has_attachments = "+strconv.FormatBool(*req.HasAttachments))
+	}
+	if req.SentAfter != nil {
+		clauses = append(clauses, "sent_at >= "+strconv.FormatInt(req.SentAfter.Unix(), 10))
+	}
+	if req.SentBefore != nil {
+		clauses = append(clauses, "sent_at < "+strconv.FormatInt(req.SentBefore.Unix(), 10))
+	}
+
+	var sort []string
+	switch req.Sort {
+	case SortNewest:
+		sort = []string{"sent_at:desc"}
+	case SortOldest:
+		sort = []string{"sent_at:asc"}
+	}
+	search := &meilisearch.SearchRequest{
+		Query:                 req.text,
+		Offset:                req.Offset,
+		Limit:                 int64(req.Limit),
+		Sort:                  sort,
+		Facets:                req.Facets,
+		AttributesToHighlight: []string{"subject", "sender", "recipients", "attachments.text"},
+		AttributesToCrop:      []string{"body_text", "attachments.text"},
+		CropLength:            snippetWords,
+		HighlightPreTag:       HighlightPreTag,
+		HighlightPostTag:      HighlightPostTag,
+		// Every word has to match, like the terms of the query language
+		MatchingStrategy: meilisearch.All,
+	}
+	if len(clauses) > 0 {
+		search.Filter = strings.Join(clauses, " AND ")
+	}
+	if req.Collapse == CollapseThread {
+		search.Distinct = "thread_id"
+	}
+	return search
+}
+
+// clientFor returns the client searches of scope run with
+func (b *meiliBackend) clientFor(ctx context.Context, scope access.Scope) (meilisearch.ServiceManager, error) {
+	if !b.tenantTokens {
+		return b.client, nil
+	}
+
+	// Tokens carry the filter only, so callers with the same scope share one
+	scope = access.Scope{TenantID: scope.TenantID, MailboxID: scope.MailboxID}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	// Renew tokens halfway through their lifetime so none expires mid-search
+	if token, ok := b.tokens[scope]; ok && now.Before(token.expiresAt.Add(-b.tokenTTL/2)) {
+		return token.client, nil
+	}
+	key, err := b.searchKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := map[string]any{}
+	if f := scopeFilter(scope); f != "" {
+		rules["filter"] = f
+	}
+	expiresAt := now.Add(b.tokenTTL)
+	token, err := b.client.GenerateTenantToken(key.UID, map[string]any{EmailsIndex: rules},
+		&meilisearch.TenantTokenOptions{APIKey: key.Key, ExpiresAt: expiresAt})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tenant token: %w", err)
+	}
+	if len(b.tokens) >= maxCachedTokens {
+		clear(b.tokens)
+	}
+	client := meilisearch.New(b.url, meilisearch.WithAPIKey(token))
+	b.tokens[scope] = tenantToken{client: client, expiresAt: expiresAt}
+	return client, nil
+}
+
+// searchKey returns the search-only API key of the emails index, creating
+// it on first use; the caller holds b.mu
+func (b *meiliBackend) searchKey(ctx context.Context) (*meilisearch.Key, error) {
+	if b.key != nil {
+		return b.key, nil
+	}
+	keys, err := b.client.GetKeysWithContext(ctx, &meilisearch.KeysQuery{Limit: 1000})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	for i := range keys.Results {
+		if keys.Results[i].Name == searchKeyName {
+			b.key = &keys.Results[i]
+			return b.key, nil
+		}
+	}
+	key, err := b.client.CreateKeyWithContext(ctx, &meilisearch.Key{
+		Name:        searchKeyName,
+		Description: "Signs IronArchive tenant tokens",
+		Actions:     []string{"search"},
+		Indexes:     []string{EmailsIndex},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create search API key: %w", err)
+	}
+	b.logger.Info("Created Meilisearch search key for tenant tokens", zap.String("key_uid", key.UID))
+	b.key = key
+	return key, nil
+}
+
+// decodeHit converts a Meilisearch hit into a Hit
+func decodeHit(raw meilisearch.Hit) (Hit, error) {
+	var doc struct {
+		EmailDocument
+		Formatted struct {
+			Subject     string               `json:"subject"`
+			Sender      string               `json:"sender"`
+			Recipients  []string             `json:"recipients"`
+			BodyText    string               `json:"body_text"`
+			Attachments []AttachmentDocument `json:"attachments"`
+		} `json:"_formatted"`
+	}
+	if err := raw.Decode(&doc); err != nil {
+		return Hit{}, fmt.Errorf("failed to decode search hit: %w", err)
+	}
+	recipients := doc.Recipients
+	if recipients == nil {
+		recipients = []string{}
+	}
+	// Cropped attachment text is highlighted where the query matched
+	attachments := []AttachmentHit{}
+	for _, a := range doc.Formatted.Attachments {
+		if strings.Contains(a.Text, HighlightPreTag) {
+			attachments = append(attachments, AttachmentHit{ID: a.ID, Filename: a.Filename, Snippet: a.Text})
+		}
+	}
+	return Hit{
+		ID:             doc.ID,
+		TenantID:       doc.TenantID,
+		MailboxID:      doc.MailboxID,
+		MessageID:      doc.MessageID,
+		Subject:        doc.Subject,
+		Sender:         doc.Sender,
+		Recipients:     recipients,
+		SentAt:         time.Unix(doc.SentAt, 0).UTC(),
+		HasAttachments: doc.HasAttachments,
+		SizeBytes:      doc.SizeBytes,
+		ThreadID:       doc.ThreadID,
+		Highlights: Highlights{
+			Subject:    doc.Formatted.Subject,
+			Sender:     doc.Formatted.Sender,
+			Recipients: doc.Formatted.Recipients,
+			Snippet:    doc.Formatted.BodyText,
+		},
+		Attachments: attachments,
+	}, nil
+}
+
+// unavailable reports whether a failed search may succeed on the PostgreSQL
+// fallback: Meilisearch is unreachable, failing or has no emails index
+func unavailable(err error) bool {
+	var apiErr *meilisearch.Error
+	if errors.As(err, &apiErr) && apiErr.MeilisearchApiError.Code == "index_not_found" {
+		return true
+	}
+	return retryable(err)
+}
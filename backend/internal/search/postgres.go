@@ -0,0 +1,101 @@
+package search
+
+import (
+	"context"
+	"time"
+
+	"ironarchive/internal/access"
+	"ironarchive/internal/database/repositories"
+)
+
+// postgresBackend searches the emails table with PostgreSQL full-text search;
+// slower than Meilisearch but available whenever the archive is
+type postgresBackend struct {
+	sessions access.SessionRunner
+}
+
+// Search runs req against the emails table as the scope's session
+func (b *postgresBackend) Search(ctx context.Context, scope access.Scope, req *ParsedRequest) (*Result, error) {
+	started := time.Now()
+	result := &Result{Hits: []Hit{}, Backend: BackendPostgres}
+	if req.Offset >= MaxTotalHits {
+		return result, nil
+	}
+
+	filter := repositories.EmailFilter{
+		Sender:         req.Sender,
+		SentAfter:      req.SentAfter,
+		SentBefore:     req.SentBefore,
+		HasAttachments: req.HasAttachments,
+	}
+	if scope.TenantID != "" {
+		filter.TenantID = &scope.TenantID
+	}
+	mailboxID := scope.MailboxID
+	if req.MailboxID != "" {
+		if mailboxID != "" && mailboxID != req.MailboxID {
+			// Another mailbox is out of scope
+			return result, nil
+		}
+		mailboxID = req.MailboxID
+	}
+	if mailboxID != "" {
+		filter.MailboxID = &mailboxID
+	}
+
+	var found *repositories.EmailSearchResult
+	err := b.sessions.WithSession(ctx, scope.Session, func(repos *repositories.Repositories) error {
+		var err error
+		found, err = repos.Emails.Search(ctx, repositories.EmailSearch{
+			Filter:           filter,
+			Query:            req.Terms,
+			Sort:             req.Sort,
+			Collapse:         req.Collapse == CollapseThread,
+			Facets:           req.Facets,
+			Offset:           req.Offset,
+			Limit:            req.Limit,
+			MaxTotal:         MaxTotalHits,
+			HighlightPreTag:  HighlightPreTag,
+			HighlightPostTag: HighlightPostTag,
+			SnippetWords:     snippetWords,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, found := range found.Hits {
+		email := found.Email
+		recipients := nonNil(email.Recipients)
+		attachments := make([]AttachmentHit, len(found.Attachments))
+		for i, a := range found.Attachments {
+			attachments[i] = AttachmentHit{ID: a.ID, Filename: a.Filename, Snippet: a.Snippet}
+		}
+		result.Hits = append(result.Hits, Hit{
+			ID:             email.ID,
+			TenantID:       found.TenantID,
+			MailboxID:      email.MailboxID,
+			MessageID:      email.MessageID,
+			Subject:        deref(email.Subject),
+			Sender:         deref(email.Sender),
+			Recipients:     recipients,
+			SentAt:         email.SentAt.UTC(),
+			HasAttachments: email.HasAttachments,
+			SizeBytes:      email.SizeBytes,
+			ThreadID:       deref(email.ThreadID),
+			Highlights: Highlights{
+				Subject:    found.Subject,
+				Sender:     found.Sender,
+				Recipients: recipients,
+				Snippet:    found.Snippet,
+			},
+			Attachments: attachments,
+		})
+	}
+	result.Facets = found.Facets
+	result.EstimatedTotalHits = found.Total
+	result.ProcessingTimeMs = time.Since(started).Milliseconds()
+	result.NextCursor = nextCursor(req, len(result.Hits), found.Total)
+	return result, nil
+}
@@ -0,0 +1,154 @@
+// Package query parses Gmail-style search queries such as
+//
+//	from:ceo@ to:legal subject:"merger" has:attachment larger:5MB before:2024-01-01 -label:spam
+//
+// into a syntax tree that the search backends compile into their own query
+// languages. Positions are offsets in characters (not bytes) from the start
+// of the query.
+package query
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Fields matched by a Field node
+const (
+	FieldFrom     = "from"
+	FieldTo       = "to"
+	FieldCc       = "cc"
+	FieldBcc      = "bcc"
+	FieldSubject  = "subject"
+	FieldFilename = "filename"
+	FieldLabel    = "label"
+)
+
+// Words returns the distinct lower-cased words of s; subject: matches emails
+// whose subject contains every word of its value
+func Words(s string) []string {
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !slices.Contains(words, word) {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// Node is a node of a parsed query
+type Node interface {
+	// Pos is the position of the node in the query
+	Pos() int
+	// String renders the node in query syntax
+	String() string
+}
+
+// And matches emails matching all of its nodes; terms separated by spaces
+// (or AND) are joined by And
+type And struct {
+	Nodes []Node
+	At    int
+}
+
+// Or matches emails matching any of its nodes
+type Or struct {
+	Nodes []Node
+	At    int
+}
+
+// Not matches emails not matching its node (-term)
+type Not struct {
+	Node Node
+	At   int
+}
+
+// Text is a free-text word, or a quoted phrase if Phrase is set
+type Text struct {
+	Value  string
+	Phrase bool
+	At     int
+}
+
+// Field matches one of the Field* names against Value, e.g. from:ceo@
+type Field struct {
+	Name  string
+	Value string
+	// Phrase is set when the value was quoted
+	Phrase bool
+	At     int
+}
+
+// HasAttachment matches emails with attachments (has:attachment)
+type HasAttachment struct {
+	At int
+}
+
+// Size matches emails larger (larger:) or smaller (smaller:) than Bytes
+type Size struct {
+	Larger bool
+	Bytes  int64
+	At     int
+}
+
+// Date matches emails sent before Time (before:), or at or after it (after:)
+type Date struct {
+	Before bool
+	Time   time.Time
+	At     int
+}
+
+func (n *And) Pos() int           { return n.At }
+func (n *Or) Pos() int            { return n.At }
+func (n *Not) Pos() int           { return n.At }
+func (n *Text) Pos() int          { return n.At }
+func (n *Field) Pos() int         { return n.At }
+func (n *HasAttachment) Pos() int { return n.At }
+func (n *Size) Pos() int          { return n.At }
+func (n *Date) Pos() int          { return n.At }
+
+func (n *And) String() string { return "(" + join(n.Nodes, " ") + ")" }
+func (n *Or) String() string  { return "(" + join(n.Nodes, " OR ") + ")" }
+func (n *Not) String() string { return "-" + n.Node.String() }
+
+func (n *Text) String() string {
+	if n.Phrase {
+		return `"` + n.Value + `"`
+	}
+	return n.Value
+}
+
+func (n *Field) String() string {
+	if n.Phrase {
+		return n.Name + `:"` + n.Value + `"`
+	}
+	return n.Name + ":" + n.Value
+}
+
+func (n *HasAttachment) String() string { return "has:attachment" }
+
+func (n *Size) String() string {
+	if n.Larger {
+		return "larger:" + strconv.FormatInt(n.Bytes, 10)
+	}
+	return "smaller:" + strconv.FormatInt(n.Bytes, 10)
+}
+
+func (n *Date) String() string {
+	if n.Before {
+		return "before:" + n.Time.Format(time.DateOnly)
+	}
+	return "after:" + n.Time.Format(time.DateOnly)
+}
+
+func join(nodes []Node, sep string) string {
+	parts := make([]string, len(nodes))
+	for i, n := range nodes {
+		parts[i] = n.String()
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,359 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MaxLength caps the length of a query in characters
+const MaxLength = 2048
+
+// maxDepth bounds the nesting of parentheses and negations
+const maxDepth = 32
+
+// Error is a syntax error at a position of the query
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+func errorf(pos int, format string, args ...any) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenLParen
+	tokenRParen
+	tokenOr
+	tokenAnd
+	tokenNot
+	// tokenTerm is a word, phrase or operator term
+	tokenTerm
+)
+
+type token struct {
+	kind tokenKind
+	pos  int
+	term Node
+}
+
+// Parse parses a query. Terms separated by spaces must all match; OR
+// (upper case) separates alternatives and binds tighter than the implicit
+// AND, parentheses group and a leading - negates. Operators are from:, to:,
+// cc:, bcc:, subject:, filename:, label:, has:attachment, larger:,
+// smaller: (bytes with an optional K, M or G suffix) and before:, after:
+// (YYYY-MM-DD). An empty query returns a nil node. Syntax errors are *Error.
+func Parse(q string) (Node, error) {
+	input := []rune(q)
+	if len(input) > MaxLength {
+		return nil, errorf(MaxLength, "query is longer than %d characters", MaxLength)
+	}
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, nil
+	}
+	node, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, errorf(t.pos, "unexpected )")
+	}
+	return node, nil
+}
+
+type parser struct {
+	tokens []token
+	i      int
+	depth  int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEOF {
+		p.i++
+	}
+	return t
+}
+
+// and parses terms up to the end of the query or group
+func (p *parser) and() (Node, error) {
+	var nodes []Node
+	for {
+		t := p.peek()
+		switch t.kind {
+		case tokenEOF, tokenRParen:
+			if len(nodes) == 0 {
+				return nil, p.missingTerm(t)
+			}
+			if len(nodes) == 1 {
+				return nodes[0], nil
+			}
+			return &And{Nodes: nodes, At: nodes[0].Pos()}, nil
+		case tokenAnd:
+			p.next()
+			if len(nodes) == 0 || !startsTerm(p.peek()) {
+				return nil, errorf(t.pos, "AND needs a term on both sides")
+			}
+		case tokenOr:
+			return nil, errorf(t.pos, "OR needs a term on both sides")
+		default:
+			node, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, node)
+		}
+	}
+}
+
+// or parses a term and the alternatives joined to it by OR
+func (p *parser) or() (Node, error) {
+	first, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []Node{first}
+	for p.peek().kind == tokenOr {
+		or := p.next()
+		if !startsTerm(p.peek()) {
+			return nil, errorf(or.pos, "OR needs a term on both sides")
+		}
+		node, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return first, nil
+	}
+	return &Or{Nodes: nodes, At: first.Pos()}, nil
+}
+
+// unary parses a term, a negation or a group
+func (p *parser) unary() (Node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenTerm:
+		return t.term, nil
+	case tokenNot, tokenLParen:
+		if p.depth++; p.depth > maxDepth {
+			return nil, errorf(t.pos, "query is nested too deeply")
+		}
+		defer func() { p.depth-- }()
+	}
+
+	if t.kind == tokenNot {
+		if !startsTerm(p.peek()) {
+			return nil, errorf(t.pos, "nothing to exclude after -")
+		}
+		node, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Node: node, At: t.pos}, nil
+	}
+	if p.peek().kind == tokenRParen {
+		return nil, errorf(t.pos, "empty parentheses")
+	}
+	node, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	if p.next().kind != tokenRParen {
+		return nil, errorf(t.pos, "unclosed (")
+	}
+	return node, nil
+}
+
+// missingTerm explains why no term was found before t
+func (p *parser) missingTerm(t token) error {
+	if p.i > 0 && p.tokens[p.i-1].kind == tokenLParen {
+		return errorf(p.tokens[p.i-1].pos, "unclosed (")
+	}
+	if t.kind == tokenRParen {
+		return errorf(t.pos, "unexpected )")
+	}
+	return errorf(t.pos, "expected a search term")
+}
+
+func startsTerm(t token) bool {
+	return t.kind == tokenTerm || t.kind == tokenNot || t.kind == tokenLParen
+}
+
+// lex splits a query into tokens; terms are parsed into their nodes
+func lex(input []rune) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, pos: i})
+			i++
+		case c == '-' && i+1 < len(input) && !unicode.IsSpace(input[i+1]) && input[i+1] != ')':
+			tokens = append(tokens, token{kind: tokenNot, pos: i})
+			i++
+		case c == '"':
+			value, end, err := phrase(input, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenTerm, pos: i, term: &Text{Value: value, Phrase: true, At: i}})
+			i = end
+		default:
+			end := i
+			for end < len(input) && !unicode.IsSpace(input[end]) && !strings.ContainsRune(`()"`, input[end]) {
+				end++
+			}
+			word := string(input[i:end])
+			switch word {
+			case "OR":
+				tokens = append(tokens, token{kind: tokenOr, pos: i})
+				i = end
+				continue
+			case "AND":
+				tokens = append(tokens, token{kind: tokenAnd, pos: i})
+				i = end
+				continue
+			}
+
+			// Unknown prefixes such as https: or RE: are searched as text
+			name, value, found := strings.Cut(word, ":")
+			if !found || !isOperator(strings.ToLower(name)) {
+				tokens = append(tokens, token{kind: tokenTerm, pos: i, term: &Text{Value: word, At: i}})
+				i = end
+				continue
+			}
+			valuePos := i + len([]rune(name)) + 1
+			quoted := false
+			if value == "" && end < len(input) && input[end] == '"' {
+				var err error
+				if value, end, err = phrase(input, end); err != nil {
+					return nil, err
+				}
+				quoted = true
+			}
+			term, err := operator(strings.ToLower(name), value, quoted, i, valuePos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenTerm, pos: i, term: term})
+			i = end
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(input)}), nil
+}
+
+// phrase reads the quoted phrase starting at input[start] and returns its
+// text and the position after the closing quote
+func phrase(input []rune, start int) (string, int, error) {
+	for end := start + 1; end < len(input); end++ {
+		if input[end] == '"' {
+			value := string(input[start+1 : end])
+			if strings.TrimSpace(value) == "" {
+				return "", 0, errorf(start, "empty quotes")
+			}
+			return value, end + 1, nil
+		}
+	}
+	return "", 0, errorf(start, "unclosed quote")
+}
+
+// isOperator reports whether name, in lower case, is a query operator
+func isOperator(name string) bool {
+	switch name {
+	case FieldFrom, FieldTo, FieldCc, FieldBcc, FieldSubject, FieldFilename, FieldLabel, "has", "larger", "smaller", "before", "after":
+		return true
+	}
+	return false
+}
+
+// operator parses the term name:value at position at
+func operator(name, value string, quoted bool, at, valuePos int) (Node, error) {
+	if value == "" {
+		return nil, errorf(valuePos, "%s: needs a value", name)
+	}
+
+	switch name {
+	case "has":
+		if !strings.EqualFold(value, "attachment") {
+			return nil, errorf(valuePos, "has: only supports attachment")
+		}
+		return &HasAttachment{At: at}, nil
+	case "larger", "smaller":
+		bytes, ok := parseSize(value)
+		if !ok {
+			return nil, errorf(valuePos, "invalid size %q; use bytes or a K, M or G suffix such as 5MB", value)
+		}
+		return &Size{Larger: name == "larger", Bytes: bytes, At: at}, nil
+	case "before", "after":
+		t, ok := parseDate(value)
+		if !ok {
+			return nil, errorf(valuePos, "invalid date %q; use YYYY-MM-DD", value)
+		}
+		return &Date{Before: name == "before", Time: t, At: at}, nil
+	}
+	return &Field{Name: name, Value: value, Phrase: quoted, At: at}, nil
+}
+
+var sizePattern = regexp.MustCompile(`(?i)^(\d+(?:\.\d+)?)([KMG]?)B?$`)
+
+// parseSize parses sizes such as 500, 20K, 1.5MB or 2G; units are binary
+func parseSize(s string) (int64, bool) {
+	m := sizePattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	switch strings.ToUpper(m[2]) {
+	case "K":
+		n *= 1 << 10
+	case "M":
+		n *= 1 << 20
+	case "G":
+		n *= 1 << 30
+	}
+	if n >= math.MaxInt64/2 {
+		return 0, false
+	}
+	return int64(math.Round(n)), true
+}
+
+// parseDate parses YYYY-MM-DD or YYYY/MM/DD as midnight UTC
+func parseDate(s string) (time.Time, bool) {
+	for _, layout := range []string{time.DateOnly, "2006/01/02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
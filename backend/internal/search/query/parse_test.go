@@ -0,0 +1,81 @@
+package query
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParse verifies operators, grouping and precedence
+func TestParse(t *testing.T) {
+	for q, want := range map[string]string{
+		`invoice`: `invoice`,
+		`from:ceo@ to:legal subject:"merger" has:attachment larger:5MB before:2024-01-01 filename:pdf -label:spam`: `(from:ceo@ to:legal subject:"merger" has:attachment larger:5242880 before:2024-01-01 filename:pdf -label:spam)`,
+		`"quarterly report" Q3`: `("quarterly report" Q3)`,
+		`a b OR c`:              `(a (b OR c))`,
+		`a AND b`:               `(a b)`,
+		`(from:alice OR from:bob) -"do not reply"`: `((from:alice OR from:bob) -"do not reply")`,
+		`-(label:spam OR label:junk)`:              `-(label:spam OR label:junk)`,
+		`FROM:Alice@Acme.test`:                     `from:Alice@Acme.test`,
+		`e-mail 10:30`:                             `(e-mail 10:30)`,
+		`smaller:1.5k after:2024/02/29`:            `(smaller:1536 after:2024-02-29)`,
+		`larger:10`:                                `larger:10`,
+		`subject:"a (b) c"`:                        `subject:"a (b) c"`,
+		`a or b`:                                   `(a or b)`,
+		`https://example.test`:                     `https://example.test`,
+		`RE:budget ticket:1234`:                    `(RE:budget ticket:1234)`,
+	} {
+		node, err := Parse(q)
+		require.NoError(t, err, q)
+		assert.Equal(t, want, node.String(), q)
+	}
+
+	node, err := Parse("  ")
+	require.NoError(t, err)
+	assert.Nil(t, node)
+}
+
+// TestParseValues verifies the nodes operators parse into
+func TestParseValues(t *testing.T) {
+	node, err := Parse(`é from:"Jane Doe" before:2024-01-01 larger:2M -label:x`)
+	require.NoError(t, err)
+	and := node.(*And)
+	require.Len(t, and.Nodes, 5)
+	assert.Equal(t, &Text{Value: "é", At: 0}, and.Nodes[0])
+	// Positions count characters, not bytes
+	assert.Equal(t, &Field{Name: FieldFrom, Value: "Jane Doe", Phrase: true, At: 2}, and.Nodes[1])
+	assert.Equal(t, &Date{Before: true, Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), At: 18}, and.Nodes[2])
+	assert.Equal(t, &Size{Larger: true, Bytes: 2 << 20, At: 36}, and.Nodes[3])
+	assert.Equal(t, &Not{Node: &Field{Name: FieldLabel, Value: "x", At: 47}, At: 46}, and.Nodes[4])
+}
+
+// TestParseErrors verifies invalid queries report what is wrong and where
+func TestParseErrors(t *testing.T) {
+	for q, want := range map[string]Error{
+		`subject:"merger`:         {Pos: 8, Msg: "unclosed quote"},
+		`a (b OR c`:               {Pos: 2, Msg: "unclosed ("},
+		`a b)`:                    {Pos: 3, Msg: "unexpected )"},
+		`a ()`:                    {Pos: 2, Msg: "empty parentheses"},
+		`OR a`:                    {Pos: 0, Msg: "OR needs a term on both sides"},
+		`a OR`:                    {Pos: 2, Msg: "OR needs a term on both sides"},
+		`a AND`:                   {Pos: 2, Msg: "AND needs a term on both sides"},
+		`a -OR b`:                 {Pos: 2, Msg: "nothing to exclude after -"},
+		`from: ceo`:               {Pos: 5, Msg: "from: needs a value"},
+		`has:pdf`:                 {Pos: 4, Msg: "has: only supports attachment"},
+		`larger:big`:              {Pos: 7, Msg: `invalid size "big"`},
+		`x before:2024-13-01`:     {Pos: 9, Msg: `invalid date "2024-13-01"`},
+		`a ""`:                    {Pos: 2, Msg: "empty quotes"},
+		strings.Repeat("(", 40):   {Pos: 32, Msg: "query is nested too deeply"},
+		strings.Repeat("a", 3000): {Pos: MaxLength, Msg: "query is longer than"},
+	} {
+		_, err := Parse(q)
+		var perr *Error
+		require.True(t, errors.As(err, &perr), "%s: %v", q, err)
+		assert.Equal(t, want.Pos, perr.Pos, q)
+		assert.Contains(t, perr.Msg, want.Msg, q)
+	}
+}
@@ -0,0 +1,263 @@
+// Package search manages the Meilisearch indexes of archived emails.
+package search
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/meilisearch/meilisearch-go"
+
+	"ironarchive/internal/models"
+	"ironarchive/internal/search/query"
+)
+
+// EmailsIndex is the UID of the live emails index. Schema migrations build
+// a versioned index next to it and swap the two.
+const EmailsIndex = "emails"
+
+// PrimaryKey is the document attribute holding the email ID
+const PrimaryKey = "id"
+
+// SchemaVersion is the version of EmailDocument and EmailSettings. Bump it
+// whenever either changes so Manager.Ensure rebuilds the index.
+const SchemaVersion = 5
+
+// MaxBodyBytes caps the indexed body text; Meilisearch only indexes the first
+// 65,535 words of an attribute anyway
+const MaxBodyBytes = 256 << 10
+
+// MaxAttachmentTextBytes caps the attachment text indexed per email, shared
+// by its attachments in order
+const MaxAttachmentTextBytes = 256 << 10
+
+// MaxTextWords caps the distinct words of the body and attachment text kept
+// in text_words; later words are only found by ranking
+const MaxTextWords = 10000
+
+// maxWordBytes leaves longer words out of text_words, such as encoded blobs
+const maxWordBytes = 100
+
+// MaxTotalHits is the deepest a search can page into its results
+const MaxTotalHits = 10000
+
+// EmailDocument is the searchable representation of an email. Dates are Unix
+// seconds so they can be filtered and sorted numerically. The *_terms,
+// *_words and labels attributes are lower-cased values that query operators
+// such as from: and filename: match with equality filters.
+type EmailDocument struct {
+	ID             string   `json:"id"`
+	TenantID       string   `json:"tenant_id"`
+	MailboxID      string   `json:"mailbox_id"`
+	MessageID      string   `json:"message_id"`
+	Subject        string   `json:"subject"`
+	Sender         string   `json:"sender"`
+	Recipients     []string `json:"recipients"`
+	BodyText       string   `json:"body_text"`
+	SentAt         int64    `json:"sent_at"`
+	HasAttachments bool     `json:"has_attachments"`
+	SizeBytes      int      `json:"size_bytes"`
+	Labels         []string `json:"labels"`
+	// ThreadID is unset until the email is threaded; searches collapsing
+	// threads keep emails without it
+	ThreadID string `json:"thread_id,omitempty"`
+	// AttachmentNames are the file names of the attachments
+	AttachmentNames []string `json:"attachment_names"`
+	SenderTerms     []string `json:"sender_terms"`
+	RecipientTerms  []string `json:"recipient_terms"`
+	SubjectWords    []string `json:"subject_words"`
+	AttachmentTerms []string `json:"attachment_terms"`
+	// TextWords are the distinct words of the body and attachment text, so
+	// free text inside OR or negation can be filtered on
+	TextWords []string `json:"text_words"`
+	// Attachments carry the extracted text of attachments, so a match in
+	// attachments.text names the attachment it came from
+	Attachments []AttachmentDocument `json:"attachments"`
+}
+
+// AttachmentDocument is an attachment of an EmailDocument
+type AttachmentDocument struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	Text     string `json:"text"`
+}
+
+// NewEmailDocument builds the document of an email in a mailbox of tenantID
+// with its attachments
+func NewEmailDocument(email *models.Email, tenantID string, attachments []models.Attachment) EmailDocument {
+	recipients := email.Recipients
+	if recipients == nil {
+		recipients = []string{}
+	}
+	var recipientTerms []string
+	for _, r := range recipients {
+		recipientTerms = append(recipientTerms, addressTerms(r)...)
+	}
+	names := []string{}
+	var attachmentTerms []string
+	docs := []AttachmentDocument{}
+	body := truncate(deref(email.BodyText), MaxBodyBytes)
+	texts := []string{body}
+	textBudget := MaxAttachmentTextBytes
+	for _, a := range attachments {
+		names = append(names, a.Filename)
+		attachmentTerms = append(attachmentTerms, filenameTerms(a.Filename)...)
+		text := truncate(deref(a.ExtractedText), textBudget)
+		textBudget -= len(text)
+		texts = append(texts, text)
+		docs = append(docs, AttachmentDocument{ID: a.ID, Filename: a.Filename, Text: text})
+	}
+	labels := []string{}
+	for _, category := range email.Categories {
+		labels = append(labels, strings.ToLower(category))
+	}
+	return EmailDocument{
+		ID:              email.ID,
+		TenantID:        tenantID,
+		MailboxID:       email.MailboxID,
+		MessageID:       email.MessageID,
+		Subject:         deref(email.Subject),
+		Sender:          deref(email.Sender),
+		Recipients:      recipients,
+		BodyText:        body,
+		SentAt:          email.SentAt.Unix(),
+		HasAttachments:  email.HasAttachments,
+		SizeBytes:       email.SizeBytes,
+		Labels:          labels,
+		ThreadID:        deref(email.ThreadID),
+		AttachmentNames: names,
+		SenderTerms:     nonNil(addressTerms(deref(email.Sender))),
+		RecipientTerms:  nonNil(dedupe(recipientTerms)),
+		SubjectWords:    nonNil(query.Words(deref(email.Subject))),
+		AttachmentTerms: nonNil(dedupe(attachmentTerms)),
+		TextWords:       textWords(texts),
+		Attachments:     docs,
+	}
+}
+
+// addressTerms returns the terms an address is found by: the address,
+// "local@", "@domain" and the bare local part and domain, including parent
+// domains, so from:ceo@, from:@acme.com and from:acme.com all match
+// ceo@mail.acme.com
+func addressTerms(address string) []string {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if address == "" {
+		return nil
+	}
+	terms := []string{address}
+	at := strings.LastIndexByte(address, '@')
+	if at < 0 {
+		return terms
+	}
+	local, domain := address[:at], address[at+1:]
+	if local != "" {
+		terms = append(terms, local+"@", local)
+	}
+	for domain != "" {
+		terms = append(terms, "@"+domain, domain)
+		_, parent, found := strings.Cut(domain, ".")
+		if !found || !strings.Contains(parent, ".") {
+			break
+		}
+		domain = parent
+	}
+	return dedupe(terms)
+}
+
+// filenameTerms returns the terms a file name is found by: the name, the
+// name without its extension, the extension and the words of the name
+func filenameTerms(name string) []string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return nil
+	}
+	terms := []string{name}
+	if ext := path.Ext(name); ext != "" && ext != name {
+		terms = append(terms, strings.TrimSuffix(name, ext), ext[1:])
+	}
+	return dedupe(append(terms, query.Words(name)...))
+}
+
+// textWords returns the distinct words of texts as query.Words splits them,
+// up to MaxTextWords
+func textWords(texts []string) []string {
+	words := []string{}
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len(words) == MaxTextWords {
+				return words
+			}
+			if len(word) <= maxWordBytes && !seen[word] {
+				seen[word] = true
+				words = append(words, word)
+			}
+		}
+	}
+	return words
+}
+
+// dedupe removes repeated terms, keeping the first occurrence
+func dedupe(terms []string) []string {
+	out := terms[:0]
+	for _, term := range terms {
+		if !slices.Contains(out, term) {
+			out = append(out, term)
+		}
+	}
+	return out
+}
+
+func nonNil(terms []string) []string {
+	if terms == nil {
+		return []string{}
+	}
+	return terms
+}
+
+// EmailSettings returns the index settings of SchemaVersion
+func EmailSettings() *meilisearch.Settings {
+	return &meilisearch.Settings{
+		// Ordered by importance for the attribute ranking rule
+		SearchableAttributes: []string{"subject", "sender", "recipients", "attachment_names", "body_text", "attachments.text"},
+		FilterableAttributes: []string{"tenant_id", "mailbox_id", "sender", "recipients", "sent_at", "has_attachments", "size_bytes",
+			"labels", "sender_terms", "recipient_terms", "subject_words", "attachment_terms", "text_words", "thread_id"},
+		SortableAttributes: []string{"sent_at", "size_bytes"},
+		// Relevance first; among equally relevant emails the newest wins
+		RankingRules: []string{"words", "typo", "proximity", "attribute", "sort", "exactness", "sent_at:desc"},
+		TypoTolerance: &meilisearch.TypoTolerance{
+			Enabled:             true,
+			MinWordSizeForTypos: meilisearch.MinWordSizeForTypos{OneTypo: 5, TwoTypos: 9},
+			// Addresses and numbers such as invoice IDs must match exactly
+			DisableOnAttributes: []string{"sender", "recipients"},
+			DisableOnNumbers:    true,
+		},
+		Pagination: &meilisearch.Pagination{MaxTotalHits: MaxTotalHits},
+		Faceting: &meilisearch.Faceting{
+			MaxValuesPerFacet: 100,
+			SortFacetValuesBy: map[string]meilisearch.SortFacetType{"*": meilisearch.SortFacetTypeCount},
+		},
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
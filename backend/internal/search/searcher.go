@@ -0,0 +1,368 @@
+package search
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ironarchive/internal/access"
+	"ironarchive/internal/database"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/search/query"
+)
+
+// Sort orders of search results
+const (
+	SortRelevance = "relevance"
+	SortNewest    = "newest"
+	SortOldest    = "oldest"
+)
+
+const (
+	// DefaultLimit is the page size of a search without a limit
+	DefaultLimit = 20
+	// MaxLimit caps the page size of a search
+	MaxLimit = 100
+	// searchKeyName names the search-only API key that signs tenant tokens
+	searchKeyName = "ironarchive-search"
+	// snippetWords is the length of the body snippet around the best match
+	snippetWords = 30
+	// maxCachedTokens bounds the tenant token cache
+	maxCachedTokens = 1000
+)
+
+// CollapseThread collapses the results of a search to one hit per thread
+const CollapseThread = "thread"
+
+// Search backends reported in Result.Backend
+const (
+	BackendMeilisearch = "meilisearch"
+	BackendPostgres    = "postgres"
+)
+
+// Highlight tags around matched words in Highlights
+const (
+	HighlightPreTag  = "<mark>"
+	HighlightPostTag = "</mark>"
+)
+
+// Facets lists the attributes whose value counts a search can return
+var Facets = []string{"tenant_id", "mailbox_id", "sender", "recipients", "has_attachments"}
+
+// ErrInvalidRequest is returned for malformed search parameters
+var ErrInvalidRequest = errors.New("invalid search request")
+
+// scopeFilter returns the Meilisearch filter that confines results to scope
+func scopeFilter(s access.Scope) string {
+	var clauses []string
+	if s.TenantID != "" {
+		clauses = append(clauses, "tenant_id = "+quote(s.TenantID))
+	}
+	if s.MailboxID != "" {
+		clauses = append(clauses, "mailbox_id = "+quote(s.MailboxID))
+	}
+	return strings.Join(clauses, " AND ")
+}
+
+// Request is a search as sent by a client; filters narrow the caller's scope
+type Request struct {
+	// Query is free text with the operators of query.Parse, e.g.
+	// from:ceo@ subject:"merger" has:attachment -label:spam
+	Query          string
+	MailboxID      string
+	Sender         string
+	HasAttachments *bool
+	SentAfter      *time.Time
+	SentBefore     *time.Time
+	// Sort is SortRelevance (default), SortNewest or SortOldest
+	Sort string
+	// Facets are attributes of Facets to count values of
+	Facets []string
+	// Collapse is CollapseThread to return the best hit of each thread
+	// only, or empty
+	Collapse string
+	// Limit is the page size, up to MaxLimit (default DefaultLimit)
+	Limit int
+	// Cursor is the NextCursor of the previous page
+	Cursor string
+}
+
+// Result is one page of search results
+type Result struct {
+	Hits               []Hit                       `json:"hits"`
+	Facets             map[string]map[string]int64 `json:"facets,omitempty"`
+	EstimatedTotalHits int64                       `json:"estimatedTotalHits"`
+	// NextCursor fetches the next page; nil on the last page
+	NextCursor       *string `json:"nextCursor"`
+	ProcessingTimeMs int64   `json:"processingTimeMs"`
+	// Backend is BackendMeilisearch, or BackendPostgres while the search
+	// index is unavailable
+	Backend string `json:"backend"`
+}
+
+// Hit is an email matching a search
+type Hit struct {
+	ID             string    `json:"id"`
+	TenantID       string    `json:"tenantId"`
+	MailboxID      string    `json:"mailboxId"`
+	MessageID      string    `json:"messageId"`
+	Subject        string    `json:"subject"`
+	Sender         string    `json:"sender"`
+	Recipients     []string  `json:"recipients"`
+	SentAt         time.Time `json:"sentAt"`
+	HasAttachments bool      `json:"hasAttachments"`
+	SizeBytes      int       `json:"sizeBytes"`
+	ThreadID       string    `json:"threadId,omitempty"`
+	// ThreadSize is the number of messages in scope of the hit's thread;
+	// only set when collapsing threads
+	ThreadSize int        `json:"threadSize,omitempty"`
+	Highlights Highlights `json:"highlights"`
+	// Attachments are the attachments whose text matched the query
+	Attachments []AttachmentHit `json:"attachments"`
+}
+
+// AttachmentHit is an attachment of a hit with the matched part of its text
+type AttachmentHit struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	Snippet  string `json:"snippet"`
+}
+
+// Highlights are the matched fields of a hit with matches wrapped in
+// HighlightPreTag and HighlightPostTag; Snippet is the body text around the
+// best match
+type Highlights struct {
+	Subject    string   `json:"subject"`
+	Sender     string   `json:"sender"`
+	Recipients []string `json:"recipients"`
+	Snippet    string   `json:"snippet"`
+}
+
+// SearcherConfig tunes the searcher
+type SearcherConfig struct {
+	// TenantTokens runs searches with tenant tokens carrying the scope
+	// filter, so Meilisearch enforces it even if a query is built wrongly
+	TenantTokens bool
+	// TokenTTL is the lifetime of a tenant token (default 10m)
+	TokenTTL time.Duration
+	// Fallback searches PostgreSQL while Meilisearch is unavailable
+	Fallback bool
+	// FallbackCooldown is how long searches stay on PostgreSQL before
+	// Meilisearch is tried again (default 30s)
+	FallbackCooldown time.Duration
+}
+
+// Backend runs validated searches; results of every backend have the same shape
+type Backend interface {
+	Search(ctx context.Context, scope access.Scope, req *ParsedRequest) (*Result, error)
+}
+
+// ParsedRequest is a validated Request
+type ParsedRequest struct {
+	Request
+	// Terms is the parsed Query; nil matches every email
+	Terms  query.Node
+	Offset int64
+	Limit  int
+
+	// text and filter are Terms compiled for Meilisearch
+	text, filter string
+}
+
+// Searcher runs searches confined to the caller's scope on Meilisearch,
+// falling back to PostgreSQL full-text search while Meilisearch is down
+type Searcher struct {
+	primary  Backend
+	fallback Backend
+	sessions access.SessionRunner
+	cfg      SearcherConfig
+	logger   *zap.Logger
+	now      func() time.Time
+
+	mu sync.Mutex
+	// downUntil is when Meilisearch is tried again after a failure
+	downUntil time.Time
+}
+
+// NewSearcher creates a searcher. Threads and fallback searches are read
+// through sessions.
+func NewSearcher(meili *database.MeilisearchConnection, sessions access.SessionRunner, cfg SearcherConfig, logger *zap.Logger) *Searcher {
+	if cfg.TokenTTL <= 0 {
+		cfg.TokenTTL = 10 * time.Minute
+	}
+	if cfg.FallbackCooldown <= 0 {
+		cfg.FallbackCooldown = 30 * time.Second
+	}
+	s := &Searcher{
+		sessions: sessions,
+		cfg:      cfg,
+		logger:   logger,
+		now:      time.Now,
+	}
+	s.primary = &meiliBackend{
+		client:       meili.Client,
+		url:          meili.URL,
+		tenantTokens: cfg.TenantTokens,
+		tokenTTL:     cfg.TokenTTL,
+		logger:       logger,
+		now:          func() time.Time { return s.now() },
+		tokens:       make(map[access.Scope]tenantToken),
+	}
+	if cfg.Fallback {
+		s.fallback = &postgresBackend{sessions: sessions}
+	}
+	return s
+}
+
+// Search returns one page of the emails in scope matching req
+func (s *Searcher) Search(ctx context.Context, scope access.Scope, req Request) (*Result, error) {
+	parsed, err := parseRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.search(ctx, scope, parsed)
+	if err != nil || parsed.Collapse != CollapseThread {
+		return result, err
+	}
+	if err := s.countThreads(ctx, scope, result.Hits); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// search runs a parsed request on Meilisearch or, while it is down, on the
+// fallback
+func (s *Searcher) search(ctx context.Context, scope access.Scope, parsed *ParsedRequest) (*Result, error) {
+	if s.fallback != nil && s.indexDown() {
+		return s.fallback.Search(ctx, scope, parsed)
+	}
+	result, err := s.primary.Search(ctx, scope, parsed)
+	if err != nil && s.fallback != nil && ctx.Err() == nil && unavailable(err) {
+		s.logger.Warn("Meilisearch is unavailable, searching PostgreSQL instead",
+			zap.Duration("retry_in", s.cfg.FallbackCooldown), zap.Error(err))
+		s.mu.Lock()
+		s.downUntil = s.now().Add(s.cfg.FallbackCooldown)
+		s.mu.Unlock()
+		return s.fallback.Search(ctx, scope, parsed)
+	}
+	return result, err
+}
+
+// countThreads sets the thread sizes of collapsed hits. Unthreaded hits
+// stand for themselves.
+func (s *Searcher) countThreads(ctx context.Context, scope access.Scope, hits []Hit) error {
+	var ids []string
+	for _, hit := range hits {
+		if hit.ThreadID != "" {
+			ids = append(ids, hit.ThreadID)
+		}
+	}
+	var counts map[string]int
+	err := s.sessions.WithSession(ctx, scope.Session, func(repos *repositories.Repositories) error {
+		var err error
+		counts, err = repos.Threads.CountMessages(ctx, ids, scope.EmailFilter())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count thread messages: %w", err)
+	}
+	for i := range hits {
+		hits[i].ThreadSize = max(counts[hits[i].ThreadID], 1)
+	}
+	return nil
+}
+
+// indexDown reports whether Meilisearch failed within the cooldown
+func (s *Searcher) indexDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now().Before(s.downUntil)
+}
+
+// parseRequest validates req and parses its query
+func parseRequest(req Request) (*ParsedRequest, error) {
+	offset, err := decodeCursor(req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+	if limit < 0 || limit > MaxLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidRequest, MaxLimit)
+	}
+	switch req.Sort {
+	case "", SortRelevance, SortNewest, SortOldest:
+	default:
+		return nil, fmt.Errorf("%w: sort must be %s, %s or %s", ErrInvalidRequest, SortRelevance, SortNewest, SortOldest)
+	}
+	for _, facet := range req.Facets {
+		if !slices.Contains(Facets, facet) {
+			return nil, fmt.Errorf("%w: unknown facet %q", ErrInvalidRequest, facet)
+		}
+	}
+	if req.Collapse != "" && req.Collapse != CollapseThread {
+		return nil, fmt.Errorf("%w: collapse must be %s", ErrInvalidRequest, CollapseThread)
+	}
+
+	terms, err := query.Parse(req.Query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+	// Queries are compiled even when Meilisearch is down so they are
+	// accepted the same way by every backend
+	text, filter, err := compileQuery(terms)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+	return &ParsedRequest{Request: req, Terms: terms, Offset: offset, Limit: limit, text: text, filter: filter}, nil
+}
+
+// nextCursor returns the cursor of the page after hits, nil on the last page
+func nextCursor(req *ParsedRequest, hits int, total int64) *string {
+	next := req.Offset + int64(hits)
+	if hits != req.Limit || next >= min(total, MaxTotalHits) {
+		return nil
+	}
+	cursor := encodeCursor(next)
+	return &cursor
+}
+
+// cursor is the position a page starts at
+type cursor struct {
+	Offset int64 `json:"o"`
+}
+
+func encodeCursor(offset int64) string {
+	data, _ := json.Marshal(cursor{Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	var c cursor
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil || c.Offset < 0 {
+		return 0, fmt.Errorf("%w: malformed cursor", ErrInvalidRequest)
+	}
+	return c.Offset, nil
+}
+
+// quote renders s as a Meilisearch filter string literal
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,462 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/access"
+	"ironarchive/internal/database"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+	"ironarchive/internal/search/query"
+	"ironarchive/internal/search/searchtest"
+)
+
+const (
+	otherTenant  = "33333333-3333-3333-3333-333333333333"
+	otherMailbox = "44444444-4444-4444-4444-444444444444"
+	userID       = "55555555-5555-5555-5555-555555555555"
+	// sharedMailbox is a second mailbox of testTenant
+	sharedMailbox = "66666666-6666-6666-6666-666666666666"
+	testThread    = "77777777-7777-7777-7777-777777777777"
+)
+
+// fakeSessions runs session-scoped calls on repos and records their sessions
+type fakeSessions struct {
+	repos    *repositories.Repositories
+	sessions []database.Session
+}
+
+func (f *fakeSessions) WithSession(_ context.Context, session database.Session, fn func(repos *repositories.Repositories) error) error {
+	f.sessions = append(f.sessions, session)
+	return fn(f.repos)
+}
+
+// fakeThreads holds one thread of testTenant: a1 and a2 in testMailbox, a
+// copy of a2 and the reply c3 in sharedMailbox
+type fakeThreads struct {
+	repositories.ThreadRepository
+	emails []models.Email
+}
+
+func newFakeThreads() *fakeThreads {
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	email := func(id, mailboxID, messageID, subject string, day int) models.Email {
+		thread := testThread
+		return models.Email{ID: id, MailboxID: mailboxID, InternetMessageID: &messageID, Subject: &subject,
+			SentAt: base.AddDate(0, 0, day), ThreadID: &thread}
+	}
+	return &fakeThreads{emails: []models.Email{
+		email("a1", testMailbox, "a1@test", "Invoice March", 0),
+		email("a2", testMailbox, "a2@test", "Invoice April", 1),
+		email("c2", sharedMailbox, "a2@test", "Invoice April", 1),
+		email("c3", sharedMailbox, "c3@test", "RE: Invoice April", 5),
+	}}
+}
+
+func (f *fakeThreads) CountMessages(_ context.Context, threadIDs []string, filter repositories.EmailFilter) (map[string]int, error) {
+	messages := map[string]map[string]bool{}
+	for _, e := range f.matching(filter) {
+		if slices.Contains(threadIDs, *e.ThreadID) {
+			if messages[*e.ThreadID] == nil {
+				messages[*e.ThreadID] = map[string]bool{}
+			}
+			messages[*e.ThreadID][*e.InternetMessageID] = true
+		}
+	}
+	counts := map[string]int{}
+	for id, m := range messages {
+		counts[id] = len(m)
+	}
+	return counts, nil
+}
+
+// matching returns the emails of the thread within filter's tenant and mailbox
+func (f *fakeThreads) matching(filter repositories.EmailFilter) []models.Email {
+	var emails []models.Email
+	for _, e := range f.emails {
+		if (filter.TenantID == nil || *filter.TenantID == testTenant) && (filter.MailboxID == nil || *filter.MailboxID == e.MailboxID) {
+			emails = append(emails, e)
+		}
+	}
+	return emails
+}
+
+// newTestSearcher creates a searcher over an index with emails of two
+// tenants: a1..a3 in testMailbox and b1, b2 in otherMailbox. a1 is labelled
+// Finance and a3 Spam; a1 and a2 are in testThread (see fakeThreads).
+func newTestSearcher(t *testing.T, cfg SearcherConfig) (*Searcher, *searchtest.Server) {
+	t.Helper()
+	server := searchtest.NewServer()
+	t.Cleanup(server.Close)
+
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	labels := map[string][]string{"a1": {"Finance"}, "a3": {"Spam"}}
+	var docs []map[string]any
+	add := func(id, mailboxID, tenantID, subject, sender string, day int, attachments bool) {
+		subjectCopy, senderCopy := subject, sender
+		body := "Quarterly numbers attached for " + subject
+		email := &models.Email{ID: id, MailboxID: mailboxID, MessageID: "<" + id + "@test>", Subject: &subjectCopy, Sender: &senderCopy,
+			Recipients: []string{"team@acme.test"}, BodyText: &body, SentAt: base.AddDate(0, 0, day), HasAttachments: attachments,
+			Categories: labels[id]}
+		if id == "a1" || id == "a2" {
+			thread := testThread
+			email.ThreadID = &thread
+		}
+		// Emails with attachments carry e.g. invoice-march.pdf with its text
+		var files []models.Attachment
+		if attachments {
+			text := "Wire transfer reference " + strings.ToUpper(id)
+			files = append(files, models.Attachment{ID: "att-" + id, EmailID: id, Filename: strings.ReplaceAll(strings.ToLower(subject), " ", "-") + ".pdf",
+				ExtractedText: &text})
+		}
+		data, err := json.Marshal(NewEmailDocument(email, tenantID, files))
+		require.NoError(t, err)
+		var doc map[string]any
+		require.NoError(t, json.Unmarshal(data, &doc))
+		docs = append(docs, doc)
+	}
+	add("a1", testMailbox, testTenant, "Invoice March", "billing@vendor.test", 0, true)
+	add("a2", testMailbox, testTenant, "Invoice April", "billing@vendor.test", 1, false)
+	add("a3", testMailbox, testTenant, "Lunch", "bob@acme.test", 2, false)
+	add("b1", otherMailbox, otherTenant, "Invoice May", "billing@vendor.test", 3, true)
+	add("b2", otherMailbox, otherTenant, "Secret plans", "eve@other.test", 4, false)
+	server.AddIndex(EmailsIndex, PrimaryKey, *EmailSettings(), docs...)
+
+	meili, err := database.NewMeilisearchConnection(server.URL, "master-key", zap.NewNop())
+	require.NoError(t, err)
+	repos := &repositories.Repositories{Threads: newFakeThreads()}
+	return NewSearcher(meili, &fakeSessions{repos: repos}, cfg, zap.NewNop()), server
+}
+
+func hitIDs(result *Result) []string {
+	ids := make([]string, len(result.Hits))
+	for i, hit := range result.Hits {
+		ids[i] = hit.ID
+	}
+	return ids
+}
+
+// TestScopeFilter verifies scopes become Meilisearch filters
+func TestScopeFilter(t *testing.T) {
+	assert.Empty(t, scopeFilter(access.Scope{}))
+	assert.Equal(t, fmt.Sprintf(`tenant_id = "%s" AND mailbox_id = "%s"`, testTenant, testMailbox),
+		scopeFilter(access.Scope{TenantID: testTenant, MailboxID: testMailbox}))
+}
+
+// TestSearchConfinesToScope verifies request filters cannot widen the scope
+func TestSearchConfinesToScope(t *testing.T) {
+	searcher, _ := newTestSearcher(t, SearcherConfig{})
+	ctx := context.Background()
+
+	result, err := searcher.Search(ctx, access.Scope{}, Request{Query: "invoice"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b1", "a2", "a1"}, hitIDs(result))
+
+	result, err = searcher.Search(ctx, access.Scope{TenantID: testTenant}, Request{Query: "invoice"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a2", "a1"}, hitIDs(result))
+
+	result, err = searcher.Search(ctx, access.Scope{TenantID: testTenant}, Request{MailboxID: otherMailbox})
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+
+	attachments := true
+	after := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	result, err = searcher.Search(ctx, access.Scope{}, Request{Sender: "billing@vendor.test", HasAttachments: &attachments, SentAfter: &after, Sort: SortOldest})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a1", "b1"}, hitIDs(result))
+}
+
+// TestSearchHighlightsAndFacets verifies highlighted fields, snippets and facet counts
+func TestSearchHighlightsAndFacets(t *testing.T) {
+	searcher, _ := newTestSearcher(t, SearcherConfig{})
+
+	result, err := searcher.Search(context.Background(), access.Scope{TenantID: testTenant}, Request{Query: "invoice", Facets: []string{"sender", "has_attachments"}})
+	require.NoError(t, err)
+	require.Len(t, result.Hits, 2)
+	hit := result.Hits[0]
+	assert.Equal(t, "Invoice April", hit.Subject)
+	assert.Equal(t, time.Date(2025, 3, 2, 12, 0, 0, 0, time.UTC), hit.SentAt)
+	assert.Equal(t, "<mark>Invoice</mark> April", hit.Highlights.Subject)
+	assert.Contains(t, hit.Highlights.Snippet, "<mark>Invoice</mark>")
+	assert.Equal(t, map[string]int64{"billing@vendor.test": 2}, result.Facets["sender"])
+	assert.Equal(t, map[string]int64{"true": 1, "false": 1}, result.Facets["has_attachments"])
+}
+
+// TestSearchAttachmentText verifies attachment text is searched and hits
+// name the attachments that matched
+func TestSearchAttachmentText(t *testing.T) {
+	searcher, _ := newTestSearcher(t, SearcherConfig{})
+	ctx := context.Background()
+	scope := access.Scope{TenantID: testTenant}
+
+	result, err := searcher.Search(ctx, scope, Request{Query: "wire transfer"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a1"}, hitIDs(result))
+	require.Len(t, result.Hits[0].Attachments, 1)
+	attachment := result.Hits[0].Attachments[0]
+	assert.Equal(t, "att-a1", attachment.ID)
+	assert.Equal(t, "invoice-march.pdf", attachment.Filename)
+	assert.Contains(t, attachment.Snippet, "<mark>Wire</mark> <mark>transfer</mark>")
+
+	// Matches elsewhere list no attachments
+	result, err = searcher.Search(ctx, scope, Request{Query: "invoice"})
+	require.NoError(t, err)
+	require.Len(t, result.Hits, 2)
+	assert.Empty(t, result.Hits[1].Attachments)
+}
+
+// TestSearchCursorPagination verifies cursors walk all results and end on the last page
+func TestSearchCursorPagination(t *testing.T) {
+	searcher, _ := newTestSearcher(t, SearcherConfig{})
+	ctx := context.Background()
+
+	var ids []string
+	req := Request{Limit: 2, Sort: SortNewest}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 5, "pagination should end")
+		result, err := searcher.Search(ctx, access.Scope{}, req)
+		require.NoError(t, err)
+		assert.EqualValues(t, 5, result.EstimatedTotalHits)
+		ids = append(ids, hitIDs(result)...)
+		if result.NextCursor == nil {
+			break
+		}
+		req.Cursor = *result.NextCursor
+	}
+	assert.Equal(t, []string{"b2", "b1", "a3", "a2", "a1"}, ids)
+
+	result, err := searcher.Search(ctx, access.Scope{}, Request{Cursor: encodeCursor(MaxTotalHits)})
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+	assert.Nil(t, result.NextCursor)
+}
+
+// TestSearchInvalidRequest verifies malformed parameters are rejected before searching
+func TestSearchInvalidRequest(t *testing.T) {
+	searcher, server := newTestSearcher(t, SearcherConfig{})
+
+	for name, req := range map[string]Request{
+		"cursor":   {Cursor: "not a cursor"},
+		"limit":    {Limit: MaxLimit + 1},
+		"sort":     {Sort: "size"},
+		"facet":    {Facets: []string{"body_text"}},
+		"collapse": {Collapse: "subject"},
+	} {
+		_, err := searcher.Search(context.Background(), access.Scope{}, req)
+		assert.ErrorIs(t, err, ErrInvalidRequest, name)
+	}
+	assert.Empty(t, server.Searches())
+}
+
+// TestSearchCollapseThreads verifies collapsed searches return the best
+// hit per thread with the number of messages in scope
+func TestSearchCollapseThreads(t *testing.T) {
+	searcher, server := newTestSearcher(t, SearcherConfig{})
+	ctx := context.Background()
+
+	result, err := searcher.Search(ctx, access.Scope{TenantID: testTenant}, Request{Collapse: CollapseThread})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a3", "a2"}, hitIDs(result))
+	assert.Equal(t, 1, result.Hits[0].ThreadSize, "unthreaded emails stand alone")
+	assert.Equal(t, testThread, result.Hits[1].ThreadID)
+	assert.Equal(t, 3, result.Hits[1].ThreadSize, "copies count once")
+	assert.Equal(t, "thread_id", server.Searches()[0].Request.Distinct)
+
+	result, err = searcher.Search(ctx, access.Scope{TenantID: testTenant, MailboxID: testMailbox}, Request{Query: "invoice", Collapse: CollapseThread, Sort: SortOldest})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a1"}, hitIDs(result))
+	assert.Equal(t, 2, result.Hits[0].ThreadSize)
+
+	result, err = searcher.Search(ctx, access.Scope{TenantID: testTenant}, Request{Query: "invoice"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a2", "a1"}, hitIDs(result))
+	assert.Zero(t, result.Hits[0].ThreadSize)
+	assert.Empty(t, server.Searches()[2].Request.Distinct)
+}
+
+// TestSearchQueryLanguage verifies operators narrow results within the scope
+func TestSearchQueryLanguage(t *testing.T) {
+	searcher, server := newTestSearcher(t, SearcherConfig{})
+	ctx := context.Background()
+	scope := access.Scope{TenantID: testTenant}
+
+	for q, want := range map[string][]string{
+		`from:billing@ has:attachment`:             {"a1"},
+		`invoice -label:spam`:                      {"a2", "a1"},
+		`-label:SPAM`:                              {"a2", "a1"},
+		`label:finance`:                            {"a1"},
+		`from:acme.test OR filename:pdf`:           {"a3", "a1"},
+		`subject:"invoice april"`:                  {"a2"},
+		`invoice before:2025-03-02`:                {"a1"},
+		`filename:invoice-march.pdf to:@acme.test`: {"a1"},
+		`"invoice april"`:                          {"a2"},
+		`-(from:bob@ OR label:finance)`:            {"a2"},
+		`march OR lunch`:                           {"a3", "a1"},
+		`-april`:                                   {"a3", "a1"},
+		`-newsletter`:                              {"a3", "a2", "a1"},
+		`invoice -"wire transfer"`:                 {"a2"},
+	} {
+		result, err := searcher.Search(ctx, scope, Request{Query: q})
+		require.NoError(t, err, q)
+		assert.Equal(t, want, hitIDs(result), q)
+	}
+
+	_, err := searcher.Search(ctx, scope, Request{Query: `invoice -label:spam`, Sender: "billing@vendor.test"})
+	require.NoError(t, err)
+	searches := server.Searches()
+	last := searches[len(searches)-1].Request
+	assert.Equal(t, "invoice", last.Query)
+	assert.Equal(t, fmt.Sprintf(`tenant_id = "%s" AND NOT labels = "spam" AND sender = "billing@vendor.test"`, testTenant), last.Filter)
+
+	for q, pos := range map[string]int{
+		`subject:"merger`: 8,
+		`lunch OR "?!"`:   9,
+	} {
+		_, err := searcher.Search(ctx, scope, Request{Query: q})
+		assert.ErrorIs(t, err, ErrInvalidRequest, q)
+		var perr *query.Error
+		require.True(t, errors.As(err, &perr), q)
+		assert.Equal(t, pos, perr.Pos, q)
+	}
+	assert.Len(t, server.Searches(), len(searches), "invalid queries are not sent")
+}
+
+// TestSearchTenantTokens verifies searches run with tenant tokens carrying the scope filter
+func TestSearchTenantTokens(t *testing.T) {
+	searcher, server := newTestSearcher(t, SearcherConfig{TenantTokens: true, TokenTTL: time.Hour})
+	ctx := context.Background()
+	scope := access.Scope{TenantID: testTenant}
+
+	result, err := searcher.Search(ctx, scope, Request{Query: "invoice"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a2", "a1"}, hitIDs(result))
+	_, err = searcher.Search(ctx, scope, Request{Query: "lunch"})
+	require.NoError(t, err)
+	_, err = searcher.Search(ctx, access.Scope{}, Request{})
+	require.NoError(t, err)
+
+	keys := server.Keys()
+	require.Len(t, keys, 1, "the search key is created once")
+	assert.Equal(t, []string{"search"}, keys[0].Actions)
+	assert.Equal(t, []string{EmailsIndex}, keys[0].Indexes)
+
+	searches := server.Searches()
+	require.Len(t, searches, 3)
+	assert.Equal(t, scopeFilter(scope), searches[0].RuleFilter)
+	assert.Equal(t, searches[0].TenantToken, searches[1].TenantToken, "tokens are reused per scope")
+	assert.NotEqual(t, searches[0].TenantToken, searches[2].TenantToken)
+	assert.Empty(t, searches[2].RuleFilter)
+
+	// Tokens are renewed halfway through their lifetime
+	searcher.now = func() time.Time { return time.Now().Add(31 * time.Minute) }
+	_, err = searcher.Search(ctx, scope, Request{})
+	require.NoError(t, err)
+	assert.NotEqual(t, searches[0].TenantToken, server.Searches()[3].TenantToken)
+}
+
+type fakeEmailSearch struct {
+	repositories.EmailRepository
+	searches []repositories.EmailSearch
+	result   repositories.EmailSearchResult
+}
+
+func (f *fakeEmailSearch) Search(_ context.Context, search repositories.EmailSearch) (*repositories.EmailSearchResult, error) {
+	f.searches = append(f.searches, search)
+	return &f.result, nil
+}
+
+// TestSearchFallback verifies searches move to PostgreSQL while Meilisearch
+// fails and return to it after the cooldown
+func TestSearchFallback(t *testing.T) {
+	searcher, server := newTestSearcher(t, SearcherConfig{Fallback: true, FallbackCooldown: time.Minute})
+	subject, sender := "Invoice March", "billing@vendor.test"
+	sentAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	emails := &fakeEmailSearch{result: repositories.EmailSearchResult{
+		Hits: []repositories.EmailSearchHit{{
+			Email: models.Email{ID: "a1", MailboxID: testMailbox, MessageID: "<a1@test>", Subject: &subject, Sender: &sender,
+				SentAt: sentAt, HasAttachments: true, SizeBytes: 2048},
+			TenantID: testTenant,
+			Subject:  "<mark>Invoice</mark> March",
+			Sender:   sender,
+			Snippet:  "Quarterly numbers attached",
+			Attachments: []repositories.EmailSearchAttachment{
+				{ID: "att1", Filename: "march.pdf", Snippet: "<mark>Invoice</mark> total 1,200"},
+			},
+		}},
+		Total:  2,
+		Facets: map[string]map[string]int64{"sender": {sender: 2}},
+	}}
+	sessions := &fakeSessions{repos: &repositories.Repositories{Emails: emails}}
+	searcher.fallback = &postgresBackend{sessions: sessions}
+	clock := time.Now()
+	searcher.now = func() time.Time { return clock }
+	ctx := context.Background()
+	session := database.Session{UserID: userID, TenantID: testTenant, Role: models.RoleTenantAdmin}
+	scope := access.Scope{TenantID: testTenant, Session: session}
+
+	result, err := searcher.Search(ctx, scope, Request{Query: "invoice"})
+	require.NoError(t, err)
+	assert.Equal(t, BackendMeilisearch, result.Backend)
+	assert.Empty(t, emails.searches)
+
+	server.FailSearches(1)
+	req := Request{Query: "invoice -label:spam", MailboxID: testMailbox, Facets: []string{"sender"}, Limit: 1}
+	result, err = searcher.Search(ctx, scope, req)
+	require.NoError(t, err)
+	assert.Equal(t, BackendPostgres, result.Backend)
+	require.Len(t, result.Hits, 1)
+	assert.Equal(t, Hit{ID: "a1", TenantID: testTenant, MailboxID: testMailbox, MessageID: "<a1@test>", Subject: subject, Sender: sender,
+		Recipients: []string{}, SentAt: sentAt, HasAttachments: true, SizeBytes: 2048, Highlights: Highlights{
+			Subject: "<mark>Invoice</mark> March", Sender: sender, Recipients: []string{}, Snippet: "Quarterly numbers attached"},
+		Attachments: []AttachmentHit{{ID: "att1", Filename: "march.pdf", Snippet: "<mark>Invoice</mark> total 1,200"}}},
+		result.Hits[0])
+	assert.Equal(t, int64(2), result.EstimatedTotalHits)
+	assert.Equal(t, map[string]int64{sender: 2}, result.Facets["sender"])
+	require.NotNil(t, result.NextCursor)
+
+	require.Len(t, emails.searches, 1)
+	assert.Equal(t, []database.Session{session}, sessions.sessions, "the fallback runs as the caller")
+	search := emails.searches[0]
+	assert.Equal(t, testTenant, *search.Filter.TenantID)
+	assert.Equal(t, testMailbox, *search.Filter.MailboxID)
+	assert.Equal(t, "(invoice -label:spam)", search.Query.String())
+	assert.Equal(t, 1, search.Limit)
+	assert.Equal(t, MaxTotalHits, search.MaxTotal)
+	assert.Equal(t, HighlightPreTag, search.HighlightPreTag)
+
+	// Meilisearch is left alone during the cooldown
+	result, err = searcher.Search(ctx, scope, Request{Cursor: *result.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, BackendPostgres, result.Backend)
+	assert.Equal(t, int64(1), emails.searches[1].Offset)
+	// Mailboxes outside the scope match nothing
+	result, err = searcher.Search(ctx, access.Scope{TenantID: testTenant, MailboxID: testMailbox}, Request{MailboxID: otherMailbox})
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+	assert.Len(t, emails.searches, 2)
+	assert.Len(t, server.Searches(), 2)
+
+	clock = clock.Add(time.Minute)
+	result, err = searcher.Search(ctx, scope, Request{Query: "invoice"})
+	require.NoError(t, err)
+	assert.Equal(t, BackendMeilisearch, result.Backend)
+	assert.Len(t, server.Searches(), 3)
+
+	// Without the fallback failures are returned
+	searcher, server = newTestSearcher(t, SearcherConfig{})
+	server.FailSearches(1)
+	_, err = searcher.Search(ctx, scope, Request{})
+	require.Error(t, err)
+}
@@ -0,0 +1,511 @@
+package searchtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/meilisearch/meilisearch-go"
+)
+
+// Search is a search request received by the fake server
+type Search struct {
+	Index   string
+	Request meilisearch.SearchRequest
+	// TenantToken is the bearer token when it was a tenant token
+	TenantToken string
+	// RuleFilter is the filter of the tenant token's search rules
+	RuleFilter string
+}
+
+// Searches returns the search requests received so far
+func (s *Server) Searches() []Search {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Search(nil), s.searches...)
+}
+
+// Keys returns the API keys created on the server
+func (s *Server) Keys() []meilisearch.Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]meilisearch.Key(nil), s.keys...)
+}
+
+func (s *Server) handleCreateKey(w http.ResponseWriter, r *http.Request) {
+	var key meilisearch.Key
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	key.UID = uuid.NewString()
+	key.Key = hex.EncodeToString(secret)
+	key.CreatedAt = time.Now().UTC()
+	key.UpdatedAt = key.CreatedAt
+
+	s.mu.Lock()
+	s.keys = append(s.keys, key)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusCreated, key)
+}
+
+func (s *Server) handleGetKeys(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := append([]meilisearch.Key{}, s.keys...)
+	writeJSON(w, http.StatusOK, meilisearch.KeysResults{Results: keys, Limit: 1000, Total: int64(len(keys))})
+}
+
+// handleSearch answers searches like Meilisearch, but every query word (also
+// of phrases) only has to occur somewhere in the searchable attributes and
+// results are sorted by sent_at alone. Filters support comparisons with AND,
+// OR, NOT and parentheses. Facets count matches before distinct applies.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	var req meilisearch.SearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	record := Search{Index: uid, Request: req}
+	filters := []string{}
+	if f, ok := req.Filter.(string); ok && f != "" {
+		filters = append(filters, f)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && strings.Count(raw, ".") == 2 {
+		rule, err := s.verifyTenantToken(raw, uid)
+		if err != nil {
+			writeError(w, http.StatusForbidden, "invalid_api_key", err.Error())
+			return
+		}
+		record.TenantToken = raw
+		record.RuleFilter = rule
+		if rule != "" {
+			filters = append(filters, rule)
+		}
+	}
+	s.searches = append(s.searches, record)
+	if s.searchFailures > 0 {
+		s.searchFailures--
+		writeError(w, http.StatusInternalServerError, "internal", "injected search failure")
+		return
+	}
+
+	idx, ok := s.indexes[uid]
+	if !ok {
+		writeIndexNotFound(w, uid)
+		return
+	}
+	var exprs []filterExpr
+	for _, f := range filters {
+		expr, err := parseFilter(f)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_search_filter", err.Error())
+			return
+		}
+		exprs = append(exprs, expr)
+	}
+
+	words := strings.Fields(strings.ToLower(strings.ReplaceAll(req.Query, `"`, " ")))
+	var matched []map[string]any
+	for _, doc := range idx.documents {
+		if matchesQuery(doc, words, idx.settings.SearchableAttributes) && matchesFilter(doc, exprs) {
+			matched = append(matched, doc)
+		}
+	}
+	desc := !slices.Contains(req.Sort, "sent_at:asc")
+	sort.Slice(matched, func(a, b int) bool {
+		sa, sb := number(matched[a]["sent_at"]), number(matched[b]["sent_at"])
+		if sa != sb {
+			return (sa > sb) == desc
+		}
+		return fmt.Sprint(matched[a]["id"]) < fmt.Sprint(matched[b]["id"])
+	})
+
+	facets := map[string]map[string]int{}
+	for _, facet := range req.Facets {
+		counts := map[string]int{}
+		for _, doc := range matched {
+			for _, value := range values(doc[facet]) {
+				counts[value]++
+			}
+		}
+		facets[facet] = counts
+	}
+
+	if req.Distinct != "" {
+		if !slices.Contains(idx.settings.FilterableAttributes, req.Distinct) {
+			writeError(w, http.StatusBadRequest, "invalid_search_distinct",
+				fmt.Sprintf("Attribute `%s` is not filterable and thus, cannot be used as distinct attribute.", req.Distinct))
+			return
+		}
+		// The best document per value is kept; documents without one are all kept
+		seen := map[string]bool{}
+		distinct := matched[:0:0]
+		for _, doc := range matched {
+			value, ok := doc[req.Distinct]
+			if ok && value != nil {
+				if seen[fmt.Sprint(value)] {
+					continue
+				}
+				seen[fmt.Sprint(value)] = true
+			}
+			distinct = append(distinct, doc)
+		}
+		matched = distinct
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = 20
+	}
+	hits := []map[string]any{}
+	for i := req.Offset; i < int64(len(matched)) && i < req.Offset+limit; i++ {
+		hits = append(hits, formatHit(matched[i], words, &req))
+	}
+	body := map[string]any{
+		"hits":               hits,
+		"query":              req.Query,
+		"offset":             req.Offset,
+		"limit":              limit,
+		"estimatedTotalHits": len(matched),
+		"processingTimeMs":   1,
+	}
+	if len(req.Facets) > 0 {
+		body["facetDistribution"] = facets
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// verifyTenantToken checks a tenant token against the server's keys and
+// returns the filter of its search rules for index uid
+func (s *Server) verifyTenantToken(raw, uid string) (string, error) {
+	var claims struct {
+		APIKeyUID   string `json:"apiKeyUid"`
+		SearchRules any    `json:"searchRules"`
+		jwt.RegisteredClaims
+	}
+	_, err := jwt.ParseWithClaims(raw, &claims, func(*jwt.Token) (any, error) {
+		for _, key := range s.keys {
+			if key.UID == claims.APIKeyUID {
+				return []byte(key.Key), nil
+			}
+		}
+		return nil, fmt.Errorf("unknown API key %q", claims.APIKeyUID)
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return "", fmt.Errorf("The provided API key is invalid: %w", err)
+	}
+	rules, _ := claims.SearchRules.(map[string]any)
+	rule, ok := rules[uid]
+	if !ok {
+		return "", fmt.Errorf("The provided API key is invalid: index `%s` not allowed", uid)
+	}
+	settings, _ := rule.(map[string]any)
+	filter, _ := settings["filter"].(string)
+	return filter, nil
+}
+
+// condition is one comparison of a filter
+type condition struct {
+	attribute string
+	operator  string
+	value     string
+}
+
+// filterExpr reports whether a document matches a filter
+type filterExpr func(doc map[string]any) bool
+
+// filterToken is a token of a filter; quoted marks string literals
+type filterToken struct {
+	text   string
+	quoted bool
+}
+
+var filterTokenPattern = regexp.MustCompile(`^(?:\s+|[()]|!=|>=|<=|=|>|<|"(?:[^"\\]|\\.)*"|[^\s()"=<>!]+)`)
+
+// parseFilter parses comparisons such as tenant_id = "x" combined with AND,
+// OR, NOT and parentheses
+func parseFilter(filter string) (filterExpr, error) {
+	var tokens []filterToken
+	for rest := filter; rest != ""; {
+		m := filterTokenPattern.FindString(rest)
+		if m == "" {
+			return nil, fmt.Errorf("malformed filter at %q", rest)
+		}
+		rest = rest[len(m):]
+		switch {
+		case strings.TrimSpace(m) == "":
+		case strings.HasPrefix(m, `"`):
+			unquoted, err := strconv.Unquote(m)
+			if err != nil {
+				return nil, fmt.Errorf("malformed string %s", m)
+			}
+			tokens = append(tokens, filterToken{text: unquoted, quoted: true})
+		default:
+			tokens = append(tokens, filterToken{text: m})
+		}
+	}
+	p := &filterParser{tokens: tokens}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter %q", p.tokens[p.i].text, filter)
+	}
+	return expr, nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	i      int
+}
+
+// keyword consumes the next token if it is the unquoted word
+func (p *filterParser) keyword(word string) bool {
+	if p.i < len(p.tokens) && !p.tokens[p.i].quoted && p.tokens[p.i].text == word {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) or() (filterExpr, error) {
+	exprs, err := p.list("OR", p.and)
+	if err != nil {
+		return nil, err
+	}
+	return func(doc map[string]any) bool {
+		return slices.ContainsFunc(exprs, func(e filterExpr) bool { return e(doc) })
+	}, nil
+}
+
+func (p *filterParser) and() (filterExpr, error) {
+	exprs, err := p.list("AND", p.not)
+	if err != nil {
+		return nil, err
+	}
+	return func(doc map[string]any) bool {
+		return !slices.ContainsFunc(exprs, func(e filterExpr) bool { return !e(doc) })
+	}, nil
+}
+
+// list parses operands separated by the keyword sep
+func (p *filterParser) list(sep string, operand func() (filterExpr, error)) ([]filterExpr, error) {
+	var exprs []filterExpr
+	for {
+		expr, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+		if !p.keyword(sep) {
+			return exprs, nil
+		}
+	}
+}
+
+func (p *filterParser) not() (filterExpr, error) {
+	if p.keyword("NOT") {
+		expr, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(doc map[string]any) bool { return !expr(doc) }, nil
+	}
+	if p.keyword("(") {
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword(")") {
+			return nil, fmt.Errorf("missing ) in filter")
+		}
+		return expr, nil
+	}
+	if p.i+3 > len(p.tokens) {
+		return nil, fmt.Errorf("incomplete comparison in filter")
+	}
+	attribute, operator, value := p.tokens[p.i], p.tokens[p.i+1], p.tokens[p.i+2]
+	if attribute.quoted || operator.quoted || !slices.Contains([]string{"=", "!=", ">=", "<=", ">", "<"}, operator.text) {
+		return nil, fmt.Errorf("unsupported filter near %q", attribute.text)
+	}
+	p.i += 3
+	c := condition{attribute: attribute.text, operator: operator.text, value: value.text}
+	return func(doc map[string]any) bool { return matchesCondition(doc[c.attribute], c) }, nil
+}
+
+func matchesFilter(doc map[string]any, filters []filterExpr) bool {
+	for _, f := range filters {
+		if !f(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesCondition(value any, c condition) bool {
+	for _, v := range values(value) {
+		switch c.operator {
+		case "=":
+			if v == c.value {
+				return true
+			}
+		case "!=":
+			if v != c.value {
+				return true
+			}
+		default:
+			got, err1 := strconv.ParseFloat(v, 64)
+			want, err2 := strconv.ParseFloat(c.value, 64)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			if (c.operator == ">=" && got >= want) || (c.operator == "<=" && got <= want) ||
+				(c.operator == ">" && got > want) || (c.operator == "<" && got < want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// defaultSearchable are the searched attributes of indexes without settings
+var defaultSearchable = []string{"subject", "sender", "recipients", "attachment_names", "body_text"}
+
+// matchesQuery reports whether every word occurs in a searchable attribute;
+// dotted attributes such as attachments.text search nested objects
+func matchesQuery(doc map[string]any, words []string, searchable []string) bool {
+	if len(searchable) == 0 {
+		searchable = defaultSearchable
+	}
+	var text strings.Builder
+	for _, attribute := range searchable {
+		for _, v := range lookup(doc, strings.Split(attribute, ".")) {
+			text.WriteString(strings.ToLower(v))
+			text.WriteByte(' ')
+		}
+	}
+	for _, word := range words {
+		if !strings.Contains(text.String(), word) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup returns the values at a dotted path, descending into arrays
+func lookup(value any, path []string) []string {
+	if len(path) == 0 {
+		return values(value)
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		return lookup(v[path[0]], path[1:])
+	case []any:
+		var out []string
+		for _, item := range v {
+			out = append(out, lookup(item, path)...)
+		}
+		return out
+	}
+	return nil
+}
+
+// formatHit adds _formatted with the highlighted and cropped attributes
+func formatHit(doc map[string]any, words []string, req *meilisearch.SearchRequest) map[string]any {
+	hit := make(map[string]any, len(doc)+1)
+	for k, v := range doc {
+		hit[k] = v
+	}
+	var formatted any = doc
+	attributes := slices.Concat(req.AttributesToHighlight, req.AttributesToCrop)
+	slices.Sort(attributes)
+	for _, attribute := range slices.Compact(attributes) {
+		formatted = format(formatted, strings.Split(attribute, "."), func(s string) string { return highlight(s, words, req) })
+	}
+	hit["_formatted"] = formatted
+	return hit
+}
+
+// format returns a copy of value with fn applied to the strings at a dotted
+// path, descending into arrays
+func format(value any, path []string, fn func(string) string) any {
+	switch v := value.(type) {
+	case string:
+		if len(path) == 0 {
+			return fn(v)
+		}
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = format(item, path, fn)
+		}
+		return out
+	case map[string]any:
+		if len(path) == 0 {
+			return v
+		}
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[k] = item
+		}
+		if item, ok := v[path[0]]; ok {
+			out[path[0]] = format(item, path[1:], fn)
+		}
+		return out
+	case nil:
+		return nil
+	}
+	if len(path) == 0 {
+		return fn(fmt.Sprint(value))
+	}
+	return value
+}
+
+func highlight(s string, words []string, req *meilisearch.SearchRequest) string {
+	for _, word := range words {
+		pattern := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(word))
+		s = pattern.ReplaceAllString(s, req.HighlightPreTag+"$0"+req.HighlightPostTag)
+	}
+	return s
+}
+
+// values returns the filter values of an attribute; arrays match per element
+func values(value any) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []any:
+		out := make([]string, len(v))
+		for i, item := range v {
+			out[i] = fmt.Sprint(item)
+		}
+		return out
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+func number(value any) float64 {
+	f, _ := value.(float64)
+	return f
+}
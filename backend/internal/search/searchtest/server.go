@@ -0,0 +1,402 @@
+// Package searchtest provides an in-memory fake of the Meilisearch index,
+// document, settings, task, key and search API for tests.
+package searchtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/meilisearch/meilisearch-go"
+)
+
+// Task is a processed task of the fake server. Tasks complete as soon as
+// they are enqueued.
+type Task struct {
+	UID      int64          `json:"uid"`
+	IndexUID string         `json:"indexUid"`
+	Type     string         `json:"type"`
+	Status   string         `json:"status"`
+	Error    *taskError     `json:"error,omitempty"`
+	Details  map[string]any `json:"details,omitempty"`
+}
+
+type taskError struct {
+	Message string `json:"message"`
+	Code    string `json:"code"`
+	Type    string `json:"type"`
+}
+
+type index struct {
+	primaryKey string
+	settings   meilisearch.Settings
+	documents  map[string]map[string]any
+	createdAt  time.Time
+}
+
+// Server is a fake Meilisearch endpoint backed by an httptest.Server
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	indexes  map[string]*index
+	tasks    []Task
+	swaps    int
+	failures int
+	// searchFailures is the number of searches still to fail
+	searchFailures int
+	keys           []meilisearch.Key
+	searches       []Search
+}
+
+// NewServer starts a fake Meilisearch server without indexes
+func NewServer() *Server {
+	s := &Server{indexes: make(map[string]*index)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /indexes", s.handleCreateIndex)
+	mux.HandleFunc("GET /indexes/{uid}", s.handleGetIndex)
+	mux.HandleFunc("DELETE /indexes/{uid}", s.handleDeleteIndex)
+	mux.HandleFunc("GET /indexes/{uid}/settings", s.handleGetSettings)
+	mux.HandleFunc("PATCH /indexes/{uid}/settings", s.handleUpdateSettings)
+	mux.HandleFunc("POST /indexes/{uid}/documents", s.handleAddDocuments)
+	mux.HandleFunc("POST /indexes/{uid}/documents/fetch", s.handleFetchDocuments)
+	mux.HandleFunc("POST /indexes/{uid}/documents/delete-batch", s.handleDeleteDocuments)
+	mux.HandleFunc("POST /swap-indexes", s.handleSwap)
+	mux.HandleFunc("GET /tasks/{uid}", s.handleGetTask)
+	mux.HandleFunc("POST /indexes/{uid}/search", s.handleSearch)
+	mux.HandleFunc("POST /keys", s.handleCreateKey)
+	mux.HandleFunc("GET /keys", s.handleGetKeys)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// AddIndex creates an index with settings and documents, as left by an
+// earlier deployment
+func (s *Server) AddIndex(uid, primaryKey string, settings meilisearch.Settings, docs ...map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx := &index{primaryKey: primaryKey, settings: settings, documents: make(map[string]map[string]any), createdAt: time.Now()}
+	for _, doc := range docs {
+		idx.documents[fmt.Sprint(doc[primaryKey])] = doc
+	}
+	s.indexes[uid] = idx
+}
+
+// Indexes returns the UIDs of all indexes in order
+func (s *Server) Indexes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	uids := make([]string, 0, len(s.indexes))
+	for uid := range s.indexes {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	return uids
+}
+
+// Settings returns the settings of an index
+func (s *Server) Settings(uid string) (meilisearch.Settings, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, ok := s.indexes[uid]
+	if !ok {
+		return meilisearch.Settings{}, false
+	}
+	return idx.settings, true
+}
+
+// Documents returns the documents of an index by primary key
+func (s *Server) Documents(uid string) map[string]map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	docs := make(map[string]map[string]any)
+	if idx, ok := s.indexes[uid]; ok {
+		for id, doc := range idx.documents {
+			docs[id] = doc
+		}
+	}
+	return docs
+}
+
+// Tasks returns the tasks processed so far
+func (s *Server) Tasks() []Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Task(nil), s.tasks...)
+}
+
+// Swaps returns how many index swaps were processed
+func (s *Server) Swaps() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.swaps
+}
+
+// FailTasks makes the next n document writes fail as tasks, e.g. to
+// exercise retries
+func (s *Server) FailTasks(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+}
+
+// FailSearches makes the next n searches fail with an internal error, e.g.
+// to exercise the search fallback
+func (s *Server) FailSearches(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.searchFailures = n
+}
+
+// injectFailure consumes an injected failure and reports whether the task
+// must fail. Callers hold s.mu.
+func (s *Server) injectFailure() bool {
+	if s.failures == 0 {
+		return false
+	}
+	s.failures--
+	return true
+}
+
+// task records a processed task and answers with its summary; a non-empty
+// code fails it. Callers hold s.mu.
+func (s *Server) task(w http.ResponseWriter, indexUID, taskType, code, message string) {
+	t := Task{UID: int64(len(s.tasks) + 1), IndexUID: indexUID, Type: taskType, Status: "succeeded"}
+	if code != "" {
+		t.Status = "failed"
+		t.Error = &taskError{Message: message, Code: code, Type: "invalid_request"}
+	}
+	s.tasks = append(s.tasks, t)
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"taskUid":    t.UID,
+		"indexUid":   indexUID,
+		"status":     "enqueued",
+		"type":       taskType,
+		"enqueuedAt": time.Now().UTC(),
+	})
+}
+
+func (s *Server) handleCreateIndex(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		UID        string `json:"uid"`
+		PrimaryKey string `json:"primaryKey"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.indexes[body.UID]; ok {
+		s.task(w, body.UID, "indexCreation", "index_already_exists", fmt.Sprintf("Index `%s` already exists.", body.UID))
+		return
+	}
+	s.indexes[body.UID] = &index{primaryKey: body.PrimaryKey, documents: make(map[string]map[string]any), createdAt: time.Now()}
+	s.task(w, body.UID, "indexCreation", "", "")
+}
+
+func (s *Server) handleGetIndex(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, ok := s.indexes[uid]
+	if !ok {
+		writeIndexNotFound(w, uid)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"uid":        uid,
+		"primaryKey": idx.primaryKey,
+		"createdAt":  idx.createdAt.UTC(),
+		"updatedAt":  idx.createdAt.UTC(),
+	})
+}
+
+func (s *Server) handleDeleteIndex(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.indexes[uid]; !ok {
+		s.task(w, uid, "indexDeletion", "index_not_found", fmt.Sprintf("Index `%s` not found.", uid))
+		return
+	}
+	delete(s.indexes, uid)
+	s.task(w, uid, "indexDeletion", "", "")
+}
+
+func (s *Server) handleGetSettings(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, ok := s.indexes[uid]
+	if !ok {
+		writeIndexNotFound(w, uid)
+		return
+	}
+	writeJSON(w, http.StatusOK, idx.settings)
+}
+
+func (s *Server) handleUpdateSettings(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	var settings meilisearch.Settings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, ok := s.indexes[uid]
+	if !ok {
+		s.task(w, uid, "settingsUpdate", "index_not_found", fmt.Sprintf("Index `%s` not found.", uid))
+		return
+	}
+	idx.settings = settings
+	s.task(w, uid, "settingsUpdate", "", "")
+}
+
+func (s *Server) handleAddDocuments(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	var docs []map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&docs); err != nil {
+		writeError(w, http.StatusBadRequest, "malformed_payload", err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.injectFailure() {
+		s.task(w, uid, "documentAdditionOrUpdate", "internal", "Injected failure.")
+		return
+	}
+	idx, ok := s.indexes[uid]
+	if !ok {
+		// Meilisearch creates missing indexes on the first write
+		idx = &index{primaryKey: r.URL.Query().Get("primaryKey"), documents: make(map[string]map[string]any), createdAt: time.Now()}
+		s.indexes[uid] = idx
+	}
+	if idx.primaryKey == "" {
+		idx.primaryKey = r.URL.Query().Get("primaryKey")
+	}
+	for _, doc := range docs {
+		id, ok := doc[idx.primaryKey]
+		if !ok {
+			s.task(w, uid, "documentAdditionOrUpdate", "missing_document_id", "Document doesn't have a primary key.")
+			return
+		}
+		idx.documents[fmt.Sprint(id)] = doc
+	}
+	s.task(w, uid, "documentAdditionOrUpdate", "", "")
+}
+
+func (s *Server) handleFetchDocuments(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	var query meilisearch.DocumentsQuery
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = 20
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, ok := s.indexes[uid]
+	if !ok {
+		writeIndexNotFound(w, uid)
+		return
+	}
+	ids := make([]string, 0, len(idx.documents))
+	for id := range idx.documents {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	results := []map[string]any{}
+	for i := query.Offset; i < int64(len(ids)) && i < query.Offset+query.Limit; i++ {
+		results = append(results, idx.documents[ids[i]])
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"results": results, "offset": query.Offset, "limit": query.Limit, "total": len(ids)})
+}
+
+func (s *Server) handleDeleteDocuments(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	var ids []string
+	if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+		writeError(w, http.StatusBadRequest, "malformed_payload", err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.injectFailure() {
+		s.task(w, uid, "documentDeletion", "internal", "Injected failure.")
+		return
+	}
+	idx, ok := s.indexes[uid]
+	if !ok {
+		s.task(w, uid, "documentDeletion", "index_not_found", fmt.Sprintf("Index `%s` not found.", uid))
+		return
+	}
+	for _, id := range ids {
+		delete(idx.documents, id)
+	}
+	s.task(w, uid, "documentDeletion", "", "")
+}
+
+func (s *Server) handleSwap(w http.ResponseWriter, r *http.Request) {
+	var swaps []meilisearch.SwapIndexesParams
+	if err := json.NewDecoder(r.Body).Decode(&swaps); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, swap := range swaps {
+		if len(swap.Indexes) != 2 {
+			writeError(w, http.StatusBadRequest, "invalid_swap_indexes", "Two indexes must be swapped.")
+			return
+		}
+		for _, uid := range swap.Indexes {
+			if _, ok := s.indexes[uid]; !ok {
+				s.task(w, "", "indexSwap", "index_not_found", fmt.Sprintf("Index `%s` not found.", uid))
+				return
+			}
+		}
+	}
+	for _, swap := range swaps {
+		a, b := swap.Indexes[0], swap.Indexes[1]
+		s.indexes[a], s.indexes[b] = s.indexes[b], s.indexes[a]
+	}
+	s.swaps++
+	s.task(w, "", "indexSwap", "", "")
+}
+
+func (s *Server) handleGetTask(w http.ResponseWriter, r *http.Request) {
+	var uid int64
+	if _, err := fmt.Sscan(r.PathValue("uid"), &uid); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_task_uid", err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if uid < 1 || uid > int64(len(s.tasks)) {
+		writeError(w, http.StatusNotFound, "task_not_found", fmt.Sprintf("Task `%d` not found.", uid))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.tasks[uid-1])
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, taskError{Message: message, Code: code, Type: "invalid_request"})
+}
+
+func writeIndexNotFound(w http.ResponseWriter, uid string) {
+	writeError(w, http.StatusNotFound, "index_not_found", fmt.Sprintf("Index `%s` not found.", uid))
+}
@@ -0,0 +1,463 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/extract"
+	"ironarchive/internal/graph"
+	"ironarchive/internal/models"
+	"ironarchive/internal/storage"
+	"ironarchive/internal/threads"
+)
+
+// errAlreadyArchived aborts an archive transaction that lost a race with another sync
+var errAlreadyArchived = errors.New("message already archived")
+
+// CredentialSource provides decrypted tenant app credentials (the vault)
+type CredentialSource interface {
+	Credentials(ctx context.Context, tenantID string) (*models.AzureCredentials, error)
+}
+
+// SyncResult summarizes a mailbox sync
+type SyncResult struct {
+	Folders      int   `json:"folders"`
+	Added        int   `json:"emailsAdded"`
+	Skipped      int   `json:"emailsSkipped"`
+	Removed      int   `json:"emailsRemovedUpstream"`
+	Bytes        int64 `json:"bytesAdded"`        // Physical bytes; content the tenant already stored is free
+	LogicalBytes int64 `json:"logicalBytesAdded"` // Every added message and attachment in full
+	FullResyncs  int   `json:"fullResyncs"`
+}
+
+// Add accumulates another result
+func (r *SyncResult) Add(o SyncResult) {
+	r.Folders += o.Folders
+	r.Added += o.Added
+	r.Skipped += o.Skipped
+	r.Removed += o.Removed
+	r.Bytes += o.Bytes
+	r.LogicalBytes += o.LogicalBytes
+	r.FullResyncs += o.FullResyncs
+}
+
+// SyncProgress is called after each delta page and folder of a mailbox sync
+// with the number of completed folders and the totals so far
+type SyncProgress func(foldersDone, foldersTotal int, sofar SyncResult)
+
+// SyncService archives M365 mailboxes through Graph delta queries
+type SyncService struct {
+	store   *repositories.Store
+	creds   CredentialSource
+	graph   *graph.Client
+	archive *storage.Archive
+	logger  *zap.Logger
+	now     func() time.Time
+}
+
+// NewSyncService creates a sync service
+func NewSyncService(store *repositories.Store, creds CredentialSource, graphClient *graph.Client, archive *storage.Archive, logger *zap.Logger) *SyncService {
+	return &SyncService{
+		store:   store,
+		creds:   creds,
+		graph:   graphClient,
+		archive: archive,
+		logger:  logger,
+		now:     time.Now,
+	}
+}
+
+// mailboxSync carries the state of one mailbox sync
+type mailboxSync struct {
+	*SyncService
+	client  *graph.TenantClient
+	tenant  *models.Tenant
+	mailbox *models.Mailbox
+	logger  *zap.Logger
+
+	// report forwards the running totals of the current folder to the caller
+	report func(folder SyncResult)
+}
+
+// SyncMailbox walks every folder of a mailbox and archives new messages.
+// Content is fetched in $batch calls and each message is committed on its
+// own; a folder's delta link is stored only after all messages of the round
+// are committed, so an interrupted sync resumes from the last completed round.
+func (s *SyncService) SyncMailbox(ctx context.Context, mailboxID string, progress SyncProgress) (SyncResult, error) {
+	var result SyncResult
+
+	mailbox, err := s.store.Mailboxes.GetByID(ctx, mailboxID)
+	if err != nil {
+		return result, fmt.Errorf("failed to load mailbox: %w", err)
+	}
+	tenant, err := s.store.Tenants.GetByID(ctx, mailbox.TenantID)
+	if err != nil {
+		return result, fmt.Errorf("failed to load tenant: %w", err)
+	}
+	creds, err := s.creds.Credentials(ctx, tenant.ID)
+	if err != nil {
+		return result, fmt.Errorf("failed to load tenant credentials: %w", err)
+	}
+
+	m := &mailboxSync{
+		SyncService: s,
+		client:      s.graph.Tenant(tenant.AzureTenantID, *creds),
+		tenant:      tenant,
+		mailbox:     mailbox,
+		logger:      s.logger.With(zap.String("tenant_id", tenant.ID), zap.String("mailbox_id", mailbox.ID)),
+	}
+
+	folders, err := m.client.ListMailFolders(ctx, mailbox.EmailAddress)
+	if err != nil {
+		return result, err
+	}
+	done := 0
+	m.report = func(folder SyncResult) {
+		if progress != nil {
+			sofar := result
+			sofar.Add(folder)
+			progress(done, len(folders), sofar)
+		}
+	}
+	for _, f := range folders {
+		folderResult, err := m.syncFolder(ctx, f)
+		result.Add(folderResult)
+		if err != nil {
+			return result, fmt.Errorf("failed to sync folder %q: %w", f.DisplayName, err)
+		}
+		done++
+		m.report(SyncResult{})
+	}
+
+	if err := s.store.Mailboxes.UpdateLastSync(ctx, mailbox.ID, s.now()); err != nil {
+		return result, fmt.Errorf("failed to record mailbox sync: %w", err)
+	}
+	m.logger.Info("Mailbox sync completed",
+		zap.Int("folders", result.Folders),
+		zap.Int("added", result.Added),
+		zap.Int("skipped", result.Skipped),
+		zap.Int64("bytes", result.Bytes),
+		zap.Int64("logical_bytes", result.LogicalBytes),
+	)
+	return result, nil
+}
+
+// syncFolder runs one delta round over a folder, falling back to a full walk
+// when the stored delta link has expired
+func (m *mailboxSync) syncFolder(ctx context.Context, f graph.MailFolder) (SyncResult, error) {
+	result := SyncResult{Folders: 1}
+
+	folder := &models.MailboxFolder{MailboxID: m.mailbox.ID, FolderID: f.ID, DisplayName: &f.DisplayName}
+	if err := m.store.Folders.Upsert(ctx, folder); err != nil {
+		return result, fmt.Errorf("failed to record folder: %w", err)
+	}
+
+	deltaLink := ""
+	if folder.DeltaLink != nil {
+		deltaLink = *folder.DeltaLink
+	}
+	handle := func(messages []graph.Message) error {
+		if err := m.archivePage(ctx, folder.ID, messages, &result); err != nil {
+			return err
+		}
+		m.report(result)
+		return nil
+	}
+
+	next, err := m.client.MessageDelta(ctx, m.mailbox.EmailAddress, f.ID, deltaLink, handle)
+	if err != nil && deltaLink != "" && graph.IsDeltaExpired(err) {
+		m.logger.Warn("Delta link expired, resyncing folder", zap.String("folder", f.DisplayName))
+		if err := m.store.Folders.ResetDelta(ctx, folder.ID); err != nil {
+			return result, fmt.Errorf("failed to reset folder delta: %w", err)
+		}
+		result.FullResyncs++
+		next, err = m.client.MessageDelta(ctx, m.mailbox.EmailAddress, f.ID, "", handle)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if err := m.store.Folders.CommitDelta(ctx, folder.ID, next, result.Added, m.now()); err != nil {
+		return result, fmt.Errorf("failed to store folder delta link: %w", err)
+	}
+	return result, nil
+}
+
+// archivePage archives the new messages of a delta page. Their content is
+// downloaded with batched requests; each message is then committed on its own.
+// Messages already archived only have their folder and flags refreshed.
+func (m *mailboxSync) archivePage(ctx context.Context, folderID string, messages []graph.Message, result *SyncResult) error {
+	var fresh []graph.Message
+	for _, msg := range messages {
+		// Deleted upstream: the archive keeps its copy
+		if msg.Removed != nil {
+			result.Removed++
+			continue
+		}
+		if archived, err := m.store.Emails.GetByMessageID(ctx, m.mailbox.ID, msg.ID); err == nil {
+			if err := m.refreshState(ctx, archived, folderID, msg); err != nil {
+				return err
+			}
+			result.Skipped++
+			continue
+		} else if !errors.Is(err, repositories.ErrNotFound) {
+			return fmt.Errorf("failed to check for archived message: %w", err)
+		}
+		fresh = append(fresh, msg)
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	contents, err := m.client.FetchMessages(ctx, m.mailbox.EmailAddress, fresh)
+	if err != nil {
+		return err
+	}
+	for i := range fresh {
+		err := contents[i].Err
+		if err == nil {
+			err = m.archiveMessage(ctx, folderID, fresh[i], &contents[i], result)
+		}
+		if isMessageGone(err) {
+			// Deleted between the delta page and the download; the next round reports it
+			result.Skipped++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshState stores the folder and flags of an archived message when
+// they changed upstream
+func (m *mailboxSync) refreshState(ctx context.Context, email *models.Email, folderID string, msg graph.Message) error {
+	isRead, flagStatus := messageState(msg)
+	if email.FolderID != nil && *email.FolderID == folderID && email.IsRead == isRead && email.FlagStatus == flagStatus {
+		return nil
+	}
+	email.FolderID, email.IsRead, email.FlagStatus = &folderID, isRead, flagStatus
+	if err := m.store.Emails.SetFolderState(ctx, email); err != nil {
+		return fmt.Errorf("failed to update message state: %w", err)
+	}
+	return nil
+}
+
+// isMessageGone reports whether a message, but not its mailbox, no longer exists
+func isMessageGone(err error) bool {
+	return graph.IsNotFound(err) && !graph.IsMailboxUnavailable(err)
+}
+
+// archiveMessage stores one message and its attachments. Blobs are written
+// (and synced) before the rows referencing them are committed. MIME is
+// stored once per tenant: a copy delivered to another mailbox references the
+// existing blob and adds only to the logical usage. Physical usage follows
+// the rows rather than the files: MIME counts when its message_blobs row is
+// created and an attachment when the tenant's first row with its hash is, so
+// blobs left behind by an interrupted sync are counted on the next attempt.
+func (m *mailboxSync) archiveMessage(ctx context.Context, folderID string, msg graph.Message, content *graph.MessageContent, result *SyncResult) error {
+	email := emailFromMessage(m.mailbox.ID, msg, m.now())
+	email.FolderID = &folderID
+	mime, err := content.OpenMIME(ctx)
+	if err != nil {
+		return err
+	}
+	var capture threads.HeaderCapture
+	info, _, err := m.archive.PutMessage(ctx, m.tenant.ID, io.TeeReader(mime, &capture))
+	mime.Close()
+	if err != nil {
+		return fmt.Errorf("failed to store message MIME: %w", err)
+	}
+	if header, err := capture.Header(); err == nil {
+		header.Apply(email)
+	} else {
+		m.logger.Debug("No threading headers in message", zap.String("message_id", msg.ID), zap.Error(err))
+	}
+	email.SizeBytes = int(info.Size)
+	blob := &models.MessageBlob{TenantID: m.tenant.ID, SHA256Hash: info.SHA256, FilePath: info.URI, SizeBytes: info.Size}
+
+	attachments, err := m.storeAttachments(ctx, content.Attachments)
+	if err != nil {
+		return err
+	}
+	email.HasAttachments = len(attachments) > 0
+	logical := info.Size
+	for _, a := range attachments {
+		logical += int64(a.SizeBytes)
+	}
+
+	var physical int64
+	err = m.store.WithTx(ctx, func(repos *repositories.Repositories) error {
+		created, err := repos.MessageBlobs.Upsert(ctx, blob)
+		if err != nil {
+			return fmt.Errorf("failed to record message blob: %w", err)
+		}
+		email.BlobID, email.FilePath = &blob.ID, blob.FilePath
+		physical = 0
+		if created {
+			physical = blob.SizeBytes
+		}
+
+		if err := repos.Emails.Create(ctx, email); err != nil {
+			if errors.Is(err, repositories.ErrConflict) {
+				return errAlreadyArchived
+			}
+			return fmt.Errorf("failed to insert email: %w", err)
+		}
+		if _, err := repos.Threads.Assign(ctx, m.tenant.ID, email.ID, threads.Keys(email)); err != nil {
+			return fmt.Errorf("failed to assign thread: %w", err)
+		}
+		for i := range attachments {
+			stored, err := repos.Attachments.ReserveHash(ctx, m.tenant.ID, attachments[i].SHA256Hash)
+			if err != nil {
+				return fmt.Errorf("failed to reserve attachment: %w", err)
+			}
+			if !stored {
+				physical += int64(attachments[i].SizeBytes)
+			}
+			attachments[i].EmailID = email.ID
+			if err := repos.Attachments.Create(ctx, &attachments[i]); err != nil {
+				return fmt.Errorf("failed to insert attachment: %w", err)
+			}
+		}
+		if err := repos.Mailboxes.AddUsage(ctx, m.mailbox.ID, 1, logical, physical); err != nil {
+			return fmt.Errorf("failed to update mailbox usage: %w", err)
+		}
+		if err := repos.Tenants.AddStorageBytes(ctx, m.tenant.ID, logical, physical); err != nil {
+			return fmt.Errorf("failed to update tenant usage: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, errAlreadyArchived) {
+		result.Skipped++
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	result.Added++
+	result.Bytes += physical
+	result.LogicalBytes += logical
+	return nil
+}
+
+// storeAttachments writes file and item attachments into the
+// content-addressed store
+func (m *mailboxSync) storeAttachments(ctx context.Context, list []graph.AttachmentContent) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	for i := range list {
+		a := &list[i]
+		// Reference attachments are links to OneDrive/SharePoint files, not content
+		if a.ODataType == graph.AttachmentTypeReference {
+			continue
+		}
+		filename := a.Name
+		if a.ODataType == graph.AttachmentTypeItem && storage.AttachmentExtension(filename) == "" {
+			filename += ".eml"
+		}
+
+		content, err := a.Open(ctx)
+		if err != nil {
+			return nil, err
+		}
+		info, _, err := m.archive.PutAttachment(ctx, m.tenant.ID, filename, content)
+		content.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to store attachment: %w", err)
+		}
+
+		attachment := models.Attachment{
+			Filename:   filename,
+			SizeBytes:  int(info.Size),
+			SHA256Hash: info.SHA256,
+			FilePath:   info.URI,
+		}
+		if a.ContentType != "" {
+			contentType := a.ContentType
+			attachment.ContentType = &contentType
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
+// emailFromMessage maps Graph message metadata to an email row
+func emailFromMessage(mailboxID string, msg graph.Message, now time.Time) *models.Email {
+	email := &models.Email{
+		MailboxID:  mailboxID,
+		MessageID:  msg.ID,
+		Recipients: []string{},
+		Categories: []string{},
+	}
+	if ids := threads.ParseMessageIDs(msg.InternetMessageID); len(ids) > 0 {
+		email.InternetMessageID = &ids[0]
+	}
+	if msg.ConversationID != "" {
+		conversationID := msg.ConversationID
+		email.ConversationID = &conversationID
+	}
+	email.IsRead, email.FlagStatus = messageState(msg)
+	if msg.Subject != "" {
+		subject := msg.Subject
+		email.Subject = &subject
+	}
+	if msg.From != nil && msg.From.EmailAddress.Address != "" {
+		sender := msg.From.EmailAddress.Address
+		email.Sender = &sender
+	}
+	for _, list := range [][]graph.Recipient{msg.ToRecipients, msg.CcRecipients, msg.BccRecipients} {
+		for _, r := range list {
+			if r.EmailAddress.Address != "" {
+				email.Recipients = append(email.Recipients, r.EmailAddress.Address)
+			}
+		}
+	}
+
+	for _, category := range msg.Categories {
+		if category != "" {
+			email.Categories = append(email.Categories, category)
+		}
+	}
+
+	// Drafts have no send time; fall back to receipt and then archive time
+	switch {
+	case msg.SentDateTime != nil:
+		email.SentAt = msg.SentDateTime.UTC()
+	case msg.ReceivedDateTime != nil:
+		email.SentAt = msg.ReceivedDateTime.UTC()
+	default:
+		email.SentAt = now.UTC()
+	}
+
+	if msg.Body != nil && msg.Body.Content != "" {
+		content := msg.Body.Content
+		if msg.Body.ContentType == "html" {
+			text := extract.HTMLToText(content)
+			email.BodyHTML = &content
+			email.BodyText = &text
+		} else {
+			email.BodyText = &content
+		}
+	}
+	return email
+}
+
+// messageState returns the read state and follow-up flag status of a message
+func messageState(msg graph.Message) (bool, string) {
+	flagStatus := models.FlagStatusNotFlagged
+	if msg.Flag != nil {
+		switch msg.Flag.FlagStatus {
+		case models.FlagStatusFlagged, models.FlagStatusComplete:
+			flagStatus = msg.Flag.FlagStatus
+		}
+	}
+	return msg.IsRead, flagStatus
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database/dbtest"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/graph"
+	"ironarchive/internal/graph/graphtest"
+	"ironarchive/internal/models"
+	"ironarchive/internal/storage"
+)
+
+const (
+	testAzureTenant = "22222222-2222-2222-2222-222222222222"
+	testUser        = "alice@contoso.com"
+)
+
+// staticCredentials returns the same app credentials for every tenant
+type staticCredentials models.AzureCredentials
+
+func (c staticCredentials) Credentials(context.Context, string) (*models.AzureCredentials, error) {
+	creds := models.AzureCredentials(c)
+	return &creds, nil
+}
+
+// syncFixture wires a sync service to the test database, a temp archive and a fake Graph server
+type syncFixture struct {
+	store   *repositories.Store
+	server  *graphtest.Server
+	archive *storage.Archive
+	service *SyncService
+	tenant  *models.Tenant
+	mailbox *models.Mailbox
+}
+
+// setupSyncTest migrates the test database and creates a tenant with one mailbox
+func setupSyncTest(t *testing.T, pageSize int) *syncFixture {
+	t.Helper()
+	ctx := context.Background()
+
+	store := repositories.NewStore(dbtest.Connect(t))
+
+	server := graphtest.NewServer(testAzureTenant, "app-id", "app-secret")
+	t.Cleanup(server.Close)
+	server.AddFolder(testUser, graphtest.Folder{ID: "inbox", DisplayName: "Inbox"})
+	server.AddFolder(testUser, graphtest.Folder{ID: "archive", DisplayName: "Archive", ParentID: "inbox"})
+
+	graphClient, err := graph.NewClient(graph.Config{BaseURL: server.BaseURL(), LoginURL: server.LoginURL(), PageSize: pageSize})
+	require.NoError(t, err)
+	local, err := storage.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	tenant := &models.Tenant{Name: "Contoso", AzureTenantID: testAzureTenant}
+	require.NoError(t, store.Tenants.Create(ctx, tenant))
+	mailbox := &models.Mailbox{TenantID: tenant.ID, EmailAddress: testUser, MailboxType: models.MailboxTypeUser, SyncEnabled: true}
+	require.NoError(t, store.Mailboxes.Create(ctx, mailbox))
+
+	creds := staticCredentials{ClientID: "app-id", ClientSecret: "app-secret"}
+	archive := storage.NewArchive(local)
+	return &syncFixture{
+		store:   store,
+		server:  server,
+		archive: archive,
+		service: NewSyncService(store, creds, graphClient, archive, zap.NewNop()),
+		tenant:  tenant,
+		mailbox: mailbox,
+	}
+}
+
+// addMessage adds a message with optional attachments to the fake mailbox
+func (f *syncFixture) addMessage(id, folderID string, attachments ...graphtest.Attachment) {
+	f.server.AddMessage(testUser, graphtest.Message{
+		ID:          id,
+		FolderID:    folderID,
+		Subject:     "Subject " + id,
+		From:        "bob@contoso.com",
+		To:          []string{testUser},
+		SentAt:      time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC),
+		Body:        "<p>Body of " + id + "</p>",
+		Attachments: attachments,
+	})
+}
+
+// TestSyncMailboxArchivesMessages verifies MIME, metadata, attachments and counters are stored
+func TestSyncMailboxArchivesMessages(t *testing.T) {
+	f := setupSyncTest(t, 2)
+	ctx := context.Background()
+
+	shared := graphtest.Attachment{ID: "a1", Name: "terms.pdf", ContentType: "application/pdf", Content: []byte("%PDF shared terms")}
+	f.addMessage("m1", "inbox", shared)
+	f.addMessage("m2", "inbox", graphtest.Attachment{ID: "a1", Name: "terms-copy.pdf", ContentType: "application/pdf", Content: shared.Content})
+	f.addMessage("m3", "archive")
+
+	result, err := f.service.SyncMailbox(ctx, f.mailbox.ID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Folders)
+	assert.Equal(t, 3, result.Added)
+
+	email, err := f.store.Emails.GetByMessageID(ctx, f.mailbox.ID, "m1")
+	require.NoError(t, err)
+	assert.Equal(t, "Subject m1", *email.Subject)
+	assert.Equal(t, "bob@contoso.com", *email.Sender)
+	assert.Equal(t, []string{testUser}, email.Recipients)
+	assert.Equal(t, "Body of m1", *email.BodyText)
+	assert.True(t, email.HasAttachments)
+	assert.Contains(t, email.FilePath, ".eml")
+
+	attachments, err := f.store.Attachments.ListByEmail(ctx, email.ID)
+	require.NoError(t, err)
+	require.Len(t, attachments, 1)
+	other, err := f.store.Emails.GetByMessageID(ctx, f.mailbox.ID, "m2")
+	require.NoError(t, err)
+	otherAttachments, err := f.store.Attachments.ListByEmail(ctx, other.ID)
+	require.NoError(t, err)
+	require.Len(t, otherAttachments, 1)
+	assert.Equal(t, attachments[0].FilePath, otherAttachments[0].FilePath, "identical content is stored once")
+
+	mailbox, err := f.store.Mailboxes.GetByID(ctx, f.mailbox.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, mailbox.EmailCount)
+	assert.Equal(t, result.Bytes, mailbox.StorageBytes)
+	assert.Equal(t, result.LogicalBytes, mailbox.LogicalStorageBytes)
+	assert.Equal(t, result.LogicalBytes-result.Bytes, int64(len(shared.Content)), "the shared attachment counts once physically")
+	assert.NotNil(t, mailbox.LastSyncAt)
+	tenant, err := f.store.Tenants.GetByID(ctx, f.tenant.ID)
+	require.NoError(t, err)
+	assert.Equal(t, result.Bytes, tenant.StorageBytes)
+	assert.Equal(t, result.LogicalBytes, tenant.LogicalStorageBytes)
+
+	folders, err := f.store.Folders.ListByMailbox(ctx, f.mailbox.ID)
+	require.NoError(t, err)
+	require.Len(t, folders, 2)
+	for _, folder := range folders {
+		assert.NotNil(t, folder.DeltaLink, "folder %s", folder.FolderID)
+	}
+}
+
+// TestSyncMailboxCountsLeftoverBlobs verifies blobs written by an attempt
+// whose transaction never committed count physically once archived
+func TestSyncMailboxCountsLeftoverBlobs(t *testing.T) {
+	f := setupSyncTest(t, 10)
+	ctx := context.Background()
+
+	report := graphtest.Attachment{ID: "a1", Name: "report.pdf", ContentType: "application/pdf", Content: []byte("%PDF quarterly report")}
+	f.addMessage("m1", "inbox", report)
+	_, created, err := f.archive.PutAttachment(ctx, f.tenant.ID, report.Name, bytes.NewReader(report.Content))
+	require.NoError(t, err)
+	require.True(t, created)
+
+	result, err := f.service.SyncMailbox(ctx, f.mailbox.ID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Added)
+	assert.Equal(t, result.LogicalBytes, result.Bytes, "nothing was stored by a committed row before")
+	tenant, err := f.store.Tenants.GetByID(ctx, f.tenant.ID)
+	require.NoError(t, err)
+	assert.Equal(t, result.Bytes, tenant.StorageBytes)
+}
+
+// TestSyncMailboxIsIncremental verifies later syncs only fetch new messages
+func TestSyncMailboxIsIncremental(t *testing.T) {
+	f := setupSyncTest(t, 10)
+	ctx := context.Background()
+	f.addMessage("m1", "inbox")
+
+	_, err := f.service.SyncMailbox(ctx, f.mailbox.ID, nil)
+	require.NoError(t, err)
+
+	f.addMessage("m2", "inbox")
+	f.server.RemoveMessage(testUser, "m1")
+	result, err := f.service.SyncMailbox(ctx, f.mailbox.ID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Added)
+	assert.Equal(t, 1, result.Removed)
+	assert.Equal(t, 0, result.Skipped)
+
+	// Removed upstream, still archived
+	_, err = f.store.Emails.GetByMessageID(ctx, f.mailbox.ID, "m1")
+	assert.NoError(t, err)
+}
+
+// TestSyncMailboxDeltaCommittedAfterMessages verifies a failed round keeps the old
+// delta link and the retry archives the rest without duplicates
+func TestSyncMailboxDeltaCommittedAfterMessages(t *testing.T) {
+	f := setupSyncTest(t, 1)
+	ctx := context.Background()
+	f.addMessage("m1", "inbox")
+	f.addMessage("m2", "inbox")
+
+	f.server.FailNext(1, graphtest.Failure{Path: "/messages/m2/$value", Status: http.StatusBadRequest, Code: "ErrorInvalidRequest"})
+	_, err := f.service.SyncMailbox(ctx, f.mailbox.ID, nil)
+	require.Error(t, err)
+
+	folders, err := f.store.Folders.ListByMailbox(ctx, f.mailbox.ID)
+	require.NoError(t, err)
+	require.Len(t, folders, 1)
+	assert.Nil(t, folders[0].DeltaLink)
+	_, err = f.store.Emails.GetByMessageID(ctx, f.mailbox.ID, "m1")
+	require.NoError(t, err, "messages before the failure are committed")
+
+	result, err := f.service.SyncMailbox(ctx, f.mailbox.ID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Added)
+	assert.Equal(t, 1, result.Skipped)
+
+	mailbox, err := f.store.Mailboxes.GetByID(ctx, f.mailbox.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, mailbox.EmailCount)
+}
+
+// TestSyncMailboxExpiredDelta verifies an expired delta link falls back to a full walk
+func TestSyncMailboxExpiredDelta(t *testing.T) {
+	f := setupSyncTest(t, 10)
+	ctx := context.Background()
+	f.addMessage("m1", "inbox")
+
+	_, err := f.service.SyncMailbox(ctx, f.mailbox.ID, nil)
+	require.NoError(t, err)
+
+	f.server.ExpireDeltaLinks()
+	f.addMessage("m2", "inbox")
+	result, err := f.service.SyncMailbox(ctx, f.mailbox.ID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.FullResyncs)
+	assert.Equal(t, 1, result.Added)
+	assert.Equal(t, 1, result.Skipped)
+}
+
+// TestSyncMailboxSharesMessageBlobs verifies a message delivered to two
+// mailboxes is stored once, with per-mailbox folder and flags, and counts
+// physically towards the mailbox that stored it first
+func TestSyncMailboxSharesMessageBlobs(t *testing.T) {
+	f := setupSyncTest(t, 10)
+	ctx := context.Background()
+
+	const otherUser = "bob@contoso.com"
+	f.server.AddFolder(otherUser, graphtest.Folder{ID: "inbox", DisplayName: "Inbox"})
+	other := &models.Mailbox{TenantID: f.tenant.ID, EmailAddress: otherUser, MailboxType: models.MailboxTypeUser, SyncEnabled: true}
+	require.NoError(t, f.store.Mailboxes.Create(ctx, other))
+
+	msg := graphtest.Message{ID: "m1", FolderID: "inbox", Subject: "Board minutes", From: "carol@contoso.com",
+		To: []string{testUser, otherUser}, SentAt: time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC), Body: "<p>Minutes</p>"}
+	f.server.AddMessage(testUser, msg)
+	msg.Read, msg.FlagStatus = true, models.FlagStatusFlagged
+	f.server.AddMessage(otherUser, msg)
+
+	first, err := f.service.SyncMailbox(ctx, f.mailbox.ID, nil)
+	require.NoError(t, err)
+	second, err := f.service.SyncMailbox(ctx, other.ID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, first.LogicalBytes, second.LogicalBytes)
+	assert.Equal(t, first.LogicalBytes, first.Bytes)
+	assert.Zero(t, second.Bytes, "the second copy reuses the stored MIME")
+
+	mine, err := f.store.Emails.GetByMessageID(ctx, f.mailbox.ID, "m1")
+	require.NoError(t, err)
+	theirs, err := f.store.Emails.GetByMessageID(ctx, other.ID, "m1")
+	require.NoError(t, err)
+	require.NotNil(t, mine.BlobID)
+	assert.Equal(t, *mine.BlobID, *theirs.BlobID)
+	assert.Equal(t, mine.FilePath, theirs.FilePath)
+	assert.NotEqual(t, *mine.FolderID, *theirs.FolderID, "folders are per mailbox")
+	assert.False(t, mine.IsRead)
+	assert.Equal(t, models.FlagStatusNotFlagged, mine.FlagStatus)
+	assert.True(t, theirs.IsRead)
+	assert.Equal(t, models.FlagStatusFlagged, theirs.FlagStatus)
+	refs, err := f.store.MessageBlobs.CountReferences(ctx, *mine.BlobID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, refs)
+
+	tenant, err := f.store.Tenants.GetByID(ctx, f.tenant.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first.Bytes, tenant.StorageBytes)
+	assert.Equal(t, 2*first.LogicalBytes, tenant.LogicalStorageBytes)
+	mailbox, err := f.store.Mailboxes.GetByID(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Zero(t, mailbox.StorageBytes)
+	assert.Equal(t, second.LogicalBytes, mailbox.LogicalStorageBytes)
+
+	// Moved and read upstream: the copy follows without being archived again
+	f.server.AddFolder(testUser, graphtest.Folder{ID: "archive", DisplayName: "Archive"})
+	msg.FolderID, msg.Read, msg.FlagStatus = "archive", true, models.FlagStatusComplete
+	f.server.AddMessage(testUser, msg)
+	result, err := f.service.SyncMailbox(ctx, f.mailbox.ID, nil)
+	require.NoError(t, err)
+	assert.Zero(t, result.Added)
+	moved, err := f.store.Emails.GetByMessageID(ctx, f.mailbox.ID, "m1")
+	require.NoError(t, err)
+	assert.NotEqual(t, *mine.FolderID, *moved.FolderID)
+	assert.True(t, moved.IsRead)
+	assert.Equal(t, models.FlagStatusComplete, moved.FlagStatus)
+}
+
+// TestEmailFromMessage verifies Graph metadata mapping and send time fallbacks
+func TestEmailFromMessage(t *testing.T) {
+	received := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	now := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	msg := graph.Message{
+		ID:                "id-1",
+		From:              &graph.Recipient{EmailAddress: graph.EmailAddress{Address: "a@x.com"}},
+		ToRecipients:      []graph.Recipient{{EmailAddress: graph.EmailAddress{Address: "b@x.com"}}},
+		CcRecipients:      []graph.Recipient{{EmailAddress: graph.EmailAddress{Address: "c@x.com"}}},
+		BccRecipients:     []graph.Recipient{{EmailAddress: graph.EmailAddress{Name: "No address"}}},
+		ReceivedDateTime:  &received,
+		Categories:        []string{"Legal", ""},
+		Body:              &graph.ItemBody{ContentType: "text", Content: "plain"},
+		InternetMessageID: "<reply-1@x.com>",
+		ConversationID:    "AAQkAD",
+		IsRead:            true,
+		Flag:              &graph.FollowupFlag{FlagStatus: "unknown"},
+	}
+
+	email := emailFromMessage("mb", msg, now)
+	assert.Nil(t, email.Subject)
+	assert.Equal(t, []string{"b@x.com", "c@x.com"}, email.Recipients)
+	assert.Equal(t, []string{"Legal"}, email.Categories)
+	assert.Equal(t, received, email.SentAt)
+	assert.Equal(t, "plain", *email.BodyText)
+	assert.Nil(t, email.BodyHTML)
+	assert.Equal(t, "reply-1@x.com", *email.InternetMessageID)
+	assert.Equal(t, "AAQkAD", *email.ConversationID)
+	assert.True(t, email.IsRead)
+	assert.Equal(t, models.FlagStatusNotFlagged, email.FlagStatus)
+
+	msg.ReceivedDateTime = nil
+	assert.Equal(t, now, emailFromMessage("mb", msg, now).SentAt)
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Archive stores message MIME and attachments per tenant. New blobs
+// go to the primary store; reads and deletes are routed by URI scheme, so
+// blobs written to an earlier backend stay readable after switching.
+type Archive struct {
+	primary BlobStore
+	stores  map[string]BlobStore
+}
+
+// NewArchive creates an archive writing to primary and able to read from secondary stores
+func NewArchive(primary BlobStore, secondary ...BlobStore) *Archive {
+	stores := map[string]BlobStore{primary.Scheme(): primary}
+	for _, s := range secondary {
+		if _, exists := stores[s.Scheme()]; !exists {
+			stores[s.Scheme()] = s
+		}
+	}
+	return &Archive{primary: primary, stores: stores}
+}
+
+// Store returns the primary blob store
+func (a *Archive) Store() BlobStore {
+	return a.primary
+}
+
+// Ping checks the primary store
+func (a *Archive) Ping(ctx context.Context) error {
+	return a.primary.Ping(ctx)
+}
+
+// PutMessage stores the raw RFC 822 content of a message addressed by its
+// SHA-256 hash; created is false when the tenant already had identical MIME
+func (a *Archive) PutMessage(ctx context.Context, tenantID string, mime io.Reader) (BlobInfo, bool, error) {
+	info, created, err := a.primary.PutContent(ctx, MessagePrefix(tenantID), ".eml", mime)
+	if err != nil {
+		return BlobInfo{}, false, err
+	}
+	info.URI = FormatURI(a.primary.Scheme(), info.Key)
+	return info, created, nil
+}
+
+// PutAttachment stores attachment content addressed by its SHA-256 hash. The
+// returned BlobInfo.SHA256 is the value for attachments.sha256_hash; created
+// is false when the tenant already had an identical attachment.
+func (a *Archive) PutAttachment(ctx context.Context, tenantID, filename string, content io.Reader) (BlobInfo, bool, error) {
+	info, created, err := a.primary.PutContent(ctx, AttachmentPrefix(tenantID), AttachmentExtension(filename), content)
+	if err != nil {
+		return BlobInfo{}, false, err
+	}
+	info.URI = FormatURI(a.primary.Scheme(), info.Key)
+	return info, created, nil
+}
+
+// Open returns a reader for the blob at uri
+func (a *Archive) Open(ctx context.Context, uri string) (io.ReadCloser, error) {
+	store, key, err := a.resolve(uri)
+	if err != nil {
+		return nil, err
+	}
+	return store.Get(ctx, key)
+}
+
+// Stat returns metadata of the blob at uri
+func (a *Archive) Stat(ctx context.Context, uri string) (BlobInfo, error) {
+	store, key, err := a.resolve(uri)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	info, err := store.Stat(ctx, key)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	info.URI = FormatURI(store.Scheme(), key)
+	return info, nil
+}
+
+// Delete removes the blob at uri
+func (a *Archive) Delete(ctx context.Context, uri string) error {
+	store, key, err := a.resolve(uri)
+	if err != nil {
+		return err
+	}
+	return store.Delete(ctx, key)
+}
+
+// resolve finds the store responsible for a URI
+func (a *Archive) resolve(uri string) (BlobStore, string, error) {
+	scheme, key, err := ParseURI(uri)
+	if err != nil {
+		return nil, "", err
+	}
+	store, ok := a.stores[scheme]
+	if !ok {
+		return nil, "", fmt.Errorf("no storage backend configured for scheme %q", scheme)
+	}
+	return store, key, nil
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"path"
+	"strings"
+)
+
+// maxExtensionLength bounds attachment extensions taken from user-controlled file names
+const maxExtensionLength = 16
+
+// Archive layout (relative to the store root):
+//
+//	tenants/{tenant_uuid}/messages/{sha256}.eml
+//	tenants/{tenant_uuid}/attachments/{sha256}.{extension}
+//
+// Blobs are content-addressed per tenant, so a message delivered to several
+// mailboxes is stored once and tenant data can be deleted independently. The
+// PRD's tenants/{tenant_uuid}/mailboxes/{mailbox_uuid}/emails/{year}/{month}/
+// layout is only found in the file_path of emails archived before
+// single-instance storage; those stay readable by URI.
+
+// AttachmentPrefix returns the content-addressed attachment directory of a tenant
+func AttachmentPrefix(tenantID string) string {
+	return path.Join("tenants", tenantID, "attachments")
+}
+
+// MessagePrefix returns the content-addressed MIME directory of a tenant
+func MessagePrefix(tenantID string) string {
+	return path.Join("tenants", tenantID, "messages")
+}
+
+// TenantPrefix returns the prefix holding all blobs of a tenant
+func TenantPrefix(tenantID string) string {
+	return path.Join("tenants", tenantID)
+}
+
+// AttachmentExtension derives a safe ".ext" suffix from an attachment file
+// name, or "" if it has none usable
+func AttachmentExtension(filename string) string {
+	ext := strings.ToLower(path.Ext(strings.ReplaceAll(filename, `\`, "/")))
+	if len(ext) < 2 || len(ext) > maxExtensionLength+1 {
+		return ""
+	}
+	for _, r := range ext[1:] {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return ""
+		}
+	}
+	return ext
+}
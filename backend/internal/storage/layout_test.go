@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAttachmentExtension verifies only safe extensions are kept
+func TestAttachmentExtension(t *testing.T) {
+	assert.Equal(t, ".pdf", AttachmentExtension("Report.PDF"))
+	assert.Equal(t, ".gz", AttachmentExtension("backup.tar.gz"))
+	assert.Equal(t, "", AttachmentExtension("README"))
+	assert.Equal(t, "", AttachmentExtension("evil.p/df"))
+	assert.Equal(t, "", AttachmentExtension("weird.ext with space"))
+	assert.Equal(t, "", AttachmentExtension("file."))
+}
+
+// TestArchiveAttachmentDedup verifies attachments and messages are deduplicated per tenant by hash
+func TestArchiveAttachmentDedup(t *testing.T) {
+	archive := NewArchive(newTestStore(t))
+	ctx := context.Background()
+
+	a, created, err := archive.PutAttachment(ctx, "t1", "invoice.pdf", strings.NewReader("pdf-bytes"))
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "tenants/t1/attachments/"+a.SHA256+".pdf", a.Key)
+
+	_, created, err = archive.PutAttachment(ctx, "t1", "copy-of-invoice.pdf", strings.NewReader("pdf-bytes"))
+	require.NoError(t, err)
+	assert.False(t, created, "same content in the same tenant is stored once")
+
+	_, created, err = archive.PutAttachment(ctx, "t2", "invoice.pdf", strings.NewReader("pdf-bytes"))
+	require.NoError(t, err)
+	assert.True(t, created, "tenants do not share attachment blobs")
+
+	message, created, err := archive.PutMessage(ctx, "t1", strings.NewReader("Subject: hi\r\n\r\nbody"))
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "tenants/t1/messages/"+message.SHA256+".eml", message.Key)
+	again, created, err := archive.PutMessage(ctx, "t1", strings.NewReader("Subject: hi\r\n\r\nbody"))
+	require.NoError(t, err)
+	assert.False(t, created, "MIME delivered to several mailboxes is stored once")
+	assert.Equal(t, message.URI, again.URI)
+}
@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// tempDirName holds in-flight writes inside the root so renames stay on one filesystem
+const tempDirName = ".tmp"
+
+// File and directory permissions for archived data
+const (
+	filePerm = 0o640
+	dirPerm  = 0o750
+)
+
+// LocalStore is a BlobStore on the local filesystem. Blobs are written to a
+// temporary file, fsynced and renamed into place, so a crash never leaves a
+// partially written blob under its final key.
+type LocalStore struct {
+	root string
+}
+
+// NewLocalStore creates a store rooted at dir, creating it if needed
+func NewLocalStore(dir string) (*LocalStore, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storage path: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, tempDirName), dirPerm); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &LocalStore{root: root}, nil
+}
+
+// Scheme implements BlobStore
+func (s *LocalStore) Scheme() string {
+	return SchemeLocal
+}
+
+// Ping checks that the storage directory is still accessible
+func (s *LocalStore) Ping(ctx context.Context) error {
+	fi, err := os.Stat(filepath.Join(s.root, tempDirName))
+	if err != nil {
+		return fmt.Errorf("storage directory unavailable: %w", err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("storage directory unavailable: %s is not a directory", fi.Name())
+	}
+	return nil
+}
+
+// Root returns the absolute storage directory
+func (s *LocalStore) Root() string {
+	return s.root
+}
+
+// Put writes r to key atomically
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader) (BlobInfo, error) {
+	if err := ValidateKey(key); err != nil {
+		return BlobInfo{}, err
+	}
+
+	tmp, size, sum, err := s.writeTemp(ctx, r)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	if err := s.commit(tmp, key); err != nil {
+		return BlobInfo{}, err
+	}
+	return s.infoWithHash(key, size, sum)
+}
+
+// PutContent writes r under its SHA-256 hash, keeping an existing identical blob
+func (s *LocalStore) PutContent(ctx context.Context, prefix, ext string, r io.Reader) (BlobInfo, bool, error) {
+	if err := ValidateKey(prefix); err != nil {
+		return BlobInfo{}, false, err
+	}
+
+	tmp, size, sum, err := s.writeTemp(ctx, r)
+	if err != nil {
+		return BlobInfo{}, false, err
+	}
+
+	key := contentKey(prefix, sum, ext)
+	if err := ValidateKey(key); err != nil {
+		os.Remove(tmp)
+		return BlobInfo{}, false, err
+	}
+
+	if _, err := os.Stat(s.path(key)); err == nil {
+		os.Remove(tmp)
+		info, err := s.infoWithHash(key, size, sum)
+		return info, false, err
+	}
+
+	// A concurrent writer of the same content may win the rename; the result is identical either way
+	if err := s.commit(tmp, key); err != nil {
+		return BlobInfo{}, false, err
+	}
+	info, err := s.infoWithHash(key, size, sum)
+	return info, true, err
+}
+
+// Get opens a blob for reading
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(s.path(key))
+	if err != nil {
+		return nil, mapFSError(key, err)
+	}
+	return f, nil
+}
+
+// Stat returns blob size and modification time
+func (s *LocalStore) Stat(ctx context.Context, key string) (BlobInfo, error) {
+	if err := ValidateKey(key); err != nil {
+		return BlobInfo{}, err
+	}
+	fi, err := os.Stat(s.path(key))
+	if err != nil {
+		return BlobInfo{}, mapFSError(key, err)
+	}
+	if fi.IsDir() {
+		return BlobInfo{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return BlobInfo{Key: key, Size: fi.Size(), ModTime: fi.ModTime()}, nil
+}
+
+// Delete removes a blob
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(key)); err != nil {
+		return mapFSError(key, err)
+	}
+	return nil
+}
+
+// List walks all blobs under prefix in lexical key order; an empty prefix lists the whole store
+func (s *LocalStore) List(ctx context.Context, prefix string, fn func(BlobInfo) error) error {
+	start := s.root
+	if prefix != "" {
+		if err := ValidateKey(prefix); err != nil {
+			return err
+		}
+		start = s.path(prefix)
+	}
+
+	err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == tempDirName && filepath.Dir(p) == s.root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		return fn(BlobInfo{Key: filepath.ToSlash(rel), Size: fi.Size(), ModTime: fi.ModTime()})
+	})
+	if errors.Is(err, fs.ErrNotExist) && prefix != "" {
+		// A prefix that was never written to simply has no blobs
+		if _, statErr := os.Stat(start); errors.Is(statErr, fs.ErrNotExist) {
+			return nil
+		}
+	}
+	return err
+}
+
+// path maps a validated key to its filesystem location
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+// writeTemp streams r into a synced temporary file and returns its path, size and hex SHA-256
+func (s *LocalStore) writeTemp(ctx context.Context, r io.Reader) (string, int64, string, error) {
+	f, err := os.CreateTemp(filepath.Join(s.root, tempDirName), "blob-*")
+	if err != nil {
+		return "", 0, "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmp := f.Name()
+
+	fail := func(err error) (string, int64, string, error) {
+		f.Close()
+		os.Remove(tmp)
+		return "", 0, "", err
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, hash), contextReader{ctx: ctx, r: r})
+	if err != nil {
+		return fail(fmt.Errorf("failed to write blob: %w", err))
+	}
+	if err := f.Chmod(filePerm); err != nil {
+		return fail(fmt.Errorf("failed to set blob permissions: %w", err))
+	}
+	if err := f.Sync(); err != nil {
+		return fail(fmt.Errorf("failed to sync blob: %w", err))
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return "", 0, "", fmt.Errorf("failed to close blob: %w", err)
+	}
+	return tmp, size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// commit renames a temporary file to its key and syncs the directory so the rename is durable
+func (s *LocalStore) commit(tmp, key string) error {
+	target := s.path(key)
+	dir := filepath.Dir(target)
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to move blob into place: %w", err)
+	}
+	return syncDir(dir)
+}
+
+// infoWithHash stats a committed blob and attaches the hash computed while writing
+func (s *LocalStore) infoWithHash(key string, size int64, sum string) (BlobInfo, error) {
+	fi, err := os.Stat(s.path(key))
+	if err != nil {
+		return BlobInfo{}, mapFSError(key, err)
+	}
+	return BlobInfo{Key: key, Size: size, SHA256: sum, ModTime: fi.ModTime()}, nil
+}
+
+// syncDir fsyncs a directory so newly created entries survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory for sync: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
+
+// mapFSError translates missing files into ErrNotFound
+func mapFSError(key string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return err
+}
+
+// LocalStore implements BlobStore
+var _ BlobStore = (*LocalStore)(nil)
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStore creates a local store in a temporary directory
+func newTestStore(t *testing.T) *LocalStore {
+	t.Helper()
+	store, err := NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	return store
+}
+
+// readBlob reads a whole blob
+func readBlob(t *testing.T, store BlobStore, key string) string {
+	t.Helper()
+	rc, err := store.Get(context.Background(), key)
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return string(data)
+}
+
+// TestLocalStorePutGetStatDelete verifies the basic blob lifecycle
+func TestLocalStorePutGetStatDelete(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	info, err := store.Put(ctx, "a/b/c.json", strings.NewReader("hello"))
+	require.NoError(t, err)
+	sum := sha256.Sum256([]byte("hello"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), info.SHA256)
+	assert.Equal(t, int64(5), info.Size)
+
+	assert.Equal(t, "hello", readBlob(t, store, "a/b/c.json"))
+
+	stat, err := store.Stat(ctx, "a/b/c.json")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), stat.Size)
+
+	// Overwrite replaces the content
+	_, err = store.Put(ctx, "a/b/c.json", strings.NewReader("bye"))
+	require.NoError(t, err)
+	assert.Equal(t, "bye", readBlob(t, store, "a/b/c.json"))
+
+	require.NoError(t, store.Delete(ctx, "a/b/c.json"))
+	_, err = store.Stat(ctx, "a/b/c.json")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.Delete(ctx, "a/b/c.json"), ErrNotFound)
+	_, err = store.Get(ctx, "a/b/c.json")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// failingReader returns some data and then an error
+type failingReader struct{ sent bool }
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if !f.sent {
+		f.sent = true
+		return copy(p, "partial"), nil
+	}
+	return 0, errors.New("connection reset")
+}
+
+// TestLocalStorePutIsAtomic verifies failed writes leave neither the blob nor temporary files
+func TestLocalStorePutIsAtomic(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	_, err := store.Put(ctx, "x/y.json", strings.NewReader("original"))
+	require.NoError(t, err)
+
+	_, err = store.Put(ctx, "x/y.json", &failingReader{})
+	require.Error(t, err)
+	assert.Equal(t, "original", readBlob(t, store, "x/y.json"), "failed write must not clobber the existing blob")
+
+	_, err = store.Put(ctx, "x/new.json", &failingReader{})
+	require.Error(t, err)
+	_, err = store.Stat(ctx, "x/new.json")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	entries, err := os.ReadDir(filepath.Join(store.Root(), tempDirName))
+	require.NoError(t, err)
+	assert.Empty(t, entries, "temporary files must be cleaned up")
+}
+
+// TestLocalStorePutCancelled verifies context cancellation aborts a write
+func TestLocalStorePutCancelled(t *testing.T) {
+	store := newTestStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := store.Put(ctx, "k.json", strings.NewReader("data"))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// TestLocalStorePutContentDeduplicates verifies identical content is stored once
+func TestLocalStorePutContentDeduplicates(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	first, created, err := store.PutContent(ctx, "tenants/t1/attachments", ".pdf", strings.NewReader("same"))
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "tenants/t1/attachments/"+first.SHA256+".pdf", first.Key)
+
+	second, created, err := store.PutContent(ctx, "tenants/t1/attachments", ".pdf", strings.NewReader("same"))
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, first.Key, second.Key)
+	assert.Equal(t, first.SHA256, second.SHA256)
+
+	other, created, err := store.PutContent(ctx, "tenants/t1/attachments", ".pdf", strings.NewReader("different"))
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.NotEqual(t, first.Key, other.Key)
+}
+
+// TestLocalStoreList verifies listing is recursive, ordered and skips temporary files
+func TestLocalStoreList(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"t/b/2.json", "t/a/1.json", "u/3.json"} {
+		_, err := store.Put(ctx, key, strings.NewReader(key))
+		require.NoError(t, err)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(store.Root(), tempDirName, "blob-stale"), []byte("x"), 0o600))
+
+	var keys []string
+	collect := func(info BlobInfo) error {
+		keys = append(keys, info.Key)
+		return nil
+	}
+
+	require.NoError(t, store.List(ctx, "", collect))
+	assert.Equal(t, []string{"t/a/1.json", "t/b/2.json", "u/3.json"}, keys)
+
+	keys = nil
+	require.NoError(t, store.List(ctx, "t", collect))
+	assert.Equal(t, []string{"t/a/1.json", "t/b/2.json"}, keys)
+
+	keys = nil
+	require.NoError(t, store.List(ctx, "missing", collect))
+	assert.Empty(t, keys)
+
+	stop := errors.New("stop")
+	err := store.List(ctx, "", func(BlobInfo) error { return stop })
+	assert.ErrorIs(t, err, stop)
+}
+
+// TestValidateKey verifies keys cannot escape the store or touch internals
+func TestValidateKey(t *testing.T) {
+	assert.NoError(t, ValidateKey("tenants/a/b.json"))
+	for _, key := range []string{"", "/abs", "../up", "a/../../b", "a//b", "a/./b", `a\b`, ".tmp/x", "a/.hidden", "a/"} {
+		assert.ErrorIs(t, ValidateKey(key), ErrInvalidKey, key)
+	}
+
+	store := newTestStore(t)
+	_, err := store.Put(context.Background(), "../escape", strings.NewReader("x"))
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"ironarchive/internal/config"
+)
+
+// Open creates the archive for the configured storage backend. When S3 is
+// selected, an existing local storage directory stays readable so blobs
+// archived before the switch keep resolving through their local: URIs.
+func Open(cfg *config.Config) (*Archive, error) {
+	switch cfg.StorageBackend {
+	case SchemeLocal:
+		local, err := NewLocalStore(cfg.EmailStoragePath)
+		if err != nil {
+			return nil, err
+		}
+		return NewArchive(local), nil
+
+	case SchemeS3:
+		locations, err := ParseS3TenantLocations(cfg.S3TenantLocations)
+		if err != nil {
+			return nil, err
+		}
+		s3, err := NewS3Store(S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			UseSSL:          cfg.S3UseSSL,
+			PathStyle:       cfg.S3PathStyle,
+			Default:         S3Location{Bucket: cfg.S3Bucket, Prefix: cfg.S3Prefix},
+			TenantLocations: locations,
+			SSE:             cfg.S3SSE,
+			SSEKMSKeyID:     cfg.S3SSEKMSKeyID,
+			PartSize:        uint64(cfg.S3PartSizeMB) << 20,
+			MaxRetries:      int(cfg.S3MaxRetries),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var secondary []BlobStore
+		if fi, err := os.Stat(cfg.EmailStoragePath); err == nil && fi.IsDir() {
+			local, err := NewLocalStore(cfg.EmailStoragePath)
+			if err != nil {
+				return nil, err
+			}
+			secondary = append(secondary, local)
+		} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to check local storage path: %w", err)
+		}
+		return NewArchive(s3, secondary...), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported storage backend %q", cfg.StorageBackend)
+	}
+}
@@ -0,0 +1,391 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// Server-side encryption modes
+const (
+	SSENone = ""
+	SSES3   = "AES256"
+	SSEKMS  = "aws:kms"
+)
+
+// Multipart chunk sizes; S3 rejects parts smaller than 5 MiB
+const (
+	DefaultS3PartSize = 16 << 20
+	minS3PartSize     = 5 << 20
+)
+
+// S3Location is a bucket and key prefix blobs are stored under
+type S3Location struct {
+	Bucket string
+	Prefix string
+}
+
+// S3Config configures an S3-compatible blob store
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	UseSSL          bool
+	// PathStyle forces path-style bucket addressing (required by most S3-compatible servers)
+	PathStyle bool
+
+	// Default is where blobs go unless the tenant has its own location
+	Default S3Location
+	// TenantLocations overrides the location per tenant ID
+	TenantLocations map[string]S3Location
+
+	// SSE is "", "AES256" (SSE-S3) or "aws:kms" (SSE-KMS with SSEKMSKeyID)
+	SSE         string
+	SSEKMSKeyID string
+
+	// PartSize is the multipart upload chunk size in bytes (minimum 5 MiB)
+	PartSize uint64
+	// MaxRetries bounds retries of failed requests with exponential backoff
+	MaxRetries int
+	// TempDir spools content-addressed uploads while hashing (default: OS temp dir)
+	TempDir string
+
+	// Transport overrides the HTTP transport (used in tests)
+	Transport http.RoundTripper
+}
+
+// S3Store is a BlobStore on S3-compatible object storage. Large blobs are
+// uploaded with multipart uploads; every object is written with the configured
+// server-side encryption. PUTs are atomic, so readers never see partial blobs.
+type S3Store struct {
+	client *minio.Client
+	cfg    S3Config
+	sse    encrypt.ServerSide
+}
+
+// NewS3Store creates an S3 blob store. Buckets must already exist.
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("S3 endpoint is required")
+	}
+	if cfg.Default.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if cfg.PartSize == 0 {
+		cfg.PartSize = DefaultS3PartSize
+	}
+	if cfg.PartSize < minS3PartSize {
+		return nil, fmt.Errorf("S3 part size must be at least %d MiB", minS3PartSize>>20)
+	}
+
+	var sse encrypt.ServerSide
+	switch cfg.SSE {
+	case SSENone:
+	case SSES3:
+		sse = encrypt.NewSSE()
+	case SSEKMS:
+		kms, err := encrypt.NewSSEKMS(cfg.SSEKMSKeyID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSE-KMS configuration: %w", err)
+		}
+		sse = kms
+	default:
+		return nil, fmt.Errorf("unsupported S3 server-side encryption %q", cfg.SSE)
+	}
+
+	lookup := minio.BucketLookupAuto
+	if cfg.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Secure:       cfg.UseSSL,
+		Region:       cfg.Region,
+		BucketLookup: lookup,
+		MaxRetries:   cfg.MaxRetries,
+		Transport:    cfg.Transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	return &S3Store{client: client, cfg: cfg, sse: sse}, nil
+}
+
+// Scheme implements BlobStore
+func (s *S3Store) Scheme() string {
+	return SchemeS3
+}
+
+// Ping checks that the default bucket is reachable
+func (s *S3Store) Ping(ctx context.Context) error {
+	exists, err := s.client.BucketExists(ctx, s.cfg.Default.Bucket)
+	if err != nil {
+		return fmt.Errorf("failed to reach S3 bucket: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("S3 bucket %q does not exist", s.cfg.Default.Bucket)
+	}
+	return nil
+}
+
+// Put uploads r to key, using multipart uploads for large or unsized streams
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader) (BlobInfo, error) {
+	if err := ValidateKey(key); err != nil {
+		return BlobInfo{}, err
+	}
+
+	hash := sha256.New()
+	info, err := s.upload(ctx, key, io.TeeReader(r, hash), -1)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	info.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return info, nil
+}
+
+// PutContent spools r to a temporary file to compute its hash, then uploads it
+// under prefix/<sha256><ext> unless an identical object already exists
+func (s *S3Store) PutContent(ctx context.Context, prefix, ext string, r io.Reader) (BlobInfo, bool, error) {
+	if err := ValidateKey(prefix); err != nil {
+		return BlobInfo{}, false, err
+	}
+
+	spool, err := os.CreateTemp(s.cfg.TempDir, "ironarchive-s3-*")
+	if err != nil {
+		return BlobInfo{}, false, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hash), contextReader{ctx: ctx, r: r})
+	if err != nil {
+		return BlobInfo{}, false, fmt.Errorf("failed to spool blob: %w", err)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	key := contentKey(prefix, sum, ext)
+	if err := ValidateKey(key); err != nil {
+		return BlobInfo{}, false, err
+	}
+
+	existing, err := s.Stat(ctx, key)
+	if err == nil {
+		existing.SHA256 = sum
+		return existing, false, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return BlobInfo{}, false, err
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return BlobInfo{}, false, fmt.Errorf("failed to rewind spool file: %w", err)
+	}
+	info, err := s.upload(ctx, key, spool, size)
+	if err != nil {
+		return BlobInfo{}, false, err
+	}
+	info.SHA256 = sum
+	return info, true, nil
+}
+
+// Get opens an object for streaming reads
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	bucket, object := s.locate(key)
+
+	obj, err := s.client.GetObject(ctx, bucket, object, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s.mapError(key, err)
+	}
+	// GetObject is lazy; Stat surfaces missing objects before the caller reads
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, s.mapError(key, err)
+	}
+	return obj, nil
+}
+
+// Stat returns object size and modification time
+func (s *S3Store) Stat(ctx context.Context, key string) (BlobInfo, error) {
+	if err := ValidateKey(key); err != nil {
+		return BlobInfo{}, err
+	}
+	bucket, object := s.locate(key)
+
+	info, err := s.client.StatObject(ctx, bucket, object, minio.StatObjectOptions{})
+	if err != nil {
+		return BlobInfo{}, s.mapError(key, err)
+	}
+	return BlobInfo{Key: key, Size: info.Size, ModTime: info.LastModified}, nil
+}
+
+// Delete removes an object; S3 deletes are idempotent, so existence is checked first
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if _, err := s.Stat(ctx, key); err != nil {
+		return err
+	}
+	bucket, object := s.locate(key)
+	if err := s.client.RemoveObject(ctx, bucket, object, minio.RemoveObjectOptions{}); err != nil {
+		return s.mapError(key, err)
+	}
+	return nil
+}
+
+// List iterates objects under prefix. Each location (default and per-tenant)
+// is listed in lexical order; an empty prefix lists every location in turn.
+func (s *S3Store) List(ctx context.Context, prefix string, fn func(BlobInfo) error) error {
+	if prefix != "" {
+		if err := ValidateKey(prefix); err != nil {
+			return err
+		}
+		bucket, object := s.locate(prefix + "/")
+		return s.listLocation(ctx, bucket, object, s.locationPrefix(prefix+"/"), fn)
+	}
+
+	if err := s.listLocation(ctx, s.cfg.Default.Bucket, objectPrefix(s.cfg.Default.Prefix), objectPrefix(s.cfg.Default.Prefix), fn); err != nil {
+		return err
+	}
+
+	tenants := make([]string, 0, len(s.cfg.TenantLocations))
+	for tenantID := range s.cfg.TenantLocations {
+		tenants = append(tenants, tenantID)
+	}
+	sort.Strings(tenants)
+	for _, tenantID := range tenants {
+		loc := s.cfg.TenantLocations[tenantID]
+		if loc == s.cfg.Default {
+			continue
+		}
+		tenantPrefix := TenantPrefix(tenantID) + "/"
+		if err := s.listLocation(ctx, loc.Bucket, objectPrefix(loc.Prefix)+tenantPrefix, objectPrefix(loc.Prefix), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listLocation lists objects under objectPrefix and strips locPrefix to recover keys
+func (s *S3Store) listLocation(ctx context.Context, bucket, objectPrefix, locPrefix string, fn func(BlobInfo) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for obj := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: objectPrefix, Recursive: true}) {
+		if obj.Err != nil {
+			return fmt.Errorf("failed to list S3 objects: %w", obj.Err)
+		}
+		key := strings.TrimPrefix(obj.Key, locPrefix)
+		if ValidateKey(key) != nil {
+			continue
+		}
+		if err := fn(BlobInfo{Key: key, Size: obj.Size, ModTime: obj.LastModified}); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// upload writes an object with the configured part size and encryption
+func (s *S3Store) upload(ctx context.Context, key string, r io.Reader, size int64) (BlobInfo, error) {
+	bucket, object := s.locate(key)
+	info, err := s.client.PutObject(ctx, bucket, object, contextReader{ctx: ctx, r: r}, size, minio.PutObjectOptions{
+		ContentType:          contentType(key),
+		ServerSideEncryption: s.sse,
+		PartSize:             s.cfg.PartSize,
+	})
+	if err != nil {
+		return BlobInfo{}, fmt.Errorf("failed to upload blob %s: %w", key, err)
+	}
+	return BlobInfo{Key: key, Size: info.Size, ModTime: info.LastModified}, nil
+}
+
+// locate maps a key to its bucket and object name
+func (s *S3Store) locate(key string) (string, string) {
+	loc := s.location(key)
+	return loc.Bucket, objectPrefix(loc.Prefix) + key
+}
+
+// locationPrefix returns the object name prefix of the location holding key
+func (s *S3Store) locationPrefix(key string) string {
+	return objectPrefix(s.location(key).Prefix)
+}
+
+// location returns the tenant-specific location for keys under tenants/{id}/, or the default
+func (s *S3Store) location(key string) S3Location {
+	if rest, ok := strings.CutPrefix(key, "tenants/"); ok {
+		tenantID, _, _ := strings.Cut(rest, "/")
+		if loc, ok := s.cfg.TenantLocations[tenantID]; ok {
+			return loc
+		}
+	}
+	return s.cfg.Default
+}
+
+// mapError translates missing objects into ErrNotFound
+func (s *S3Store) mapError(key string, err error) error {
+	switch minio.ToErrorResponse(err).Code {
+	case minio.NoSuchKey, "NotFound":
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return err
+}
+
+// objectPrefix normalizes a configured prefix to "" or "dir/"
+func objectPrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+// contentType guesses the content type stored with an object from its key
+func contentType(key string) string {
+	if path.Ext(key) == ".json" {
+		return "application/json"
+	}
+	return "application/octet-stream"
+}
+
+// ParseS3TenantLocations parses "tenantID=bucket[/prefix]" entries separated by commas
+func ParseS3TenantLocations(spec string) (map[string]S3Location, error) {
+	locations := make(map[string]S3Location)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tenantID, target, ok := strings.Cut(entry, "=")
+		if !ok || tenantID == "" || target == "" {
+			return nil, fmt.Errorf("invalid S3 tenant location %q, expected tenantID=bucket[/prefix]", entry)
+		}
+		bucket, prefix, _ := strings.Cut(target, "/")
+		if bucket == "" {
+			return nil, fmt.Errorf("invalid S3 tenant location %q: bucket is required", entry)
+		}
+		locations[strings.TrimSpace(tenantID)] = S3Location{Bucket: bucket, Prefix: strings.Trim(prefix, "/")}
+	}
+	return locations, nil
+}
+
+// S3Store implements BlobStore
+var _ BlobStore = (*S3Store)(nil)
@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is an in-memory S3 stand-in that records request headers and can inject failures
+type fakeS3 struct {
+	server   *httptest.Server
+	backend  *s3mem.Backend
+	failNext atomic.Int32
+
+	mu      sync.Mutex
+	headers []http.Header
+}
+
+// newFakeS3 starts a fake S3 server with the given buckets
+func newFakeS3(t *testing.T, buckets ...string) *fakeS3 {
+	t.Helper()
+	f := &fakeS3{backend: s3mem.New()}
+	for _, bucket := range buckets {
+		require.NoError(t, f.backend.CreateBucket(bucket))
+	}
+
+	handler := gofakes3.New(f.backend).Server()
+	f.server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.headers = append(f.headers, r.Header.Clone())
+		f.mu.Unlock()
+
+		if f.failNext.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// newStore creates an S3Store pointed at the fake server
+func (f *fakeS3) newStore(t *testing.T, cfg S3Config) *S3Store {
+	t.Helper()
+	cfg.Endpoint = strings.TrimPrefix(f.server.URL, "https://")
+	cfg.UseSSL = true
+	cfg.Transport = f.server.Client().Transport
+	cfg.Region = "us-east-1"
+	cfg.AccessKeyID = "test"
+	cfg.SecretAccessKey = "test"
+	cfg.PathStyle = true
+	cfg.TempDir = t.TempDir()
+	if cfg.Default.Bucket == "" {
+		cfg.Default.Bucket = "archive"
+	}
+	store, err := NewS3Store(cfg)
+	require.NoError(t, err)
+	return store
+}
+
+// sawHeader reports whether any request carried header=value
+func (f *fakeS3) sawHeader(name, value string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, h := range f.headers {
+		if h.Get(name) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// TestS3StoreLifecycle verifies put, get, stat, list and delete against the fake server
+func TestS3StoreLifecycle(t *testing.T) {
+	fake := newFakeS3(t, "archive")
+	store := fake.newStore(t, S3Config{Default: S3Location{Bucket: "archive", Prefix: "prod"}})
+	ctx := context.Background()
+
+	require.NoError(t, store.Ping(ctx))
+
+	info, err := store.Put(ctx, "tenants/t1/mailboxes/m1/emails/2025/01/a.json", strings.NewReader("{}"))
+	require.NoError(t, err)
+	assert.NotEmpty(t, info.SHA256)
+
+	rc, err := store.Get(ctx, "tenants/t1/mailboxes/m1/emails/2025/01/a.json")
+	require.NoError(t, err)
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	assert.Equal(t, "{}", string(data))
+
+	// Objects live under the configured prefix
+	_, err = fake.backend.HeadObject("archive", "prod/tenants/t1/mailboxes/m1/emails/2025/01/a.json")
+	require.NoError(t, err)
+
+	var keys []string
+	require.NoError(t, store.List(ctx, "tenants/t1", func(b BlobInfo) error {
+		keys = append(keys, b.Key)
+		return nil
+	}))
+	assert.Equal(t, []string{"tenants/t1/mailboxes/m1/emails/2025/01/a.json"}, keys)
+
+	require.NoError(t, store.Delete(ctx, "tenants/t1/mailboxes/m1/emails/2025/01/a.json"))
+	_, err = store.Stat(ctx, "tenants/t1/mailboxes/m1/emails/2025/01/a.json")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.Get(ctx, "tenants/t1/mailboxes/m1/emails/2025/01/a.json")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.Delete(ctx, "tenants/t1/mailboxes/m1/emails/2025/01/a.json"), ErrNotFound)
+}
+
+// TestS3StoreMultipartAndDedup verifies large content-addressed uploads and deduplication
+func TestS3StoreMultipartAndDedup(t *testing.T) {
+	fake := newFakeS3(t, "archive")
+	store := fake.newStore(t, S3Config{PartSize: minS3PartSize})
+	ctx := context.Background()
+
+	payload := make([]byte, minS3PartSize*2+1024)
+	_, err := rand.Read(payload)
+	require.NoError(t, err)
+
+	info, created, err := store.PutContent(ctx, "tenants/t1/attachments", ".bin", bytes.NewReader(payload))
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, int64(len(payload)), info.Size)
+	assert.True(t, fake.sawHeader("Content-Type", "application/octet-stream"))
+
+	rc, err := store.Get(ctx, info.Key)
+	require.NoError(t, err)
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	assert.True(t, bytes.Equal(payload, data), "multipart upload should round-trip")
+
+	again, created, err := store.PutContent(ctx, "tenants/t1/attachments", ".bin", bytes.NewReader(payload))
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, info.Key, again.Key)
+}
+
+// TestS3StoreServerSideEncryption verifies SSE headers are sent on uploads
+func TestS3StoreServerSideEncryption(t *testing.T) {
+	fake := newFakeS3(t, "archive")
+	store := fake.newStore(t, S3Config{SSE: SSES3})
+
+	_, err := store.Put(context.Background(), "k.json", strings.NewReader("x"))
+	require.NoError(t, err)
+	assert.True(t, fake.sawHeader("X-Amz-Server-Side-Encryption", "AES256"))
+
+	_, err = NewS3Store(S3Config{Endpoint: "x", Default: S3Location{Bucket: "b"}, SSE: "rot13"})
+	assert.Error(t, err)
+}
+
+// TestS3StoreTenantLocations verifies per-tenant bucket and prefix routing
+func TestS3StoreTenantLocations(t *testing.T) {
+	fake := newFakeS3(t, "archive", "acme-bucket")
+	locations, err := ParseS3TenantLocations("acme=acme-bucket/mail")
+	require.NoError(t, err)
+	store := fake.newStore(t, S3Config{TenantLocations: locations})
+	ctx := context.Background()
+
+	_, err = store.Put(ctx, "tenants/acme/mailboxes/m/emails/2025/01/a.json", strings.NewReader("a"))
+	require.NoError(t, err)
+	_, err = store.Put(ctx, "tenants/other/mailboxes/m/emails/2025/01/b.json", strings.NewReader("b"))
+	require.NoError(t, err)
+
+	_, err = fake.backend.HeadObject("acme-bucket", "mail/tenants/acme/mailboxes/m/emails/2025/01/a.json")
+	require.NoError(t, err)
+	_, err = fake.backend.HeadObject("archive", "tenants/other/mailboxes/m/emails/2025/01/b.json")
+	require.NoError(t, err)
+
+	var keys []string
+	require.NoError(t, store.List(ctx, "", func(b BlobInfo) error {
+		keys = append(keys, b.Key)
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{
+		"tenants/acme/mailboxes/m/emails/2025/01/a.json",
+		"tenants/other/mailboxes/m/emails/2025/01/b.json",
+	}, keys)
+
+	_, err = ParseS3TenantLocations("missing-bucket=")
+	assert.Error(t, err)
+}
+
+// TestS3StoreRetriesTransientErrors verifies 503 responses are retried
+func TestS3StoreRetriesTransientErrors(t *testing.T) {
+	fake := newFakeS3(t, "archive")
+	store := fake.newStore(t, S3Config{MaxRetries: 5})
+
+	fake.failNext.Store(2)
+	_, err := store.Put(context.Background(), "k.json", strings.NewReader("x"))
+	require.NoError(t, err)
+
+	fake.failNext.Store(10)
+	retryless := fake.newStore(t, S3Config{MaxRetries: 1})
+	_, err = retryless.Stat(context.Background(), "k.json")
+	assert.Error(t, err)
+}
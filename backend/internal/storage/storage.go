@@ -0,0 +1,86 @@
+// Package storage persists archived email bodies and attachments as blobs
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when a blob does not exist
+	ErrNotFound = errors.New("blob not found")
+	// ErrInvalidKey is returned for keys that are absolute, unclean or escape the store
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// BlobInfo describes a stored blob
+type BlobInfo struct {
+	// Key is the slash-separated path of the blob relative to the store root
+	Key string
+	// URI is the backend-neutral location stored in file_path columns (set by Archive)
+	URI  string
+	Size int64
+	// SHA256 is the hex content hash; set by Put and PutContent, empty from Stat and List
+	SHA256  string
+	ModTime time.Time
+}
+
+// BlobStore is a streaming key-value store for archive blobs. Writes are
+// atomic: readers see either the previous blob or the complete new one.
+type BlobStore interface {
+	// Scheme names the backend in blob URIs (e.g. "local", "s3")
+	Scheme() string
+	// Ping checks that the backend is reachable and writable
+	Ping(ctx context.Context) error
+	// Put writes r to key, replacing any existing blob
+	Put(ctx context.Context, key string, r io.Reader) (BlobInfo, error)
+	// PutContent writes r to prefix/<sha256><ext>. If a blob with the same
+	// content already exists it is kept and created is false.
+	PutContent(ctx context.Context, prefix, ext string, r io.Reader) (info BlobInfo, created bool, err error)
+	// Get opens a blob for reading; the caller must close it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Stat returns blob metadata without reading its content
+	Stat(ctx context.Context, key string) (BlobInfo, error)
+	// Delete removes a blob
+	Delete(ctx context.Context, key string) error
+	// List calls fn for every blob under prefix, stopping at the first error
+	List(ctx context.Context, prefix string, fn func(BlobInfo) error) error
+}
+
+// ValidateKey checks that key is a clean, relative, slash-separated path
+// without hidden segments (which are reserved for store internals)
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) || path.Clean(key) != key {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+	}
+	return nil
+}
+
+// contentKey returns the content-addressed key for a hash
+func contentKey(prefix, sha256Hex, ext string) string {
+	return prefix + "/" + sha256Hex + ext
+}
+
+// contextReader aborts long streaming copies when the context is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read implements io.Reader
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Backend schemes used in blob URIs
+const (
+	SchemeLocal = "local"
+	SchemeS3    = "s3"
+)
+
+// schemePattern matches RFC 3986 URI schemes
+var schemePattern = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+
+// FormatURI builds the value stored in file_path columns, e.g.
+// "s3:tenants/{uuid}/mailboxes/{uuid}/emails/2025/10/{id}.json". The key part
+// is identical on every backend, so blobs can be copied between backends by
+// rewriting only the scheme; bucket and prefix come from configuration.
+func FormatURI(scheme, key string) string {
+	return scheme + ":" + key
+}
+
+// ParseURI splits a blob URI into scheme and key. Values without a scheme
+// are treated as keys on the local backend.
+func ParseURI(uri string) (string, string, error) {
+	scheme, key, found := strings.Cut(uri, ":")
+	if !found || !schemePattern.MatchString(scheme) {
+		scheme, key = SchemeLocal, uri
+	}
+	if err := ValidateKey(key); err != nil {
+		return "", "", fmt.Errorf("invalid blob URI %q: %w", uri, err)
+	}
+	return scheme, key, nil
+}
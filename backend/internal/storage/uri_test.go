@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseURI verifies scheme parsing and the local fallback for bare keys
+func TestParseURI(t *testing.T) {
+	scheme, key, err := ParseURI("s3:tenants/t/a.json")
+	require.NoError(t, err)
+	assert.Equal(t, SchemeS3, scheme)
+	assert.Equal(t, "tenants/t/a.json", key)
+
+	scheme, key, err = ParseURI("tenants/t/a:b.json")
+	require.NoError(t, err)
+	assert.Equal(t, SchemeLocal, scheme)
+	assert.Equal(t, "tenants/t/a:b.json", key)
+
+	_, _, err = ParseURI("local:../etc/passwd")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	assert.Equal(t, "local:a/b", FormatURI(SchemeLocal, "a/b"))
+}
+
+// TestArchiveRoutesByScheme verifies blobs stay readable on the backend they were written to
+func TestArchiveRoutesByScheme(t *testing.T) {
+	ctx := context.Background()
+	local := newTestStore(t)
+	fake := newFakeS3(t, "archive")
+	s3 := fake.newStore(t, S3Config{})
+
+	old, _, err := NewArchive(local).PutMessage(ctx, "t", strings.NewReader("old"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(old.URI, "local:tenants/t/"))
+
+	archive := NewArchive(s3, local)
+	fresh, _, err := archive.PutMessage(ctx, "t", strings.NewReader("new"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(fresh.URI, "s3:tenants/t/"))
+
+	for uri, want := range map[string]string{old.URI: "old", fresh.URI: "new"} {
+		rc, err := archive.Open(ctx, uri)
+		require.NoError(t, err)
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		assert.Equal(t, want, string(data))
+	}
+
+	_, err = NewArchive(local).Open(ctx, fresh.URI)
+	assert.Error(t, err, "s3 URIs need an s3 backend")
+}
@@ -0,0 +1,126 @@
+package threads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+	"ironarchive/internal/storage"
+)
+
+// Backfiller threads emails archived before threading existed. Their
+// threading headers are read from the archived MIME.
+type Backfiller struct {
+	emails    repositories.EmailRepository
+	tx        txRunner
+	blobs     blobOpener
+	batchSize int
+	logger    *zap.Logger
+}
+
+// txRunner runs fn in a transaction (repositories.Store)
+type txRunner interface {
+	WithTx(ctx context.Context, fn func(repos *repositories.Repositories) error) error
+}
+
+// blobOpener reads archived messages
+type blobOpener interface {
+	Open(ctx context.Context, uri string) (io.ReadCloser, error)
+}
+
+// NewBackfiller creates a thread backfill reading messages from archive
+func NewBackfiller(store *repositories.Store, archive *storage.Archive, logger *zap.Logger) *Backfiller {
+	return &Backfiller{
+		emails:    store.Emails,
+		tx:        store,
+		blobs:     archive,
+		batchSize: 200,
+		logger:    logger,
+	}
+}
+
+// Run threads every unthreaded email and returns. It is safe to run on
+// several replicas at once; an error stops the backfill until the next start.
+func (b *Backfiller) Run(ctx context.Context) {
+	var total int
+	for {
+		n, err := b.BackfillBatch(ctx)
+		total += n
+		if err != nil {
+			if ctx.Err() == nil {
+				b.logger.Error("Thread backfill failed", zap.Int("threaded", total), zap.Error(err))
+			}
+			return
+		}
+		if n < b.batchSize {
+			if total > 0 {
+				b.logger.Info("Thread backfill complete", zap.Int("threaded", total))
+			}
+			return
+		}
+	}
+}
+
+// BackfillBatch threads one batch of unthreaded emails and returns how many
+// were threaded
+func (b *Backfiller) BackfillBatch(ctx context.Context) (int, error) {
+	emails, err := b.emails.ListUnthreaded(ctx, b.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list unthreaded emails: %w", err)
+	}
+	for i := range emails {
+		if err := b.thread(ctx, &emails[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(emails), nil
+}
+
+// thread stores the headers of an email and assigns its thread
+func (b *Backfiller) thread(ctx context.Context, email *models.Email) error {
+	if email.InternetMessageID == nil {
+		header, err := b.readHeader(ctx, email)
+		if err != nil {
+			return err
+		}
+		header.Apply(email)
+	}
+	return b.tx.WithTx(ctx, func(repos *repositories.Repositories) error {
+		if err := repos.Emails.SetThreadHeaders(ctx, email); err != nil {
+			return fmt.Errorf("failed to store threading headers: %w", err)
+		}
+		mailbox, err := repos.Mailboxes.GetByID(ctx, email.MailboxID)
+		if err != nil {
+			return fmt.Errorf("failed to get mailbox: %w", err)
+		}
+		if _, err := repos.Threads.Assign(ctx, mailbox.TenantID, email.ID, Keys(email)); err != nil {
+			return fmt.Errorf("failed to assign thread: %w", err)
+		}
+		return nil
+	})
+}
+
+// readHeader reads the threading headers of an archived email. Missing or
+// unparseable blobs yield no headers, which leaves the email on its own.
+func (b *Backfiller) readHeader(ctx context.Context, email *models.Email) (Header, error) {
+	blob, err := b.blobs.Open(ctx, email.FilePath)
+	if errors.Is(err, storage.ErrNotFound) {
+		b.logger.Warn("Archived message missing, threading it alone", zap.String("email_id", email.ID))
+		return Header{}, nil
+	}
+	if err != nil {
+		return Header{}, fmt.Errorf("failed to open message: %w", err)
+	}
+	defer blob.Close()
+	header, err := ReadHeader(blob)
+	if err != nil {
+		b.logger.Debug("No message header, threading it alone", zap.String("email_id", email.ID), zap.Error(err))
+		return Header{}, nil
+	}
+	return header, nil
+}
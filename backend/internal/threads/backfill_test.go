@@ -0,0 +1,168 @@
+package threads
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+	"ironarchive/internal/storage"
+)
+
+// fakeEmails lists the emails without a thread and records stored headers
+type fakeEmails struct {
+	repositories.EmailRepository
+	emails map[string]*models.Email
+	order  []string
+}
+
+func (f *fakeEmails) ListUnthreaded(_ context.Context, limit int) ([]models.Email, error) {
+	var out []models.Email
+	for _, id := range f.order {
+		if e := f.emails[id]; e.ThreadID == nil && len(out) < limit {
+			out = append(out, *e)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeEmails) SetThreadHeaders(_ context.Context, email *models.Email) error {
+	e := f.emails[email.ID]
+	e.InternetMessageID, e.InReplyTo, e.References = email.InternetMessageID, email.InReplyTo, email.References
+	return nil
+}
+
+type fakeMailboxes struct {
+	repositories.MailboxRepository
+}
+
+func (fakeMailboxes) GetByID(_ context.Context, id string) (*models.Mailbox, error) {
+	return &models.Mailbox{ID: id, TenantID: "tenant-" + id}, nil
+}
+
+// fakeThreads assigns threads like ThreadRepository.Assign: by shared keys
+// within a tenant, merging threads an email links
+type fakeThreads struct {
+	repositories.ThreadRepository
+	emails  *fakeEmails
+	keys    map[string]string
+	created int
+}
+
+func (f *fakeThreads) Assign(_ context.Context, tenantID, emailID string, keys []string) (string, error) {
+	var found []string
+	for _, key := range keys {
+		if id, ok := f.keys[tenantID+"/"+key]; ok {
+			found = append(found, id)
+		}
+	}
+	threadID := ""
+	if len(found) == 0 {
+		f.created++
+		threadID = fmt.Sprintf("thread-%d", f.created)
+	} else {
+		threadID = found[0]
+	}
+	for k, id := range f.keys {
+		for _, merged := range found {
+			if id == merged {
+				f.keys[k] = threadID
+			}
+		}
+	}
+	for _, e := range f.emails.emails {
+		for _, merged := range found {
+			if e.ThreadID != nil && *e.ThreadID == merged {
+				e.ThreadID = &threadID
+			}
+		}
+	}
+	for _, key := range keys {
+		f.keys[tenantID+"/"+key] = threadID
+	}
+	f.emails.emails[emailID].ThreadID = &threadID
+	return threadID, nil
+}
+
+// fakeStore runs transactions on the fakes
+type fakeStore struct {
+	repos *repositories.Repositories
+}
+
+func (f fakeStore) WithTx(_ context.Context, fn func(repos *repositories.Repositories) error) error {
+	return fn(f.repos)
+}
+
+type fakeBlobs map[string]string
+
+func (f fakeBlobs) Open(_ context.Context, uri string) (io.ReadCloser, error) {
+	switch data, ok := f[uri]; {
+	case uri == "broken":
+		return nil, errors.New("connection reset")
+	case !ok:
+		return nil, storage.ErrNotFound
+	default:
+		return io.NopCloser(bytes.NewReader([]byte(data))), nil
+	}
+}
+
+func newBackfillFixture(emails ...models.Email) (*Backfiller, *fakeEmails) {
+	f := &fakeEmails{emails: map[string]*models.Email{}}
+	for i := range emails {
+		f.emails[emails[i].ID] = &emails[i]
+		f.order = append(f.order, emails[i].ID)
+	}
+	repos := &repositories.Repositories{Emails: f, Mailboxes: fakeMailboxes{}, Threads: &fakeThreads{emails: f, keys: map[string]string{}}}
+	return &Backfiller{
+		emails:    f,
+		tx:        fakeStore{repos: repos},
+		blobs:     fakeBlobs{"root.eml": "Message-ID: <root@mail.acme.test>\r\n\r\n", "reply.eml": replyMIME, "json": `{"id": "AAMk"}`},
+		batchSize: 2,
+		logger:    zap.NewNop(),
+	}, f
+}
+
+// TestBackfill verifies archived emails are threaded from their MIME
+// headers, and emails without readable headers are threaded alone
+func TestBackfill(t *testing.T) {
+	b, emails := newBackfillFixture(
+		models.Email{ID: "e1", MailboxID: "m1", FilePath: "root.eml"},
+		models.Email{ID: "e2", MailboxID: "m1", FilePath: "json"},
+		models.Email{ID: "e3", MailboxID: "m1", FilePath: "missing"},
+		models.Email{ID: "e4", MailboxID: "m1", FilePath: "reply.eml"},
+		models.Email{ID: "e5", MailboxID: "m2", FilePath: "root.eml"},
+	)
+	b.Run(context.Background())
+
+	thread := func(id string) string { return *emails.emails[id].ThreadID }
+	assert.Equal(t, thread("e1"), thread("e4"), "the reply references the root")
+	assert.NotEqual(t, thread("e1"), thread("e5"), "threads are per tenant")
+	assert.NotEqual(t, thread("e2"), thread("e3"))
+	assert.Equal(t, "reply-1@mail.acme.test", *emails.emails["e4"].InReplyTo)
+	assert.Equal(t, []string{"root@mail.acme.test", "reply-1@mail.acme.test"}, emails.emails["e4"].References)
+
+	n, err := b.BackfillBatch(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+// TestBackfillStorageFailure verifies unreadable storage stops the backfill
+// with the email left unthreaded
+func TestBackfillStorageFailure(t *testing.T) {
+	b, emails := newBackfillFixture(
+		models.Email{ID: "e1", MailboxID: "m1", FilePath: "root.eml"},
+		models.Email{ID: "e2", MailboxID: "m1", FilePath: "broken"},
+	)
+	n, err := b.BackfillBatch(context.Background())
+	assert.ErrorContains(t, err, "connection reset")
+	assert.Equal(t, 1, n)
+	assert.Nil(t, emails.emails["e2"].ThreadID)
+}
@@ -0,0 +1,153 @@
+package threads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ironarchive/internal/access"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+)
+
+// maxConversationEmails caps the emails returned for a conversation
+const maxConversationEmails = 500
+
+// ErrThreadNotFound is returned for threads that do not exist or have no
+// emails in the caller's scope
+var ErrThreadNotFound = errors.New("thread not found")
+
+// Reader reads conversations as the caller, so row-level security applies
+type Reader struct {
+	sessions access.SessionRunner
+}
+
+// NewReader creates a conversation reader running its queries through sessions
+func NewReader(sessions access.SessionRunner) *Reader {
+	return &Reader{sessions: sessions}
+}
+
+// Conversation is a thread with its messages in scope, oldest first
+type Conversation struct {
+	Thread   models.Thread         `json:"thread"`
+	Messages []ConversationMessage `json:"messages"`
+	// Truncated is set when the thread has more emails than were returned
+	Truncated bool `json:"truncated"`
+}
+
+// ConversationMessage is a message of a conversation. A message archived
+// in several mailboxes is listed once, with a copy per mailbox.
+type ConversationMessage struct {
+	ID                string    `json:"id"` // the email ID of the first copy
+	InternetMessageID string    `json:"internetMessageId,omitempty"`
+	InReplyTo         string    `json:"inReplyTo,omitempty"`
+	Subject           string    `json:"subject"`
+	Sender            string    `json:"sender"`
+	Recipients        []string  `json:"recipients"`
+	SentAt            time.Time `json:"sentAt"`
+	HasAttachments    bool      `json:"hasAttachments"`
+	SizeBytes         int       `json:"sizeBytes"`
+	BodyText          *string   `json:"bodyText,omitempty"`
+	BodyHTML          *string   `json:"bodyHtml,omitempty"`
+	Copies            []Copy    `json:"copies"`
+}
+
+// Copy is an archived email holding a conversation message
+type Copy struct {
+	ID        string `json:"id"`
+	MailboxID string `json:"mailboxId"`
+}
+
+// Conversation returns a thread with the emails in scope, across the
+// mailboxes of its tenant. For a single-mailbox scope the thread summary
+// covers that mailbox only.
+func (r *Reader) Conversation(ctx context.Context, scope access.Scope, threadID string) (*Conversation, error) {
+	var thread *models.Thread
+	var emails []models.Email
+	err := r.sessions.WithSession(ctx, scope.Session, func(repos *repositories.Repositories) error {
+		var err error
+		thread, err = repos.Threads.GetByID(ctx, threadID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			return ErrThreadNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load thread: %w", err)
+		}
+		if scope.TenantID != "" && thread.TenantID != scope.TenantID {
+			return ErrThreadNotFound
+		}
+		emails, err = repos.Threads.ListEmails(ctx, threadID, scope.EmailFilter(), maxConversationEmails+1)
+		if err != nil {
+			return fmt.Errorf("failed to list thread emails: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(emails) == 0 {
+		return nil, ErrThreadNotFound
+	}
+	conversation := &Conversation{Thread: *thread, Messages: []ConversationMessage{}}
+	if len(emails) > maxConversationEmails {
+		emails, conversation.Truncated = emails[:maxConversationEmails], true
+	}
+
+	byMessageID := make(map[string]int)
+	for _, email := range emails {
+		held := Copy{ID: email.ID, MailboxID: email.MailboxID}
+		if email.InternetMessageID != nil {
+			if i, ok := byMessageID[*email.InternetMessageID]; ok {
+				conversation.Messages[i].Copies = append(conversation.Messages[i].Copies, held)
+				continue
+			}
+			byMessageID[*email.InternetMessageID] = len(conversation.Messages)
+		}
+		conversation.Messages = append(conversation.Messages, ConversationMessage{
+			ID:                email.ID,
+			InternetMessageID: deref(email.InternetMessageID),
+			InReplyTo:         deref(email.InReplyTo),
+			Subject:           deref(email.Subject),
+			Sender:            deref(email.Sender),
+			Recipients:        nonNil(email.Recipients),
+			SentAt:            email.SentAt.UTC(),
+			HasAttachments:    email.HasAttachments,
+			SizeBytes:         email.SizeBytes,
+			BodyText:          email.BodyText,
+			BodyHTML:          email.BodyHTML,
+			Copies:            []Copy{held},
+		})
+	}
+
+	if scope.MailboxID != "" {
+		// Other mailboxes of the thread are out of scope
+		first, last := conversation.Messages[0], conversation.Messages[len(conversation.Messages)-1]
+		conversation.Thread.Subject = first.subject()
+		conversation.Thread.MessageCount = len(conversation.Messages)
+		conversation.Thread.FirstSentAt, conversation.Thread.LastSentAt = &first.SentAt, &last.SentAt
+	}
+	return conversation, nil
+}
+
+// subject returns the subject of a message, nil if it has none
+func (m ConversationMessage) subject() *string {
+	if m.Subject == "" {
+		return nil
+	}
+	return &m.Subject
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
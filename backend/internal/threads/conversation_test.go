@@ -0,0 +1,112 @@
+package threads
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ironarchive/internal/access"
+	"ironarchive/internal/database"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+)
+
+const (
+	convTenant    = "11111111-1111-1111-1111-111111111111"
+	convMailbox   = "22222222-2222-2222-2222-222222222222"
+	otherTenant   = "33333333-3333-3333-3333-333333333333"
+	otherMailbox  = "44444444-4444-4444-4444-444444444444"
+	sharedMailbox = "66666666-6666-6666-6666-666666666666"
+	convThread    = "77777777-7777-7777-7777-777777777777"
+)
+
+// fakeSessions runs session-scoped calls on repos and records their sessions
+type fakeSessions struct {
+	repos    *repositories.Repositories
+	sessions []database.Session
+}
+
+func (f *fakeSessions) WithSession(_ context.Context, session database.Session, fn func(repos *repositories.Repositories) error) error {
+	f.sessions = append(f.sessions, session)
+	return fn(f.repos)
+}
+
+// conversationThreads holds one thread of convTenant: a1 and a2 in
+// convMailbox, a copy of a2 and the reply c3 in sharedMailbox
+type conversationThreads struct {
+	repositories.ThreadRepository
+	emails []models.Email
+}
+
+func newConversationThreads() *conversationThreads {
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	email := func(id, mailboxID, messageID, subject string, day int) models.Email {
+		thread := convThread
+		return models.Email{ID: id, MailboxID: mailboxID, InternetMessageID: &messageID, Subject: &subject,
+			SentAt: base.AddDate(0, 0, day), ThreadID: &thread}
+	}
+	return &conversationThreads{emails: []models.Email{
+		email("a1", convMailbox, "a1@test", "Invoice March", 0),
+		email("a2", convMailbox, "a2@test", "Invoice April", 1),
+		email("c2", sharedMailbox, "a2@test", "Invoice April", 1),
+		email("c3", sharedMailbox, "c3@test", "RE: Invoice April", 5),
+	}}
+}
+
+func (f *conversationThreads) GetByID(_ context.Context, id string) (*models.Thread, error) {
+	if id != convThread {
+		return nil, repositories.ErrNotFound
+	}
+	subject := "Invoice March"
+	return &models.Thread{ID: convThread, TenantID: convTenant, Subject: &subject, MessageCount: 3}, nil
+}
+
+func (f *conversationThreads) ListEmails(_ context.Context, threadID string, filter repositories.EmailFilter, limit int) ([]models.Email, error) {
+	var emails []models.Email
+	for _, e := range f.emails {
+		inScope := (filter.TenantID == nil || *filter.TenantID == convTenant) && (filter.MailboxID == nil || *filter.MailboxID == e.MailboxID)
+		if inScope && *e.ThreadID == threadID && len(emails) < limit {
+			emails = append(emails, e)
+		}
+	}
+	return emails, nil
+}
+
+// TestConversation verifies a thread is returned with its messages across
+// the mailboxes in scope, copies grouped
+func TestConversation(t *testing.T) {
+	sessions := &fakeSessions{repos: &repositories.Repositories{Threads: newConversationThreads()}}
+	reader := NewReader(sessions)
+	ctx := context.Background()
+
+	session := database.Session{UserID: "55555555-5555-5555-5555-555555555555", TenantID: convTenant, Role: models.RoleTenantAdmin}
+	conversation, err := reader.Conversation(ctx, access.Scope{TenantID: convTenant, Session: session}, convThread)
+	require.NoError(t, err)
+	assert.Equal(t, []database.Session{session}, sessions.sessions, "threads are read as the caller")
+	assert.Equal(t, 3, conversation.Thread.MessageCount)
+	require.Len(t, conversation.Messages, 3)
+	assert.Equal(t, []Copy{{ID: "a2", MailboxID: convMailbox}, {ID: "c2", MailboxID: sharedMailbox}}, conversation.Messages[1].Copies)
+	assert.Equal(t, "RE: Invoice April", conversation.Messages[2].Subject)
+	assert.False(t, conversation.Truncated)
+
+	// Users see their own mailbox only
+	conversation, err = reader.Conversation(ctx, access.Scope{TenantID: convTenant, MailboxID: convMailbox}, convThread)
+	require.NoError(t, err)
+	require.Len(t, conversation.Messages, 2)
+	assert.Equal(t, 2, conversation.Thread.MessageCount)
+	assert.Equal(t, time.Date(2025, 3, 2, 12, 0, 0, 0, time.UTC), *conversation.Thread.LastSentAt)
+	assert.Len(t, conversation.Messages[1].Copies, 1)
+
+	for name, scope := range map[string]access.Scope{
+		"other tenant":  {TenantID: otherTenant},
+		"other mailbox": {TenantID: convTenant, MailboxID: otherMailbox},
+	} {
+		_, err = reader.Conversation(ctx, scope, convThread)
+		assert.ErrorIs(t, err, ErrThreadNotFound, name)
+	}
+	_, err = reader.Conversation(ctx, access.Scope{}, "88888888-8888-8888-8888-888888888888")
+	assert.ErrorIs(t, err, ErrThreadNotFound)
+}
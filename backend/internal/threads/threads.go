@@ -0,0 +1,155 @@
+// Package threads reconstructs email conversations. Emails are linked by
+// their Message-ID, In-Reply-To and References headers and the Microsoft
+// Graph conversationId; emails sharing any of these keys form a thread.
+package threads
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net/textproto"
+	"strings"
+
+	"ironarchive/internal/models"
+)
+
+// Key prefixes of thread keys
+const (
+	MessageIDKey    = "mid:"
+	ConversationKey = "conv:"
+)
+
+// maxReferences caps the References used as keys. Long threads repeat the
+// same chain, so the oldest and the most recent IDs suffice.
+const maxReferences = 100
+
+// maxHeaderBytes caps the header block read from a message
+const maxHeaderBytes = 64 << 10
+
+// Header holds the threading headers of a message, without angle brackets
+type Header struct {
+	MessageID  string
+	InReplyTo  string
+	References []string
+}
+
+// ParseMessageIDs returns the message IDs of a header value. IDs are taken
+// from angle brackets; values without brackets are split on whitespace, as
+// some clients omit them.
+func ParseMessageIDs(value string) []string {
+	var ids []string
+	rest := value
+	for {
+		start := strings.IndexByte(rest, '<')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(rest[start:], '>')
+		if end < 0 {
+			break
+		}
+		if id := strings.TrimSpace(rest[start+1 : start+end]); id != "" {
+			ids = append(ids, id)
+		}
+		rest = rest[start+end+1:]
+	}
+	if ids != nil || strings.ContainsAny(value, "<>") {
+		return ids
+	}
+	return append(ids, strings.Fields(value)...)
+}
+
+// ReadHeader reads the threading headers of an RFC 822 message. A header
+// block cut short still yields the headers read so far.
+func ReadHeader(r io.Reader) (Header, error) {
+	fields, err := textproto.NewReader(bufio.NewReader(io.LimitReader(r, maxHeaderBytes))).ReadMIMEHeader()
+	if err != nil && len(fields) == 0 {
+		return Header{}, err
+	}
+	var h Header
+	if ids := ParseMessageIDs(fields.Get("Message-Id")); len(ids) > 0 {
+		h.MessageID = ids[0]
+	}
+	if ids := ParseMessageIDs(fields.Get("In-Reply-To")); len(ids) > 0 {
+		h.InReplyTo = ids[0]
+	}
+	for _, value := range fields.Values("References") {
+		h.References = append(h.References, ParseMessageIDs(value)...)
+	}
+	return h, nil
+}
+
+// Apply sets the threading headers of an email. A Message-ID already set
+// (from Graph) is kept.
+func (h Header) Apply(email *models.Email) {
+	if email.InternetMessageID == nil && h.MessageID != "" {
+		id := h.MessageID
+		email.InternetMessageID = &id
+	}
+	if h.InReplyTo != "" {
+		id := h.InReplyTo
+		email.InReplyTo = &id
+	}
+	email.References = append([]string{}, h.References...)
+}
+
+// HeaderCapture is an io.Writer keeping the header block of a message
+// streamed through it, for use with io.TeeReader
+type HeaderCapture struct {
+	buf  bytes.Buffer
+	done bool
+}
+
+// Write keeps p until the end of the header block and never fails
+func (c *HeaderCapture) Write(p []byte) (int, error) {
+	if c.done {
+		return len(p), nil
+	}
+	// The blank line may straddle writes
+	from := max(c.buf.Len()-3, 0)
+	c.buf.Write(p[:min(len(p), maxHeaderBytes-c.buf.Len())])
+	tail := c.buf.Bytes()[from:]
+	if bytes.Contains(tail, []byte("\r\n\r\n")) || bytes.Contains(tail, []byte("\n\n")) || c.buf.Len() >= maxHeaderBytes {
+		c.done = true
+	}
+	return len(p), nil
+}
+
+// Header returns the threading headers captured so far
+func (c *HeaderCapture) Header() (Header, error) {
+	if c.buf.Len() == 0 {
+		return Header{}, errors.New("no message header captured")
+	}
+	return ReadHeader(bytes.NewReader(c.buf.Bytes()))
+}
+
+// Keys returns the thread keys of an email: its own and referenced
+// Message-IDs, and its Graph conversation ID
+func Keys(email *models.Email) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	add := func(prefix, value string) {
+		if value = strings.TrimSpace(value); value != "" && !seen[prefix+value] {
+			seen[prefix+value] = true
+			keys = append(keys, prefix+value)
+		}
+	}
+	if email.InternetMessageID != nil {
+		add(MessageIDKey, *email.InternetMessageID)
+	}
+	if email.InReplyTo != nil {
+		add(MessageIDKey, *email.InReplyTo)
+	}
+	references := email.References
+	if len(references) > maxReferences {
+		references = append(append([]string{}, references[:1]...), references[len(references)-maxReferences+1:]...)
+	}
+	for _, id := range references {
+		add(MessageIDKey, id)
+	}
+	if email.ConversationID != nil {
+		add(ConversationKey, *email.ConversationID)
+	}
+	return keys
+}
@@ -0,0 +1,90 @@
+package threads
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ironarchive/internal/models"
+)
+
+const replyMIME = "Message-ID: <reply-2@mail.acme.test>\r\n" +
+	"In-Reply-To: <reply-1@mail.acme.test>\r\n" +
+	"References: <root@mail.acme.test>\r\n" +
+	"\t<reply-1@mail.acme.test>\r\n" +
+	"Subject: RE: RE: Merger\r\n" +
+	"\r\n" +
+	"In-Reply-To: <body@not.a.header>\r\n"
+
+// TestParseMessageIDs verifies IDs are taken from angle brackets, or from
+// whitespace when a client left them out
+func TestParseMessageIDs(t *testing.T) {
+	for value, want := range map[string][]string{
+		"<a@x> <b@x>":                   {"a@x", "b@x"},
+		"<a@x>,<b@x> (comment)":         {"a@x", "b@x"},
+		"  a@x  b@x ":                   {"a@x", "b@x"},
+		"<>":                            nil,
+		"":                              nil,
+		"Re: your message <a@x> of 3rd": {"a@x"},
+	} {
+		assert.Equal(t, want, ParseMessageIDs(value), value)
+	}
+}
+
+// TestReadHeader verifies threading headers are read, including folded
+// References, and the body is ignored
+func TestReadHeader(t *testing.T) {
+	h, err := ReadHeader(strings.NewReader(replyMIME))
+	require.NoError(t, err)
+	assert.Equal(t, Header{
+		MessageID:  "reply-2@mail.acme.test",
+		InReplyTo:  "reply-1@mail.acme.test",
+		References: []string{"root@mail.acme.test", "reply-1@mail.acme.test"},
+	}, h)
+
+	_, err = ReadHeader(strings.NewReader(`{"id": "AAMk"}`))
+	assert.Error(t, err)
+}
+
+// TestHeaderCapture verifies the header block is kept from a stream read
+// in small pieces
+func TestHeaderCapture(t *testing.T) {
+	var capture HeaderCapture
+	_, err := io.ReadAll(io.TeeReader(iotest.OneByteReader(strings.NewReader(replyMIME+strings.Repeat("body\r\n", 1000))), &capture))
+	require.NoError(t, err)
+	assert.Equal(t, len(replyMIME)-len("In-Reply-To: <body@not.a.header>\r\n"), capture.buf.Len())
+	h, err := capture.Header()
+	require.NoError(t, err)
+	assert.Equal(t, "reply-1@mail.acme.test", h.InReplyTo)
+
+	var empty HeaderCapture
+	_, err = empty.Header()
+	assert.Error(t, err)
+}
+
+// TestApplyAndKeys verifies the keys of an email and that a Graph
+// Message-ID takes precedence over the header
+func TestApplyAndKeys(t *testing.T) {
+	graphID, conversation := "reply-2@mail.acme.test", "AAQkADAw"
+	email := &models.Email{InternetMessageID: &graphID, ConversationID: &conversation}
+	Header{MessageID: "other@x", InReplyTo: "reply-1@mail.acme.test", References: []string{"root@mail.acme.test", "reply-1@mail.acme.test"}}.Apply(email)
+	assert.Equal(t, "reply-2@mail.acme.test", *email.InternetMessageID)
+	assert.Equal(t, []string{"mid:reply-2@mail.acme.test", "mid:reply-1@mail.acme.test", "mid:root@mail.acme.test", "conv:AAQkADAw"}, Keys(email))
+
+	assert.Empty(t, Keys(&models.Email{}))
+
+	var references []string
+	for i := range 150 {
+		references = append(references, fmt.Sprintf("r%d@x", i))
+	}
+	keys := Keys(&models.Email{References: references})
+	require.Len(t, keys, maxReferences)
+	assert.Equal(t, "mid:r0@x", keys[0])
+	assert.Equal(t, "mid:r51@x", keys[1])
+	assert.Equal(t, "mid:r149@x", keys[maxReferences-1])
+}
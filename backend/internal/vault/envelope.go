@@ -0,0 +1,112 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ciphertextPrefix marks values sealed by the vault; the scheme version
+// after "enc:" lets the format evolve without guessing
+const (
+	ciphertextPrefix = "enc:"
+	schemeV1         = "v1"
+)
+
+// dataKeySize is the length of per-tenant data keys (AES-256)
+const dataKeySize = 32
+
+// ErrDecryptionFailed is returned when a ciphertext is corrupt, tampered with
+// or sealed under a different key or tenant
+var ErrDecryptionFailed = errors.New("decryption failed")
+
+// seal encrypts plaintext with AES-256-GCM, binding it to aad, and returns nonce || ciphertext
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open decrypts nonce || ciphertext produced by seal
+func open(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, ErrDecryptionFailed
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
+
+// newGCM creates an AES-GCM AEAD for the given key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// newDataKey generates a random data key
+func newDataKey() ([]byte, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	return key, nil
+}
+
+// formatCiphertext encodes sealed bytes as "enc:v1:<keyVersion>:<base64>"
+func formatCiphertext(keyVersion int, sealed []byte) string {
+	return ciphertextPrefix + schemeV1 + ":" + strconv.Itoa(keyVersion) + ":" + base64.StdEncoding.EncodeToString(sealed)
+}
+
+// parseCiphertext decodes a value produced by formatCiphertext
+func parseCiphertext(value string) (int, []byte, error) {
+	parts := strings.SplitN(strings.TrimPrefix(value, ciphertextPrefix), ":", 3)
+	if len(parts) != 3 || parts[0] != schemeV1 {
+		return 0, nil, fmt.Errorf("%w: unsupported ciphertext format", ErrDecryptionFailed)
+	}
+	version, err := strconv.Atoi(parts[1])
+	if err != nil || version < 1 {
+		return 0, nil, fmt.Errorf("%w: invalid key version", ErrDecryptionFailed)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: invalid encoding", ErrDecryptionFailed)
+	}
+	return version, sealed, nil
+}
+
+// isCiphertext reports whether a stored value was sealed by the vault
+func isCiphertext(value string) bool {
+	return strings.HasPrefix(value, ciphertextPrefix)
+}
+
+// credentialsAAD binds credential ciphertexts to their tenant, so a value
+// copied to another tenant row fails to decrypt
+func credentialsAAD(tenantID string) []byte {
+	return []byte("ironarchive:credentials:" + tenantID)
+}
+
+// dataKeyAAD binds a wrapped data key to its tenant and version
+func dataKeyAAD(tenantID string, version int) []byte {
+	return []byte("ironarchive:data-key:" + tenantID + ":" + strconv.Itoa(version))
+}
@@ -0,0 +1,74 @@
+package vault
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ironarchive/internal/models"
+)
+
+// TestSealOpenRoundTrip verifies AES-GCM sealing and associated data binding
+func TestSealOpenRoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, dataKeySize)
+
+	sealed, err := seal(key, []byte("hello"), []byte("aad"))
+	require.NoError(t, err)
+
+	plaintext, err := open(key, sealed, []byte("aad"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(plaintext))
+
+	_, err = open(key, sealed, []byte("other"))
+	assert.ErrorIs(t, err, ErrDecryptionFailed, "different associated data must fail")
+
+	sealed[len(sealed)-1] ^= 0xff
+	_, err = open(key, sealed, []byte("aad"))
+	assert.ErrorIs(t, err, ErrDecryptionFailed, "tampered ciphertext must fail")
+
+	_, err = open(key, []byte{1, 2}, nil)
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+}
+
+// TestCiphertextFormat verifies the versioned ciphertext encoding
+func TestCiphertextFormat(t *testing.T) {
+	value := formatCiphertext(3, []byte{1, 2, 3})
+	assert.Equal(t, "enc:v1:3:AQID", value)
+	assert.True(t, isCiphertext(value))
+	assert.False(t, isCiphertext(`{"app_id":"x"}`))
+
+	version, sealed, err := parseCiphertext(value)
+	require.NoError(t, err)
+	assert.Equal(t, 3, version)
+	assert.Equal(t, []byte{1, 2, 3}, sealed)
+
+	for _, bad := range []string{"enc:v2:1:AQID", "enc:v1:x:AQID", "enc:v1:0:AQID", "enc:v1:1:***", "enc:v1"} {
+		_, _, err := parseCiphertext(bad)
+		assert.ErrorIs(t, err, ErrDecryptionFailed, bad)
+	}
+}
+
+// TestEncryptCredentialsBoundToTenant verifies ciphertexts cannot be moved between tenants
+func TestEncryptCredentialsBoundToTenant(t *testing.T) {
+	key := bytes.Repeat([]byte{9}, dataKeySize)
+	creds := models.AzureCredentials{ClientID: "client", ClientSecret: "secret"}
+
+	value, err := encryptCredentials(key, 1, "tenant-a", creds)
+	require.NoError(t, err)
+	assert.NotContains(t, value, "secret")
+
+	_, sealed, err := parseCiphertext(value)
+	require.NoError(t, err)
+
+	plaintext, err := open(key, sealed, credentialsAAD("tenant-a"))
+	require.NoError(t, err)
+	decoded, err := decodePayload(plaintext)
+	require.NoError(t, err)
+	assert.Equal(t, "client", decoded.ClientID)
+	assert.Equal(t, "secret", decoded.ClientSecret.Reveal())
+
+	_, err = open(key, sealed, credentialsAAD("tenant-b"))
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+}
@@ -0,0 +1,110 @@
+package vault
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// MasterKeySize is the required master key length (AES-256)
+const MasterKeySize = 32
+
+// defaultKeyID names a master key given without an explicit identifier
+const defaultKeyID = "default"
+
+// keyIDPattern restricts master key identifiers to safe, loggable names
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// ErrNoMasterKey is returned when neither an inline key nor a key file is configured
+var ErrNoMasterKey = errors.New("no vault master key configured")
+
+// Keyring holds the master keys used to wrap tenant data keys. The first key
+// is active and wraps new data keys; the others are kept so data keys wrapped
+// before a master key rotation can still be unwrapped.
+type Keyring struct {
+	activeID string
+	keys     map[string][]byte
+}
+
+// ParseKeyring parses master keys separated by commas or newlines. Each entry
+// is "id:base64key" or a bare base64 key (only allowed as the sole entry).
+// Blank lines and lines starting with # are ignored.
+func ParseKeyring(spec string) (*Keyring, error) {
+	entries := strings.FieldsFunc(spec, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	})
+
+	kr := &Keyring{keys: make(map[string][]byte)}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		id, encoded, found := strings.Cut(entry, ":")
+		if !found {
+			id, encoded = defaultKeyID, entry
+		}
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("invalid master key id %q", id)
+		}
+		if _, exists := kr.keys[id]; exists {
+			return nil, fmt.Errorf("duplicate master key id %q", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("master key %q is not valid base64: %w", id, err)
+		}
+		if len(key) != MasterKeySize {
+			return nil, fmt.Errorf("master key %q must be %d bytes, got %d", id, MasterKeySize, len(key))
+		}
+
+		if kr.activeID == "" {
+			kr.activeID = id
+		}
+		kr.keys[id] = key
+	}
+
+	if kr.activeID == "" {
+		return nil, ErrNoMasterKey
+	}
+	if _, bare := kr.keys[defaultKeyID]; bare && len(kr.keys) > 1 {
+		return nil, fmt.Errorf("every master key needs an id when more than one is configured")
+	}
+	return kr, nil
+}
+
+// LoadKeyring reads master keys from the inline value or, if empty, the key file
+func LoadKeyring(inline, file string) (*Keyring, error) {
+	if inline != "" && file != "" {
+		return nil, fmt.Errorf("configure either an inline master key or a key file, not both")
+	}
+	if file != "" {
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read master key file: %w", err)
+		}
+		return ParseKeyring(string(contents))
+	}
+	return ParseKeyring(inline)
+}
+
+// ActiveID returns the identifier of the key used to wrap new data keys
+func (k *Keyring) ActiveID() string {
+	return k.activeID
+}
+
+// Len returns the number of configured master keys
+func (k *Keyring) Len() int {
+	return len(k.keys)
+}
+
+// key returns the master key with the given identifier
+func (k *Keyring) key(id string) ([]byte, bool) {
+	key, ok := k.keys[id]
+	return key, ok
+}
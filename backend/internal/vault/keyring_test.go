@@ -0,0 +1,72 @@
+package vault
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKey returns a deterministic base64 master key filled with b
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string([]byte{b}), MasterKeySize)))
+}
+
+// TestParseKeyring verifies ids, ordering and the active key
+func TestParseKeyring(t *testing.T) {
+	kr, err := ParseKeyring("new:" + testKey(2) + ", old:" + testKey(1))
+	require.NoError(t, err)
+	assert.Equal(t, "new", kr.ActiveID())
+	assert.Equal(t, 2, kr.Len())
+
+	key, ok := kr.key("old")
+	require.True(t, ok)
+	assert.Equal(t, byte(1), key[0])
+}
+
+// TestParseKeyringBareKey verifies a single key without an id is accepted
+func TestParseKeyringBareKey(t *testing.T) {
+	kr, err := ParseKeyring(testKey(1))
+	require.NoError(t, err)
+	assert.Equal(t, defaultKeyID, kr.ActiveID())
+}
+
+// TestParseKeyringInvalid verifies malformed key specs are rejected
+func TestParseKeyringInvalid(t *testing.T) {
+	cases := map[string]string{
+		"empty":         "",
+		"short key":     "a:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		"bad base64":    "a:not base64!",
+		"duplicate id":  "a:" + testKey(1) + ",a:" + testKey(2),
+		"bad id":        "a b:" + testKey(1),
+		"mixed bare id": testKey(1) + ",a:" + testKey(2),
+	}
+	for name, spec := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseKeyring(spec)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// TestLoadKeyringFromFile verifies key files support comments and one key per line
+func TestLoadKeyringFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	contents := "# rotated 2025-10\nk2:" + testKey(2) + "\n\nk1:" + testKey(1) + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+
+	kr, err := LoadKeyring("", path)
+	require.NoError(t, err)
+	assert.Equal(t, "k2", kr.ActiveID())
+	assert.Equal(t, 2, kr.Len())
+
+	_, err = LoadKeyring("k:"+testKey(1), path)
+	assert.Error(t, err, "inline keys and a key file are mutually exclusive")
+
+	_, err = LoadKeyring("", "")
+	assert.ErrorIs(t, err, ErrNoMasterKey)
+}
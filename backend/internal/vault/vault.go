@@ -0,0 +1,327 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+)
+
+var (
+	// ErrNotConfigured is returned when a tenant has no credentials stored
+	ErrNotConfigured = errors.New("tenant has no Azure credentials configured")
+	// ErrUnknownMasterKey is returned when a data key was wrapped by a master key that is not configured
+	ErrUnknownMasterKey = errors.New("data key was wrapped by an unknown master key")
+	// ErrMissingDataKey is returned when a ciphertext references a data key version that does not exist
+	ErrMissingDataKey = errors.New("data key version not found")
+)
+
+// credentialsPayload is the plaintext sealed into tenants.azure_app_credentials
+// (the documented app_id + app_secret JSON blob)
+type credentialsPayload struct {
+	AppID     string `json:"app_id"`
+	AppSecret string `json:"app_secret"`
+}
+
+// Vault encrypts tenant Azure credentials with envelope encryption: each
+// tenant has its own data key, and data keys are stored wrapped by a master
+// key from configuration. Plaintext secrets only leave the vault as
+// models.Secret, which never serializes.
+type Vault struct {
+	store  *repositories.Store
+	keys   *Keyring
+	logger *zap.Logger
+}
+
+// RotationResult summarizes a key rotation run
+type RotationResult struct {
+	Tenants     int
+	Reencrypted int
+	Failed      int
+}
+
+// New creates a vault using the given repositories and master keys
+func New(store *repositories.Store, keys *Keyring, logger *zap.Logger) *Vault {
+	return &Vault{
+		store:  store,
+		keys:   keys,
+		logger: logger,
+	}
+}
+
+// SetCredentials encrypts and stores a tenant's credentials in its own transaction
+func (v *Vault) SetCredentials(ctx context.Context, tenantID string, creds models.AzureCredentials) error {
+	return v.store.WithTx(ctx, func(repos *repositories.Repositories) error {
+		return v.Seal(ctx, repos, tenantID, creds)
+	})
+}
+
+// Seal encrypts and stores a tenant's credentials using repositories bound to
+// a transaction, e.g. to save credentials atomically with a new tenant. The
+// tenant row is locked so concurrent writes and rotations serialize.
+func (v *Vault) Seal(ctx context.Context, repos *repositories.Repositories, tenantID string, creds models.AzureCredentials) error {
+	if _, err := repos.Tenants.LockCredentials(ctx, tenantID); err != nil {
+		return fmt.Errorf("failed to lock tenant: %w", err)
+	}
+
+	dataKey, version, err := v.currentDataKey(ctx, repos, tenantID)
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := encryptCredentials(dataKey, version, tenantID, creds)
+	if err != nil {
+		return err
+	}
+	if err := repos.Tenants.UpdateCredentials(ctx, tenantID, ciphertext); err != nil {
+		return fmt.Errorf("failed to store credentials: %w", err)
+	}
+	return nil
+}
+
+// Credentials decrypts a tenant's credentials for use by the Graph client
+func (v *Vault) Credentials(ctx context.Context, tenantID string) (*models.AzureCredentials, error) {
+	var creds *models.AzureCredentials
+	err := v.store.WithTx(ctx, func(repos *repositories.Repositories) error {
+		var err error
+		creds, _, err = v.open(ctx, repos, tenantID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// Info returns the API-safe view of a tenant's credentials (no secret)
+func (v *Vault) Info(ctx context.Context, tenantID string) (*models.AzureCredentialsInfo, error) {
+	var info models.AzureCredentialsInfo
+	err := v.store.WithTx(ctx, func(repos *repositories.Repositories) error {
+		creds, version, err := v.open(ctx, repos, tenantID)
+		if errors.Is(err, ErrNotConfigured) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		info = models.AzureCredentialsInfo{Configured: true, ClientID: creds.ClientID, KeyVersion: version}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Rotate re-encrypts every tenant's credentials under a fresh data key wrapped
+// by the active master key, then deletes the superseded data keys. Each tenant
+// is rotated in its own short transaction, so the application keeps running.
+// Plaintext values left from before the vault existed are encrypted as well.
+// After a successful run, master keys other than the active one can be removed.
+func (v *Vault) Rotate(ctx context.Context) (RotationResult, error) {
+	var result RotationResult
+
+	tenantIDs, err := v.store.Tenants.ListIDs(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to list tenants: %w", err)
+	}
+
+	var errs []error
+	for _, tenantID := range tenantIDs {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		result.Tenants++
+
+		var reencrypted bool
+		err := v.store.WithTx(ctx, func(repos *repositories.Repositories) error {
+			var err error
+			reencrypted, err = v.rotateTenant(ctx, repos, tenantID)
+			return err
+		})
+		if err != nil {
+			result.Failed++
+			errs = append(errs, fmt.Errorf("tenant %s: %w", tenantID, err))
+			v.logger.Error("Failed to rotate tenant credentials", zap.String("tenant_id", tenantID), zap.Error(err))
+			continue
+		}
+		if reencrypted {
+			result.Reencrypted++
+		}
+	}
+
+	v.logger.Info("Credential key rotation finished",
+		zap.String("master_key_id", v.keys.ActiveID()),
+		zap.Int("tenants", result.Tenants),
+		zap.Int("reencrypted", result.Reencrypted),
+		zap.Int("failed", result.Failed),
+	)
+	return result, errors.Join(errs...)
+}
+
+// rotateTenant creates the next data key version for a tenant and re-encrypts its credentials
+func (v *Vault) rotateTenant(ctx context.Context, repos *repositories.Repositories, tenantID string) (bool, error) {
+	creds, _, err := v.open(ctx, repos, tenantID)
+	configured := !errors.Is(err, ErrNotConfigured)
+	if err != nil && configured {
+		return false, err
+	}
+
+	latest, err := repos.DataKeys.GetLatest(ctx, tenantID)
+	nextVersion := 1
+	switch {
+	case err == nil:
+		nextVersion = latest.Version + 1
+	case !errors.Is(err, repositories.ErrNotFound):
+		return false, fmt.Errorf("failed to load data key: %w", err)
+	}
+
+	dataKey, err := v.createDataKey(ctx, repos, tenantID, nextVersion)
+	if err != nil {
+		return false, err
+	}
+
+	if configured {
+		ciphertext, err := encryptCredentials(dataKey, nextVersion, tenantID, *creds)
+		if err != nil {
+			return false, err
+		}
+		if err := repos.Tenants.UpdateCredentials(ctx, tenantID, ciphertext); err != nil {
+			return false, fmt.Errorf("failed to store credentials: %w", err)
+		}
+	}
+
+	if _, err := repos.DataKeys.DeleteOlderThan(ctx, tenantID, nextVersion); err != nil {
+		return false, fmt.Errorf("failed to delete superseded data keys: %w", err)
+	}
+	return configured, nil
+}
+
+// open locks the tenant row and decrypts its credentials, returning the data key version used
+func (v *Vault) open(ctx context.Context, repos *repositories.Repositories, tenantID string) (*models.AzureCredentials, int, error) {
+	stored, err := repos.Tenants.LockCredentials(ctx, tenantID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load credentials: %w", err)
+	}
+	if stored == "" {
+		return nil, 0, ErrNotConfigured
+	}
+
+	if !isCiphertext(stored) {
+		v.logger.Warn("Tenant credentials are stored unencrypted; run key rotation to encrypt them",
+			zap.String("tenant_id", tenantID),
+		)
+		creds, err := decodePayload([]byte(stored))
+		return creds, 0, err
+	}
+
+	version, sealed, err := parseCiphertext(stored)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	keys, err := repos.DataKeys.ListByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load data keys: %w", err)
+	}
+	for _, k := range keys {
+		if k.Version != version {
+			continue
+		}
+		dataKey, err := v.unwrap(k)
+		if err != nil {
+			return nil, 0, err
+		}
+		plaintext, err := open(dataKey, sealed, credentialsAAD(tenantID))
+		if err != nil {
+			return nil, 0, err
+		}
+		creds, err := decodePayload(plaintext)
+		return creds, version, err
+	}
+	return nil, 0, fmt.Errorf("%w: version %d", ErrMissingDataKey, version)
+}
+
+// currentDataKey returns the tenant's newest data key, creating version 1 if it has none
+func (v *Vault) currentDataKey(ctx context.Context, repos *repositories.Repositories, tenantID string) ([]byte, int, error) {
+	latest, err := repos.DataKeys.GetLatest(ctx, tenantID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		key, err := v.createDataKey(ctx, repos, tenantID, 1)
+		return key, 1, err
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load data key: %w", err)
+	}
+
+	key, err := v.unwrap(*latest)
+	if err != nil {
+		return nil, 0, err
+	}
+	return key, latest.Version, nil
+}
+
+// createDataKey generates a data key, wraps it with the active master key and stores it
+func (v *Vault) createDataKey(ctx context.Context, repos *repositories.Repositories, tenantID string, version int) ([]byte, error) {
+	dataKey, err := newDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	masterID := v.keys.ActiveID()
+	master, _ := v.keys.key(masterID)
+	wrapped, err := seal(master, dataKey, dataKeyAAD(tenantID, version))
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	err = repos.DataKeys.Create(ctx, &models.TenantDataKey{
+		TenantID:    tenantID,
+		Version:     version,
+		MasterKeyID: masterID,
+		WrappedKey:  wrapped,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// unwrap decrypts a stored data key with the master key it was wrapped by
+func (v *Vault) unwrap(k models.TenantDataKey) ([]byte, error) {
+	master, ok := v.keys.key(k.MasterKeyID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMasterKey, k.MasterKeyID)
+	}
+	dataKey, err := open(master, k.WrappedKey, dataKeyAAD(k.TenantID, k.Version))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key version %d: %w", k.Version, err)
+	}
+	return dataKey, nil
+}
+
+// encryptCredentials seals credentials with a data key into the versioned ciphertext format
+func encryptCredentials(dataKey []byte, version int, tenantID string, creds models.AzureCredentials) (string, error) {
+	plaintext, err := json.Marshal(credentialsPayload{AppID: creds.ClientID, AppSecret: creds.ClientSecret.Reveal()})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode credentials: %w", err)
+	}
+	sealed, err := seal(dataKey, plaintext, credentialsAAD(tenantID))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt credentials: %w", err)
+	}
+	return formatCiphertext(version, sealed), nil
+}
+
+// decodePayload parses decrypted (or legacy plaintext) credentials
+func decodePayload(plaintext []byte) (*models.AzureCredentials, error) {
+	var payload credentialsPayload
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return nil, fmt.Errorf("%w: invalid credentials payload", ErrDecryptionFailed)
+	}
+	return &models.AzureCredentials{ClientID: payload.AppID, ClientSecret: models.Secret(payload.AppSecret)}, nil
+}
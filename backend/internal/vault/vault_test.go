@@ -0,0 +1,119 @@
+package vault
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database/dbtest"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/models"
+)
+
+// setupTestVault connects to the test database, applies migrations and returns a store
+func setupTestVault(t *testing.T) *repositories.Store {
+	t.Helper()
+
+	return repositories.NewStore(dbtest.Connect(t))
+}
+
+// createTestTenant inserts a tenant with the given raw credentials value
+func createTestTenant(t *testing.T, store *repositories.Store, azureID, credentials string) string {
+	t.Helper()
+	tenant := &models.Tenant{Name: "Tenant " + azureID[:4], AzureTenantID: azureID, AzureAppCredentials: credentials}
+	require.NoError(t, store.Tenants.Create(context.Background(), tenant))
+	return tenant.ID
+}
+
+// TestVaultStoresCiphertextOnly verifies secrets are encrypted at rest and decrypt correctly
+func TestVaultStoresCiphertextOnly(t *testing.T) {
+	store := setupTestVault(t)
+	ctx := context.Background()
+
+	keys, err := ParseKeyring("k1:" + testKey(1))
+	require.NoError(t, err)
+	v := New(store, keys, zap.NewNop())
+
+	tenantID := createTestTenant(t, store, "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "")
+
+	info, err := v.Info(ctx, tenantID)
+	require.NoError(t, err)
+	assert.False(t, info.Configured)
+
+	require.NoError(t, v.SetCredentials(ctx, tenantID, models.AzureCredentials{ClientID: "app", ClientSecret: "crown-jewel"}))
+
+	tenant, err := store.Tenants.GetByID(ctx, tenantID)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(tenant.AzureAppCredentials, "enc:v1:1:"))
+	assert.NotContains(t, tenant.AzureAppCredentials, "crown-jewel")
+
+	creds, err := v.Credentials(ctx, tenantID)
+	require.NoError(t, err)
+	assert.Equal(t, "crown-jewel", creds.ClientSecret.Reveal())
+
+	info, err = v.Info(ctx, tenantID)
+	require.NoError(t, err)
+	assert.Equal(t, models.AzureCredentialsInfo{Configured: true, ClientID: "app", KeyVersion: 1}, *info)
+}
+
+// TestVaultRotateMasterKey verifies rotation re-encrypts rows so the old master key can be removed
+func TestVaultRotateMasterKey(t *testing.T) {
+	store := setupTestVault(t)
+	ctx := context.Background()
+
+	oldKeys, err := ParseKeyring("old:" + testKey(1))
+	require.NoError(t, err)
+	tenantID := createTestTenant(t, store, "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "")
+	require.NoError(t, New(store, oldKeys, zap.NewNop()).SetCredentials(ctx, tenantID, models.AzureCredentials{ClientID: "app", ClientSecret: "s1"}))
+
+	// A tenant created before the vault existed still holds plaintext JSON
+	legacyID := createTestTenant(t, store, "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", `{"app_id":"legacy","app_secret":"s2"}`)
+
+	rotatingKeys, err := ParseKeyring("new:" + testKey(2) + ",old:" + testKey(1))
+	require.NoError(t, err)
+	result, err := New(store, rotatingKeys, zap.NewNop()).Rotate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, RotationResult{Tenants: 2, Reencrypted: 2}, result)
+
+	dataKeys, err := store.DataKeys.ListByTenant(ctx, tenantID)
+	require.NoError(t, err)
+	require.Len(t, dataKeys, 1, "superseded data keys should be deleted")
+	assert.Equal(t, 2, dataKeys[0].Version)
+	assert.Equal(t, "new", dataKeys[0].MasterKeyID)
+
+	// The old master key is no longer needed
+	newKeys, err := ParseKeyring("new:" + testKey(2))
+	require.NoError(t, err)
+	v := New(store, newKeys, zap.NewNop())
+
+	creds, err := v.Credentials(ctx, tenantID)
+	require.NoError(t, err)
+	assert.Equal(t, "s1", creds.ClientSecret.Reveal())
+
+	legacy, err := v.Credentials(ctx, legacyID)
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", legacy.ClientID)
+	tenant, err := store.Tenants.GetByID(ctx, legacyID)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(tenant.AzureAppCredentials, "enc:v1:1:"))
+}
+
+// TestVaultUnknownMasterKey verifies a missing master key surfaces a clear error
+func TestVaultUnknownMasterKey(t *testing.T) {
+	store := setupTestVault(t)
+	ctx := context.Background()
+
+	keys, err := ParseKeyring("k1:" + testKey(1))
+	require.NoError(t, err)
+	tenantID := createTestTenant(t, store, "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "")
+	require.NoError(t, New(store, keys, zap.NewNop()).SetCredentials(ctx, tenantID, models.AzureCredentials{ClientID: "app", ClientSecret: "s"}))
+
+	otherKeys, err := ParseKeyring("k2:" + testKey(2))
+	require.NoError(t, err)
+	_, err = New(store, otherKeys, zap.NewNop()).Credentials(ctx, tenantID)
+	assert.ErrorIs(t, err, ErrUnknownMasterKey)
+}
@@ -0,0 +1,176 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/graph"
+	"ironarchive/internal/models"
+	"ironarchive/internal/progress"
+	"ironarchive/internal/scheduler"
+	"ironarchive/internal/services"
+)
+
+// ErrUnsupportedJob is returned for job types the worker does not handle
+var ErrUnsupportedJob = errors.New("unsupported job type")
+
+// SyncJobResult is stored as the result of a finished sync job
+type SyncJobResult struct {
+	services.SyncResult
+	Mailboxes       int      `json:"mailboxes"`
+	FailedMailboxes []string `json:"failedMailboxes,omitempty"`
+}
+
+// SyncWorker runs SYNC_MAILBOX, SYNC_TENANT and SYNC_ALL jobs
+type SyncWorker struct {
+	store    *repositories.Store
+	sync     *services.SyncService
+	progress *progress.Publisher
+	logger   *zap.Logger
+}
+
+// NewSyncWorker creates a sync worker that publishes live progress to
+// events, if not nil
+func NewSyncWorker(store *repositories.Store, sync *services.SyncService, events *progress.Publisher, logger *zap.Logger) *SyncWorker {
+	return &SyncWorker{store: store, sync: sync, progress: events, logger: logger}
+}
+
+// Process runs a sync job to completion and records its status, progress and
+// result in the jobs table. Tenant and global jobs keep going when a single
+// mailbox fails and fail the job at the end, unless the tenant's credentials
+// are rejected. Failures are returned, not recorded: the queue decides from
+// Retryable and the attempts left whether the job is retried or failed.
+func (w *SyncWorker) Process(ctx context.Context, job *models.Job) error {
+	mailboxes, err := w.mailboxes(ctx, job)
+	if err != nil {
+		return err
+	}
+	if err := w.store.Jobs.MarkRunning(ctx, job.ID); err != nil {
+		return fmt.Errorf("failed to mark job running: %w", err)
+	}
+
+	result := SyncJobResult{Mailboxes: len(mailboxes)}
+	tracker := w.progress.Track(job)
+	tracker.Update(ctx, func(e *progress.Event) { e.Total = len(mailboxes) })
+	defer tracker.Flush(ctx)
+	percent := 0
+	// Tenants whose app registration was rejected; their other mailboxes would fail the same way
+	denied := make(map[string]error)
+	var lastErr error
+	for i, mailbox := range mailboxes {
+		// Stop between mailboxes once the job is cancelled or the worker shuts down
+		if ctx.Err() != nil {
+			lastErr = context.Cause(ctx)
+			break
+		}
+		if err, ok := denied[mailbox.TenantID]; ok {
+			result.FailedMailboxes = append(result.FailedMailboxes, mailbox.ID)
+			lastErr = err
+			continue
+		}
+		report := func(done, total int, sofar services.SyncResult) {
+			current := (i*100 + done*100/total) / len(mailboxes)
+			tracker.Update(ctx, func(e *progress.Event) {
+				e.Progress = current
+				e.Items = result.Added + result.Skipped + sofar.Added + sofar.Skipped
+				e.Bytes = result.Bytes + sofar.Bytes
+			})
+			// The jobs row follows folder by folder; live updates go through the tracker
+			if current != percent {
+				percent = current
+				if err := w.store.Jobs.UpdateProgress(ctx, job.ID, percent); err != nil {
+					w.logger.Warn("Failed to update job progress", zap.String("job_id", job.ID), zap.Error(err))
+				}
+			}
+		}
+		mailboxResult, err := w.sync.SyncMailbox(ctx, mailbox.ID, report)
+		result.Add(mailboxResult)
+		tracker.Update(ctx, func(e *progress.Event) {
+			e.Done = i + 1
+			e.Items = result.Added + result.Skipped
+			e.Bytes = result.Bytes
+		})
+		if err != nil {
+			w.logger.Error("Mailbox sync failed",
+				zap.String("job_id", job.ID),
+				zap.String("mailbox_id", mailbox.ID),
+				zap.Bool("permanent", graph.IsPermanent(err)),
+				zap.Error(err),
+			)
+			result.FailedMailboxes = append(result.FailedMailboxes, mailbox.ID)
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			if graph.IsAccessDenied(err) {
+				denied[mailbox.TenantID] = err
+			}
+		}
+	}
+
+	if encoded, err := json.Marshal(result); err == nil {
+		if err := w.store.Jobs.UpdateResult(context.WithoutCancel(ctx), job.ID, encoded); err != nil {
+			w.logger.Warn("Failed to store job result", zap.String("job_id", job.ID), zap.Error(err))
+		}
+	}
+
+	if lastErr != nil {
+		if len(mailboxes) > 1 && ctx.Err() == nil {
+			lastErr = fmt.Errorf("%d of %d mailboxes failed, last error: %w", len(result.FailedMailboxes), len(mailboxes), lastErr)
+		}
+		return lastErr
+	}
+	if err := w.store.Jobs.MarkCompleted(ctx, job.ID); err != nil {
+		return fmt.Errorf("failed to mark job completed: %w", err)
+	}
+	return nil
+}
+
+// Retryable reports whether a failed sync job is worth another attempt;
+// rejected credentials, removed mailboxes or malformed jobs fail the same way
+// again
+func (w *SyncWorker) Retryable(err error) bool {
+	return !graph.IsPermanent(err) && !errors.Is(err, repositories.ErrNotFound) && !errors.Is(err, ErrUnsupportedJob)
+}
+
+// mailboxes resolves the mailboxes a job covers. Scheduled tenant and global
+// jobs leave out mailboxes that follow a more specific schedule.
+func (w *SyncWorker) mailboxes(ctx context.Context, job *models.Job) ([]models.Mailbox, error) {
+	switch job.Type {
+	case models.JobTypeSyncMailbox:
+		if job.MailboxID == nil {
+			return nil, fmt.Errorf("%s job without mailbox", job.Type)
+		}
+		mailbox, err := w.store.Mailboxes.GetByID(ctx, *job.MailboxID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load mailbox: %w", err)
+		}
+		return []models.Mailbox{*mailbox}, nil
+	case models.JobTypeSyncTenant:
+		if job.TenantID == nil {
+			return nil, fmt.Errorf("%s job without tenant", job.Type)
+		}
+		if scheduled(job) {
+			return w.store.Mailboxes.ListInheritingSchedule(ctx, job.TenantID)
+		}
+		return w.store.Mailboxes.ListSyncEnabled(ctx, job.TenantID)
+	case models.JobTypeSyncAll:
+		if scheduled(job) {
+			return w.store.Mailboxes.ListInheritingSchedule(ctx, nil)
+		}
+		return w.store.Mailboxes.ListSyncEnabled(ctx, nil)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedJob, job.Type)
+	}
+}
+
+// scheduled reports whether the scheduler enqueued a job
+func scheduled(job *models.Job) bool {
+	var tick scheduler.TickMetadata
+	return len(job.Metadata) > 0 && json.Unmarshal(job.Metadata, &tick) == nil && tick.Trigger == scheduler.TriggerSchedule
+}
@@ -0,0 +1,82 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"ironarchive/internal/database/dbtest"
+	"ironarchive/internal/database/repositories"
+	"ironarchive/internal/graph"
+	"ironarchive/internal/graph/graphtest"
+	"ironarchive/internal/models"
+	"ironarchive/internal/services"
+	"ironarchive/internal/storage"
+)
+
+const testAzureTenant = "33333333-3333-3333-3333-333333333333"
+
+// staticCredentials returns the same app credentials for every tenant
+type staticCredentials models.AzureCredentials
+
+func (c staticCredentials) Credentials(context.Context, string) (*models.AzureCredentials, error) {
+	creds := models.AzureCredentials(c)
+	return &creds, nil
+}
+
+// setupTestStore connects to the test database and applies migrations
+func setupTestStore(t *testing.T) *repositories.Store {
+	t.Helper()
+
+	return repositories.NewStore(dbtest.Connect(t))
+}
+
+// TestSyncWorkerTenantJob verifies a tenant job syncs every enabled mailbox and
+// fails with a per-mailbox summary when one of them cannot be synced, leaving
+// the job's status for the queue to settle
+func TestSyncWorkerTenantJob(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	server := graphtest.NewServer(testAzureTenant, "app-id", "app-secret")
+	t.Cleanup(server.Close)
+	server.AddFolder("alice@contoso.com", graphtest.Folder{ID: "inbox", DisplayName: "Inbox"})
+	server.AddMessage("alice@contoso.com", graphtest.Message{ID: "m1", FolderID: "inbox", Subject: "Hi", SentAt: time.Now()})
+
+	graphClient, err := graph.NewClient(graph.Config{BaseURL: server.BaseURL(), LoginURL: server.LoginURL()})
+	require.NoError(t, err)
+	local, err := storage.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	creds := staticCredentials{ClientID: "app-id", ClientSecret: "app-secret"}
+	sync := services.NewSyncService(store, creds, graphClient, storage.NewArchive(local), zap.NewNop())
+	worker := NewSyncWorker(store, sync, nil, zap.NewNop())
+
+	tenant := &models.Tenant{Name: "Contoso", AzureTenantID: testAzureTenant}
+	require.NoError(t, store.Tenants.Create(ctx, tenant))
+	for _, address := range []string{"alice@contoso.com", "gone@contoso.com"} {
+		mailbox := &models.Mailbox{TenantID: tenant.ID, EmailAddress: address, MailboxType: models.MailboxTypeUser, SyncEnabled: true}
+		require.NoError(t, store.Mailboxes.Create(ctx, mailbox))
+	}
+
+	job := &models.Job{Type: models.JobTypeSyncTenant, TenantID: &tenant.ID}
+	require.NoError(t, store.Jobs.Create(ctx, job))
+	err = worker.Process(ctx, job)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 of 2 mailboxes failed")
+
+	stored, err := store.Jobs.GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusRunning, stored.Status, "the queue decides between a retry and failing the job")
+	assert.Nil(t, stored.ErrorMessage)
+
+	var result SyncJobResult
+	require.NoError(t, json.Unmarshal(stored.Result, &result))
+	assert.Equal(t, 2, result.Mailboxes)
+	assert.Equal(t, 1, result.Added)
+	assert.Len(t, result.FailedMailboxes, 1)
+}
@@ -0,0 +1,66 @@
+package errors
+
+import "net/http"
+
+// AppError is the domain error returned by services and rendered by the API error handler
+type AppError struct {
+	Code       string                 `json:"code"`
+	Message    string                 `json:"message"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	StatusCode int                    `json:"-"`
+}
+
+// Error implements the error interface
+func (e *AppError) Error() string {
+	return e.Message
+}
+
+// WithDetails attaches additional context to the error and returns it
+func (e *AppError) WithDetails(details map[string]interface{}) *AppError {
+	e.Details = details
+	return e
+}
+
+// New creates an AppError with an explicit code and HTTP status
+func New(statusCode int, code, message string) *AppError {
+	return &AppError{
+		Code:       code,
+		Message:    message,
+		StatusCode: statusCode,
+	}
+}
+
+// NewBadRequest creates a 400 error
+func NewBadRequest(message string) *AppError {
+	return New(http.StatusBadRequest, "BAD_REQUEST", message)
+}
+
+// NewUnauthorized creates a 401 error
+func NewUnauthorized(message string) *AppError {
+	return New(http.StatusUnauthorized, "UNAUTHORIZED", message)
+}
+
+// NewForbidden creates a 403 error
+func NewForbidden(message string) *AppError {
+	return New(http.StatusForbidden, "FORBIDDEN", message)
+}
+
+// NewNotFound creates a 404 error
+func NewNotFound(message string) *AppError {
+	return New(http.StatusNotFound, "NOT_FOUND", message)
+}
+
+// NewConflict creates a 409 error
+func NewConflict(message string) *AppError {
+	return New(http.StatusConflict, "CONFLICT", message)
+}
+
+// NewInternal creates a 500 error
+func NewInternal(message string) *AppError {
+	return New(http.StatusInternalServerError, "INTERNAL_ERROR", message)
+}
+
+// NewServiceUnavailable creates a 503 error
+func NewServiceUnavailable(message string) *AppError {
+	return New(http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", message)
+}